@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -8,17 +9,26 @@ import (
 	"time"
 
 	"gokube/pkg/api/server"
+	"gokube/pkg/listwatch"
 	"gokube/pkg/storage"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	address        string
-	etcdPeerPort   int
-	etcdClientPort int
+	address                     string
+	metricsAddress              string
+	etcdPeerPort                int
+	etcdClientPort              int
+	compactionInterval          time.Duration
+	maxRetainedRevisions        int64
+	validateNodeNames           bool
+	maxWatchDuration            time.Duration
+	maxRequestsInFlight         int
+	maxMutatingRequestsInFlight int
 )
 
 func main() {
@@ -34,8 +44,15 @@ func main() {
 	}
 
 	rootCmd.Flags().StringVar(&address, "address", ":8080", `The address to serve on (default ":8080")`)
+	rootCmd.Flags().StringVar(&metricsAddress, "metrics-address", listwatch.DefaultMetricsServerOptions().Addr, `The address to serve the Prometheus metrics, such as the storage operation metrics, on (empty disables)`)
 	rootCmd.Flags().IntVar(&etcdPeerPort, "etcd-peer-port", 0, `The port to start etcd peer on (default random port)`)
 	rootCmd.Flags().IntVar(&etcdClientPort, "etcd-client-port", 2379, `The port to start etcd client on (default 2379)`)
+	rootCmd.Flags().BoolVar(&validateNodeNames, "validate-pod-node-names", false, `Reject pods whose node name doesn't refer to an existing Ready node`)
+	rootCmd.Flags().DurationVar(&maxWatchDuration, "max-watch-duration", 0, `How long a watch is served before it is closed with a bookmark to reconnect from (0 disables)`)
+	rootCmd.Flags().IntVar(&maxRequestsInFlight, "max-requests-inflight", server.DefaultOptions().MaxRequestsInFlight, `Read-only requests served at once, the requests over it get a 429 (0 disables)`)
+	rootCmd.Flags().IntVar(&maxMutatingRequestsInFlight, "max-mutating-requests-inflight", server.DefaultOptions().MaxMutatingRequestsInFlight, `Mutating requests served at once, the requests over it get a 429 (0 disables)`)
+	rootCmd.Flags().DurationVar(&compactionInterval, "compaction-interval", storage.DefaultCompactorOptions().Interval, `How often to compact etcd history not needed by active watchers (0 disables)`)
+	rootCmd.Flags().Int64Var(&maxRetainedRevisions, "max-retained-revisions", storage.DefaultCompactorOptions().MaxRetainedRevisions, `Revisions behind the current one kept for lagging watchers, older ones are compacted regardless (0 keeps all revisions watchers need)`)
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -63,15 +80,51 @@ func runAPIServer() error {
 	}
 	defer cli.Close()
 
-	store := storage.NewEtcdStorage(cli)
-	apiServer := server.NewAPIServer(store)
+	store, err := storage.NewEtcdStorageWithMetrics(cli, prometheus.DefaultRegisterer)
+	if err != nil {
+		storage.StopEmbeddedEtcd(etcdServer)
+		return err
+	}
+	if metricsAddress != "" {
+		metricsOpts := listwatch.DefaultMetricsServerOptions()
+		metricsOpts.Addr = metricsAddress
+		metricsServer, err := listwatch.NewMetricsServer(metricsOpts)
+		if err == nil {
+			err = metricsServer.Start()
+		}
+		if err != nil {
+			storage.StopEmbeddedEtcd(etcdServer)
+			return fmt.Errorf("failed to serve metrics: %v", err)
+		}
+		defer func() { _ = metricsServer.Shutdown(context.Background()) }()
+		fmt.Printf("Serving metrics on %s%s\n", metricsServer.Addr(), metricsOpts.MetricsPath)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if compactionInterval > 0 {
+		compactorOpts := storage.DefaultCompactorOptions()
+		compactorOpts.Interval = compactionInterval
+		compactorOpts.MaxRetainedRevisions = maxRetainedRevisions
+		go store.StartCompactorWithOptions(ctx, compactorOpts)
+	}
+	opts := server.DefaultOptions()
+	opts.ValidatePodNodeNames = validateNodeNames
+	opts.MaxWatchDuration = maxWatchDuration
+	opts.MaxRequestsInFlight = maxRequestsInFlight
+	opts.MaxMutatingRequestsInFlight = maxMutatingRequestsInFlight
+	apiServer := server.NewAPIServerWithOptions(store, opts)
+	if err := apiServer.MigrateLegacyPods(ctx); err != nil {
+		storage.StopEmbeddedEtcd(etcdServer)
+		return err
+	}
 
 	fmt.Printf("Starting API server on %s\n", address)
 
 	// Start the API server in a goroutine
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- apiServer.Start(address)
+		errCh <- apiServer.Start(ctx, address)
 	}()
 
 	// Wait for either an error or shutdown signal
@@ -81,6 +134,8 @@ func runAPIServer() error {
 		return err
 	case <-stopCh:
 		fmt.Println("\nReceived shutdown signal. Stopping services...")
+		cancel()
+		<-errCh
 		storage.StopEmbeddedEtcd(etcdServer)
 		return nil
 	}
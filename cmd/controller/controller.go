@@ -6,18 +6,26 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"gokube/pkg/controller"
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/leaderelection"
 	"gokube/pkg/registry"
 	"gokube/pkg/storage"
 
 	"github.com/spf13/cobra"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 var (
-	apiServerURL string
-	etcdPort     int
+	apiServerURL  string
+	etcdPort      int
+	resyncPeriod  time.Duration
+	workers       int
+	podEviction   time.Duration
+	etcdSecurity  etcdclient.Security
+	leaderElect   bool
+	leaseDuration time.Duration
 )
 
 func main() {
@@ -34,6 +42,16 @@ func main() {
 
 	rootCmd.Flags().StringVar(&apiServerURL, "api-server", "localhost:8080", "URL of the API server")
 	rootCmd.Flags().IntVar(&etcdPort, "etcd-port", 2379, "Port of the etcd server")
+	rootCmd.Flags().StringVar(&etcdSecurity.CAFile, "etcd-cafile", "", "CA bundle to verify the etcd server certificate with; setting a TLS file connects over TLS")
+	rootCmd.Flags().StringVar(&etcdSecurity.CertFile, "etcd-certfile", "", "Client certificate to authenticate to etcd with")
+	rootCmd.Flags().StringVar(&etcdSecurity.KeyFile, "etcd-keyfile", "", "Key of the etcd client certificate")
+	rootCmd.Flags().StringVar(&etcdSecurity.Username, "etcd-username", "", "Username to authenticate to etcd with")
+	rootCmd.Flags().StringVar(&etcdSecurity.Password, "etcd-password", "", "Password to authenticate to etcd with")
+	rootCmd.Flags().DurationVar(&resyncPeriod, "resync-period", controller.DefaultOptions().ResyncPeriod, "Interval of the full sweep that reconciles every ReplicaSet and Deployment")
+	rootCmd.Flags().DurationVar(&podEviction, "pod-eviction-timeout", controller.DefaultNodeLifecycleOptions().PodEvictionTimeout, "How long a node may be NotReady before its pods are rescheduled on other nodes")
+	rootCmd.Flags().IntVar(&workers, "workers", controller.DefaultOptions().Workers, "Number of ReplicaSets, and of Deployments, reconciled concurrently")
+	rootCmd.Flags().BoolVar(&leaderElect, "leader-elect", true, "Elect a leader among the running controllers so that only one of them acts")
+	rootCmd.Flags().DurationVar(&leaseDuration, "leader-elect-lease-duration", leaderelection.DefaultOptions().LeaseDuration, "How long the other controllers wait before taking over from a leader that stopped renewing its lease")
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -45,31 +63,51 @@ func runController() error {
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
 
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints: []string{fmt.Sprintf("localhost:%d", etcdPort)},
-	})
+	// Create etcd storage instance
+	etcdConfig := etcdclient.DefaultConfig()
+	etcdConfig.Endpoints = []string{fmt.Sprintf("localhost:%d", etcdPort)}
+	etcdConfig.Security = etcdSecurity
+	store, err := storage.NewEtcdStorageFromConfig(etcdConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
-	defer cli.Close()
-
-	// Create etcd storage instance
-	store := storage.NewEtcdStorage(cli)
+	defer store.Close()
 
 	// Initialize registries with the etcd storage
 	rsRegistry := registry.NewReplicaSetRegistry(store)
 	podRegistry := registry.NewPodRegistry(store)
-
-	rsController := controller.NewReplicaSetController(rsRegistry, podRegistry)
+	deploymentRegistry := registry.NewDeploymentRegistry(store)
+	nodeRegistry := registry.NewNodeRegistry(store)
+
+	opts := controller.DefaultOptions()
+	opts.ResyncPeriod = resyncPeriod
+	opts.Workers = workers
+	opts.Endpoints = etcdConfig.Endpoints
+	opts.ListWatch.Security = etcdSecurity
+	opts.Events = registry.NewEventRegistry(store)
+	rsController := controller.NewReplicaSetControllerWithOptions(rsRegistry, podRegistry, opts)
+	deploymentController := controller.NewDeploymentControllerWithOptions(deploymentRegistry, rsRegistry, podRegistry, opts)
+	nodeLifecycleOpts := controller.DefaultNodeLifecycleOptions()
+	nodeLifecycleOpts.PodEvictionTimeout = podEviction
+	nodeLifecycleController := controller.NewNodeLifecycleControllerWithOptions(nodeRegistry, podRegistry, nodeLifecycleOpts)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go rsController.Start(ctx)
-
-	fmt.Println("Controller started successfully")
+	start := func(ctx context.Context) {
+		go rsController.Start(ctx)
+		go deploymentController.Start(ctx)
+		go nodeLifecycleController.Start(ctx)
+		fmt.Println("Controller started successfully")
+	}
+	stopped, err := leaderelection.Run(ctx, etcdConfig, "controller", leaderElect, leaseDuration, start)
+	if err != nil {
+		return err
+	}
 
 	<-stopCh
 	fmt.Println("\nReceived shutdown signal. Stopping controller...")
+	cancel()
+	<-stopped
 	return nil
 }
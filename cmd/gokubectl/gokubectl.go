@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	resourcePods        = "pods"
+	resourceNodes       = "nodes"
+	resourceReplicaSets = "replicasets"
+)
+
+// resourceAliases maps the names accepted on the command line to the resource they refer to
+var resourceAliases = map[string]string{
+	"pod": resourcePods, "pods": resourcePods, "po": resourcePods,
+	"node": resourceNodes, "nodes": resourceNodes, "no": resourceNodes,
+	"replicaset": resourceReplicaSets, "replicasets": resourceReplicaSets, "rs": resourceReplicaSets,
+}
+
+// kindResources maps the kind field of a resource file to the resource it creates
+var kindResources = map[string]string{
+	"pod":        resourcePods,
+	"node":       resourceNodes,
+	"replicaset": resourceReplicaSets,
+}
+
+func main() {
+	if err := newRootCmd(os.Stdout).Execute(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCmd builds the gokubectl command tree writing its output to out
+func newRootCmd(out io.Writer) *cobra.Command {
+	var server string
+
+	rootCmd := &cobra.Command{
+		Use:           "gokubectl",
+		Short:         "Manage gokube resources through the API server",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	rootCmd.SetOut(out)
+	rootCmd.PersistentFlags().StringVar(&server, "server", "localhost:8080", `The address of the API server (default "localhost:8080")`)
+
+	newClient := func() *client.Client {
+		return client.NewClient(server)
+	}
+	rootCmd.AddCommand(newGetCmd(out, newClient), newCreateCmd(out, newClient), newDeleteCmd(out, newClient))
+
+	return rootCmd
+}
+
+func newGetCmd(out io.Writer, newClient func() *client.Client) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "get (pods|nodes|replicasets) [name]",
+		Short: "Display one or many resources",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "" && output != "json" {
+				return fmt.Errorf("unsupported output format %q", output)
+			}
+			resource, err := parseResource(args[0])
+			if err != nil {
+				return err
+			}
+
+			name := ""
+			if len(args) == 2 {
+				name = args[1]
+			}
+			return runGet(cmd.Context(), out, newClient(), resource, name, output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", `Output format. One of: json (default table)`)
+
+	return cmd
+}
+
+func newCreateCmd(out io.Writer, newClient func() *client.Client) *cobra.Command {
+	var filename string
+
+	cmd := &cobra.Command{
+		Use:   "create -f FILENAME",
+		Short: "Create a resource from a JSON or YAML file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %v", filename, err)
+			}
+			return runCreate(cmd.Context(), out, newClient(), data)
+		},
+	}
+	cmd.Flags().StringVarP(&filename, "filename", "f", "", "The JSON or YAML file describing the resource to create")
+	_ = cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+func newDeleteCmd(out io.Writer, newClient func() *client.Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete (pod|node|replicaset) NAME",
+		Short: "Delete a resource by name",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource, err := parseResource(args[0])
+			if err != nil {
+				return err
+			}
+			return runDelete(cmd.Context(), out, newClient(), resource, args[1])
+		},
+	}
+}
+
+func parseResource(arg string) (string, error) {
+	resource, ok := resourceAliases[strings.ToLower(arg)]
+	if !ok {
+		return "", fmt.Errorf("unknown resource type %q", arg)
+	}
+	return resource, nil
+}
+
+func runGet(ctx context.Context, out io.Writer, c *client.Client, resource, name, output string) error {
+	var (
+		result     interface{}
+		count      int
+		printTable func(now time.Time) error
+	)
+
+	switch resource {
+	case resourcePods:
+		var pods []*api.Pod
+		if name != "" {
+			pod, err := c.GetPod(ctx, name)
+			if err != nil {
+				return err
+			}
+			result, pods = pod, []*api.Pod{pod}
+		} else {
+			list, err := c.ListPods(ctx)
+			if err != nil {
+				return err
+			}
+			result, pods = list, list
+		}
+		count = len(pods)
+		printTable = func(now time.Time) error { return api.PrintTable(out, pods, now) }
+	case resourceNodes:
+		var nodes []*api.Node
+		if name != "" {
+			node, err := c.GetNode(ctx, name)
+			if err != nil {
+				return err
+			}
+			result, nodes = node, []*api.Node{node}
+		} else {
+			list, err := c.ListNodes(ctx)
+			if err != nil {
+				return err
+			}
+			result, nodes = list, list
+		}
+		count = len(nodes)
+		printTable = func(now time.Time) error { return api.PrintTable(out, nodes, now) }
+	case resourceReplicaSets:
+		var replicaSets []*api.ReplicaSet
+		if name != "" {
+			rs, err := c.GetReplicaSet(ctx, name)
+			if err != nil {
+				return err
+			}
+			result, replicaSets = rs, []*api.ReplicaSet{rs}
+		} else {
+			list, err := c.ListReplicaSets(ctx)
+			if err != nil {
+				return err
+			}
+			result, replicaSets = list, list
+		}
+		count = len(replicaSets)
+		printTable = func(now time.Time) error { return api.PrintTable(out, replicaSets, now) }
+	}
+
+	if output == "json" {
+		return printJSON(out, result)
+	}
+	if count == 0 {
+		_, err := fmt.Fprintln(out, "No resources found.")
+		return err
+	}
+	return printTable(time.Now())
+}
+
+func runCreate(ctx context.Context, out io.Writer, c *client.Client, data []byte) error {
+	// JSON is valid YAML, so every file is converted and then decoded as JSON
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode resource: %v", err)
+	}
+
+	var typeMeta struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &typeMeta); err != nil {
+		return fmt.Errorf("failed to decode resource: %v", err)
+	}
+	if typeMeta.Kind == "" {
+		return fmt.Errorf("resource file must set kind")
+	}
+	resource, ok := kindResources[strings.ToLower(typeMeta.Kind)]
+	if !ok {
+		return fmt.Errorf("unknown kind %q", typeMeta.Kind)
+	}
+
+	var name string
+	switch resource {
+	case resourcePods:
+		pod := new(api.Pod)
+		if err := json.Unmarshal(data, pod); err != nil {
+			return fmt.Errorf("failed to decode pod: %v", err)
+		}
+		created, err := c.CreatePod(ctx, pod)
+		if err != nil {
+			return err
+		}
+		name = created.Name
+	case resourceNodes:
+		node := new(api.Node)
+		if err := json.Unmarshal(data, node); err != nil {
+			return fmt.Errorf("failed to decode node: %v", err)
+		}
+		created, err := c.CreateNode(ctx, node)
+		if err != nil {
+			return err
+		}
+		name = created.Name
+	case resourceReplicaSets:
+		rs := new(api.ReplicaSet)
+		if err := json.Unmarshal(data, rs); err != nil {
+			return fmt.Errorf("failed to decode replicaset: %v", err)
+		}
+		created, err := c.CreateReplicaSet(ctx, rs)
+		if err != nil {
+			return err
+		}
+		name = created.Name
+	}
+
+	_, err = fmt.Fprintf(out, "%s/%s created\n", singular(resource), name)
+	return err
+}
+
+func runDelete(ctx context.Context, out io.Writer, c *client.Client, resource, name string) error {
+	var err error
+	switch resource {
+	case resourcePods:
+		err = c.DeletePod(ctx, name)
+	case resourceNodes:
+		err = c.DeleteNode(ctx, name)
+	case resourceReplicaSets:
+		err = c.DeleteReplicaSet(ctx, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "%s/%s deleted\n", singular(resource), name)
+	return err
+}
+
+func singular(resource string) string {
+	return strings.TrimSuffix(resource, "s")
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
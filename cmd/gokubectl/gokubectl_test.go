@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestGokubectl(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		apiServer := httptest.NewServer(server.NewAPIServer(storage.NewEtcdStorage(etcdServer)).Handler())
+		defer apiServer.Close()
+
+		run := func(t *testing.T, args ...string) (string, error) {
+			var out bytes.Buffer
+			cmd := newRootCmd(&out)
+			cmd.SetArgs(append([]string{"--server", apiServer.URL}, args...))
+			err := cmd.Execute()
+			return out.String(), err
+		}
+
+		writeFile := func(t *testing.T, content string) string {
+			path := filepath.Join(t.TempDir(), "resource.json")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			return path
+		}
+
+		t.Run("should report no resources when none exist", func(t *testing.T) {
+			out, err := run(t, "get", "pods")
+			require.NoError(t, err)
+			assert.Equal(t, "No resources found.\n", out)
+		})
+
+		t.Run("should create resources from a file", func(t *testing.T) {
+			out, err := run(t, "create", "-f", writeFile(t, `{
+				"kind": "Pod",
+				"metadata": {"name": "nginx"},
+				"spec": {"containers": [{"name": "nginx", "image": "nginx:latest"}]}
+			}`))
+			require.NoError(t, err)
+			assert.Equal(t, "pod/nginx created\n", out)
+
+			out, err = run(t, "create", "-f", writeFile(t, `{
+				"kind": "Node",
+				"metadata": {"name": "node-1"},
+				"status": "Ready"
+			}`))
+			require.NoError(t, err)
+			assert.Equal(t, "node/node-1 created\n", out)
+
+			out, err = run(t, "create", "-f", writeFile(t, `{
+				"kind": "ReplicaSet",
+				"metadata": {"name": "web"},
+				"spec": {
+					"replicas": 3,
+					"selector": {"app": "web"},
+					"template": {
+						"metadata": {"labels": {"app": "web"}},
+						"spec": {"containers": [{"name": "web", "image": "nginx:latest"}]}
+					}
+				}
+			}`))
+			require.NoError(t, err)
+			assert.Equal(t, "replicaset/web created\n", out)
+		})
+
+		t.Run("should render resources as a table", func(t *testing.T) {
+			out, err := run(t, "get", "pods")
+			require.NoError(t, err)
+			assert.Regexp(t, `^NAME    STATUS    NODE     AGE\nnginx   Pending   <none>   \d+s\n$`, out)
+
+			out, err = run(t, "get", "nodes")
+			require.NoError(t, err)
+			assert.Regexp(t, `^NAME     STATUS   AGE\nnode-1   Ready    \d+s\n$`, out)
+
+			out, err = run(t, "get", "rs", "web")
+			require.NoError(t, err)
+			assert.Regexp(t, `^NAME   DESIRED   CURRENT   AGE\nweb    3         0         \d+s\n$`, out)
+		})
+
+		t.Run("should render resources as json", func(t *testing.T) {
+			out, err := run(t, "get", "pod", "nginx", "-o", "json")
+			require.NoError(t, err)
+
+			pod := new(api.Pod)
+			require.NoError(t, json.Unmarshal([]byte(out), pod))
+			assert.Equal(t, "nginx", pod.Name)
+			assert.Equal(t, "docker.io/library/nginx:latest", pod.Spec.Containers[0].Image)
+
+			out, err = run(t, "get", "replicasets", "-o", "json")
+			require.NoError(t, err)
+
+			var replicaSets []*api.ReplicaSet
+			require.NoError(t, json.Unmarshal([]byte(out), &replicaSets))
+			require.Len(t, replicaSets, 1)
+			assert.Equal(t, int32(3), replicaSets[0].Spec.Replicas)
+		})
+
+		t.Run("should delete resources", func(t *testing.T) {
+			out, err := run(t, "delete", "pod", "nginx")
+			require.NoError(t, err)
+			assert.Equal(t, "pod/nginx deleted\n", out)
+
+			_, err = run(t, "get", "pod", "nginx")
+			assert.ErrorContains(t, err, "not found")
+		})
+
+		t.Run("should create resources from a YAML file", func(t *testing.T) {
+			out, err := run(t, "create", "-f", writeFile(t, `
+kind: Pod
+metadata:
+  name: redis
+spec:
+  containers:
+    - name: redis
+      image: redis:latest
+`))
+			require.NoError(t, err)
+			assert.Equal(t, "pod/redis created\n", out)
+
+			out, err = run(t, "get", "pod", "redis", "-o", "json")
+			require.NoError(t, err)
+			pod := new(api.Pod)
+			require.NoError(t, json.Unmarshal([]byte(out), pod))
+			assert.Equal(t, "docker.io/library/redis:latest", pod.Spec.Containers[0].Image)
+		})
+
+		t.Run("should return errors for invalid input", func(t *testing.T) {
+			_, err := run(t, "get", "services")
+			assert.ErrorContains(t, err, `unknown resource type "services"`)
+
+			_, err = run(t, "get", "pods", "-o", "yaml")
+			assert.ErrorContains(t, err, `unsupported output format "yaml"`)
+
+			_, err = run(t, "create", "-f", writeFile(t, `{"metadata": {"name": "nginx"}}`))
+			assert.ErrorContains(t, err, "resource file must set kind")
+
+			_, err = run(t, "create", "-f", writeFile(t, `{"kind": "Pod", "metadata": {"name": "no-containers"}}`))
+			assert.ErrorContains(t, err, "invalid")
+
+			_, err = run(t, "delete", "node", "missing")
+			assert.ErrorContains(t, err, "not found")
+		})
+	})
+}
@@ -1,16 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gokube/pkg/kubelet"
 )
 
 var (
-	nodeName     string
-	apiServerURL string
+	nodeName        string
+	apiServerURL    string
+	stopGracePeriod time.Duration
+	statusInterval  time.Duration
+	ownerLabels     bool
+	podStatusPeriod time.Duration
+	stopContainers  bool
+	shutdownTimeout time.Duration
+	logDriver       string
+	logMaxSize      string
+	logMaxFile      int
+	pullSecretsFile string
 )
 
 func main() {
@@ -27,6 +41,16 @@ func main() {
 
 	rootCmd.Flags().StringVar(&nodeName, "node-name", "", "The name of the node")
 	rootCmd.Flags().StringVar(&apiServerURL, "api-server-url", "localhost:8080", "The URL of the API server")
+	rootCmd.Flags().DurationVar(&statusInterval, "node-status-update-interval", kubelet.DefaultOptions().NodeStatusUpdateInterval, "How often the node status and allocatable resources are reported")
+	rootCmd.Flags().DurationVar(&stopGracePeriod, "stop-grace-period", kubelet.DefaultOptions().StopGracePeriod, "How long a container is given to stop before it is killed")
+	rootCmd.Flags().DurationVar(&podStatusPeriod, "pod-status-update-interval", kubelet.DefaultOptions().PodStatusUpdateInterval, "How often the container states are inspected and changed pod statuses reported")
+	rootCmd.Flags().BoolVar(&stopContainers, "stop-containers-on-shutdown", kubelet.DefaultOptions().StopContainersOnShutdown, "Stop the containers of the pods when the kubelet shuts down")
+	rootCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long in-flight pod operations are waited for on shutdown")
+	rootCmd.Flags().StringVar(&logDriver, "log-driver", kubelet.DefaultOptions().LogConfig.Driver, "The log driver of the containers, json-file, local or none")
+	rootCmd.Flags().StringVar(&logMaxSize, "log-max-size", kubelet.DefaultOptions().LogConfig.MaxSize, "The size a container log file is rotated at, such as 10m")
+	rootCmd.Flags().IntVar(&logMaxFile, "log-max-file", kubelet.DefaultOptions().LogConfig.MaxFile, "How many rotated log files are kept per container")
+	rootCmd.Flags().StringVar(&pullSecretsFile, "image-pull-secrets-file", "", "A JSON file mapping the image pull secret names pods refer to to their registry, username and password")
+	rootCmd.Flags().BoolVar(&ownerLabels, "owner-labels", kubelet.DefaultOptions().OwnerLabels, "Label containers with the kind, name and UID of the workload owning their pod")
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -35,7 +59,25 @@ func main() {
 }
 
 func runKubelet() error {
-	k, err := kubelet.NewKubelet(nodeName, apiServerURL)
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+
+	opts := kubelet.DefaultOptions()
+	opts.StopGracePeriod = stopGracePeriod
+	opts.NodeStatusUpdateInterval = statusInterval
+	opts.OwnerLabels = ownerLabels
+	opts.PodStatusUpdateInterval = podStatusPeriod
+	opts.StopContainersOnShutdown = stopContainers
+	opts.LogConfig = kubelet.LogConfig{Driver: logDriver, MaxSize: logMaxSize, MaxFile: logMaxFile}
+	if pullSecretsFile != "" {
+		secrets, err := kubelet.LoadPullSecrets(pullSecretsFile)
+		if err != nil {
+			return err
+		}
+		opts.PullSecrets = secrets
+	}
+
+	k, err := kubelet.NewKubeletWithOptions(nodeName, apiServerURL, opts)
 	if err != nil {
 		return fmt.Errorf("failed to create kubelet: %v", err)
 	}
@@ -44,6 +86,13 @@ func runKubelet() error {
 		return fmt.Errorf("failed to start kubelet: %v", err)
 	}
 
-	// Block forever
-	select {}
+	<-stopCh
+	fmt.Println("Shutting down kubelet...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := k.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop kubelet: %v", err)
+	}
+	return nil
 }
@@ -8,17 +8,25 @@ import (
 	"syscall"
 	"time"
 
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/leaderelection"
 	"gokube/pkg/registry"
 	"gokube/pkg/scheduler"
 	"gokube/pkg/storage"
 
 	"github.com/spf13/cobra"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 var (
-	etcdPort       int
-	schedulingRate time.Duration
+	etcdPort          int
+	schedulingRate    time.Duration
+	schedulingTimeout time.Duration
+	failOnTimeout     bool
+	batchSize         int
+	podListCacheTTL   time.Duration
+	etcdSecurity      etcdclient.Security
+	leaderElect       bool
+	leaseDuration     time.Duration
 )
 
 func main() {
@@ -34,7 +42,18 @@ func main() {
 	}
 
 	rootCmd.Flags().IntVar(&etcdPort, "etcd-port", 2379, "Port of the etcd server")
+	rootCmd.Flags().StringVar(&etcdSecurity.CAFile, "etcd-cafile", "", "CA bundle to verify the etcd server certificate with; setting a TLS file connects over TLS")
+	rootCmd.Flags().StringVar(&etcdSecurity.CertFile, "etcd-certfile", "", "Client certificate to authenticate to etcd with")
+	rootCmd.Flags().StringVar(&etcdSecurity.KeyFile, "etcd-keyfile", "", "Key of the etcd client certificate")
+	rootCmd.Flags().StringVar(&etcdSecurity.Username, "etcd-username", "", "Username to authenticate to etcd with")
+	rootCmd.Flags().StringVar(&etcdSecurity.Password, "etcd-password", "", "Password to authenticate to etcd with")
 	rootCmd.Flags().DurationVar(&schedulingRate, "scheduling-rate", 10*time.Second, "How often to run the scheduling loop")
+	rootCmd.Flags().DurationVar(&schedulingTimeout, "scheduling-timeout", scheduler.DefaultOptions().SchedulingTimeout, "How long a pod may stay unscheduled before it is marked as failing to schedule (0 disables)")
+	rootCmd.Flags().BoolVar(&failOnTimeout, "fail-unschedulable", scheduler.DefaultOptions().FailOnTimeout, "Mark pods that can never be scheduled as Failed after the scheduling timeout")
+	rootCmd.Flags().IntVar(&batchSize, "batch-size", scheduler.DefaultOptions().BatchSize, "The most pending pods a scheduling pass considers (0 considers all)")
+	rootCmd.Flags().DurationVar(&podListCacheTTL, "pod-list-cache-ttl", 0, "How long a list of all pods is served from memory (0 disables the cache)")
+	rootCmd.Flags().BoolVar(&leaderElect, "leader-elect", true, "Elect a leader among the running schedulers so that only one of them acts")
+	rootCmd.Flags().DurationVar(&leaseDuration, "leader-elect-lease-duration", leaderelection.DefaultOptions().LeaseDuration, "How long the other schedulers wait before taking over from a leader that stopped renewing its lease")
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -46,35 +65,45 @@ func runScheduler() error {
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
 
-	// Create etcd client
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints: []string{fmt.Sprintf("localhost:%d", etcdPort)},
-	})
+	// Create etcd storage instance
+	etcdConfig := etcdclient.DefaultConfig()
+	etcdConfig.Endpoints = []string{fmt.Sprintf("localhost:%d", etcdPort)}
+	etcdConfig.Security = etcdSecurity
+	store, err := storage.NewEtcdStorageFromConfig(etcdConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
-	defer cli.Close()
-
-	// Create etcd storage instance
-	store := storage.NewEtcdStorage(cli)
+	defer store.Close()
 
 	// Initialize registries with the etcd storage
-	podRegistry := registry.NewPodRegistry(store)
+	podRegistry := registry.NewPodRegistryWithOptions(store, registry.PodRegistryOptions{ListCacheTTL: podListCacheTTL})
 	nodeRegistry := registry.NewNodeRegistry(store)
 
 	// Create and start the scheduler
-	sched := scheduler.NewScheduler(podRegistry, nodeRegistry, schedulingRate)
+	opts := scheduler.DefaultOptions()
+	opts.SchedulingTimeout = schedulingTimeout
+	opts.FailOnTimeout = failOnTimeout
+	opts.BatchSize = batchSize
+	sched := scheduler.NewSchedulerWithOptions(podRegistry, nodeRegistry, schedulingRate, opts)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go sched.Start(ctx)
+	start := func(ctx context.Context) {
+		go sched.Start(ctx)
 
-	fmt.Printf("Scheduler started successfully\n")
-	fmt.Printf("Connected to etcd at localhost:%d\n", etcdPort)
-	fmt.Printf("Scheduling rate: %v\n", schedulingRate)
+		fmt.Printf("Scheduler started successfully\n")
+		fmt.Printf("Connected to etcd at localhost:%d\n", etcdPort)
+		fmt.Printf("Scheduling rate: %v\n", schedulingRate)
+	}
+	stopped, err := leaderelection.Run(ctx, etcdConfig, "scheduler", leaderElect, leaseDuration, start)
+	if err != nil {
+		return err
+	}
 
 	<-stopCh
 	fmt.Println("\nReceived shutdown signal. Stopping scheduler...")
+	cancel()
+	<-stopped
 	return nil
 }
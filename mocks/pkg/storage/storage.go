@@ -12,7 +12,9 @@ package storage
 import (
 	context "context"
 	runtime "gokube/pkg/runtime"
+	storage "gokube/pkg/storage"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -55,6 +57,50 @@ func (mr *MockStorageMockRecorder) Create(ctx, key, obj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockStorage)(nil).Create), ctx, key, obj)
 }
 
+// CreateOrUpdate mocks base method.
+func (m *MockStorage) CreateOrUpdate(ctx context.Context, key string, obj runtime.Object, preserve func(runtime.Object)) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrUpdate", ctx, key, obj, preserve)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrUpdate indicates an expected call of CreateOrUpdate.
+func (mr *MockStorageMockRecorder) CreateOrUpdate(ctx, key, obj, preserve any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrUpdate", reflect.TypeOf((*MockStorage)(nil).CreateOrUpdate), ctx, key, obj, preserve)
+}
+
+// CreateWithTTL mocks base method.
+func (m *MockStorage) CreateWithTTL(ctx context.Context, key string, obj runtime.Object, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWithTTL", ctx, key, obj, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWithTTL indicates an expected call of CreateWithTTL.
+func (mr *MockStorageMockRecorder) CreateWithTTL(ctx, key, obj, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithTTL", reflect.TypeOf((*MockStorage)(nil).CreateWithTTL), ctx, key, obj, ttl)
+}
+
+// CurrentRevision mocks base method.
+func (m *MockStorage) CurrentRevision(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentRevision", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CurrentRevision indicates an expected call of CurrentRevision.
+func (mr *MockStorageMockRecorder) CurrentRevision(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentRevision", reflect.TypeOf((*MockStorage)(nil).CurrentRevision), ctx)
+}
+
 // Delete mocks base method.
 func (m *MockStorage) Delete(ctx context.Context, key string) error {
 	m.ctrl.T.Helper()
@@ -69,6 +115,20 @@ func (mr *MockStorageMockRecorder) Delete(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), ctx, key)
 }
 
+// DeleteAtRevision mocks base method.
+func (m *MockStorage) DeleteAtRevision(ctx context.Context, key string, revision int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAtRevision", ctx, key, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAtRevision indicates an expected call of DeleteAtRevision.
+func (mr *MockStorageMockRecorder) DeleteAtRevision(ctx, key, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAtRevision", reflect.TypeOf((*MockStorage)(nil).DeleteAtRevision), ctx, key, revision)
+}
+
 // DeletePrefix mocks base method.
 func (m *MockStorage) DeletePrefix(ctx context.Context, prefix string) error {
 	m.ctrl.T.Helper()
@@ -97,6 +157,20 @@ func (mr *MockStorageMockRecorder) Get(ctx, key, obj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStorage)(nil).Get), ctx, key, obj)
 }
 
+// GuaranteedUpdate mocks base method.
+func (m *MockStorage) GuaranteedUpdate(ctx context.Context, key string, obj runtime.Object, tryUpdate func(runtime.Object) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GuaranteedUpdate", ctx, key, obj, tryUpdate)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GuaranteedUpdate indicates an expected call of GuaranteedUpdate.
+func (mr *MockStorageMockRecorder) GuaranteedUpdate(ctx, key, obj, tryUpdate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GuaranteedUpdate", reflect.TypeOf((*MockStorage)(nil).GuaranteedUpdate), ctx, key, obj, tryUpdate)
+}
+
 // List mocks base method.
 func (m *MockStorage) List(ctx context.Context, prefix string, listObj any) error {
 	m.ctrl.T.Helper()
@@ -111,6 +185,52 @@ func (mr *MockStorageMockRecorder) List(ctx, prefix, listObj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockStorage)(nil).List), ctx, prefix, listObj)
 }
 
+// ListPaged mocks base method.
+func (m *MockStorage) ListPaged(ctx context.Context, prefix string, limit int64, continueToken string, listObj any) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPaged", ctx, prefix, limit, continueToken, listObj)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPaged indicates an expected call of ListPaged.
+func (mr *MockStorageMockRecorder) ListPaged(ctx, prefix, limit, continueToken, listObj any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPaged", reflect.TypeOf((*MockStorage)(nil).ListPaged), ctx, prefix, limit, continueToken, listObj)
+}
+
+// ListStream mocks base method.
+func (m *MockStorage) ListStream(ctx context.Context, prefix string, newFunc func() runtime.Object) (<-chan runtime.Object, <-chan error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStream", ctx, prefix, newFunc)
+	ret0, _ := ret[0].(<-chan runtime.Object)
+	ret1, _ := ret[1].(<-chan error)
+	return ret0, ret1
+}
+
+// ListStream indicates an expected call of ListStream.
+func (mr *MockStorageMockRecorder) ListStream(ctx, prefix, newFunc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStream", reflect.TypeOf((*MockStorage)(nil).ListStream), ctx, prefix, newFunc)
+}
+
+// ListWithMeta mocks base method.
+func (m *MockStorage) ListWithMeta(ctx context.Context, prefix string, listObj any) ([]storage.ItemMeta, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWithMeta", ctx, prefix, listObj)
+	ret0, _ := ret[0].([]storage.ItemMeta)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListWithMeta indicates an expected call of ListWithMeta.
+func (mr *MockStorageMockRecorder) ListWithMeta(ctx, prefix, listObj any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWithMeta", reflect.TypeOf((*MockStorage)(nil).ListWithMeta), ctx, prefix, listObj)
+}
+
 // Update mocks base method.
 func (m *MockStorage) Update(ctx context.Context, key string, obj runtime.Object) error {
 	m.ctrl.T.Helper()
@@ -124,3 +244,97 @@ func (mr *MockStorageMockRecorder) Update(ctx, key, obj any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), ctx, key, obj)
 }
+
+// UpdateAtRevision mocks base method.
+func (m *MockStorage) UpdateAtRevision(ctx context.Context, key string, obj runtime.Object, revision int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAtRevision", ctx, key, obj, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAtRevision indicates an expected call of UpdateAtRevision.
+func (mr *MockStorageMockRecorder) UpdateAtRevision(ctx, key, obj, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAtRevision", reflect.TypeOf((*MockStorage)(nil).UpdateAtRevision), ctx, key, obj, revision)
+}
+
+// Watch mocks base method.
+func (m *MockStorage) Watch(ctx context.Context, prefix string) (<-chan storage.WatchEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx, prefix)
+	ret0, _ := ret[0].(<-chan storage.WatchEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockStorageMockRecorder) Watch(ctx, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockStorage)(nil).Watch), ctx, prefix)
+}
+
+// WatchFiltered mocks base method.
+func (m *MockStorage) WatchFiltered(ctx context.Context, prefix string, types ...storage.EventType) (<-chan storage.WatchEvent, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, prefix}
+	for _, a := range types {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WatchFiltered", varargs...)
+	ret0, _ := ret[0].(<-chan storage.WatchEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchFiltered indicates an expected call of WatchFiltered.
+func (mr *MockStorageMockRecorder) WatchFiltered(ctx, prefix any, types ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, prefix}, types...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchFiltered", reflect.TypeOf((*MockStorage)(nil).WatchFiltered), varargs...)
+}
+
+// WatchFromRevision mocks base method.
+func (m *MockStorage) WatchFromRevision(ctx context.Context, prefix string, revision int64, opts storage.WatchOptions) (<-chan storage.WatchEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchFromRevision", ctx, prefix, revision, opts)
+	ret0, _ := ret[0].(<-chan storage.WatchEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchFromRevision indicates an expected call of WatchFromRevision.
+func (mr *MockStorageMockRecorder) WatchFromRevision(ctx, prefix, revision, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchFromRevision", reflect.TypeOf((*MockStorage)(nil).WatchFromRevision), ctx, prefix, revision, opts)
+}
+
+// WatchKey mocks base method.
+func (m *MockStorage) WatchKey(ctx context.Context, key string, opts storage.WatchOptions) (<-chan storage.WatchEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchKey", ctx, key, opts)
+	ret0, _ := ret[0].(<-chan storage.WatchEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchKey indicates an expected call of WatchKey.
+func (mr *MockStorageMockRecorder) WatchKey(ctx, key, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchKey", reflect.TypeOf((*MockStorage)(nil).WatchKey), ctx, key, opts)
+}
+
+// WatchWithOptions mocks base method.
+func (m *MockStorage) WatchWithOptions(ctx context.Context, prefix string, opts storage.WatchOptions) (<-chan storage.WatchEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchWithOptions", ctx, prefix, opts)
+	ret0, _ := ret[0].(<-chan storage.WatchEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchWithOptions indicates an expected call of WatchWithOptions.
+func (mr *MockStorageMockRecorder) WatchWithOptions(ctx, prefix, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchWithOptions", reflect.TypeOf((*MockStorage)(nil).WatchWithOptions), ctx, prefix, opts)
+}
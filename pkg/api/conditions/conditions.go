@@ -0,0 +1,69 @@
+// Package conditions provides helpers to read and update the condition lists carried by
+// resources such as Pods and Nodes.
+package conditions
+
+import (
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// now returns the current time, it is a variable so tests can control the clock
+var now = func() time.Time {
+	return time.Now().UTC()
+}
+
+// GetCondition returns the condition of the given type, or nil if it is not present.
+func GetCondition(conditions []api.Condition, conditionType api.ConditionType) *api.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds the condition or replaces the existing condition of the same type.
+// LastTransitionTime is only updated when the status changes; if the condition has an
+// explicit LastTransitionTime on a status change it is kept.
+// It returns true if the condition list was modified.
+func SetCondition(conditions *[]api.Condition, condition api.Condition) bool {
+	existing := GetCondition(*conditions, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = now()
+		}
+		*conditions = append(*conditions, condition)
+		return true
+	}
+
+	if existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = now()
+	}
+
+	if *existing == condition {
+		return false
+	}
+	*existing = condition
+	return true
+}
+
+// RemoveCondition removes the condition of the given type.
+// It returns true if the condition was present.
+func RemoveCondition(conditions *[]api.Condition, conditionType api.ConditionType) bool {
+	for i := range *conditions {
+		if (*conditions)[i].Type == conditionType {
+			*conditions = append((*conditions)[:i], (*conditions)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsConditionTrue returns true if the condition of the given type is present and has status True.
+func IsConditionTrue(conditions []api.Condition, conditionType api.ConditionType) bool {
+	condition := GetCondition(conditions, conditionType)
+	return condition != nil && condition.Status == api.ConditionTrue
+}
@@ -0,0 +1,108 @@
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+)
+
+// setClock makes now return the given time for the duration of the test
+func setClock(t *testing.T, clock *time.Time) {
+	original := now
+	now = func() time.Time { return *clock }
+	t.Cleanup(func() { now = original })
+}
+
+func TestSetCondition(t *testing.T) {
+	t.Run("should add a new condition with the current transition time", func(t *testing.T) {
+		clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		setClock(t, &clock)
+
+		var conditions []api.Condition
+		changed := SetCondition(&conditions, api.Condition{Type: api.NodeConditionReady, Status: api.ConditionTrue, Reason: "KubeletReady"})
+
+		assert.True(t, changed)
+		require.Len(t, conditions, 1)
+		assert.Equal(t, api.ConditionTrue, conditions[0].Status)
+		assert.Equal(t, "KubeletReady", conditions[0].Reason)
+		assert.Equal(t, clock, conditions[0].LastTransitionTime)
+	})
+
+	t.Run("should keep the transition time when the status does not change", func(t *testing.T) {
+		clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		setClock(t, &clock)
+
+		var conditions []api.Condition
+		SetCondition(&conditions, api.Condition{Type: api.NodeConditionReady, Status: api.ConditionFalse, Reason: "Starting"})
+
+		clock = clock.Add(time.Minute)
+		changed := SetCondition(&conditions, api.Condition{Type: api.NodeConditionReady, Status: api.ConditionFalse, Reason: "StillStarting"})
+
+		assert.True(t, changed)
+		require.Len(t, conditions, 1)
+		assert.Equal(t, "StillStarting", conditions[0].Reason)
+		assert.Equal(t, clock.Add(-time.Minute), conditions[0].LastTransitionTime)
+	})
+
+	t.Run("should update the transition time when the status changes", func(t *testing.T) {
+		clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		setClock(t, &clock)
+
+		var conditions []api.Condition
+		SetCondition(&conditions, api.Condition{Type: api.NodeConditionReady, Status: api.ConditionFalse})
+
+		clock = clock.Add(time.Minute)
+		changed := SetCondition(&conditions, api.Condition{Type: api.NodeConditionReady, Status: api.ConditionTrue})
+
+		assert.True(t, changed)
+		require.Len(t, conditions, 1)
+		assert.Equal(t, api.ConditionTrue, conditions[0].Status)
+		assert.Equal(t, clock, conditions[0].LastTransitionTime)
+	})
+
+	t.Run("should report no change when the condition is identical", func(t *testing.T) {
+		var conditions []api.Condition
+		SetCondition(&conditions, api.Condition{Type: api.PodConditionScheduled, Status: api.ConditionTrue})
+
+		changed := SetCondition(&conditions, api.Condition{Type: api.PodConditionScheduled, Status: api.ConditionTrue})
+
+		assert.False(t, changed)
+		assert.Len(t, conditions, 1)
+	})
+
+	t.Run("should keep conditions of other types", func(t *testing.T) {
+		var conditions []api.Condition
+		SetCondition(&conditions, api.Condition{Type: api.PodConditionScheduled, Status: api.ConditionTrue})
+		SetCondition(&conditions, api.Condition{Type: api.NodeConditionReady, Status: api.ConditionFalse})
+
+		assert.Len(t, conditions, 2)
+		assert.True(t, IsConditionTrue(conditions, api.PodConditionScheduled))
+		assert.False(t, IsConditionTrue(conditions, api.NodeConditionReady))
+	})
+}
+
+func TestGetCondition(t *testing.T) {
+	conditions := []api.Condition{{Type: api.PodConditionScheduled, Status: api.ConditionTrue}}
+
+	assert.NotNil(t, GetCondition(conditions, api.PodConditionScheduled))
+	assert.Nil(t, GetCondition(conditions, api.NodeConditionReady))
+	assert.Nil(t, GetCondition(nil, api.NodeConditionReady))
+}
+
+func TestRemoveCondition(t *testing.T) {
+	conditions := []api.Condition{
+		{Type: api.PodConditionScheduled, Status: api.ConditionTrue},
+		{Type: api.NodeConditionReady, Status: api.ConditionTrue},
+	}
+
+	assert.True(t, RemoveCondition(&conditions, api.PodConditionScheduled))
+	require.Len(t, conditions, 1)
+	assert.Equal(t, api.NodeConditionReady, conditions[0].Type)
+
+	assert.False(t, RemoveCondition(&conditions, api.PodConditionScheduled))
+	assert.Len(t, conditions, 1)
+}
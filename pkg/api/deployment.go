@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidDeploymentSpec is returned for a Deployment that fails validation
+var ErrInvalidDeploymentSpec = errors.New("invalid deployment spec")
+
+// KindDeployment is the kind used in owner references to Deployments
+const KindDeployment = "Deployment"
+
+// PodTemplateHashLabel labels the ReplicaSets of a Deployment, and their pods, with the hash of
+// the pod template they were created from. It is added to their selector as well, so that the
+// ReplicaSets of two templates never select each other's pods.
+const PodTemplateHashLabel = "pod-template-hash"
+
+// Defaults of the rolling update parameters of a Deployment strategy that sets neither
+const (
+	DefaultMaxSurge       int32 = 1
+	DefaultMaxUnavailable int32 = 0
+)
+
+// Deployment manages ReplicaSets to run replicas of a pod template, rolling the pods over
+// gradually to a new ReplicaSet when the template changes
+type Deployment struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       DeploymentSpec   `json:"spec"`
+	Status     DeploymentStatus `json:"status,omitempty"`
+}
+
+// DeploymentSpec is the specification of a Deployment
+type DeploymentSpec struct {
+	Replicas int32              `json:"replicas"`
+	Selector map[string]string  `json:"selector"`
+	Template PodTemplateSpec    `json:"template"`
+	Strategy DeploymentStrategy `json:"strategy,omitempty"`
+}
+
+// DeploymentStrategy describes how the pods of a Deployment are replaced when its template
+// changes. If neither parameter is set, DefaultMaxSurge and DefaultMaxUnavailable are used.
+type DeploymentStrategy struct {
+	// MaxSurge is how many pods may run above the desired replicas during a rollout
+	MaxSurge int32 `json:"maxSurge,omitempty"`
+	// MaxUnavailable is how many of the desired replicas may be unavailable during a rollout
+	MaxUnavailable int32 `json:"maxUnavailable,omitempty"`
+}
+
+// RollingUpdateLimits returns the MaxSurge and MaxUnavailable of the strategy, with defaults
+func (s DeploymentStrategy) RollingUpdateLimits() (maxSurge, maxUnavailable int32) {
+	if s.MaxSurge == 0 && s.MaxUnavailable == 0 {
+		return DefaultMaxSurge, DefaultMaxUnavailable
+	}
+	return s.MaxSurge, s.MaxUnavailable
+}
+
+// DeploymentStatus represents the current status of a Deployment
+type DeploymentStatus struct {
+	// Replicas is the number of pods of all the ReplicaSets of the Deployment
+	Replicas int32 `json:"replicas"`
+	// UpdatedReplicas is the number of pods created from the current template
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+	// AvailableReplicas is the number of running pods of all the ReplicaSets of the Deployment
+	AvailableReplicas int32 `json:"availableReplicas"`
+}
+
+// Validate checks if the Deployment configuration is valid.
+// The pod template is validated as the ReplicaSet the controller would create from it.
+func (d *Deployment) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidDeploymentSpec)
+	}
+	if d.Spec.Strategy.MaxSurge < 0 || d.Spec.Strategy.MaxUnavailable < 0 {
+		return fmt.Errorf("%w: maxSurge and maxUnavailable must not be negative", ErrInvalidDeploymentSpec)
+	}
+	if !MatchesSelector(d.Spec.Selector, d.Spec.Template.Labels) {
+		return fmt.Errorf("%w: selector does not match the template labels", ErrInvalidDeploymentSpec)
+	}
+
+	rs := &ReplicaSet{
+		ObjectMeta: ObjectMeta{Name: d.Name},
+		Spec: ReplicaSetSpec{
+			Replicas: d.Spec.Replicas,
+			Selector: d.Spec.Selector,
+			Template: d.Spec.Template,
+		},
+	}
+	if err := rs.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDeploymentSpec, err)
+	}
+
+	return nil
+}
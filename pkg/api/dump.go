@@ -0,0 +1,11 @@
+package api
+
+// Dump is a snapshot of all resources in the cluster
+type Dump struct {
+	Pods        []*Pod        `json:"pods"`
+	Nodes       []*Node       `json:"nodes"`
+	ReplicaSets []*ReplicaSet `json:"replicaSets"`
+	// Continue is set when the dump was limited and more resources remain. Passing it back as
+	// the continue query parameter returns the next page.
+	Continue string `json:"continue,omitempty"`
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSelector is returned for a label selector that can't be parsed
+var ErrInvalidSelector = errors.New("invalid label selector")
+
+// Endpoints are the ready pods matching a label selector, the pods that receive its traffic
+type Endpoints struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Selector  map[string]string `json:"selector,omitempty"`
+	// Pods are the names of the ready pods, sorted
+	Pods []string `json:"pods"`
+}
+
+// ParseSelector parses a label selector of comma separated key=value pairs, such as
+// "app=web,tier=frontend". The empty selector matches every pod.
+func ParseSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if selector == "" {
+		return labels, nil
+	}
+
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, found := strings.Cut(requirement, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(strings.TrimPrefix(value, "="))
+		if !found || key == "" {
+			return nil, fmt.Errorf("%w: %q, expected key=value pairs", ErrInvalidSelector, selector)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
@@ -0,0 +1,38 @@
+package api
+
+import "errors"
+
+// ErrInvalidEvent is returned for events that don't refer to an object
+var ErrInvalidEvent = errors.New("invalid event")
+
+const (
+	// EventTypeNormal is the type of events that report expected behaviour
+	EventTypeNormal = "Normal"
+	// EventTypeWarning is the type of events that report something going wrong
+	EventTypeWarning = "Warning"
+)
+
+// Event reports something that happened to an object, such as a pod failing to be scheduled.
+// Events are short-lived, they expire some time after they are recorded.
+type Event struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	// InvolvedObject is the object the event is about
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	Type           string          `json:"type,omitempty"`
+	Reason         string          `json:"reason,omitempty"`
+	Message        string          `json:"message,omitempty"`
+}
+
+// ObjectReference identifies an object of any kind
+type ObjectReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// Validate checks if the event refers to an object
+func (e *Event) Validate() error {
+	if e.InvolvedObject.Kind == "" || e.InvolvedObject.Name == "" {
+		return ErrInvalidEvent
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package api
+
+// GroupVersion identifies the API group and version that resources are served under
+type GroupVersion struct {
+	// Group is the API group. The empty group is the core group.
+	Group   string
+	Version string
+}
+
+// CoreGroupVersion is the group version of the core API, served under /api/v1
+var CoreGroupVersion = GroupVersion{Version: "v1"}
+
+// Path returns the path of the group version: /api/<version> for the core group
+// and /apis/<group>/<version> for any other group
+func (gv GroupVersion) Path() string {
+	if gv.Group == "" {
+		return "/api/" + gv.Version
+	}
+	return "/apis/" + gv.Group + "/" + gv.Version
+}
+
+// Resources served by the API server
+const (
+	ResourcePods        = "pods"
+	ResourceNodes       = "nodes"
+	ResourceReplicaSets = "replicasets"
+	ResourceDeployments = "deployments"
+	ResourceEndpoints   = "endpoints"
+)
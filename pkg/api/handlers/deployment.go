@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+// DeploymentHandler handles Deployment-related HTTP requests
+type DeploymentHandler struct {
+	deploymentRegistry *registry.DeploymentRegistry
+}
+
+// NewDeploymentHandler creates a new DeploymentHandler
+func NewDeploymentHandler(deploymentRegistry *registry.DeploymentRegistry) *DeploymentHandler {
+	return &DeploymentHandler{deploymentRegistry: deploymentRegistry}
+}
+
+const deploymentAttributeKey = "deployment"
+
+// LoadDeploymentIntoRequest retrieves the deployment and stores it in the request attributes
+func (h *DeploymentHandler) LoadDeploymentIntoRequest(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	name := req.PathParameter("name")
+	deployment, err := h.deploymentRegistry.Get(req.Request.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNotFound):
+			api.WriteError(resp, http.StatusNotFound, err)
+		default:
+			api.WriteError(resp, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	req.SetAttribute(deploymentAttributeKey, deployment)
+	chain.ProcessFilter(req, resp)
+}
+
+// CreateDeployment handles POST requests to create a new deployment
+func (h *DeploymentHandler) CreateDeployment(request *restful.Request, response *restful.Response) {
+	deployment := new(api.Deployment)
+	if err := request.ReadEntity(deployment); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.deploymentRegistry.Create(request.Request.Context(), deployment); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrDeploymentInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrDeploymentExists):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, deployment)
+}
+
+// GetDeployment handles GET requests to retrieve a deployment
+func (h *DeploymentHandler) GetDeployment(request *restful.Request, response *restful.Response) {
+	deployment, ok := request.Attribute(deploymentAttributeKey).(*api.Deployment)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve deployment from request attributes"))
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, deployment)
+}
+
+// UpdateDeployment handles PUT requests to update a deployment, such as changing its template
+// to roll out new pods. With an If-Match header the deployment is only updated if it is still
+// at that resource version.
+func (h *DeploymentHandler) UpdateDeployment(request *restful.Request, response *restful.Response) {
+	existingDeployment, ok := request.Attribute(deploymentAttributeKey).(*api.Deployment)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve deployment from request attributes"))
+		return
+	}
+
+	deployment := new(api.Deployment)
+	if err := request.ReadEntity(deployment); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if existingDeployment.Name != deployment.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("deployment name in URL does not match the deployment in the request body"))
+		return
+	}
+
+	revision, ok := checkIfMatch(request, response, existingDeployment.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.deploymentRegistry.UpdateAtRevision(request.Request.Context(), deployment, revision)
+	} else {
+		err = h.deploymentRegistry.Update(request.Request.Context(), deployment)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrDeploymentInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, deployment)
+}
+
+// DeleteDeployment handles DELETE requests to remove a deployment. With an If-Match header the
+// deployment is only deleted if it is still at that resource version.
+func (h *DeploymentHandler) DeleteDeployment(request *restful.Request, response *restful.Response) {
+	deployment, ok := request.Attribute(deploymentAttributeKey).(*api.Deployment)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve deployment from request attributes"))
+		return
+	}
+
+	revision, ok := checkIfMatch(request, response, deployment.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.deploymentRegistry.DeleteAtRevision(request.Request.Context(), deployment.Name, revision)
+	} else {
+		err = h.deploymentRegistry.Delete(request.Request.Context(), deployment.Name)
+	}
+	if err != nil {
+		writeDeleteError(response, err)
+		return
+	}
+
+	api.WriteResponse(response, http.StatusNoContent, nil)
+}
+
+// ListDeployments handles GET requests to list all deployments. With ?watch=true it streams
+// changes to deployments instead, preceded by the current deployments with ?sendInitialEvents=true.
+func (h *DeploymentHandler) ListDeployments(request *restful.Request, response *restful.Response) {
+	if isWatchRequest(request) {
+		h.WatchDeployments(request, response)
+		return
+	}
+
+	deployments, err := h.deploymentRegistry.List(request.Request.Context())
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, deployments)
+}
+
+// WatchDeployments streams changes to deployments, starting after the optional resourceVersion query parameter
+func (h *DeploymentHandler) WatchDeployments(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	watch := func(resourceVersion int64) (<-chan storage.WatchEvent, error) {
+		return h.deploymentRegistry.Watch(ctx, resourceVersion)
+	}
+
+	if isWatchListRequest(request) {
+		serveWatchList(request, response, func() ([]*api.Deployment, int64, error) {
+			return h.deploymentRegistry.ListWithRevision(ctx)
+		}, watch)
+		return
+	}
+	serveWatch(request, response, h.deploymentRegistry.CurrentRevision, watch)
+}
+
+// RegisterDeploymentRoutes registers deployment routes with the WebService
+func RegisterDeploymentRoutes(ws *restful.WebService, handler *DeploymentHandler) {
+	ws.Route(ws.POST("/deployments").Consumes(restful.MIME_JSON, MIMEYAML).To(handler.CreateDeployment))
+	ws.Route(ws.GET("/deployments").To(handler.ListDeployments))
+	ws.Route(ws.GET("/deployments/{name}").Filter(handler.LoadDeploymentIntoRequest).To(handler.GetDeployment))
+	ws.Route(ws.PUT("/deployments/{name}").Consumes(restful.MIME_JSON, MIMEYAML).Filter(handler.LoadDeploymentIntoRequest).To(handler.UpdateDeployment))
+	ws.Route(ws.DELETE("/deployments/{name}").Filter(handler.LoadDeploymentIntoRequest).To(handler.DeleteDeployment))
+}
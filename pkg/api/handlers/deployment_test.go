@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestDeploymentRoutes(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		RegisterDeploymentRoutes(ws, NewDeploymentHandler(registry.NewDeploymentRegistry(storage.NewEtcdStorage(etcdServer))))
+
+		serve := func(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			for i := 0; i+1 < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+		body := func(image string, replicas int) string {
+			deployment := api.Deployment{
+				ObjectMeta: api.ObjectMeta{Name: "web"},
+				Spec: api.DeploymentSpec{
+					Replicas: int32(replicas),
+					Selector: map[string]string{"app": "web"},
+					Template: api.PodTemplateSpec{
+						ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "web"}},
+						Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: image}}},
+					},
+				},
+			}
+			data, err := json.Marshal(deployment)
+			require.NoError(t, err)
+			return string(data)
+		}
+
+		t.Run("should create a deployment", func(t *testing.T) {
+			assert.Equal(t, http.StatusCreated, serve("POST", "/api/v1/deployments", body("nginx:latest", 3)).Code)
+			assert.Equal(t, http.StatusConflict, serve("POST", "/api/v1/deployments", body("nginx:latest", 3)).Code)
+			assert.Equal(t, http.StatusBadRequest, serve("POST", "/api/v1/deployments", body("nginx:latest", -1)).Code)
+		})
+
+		t.Run("should get and list deployments", func(t *testing.T) {
+			resp := serve("GET", "/api/v1/deployments/web", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			var deployment api.Deployment
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &deployment))
+			assert.Equal(t, int32(3), deployment.Spec.Replicas)
+
+			resp = serve("GET", "/api/v1/deployments", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			var deployments []api.Deployment
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &deployments))
+			require.Len(t, deployments, 1)
+
+			assert.Equal(t, http.StatusNotFound, serve("GET", "/api/v1/deployments/missing", "").Code)
+		})
+
+		t.Run("should update the template of a deployment", func(t *testing.T) {
+			resp := serve("PUT", "/api/v1/deployments/web", body("nginx:1.19", 3), ifMatchHeader, "1")
+			assert.Equal(t, http.StatusConflict, resp.Code)
+
+			resp = serve("PUT", "/api/v1/deployments/web", body("nginx:1.19", 3))
+			require.Equal(t, http.StatusOK, resp.Code)
+			var deployment api.Deployment
+			require.NoError(t, json.Unmarshal(serve("GET", "/api/v1/deployments/web", "").Body.Bytes(), &deployment))
+			assert.Equal(t, "nginx:1.19", deployment.Spec.Template.Spec.Containers[0].Image)
+		})
+
+		t.Run("should delete a deployment", func(t *testing.T) {
+			assert.Equal(t, http.StatusNoContent, serve("DELETE", "/api/v1/deployments/web", "").Code)
+			assert.Equal(t, http.StatusNotFound, serve("GET", "/api/v1/deployments/web", "").Code)
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// dumpResources is the order in which resources are paged through in a dump
+var dumpResources = []string{"pods", "nodes", "replicasets"}
+
+// DumpHandler serves a snapshot of all resources, for debugging
+type DumpHandler struct {
+	podRegistry        *registry.PodRegistry
+	nodeRegistry       *registry.NodeRegistry
+	replicaSetRegistry *registry.ReplicaSetRegistry
+}
+
+// NewDumpHandler creates a new DumpHandler
+func NewDumpHandler(podRegistry *registry.PodRegistry, nodeRegistry *registry.NodeRegistry, replicaSetRegistry *registry.ReplicaSetRegistry) *DumpHandler {
+	return &DumpHandler{
+		podRegistry:        podRegistry,
+		nodeRegistry:       nodeRegistry,
+		replicaSetRegistry: replicaSetRegistry,
+	}
+}
+
+// Dump handles GET requests for all pods, nodes and replicasets.
+// With ?limit=<n> at most n resources are returned, and the continue token of the response
+// returns the next page with ?continue=<token>. Each page is read from the current state of the
+// cluster, so resources changed between pages may be missed or seen twice.
+func (h *DumpHandler) Dump(request *restful.Request, response *restful.Response) {
+	limit, err := parseLimit(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	after, err := decodeDumpContinue(request.QueryParameter("continue"))
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := request.Request.Context()
+	pager := &dumpPager{after: after, remaining: limit}
+	dump := &api.Dump{}
+	dump.Pods, err = pageOf(pager, 0, func(limit int64, continueToken string) ([]*api.Pod, string, error) {
+		return h.podRegistry.ListPodsPaged(ctx, api.NamespaceAll, limit, continueToken)
+	})
+	if err == nil {
+		dump.Nodes, err = pageOf(pager, 1, func(limit int64, continueToken string) ([]*api.Node, string, error) {
+			return h.nodeRegistry.ListNodesPaged(ctx, limit, continueToken)
+		})
+	}
+	if err == nil {
+		dump.ReplicaSets, err = pageOf(pager, 2, func(limit int64, continueToken string) ([]*api.ReplicaSet, string, error) {
+			return h.replicaSetRegistry.ListPaged(ctx, limit, continueToken)
+		})
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, registry.ErrInvalidContinueToken) {
+			status = http.StatusBadRequest
+		}
+		api.WriteError(response, status, err)
+		return
+	}
+	if pager.next != nil {
+		dump.Continue = encodeDumpContinue(*pager.next)
+	}
+
+	api.WriteResponse(response, http.StatusOK, dump)
+}
+
+// dumpCursor is where a dump resumes
+type dumpCursor struct {
+	// resource is the index in dumpResources of the resource type the dump resumes at
+	resource int
+	// continueToken continues the listing of that resource type, it is empty to list it from
+	// the start. It carries the storage key of the last resource listed, so resources of the
+	// same name in different namespaces are told apart.
+	continueToken string
+}
+
+// dumpPager tracks a page of a dump as resources of each type are added to it
+type dumpPager struct {
+	// after is where the previous page ended
+	after dumpCursor
+	// remaining is how many more resources fit in the page, or -1 for no limit
+	remaining int
+	// next is where the next page starts, it is nil while the page isn't full
+	next *dumpCursor
+}
+
+// pageOf lists the resources of the type that belong in the page, in storage key order. Only
+// as many resources as fit in the page are read from storage.
+func pageOf[T any](p *dumpPager, resource int, list func(limit int64, continueToken string) ([]T, string, error)) ([]T, error) {
+	if p.next != nil || resource < p.after.resource {
+		return make([]T, 0), nil
+	}
+
+	continueToken := ""
+	if resource == p.after.resource {
+		continueToken = p.after.continueToken
+	}
+	var limit int64
+	if p.remaining > 0 {
+		limit = int64(p.remaining)
+	}
+	items, next, err := list(limit, continueToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.remaining > 0 {
+		p.remaining -= len(items)
+	}
+	switch {
+	case next != "":
+		p.next = &dumpCursor{resource: resource, continueToken: next}
+	case p.remaining == 0 && resource < len(dumpResources)-1:
+		p.next = &dumpCursor{resource: resource + 1}
+	}
+	if items == nil {
+		items = make([]T, 0)
+	}
+	return items, nil
+}
+
+// parseLimit returns the limit query parameter, or -1 when it isn't set
+func parseLimit(request *restful.Request) (int, error) {
+	value := request.QueryParameter("limit")
+	if value == "" {
+		return -1, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit %q, must be a positive integer", value)
+	}
+	return limit, nil
+}
+
+// encodeDumpContinue returns the continue token resuming a dump at the cursor
+func encodeDumpContinue(cursor dumpCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(dumpResources[cursor.resource] + "/" + cursor.continueToken))
+}
+
+// decodeDumpContinue returns the cursor of a continue token, or a cursor at the first
+// resource when there is no token
+func decodeDumpContinue(token string) (dumpCursor, error) {
+	if token == "" {
+		return dumpCursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return dumpCursor{}, fmt.Errorf("invalid continue token %q", token)
+	}
+	resource, continueToken, found := strings.Cut(string(data), "/")
+	index := slices.Index(dumpResources, resource)
+	if index < 0 || !found {
+		return dumpCursor{}, fmt.Errorf("invalid continue token %q", token)
+	}
+	return dumpCursor{resource: index, continueToken: continueToken}, nil
+}
+
+// RegisterDumpRoutes registers the dump route with the WebService
+func RegisterDumpRoutes(ws *restful.WebService, handler *DumpHandler) {
+	ws.Route(ws.GET("/dump").To(handler.Dump))
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestDump(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		store := storage.NewEtcdStorage(etcdServer)
+		podRegistry := registry.NewPodRegistry(store)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(store)
+		RegisterDumpRoutes(ws, NewDumpHandler(podRegistry, nodeRegistry, replicaSetRegistry))
+		ctx := context.Background()
+
+		spec := api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}}
+		for _, name := range []string{"pod-b", "pod-a", "pod-c"} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{ObjectMeta: api.ObjectMeta{Name: name}, Spec: spec}))
+		}
+		for _, name := range []string{"node-1", "node-2"} {
+			require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: name}}))
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 1,
+				Selector: map[string]string{"app": "rs"},
+				Template: api.PodTemplateSpec{ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "rs"}}, Spec: spec},
+			},
+		}))
+
+		getDump := func(query url.Values) (*httptest.ResponseRecorder, *api.Dump) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/dump?"+query.Encode(), nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			dump := new(api.Dump)
+			if resp.Code == http.StatusOK {
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), dump))
+			}
+			return resp, dump
+		}
+
+		t.Run("should include resources of every type", func(t *testing.T) {
+			resp, dump := getDump(url.Values{})
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+			assert.Equal(t, []string{"pod-a", "pod-b", "pod-c"}, names(dump.Pods, func(p *api.Pod) string { return p.Name }))
+			assert.Equal(t, []string{"node-1", "node-2"}, names(dump.Nodes, func(n *api.Node) string { return n.Name }))
+			assert.Equal(t, []string{"rs"}, names(dump.ReplicaSets, func(rs *api.ReplicaSet) string { return rs.Name }))
+			assert.Empty(t, dump.Continue)
+		})
+
+		t.Run("should page through all resources", func(t *testing.T) {
+			var all []string
+			query := url.Values{"limit": {"2"}}
+			for pages := 1; ; pages++ {
+				require.LessOrEqual(t, pages, 3)
+
+				resp, dump := getDump(query)
+				require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+				assert.LessOrEqual(t, len(dump.Pods)+len(dump.Nodes)+len(dump.ReplicaSets), 2)
+
+				all = append(all, names(dump.Pods, func(p *api.Pod) string { return "pod/" + p.Name })...)
+				all = append(all, names(dump.Nodes, func(n *api.Node) string { return "node/" + n.Name })...)
+				all = append(all, names(dump.ReplicaSets, func(rs *api.ReplicaSet) string { return "replicaset/" + rs.Name })...)
+				if dump.Continue == "" {
+					break
+				}
+				query.Set("continue", dump.Continue)
+			}
+
+			assert.Equal(t, []string{"pod/pod-a", "pod/pod-b", "pod/pod-c", "node/node-1", "node/node-2", "replicaset/rs"}, all)
+		})
+
+		t.Run("should page through pods of the same name in different namespaces", func(t *testing.T) {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod-a", Namespace: "team-a"}, Spec: spec}))
+			defer func() { _ = podRegistry.DeletePod(ctx, "team-a", "pod-a") }()
+
+			var pods []string
+			query := url.Values{"limit": {"1"}}
+			for pages := 1; ; pages++ {
+				require.LessOrEqual(t, pages, 7)
+
+				resp, dump := getDump(query)
+				require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+				pods = append(pods, names(dump.Pods, func(p *api.Pod) string { return p.Namespace + "/" + p.Name })...)
+				if dump.Continue == "" {
+					break
+				}
+				query.Set("continue", dump.Continue)
+			}
+
+			assert.Equal(t, []string{"default/pod-a", "default/pod-b", "default/pod-c", "team-a/pod-a"}, pods)
+		})
+
+		t.Run("should reject an invalid limit or continue token", func(t *testing.T) {
+			for _, query := range []url.Values{{"limit": {"0"}}, {"limit": {"many"}}, {"continue": {"not-a-token"}}, {"continue": {encodeDumpContinue(dumpCursor{resource: 1, continueToken: "not-a-token"})}}} {
+				resp, _ := getDump(query)
+				assert.Equal(t, http.StatusBadRequest, resp.Code, fmt.Sprint(query))
+			}
+		})
+	})
+}
+
+func names[T any](items []T, name func(T) string) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, name(item))
+	}
+	return result
+}
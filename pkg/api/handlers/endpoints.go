@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"gokube/pkg/api"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+// EndpointsLister looks up the ready pods matching a label selector from a cache
+type EndpointsLister interface {
+	EndpointsFor(namespace string, selector map[string]string) []string
+	HasSynced() bool
+}
+
+// EndpointsHandler handles Endpoints-related HTTP requests
+type EndpointsHandler struct {
+	lister EndpointsLister
+}
+
+// NewEndpointsHandler creates a new EndpointsHandler
+func NewEndpointsHandler(lister EndpointsLister) *EndpointsHandler {
+	return &EndpointsHandler{lister: lister}
+}
+
+// GetEndpoints handles GET requests for the endpoints of the ?labelSelector=key=value,...
+// query parameter, in the namespace of the path or in all namespaces without one
+func (h *EndpointsHandler) GetEndpoints(request *restful.Request, response *restful.Response) {
+	selector, err := api.ParseSelector(request.QueryParameter("labelSelector"))
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if !h.lister.HasSynced() {
+		api.WriteError(response, http.StatusServiceUnavailable, fmt.Errorf("endpoints cache has not synced yet"))
+		return
+	}
+
+	namespace := namespaceOf(request)
+	api.WriteResponse(response, http.StatusOK, &api.Endpoints{
+		Namespace: namespace,
+		Selector:  selector,
+		Pods:      h.lister.EndpointsFor(namespace, selector),
+	})
+}
+
+// RegisterEndpointsRoutes registers the Endpoints routes with the WebService
+func RegisterEndpointsRoutes(ws *restful.WebService, endpointsHandler *EndpointsHandler) {
+	ws.Route(ws.GET("/endpoints").To(endpointsHandler.GetEndpoints))
+	ws.Route(ws.GET("/namespaces/{namespace}/endpoints").To(endpointsHandler.GetEndpoints))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// ContinueHeader is the response header of a paged list that carries the continue token of the
+// next page. It is not set on the last page.
+const ContinueHeader = "X-Continue"
+
+// listPage is a page of a list requested with ?limit= and ?continue=
+type listPage struct {
+	limit         int64
+	continueToken string
+}
+
+// parseListPage returns the page requested, and false when the whole list is requested
+func parseListPage(request *restful.Request) (listPage, bool, error) {
+	limit, err := parseLimit(request)
+	if err != nil {
+		return listPage{}, false, err
+	}
+
+	page := listPage{continueToken: request.QueryParameter("continue")}
+	if limit > 0 {
+		page.limit = int64(limit)
+	}
+	return page, limit > 0 || page.continueToken != "", nil
+}
+
+// parseListOrder returns the order requested with ?orderBy=, by name when there is none. Pages
+// are listed in the order objects are stored in, which is by name.
+func parseListOrder(request *restful.Request, paged bool) (registry.ListOrder, error) {
+	order, err := registry.ParseListOrder(request.QueryParameter("orderBy"))
+	if err != nil {
+		return "", err
+	}
+	if paged && order != registry.OrderByName {
+		return "", fmt.Errorf("%w: pages can only be listed by %s", registry.ErrInvalidListOrder, registry.OrderByName)
+	}
+	return order, nil
+}
+
+// writeListPage writes a page of a list, with the token continuing the list in ContinueHeader
+func writeListPage(response *restful.Response, items interface{}, continueToken string, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, registry.ErrInvalidContinueToken) {
+			status = http.StatusBadRequest
+		}
+		api.WriteError(response, status, err)
+		return
+	}
+
+	if continueToken != "" {
+		response.AddHeader(ContinueHeader, continueToken)
+	}
+	api.WriteResponse(response, http.StatusOK, items)
+}
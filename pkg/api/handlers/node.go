@@ -7,6 +7,7 @@ import (
 
 	"gokube/pkg/api"
 	"gokube/pkg/registry"
+	"gokube/pkg/storage"
 
 	"github.com/emicklei/go-restful/v3"
 )
@@ -29,7 +30,7 @@ func (h *NodeHandler) LoadNodeIntoRequest(req *restful.Request, resp *restful.Re
 	node, err := h.nodeRegistry.GetNode(req.Request.Context(), name)
 	if err != nil {
 		switch {
-		case errors.Is(err, registry.ErrNodeNotFound):
+		case errors.Is(err, registry.ErrNotFound):
 			api.WriteError(resp, http.StatusNotFound, err)
 		default:
 			api.WriteError(resp, http.StatusInternalServerError, err)
@@ -73,7 +74,8 @@ func (h *NodeHandler) GetNode(request *restful.Request, response *restful.Respon
 	api.WriteResponse(response, http.StatusOK, node)
 }
 
-// UpdateNode handles PUT requests to update a Node
+// UpdateNode handles PUT requests to update a Node. With an If-Match header the Node is only
+// updated if it is still at that resource version.
 func (h *NodeHandler) UpdateNode(request *restful.Request, response *restful.Response) {
 	existingNode, ok := request.Attribute(nodeAttributeKey).(*api.Node)
 	if !ok {
@@ -92,10 +94,23 @@ func (h *NodeHandler) UpdateNode(request *restful.Request, response *restful.Res
 		return
 	}
 
-	if err := h.nodeRegistry.UpdateNode(request.Request.Context(), node); err != nil {
+	revision, ok := checkIfMatch(request, response, existingNode.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.nodeRegistry.UpdateNodeAtRevision(request.Request.Context(), node, revision)
+	} else {
+		err = h.nodeRegistry.UpdateNode(request.Request.Context(), node)
+	}
+	if err != nil {
 		switch {
 		case errors.Is(err, registry.ErrNodeInvalid):
 			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrConflict):
+			api.WriteError(response, http.StatusConflict, err)
 		default:
 			api.WriteError(response, http.StatusInternalServerError, err)
 		}
@@ -105,7 +120,97 @@ func (h *NodeHandler) UpdateNode(request *restful.Request, response *restful.Res
 	api.WriteResponse(response, http.StatusOK, node)
 }
 
-// DeleteNode handles DELETE requests to remove a Node
+// PatchNode handles PATCH requests with a JSON merge patch for a Node. The patch is applied to
+// the stored Node, which is only written if it wasn't modified in the meantime. An invalid
+// patched Node is rejected with 422.
+func (h *NodeHandler) PatchNode(request *restful.Request, response *restful.Response) {
+	existingNode, ok := request.Attribute(nodeAttributeKey).(*api.Node)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve node from request attributes"))
+		return
+	}
+
+	if _, ok := checkIfMatch(request, response, existingNode.ResourceVersion); !ok {
+		return
+	}
+
+	patch, err := readMergePatch(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	node := new(api.Node)
+	if err := applyMergePatch(existingNode, patch, node); err != nil {
+		api.WriteError(response, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if existingNode.Name != node.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("the name of a node can't be patched"))
+		return
+	}
+
+	revision, err := storage.ResourceVersionOf(existingNode)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.nodeRegistry.UpdateNodeAtRevision(request.Request.Context(), node, revision); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNodeInvalid):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, registry.ErrConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, node)
+}
+
+// UpdateNodeStatus handles PUT requests to the status subresource of a Node.
+// Only the status, conditions and resources are updated, so that kubelet reports don't
+// overwrite the spec.
+func (h *NodeHandler) UpdateNodeStatus(request *restful.Request, response *restful.Response) {
+	existingNode, ok := request.Attribute(nodeAttributeKey).(*api.Node)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve node from request attributes"))
+		return
+	}
+
+	statusNode := new(api.Node)
+	if err := request.ReadEntity(statusNode); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if existingNode.Name != statusNode.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("node name in URL does not match the name in the request body"))
+		return
+	}
+
+	updatedNode, err := h.nodeRegistry.UpdateNodeStatus(request.Request.Context(), statusNode)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNodeSpecChanged):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, updatedNode)
+}
+
+// DeleteNode handles DELETE requests to remove a Node. With an If-Match header the Node is only
+// deleted if it is still at that resource version.
 func (h *NodeHandler) DeleteNode(request *restful.Request, response *restful.Response) {
 	node, ok := request.Attribute(nodeAttributeKey).(*api.Node)
 	if !ok {
@@ -113,18 +218,50 @@ func (h *NodeHandler) DeleteNode(request *restful.Request, response *restful.Res
 		return
 	}
 
-	if err := h.nodeRegistry.DeleteNode(request.Request.Context(), node.Name); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+	revision, ok := checkIfMatch(request, response, node.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.nodeRegistry.DeleteNodeAtRevision(request.Request.Context(), node.Name, revision)
+	} else {
+		err = h.nodeRegistry.DeleteNode(request.Request.Context(), node.Name)
+	}
+	if err != nil {
+		writeDeleteError(response, err)
 		return
 	}
 
 	api.WriteResponse(response, http.StatusNoContent, nil)
 }
 
-// ListNodes handles GET requests to list all Nodes
+// ListNodes handles GET requests to list all Nodes. With ?watch=true it streams changes
+// to Nodes instead, preceded by the current Nodes with ?sendInitialEvents=true.
 func (h *NodeHandler) ListNodes(request *restful.Request, response *restful.Response) {
+	if isWatchRequest(request) {
+		h.WatchNodes(request, response)
+		return
+	}
+
+	page, paged, err := parseListPage(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	order, err := parseListOrder(request, paged)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if paged {
+		nodes, next, err := h.nodeRegistry.ListNodesPaged(request.Request.Context(), page.limit, page.continueToken)
+		writeListPage(response, nodes, next, err)
+		return
+	}
 
-	nodes, err := h.nodeRegistry.ListNodes(request.Request.Context())
+	nodes, err := h.nodeRegistry.ListNodesOrdered(request.Request.Context(), order)
 	if err != nil {
 		api.WriteError(response, http.StatusInternalServerError, err)
 		return
@@ -132,11 +269,29 @@ func (h *NodeHandler) ListNodes(request *restful.Request, response *restful.Resp
 	api.WriteResponse(response, http.StatusOK, nodes)
 }
 
+// WatchNodes streams changes to Nodes, starting after the optional resourceVersion query parameter
+func (h *NodeHandler) WatchNodes(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	watch := func(resourceVersion int64) (<-chan storage.WatchEvent, error) {
+		return h.nodeRegistry.WatchNodes(ctx, resourceVersion)
+	}
+
+	if isWatchListRequest(request) {
+		serveWatchList(request, response, func() ([]*api.Node, int64, error) {
+			return h.nodeRegistry.ListNodesWithRevision(ctx)
+		}, watch)
+		return
+	}
+	serveWatch(request, response, h.nodeRegistry.CurrentRevision, watch)
+}
+
 // RegisterNodeRoutes registers Node routes with the WebService
 func RegisterNodeRoutes(ws *restful.WebService, handler *NodeHandler) {
-	ws.Route(ws.POST("/nodes").To(handler.CreateNode))
+	ws.Route(ws.POST("/nodes").Consumes(restful.MIME_JSON, MIMEYAML).To(handler.CreateNode))
 	ws.Route(ws.GET("/nodes").To(handler.ListNodes))
 	ws.Route(ws.GET("/nodes/{name}").Filter(handler.LoadNodeIntoRequest).To(handler.GetNode))
-	ws.Route(ws.PUT("/nodes/{name}").Filter(handler.LoadNodeIntoRequest).To(handler.UpdateNode))
+	ws.Route(ws.PUT("/nodes/{name}").Consumes(restful.MIME_JSON, MIMEYAML).Filter(handler.LoadNodeIntoRequest).To(handler.UpdateNode))
+	ws.Route(ws.PUT("/nodes/{name}/status").Filter(handler.LoadNodeIntoRequest).To(handler.UpdateNodeStatus))
+	ws.Route(ws.PATCH("/nodes/{name}").Consumes(MIMEMergePatch).Filter(handler.LoadNodeIntoRequest).To(handler.PatchNode))
 	ws.Route(ws.DELETE("/nodes/{name}").Filter(handler.LoadNodeIntoRequest).To(handler.DeleteNode))
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
 	"gokube/pkg/api"
@@ -403,6 +404,92 @@ func TestUpdateNode(t *testing.T) {
 			assert.Equal(t, http.StatusNotFound, resp.Code)
 		})
 	})
+
+	t.Run("should only update a node at the If-Match resource version", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			nodeRegistry := registry.NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterNodeRoutes(ws, NewNodeHandler(nodeRegistry))
+			ctx := context.Background()
+
+			require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "test-node"}}))
+			stored, err := nodeRegistry.GetNode(ctx, "test-node")
+			require.NoError(t, err)
+			staleVersion := stored.ResourceVersion
+
+			update := func(ifMatch string, providerID string) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(&api.Node{
+					ObjectMeta: api.ObjectMeta{Name: "test-node", ResourceVersion: staleVersion},
+					Spec:       api.NodeSpec{ProviderID: providerID},
+				})
+				req := httptest.NewRequest("PUT", "/api/v1/nodes/test-node", bytes.NewReader(body))
+				req.Header.Set("Content-Type", restful.MIME_JSON)
+				if ifMatch != "" {
+					req.Header.Set("If-Match", ifMatch)
+				}
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, req)
+				return resp
+			}
+
+			resp := update(`"`+staleVersion+`"`, "docker://a")
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+			// A client still holding the old version must not overwrite the update
+			resp = update(staleVersion, "docker://b")
+			assert.Equal(t, http.StatusConflict, resp.Code)
+			stored, err = nodeRegistry.GetNode(ctx, "test-node")
+			require.NoError(t, err)
+			assert.Equal(t, "docker://a", stored.Spec.ProviderID)
+
+			// Without If-Match the node is replaced whatever the resource version in the body
+			resp = update("", "docker://c")
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+			stored, err = nodeRegistry.GetNode(ctx, "test-node")
+			require.NoError(t, err)
+			assert.Equal(t, "docker://c", stored.Spec.ProviderID)
+		})
+	})
+}
+
+func TestPatchNode(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		nodeRegistry := registry.NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterNodeRoutes(ws, NewNodeHandler(nodeRegistry))
+
+		node := &api.Node{
+			ObjectMeta: api.ObjectMeta{Name: "test-node"},
+			Spec:       api.NodeSpec{ProviderID: "docker://test-node"},
+			Status:     api.NodeReady,
+		}
+		require.NoError(t, nodeRegistry.CreateNode(context.Background(), node))
+
+		patch := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("PATCH", "/api/v1/nodes/test-node", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", MIMEMergePatch)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		t.Run("should cordon the node without changing other fields", func(t *testing.T) {
+			resp := patch(`{"spec": {"unschedulable": true}}`)
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+			stored, err := nodeRegistry.GetNode(context.Background(), "test-node")
+			require.NoError(t, err)
+			assert.True(t, stored.Spec.Unschedulable)
+			assert.Equal(t, "docker://test-node", stored.Spec.ProviderID)
+			assert.Equal(t, api.NodeReady, stored.Status)
+		})
+
+		t.Run("should reject a patch that doesn't decode into a node", func(t *testing.T) {
+			assert.Equal(t, http.StatusUnprocessableEntity, patch(`{"spec": {"unschedulable": "yes"}}`).Code)
+		})
+
+		t.Run("should reject renaming the node", func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, patch(`{"metadata": {"name": null}}`).Code)
+		})
+	})
 }
 
 func TestDeleteNode(t *testing.T) {
@@ -533,6 +620,34 @@ func TestListNodes(t *testing.T) {
 		})
 	})
 
+	t.Run("should list the newest nodes first", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			nodeRegistry := registry.NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterNodeRoutes(ws, NewNodeHandler(nodeRegistry))
+			ctx := context.Background()
+
+			created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, name := range []string{"node-b", "node-a", "node-c"} {
+				require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+					ObjectMeta: api.ObjectMeta{Name: name, CreationTimestamp: created.Add(time.Duration(i) * time.Minute)},
+				}))
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/nodes?orderBy=-creationTimestamp", nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var nodes []api.Node
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &nodes))
+			var names []string
+			for _, node := range nodes {
+				names = append(names, node.Name)
+			}
+			assert.Equal(t, []string{"node-c", "node-a", "node-b"}, names)
+		})
+	})
+
 	t.Run("should return internal server error for registry failure", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -556,3 +671,26 @@ func TestListNodes(t *testing.T) {
 		})
 	})
 }
+
+func TestWatchNodes(t *testing.T) {
+	t.Run("should stream node changes when watch is requested", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			nodeRegistry := registry.NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterNodeRoutes(ws, NewNodeHandler(nodeRegistry))
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			events := startWatch(t, server.URL+"/api/v1/nodes?watch=true")
+
+			node := &api.Node{ObjectMeta: api.ObjectMeta{Name: "test-node"}, Status: api.NodeReady}
+			require.NoError(t, nodeRegistry.CreateNode(context.Background(), node))
+
+			event := nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchAdded, event.Type)
+			var watchedNode api.Node
+			require.NoError(t, json.Unmarshal(event.Object, &watchedNode))
+			assert.Equal(t, "test-node", watchedNode.Name)
+		})
+	})
+}
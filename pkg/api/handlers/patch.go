@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+// MIMEMergePatch is the content type of a JSON merge patch (RFC 7386)
+const MIMEMergePatch = "application/merge-patch+json"
+
+var (
+	// ErrInvalidPatch is returned when the body of a PATCH request is not a JSON merge patch
+	ErrInvalidPatch = errors.New("invalid merge patch")
+	// ErrInvalidPatchedObject is returned when the patched object can't be decoded, such as when
+	// the patch sets a field to a value of the wrong type
+	ErrInvalidPatchedObject = errors.New("invalid patched object")
+)
+
+// readMergePatch reads the JSON merge patch in the body of the request
+func readMergePatch(request *restful.Request) (any, error) {
+	body, err := io.ReadAll(request.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	patch, err := decodeJSON(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return patch, nil
+}
+
+// applyMergePatch applies the merge patch to the JSON encoding of original and decodes the
+// result into patched
+func applyMergePatch(original any, patch any, patched any) error {
+	data, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	document, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(merged, patched); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatchedObject, err)
+	}
+	return nil
+}
+
+// mergePatch merges the patch into the target as described by RFC 7386. A patch that is not an
+// object replaces the target, null values of an object remove the field from the target.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+	for field, value := range patchObject {
+		if value == nil {
+			delete(targetObject, field)
+			continue
+		}
+		targetObject[field] = mergePatch(targetObject[field], value)
+	}
+	return targetObject
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as they were written so that large
+// integers don't lose precision
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+	return document, nil
+}
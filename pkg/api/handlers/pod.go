@@ -1,19 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/emicklei/go-restful/v3"
 
 	"gokube/pkg/api"
 	"gokube/pkg/registry"
+	"gokube/pkg/storage"
 )
 
+// ErrInvalidNodeName is returned when a pod's NodeName doesn't refer to an existing Ready node
+var ErrInvalidNodeName = errors.New("invalid node name")
+
 // PodHandler handles Pod-related requests
 type PodHandler struct {
 	podRegistry *registry.PodRegistry
+	// nodeRegistry is set when the NodeName of created and updated pods is validated
+	nodeRegistry *registry.NodeRegistry
 }
 
 // NewPodHandler creates a new instance of PodHandler
@@ -21,15 +32,57 @@ func NewPodHandler(podRegistry *registry.PodRegistry) *PodHandler {
 	return &PodHandler{podRegistry: podRegistry}
 }
 
+// NewPodHandlerWithNodeValidation creates a PodHandler that rejects pods whose NodeName doesn't
+// refer to an existing Ready node. Pods are still bound to nodes by the scheduler through the
+// registry, which isn't affected.
+func NewPodHandlerWithNodeValidation(podRegistry *registry.PodRegistry, nodeRegistry *registry.NodeRegistry) *PodHandler {
+	return &PodHandler{podRegistry: podRegistry, nodeRegistry: nodeRegistry}
+}
+
+// validateNodeName checks that nodeName, when set, refers to an existing Ready node.
+// It does nothing unless node validation is enabled.
+func (h *PodHandler) validateNodeName(ctx context.Context, nodeName string) error {
+	if h.nodeRegistry == nil || nodeName == "" {
+		return nil
+	}
+
+	node, err := h.nodeRegistry.GetNode(ctx, nodeName)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			return fmt.Errorf("%w: node %q does not exist", ErrInvalidNodeName, nodeName)
+		}
+		return err
+	}
+	if !node.IsReady() {
+		return fmt.Errorf("%w: node %q is not ready", ErrInvalidNodeName, nodeName)
+	}
+	return nil
+}
+
+// writeNodeNameError writes the error returned by validateNodeName
+func writeNodeNameError(response *restful.Response, err error) {
+	if errors.Is(err, ErrInvalidNodeName) {
+		api.WriteError(response, http.StatusUnprocessableEntity, err)
+		return
+	}
+	api.WriteError(response, http.StatusInternalServerError, err)
+}
+
 const podAttributeKey = "pod"
 
+// namespaceOf returns the namespace in the path of the request. It is empty for the routes
+// without a namespace, where pods are in the default namespace.
+func namespaceOf(request *restful.Request) string {
+	return request.PathParameter("namespace")
+}
+
 // LoadPodIntoRequest retrieves the pod and stores it in the request attributes
 func (h *PodHandler) LoadPodIntoRequest(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 	name := req.PathParameter("name")
-	pod, err := h.podRegistry.GetPod(req.Request.Context(), name)
+	pod, err := h.podRegistry.GetPod(req.Request.Context(), namespaceOf(req), name)
 	if err != nil {
 		switch {
-		case errors.Is(err, registry.ErrPodNotFound):
+		case errors.Is(err, registry.ErrNotFound):
 			api.WriteError(resp, http.StatusNotFound, err)
 		default:
 			api.WriteError(resp, http.StatusInternalServerError, err)
@@ -48,10 +101,25 @@ func (h *PodHandler) CreatePod(request *restful.Request, response *restful.Respo
 		return
 	}
 
+	if namespace := namespaceOf(request); namespace != "" {
+		if pod.Namespace != "" && pod.Namespace != namespace {
+			api.WriteError(response, http.StatusBadRequest, fmt.Errorf("pod namespace in request body does not match namespace in URL"))
+			return
+		}
+		pod.Namespace = namespace
+	}
+
+	if err := h.validateNodeName(request.Request.Context(), pod.NodeName); err != nil {
+		writeNodeNameError(response, err)
+		return
+	}
+
 	if err := h.podRegistry.CreatePod(request.Request.Context(), pod); err != nil {
 		switch {
 		case errors.Is(err, registry.ErrPodAlreadyExists):
 			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrPodInvalidContainerName):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
 		case errors.Is(err, registry.ErrPodInvalid):
 			api.WriteError(response, http.StatusBadRequest, err)
 			return
@@ -64,30 +132,233 @@ func (h *PodHandler) CreatePod(request *restful.Request, response *restful.Respo
 	api.WriteResponse(response, http.StatusCreated, pod)
 }
 
-// ListPods handles GET requests to list all Pods
+// ListPods handles GET requests to list the Pods of the namespace in the path, or of all
+// namespaces without one, filtered by the optional field selector. With ?watch=true it streams
+// changes to Pods instead, preceded by the current Pods with ?sendInitialEvents=true.
 func (h *PodHandler) ListPods(request *restful.Request, response *restful.Response) {
-	nodeName := request.QueryParameter("nodeName")
-	pods, err := h.podRegistry.ListPods(request.Request.Context())
+	if isWatchRequest(request) {
+		h.WatchPods(request, response)
+		return
+	}
+
+	selector, err := parsePodFieldSelector(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	page, paged, err := parseListPage(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	order, err := parseListOrder(request, paged)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if paged {
+		if !selector.empty() {
+			api.WriteError(response, http.StatusBadRequest, fmt.Errorf("pods can't be listed in pages by field selector"))
+			return
+		}
+		pods, next, err := h.podRegistry.ListPodsPaged(request.Request.Context(), namespaceOf(request), page.limit, page.continueToken)
+		writeListPage(response, pods, next, err)
+		return
+	}
+
+	var pods []*api.Pod
+	if selector.nodeName != "" {
+		// The pods of a node are looked up in the node index rather than filtered
+		pods, err = h.podRegistry.ListPodsByNode(request.Request.Context(), selector.nodeName)
+		pods = filterNamespace(pods, namespaceOf(request))
+		registry.SortByOrder(pods, order)
+	} else {
+		pods, err = h.podRegistry.ListPodsOrdered(request.Request.Context(), namespaceOf(request), order)
+	}
 	if err != nil {
 		api.WriteError(response, http.StatusInternalServerError, err)
 		return
 	}
+	pods = selector.filter(pods)
 
 	if pods == nil {
 		pods = make([]*api.Pod, 0)
 	}
 
-	if nodeName != "" {
-		filteredPods := make([]*api.Pod, 0)
-		for _, pod := range pods {
-			if pod.NodeName == nodeName {
-				filteredPods = append(filteredPods, pod)
+	api.WriteResponse(response, http.StatusOK, pods)
+}
+
+// filterNamespace returns the pods in the namespace, or all pods for NamespaceAll
+func filterNamespace(pods []*api.Pod, namespace string) []*api.Pod {
+	if namespace == api.NamespaceAll {
+		return pods
+	}
+
+	filtered := make([]*api.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Namespace == namespace {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+// podFieldSelector holds the fields pods are listed by, an empty field matches every pod
+type podFieldSelector struct {
+	nodeName string
+	phase    api.PodPhase
+}
+
+// empty reports whether the selector matches every pod
+func (s podFieldSelector) empty() bool {
+	return s.nodeName == "" && s.phase == ""
+}
+
+// filter returns the pods matching the selector
+func (s podFieldSelector) filter(pods []*api.Pod) []*api.Pod {
+	if s.empty() {
+		return pods
+	}
+
+	filtered := make([]*api.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if s.matches(pod) {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+// matches reports whether the pod matches the selector
+func (s podFieldSelector) matches(pod *api.Pod) bool {
+	return (s.nodeName == "" || pod.NodeName == s.nodeName) && (s.phase == "" || pod.Status.Phase == s.phase)
+}
+
+// filterEvents returns the events of the pods matching the selector. Like a list by the
+// selector, a pod that starts matching it is ADDED and a pod that stops matching it is
+// DELETED, so a watch by spec.nodeName sees a pod leave the node. The returned channel is
+// closed once events is, or the context is done.
+func (s podFieldSelector) filterEvents(ctx context.Context, events <-chan storage.WatchEvent) <-chan storage.WatchEvent {
+	filtered := make(chan storage.WatchEvent)
+	go func() {
+		defer close(filtered)
+		for event := range events {
+			event, ok := s.filterEvent(event)
+			if !ok {
+				continue
 			}
+			select {
+			case filtered <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return filtered
+}
+
+// filterEvent returns the event as seen through the selector, and false if the pod matches
+// the selector neither before nor after the change
+func (s podFieldSelector) filterEvent(event storage.WatchEvent) (storage.WatchEvent, bool) {
+	matches := func(value []byte) bool {
+		if value == nil {
+			return false
+		}
+		pod := &api.Pod{}
+		if err := json.Unmarshal(value, pod); err != nil {
+			// Passed on for the client to report, as an unfiltered watch would
+			return true
 		}
-		pods = filteredPods
+		return s.matches(pod)
 	}
 
-	api.WriteResponse(response, http.StatusOK, pods)
+	switch event.Type {
+	case storage.EventError:
+		return event, true
+	case storage.EventAdd:
+		return event, matches(event.Value)
+	case storage.EventDelete:
+		return event, matches(event.OldValue)
+	}
+
+	now, before := matches(event.Value), matches(event.OldValue)
+	switch {
+	case now && !before:
+		event.Type = storage.EventAdd
+	case !now && before:
+		event.Type = storage.EventDelete
+		event.OldValue = event.Value
+	}
+	return event, now || before
+}
+
+// parsePodFieldSelector returns the fields requested with ?fieldSelector=<field>=<value>,...
+// where the fields are spec.nodeName and status.phase, or with the legacy ?nodeName=<node>
+// and ?status=<phase> query parameters
+func parsePodFieldSelector(request *restful.Request) (podFieldSelector, error) {
+	selector := podFieldSelector{
+		nodeName: request.QueryParameter("nodeName"),
+		phase:    api.PodPhase(request.QueryParameter("status")),
+	}
+
+	fieldSelector := request.QueryParameter("fieldSelector")
+	if fieldSelector != "" {
+		for _, requirement := range strings.Split(fieldSelector, ",") {
+			field, value, found := strings.Cut(requirement, "=")
+			value = strings.TrimPrefix(value, "=")
+			if !found || value == "" {
+				return podFieldSelector{}, fmt.Errorf("invalid field selector %q, expected <field>=<value>", fieldSelector)
+			}
+
+			switch field {
+			case "spec.nodeName":
+				selector.nodeName = value
+			case "status.phase":
+				selector.phase = api.PodPhase(value)
+			default:
+				return podFieldSelector{}, fmt.Errorf("unsupported field selector %q, only spec.nodeName and status.phase are supported", fieldSelector)
+			}
+		}
+	}
+
+	switch selector.phase {
+	case "", api.PodPending, api.PodRunning, api.PodSucceeded, api.PodFailed:
+	default:
+		return podFieldSelector{}, fmt.Errorf("unknown pod phase %q", selector.phase)
+	}
+	return selector, nil
+}
+
+// WatchPods streams changes to the Pods of the namespace in the path, or of all namespaces
+// without one, starting after the optional resourceVersion query parameter. With a field
+// selector only the changes to the Pods matching it are streamed, a Pod that stops matching
+// it is streamed as DELETED.
+func (h *PodHandler) WatchPods(request *restful.Request, response *restful.Response) {
+	selector, err := parsePodFieldSelector(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := request.Request.Context()
+	namespace := namespaceOf(request)
+	watch := func(resourceVersion int64) (<-chan storage.WatchEvent, error) {
+		events, err := h.podRegistry.WatchPodsInNamespace(ctx, namespace, resourceVersion)
+		if err != nil || selector.empty() {
+			return events, err
+		}
+		return selector.filterEvents(ctx, events), nil
+	}
+
+	if isWatchListRequest(request) {
+		serveWatchList(request, response, func() ([]*api.Pod, int64, error) {
+			pods, revision, err := h.podRegistry.ListPodsWithRevision(ctx, namespace)
+			return selector.filter(pods), revision, err
+		}, watch)
+		return
+	}
+	serveWatch(request, response, h.podRegistry.CurrentRevision, watch)
 }
 
 // GetPod handles GET requests to retrieve a Pod
@@ -100,7 +371,8 @@ func (h *PodHandler) GetPod(request *restful.Request, response *restful.Response
 	api.WriteResponse(response, http.StatusOK, pod)
 }
 
-// UpdatePod handles PUT requests to update a Pod
+// UpdatePod handles PUT requests to update a Pod. With an If-Match header the Pod is only
+// updated if it is still at that resource version.
 func (h *PodHandler) UpdatePod(request *restful.Request, response *restful.Response) {
 	existingPod, ok := request.Attribute(podAttributeKey).(*api.Pod)
 	if !ok {
@@ -118,12 +390,42 @@ func (h *PodHandler) UpdatePod(request *restful.Request, response *restful.Respo
 		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("pod name in URL does not match pod name in request body"))
 		return
 	}
+	if err := setNamespace(updatedPod, existingPod); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
 
-	if err := h.podRegistry.UpdatePod(request.Request.Context(), updatedPod); err != nil {
+	revision, ok := checkIfMatch(request, response, existingPod.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	// Only a change of node is validated, so that status updates for pods on a node that
+	// has since become NotReady are still accepted
+	if updatedPod.NodeName != existingPod.NodeName {
+		if err := h.validateNodeName(request.Request.Context(), updatedPod.NodeName); err != nil {
+			writeNodeNameError(response, err)
+			return
+		}
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.podRegistry.UpdatePodAtRevision(request.Request.Context(), updatedPod, revision)
+	} else {
+		err = h.podRegistry.UpdatePod(request.Request.Context(), updatedPod)
+	}
+	if err != nil {
 		switch {
+		case errors.Is(err, registry.ErrPodInvalidContainerName):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+			return
 		case errors.Is(err, registry.ErrPodInvalid):
 			api.WriteError(response, http.StatusBadRequest, err)
 			return
+		case errors.Is(err, registry.ErrConflict):
+			api.WriteError(response, http.StatusConflict, err)
+			return
 		default:
 			api.WriteError(response, http.StatusInternalServerError, err)
 			return
@@ -133,7 +435,118 @@ func (h *PodHandler) UpdatePod(request *restful.Request, response *restful.Respo
 	api.WriteResponse(response, http.StatusOK, updatedPod)
 }
 
-// DeletePod handles DELETE requests to remove a Pod
+// UpdatePodStatus handles PUT requests to the status subresource of a Pod.
+// Only the status and conditions are updated, so that status reports don't overwrite the spec
+// or the node binding.
+func (h *PodHandler) UpdatePodStatus(request *restful.Request, response *restful.Response) {
+	existingPod, ok := request.Attribute(podAttributeKey).(*api.Pod)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve pod from request attributes"))
+		return
+	}
+
+	statusPod := new(api.Pod)
+	if err := request.ReadEntity(statusPod); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if existingPod.Name != statusPod.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("pod name in URL does not match pod name in request body"))
+		return
+	}
+	if err := setNamespace(statusPod, existingPod); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	updatedPod, err := h.podRegistry.UpdatePodStatus(request.Request.Context(), statusPod)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrPodSpecChanged):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, updatedPod)
+}
+
+// PatchPod handles PATCH requests with a JSON merge patch for a Pod. The patch is applied to the
+// stored Pod, which is only written if it wasn't modified in the meantime, so that fields the
+// patch leaves out are never overwritten. An invalid patched Pod is rejected with 422.
+func (h *PodHandler) PatchPod(request *restful.Request, response *restful.Response) {
+	existingPod, ok := request.Attribute(podAttributeKey).(*api.Pod)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve pod from request attributes"))
+		return
+	}
+
+	if _, ok := checkIfMatch(request, response, existingPod.ResourceVersion); !ok {
+		return
+	}
+
+	patch, err := readMergePatch(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	patchedPod := new(api.Pod)
+	if err := applyMergePatch(existingPod, patch, patchedPod); err != nil {
+		api.WriteError(response, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if existingPod.Name != patchedPod.Name || existingPod.Namespace != patchedPod.Namespace {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("the name and namespace of a pod can't be patched"))
+		return
+	}
+	patchedPod.ResourceVersion = existingPod.ResourceVersion
+	revision, err := storage.ResourceVersionOf(existingPod)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	if patchedPod.NodeName != existingPod.NodeName {
+		if err := h.validateNodeName(request.Request.Context(), patchedPod.NodeName); err != nil {
+			writeNodeNameError(response, err)
+			return
+		}
+	}
+
+	if err := h.podRegistry.UpdatePodAtRevision(request.Request.Context(), patchedPod, revision); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrPodInvalid):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, registry.ErrConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, patchedPod)
+}
+
+// setNamespace sets the namespace of the stored pod on the pod in a request body, which may
+// leave it out but can't move the pod to another namespace
+func setNamespace(pod, existingPod *api.Pod) error {
+	if pod.Namespace != "" && pod.Namespace != existingPod.Namespace {
+		return fmt.Errorf("pod namespace in request body does not match the namespace of the pod")
+	}
+	pod.Namespace = existingPod.Namespace
+	return nil
+}
+
+// DeletePod handles DELETE requests to remove a Pod. With an If-Match header the Pod is only
+// deleted if it is still at that resource version.
 func (h *PodHandler) DeletePod(request *restful.Request, response *restful.Response) {
 	pod, ok := request.Attribute(podAttributeKey).(*api.Pod)
 	if !ok {
@@ -141,14 +554,53 @@ func (h *PodHandler) DeletePod(request *restful.Request, response *restful.Respo
 		return
 	}
 
-	if err := h.podRegistry.DeletePod(request.Request.Context(), pod.Name); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+	revision, ok := checkIfMatch(request, response, pod.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	if len(pod.Finalizers) > 0 && !isForceDelete(request) {
+		marked, err := h.podRegistry.MarkPodForDeletion(request.Request.Context(), pod.Namespace, pod.Name)
+		switch {
+		case errors.Is(err, registry.ErrNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case err != nil:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		case marked != nil:
+			// Deletion completes once the finalizers are removed
+			api.WriteResponse(response, http.StatusAccepted, marked)
+		default:
+			api.WriteResponse(response, http.StatusNoContent, nil)
+		}
+		return
+	}
+
+	if len(pod.Finalizers) > 0 {
+		log.Printf("Warning: force deleting pod %s without waiting for finalizers %v", pod.Name, pod.Finalizers)
+	}
+	var err error
+	if revision != 0 {
+		err = h.podRegistry.DeletePodAtRevision(request.Request.Context(), pod.Namespace, pod.Name, revision)
+	} else {
+		err = h.podRegistry.DeletePod(request.Request.Context(), pod.Namespace, pod.Name)
+	}
+	if err != nil {
+		writeDeleteError(response, err)
 		return
 	}
 
 	api.WriteResponse(response, http.StatusNoContent, nil)
 }
 
+// isForceDelete reports whether a DELETE request asks to remove the object immediately,
+// bypassing its finalizers, with ?force=true or ?gracePeriodSeconds=0
+func isForceDelete(request *restful.Request) bool {
+	if force, err := strconv.ParseBool(request.QueryParameter("force")); err == nil && force {
+		return true
+	}
+	return request.QueryParameter("gracePeriodSeconds") == "0"
+}
+
 // ListUnassignedPods handles GET requests to list all unassigned Pods
 func (h *PodHandler) ListUnassignedPods(request *restful.Request, response *restful.Response) {
 	pods, err := h.podRegistry.ListUnassignedPods(request.Request.Context())
@@ -160,11 +612,18 @@ func (h *PodHandler) ListUnassignedPods(request *restful.Request, response *rest
 	api.WriteResponse(response, http.StatusOK, pods)
 }
 
+// RegisterPodRoutes registers the pod routes under /pods, where pods are in the default
+// namespace and listing returns the pods of all namespaces, and under
+// /namespaces/{namespace}/pods
 func RegisterPodRoutes(ws *restful.WebService, podHandler *PodHandler) {
-	ws.Route(ws.POST("/pods").To(podHandler.CreatePod))
-	ws.Route(ws.GET("/pods").To(podHandler.ListPods))
-	ws.Route(ws.GET("/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.GetPod))
-	ws.Route(ws.PUT("/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePod))
-	ws.Route(ws.DELETE("/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.DeletePod))
+	for _, root := range []string{"/pods", "/namespaces/{namespace}/pods"} {
+		ws.Route(ws.POST(root).Consumes(restful.MIME_JSON, MIMEYAML).To(podHandler.CreatePod))
+		ws.Route(ws.GET(root).To(podHandler.ListPods))
+		ws.Route(ws.GET(root + "/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.GetPod))
+		ws.Route(ws.PUT(root+"/{name}").Consumes(restful.MIME_JSON, MIMEYAML).Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePod))
+		ws.Route(ws.PUT(root + "/{name}/status").Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePodStatus))
+		ws.Route(ws.PATCH(root + "/{name}").Consumes(MIMEMergePatch).Filter(podHandler.LoadPodIntoRequest).To(podHandler.PatchPod))
+		ws.Route(ws.DELETE(root + "/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.DeletePod))
+	}
 	ws.Route(ws.GET("/pods/unassigned").To(podHandler.ListUnassignedPods))
 }
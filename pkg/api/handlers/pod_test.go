@@ -7,11 +7,17 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
 	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
 	"github.com/emicklei/go-restful/v3"
@@ -64,10 +70,10 @@ func TestCreatePod(t *testing.T) {
 			assert.Equal(t, pod.Name, createdPod.Name)
 			assert.Equal(t, pod.Spec.Replicas, createdPod.Spec.Replicas)
 			assert.Equal(t, len(pod.Spec.Containers), len(createdPod.Spec.Containers))
-			assert.Equal(t, pod.Spec.Containers[0].Image, createdPod.Spec.Containers[0].Image)
+			assert.Equal(t, "docker.io/library/nginx:latest", createdPod.Spec.Containers[0].Image)
 
-			// Check that the status is set to Unassigned
-			assert.Equal(t, api.PodPending, createdPod.Status)
+			// Check that the phase defaults to Pending
+			assert.Equal(t, api.PodPending, createdPod.Status.Phase)
 		})
 	})
 
@@ -105,6 +111,61 @@ func TestCreatePod(t *testing.T) {
 		})
 	})
 
+	t.Run("should create a pod with multiple containers", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterPodRoutes(ws, NewPodHandler(registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "multi-container"},
+				Spec: api.PodSpec{
+					Containers: []api.Container{
+						{Name: "nginx", Image: "nginx:latest"},
+						{Name: "sidecar", Image: "busybox:latest"},
+					},
+				},
+			}
+
+			body, _ := json.Marshal(pod)
+			req := httptest.NewRequest("POST", "/api/v1/pods", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusCreated, resp.Code)
+		})
+	})
+
+	t.Run("should return unprocessable entity for duplicate container names", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "duplicate-containers"},
+				Spec: api.PodSpec{
+					Containers: []api.Container{
+						{Name: "app", Image: "nginx:latest"},
+						{Name: "app", Image: "busybox:latest"},
+					},
+				},
+			}
+
+			body, _ := json.Marshal(pod)
+			req := httptest.NewRequest("POST", "/api/v1/pods", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			assert.Contains(t, resp.Body.String(), `"app" is used by more than one container`)
+
+			_, err := podRegistry.GetPod(context.Background(), api.NamespaceDefault, "duplicate-containers")
+			assert.ErrorIs(t, err, registry.ErrPodNotFound)
+		})
+	})
+
 	t.Run("should return conflict for existing pod", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
@@ -185,6 +246,96 @@ func TestCreatePod(t *testing.T) {
 	})
 }
 
+func TestPodNodeNameValidation(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		store := storage.NewEtcdStorage(etcdServer)
+		podRegistry := registry.NewPodRegistry(store)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		RegisterPodRoutes(ws, NewPodHandlerWithNodeValidation(podRegistry, nodeRegistry))
+		ctx := context.Background()
+
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "ready-node"}, Status: api.NodeReady}))
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "not-ready-node"}, Status: api.NodeNotReady}))
+
+		send := func(method, path string, pod *api.Pod) *httptest.ResponseRecorder {
+			body, err := json.Marshal(pod)
+			require.NoError(t, err)
+			req := httptest.NewRequest(method, path, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+		newPod := func(name, nodeName string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				NodeName:   nodeName,
+			}
+		}
+
+		t.Run("should accept a pod on an existing ready node", func(t *testing.T) {
+			resp := send("POST", "/api/v1/pods", newPod("on-ready-node", "ready-node"))
+			assert.Equal(t, http.StatusCreated, resp.Code)
+		})
+
+		t.Run("should accept a pod without a node", func(t *testing.T) {
+			resp := send("POST", "/api/v1/pods", newPod("unassigned", ""))
+			assert.Equal(t, http.StatusCreated, resp.Code)
+		})
+
+		t.Run("should reject a pod on a node that does not exist", func(t *testing.T) {
+			resp := send("POST", "/api/v1/pods", newPod("dangling", "missing-node"))
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			assert.Contains(t, resp.Body.String(), `node "missing-node" does not exist`)
+
+			_, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "dangling")
+			assert.ErrorIs(t, err, registry.ErrPodNotFound)
+		})
+
+		t.Run("should reject a pod on a node that is not ready", func(t *testing.T) {
+			resp := send("POST", "/api/v1/pods", newPod("on-not-ready-node", "not-ready-node"))
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("should reject moving a pod to a node that does not exist", func(t *testing.T) {
+			resp := send("PUT", "/api/v1/pods/unassigned", newPod("unassigned", "missing-node"))
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("should accept updates that keep the node of the pod", func(t *testing.T) {
+			node, err := nodeRegistry.GetNode(ctx, "ready-node")
+			require.NoError(t, err)
+			node.Status = api.NodeNotReady
+			require.NoError(t, nodeRegistry.UpdateNode(ctx, node))
+
+			pod := newPod("on-ready-node", "ready-node")
+			pod.Status.Phase = api.PodRunning
+			resp := send("PUT", "/api/v1/pods/on-ready-node", pod)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+	})
+
+	t.Run("should not validate node names unless enabled", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterPodRoutes(ws, NewPodHandler(registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			body, err := json.Marshal(&api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "dangling"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				NodeName:   "missing-node",
+			})
+			require.NoError(t, err)
+			req := httptest.NewRequest("POST", "/api/v1/pods", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusCreated, resp.Code)
+		})
+	})
+}
+
 func TestListPods(t *testing.T) {
 	t.Run("should list all pods", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
@@ -272,6 +423,676 @@ func TestListPods(t *testing.T) {
 	})
 }
 
+func TestListPodsPaged(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+		ctx := context.Background()
+
+		for _, name := range []string{"pod-a", "pod-b", "pod-c"} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}))
+		}
+
+		listPods := func(query url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/api/v1/pods?"+query.Encode(), nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		t.Run("should list pods page by page", func(t *testing.T) {
+			var names []string
+			query := url.Values{"limit": {"2"}}
+			for pages := 1; ; pages++ {
+				resp := listPods(query)
+				require.Equal(t, http.StatusOK, resp.Code)
+
+				var pods []api.Pod
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+				assert.LessOrEqual(t, len(pods), 2)
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+
+				next := resp.Header().Get(ContinueHeader)
+				if next == "" {
+					assert.Equal(t, 2, pages)
+					break
+				}
+				query.Set("continue", next)
+			}
+			assert.Equal(t, []string{"pod-a", "pod-b", "pod-c"}, names)
+		})
+
+		t.Run("should reject an invalid continue token", func(t *testing.T) {
+			resp := listPods(url.Values{"limit": {"2"}, "continue": {"bogus"}})
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("should reject an invalid limit", func(t *testing.T) {
+			resp := listPods(url.Values{"limit": {"-1"}})
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
+
+func TestListPodsOrdered(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+		ctx := context.Background()
+
+		// Created in another order than their names sort in
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, name := range []string{"pod-b", "pod-c", "pod-a"} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name, CreationTimestamp: created.Add(time.Duration(i) * time.Minute)},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}))
+		}
+
+		listPods := func(query url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/api/v1/pods?"+query.Encode(), nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		for orderBy, expected := range map[string][]string{
+			"":                   {"pod-a", "pod-b", "pod-c"},
+			"name":               {"pod-a", "pod-b", "pod-c"},
+			"-name":              {"pod-c", "pod-b", "pod-a"},
+			"creationTimestamp":  {"pod-b", "pod-c", "pod-a"},
+			"-creationTimestamp": {"pod-a", "pod-c", "pod-b"},
+		} {
+			t.Run("should list pods ordered by "+orderBy, func(t *testing.T) {
+				resp := listPods(url.Values{"orderBy": {orderBy}})
+				require.Equal(t, http.StatusOK, resp.Code)
+
+				var pods []api.Pod
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+				var names []string
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+				assert.Equal(t, expected, names)
+			})
+		}
+
+		t.Run("should reject an unknown order", func(t *testing.T) {
+			resp := listPods(url.Values{"orderBy": {"age"}})
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("should reject pages in another order than by name", func(t *testing.T) {
+			resp := listPods(url.Values{"orderBy": {"-creationTimestamp"}, "limit": {"2"}})
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
+
+func TestNamespacedPodRoutes(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+		serve := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+		podNames := func(resp *httptest.ResponseRecorder) []string {
+			var pods []api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Namespace+"/"+pod.Name)
+			}
+			return names
+		}
+		body := `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "web", "image": "nginx"}]}}`
+
+		t.Run("should create pods of the same name in different namespaces", func(t *testing.T) {
+			assert.Equal(t, http.StatusCreated, serve("POST", "/api/v1/pods", body).Code)
+			assert.Equal(t, http.StatusCreated, serve("POST", "/api/v1/namespaces/team-a/pods", body).Code)
+			assert.Equal(t, http.StatusConflict, serve("POST", "/api/v1/namespaces/team-a/pods", body).Code)
+		})
+
+		t.Run("should reject a body in another namespace than the path", func(t *testing.T) {
+			resp := serve("POST", "/api/v1/namespaces/team-a/pods", `{"metadata": {"name": "db", "namespace": "team-b"}, "spec": {"containers": [{"name": "db", "image": "redis"}]}}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("should map the routes without a namespace to the default namespace", func(t *testing.T) {
+			resp := serve("GET", "/api/v1/pods/web", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			var pod api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pod))
+			assert.Equal(t, api.NamespaceDefault, pod.Namespace)
+
+			assert.Equal(t, http.StatusOK, serve("GET", "/api/v1/namespaces/default/pods/web", "").Code)
+			assert.Equal(t, http.StatusNotFound, serve("GET", "/api/v1/namespaces/team-b/pods/web", "").Code)
+		})
+
+		t.Run("should list the pods of a namespace or of all namespaces", func(t *testing.T) {
+			assert.Equal(t, []string{"team-a/web"}, podNames(serve("GET", "/api/v1/namespaces/team-a/pods", "")))
+			assert.ElementsMatch(t, []string{"default/web", "team-a/web"}, podNames(serve("GET", "/api/v1/pods", "")))
+		})
+
+		t.Run("should delete the pod of the namespace only", func(t *testing.T) {
+			assert.Equal(t, http.StatusNoContent, serve("DELETE", "/api/v1/namespaces/team-a/pods/web", "").Code)
+			assert.Equal(t, []string{"default/web"}, podNames(serve("GET", "/api/v1/pods", "")))
+		})
+	})
+}
+
+func TestListPodsByNode(t *testing.T) {
+	newPod := func(name string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name},
+			Spec: api.PodSpec{
+				Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}},
+			},
+		}
+	}
+
+	listPods := func(t *testing.T, container *restful.Container, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/pods?"+query, nil)
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, req)
+		return resp
+	}
+
+	podNames := func(t *testing.T, resp *httptest.ResponseRecorder) []string {
+		var pods []api.Pod
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+		names := make([]string, 0, len(pods))
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	t.Run("should list only the pods bound to the node", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			podRegistry := registry.NewPodRegistry(store)
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			for _, name := range []string{"pod-1", "pod-2", "pod-3"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, newPod(name)))
+			}
+			_, err := podRegistry.BindPod(ctx, api.NamespaceDefault, "pod-1", "node-1")
+			require.NoError(t, err)
+			_, err = podRegistry.BindPod(ctx, api.NamespaceDefault, "pod-2", "node-2")
+			require.NoError(t, err)
+
+			resp := listPods(t, container, "fieldSelector=spec.nodeName=node-1")
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []string{"pod-1"}, podNames(t, resp))
+
+			// A binding is visible as soon as it is committed
+			_, err = podRegistry.BindPod(ctx, api.NamespaceDefault, "pod-3", "node-1")
+			require.NoError(t, err)
+
+			resp = listPods(t, container, "fieldSelector=spec.nodeName=node-1")
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []string{"pod-1", "pod-3"}, podNames(t, resp))
+
+			resp = listPods(t, container, "fieldSelector=spec.nodeName=node-3")
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Empty(t, podNames(t, resp))
+		})
+	})
+
+	t.Run("should drop deleted pods from the node", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			podRegistry := registry.NewPodRegistry(store)
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+			_, err := podRegistry.BindPod(ctx, api.NamespaceDefault, "pod-1", "node-1")
+			require.NoError(t, err)
+			require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, "pod-1"))
+
+			resp := listPods(t, container, "fieldSelector=spec.nodeName=node-1")
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Empty(t, podNames(t, resp))
+		})
+	})
+
+	t.Run("should filter pods by node and status", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			podRegistry := registry.NewPodRegistry(store)
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			for _, name := range []string{"pod-1", "pod-2", "pod-3", "pod-4"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, newPod(name)))
+			}
+			for name, node := range map[string]string{"pod-1": "node-1", "pod-2": "node-1", "pod-3": "node-2"} {
+				_, err := podRegistry.BindPod(ctx, api.NamespaceDefault, name, node)
+				require.NoError(t, err)
+			}
+			pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod-2")
+			require.NoError(t, err)
+			pod.Status.Phase = api.PodRunning
+			_, err = podRegistry.UpdatePodStatus(ctx, pod)
+			require.NoError(t, err)
+
+			for query, expected := range map[string][]string{
+				"":                                   {"pod-1", "pod-2", "pod-3", "pod-4"},
+				"nodeName=node-1":                    {"pod-1", "pod-2"},
+				"status=Pending":                     {"pod-1", "pod-3", "pod-4"},
+				"fieldSelector=status.phase=Pending": {"pod-1", "pod-3", "pod-4"},
+				"fieldSelector=spec.nodeName=node-2,status.phase=Pending": {"pod-3"},
+				"fieldSelector=spec.nodeName=node-1,status.phase=Running": {"pod-2"},
+				"nodeName=node-2&status=Running":                          {},
+			} {
+				resp := listPods(t, container, query)
+				require.Equal(t, http.StatusOK, resp.Code, query)
+				assert.Equal(t, expected, podNames(t, resp), query)
+			}
+		})
+	})
+
+	t.Run("should reject unsupported field selectors", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			RegisterPodRoutes(ws, NewPodHandler(registry.NewPodRegistry(store)))
+
+			for _, query := range []string{
+				"fieldSelector=metadata.uid=1234",
+				"fieldSelector=spec.nodeName",
+				"fieldSelector=status.phase=Sleeping",
+				"status=Sleeping",
+			} {
+				resp := listPods(t, container, query)
+				assert.Equal(t, http.StatusBadRequest, resp.Code, query)
+			}
+		})
+	})
+}
+
+func TestWatchPods(t *testing.T) {
+	newPod := func(name string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name},
+			Spec: api.PodSpec{
+				Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}},
+			},
+		}
+	}
+
+	t.Run("should list pods when watch is not requested", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			require.NoError(t, podRegistry.CreatePod(context.Background(), newPod("test-pod")))
+
+			req := httptest.NewRequest("GET", "/api/v1/pods?watch=false", nil)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var pods []api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+			require.Len(t, pods, 1)
+			assert.Equal(t, "test-pod", pods[0].Name)
+		})
+	})
+
+	t.Run("should stream pod changes when watch is requested", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			events := startWatch(t, server.URL+"/api/v1/pods?watch=true")
+
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("test-pod")))
+			event := nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchAdded, event.Type)
+			var pod api.Pod
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, "test-pod", pod.Name)
+			assert.NotEmpty(t, event.ResourceVersion)
+
+			pod.Status.Phase = api.PodRunning
+			require.NoError(t, podRegistry.UpdatePod(ctx, &pod))
+			event = nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchModified, event.Type)
+
+			require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, "test-pod"))
+			event = nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchDeleted, event.Type)
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, "test-pod", pod.Name)
+		})
+	})
+
+	t.Run("should replay changes after the given resourceVersion", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			// Record the resourceVersion of the first pod through a watch
+			first := startWatch(t, server.URL+"/api/v1/pods?watch=true")
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+			resourceVersion := nextWatchEvent(t, first).ResourceVersion
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-2")))
+
+			events := startWatch(t, server.URL+"/api/v1/pods?watch=true&resourceVersion="+resourceVersion)
+			event := nextWatchEvent(t, events)
+			var pod api.Pod
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, "pod-2", pod.Name)
+		})
+	})
+
+	t.Run("should end a watch from a compacted resourceVersion with a 410 error", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			podRegistry := registry.NewPodRegistry(store)
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+			stale, err := store.CurrentRevision(ctx)
+			require.NoError(t, err)
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-2")))
+			_, err = store.Compact(ctx)
+			require.NoError(t, err)
+
+			events := startWatch(t, server.URL+"/api/v1/pods?watch=true&resourceVersion="+strconv.FormatInt(stale-1, 10))
+			event := nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchError, event.Type)
+			var status api.WatchStatus
+			require.NoError(t, json.Unmarshal(event.Object, &status))
+			assert.Equal(t, http.StatusGone, status.Code)
+			_, open := <-events
+			assert.False(t, open, "the watch should be closed after the error")
+		})
+	})
+
+	t.Run("should stream the current pods and a bookmark before live changes", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-2")))
+
+			events := startWatch(t, server.URL+"/api/v1/pods?watch=true&sendInitialEvents=true")
+
+			var names []string
+			var snapshotVersion string
+			for range 2 {
+				event := nextWatchEvent(t, events)
+				assert.Equal(t, api.WatchAdded, event.Type)
+				var pod api.Pod
+				require.NoError(t, json.Unmarshal(event.Object, &pod))
+				names = append(names, pod.Name)
+				snapshotVersion = event.ResourceVersion
+			}
+			assert.Equal(t, []string{"pod-1", "pod-2"}, names)
+
+			bookmark := nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchBookmark, bookmark.Type)
+			assert.Equal(t, snapshotVersion, bookmark.ResourceVersion)
+
+			// A pod created after the snapshot arrives once, as a live change
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-3")))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-4")))
+			for _, name := range []string{"pod-3", "pod-4"} {
+				event := nextWatchEvent(t, events)
+				assert.Equal(t, api.WatchAdded, event.Type)
+				var pod api.Pod
+				require.NoError(t, json.Unmarshal(event.Object, &pod))
+				assert.Equal(t, name, pod.Name)
+
+				live, err := strconv.ParseInt(event.ResourceVersion, 10, 64)
+				require.NoError(t, err)
+				snapshot, err := strconv.ParseInt(snapshotVersion, 10, 64)
+				require.NoError(t, err)
+				assert.Greater(t, live, snapshot)
+			}
+		})
+	})
+
+	t.Run("should close a watch after the maximum duration with a bookmark to resume from", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			ws.Filter(MaxWatchDurationFilter(500 * time.Millisecond))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			started := time.Now()
+			events := startWatch(t, server.URL+"/api/v1/pods?watch=true")
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+			added := nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchAdded, added.Type)
+
+			bookmark := nextWatchEvent(t, events)
+			assert.Equal(t, api.WatchBookmark, bookmark.Type)
+			assert.Equal(t, added.ResourceVersion, bookmark.ResourceVersion)
+			assert.GreaterOrEqual(t, time.Since(started), 500*time.Millisecond)
+			_, open := <-events
+			assert.False(t, open, "the watch should be closed after the bookmark")
+
+			// A change made while reconnecting is streamed by the resumed watch
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-2")))
+			resumed := startWatch(t, server.URL+"/api/v1/pods?watch=true&resourceVersion="+bookmark.ResourceVersion)
+			event := nextWatchEvent(t, resumed)
+			assert.Equal(t, api.WatchAdded, event.Type)
+			var pod api.Pod
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, "pod-2", pod.Name)
+
+			bookmark = nextWatchEvent(t, resumed)
+			assert.Equal(t, api.WatchBookmark, bookmark.Type)
+			assert.Equal(t, event.ResourceVersion, bookmark.ResourceVersion)
+
+			// A watch without changes ends with the revision it started at
+			idle := startWatch(t, server.URL+"/api/v1/pods?watch=true")
+			bookmark = nextWatchEvent(t, idle)
+			assert.Equal(t, api.WatchBookmark, bookmark.Type)
+			assert.Equal(t, event.ResourceVersion, bookmark.ResourceVersion)
+		})
+	})
+
+	t.Run("should only stream the pods of the namespace in the path", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			other := newPod("other")
+			other.Namespace = "other"
+			require.NoError(t, podRegistry.CreatePod(ctx, other))
+
+			events := startWatch(t, server.URL+"/api/v1/namespaces/default/pods?watch=true&sendInitialEvents=true")
+			assert.Equal(t, api.WatchBookmark, nextWatchEvent(t, events).Type)
+
+			other = newPod("other-2")
+			other.Namespace = "other"
+			require.NoError(t, podRegistry.CreatePod(ctx, other))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+
+			event := nextWatchEvent(t, events)
+			var pod api.Pod
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, "pod-1", pod.Name)
+		})
+	})
+
+	t.Run("should only stream the pods matching the field selector", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			server := httptest.NewServer(container)
+			t.Cleanup(server.Close)
+
+			onNode := func(name, nodeName string) *api.Pod {
+				pod := newPod(name)
+				pod.NodeName = nodeName
+				return pod
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, onNode("pod-1", "node-1")))
+			require.NoError(t, podRegistry.CreatePod(ctx, onNode("pod-2", "node-2")))
+
+			events := startWatch(t, server.URL+"/api/v1/pods?watch=true&sendInitialEvents=true&fieldSelector=spec.nodeName=node-1")
+			event := nextWatchEvent(t, events)
+			var pod api.Pod
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, api.WatchAdded, event.Type)
+			assert.Equal(t, "pod-1", pod.Name)
+			assert.Equal(t, api.WatchBookmark, nextWatchEvent(t, events).Type)
+
+			// A pod bound to the node is added, pods on other nodes aren't streamed
+			require.NoError(t, podRegistry.CreatePod(ctx, onNode("pod-3", "node-2")))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-4")))
+			_, err := podRegistry.BindPod(ctx, api.NamespaceDefault, "pod-4", "node-1")
+			require.NoError(t, err)
+			event = nextWatchEvent(t, events)
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, api.WatchAdded, event.Type)
+			assert.Equal(t, "pod-4", pod.Name)
+
+			// A pod moved off the node is deleted
+			moved, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod-1")
+			require.NoError(t, err)
+			moved.NodeName = "node-2"
+			require.NoError(t, podRegistry.UpdatePod(ctx, moved))
+			event = nextWatchEvent(t, events)
+			require.NoError(t, json.Unmarshal(event.Object, &pod))
+			assert.Equal(t, api.WatchDeleted, event.Type)
+			assert.Equal(t, "pod-1", pod.Name)
+			assert.Equal(t, "node-2", pod.NodeName)
+		})
+	})
+
+	t.Run("should reject an invalid field selector", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+			req := httptest.NewRequest("GET", "/api/v1/pods?watch=true&fieldSelector=metadata.uid=1234", nil)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("should reject a resourceVersion with sendInitialEvents", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+			req := httptest.NewRequest("GET", "/api/v1/pods?watch=true&sendInitialEvents=true&resourceVersion=1", nil)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("should return bad request for invalid resourceVersion", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+			req := httptest.NewRequest("GET", "/api/v1/pods?watch=true&resourceVersion=abc", nil)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
+
+// startWatch opens a watch request against url and returns a channel of the decoded events.
+// The request is cancelled when the test finishes, before any server registered earlier with
+// t.Cleanup is closed.
+func startWatch(t *testing.T, url string) <-chan api.WatchEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := make(chan api.WatchEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var event api.WatchEvent
+			if err := decoder.Decode(&event); err != nil {
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// nextWatchEvent waits for the next event of a watch started with startWatch
+func nextWatchEvent(t *testing.T, events <-chan api.WatchEvent) api.WatchEvent {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "watch closed unexpectedly")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch event")
+	}
+	return api.WatchEvent{}
+}
+
 func TestGetPod(t *testing.T) {
 	t.Run("should get existing pod", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
@@ -418,7 +1239,7 @@ func TestUpdatePod(t *testing.T) {
 			err = json.Unmarshal(resp.Body.Bytes(), &returnedPod)
 			assert.NoError(t, err)
 			assert.Equal(t, updatedPod.Spec.Replicas, returnedPod.Spec.Replicas)
-			assert.Equal(t, updatedPod.Spec.Containers[0].Image, returnedPod.Spec.Containers[0].Image)
+			assert.Equal(t, "docker.io/library/nginx:1.19", returnedPod.Spec.Containers[0].Image)
 		})
 	})
 
@@ -526,13 +1347,13 @@ func TestUpdatePod(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterPodRoutes(ws, handler)
 
-			// Mock Get operation for the middleware
+			// Mock Get operation for the middleware and the registry
 			existingPod := &api.Pod{
 				ObjectMeta: api.ObjectMeta{
 					Name: "test-pod",
 				},
 			}
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *existingPod)
+			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *existingPod).Times(2)
 			mockStore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			pod := &api.Pod{
@@ -588,6 +1409,236 @@ func TestUpdatePod(t *testing.T) {
 			assert.Equal(t, http.StatusNotFound, resp.Code)
 		})
 	})
+	t.Run("should only update a pod at the If-Match resource version", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+			stored, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err)
+			staleVersion := stored.ResourceVersion
+
+			update := func(ifMatch string, image string) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(&api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: image}}},
+				})
+				req := httptest.NewRequest("PUT", "/api/v1/pods/test-pod", bytes.NewReader(body))
+				req.Header.Set("Content-Type", restful.MIME_JSON)
+				req.Header.Set("If-Match", ifMatch)
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, req)
+				return resp
+			}
+
+			resp := update(`"`+staleVersion+`"`, "nginx:1.25")
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+			var updated api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+			assert.NotEqual(t, staleVersion, updated.ResourceVersion)
+
+			// A client still holding the old version must not overwrite the update
+			resp = update(staleVersion, "nginx:1.26")
+			assert.Equal(t, http.StatusConflict, resp.Code)
+			stored, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err)
+			assert.Equal(t, "docker.io/library/nginx:1.25", stored.Spec.Containers[0].Image)
+
+			resp = update("not-a-version", "nginx:1.26")
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
+
+func TestUpdatePodStatus(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+		ctx := context.Background()
+
+		putStatus := func(pod *api.Pod) *httptest.ResponseRecorder {
+			body, err := json.Marshal(pod)
+			require.NoError(t, err)
+			req := httptest.NewRequest("PUT", "/api/v1/pods/"+pod.Name+"/status", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+		newPod := func(name string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+		}
+
+		t.Run("should not overwrite the node set by a concurrent binding", func(t *testing.T) {
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("bound")))
+			stale, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "bound")
+			require.NoError(t, err)
+
+			_, err = podRegistry.BindPod(ctx, api.NamespaceDefault, "bound", "node-1")
+			require.NoError(t, err)
+
+			stale.Status.Phase = api.PodRunning
+			resp := putStatus(stale)
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+			stored, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "bound")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodRunning, stored.Status.Phase)
+			assert.Equal(t, "node-1", stored.NodeName)
+			assert.NotNil(t, conditions.GetCondition(stored.Status.Conditions, api.PodConditionScheduled), "conditions of other types are kept")
+		})
+
+		t.Run("should reject spec changes", func(t *testing.T) {
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("spec-change")))
+
+			pod := newPod("spec-change")
+			pod.Spec.Containers[0].Image = "nginx:1.27"
+			pod.Status.Phase = api.PodRunning
+			resp := putStatus(pod)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+			stored, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "spec-change")
+			require.NoError(t, err)
+			assert.Equal(t, "docker.io/library/nginx:latest", stored.Spec.Containers[0].Image)
+			assert.Equal(t, api.PodPending, stored.Status.Phase)
+		})
+
+		t.Run("should return not found for a missing pod", func(t *testing.T) {
+			resp := putStatus(newPod("missing"))
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+}
+
+func TestPatchPod(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web", "tier": "frontend"}},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx"}}},
+		}
+		require.NoError(t, podRegistry.CreatePod(context.Background(), pod))
+
+		patch := func(path, contentType, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("PATCH", path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", contentType)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		t.Run("should merge the patch into the stored pod", func(t *testing.T) {
+			resp := patch("/api/v1/pods/web", MIMEMergePatch, `{"status": {"phase": "Running"}, "metadata": {"labels": {"tier": null, "track": "stable"}}}`)
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+			stored, err := podRegistry.GetPod(context.Background(), api.NamespaceDefault, "web")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodRunning, stored.Status.Phase)
+			assert.Equal(t, map[string]string{"app": "web", "track": "stable"}, stored.Labels)
+			assert.Equal(t, pod.Spec.Containers[0].Name, stored.Spec.Containers[0].Name)
+		})
+
+		t.Run("should patch pods in the namespace of the path", func(t *testing.T) {
+			resp := patch("/api/v1/namespaces/default/pods/web", MIMEMergePatch, `{"status": {"phase": "Succeeded"}}`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, http.StatusNotFound, patch("/api/v1/namespaces/team-a/pods/web", MIMEMergePatch, `{}`).Code)
+		})
+
+		t.Run("should reject an invalid patched spec", func(t *testing.T) {
+			resp := patch("/api/v1/pods/web", MIMEMergePatch, `{"spec": {"containers": null}}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+			resp = patch("/api/v1/pods/web", MIMEMergePatch, `{"spec": {"containers": "nginx"}}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("should reject a patch that is not JSON", func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, patch("/api/v1/pods/web", MIMEMergePatch, `{"status"`).Code)
+		})
+
+		t.Run("should reject renaming the pod", func(t *testing.T) {
+			resp := patch("/api/v1/pods/web", MIMEMergePatch, `{"metadata": {"name": "db"}}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("should reject other content types", func(t *testing.T) {
+			resp := patch("/api/v1/pods/web", restful.MIME_JSON, `{"status": {"phase": "Failed"}}`)
+			assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+		})
+
+		t.Run("should honour If-Match", func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/api/v1/pods/web", bytes.NewBufferString(`{"status": {"phase": "Failed"}}`))
+			req.Header.Set("Content-Type", MIMEMergePatch)
+			req.Header.Set(ifMatchHeader, "1")
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+	})
+}
+
+// afterFirstGetStorage calls afterGet once, after the first Get of the wrapped storage
+type afterFirstGetStorage struct {
+	storage.Storage
+	once     sync.Once
+	afterGet func()
+}
+
+func (s *afterFirstGetStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
+	err := s.Storage.Get(ctx, key, obj)
+	s.once.Do(s.afterGet)
+	return err
+}
+
+func TestPatchPodConflictsWithConcurrentUpdate(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		store := storage.NewEtcdStorage(etcdServer)
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx"}}},
+		}
+		require.NoError(t, registry.NewPodRegistry(store).CreatePod(context.Background(), pod))
+
+		// Another API server replaces the labels once the patched pod has been loaded
+		other := restful.NewContainer()
+		otherWS := new(restful.WebService)
+		otherWS.Path("/api/v1").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+		RegisterPodRoutes(otherWS, NewPodHandler(registry.NewPodRegistry(store)))
+		other.Add(otherWS)
+		racingStore := &afterFirstGetStorage{Storage: store, afterGet: func() {
+			pod.Labels = map[string]string{"app": "web", "track": "canary"}
+			body, err := json.Marshal(pod)
+			require.NoError(t, err)
+			req := httptest.NewRequest("PUT", "/api/v1/pods/web", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			other.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		}}
+		podRegistry := registry.NewPodRegistry(racingStore)
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+		req := httptest.NewRequest("PATCH", "/api/v1/pods/web", bytes.NewBufferString(`{"status": {"phase": "Running"}}`))
+		req.Header.Set("Content-Type", MIMEMergePatch)
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusConflict, resp.Code, resp.Body.String())
+
+		stored, err := podRegistry.GetPod(context.Background(), api.NamespaceDefault, "web")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "web", "track": "canary"}, stored.Labels, "the concurrent update should be kept")
+	})
 }
 
 func TestDeletePod(t *testing.T) {
@@ -628,11 +1679,45 @@ func TestDeletePod(t *testing.T) {
 			assert.Equal(t, http.StatusNoContent, resp.Code)
 
 			// Verify pod is deleted
-			_, err = podRegistry.GetPod(ctx, "test-pod")
+			_, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "test-pod")
 			assert.Error(t, err)
 		})
 	})
 
+	t.Run("should wait for finalizers unless forced", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			for _, name := range []string{"finalized", "forced", "no-grace"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name, Finalizers: []string{"example.com/stuck"}},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				}))
+			}
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/pods/finalized", nil))
+			assert.Equal(t, http.StatusAccepted, resp.Code)
+
+			pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "finalized")
+			require.NoError(t, err, "a pod with a lingering finalizer should be kept")
+			assert.NotNil(t, pod.DeletionTimestamp)
+
+			for _, path := range []string{"/api/v1/pods/finalized?force=true", "/api/v1/pods/forced?force=true", "/api/v1/pods/no-grace?gracePeriodSeconds=0"} {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("DELETE", path, nil))
+				assert.Equal(t, http.StatusNoContent, resp.Code, path)
+			}
+
+			for _, name := range []string{"finalized", "forced", "no-grace"} {
+				_, err := podRegistry.GetPod(ctx, api.NamespaceDefault, name)
+				assert.ErrorIs(t, err, registry.ErrPodNotFound, "force delete should remove %s from storage", name)
+			}
+		})
+	})
+
 	t.Run("should return internal server error for registry failure", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -650,7 +1735,7 @@ func TestDeletePod(t *testing.T) {
 					Name: "test-pod",
 				},
 			}
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *pod)
+			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *pod).Times(2)
 			mockStore.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			req := httptest.NewRequest("DELETE", "/api/v1/pods/test-pod", nil)
@@ -678,6 +1763,65 @@ func TestDeletePod(t *testing.T) {
 			assert.Equal(t, http.StatusNotFound, resp.Code)
 		})
 	})
+
+	t.Run("should return not found for a pod deleted after it was loaded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStore := mockStorage.NewMockStorage(ctrl)
+		handler := NewPodHandler(registry.NewPodRegistry(mockStore))
+
+		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterPodRoutes(ws, handler)
+
+			pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "test-pod"}}
+			gomock.InOrder(
+				mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *pod),
+				mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(storage.ErrNotFound),
+			)
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/pods/test-pod", nil))
+
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("should only delete a pod at the If-Match resource version", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+			stored, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err)
+			staleVersion := stored.ResourceVersion
+
+			stored.Labels = map[string]string{"app": "web"}
+			require.NoError(t, podRegistry.UpdatePod(ctx, stored))
+
+			req := httptest.NewRequest("DELETE", "/api/v1/pods/test-pod", nil)
+			req.Header.Set("If-Match", staleVersion)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusConflict, resp.Code)
+			_, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err, "a pod deleted with a stale version should be kept")
+
+			req = httptest.NewRequest("DELETE", "/api/v1/pods/test-pod", nil)
+			req.Header.Set("If-Match", stored.ResourceVersion)
+			resp = httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+			_, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			assert.ErrorIs(t, err, registry.ErrPodNotFound)
+		})
+	})
 }
 
 func TestListUnassignedPods(t *testing.T) {
@@ -704,7 +1848,7 @@ func TestListUnassignedPods(t *testing.T) {
 						},
 					},
 				},
-				Status: api.PodPending,
+				Status: api.PodStatus{Phase: api.PodPending},
 			}
 
 			// Create assigned pod
@@ -721,7 +1865,7 @@ func TestListUnassignedPods(t *testing.T) {
 						},
 					},
 				},
-				Status: api.PodRunning,
+				Status: api.PodStatus{Phase: api.PodRunning},
 			}
 
 			err := podRegistry.CreatePod(ctx, unassignedPod)
@@ -743,7 +1887,7 @@ func TestListUnassignedPods(t *testing.T) {
 
 			require.Len(t, pods, 1)
 			assert.Equal(t, unassignedPod.Name, pods[0].Name)
-			assert.Equal(t, api.PodPending, pods[0].Status)
+			assert.Equal(t, api.PodPending, pods[0].Status.Phase)
 		})
 	})
 
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// ifMatchHeader carries the resource version the object of a PUT or DELETE request must still
+// be at, so that clients can read, modify and write an object without losing concurrent changes
+const ifMatchHeader = "If-Match"
+
+// parseIfMatch reads the If-Match header of the request. The resource version may be quoted
+// like an ETag. Without the header, or with *, any version matches and 0 is returned.
+func parseIfMatch(request *restful.Request) (int64, error) {
+	value := strings.TrimSpace(request.HeaderParameter(ifMatchHeader))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	resourceVersion, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || resourceVersion <= 0 {
+		return 0, fmt.Errorf("invalid %s resource version %q", ifMatchHeader, value)
+	}
+	return resourceVersion, nil
+}
+
+// checkIfMatch checks the If-Match precondition of the request against the resource version of
+// the loaded object and returns the required version, 0 if there is none. If the precondition
+// is malformed or doesn't hold, the error is written and false is returned. The caller must
+// still make its write conditional on the version, as the object may change after it was loaded.
+func checkIfMatch(request *restful.Request, response *restful.Response, resourceVersion string) (int64, bool) {
+	required, err := parseIfMatch(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	if required != 0 && strconv.FormatInt(required, 10) != resourceVersion {
+		api.WriteError(response, http.StatusConflict,
+			fmt.Errorf("object is at resource version %s, not %d", resourceVersion, required))
+		return 0, false
+	}
+	return required, true
+}
+
+// writeDeleteError writes the error of a delete, which may be conditional on a resource version
+func writeDeleteError(response *restful.Response, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		api.WriteError(response, http.StatusNotFound, err)
+	case errors.Is(err, registry.ErrConflict):
+		api.WriteError(response, http.StatusConflict, err)
+	default:
+		api.WriteError(response, http.StatusInternalServerError, err)
+	}
+}
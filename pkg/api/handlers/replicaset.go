@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 
 	"gokube/pkg/api"
 	"gokube/pkg/registry"
+	"gokube/pkg/storage"
 
 	"github.com/emicklei/go-restful/v3"
 )
@@ -14,13 +16,23 @@ import (
 // ReplicasetHandler handles Replicaset-related HTTP requests
 type ReplicasetHandler struct {
 	replicasetRegistry *registry.ReplicaSetRegistry
+	// podRegistry holds the pods deleted along with their replicaset
+	podRegistry *registry.PodRegistry
 }
 
 // NewReplicasetHandler creates a new ReplicasetHandler
-func NewReplicasetHandler(replicasetRegistry *registry.ReplicaSetRegistry) *ReplicasetHandler {
-	return &ReplicasetHandler{replicasetRegistry: replicasetRegistry}
+func NewReplicasetHandler(replicasetRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry) *ReplicasetHandler {
+	return &ReplicasetHandler{replicasetRegistry: replicasetRegistry, podRegistry: podRegistry}
 }
 
+// Propagation policies of a replicaset deletion, given with ?propagationPolicy=
+const (
+	// propagationBackground deletes the pods controlled by the replicaset after it
+	propagationBackground = "Background"
+	// propagationOrphan keeps the pods, the ReplicaSet controller releases them
+	propagationOrphan = "Orphan"
+)
+
 const replicasetAttributeKey = "replicaset"
 
 // LoadReplicasetIntoRequest retrieves the replicaset and stores it in the request attributes
@@ -29,7 +41,7 @@ func (h *ReplicasetHandler) LoadReplicasetIntoRequest(req *restful.Request, resp
 	replicaset, err := h.replicasetRegistry.Get(req.Request.Context(), name)
 	if err != nil {
 		switch {
-		case errors.Is(err, registry.ErrReplicaSetNotFound):
+		case errors.Is(err, registry.ErrNotFound):
 			api.WriteError(resp, http.StatusNotFound, err)
 		default:
 			api.WriteError(resp, http.StatusInternalServerError, err)
@@ -50,6 +62,8 @@ func (h *ReplicasetHandler) CreateReplicaset(request *restful.Request, response
 
 	if err := h.replicasetRegistry.Create(request.Request.Context(), replicaset); err != nil {
 		switch {
+		case errors.Is(err, registry.ErrReplicaSetInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
 		case errors.Is(err, registry.ErrReplicaSetExists):
 			api.WriteError(response, http.StatusConflict, err)
 		default:
@@ -71,7 +85,8 @@ func (h *ReplicasetHandler) GetReplicaset(request *restful.Request, response *re
 	api.WriteResponse(response, http.StatusOK, replicaset)
 }
 
-// UpdateReplicaset handles PUT requests to update a replicaset
+// UpdateReplicaset handles PUT requests to update a replicaset. With an If-Match header the
+// replicaset is only updated if it is still at that resource version.
 func (h *ReplicasetHandler) UpdateReplicaset(request *restful.Request, response *restful.Response) {
 	existingReplicaset, ok := request.Attribute(replicasetAttributeKey).(*api.ReplicaSet)
 	if !ok {
@@ -90,15 +105,36 @@ func (h *ReplicasetHandler) UpdateReplicaset(request *restful.Request, response
 		return
 	}
 
-	if err := h.replicasetRegistry.Update(request.Request.Context(), replicaset); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+	revision, ok := checkIfMatch(request, response, existingReplicaset.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.replicasetRegistry.UpdateAtRevision(request.Request.Context(), replicaset, revision)
+	} else {
+		err = h.replicasetRegistry.Update(request.Request.Context(), replicaset)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrReplicaSetInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
 	api.WriteResponse(response, http.StatusOK, replicaset)
 }
 
-// DeleteReplicaset handles DELETE requests to remove a replicaset
+// DeleteReplicaset handles DELETE requests to remove a replicaset. With an If-Match header the
+// replicaset is only deleted if it is still at that resource version. The pods whose controller
+// reference points to the replicaset are deleted with it, unless ?propagationPolicy=Orphan
+// keeps them.
 func (h *ReplicasetHandler) DeleteReplicaset(request *restful.Request, response *restful.Response) {
 	replicaset, ok := request.Attribute(replicasetAttributeKey).(*api.ReplicaSet)
 	if !ok {
@@ -106,16 +142,141 @@ func (h *ReplicasetHandler) DeleteReplicaset(request *restful.Request, response
 		return
 	}
 
-	if err := h.replicasetRegistry.Delete(request.Request.Context(), replicaset.Name); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+	policy := request.QueryParameter("propagationPolicy")
+	switch policy {
+	case "":
+		policy = propagationBackground
+	case propagationBackground, propagationOrphan:
+	default:
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("unsupported propagation policy %q, must be %q or %q", policy, propagationBackground, propagationOrphan))
+		return
+	}
+
+	revision, ok := checkIfMatch(request, response, replicaset.ResourceVersion)
+	if !ok {
+		return
+	}
+
+	ctx := request.Request.Context()
+	// The pods are listed while the replicaset exists, as the ReplicaSet controller releases
+	// the pods of a deleted replicaset
+	var ownedPods []*api.Pod
+	if policy == propagationBackground {
+		var err error
+		if ownedPods, err = h.listOwnedPods(ctx, replicaset); err != nil {
+			api.WriteError(response, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	var err error
+	if revision != 0 {
+		err = h.replicasetRegistry.DeleteAtRevision(ctx, replicaset.Name, revision)
+	} else {
+		err = h.replicasetRegistry.Delete(ctx, replicaset.Name)
+	}
+	if err != nil {
+		writeDeleteError(response, err)
 		return
 	}
 
+	if policy == propagationBackground {
+		if err := h.deleteOwnedPods(ctx, replicaset, ownedPods); err != nil {
+			api.WriteError(response, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	api.WriteResponse(response, http.StatusNoContent, nil)
 }
 
-// ListReplicasets handles GET requests to list all replicasets
+// deleteOwnedPods deletes the pods listed before the replicaset was deleted, along with those
+// still controlled by it, such as pods created by a reconcile that raced with the deletion.
+// Pods with finalizers are marked for deletion.
+func (h *ReplicasetHandler) deleteOwnedPods(ctx context.Context, replicaset *api.ReplicaSet, listed []*api.Pod) error {
+	remaining, err := h.listOwnedPods(ctx, replicaset)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[string]bool)
+	for _, pod := range append(listed, remaining...) {
+		key := pod.Namespace + "/" + pod.Name
+		if deleted[key] {
+			continue
+		}
+		deleted[key] = true
+
+		_, err := h.podRegistry.MarkPodForDeletion(ctx, pod.Namespace, pod.Name)
+		if err != nil && !errors.Is(err, registry.ErrPodNotFound) {
+			return fmt.Errorf("failed to delete pod %s of replicaset %s: %w", pod.Name, replicaset.Name, err)
+		}
+	}
+	return nil
+}
+
+// listOwnedPods returns the pods of the replicaset. Those of a replicaset with a UID are looked
+// up by owner. A replicaset stored without a UID falls back to the pods whose controller
+// reference names it, and to the pods without a controller matching its selector, or its
+// template labels when it has no selector.
+func (h *ReplicasetHandler) listOwnedPods(ctx context.Context, replicaset *api.ReplicaSet) ([]*api.Pod, error) {
+	if replicaset.UID != "" {
+		return h.podRegistry.ListPodsByOwner(ctx, replicaset.UID)
+	}
+
+	pods, err := h.podRegistry.ListPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selector := replicaset.Spec.Selector
+	if len(selector) == 0 {
+		selector = replicaset.Spec.Template.Labels
+	}
+	var owned []*api.Pod
+	for _, pod := range pods {
+		if api.GetControllerOf(&pod.ObjectMeta) != nil {
+			if api.IsOwnedBy(pod, &replicaset.ObjectMeta) {
+				owned = append(owned, pod)
+			}
+			continue
+		}
+		if len(selector) > 0 && api.MatchesSelector(selector, pod.Labels) {
+			owned = append(owned, pod)
+		}
+	}
+	return owned, nil
+}
+
+// ListReplicasets handles GET requests to list all replicasets. With ?watch=true it streams
+// changes to replicasets instead, preceded by the current replicasets with ?sendInitialEvents=true.
+// With ?status=drifted it reports the replicasets that are not at their desired replica count.
 func (h *ReplicasetHandler) ListReplicasets(request *restful.Request, response *restful.Response) {
+	if isWatchRequest(request) {
+		h.WatchReplicasets(request, response)
+		return
+	}
+
+	switch status := request.QueryParameter("status"); status {
+	case "":
+	case replicaSetStatusDrifted:
+		h.ListDriftedReplicasets(request, response)
+		return
+	default:
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("unsupported status filter %q, only %q is supported", status, replicaSetStatusDrifted))
+		return
+	}
+
+	page, paged, err := parseListPage(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if paged {
+		replicasets, next, err := h.replicasetRegistry.ListPaged(request.Request.Context(), page.limit, page.continueToken)
+		writeListPage(response, replicasets, next, err)
+		return
+	}
+
 	replicasets, err := h.replicasetRegistry.List(request.Request.Context())
 	if err != nil {
 		api.WriteError(response, http.StatusInternalServerError, err)
@@ -125,11 +286,49 @@ func (h *ReplicasetHandler) ListReplicasets(request *restful.Request, response *
 	api.WriteResponse(response, http.StatusOK, replicasets)
 }
 
+// replicaSetStatusDrifted selects the replicasets whose observed replica count differs from the desired one
+const replicaSetStatusDrifted = "drifted"
+
+// ListDriftedReplicasets reports the replicasets whose stored status differs from their desired
+// replica count, with the number of replicas missing
+func (h *ReplicasetHandler) ListDriftedReplicasets(request *restful.Request, response *restful.Response) {
+	replicasets, err := h.replicasetRegistry.List(request.Request.Context())
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	drifted := make([]api.ReplicaSetDrift, 0)
+	for _, replicaset := range replicasets {
+		if drift, ok := replicaset.Drift(); ok {
+			drifted = append(drifted, drift)
+		}
+	}
+
+	api.WriteResponse(response, http.StatusOK, drifted)
+}
+
+// WatchReplicasets streams changes to replicasets, starting after the optional resourceVersion query parameter
+func (h *ReplicasetHandler) WatchReplicasets(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	watch := func(resourceVersion int64) (<-chan storage.WatchEvent, error) {
+		return h.replicasetRegistry.Watch(ctx, resourceVersion)
+	}
+
+	if isWatchListRequest(request) {
+		serveWatchList(request, response, func() ([]*api.ReplicaSet, int64, error) {
+			return h.replicasetRegistry.ListWithRevision(ctx)
+		}, watch)
+		return
+	}
+	serveWatch(request, response, h.replicasetRegistry.CurrentRevision, watch)
+}
+
 // RegisterReplicasetRoutes registers replicaset routes with the WebService
 func RegisterReplicasetRoutes(ws *restful.WebService, handler *ReplicasetHandler) {
-	ws.Route(ws.POST("/replicasets").To(handler.CreateReplicaset))
+	ws.Route(ws.POST("/replicasets").Consumes(restful.MIME_JSON, MIMEYAML).To(handler.CreateReplicaset))
 	ws.Route(ws.GET("/replicasets").To(handler.ListReplicasets))
 	ws.Route(ws.GET("/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.GetReplicaset))
-	ws.Route(ws.PUT("/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.UpdateReplicaset))
+	ws.Route(ws.PUT("/replicasets/{name}").Consumes(restful.MIME_JSON, MIMEYAML).Filter(handler.LoadReplicasetIntoRequest).To(handler.UpdateReplicaset))
 	ws.Route(ws.DELETE("/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.DeleteReplicaset))
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -26,7 +27,7 @@ func TestCreateReplicaset(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
 			replicasetRegistry := registry.NewReplicaSetRegistry(store)
-			handler := NewReplicasetHandler(replicasetRegistry)
+			handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
 
 			RegisterReplicasetRoutes(ws, handler)
 
@@ -83,7 +84,7 @@ func TestCreateReplicaset(t *testing.T) {
 
 		mockStore := mockStorage.NewMockStorage(ctrl)
 		nodeRegistry := registry.NewReplicaSetRegistry(mockStore)
-		handler := NewReplicasetHandler(nodeRegistry)
+		handler := NewReplicasetHandler(nodeRegistry, registry.NewPodRegistry(mockStore))
 
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterReplicasetRoutes(ws, handler)
@@ -131,7 +132,7 @@ func TestCreateReplicaset(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
 			replicasetRegistry := registry.NewReplicaSetRegistry(store)
-			handler := NewReplicasetHandler(replicasetRegistry)
+			handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
 			ctx := context.Background()
 
 			RegisterReplicasetRoutes(ws, handler)
@@ -176,6 +177,52 @@ func TestCreateReplicaset(t *testing.T) {
 			assert.Equal(t, http.StatusConflict, resp.Code)
 		})
 	})
+
+	t.Run("should return bad request for invalid templates", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			containers []api.Container
+		}{
+			{name: "empty template", containers: nil},
+			{name: "empty image", containers: []api.Container{{Name: "nginx"}}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+					store := storage.NewEtcdStorage(etcdServer)
+					replicasetRegistry := registry.NewReplicaSetRegistry(store)
+					handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
+
+					RegisterReplicasetRoutes(ws, handler)
+
+					replicaset := &api.ReplicaSet{
+						ObjectMeta: api.ObjectMeta{
+							Name: "nginx-rs",
+						},
+						Spec: api.ReplicaSetSpec{
+							Replicas: 2,
+							Template: api.PodTemplateSpec{
+								Spec: api.PodSpec{Containers: tc.containers},
+							},
+						},
+					}
+
+					body, _ := json.Marshal(replicaset)
+					req := httptest.NewRequest("POST", "/api/v1/replicasets", bytes.NewReader(body))
+					req.Header.Set("Content-Type", restful.MIME_JSON)
+					resp := httptest.NewRecorder()
+
+					container.ServeHTTP(resp, req)
+
+					assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+					_, err := replicasetRegistry.Get(context.Background(), replicaset.Name)
+					assert.ErrorIs(t, err, registry.ErrReplicaSetNotFound)
+				})
+			})
+		}
+	})
 }
 
 func TestGetReplicaset(t *testing.T) {
@@ -183,7 +230,7 @@ func TestGetReplicaset(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
 			replicasetRegistry := registry.NewReplicaSetRegistry(store)
-			handler := NewReplicasetHandler(replicasetRegistry)
+			handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
 			ctx := context.Background()
 
 			RegisterReplicasetRoutes(ws, handler)
@@ -236,7 +283,7 @@ func TestGetReplicaset(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
 			replicasetRegistry := registry.NewReplicaSetRegistry(store)
-			handler := NewReplicasetHandler(replicasetRegistry)
+			handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
 
 			RegisterReplicasetRoutes(ws, handler)
 
@@ -250,13 +297,13 @@ func TestGetReplicaset(t *testing.T) {
 		})
 	})
 
-	t.Run("should return not found for registry failure", func(t *testing.T) {
+	t.Run("should return internal server error for registry failure", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockStore := mockStorage.NewMockStorage(ctrl)
 		replicasetRegistry := registry.NewReplicaSetRegistry(mockStore)
-		handler := NewReplicasetHandler(replicasetRegistry)
+		handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(mockStore))
 
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterReplicasetRoutes(ws, handler)
@@ -269,7 +316,7 @@ func TestGetReplicaset(t *testing.T) {
 
 			container.ServeHTTP(resp, req)
 
-			assert.Equal(t, http.StatusNotFound, resp.Code)
+			assert.Equal(t, http.StatusInternalServerError, resp.Code)
 		})
 	})
 }
@@ -279,7 +326,7 @@ func TestUpdateReplicaset(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
 			replicasetRegistry := registry.NewReplicaSetRegistry(store)
-			handler := NewReplicasetHandler(replicasetRegistry)
+			handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
 			ctx := context.Background()
 
 			RegisterReplicasetRoutes(ws, handler)
@@ -359,7 +406,7 @@ func TestUpdateReplicaset(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
 			replicasetRegistry := registry.NewReplicaSetRegistry(store)
-			handler := NewReplicasetHandler(replicasetRegistry)
+			handler := NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store))
 			ctx := context.Background()
 
 			RegisterReplicasetRoutes(ws, handler)
@@ -430,3 +477,192 @@ func TestUpdateReplicaset(t *testing.T) {
 	})
 
 }
+
+func TestDeleteReplicaset(t *testing.T) {
+	newReplicaSet := func(name string) *api.ReplicaSet {
+		return &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: name},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Selector: map[string]string{"app": name},
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": name}},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				},
+			},
+		}
+	}
+
+	// setup creates the ReplicaSets rs-a and rs-abc, whose name starts with rs-a, with two pods each
+	setup := func(t *testing.T, store storage.Storage) (*registry.PodRegistry, *ReplicasetHandler) {
+		replicasetRegistry := registry.NewReplicaSetRegistry(store)
+		podRegistry := registry.NewPodRegistry(store)
+		ctx := context.Background()
+		for _, name := range []string{"rs-a", "rs-abc"} {
+			rs := newReplicaSet(name)
+			require.NoError(t, replicasetRegistry.Create(ctx, rs))
+			for i := 0; i < 2; i++ {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{
+						Name:            fmt.Sprintf("%s-%d", name, i),
+						Labels:          map[string]string{"app": name},
+						OwnerReferences: []api.OwnerReference{api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet)},
+					},
+					Spec: rs.Spec.Template.Spec,
+				}))
+			}
+		}
+		return podRegistry, NewReplicasetHandler(replicasetRegistry, podRegistry)
+	}
+
+	podNames := func(t *testing.T, podRegistry *registry.PodRegistry) []string {
+		pods, err := podRegistry.ListPods(context.Background())
+		require.NoError(t, err)
+		var names []string
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	t.Run("should delete the pods controlled by the replicaset only", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry, handler := setup(t, storage.NewEtcdStorage(etcdServer))
+			RegisterReplicasetRoutes(ws, handler)
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/replicasets/rs-a", nil))
+			require.Equal(t, http.StatusNoContent, resp.Code)
+
+			assert.ElementsMatch(t, []string{"rs-abc-0", "rs-abc-1"}, podNames(t, podRegistry))
+		})
+	})
+
+	t.Run("should delete the pods of a replicaset stored without a UID", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			podRegistry := registry.NewPodRegistry(store)
+			handler := NewReplicasetHandler(registry.NewReplicaSetRegistry(store), podRegistry)
+			RegisterReplicasetRoutes(ws, handler)
+			ctx := context.Background()
+
+			// Replicasets stored before UIDs were assigned have none
+			legacy := newReplicaSet("legacy")
+			require.NoError(t, store.Create(ctx, "/replicasets/legacy", legacy))
+			other := newReplicaSet("other")
+			other.UID = "other-uid"
+			for name, meta := range map[string]api.ObjectMeta{
+				"legacy-0":   {Labels: map[string]string{"app": "legacy"}, OwnerReferences: []api.OwnerReference{api.NewControllerRef(&legacy.ObjectMeta, api.KindReplicaSet)}},
+				"legacy-1":   {Labels: map[string]string{"app": "legacy"}},
+				"adopted":    {Labels: map[string]string{"app": "legacy"}, OwnerReferences: []api.OwnerReference{api.NewControllerRef(&other.ObjectMeta, api.KindReplicaSet)}},
+				"unselected": {Labels: map[string]string{"app": "other"}},
+			} {
+				meta.Name = name
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{ObjectMeta: meta, Spec: legacy.Spec.Template.Spec}))
+			}
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/replicasets/legacy", nil))
+			require.Equal(t, http.StatusNoContent, resp.Code)
+
+			assert.ElementsMatch(t, []string{"adopted", "unselected"}, podNames(t, podRegistry))
+		})
+	})
+
+	t.Run("should keep the pods with the orphan propagation policy", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry, handler := setup(t, storage.NewEtcdStorage(etcdServer))
+			RegisterReplicasetRoutes(ws, handler)
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/replicasets/rs-a?propagationPolicy=Orphan", nil))
+			require.Equal(t, http.StatusNoContent, resp.Code)
+
+			assert.ElementsMatch(t, []string{"rs-a-0", "rs-a-1", "rs-abc-0", "rs-abc-1"}, podNames(t, podRegistry))
+		})
+	})
+
+	t.Run("should reject unsupported propagation policies", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry, handler := setup(t, storage.NewEtcdStorage(etcdServer))
+			RegisterReplicasetRoutes(ws, handler)
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/replicasets/rs-a?propagationPolicy=Foreground", nil))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Len(t, podNames(t, podRegistry), 4)
+		})
+	})
+}
+
+func TestListDriftedReplicasets(t *testing.T) {
+	newReplicaset := func(name string, desired, current int32) *api.ReplicaSet {
+		return &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: name},
+			Spec: api.ReplicaSetSpec{
+				Replicas: desired,
+				Selector: map[string]string{"app": name},
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": name}},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				},
+			},
+			Status: api.ReplicaSetStatus{Replicas: current},
+		}
+	}
+
+	t.Run("should report the replicasets not at their desired replica count", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			replicasetRegistry := registry.NewReplicaSetRegistry(store)
+			RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store)))
+			ctx := context.Background()
+
+			// No controller runs, so the new replicaset stays under-replicated
+			require.NoError(t, replicasetRegistry.Create(ctx, newReplicaset("under", 3, 0)))
+			require.NoError(t, replicasetRegistry.Create(ctx, newReplicaset("converged", 2, 2)))
+			require.NoError(t, replicasetRegistry.Create(ctx, newReplicaset("over", 1, 2)))
+
+			req := httptest.NewRequest("GET", "/api/v1/replicasets?status=drifted", nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			require.Equal(t, http.StatusOK, resp.Code)
+			var drifted []api.ReplicaSetDrift
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &drifted))
+			assert.ElementsMatch(t, []api.ReplicaSetDrift{
+				{Name: "under", DesiredReplicas: 3, CurrentReplicas: 0, Delta: 3},
+				{Name: "over", DesiredReplicas: 1, CurrentReplicas: 2, Delta: -1},
+			}, drifted)
+		})
+	})
+
+	t.Run("should return an empty report when all replicasets converged", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			replicasetRegistry := registry.NewReplicaSetRegistry(store)
+			RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry, registry.NewPodRegistry(store)))
+			require.NoError(t, replicasetRegistry.Create(context.Background(), newReplicaset("converged", 2, 2)))
+
+			req := httptest.NewRequest("GET", "/api/v1/replicasets?status=drifted", nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.JSONEq(t, "[]", resp.Body.String())
+		})
+	})
+
+	t.Run("should reject unsupported status filters", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			RegisterReplicasetRoutes(ws, NewReplicasetHandler(registry.NewReplicaSetRegistry(store), registry.NewPodRegistry(store)))
+
+			req := httptest.NewRequest("GET", "/api/v1/replicasets?status=ready", nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
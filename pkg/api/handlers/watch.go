@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// isWatchRequest reports whether a list request asks to watch for changes instead,
+// following the Kubernetes convention of GET /<resource>?watch=true
+func isWatchRequest(request *restful.Request) bool {
+	watch, err := strconv.ParseBool(request.QueryParameter("watch"))
+	return err == nil && watch
+}
+
+// isWatchListRequest reports whether a watch request asks for the current list of objects
+// before the changes, with ?watch=true&sendInitialEvents=true
+func isWatchListRequest(request *restful.Request) bool {
+	send, err := strconv.ParseBool(request.QueryParameter("sendInitialEvents"))
+	return err == nil && send
+}
+
+// parseResourceVersion reads the resourceVersion query parameter of a watch request.
+// An absent resourceVersion means watching from now on.
+func parseResourceVersion(request *restful.Request) (int64, error) {
+	value := request.QueryParameter("resourceVersion")
+	if value == "" {
+		return 0, nil
+	}
+
+	resourceVersion, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || resourceVersion < 0 {
+		return 0, fmt.Errorf("invalid resourceVersion %q", value)
+	}
+	return resourceVersion, nil
+}
+
+// serveWatch parses the watch parameters, starts the watch and streams its events
+// until the client goes away or the watch ends. A watch without a resourceVersion starts at
+// the current revision, so that a watch closed before any event can be resumed from it.
+func serveWatch(
+	request *restful.Request,
+	response *restful.Response,
+	currentRevision func(ctx context.Context) (int64, error),
+	watch func(resourceVersion int64) (<-chan storage.WatchEvent, error),
+) {
+	resourceVersion, err := parseResourceVersion(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if resourceVersion == 0 {
+		resourceVersion, err = currentRevision(request.Request.Context())
+		if err != nil {
+			api.WriteError(response, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	events, err := watch(resourceVersion)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	streamWatchEvents(request, response, nil, resourceVersion, events)
+}
+
+// maxWatchDurationAttributeKey is the request attribute MaxWatchDurationFilter sets
+const maxWatchDurationAttributeKey = "maxWatchDuration"
+
+// MaxWatchDurationFilter limits the watches served by the routes it filters to maxDuration.
+// A watch that reaches it is closed after a BOOKMARK event carrying the revision it got to,
+// the client reconnects with that resourceVersion to continue without missing a change.
+// This keeps watches from piling up on a server and from going stale.
+func MaxWatchDurationFilter(maxDuration time.Duration) restful.FilterFunction {
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		request.SetAttribute(maxWatchDurationAttributeKey, maxDuration)
+		chain.ProcessFilter(request, response)
+	}
+}
+
+// maxWatchDurationOf returns the maximum duration of the watch request, 0 if it is unlimited
+func maxWatchDurationOf(request *restful.Request) time.Duration {
+	maxDuration, _ := request.Attribute(maxWatchDurationAttributeKey).(time.Duration)
+	return maxDuration
+}
+
+// serveWatchList streams the objects listed at a revision as ADDED events carrying that
+// revision, then a BOOKMARK event, then the changes made after the revision, all on one
+// connection. Since the watch starts right after the listed revision, no change is missed
+// or sent twice.
+func serveWatchList[T any](
+	request *restful.Request,
+	response *restful.Response,
+	list func() ([]T, int64, error),
+	watch func(resourceVersion int64) (<-chan storage.WatchEvent, error),
+) {
+	if request.QueryParameter("resourceVersion") != "" {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("resourceVersion can't be combined with sendInitialEvents"))
+		return
+	}
+
+	objects, revision, err := list()
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	resourceVersion := strconv.FormatInt(revision, 10)
+	initial := make([]api.WatchEvent, 0, len(objects)+1)
+	for _, object := range objects {
+		data, err := json.Marshal(object)
+		if err != nil {
+			api.WriteError(response, http.StatusInternalServerError, err)
+			return
+		}
+		initial = append(initial, api.WatchEvent{Type: api.WatchAdded, Object: data, ResourceVersion: resourceVersion})
+	}
+	initial = append(initial, api.WatchEvent{Type: api.WatchBookmark, ResourceVersion: resourceVersion})
+
+	events, err := watch(revision)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	streamWatchEvents(request, response, initial, revision, events)
+}
+
+// streamWatchEvents writes the initial events and then each watch event as a line of JSON,
+// flushing after every watch event. The watch starts after revision. Once the maximum watch
+// duration is reached, a BOOKMARK event with the revision of the last event is written and the
+// watch is closed. A watch that fails is closed after an ERROR event.
+func streamWatchEvents(request *restful.Request, response *restful.Response, initial []api.WatchEvent, revision int64, events <-chan storage.WatchEvent) {
+	response.Header().Set("Content-Type", restful.MIME_JSON)
+	response.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(response)
+	for _, event := range initial {
+		if err := encoder.Encode(event); err != nil {
+			log.Printf("Error writing watch event: %v", err)
+			return
+		}
+	}
+	response.Flush()
+
+	var expired <-chan time.Time
+	if maxDuration := maxWatchDurationOf(request); maxDuration > 0 {
+		timer := time.NewTimer(maxDuration)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-request.Request.Context().Done():
+			return
+		case <-expired:
+			bookmark := api.WatchEvent{Type: api.WatchBookmark, ResourceVersion: strconv.FormatInt(revision, 10)}
+			if err := encoder.Encode(bookmark); err != nil {
+				log.Printf("Error writing watch event: %v", err)
+			}
+			response.Flush()
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if err := encoder.Encode(toAPIWatchEvent(event)); err != nil {
+				log.Printf("Error writing watch event: %v", err)
+				return
+			}
+			response.Flush()
+			if event.Type == storage.EventError {
+				return
+			}
+			revision = event.Revision
+		}
+	}
+}
+
+// toAPIWatchEvent converts a storage event to the event streamed to clients
+func toAPIWatchEvent(event storage.WatchEvent) api.WatchEvent {
+	watchEvent := api.WatchEvent{
+		Object:          event.Value,
+		ResourceVersion: strconv.FormatInt(event.Revision, 10),
+	}
+
+	switch event.Type {
+	case storage.EventAdd:
+		watchEvent.Type = api.WatchAdded
+	case storage.EventUpdate:
+		watchEvent.Type = api.WatchModified
+	case storage.EventDelete:
+		watchEvent.Type = api.WatchDeleted
+		watchEvent.Object = event.OldValue
+	case storage.EventError:
+		return watchErrorEvent(event.Err)
+	}
+
+	return watchEvent
+}
+
+// watchErrorEvent returns the ERROR event ending a watch that failed with err. A compacted
+// revision is reported as 410 Gone, for the client to list again.
+func watchErrorEvent(err error) api.WatchEvent {
+	status := api.WatchStatus{Code: http.StatusInternalServerError, Message: err.Error()}
+	if errors.Is(err, storage.ErrCompacted) {
+		status.Code = http.StatusGone
+	}
+
+	data, marshalErr := json.Marshal(status)
+	if marshalErr != nil {
+		log.Printf("Error encoding watch status: %v", marshalErr)
+	}
+	return api.WatchEvent{Type: api.WatchError, Object: data}
+}
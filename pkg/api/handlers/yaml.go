@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/emicklei/go-restful/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// MIMEYAML is the content type of resources written in YAML
+const MIMEYAML = "application/yaml"
+
+// yamlEntityAccessor reads YAML entities by converting them to JSON, so that they are decoded
+// with the json tags of the api types exactly like JSON entities
+type yamlEntityAccessor struct{}
+
+func init() {
+	restful.RegisterEntityAccessor(MIMEYAML, yamlEntityAccessor{})
+}
+
+// Read converts the YAML body of the request to JSON and decodes it into v
+func (yamlEntityAccessor) Read(request *restful.Request, v interface{}) error {
+	body, err := io.ReadAll(request.Request.Body)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return fmt.Errorf("invalid YAML: %v", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Write encodes v as YAML
+func (yamlEntityAccessor) Write(response *restful.Response, status int, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	response.Header().Set(restful.HEADER_ContentType, MIMEYAML)
+	response.WriteHeader(status)
+	_, err = response.Write(data)
+	return err
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/distribution/reference"
+)
+
+// ErrInvalidImage is returned for an image that isn't a valid image reference
+var ErrInvalidImage = errors.New("invalid image reference")
+
+// NormalizeImage returns the fully qualified form of an image reference, with the default
+// registry and the latest tag filled in when they are omitted, e.g. nginx becomes
+// docker.io/library/nginx:latest. A reference with a digest is left without a tag.
+func NormalizeImage(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidImage, image, err)
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// ImageRegistry returns the host of the registry an image reference is pulled from, e.g.
+// docker.io for nginx and registry.example.com for registry.example.com/team/app:v1
+func ImageRegistry(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidImage, image, err)
+	}
+	return reference.Domain(named), nil
+}
+
+// NormalizeImages normalizes the image of every container in the spec
+func (s *PodSpec) NormalizeImages() error {
+	for i := range s.Containers {
+		image, err := NormalizeImage(s.Containers[i].Image)
+		if err != nil {
+			return err
+		}
+		s.Containers[i].Image = image
+	}
+	return nil
+}
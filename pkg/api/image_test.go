@@ -0,0 +1,79 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		expected string
+	}{
+		{
+			name:     "bare name gets the default registry and latest tag",
+			image:    "nginx",
+			expected: "docker.io/library/nginx:latest",
+		},
+		{
+			name:     "tagged reference keeps its tag",
+			image:    "nginx:1.27",
+			expected: "docker.io/library/nginx:1.27",
+		},
+		{
+			name:     "reference with a registry is kept",
+			image:    "ghcr.io/example/app:v1",
+			expected: "ghcr.io/example/app:v1",
+		},
+		{
+			name:     "digest reference gets no tag",
+			image:    "busybox@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			expected: "docker.io/library/busybox@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, err := NormalizeImage(tt.image)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, image)
+		})
+	}
+
+	t.Run("invalid reference is rejected", func(t *testing.T) {
+		for _, image := range []string{"Nginx", "nginx:", "nginx::latest", "nginx:la test"} {
+			_, err := NormalizeImage(image)
+			assert.ErrorIs(t, err, ErrInvalidImage, image)
+		}
+	})
+}
+
+func TestImageRegistry(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                            "docker.io",
+		"docker.io/library/nginx:latest":   "docker.io",
+		"registry.example.com/team/app:v1": "registry.example.com",
+		"localhost:5000/app":               "localhost:5000",
+	} {
+		host, err := ImageRegistry(image)
+		require.NoError(t, err)
+		assert.Equal(t, expected, host, image)
+	}
+
+	_, err := ImageRegistry("Nginx")
+	assert.ErrorIs(t, err, ErrInvalidImage)
+}
+
+func TestPodValidation_InvalidImage(t *testing.T) {
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: "pod"},
+		Spec:       PodSpec{Containers: []Container{{Name: "app", Image: "nginx::latest"}}},
+	}
+
+	err := pod.Validate()
+	assert.ErrorIs(t, err, ErrInvalidPodSpec)
+	assert.ErrorContains(t, err, "invalid image reference")
+}
@@ -5,8 +5,56 @@ import "github.com/go-playground/validator/v10"
 // Node is a simplified representation of a Kubernetes Node
 type Node struct {
 	ObjectMeta `json:"metadata,omitempty"`
-	Spec       NodeSpec   `json:"spec,omitempty"`
-	Status     NodeStatus `json:"status,omitempty"`
+	Spec       NodeSpec    `json:"spec,omitempty"`
+	Status     NodeStatus  `json:"status,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	// Capacity is the total amount of each resource on the node
+	Capacity ResourceList `json:"capacity,omitempty"`
+	// Allocatable is the amount of each resource that is still free for new pods
+	Allocatable ResourceList `json:"allocatable,omitempty"`
+}
+
+// ResourceName is the name of a resource a node provides
+type ResourceName string
+
+const (
+	// ResourceCPU is CPU, measured in millicores
+	ResourceCPU ResourceName = "cpu"
+	// ResourceMemory is memory, measured in bytes
+	ResourceMemory ResourceName = "memory"
+)
+
+// ResourceList is a set of resource quantities by resource name
+type ResourceList map[ResourceName]int64
+
+// Add adds the quantities of other to the list
+func (l ResourceList) Add(other ResourceList) {
+	for name, quantity := range other {
+		l[name] += quantity
+	}
+}
+
+// Fits reports whether request fits on a node with this capacity once requested is already
+// in use. Resources the capacity doesn't list aren't limited, so a node that hasn't reported
+// its capacity fits every request.
+func (l ResourceList) Fits(requested, request ResourceList) bool {
+	for name, quantity := range request {
+		if capacity, ok := l[name]; ok && quantity > 0 && requested[name]+quantity > capacity {
+			return false
+		}
+	}
+	return true
+}
+
+// IsReady reports whether the node can run pods. The Ready condition, when reported,
+// takes precedence over the node status.
+func (n *Node) IsReady() bool {
+	for _, condition := range n.Conditions {
+		if condition.Type == NodeConditionReady {
+			return condition.Status == ConditionTrue
+		}
+	}
+	return n.Status == NodeReady
 }
 
 // Validate checks if the Node configuration is valid
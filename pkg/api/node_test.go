@@ -90,3 +90,26 @@ func TestNodeValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeIsReady(t *testing.T) {
+	assert.True(t, (&Node{Status: NodeReady}).IsReady())
+	assert.False(t, (&Node{Status: NodeNotReady}).IsReady())
+	assert.False(t, (&Node{}).IsReady())
+
+	// The Ready condition takes precedence over the status
+	assert.False(t, (&Node{Status: NodeReady, Conditions: []Condition{{Type: NodeConditionReady, Status: ConditionFalse}}}).IsReady())
+	assert.True(t, (&Node{Status: NodeNotReady, Conditions: []Condition{{Type: NodeConditionReady, Status: ConditionTrue}}}).IsReady())
+}
+
+func TestResourceListFits(t *testing.T) {
+	capacity := ResourceList{ResourceCPU: 2000, ResourceMemory: 1024}
+
+	assert.True(t, capacity.Fits(ResourceList{}, ResourceList{ResourceCPU: 2000}))
+	assert.True(t, capacity.Fits(ResourceList{ResourceCPU: 1000}, ResourceList{ResourceCPU: 1000, ResourceMemory: 1024}))
+	assert.False(t, capacity.Fits(ResourceList{ResourceCPU: 1000}, ResourceList{ResourceCPU: 1001}))
+	assert.False(t, capacity.Fits(nil, ResourceList{ResourceMemory: 2048}))
+
+	// Resources without a reported capacity aren't limited
+	assert.True(t, ResourceList(nil).Fits(nil, ResourceList{ResourceCPU: 64000}))
+	assert.True(t, ResourceList{ResourceCPU: 2000}.Fits(nil, ResourceList{ResourceMemory: 1 << 40}))
+}
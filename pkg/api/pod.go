@@ -3,18 +3,42 @@ package api
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"regexp"
 
 	"github.com/go-playground/validator/v10"
 )
 
 var (
 	ErrInvalidPodSpec = errors.New("invalid pod spec")
+	// ErrInvalidContainerName is returned for a container name that isn't a DNS-1123 label or
+	// that is shared by another container of the pod. It wraps ErrInvalidPodSpec.
+	ErrInvalidContainerName = fmt.Errorf("%w: invalid container name", ErrInvalidPodSpec)
 )
 
+// maxContainerNameLength is the maximum length of a DNS-1123 label
+const maxContainerNameLength = 63
+
+var dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Container is a container of a pod, run from an image
+type Container struct {
+	Name  string `json:"name" validate:"required"`
+	Image string `json:"image" validate:"required"`
+	// Resources are the resources the container needs
+	Resources ResourceRequirements `json:"resources,omitempty"`
+}
+
 type PodSpec struct {
 	Containers []Container `json:"containers" validate:"required,dive,required"`
 	Replicas   int32       `json:"replicas" validate:"gte=0"`
+	// NodeSelector restricts the pod to nodes whose labels contain all of these key/value pairs
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Priority ranks the pod against the other pods of its node. When the node is full, the
+	// kubelet evicts pods of lower priority to admit it.
+	Priority int32 `json:"priority,omitempty"`
+	// ImagePullSecrets name the registry credentials, configured on the kubelets, that the
+	// images of the pod are pulled with. Pods whose images are in private registries need them.
+	ImagePullSecrets []LocalObjectReference `json:"imagePullSecrets,omitempty" validate:"dive"`
 }
 
 type Pod struct {
@@ -25,6 +49,49 @@ type Pod struct {
 	// Add other fields as needed
 }
 
+// PodPhase is a summary of where a pod is in its lifecycle. Finer grained observations, such
+// as whether the pod was scheduled or is ready, are reported by its conditions.
+type PodPhase string
+
+const (
+	// PodPending means the pod has been accepted by the system, but one or more of the containers
+	// has not been started. This includes time before being bound to a node, as well as time spent
+	// pulling images onto the host.
+	PodPending PodPhase = "Pending"
+
+	// PodRunning means the pod has been bound to a node and all of the containers have been started.
+	// At least one container is still running or is in the process of being restarted.
+	PodRunning PodPhase = "Running"
+
+	// PodSucceeded means that all containers in the pod have voluntarily terminated
+	// with a container exit code of 0, and the system is not going to restart any of these containers.
+	PodSucceeded PodPhase = "Succeeded"
+
+	// PodFailed means that all containers in the pod have terminated, and at least one container has
+	// terminated in a failure (exited with a non-zero exit code or was stopped by the system).
+	PodFailed PodPhase = "Failed"
+)
+
+// PodStatus is the observed state of a pod
+type PodStatus struct {
+	Phase PodPhase `json:"phase,omitempty"`
+	// Conditions are set by the components observing the pod: PodScheduled by the scheduler,
+	// Admitted, ContainersReady and Ready by the kubelet of its node
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// PodConditionScheduled represents the status of the scheduling process for the pod
+	PodConditionScheduled ConditionType = "PodScheduled"
+	// PodConditionReady means the pod is able to serve traffic
+	PodConditionReady ConditionType = "Ready"
+	// PodConditionContainersReady means all the containers of the pod are running
+	PodConditionContainersReady ConditionType = "ContainersReady"
+	// PodConditionAdmitted reports whether the kubelet of the node the pod is bound to accepted
+	// to run it, its reason tells why a pod was rejected or evicted
+	PodConditionAdmitted ConditionType = "Admitted"
+)
+
 // Validate validates the PodSpec of the Pod.
 func (p *Pod) Validate() error {
 	validate := validator.New()
@@ -32,19 +99,82 @@ func (p *Pod) Validate() error {
 		return fmt.Errorf("%w: %v", ErrInvalidPodSpec, err)
 	}
 
+	if err := validateContainerNames(p.Spec.Containers); err != nil {
+		return err
+	}
+
+	for _, container := range p.Spec.Containers {
+		if _, err := NormalizeImage(container.Image); err != nil {
+			return fmt.Errorf("%w: container %s: %v", ErrInvalidPodSpec, container.Name, err)
+		}
+		for name, quantity := range container.Resources.Requests {
+			if quantity < 0 {
+				return fmt.Errorf("%w: container %s: %s request must not be negative", ErrInvalidPodSpec, container.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResourceRequests returns the resources requested by all containers of the pod
+func (s *PodSpec) ResourceRequests() ResourceList {
+	requests := make(ResourceList)
+	for _, container := range s.Containers {
+		requests.Add(container.Resources.Requests)
+	}
+	return requests
+}
+
+// validateContainerNames checks that every container name is a DNS-1123 label, as they name
+// Docker containers, and that no two containers share a name
+func validateContainerNames(containers []Container) error {
+	seen := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		if len(container.Name) > maxContainerNameLength || !dns1123Label.MatchString(container.Name) {
+			return fmt.Errorf("%w: %q must be a DNS-1123 label of at most %d lowercase alphanumeric characters or '-'",
+				ErrInvalidContainerName, container.Name, maxContainerNameLength)
+		}
+		if seen[container.Name] {
+			return fmt.Errorf("%w: %q is used by more than one container", ErrInvalidContainerName, container.Name)
+		}
+		seen[container.Name] = true
+	}
 	return nil
 }
 
 // IsActive checks if the pod is active.
 func (p *Pod) IsActive() bool {
-	return p.Status != PodFailed //even succeeded pods should be considered active? or else controller keeps on creating pods
+	return p.Status.Phase != PodFailed //even succeeded pods should be considered active? or else controller keeps on creating pods
+}
+
+// IsTerminating checks if the deletion of the pod was requested
+func (p *Pod) IsTerminating() bool {
+	return p.DeletionTimestamp != nil
+}
+
+// IsReady checks if the pod should receive traffic, that is if its Ready condition is true
+// and it isn't terminating
+func (p *Pod) IsReady() bool {
+	if p.IsTerminating() {
+		return false
+	}
+	for _, condition := range p.Status.Conditions {
+		if condition.Type == PodConditionReady {
+			return condition.Status == ConditionTrue
+		}
+	}
+	return false
 }
 
+// IsPodActiveAndOwnedBy checks if the pod is active and controlled by the given ReplicaSet
 func IsPodActiveAndOwnedBy(pod *Pod, meta *ObjectMeta) bool {
-	// Check if the pod name contains the ReplicaSet name (ownership)
 	return IsOwnedBy(pod, meta) && pod.IsActive()
 }
 
+// IsOwnedBy checks if the controller reference of the pod points to the given ReplicaSet.
+// Pods without a controller reference are owned by no ReplicaSet, whatever their name.
 func IsOwnedBy(pod *Pod, meta *ObjectMeta) bool {
-	return strings.HasPrefix(pod.Name, meta.Name)
+	ref := GetControllerOf(&pod.ObjectMeta)
+	return ref != nil && ref.RefersTo(meta, KindReplicaSet)
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/go-playground/validator/v10"
@@ -84,7 +85,7 @@ func TestPodValidation(t *testing.T) {
 				},
 				Replicas: 3,
 			},
-			Status: PodPending,
+			Status: PodStatus{Phase: PodPending},
 		}
 
 		err := validate.Struct(pod)
@@ -96,7 +97,7 @@ func TestPodValidation(t *testing.T) {
 			ObjectMeta: ObjectMeta{
 				Name: "test-pod",
 			},
-			Status: PodPending,
+			Status: PodStatus{Phase: PodPending},
 		}
 
 		err := validate.Struct(pod)
@@ -105,10 +106,57 @@ func TestPodValidation(t *testing.T) {
 	})
 }
 
+func TestPodValidate_ContainerNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []Container
+		valid      bool
+	}{
+		{
+			name:       "multiple containers with unique names",
+			containers: []Container{{Name: "nginx", Image: "nginx:latest"}, {Name: "log-shipper-1", Image: "busybox:latest"}},
+			valid:      true,
+		},
+		{
+			name:       "duplicate names",
+			containers: []Container{{Name: "app", Image: "nginx:latest"}, {Name: "app", Image: "busybox:latest"}},
+		},
+		{
+			name:       "uppercase name",
+			containers: []Container{{Name: "Nginx", Image: "nginx:latest"}},
+		},
+		{
+			name:       "name with underscore",
+			containers: []Container{{Name: "my_app", Image: "nginx:latest"}},
+		},
+		{
+			name:       "name ending with a dash",
+			containers: []Container{{Name: "app-", Image: "nginx:latest"}},
+		},
+		{
+			name:       "name longer than 63 characters",
+			containers: []Container{{Name: strings.Repeat("a", 64), Image: "nginx:latest"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := Pod{ObjectMeta: ObjectMeta{Name: "test-pod"}, Spec: PodSpec{Containers: tt.containers}}
+			err := pod.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidContainerName)
+			assert.ErrorIs(t, err, ErrInvalidPodSpec)
+		})
+	}
+}
+
 func TestPodIsActive(t *testing.T) {
 	tests := []struct {
 		name     string
-		status   PodStatus
+		status   PodPhase
 		expected bool
 	}{
 		{
@@ -131,17 +179,12 @@ func TestPodIsActive(t *testing.T) {
 			status:   PodFailed,
 			expected: false,
 		},
-		{
-			name:     "Pod is active when status is PodScheduled",
-			status:   PodScheduled,
-			expected: true,
-		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			pod := Pod{
-				Status: tt.status,
+				Status: PodStatus{Phase: tt.status},
 			}
 			assert.Equal(t, tt.expected, pod.IsActive())
 		})
@@ -159,12 +202,14 @@ func TestIsPodActiveAndOwnedBy(t *testing.T) {
 			name: "Pod is active and owned by ReplicaSet",
 			pod: Pod{
 				ObjectMeta: ObjectMeta{
-					Name: "replicaset-12345-pod",
+					Name:            "replicaset-12345-pod",
+					OwnerReferences: []OwnerReference{{Kind: KindReplicaSet, Name: "replicaset-12345", UID: "uid-1", Controller: true}},
 				},
-				Status: PodRunning,
+				Status: PodStatus{Phase: PodRunning},
 			},
 			meta: ObjectMeta{
 				Name: "replicaset-12345",
+				UID:  "uid-1",
 			},
 			expected: true,
 		},
@@ -172,12 +217,14 @@ func TestIsPodActiveAndOwnedBy(t *testing.T) {
 			name: "Pod is not active but owned by ReplicaSet",
 			pod: Pod{
 				ObjectMeta: ObjectMeta{
-					Name: "replicaset-12345-pod",
+					Name:            "replicaset-12345-pod",
+					OwnerReferences: []OwnerReference{{Kind: KindReplicaSet, Name: "replicaset-12345", UID: "uid-1", Controller: true}},
 				},
-				Status: PodFailed,
+				Status: PodStatus{Phase: PodFailed},
 			},
 			meta: ObjectMeta{
 				Name: "replicaset-12345",
+				UID:  "uid-1",
 			},
 			expected: false,
 		},
@@ -187,10 +234,11 @@ func TestIsPodActiveAndOwnedBy(t *testing.T) {
 				ObjectMeta: ObjectMeta{
 					Name: "other-replicaset-12345-pod",
 				},
-				Status: PodRunning,
+				Status: PodStatus{Phase: PodRunning},
 			},
 			meta: ObjectMeta{
 				Name: "replicaset-12345",
+				UID:  "uid-1",
 			},
 			expected: false,
 		},
@@ -200,10 +248,11 @@ func TestIsPodActiveAndOwnedBy(t *testing.T) {
 				ObjectMeta: ObjectMeta{
 					Name: "other-replicaset-12345-pod",
 				},
-				Status: PodFailed,
+				Status: PodStatus{Phase: PodFailed},
 			},
 			meta: ObjectMeta{
 				Name: "replicaset-12345",
+				UID:  "uid-1",
 			},
 			expected: false,
 		},
@@ -224,7 +273,7 @@ func TestIsOwnedBy(t *testing.T) {
 		expected bool
 	}{
 		{
-			name: "Pod is owned by ReplicaSet",
+			name: "Pod named after ReplicaSet without a controller reference",
 			pod: Pod{
 				ObjectMeta: ObjectMeta{
 					Name: "replicaset-12345-pod",
@@ -233,17 +282,21 @@ func TestIsOwnedBy(t *testing.T) {
 			meta: ObjectMeta{
 				Name: "replicaset-12345",
 			},
-			expected: true,
+			expected: false,
 		},
 		{
-			name: "Pod is not owned by ReplicaSet",
+			name: "Pod controlled by a ReplicaSet whose name extends the ReplicaSet name",
 			pod: Pod{
 				ObjectMeta: ObjectMeta{
-					Name: "other-replicaset-12345-pod",
+					Name: "replicaset-12345-abc-pod",
+					OwnerReferences: []OwnerReference{
+						{Kind: KindReplicaSet, Name: "replicaset-12345-abc", UID: "uid-2", Controller: true},
+					},
 				},
 			},
 			meta: ObjectMeta{
 				Name: "replicaset-12345",
+				UID:  "uid-1",
 			},
 			expected: false,
 		},
@@ -259,6 +312,38 @@ func TestIsOwnedBy(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "Pod controller reference points to ReplicaSet",
+			pod: Pod{
+				ObjectMeta: ObjectMeta{
+					Name: "pod",
+					OwnerReferences: []OwnerReference{
+						{Kind: KindReplicaSet, Name: "replicaset-12345", UID: "uid-1", Controller: true},
+					},
+				},
+			},
+			meta: ObjectMeta{
+				Name: "replicaset-12345",
+				UID:  "uid-1",
+			},
+			expected: true,
+		},
+		{
+			name: "Pod controller reference points to a previous ReplicaSet with the same name",
+			pod: Pod{
+				ObjectMeta: ObjectMeta{
+					Name: "replicaset-12345-pod",
+					OwnerReferences: []OwnerReference{
+						{Kind: KindReplicaSet, Name: "replicaset-12345", UID: "uid-1", Controller: true},
+					},
+				},
+			},
+			meta: ObjectMeta{
+				Name: "replicaset-12345",
+				UID:  "uid-2",
+			},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"gokube/pkg/api"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kinds of requests limited separately, used as the request_kind label of the in-flight metrics
+const (
+	requestKindReadOnly = "readOnly"
+	requestKindMutating = "mutating"
+)
+
+// ErrTooManyRequests is returned with a 429 for requests over the in-flight limit of their kind
+var ErrTooManyRequests = errors.New("too many requests in flight, try again later")
+
+// retryAfterSeconds is the Retry-After sent with the requests rejected by the in-flight limiter
+const retryAfterSeconds = "1"
+
+type serverMetrics struct {
+	inFlight *prometheus.GaugeVec
+	rejected *prometheus.CounterVec
+}
+
+// newServerMetrics creates the API server metrics and registers them with the registerer. Nil
+// registers them with the default Prometheus registerer. Metrics already registered by another
+// API server are reused.
+func newServerMetrics(registerer prometheus.Registerer) (*serverMetrics, error) {
+	m := &serverMetrics{
+		inFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "apiserver_current_inflight_requests",
+				Help: "Number of requests currently being served by kind (readOnly/mutating)",
+			},
+			[]string{"request_kind"},
+		),
+		rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "apiserver_rejected_requests_total",
+				Help: "Total number of requests rejected with a 429 for exceeding the in-flight limit, by kind",
+			},
+			[]string{"request_kind"},
+		),
+	}
+
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	err := errors.Join(
+		register(registerer, &m.inFlight),
+		register(registerer, &m.rejected),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register API server metrics: %v", err)
+	}
+	return m, nil
+}
+
+// register registers the collector with the registerer. If an identical collector is registered
+// already, the collector is replaced by it.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector *T) error {
+	err := registerer.Register(*collector)
+	if err == nil {
+		return nil
+	}
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+			*collector = existing
+			return nil
+		}
+	}
+	return err
+}
+
+// inFlightLimiter bounds the requests served concurrently, with separate limits for read-only
+// and mutating requests so that a burst of reads can't starve the writes, or the other way
+// around. Requests over the limit are rejected with a 429 rather than queued, which keeps the
+// load on etcd bounded.
+type inFlightLimiter struct {
+	readOnly chan struct{}
+	mutating chan struct{}
+	metrics  *serverMetrics
+}
+
+// newInFlightLimiter creates a limiter allowing maxReadOnly read-only and maxMutating mutating
+// requests in flight. A limit of zero or less leaves that kind of request unlimited.
+func newInFlightLimiter(maxReadOnly, maxMutating int, metrics *serverMetrics) *inFlightLimiter {
+	limiter := &inFlightLimiter{metrics: metrics}
+	if maxReadOnly > 0 {
+		limiter.readOnly = make(chan struct{}, maxReadOnly)
+	}
+	if maxMutating > 0 {
+		limiter.mutating = make(chan struct{}, maxMutating)
+	}
+	return limiter
+}
+
+// Filter serves the request if it is within the limit of its kind and rejects it otherwise.
+// Watches are long-running and don't load etcd while they wait for changes, they are not
+// limited, so that they don't take up the slots of short requests.
+func (l *inFlightLimiter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if request.QueryParameter("watch") == "true" {
+		chain.ProcessFilter(request, response)
+		return
+	}
+
+	kind, slots := requestKindReadOnly, l.readOnly
+	if isMutating(request.Request.Method) {
+		kind, slots = requestKindMutating, l.mutating
+	}
+
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		default:
+			l.metrics.rejected.WithLabelValues(kind).Inc()
+			response.AddHeader("Retry-After", retryAfterSeconds)
+			api.WriteError(response, http.StatusTooManyRequests, ErrTooManyRequests)
+			return
+		}
+	}
+
+	inFlight := l.metrics.inFlight.WithLabelValues(kind)
+	inFlight.Inc()
+	defer inFlight.Dec()
+	chain.ProcessFilter(request, response)
+}
+
+// isMutating reports whether requests of the method change resources
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// newInFlightLimiterOrLog creates the in-flight limiter of the options. If its metrics can't be
+// registered they are still tracked, only not exported.
+func newInFlightLimiterOrLog(opts Options) *inFlightLimiter {
+	metrics, err := newServerMetrics(opts.Registerer)
+	if err != nil {
+		log.Printf("Failed to register API server metrics: %v", err)
+		metrics, _ = newServerMetrics(prometheus.NewRegistry())
+	}
+	return newInFlightLimiter(opts.MaxRequestsInFlight, opts.MaxMutatingRequestsInFlight, metrics)
+}
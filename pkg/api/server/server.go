@@ -1,52 +1,201 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"gokube/pkg/api"
 	"gokube/pkg/api/handlers"
 	"gokube/pkg/registry"
 
 	"github.com/emicklei/go-restful/v3"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"gokube/pkg/storage"
 )
 
+// GroupVersion identifies the API group and version that resources are served under
+type GroupVersion = api.GroupVersion
+
+// CoreGroupVersion is the group version of the core API, served under /api/v1
+var CoreGroupVersion = api.CoreGroupVersion
+
+// Resources served by the API server
+const (
+	ResourcePods        = api.ResourcePods
+	ResourceNodes       = api.ResourceNodes
+	ResourceReplicaSets = api.ResourceReplicaSets
+	ResourceDeployments = api.ResourceDeployments
+	ResourceEndpoints   = api.ResourceEndpoints
+)
+
+// endpointsCacheRestartDelay is how long the endpoints cache waits before listing the pods
+// again after failing
+const endpointsCacheRestartDelay = time.Second
+
+// Options configures the APIServer
+type Options struct {
+	// BasePath is prepended to the path of every group, e.g. "/gokube" serves the core group on /gokube/api/v1
+	BasePath string
+	// Groups maps a resource to the group version it is served under.
+	// Resources that aren't listed are served under the core group.
+	Groups map[string]GroupVersion
+	// ValidatePodNodeNames rejects created and updated pods whose NodeName doesn't refer to
+	// an existing Ready node
+	ValidatePodNodeNames bool
+	// MaxWatchDuration is how long a watch is served before it is closed with a final BOOKMARK
+	// event, from whose resourceVersion the client reconnects. Zero serves watches until the
+	// client goes away.
+	MaxWatchDuration time.Duration
+	// MaxRequestsInFlight is how many read-only requests are served at once, the requests
+	// over it are rejected with a 429. Zero or less doesn't limit them. Watches aren't limited.
+	MaxRequestsInFlight int
+	// MaxMutatingRequestsInFlight is how many requests changing resources are served at once,
+	// the requests over it are rejected with a 429. Zero or less doesn't limit them.
+	MaxMutatingRequestsInFlight int
+	// Registerer is where the API server metrics, such as the requests in flight, are
+	// registered. Nil registers them with the default Prometheus registerer.
+	Registerer prometheus.Registerer
+}
+
+// DefaultOptions returns the default API server configuration, serving all resources under /api/v1
+func DefaultOptions() Options {
+	return Options{
+		MaxRequestsInFlight:         400,
+		MaxMutatingRequestsInFlight: 200,
+	}
+}
+
 // APIServer represents the API server
 type APIServer struct {
+	opts               Options
 	nodeRegistry       *registry.NodeRegistry
 	podRegistry        *registry.PodRegistry
 	replicasetRegistry *registry.ReplicaSetRegistry
+	deploymentRegistry *registry.DeploymentRegistry
+	endpoints          *registry.ReadyPodCache
+	limiter            *inFlightLimiter
 }
 
 // NewAPIServer creates a new instance of APIServer
 func NewAPIServer(storage storage.Storage) *APIServer {
+	return NewAPIServerWithOptions(storage, DefaultOptions())
+}
+
+// NewAPIServerWithOptions creates a new instance of APIServer with the given options
+func NewAPIServerWithOptions(storage storage.Storage, opts Options) *APIServer {
+	podRegistry := registry.NewPodRegistry(storage)
 	return &APIServer{
+		opts:               opts,
 		nodeRegistry:       registry.NewNodeRegistry(storage),
-		podRegistry:        registry.NewPodRegistry(storage),
+		podRegistry:        podRegistry,
 		replicasetRegistry: registry.NewReplicaSetRegistry(storage),
+		deploymentRegistry: registry.NewDeploymentRegistry(storage),
+		endpoints:          registry.NewReadyPodCache(podRegistry),
+		limiter:            newInFlightLimiterOrLog(opts),
 	}
 }
 
-// Start initializes and starts the API server
-func (s *APIServer) Start(address string) error {
+// MigrateLegacyPods moves the pods stored before pods had namespaces into the default
+// namespace. It is run before the API server starts serving.
+func (s *APIServer) MigrateLegacyPods(ctx context.Context) error {
+	migrated, err := s.podRegistry.MigrateLegacyPods(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to migrate pods: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("Migrated %d pods to the default namespace", migrated)
+	}
+	return nil
+}
+
+// Start initializes and starts the API server, serving until the context is done. The
+// endpoints cache runs for as long as the server does.
+func (s *APIServer) Start(ctx context.Context, address string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.RunEndpointsCache(ctx)
+
+	server := &http.Server{Addr: address, Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RunEndpointsCache keeps the cache the endpoints are served from up to date with the pods
+// until the context is done. The endpoints are unavailable until the cache has synced.
+func (s *APIServer) RunEndpointsCache(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := s.endpoints.Run(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Endpoints cache failed, restarting: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(endpointsCacheRestartDelay):
+		}
+	}
+}
+
+// Handler returns an http.Handler serving the API routes
+func (s *APIServer) Handler() http.Handler {
 	container := restful.NewContainer()
 	s.registerRoutes(container)
-
-	return http.ListenAndServe(address, container)
+	return container
 }
 
-// registerRoutes adds routes to the container
+// registerRoutes adds a web service for every group version to the container.
+// The core group is always served, as it hosts the health check.
 func (s *APIServer) registerRoutes(container *restful.Container) {
-	ws := new(restful.WebService)
+	container.Filter(s.limiter.Filter)
+	core := s.registerGroup(container, CoreGroupVersion)
+	core.Route(core.GET("/healthz").To(s.healthz))
+	handlers.RegisterDumpRoutes(core, handlers.NewDumpHandler(s.podRegistry, s.nodeRegistry, s.replicasetRegistry))
 
-	ws.Path("/api/v1").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
-	ws.Route(ws.GET("/healthz").To(s.healthz))
-	handlers.RegisterPodRoutes(ws, handlers.NewPodHandler(s.podRegistry))
-	handlers.RegisterNodeRoutes(ws, handlers.NewNodeHandler(s.nodeRegistry))
-	handlers.RegisterReplicasetRoutes(ws, handlers.NewReplicasetHandler(s.replicasetRegistry))
+	podHandler := handlers.NewPodHandler(s.podRegistry)
+	if s.opts.ValidatePodNodeNames {
+		podHandler = handlers.NewPodHandlerWithNodeValidation(s.podRegistry, s.nodeRegistry)
+	}
 
+	handlers.RegisterPodRoutes(s.registerGroup(container, s.groupVersionOf(ResourcePods)), podHandler)
+	handlers.RegisterNodeRoutes(s.registerGroup(container, s.groupVersionOf(ResourceNodes)), handlers.NewNodeHandler(s.nodeRegistry))
+	handlers.RegisterReplicasetRoutes(s.registerGroup(container, s.groupVersionOf(ResourceReplicaSets)), handlers.NewReplicasetHandler(s.replicasetRegistry, s.podRegistry))
+	handlers.RegisterDeploymentRoutes(s.registerGroup(container, s.groupVersionOf(ResourceDeployments)), handlers.NewDeploymentHandler(s.deploymentRegistry))
+	handlers.RegisterEndpointsRoutes(s.registerGroup(container, s.groupVersionOf(ResourceEndpoints)), handlers.NewEndpointsHandler(s.endpoints))
+}
+
+// registerGroup returns the web service serving the group version, adding it to the container if needed
+func (s *APIServer) registerGroup(container *restful.Container, gv GroupVersion) *restful.WebService {
+	path := s.opts.BasePath + gv.Path()
+	for _, ws := range container.RegisteredWebServices() {
+		if ws.RootPath() == path {
+			return ws
+		}
+	}
+
+	ws := new(restful.WebService)
+	ws.Path(path).Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	if s.opts.MaxWatchDuration > 0 {
+		ws.Filter(handlers.MaxWatchDurationFilter(s.opts.MaxWatchDuration))
+	}
 	container.Add(ws)
+	return ws
+}
+
+// groupVersionOf returns the group version the resource is served under
+func (s *APIServer) groupVersionOf(resource string) GroupVersion {
+	if gv, ok := s.opts.Groups[resource]; ok {
+		return gv
+	}
+	return CoreGroupVersion
 }
 
 func (s *APIServer) healthz(request *restful.Request, response *restful.Response) {
@@ -1,15 +1,25 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
+	"gokube/pkg/api"
+	"gokube/pkg/api/handlers"
+	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
 	"github.com/emicklei/go-restful/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -36,14 +46,19 @@ func TestAPIServer_Start(t *testing.T) {
 			store := storage.NewEtcdStorage(etcdServer)
 			server := NewAPIServer(store)
 
-			// Start server in a goroutine
-			go func() {
-				err := server.Start(":0")
-				require.NoError(t, err)
-			}()
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- server.Start(ctx, "localhost:0") }()
 
-			// Give the server time to start
+			// Give the server time to start, then stop it with the context
 			time.Sleep(100 * time.Millisecond)
+			cancel()
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(2 * time.Second):
+				t.Fatal("server did not stop after the context was done")
+			}
 		})
 	})
 
@@ -72,18 +87,21 @@ func TestAPIServer_RegisterRoutes(t *testing.T) {
 
 			routes := container.RegisteredWebServices()[0].Routes()
 			expectedRoutes := map[string]bool{
-				"/api/v1/pods:POST":           true, // Create pod
-				"/api/v1/pods:GET":            true, // List pods
-				"/api/v1/pods/{name}:GET":     true, // Get pod
-				"/api/v1/pods/{name}:PUT":     true, // Get pod
-				"/api/v1/pods/{name}:DELETE":  true, // Delete pod
-				"/api/v1/pods/unassigned:GET": true, // List unassigned pods
-				"/api/v1/nodes:POST":          true, // Create node
-				"/api/v1/nodes:GET":           true, // List nodes
-				"/api/v1/nodes/{name}:GET":    true, // Get node
-				"/api/v1/nodes/{name}:PUT":    true, // Get node
-				"/api/v1/nodes/{name}:DELETE": true, // Delete node
-				"/api/v1/healthz:GET":         true, // Health check
+				"/api/v1/pods:POST":               true, // Create pod
+				"/api/v1/pods:GET":                true, // List pods
+				"/api/v1/pods/{name}:GET":         true, // Get pod
+				"/api/v1/pods/{name}:PUT":         true, // Get pod
+				"/api/v1/pods/{name}/status:PUT":  true, // Update pod status
+				"/api/v1/pods/{name}:DELETE":      true, // Delete pod
+				"/api/v1/pods/unassigned:GET":     true, // List unassigned pods
+				"/api/v1/nodes:POST":              true, // Create node
+				"/api/v1/nodes:GET":               true, // List nodes
+				"/api/v1/nodes/{name}:GET":        true, // Get node
+				"/api/v1/nodes/{name}:PUT":        true, // Get node
+				"/api/v1/nodes/{name}/status:PUT": true, // Update node status
+				"/api/v1/nodes/{name}:DELETE":     true, // Delete node
+				"/api/v1/endpoints:GET":           true, // Get endpoints
+				"/api/v1/healthz:GET":             true, // Health check
 			}
 
 			foundRoutes := make(map[string]bool)
@@ -100,6 +118,103 @@ func TestAPIServer_RegisterRoutes(t *testing.T) {
 	})
 }
 
+func TestAPIServer_GroupVersions(t *testing.T) {
+	t.Run("should build group version paths", func(t *testing.T) {
+		assert.Equal(t, "/api/v1", CoreGroupVersion.Path())
+		assert.Equal(t, "/apis/apps/v1", GroupVersion{Group: "apps", Version: "v1"}.Path())
+	})
+
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		serve := func(container *restful.Container, method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		t.Run("should serve replicasets under the apps group", func(t *testing.T) {
+			server := NewAPIServerWithOptions(storage.NewEtcdStorage(etcdServer), Options{
+				Groups: map[string]GroupVersion{ResourceReplicaSets: {Group: "apps", Version: "v1"}},
+			})
+			container := server.createTestContainer()
+
+			foundRoutes := make(map[string]bool)
+			for _, ws := range container.RegisteredWebServices() {
+				for _, route := range ws.Routes() {
+					foundRoutes[route.Path+":"+route.Method] = true
+				}
+			}
+			assert.True(t, foundRoutes["/apis/apps/v1/replicasets:POST"])
+			assert.True(t, foundRoutes["/apis/apps/v1/replicasets/{name}:GET"])
+			assert.True(t, foundRoutes["/api/v1/pods:GET"])
+			assert.True(t, foundRoutes["/api/v1/healthz:GET"])
+			assert.False(t, foundRoutes["/api/v1/replicasets:GET"])
+
+			rs := `{"metadata":{"name":"web"},"spec":{"replicas":1,"selector":{"app":"web"},` +
+				`"template":{"metadata":{"labels":{"app":"web"}},"spec":{"containers":[{"name":"web","image":"nginx"}]}}}}`
+			assert.Equal(t, http.StatusCreated, serve(container, http.MethodPost, "/apis/apps/v1/replicasets", rs).Code)
+			assert.Equal(t, http.StatusOK, serve(container, http.MethodGet, "/apis/apps/v1/replicasets/web", "").Code)
+			assert.Equal(t, http.StatusNotFound, serve(container, http.MethodGet, "/api/v1/replicasets/web", "").Code)
+			assert.Equal(t, http.StatusOK, serve(container, http.MethodGet, "/api/v1/pods", "").Code)
+		})
+
+		t.Run("should prepend the base path to every group", func(t *testing.T) {
+			server := NewAPIServerWithOptions(storage.NewEtcdStorage(etcdServer), Options{
+				BasePath: "/gokube",
+				Groups:   map[string]GroupVersion{ResourceReplicaSets: {Group: "apps", Version: "v1"}},
+			})
+			container := server.createTestContainer()
+
+			assert.Equal(t, http.StatusOK, serve(container, http.MethodGet, "/gokube/api/v1/healthz", "").Code)
+			assert.Equal(t, http.StatusOK, serve(container, http.MethodGet, "/gokube/api/v1/nodes", "").Code)
+			assert.Equal(t, http.StatusOK, serve(container, http.MethodGet, "/gokube/apis/apps/v1/replicasets", "").Code)
+			assert.Equal(t, http.StatusNotFound, serve(container, http.MethodGet, "/api/v1/healthz", "").Code)
+		})
+	})
+}
+
+func TestAPIServer_YAMLManifests(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		container := NewAPIServer(storage.NewEtcdStorage(etcdServer)).createTestContainer()
+		serve := func(contentType, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/pods", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		manifest := `
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  containers:
+    - name: web
+      image: nginx
+      resources:
+        requests:
+          cpu: 250
+`
+		resp := serve(handlers.MIMEYAML, manifest)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+		pod, err := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer)).GetPod(context.Background(), api.NamespaceDefault, "web")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "web"}, pod.Labels)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "web", pod.Spec.Containers[0].Name)
+		assert.Equal(t, int64(250), pod.Spec.Containers[0].Resources.Requests[api.ResourceCPU])
+		assert.Equal(t, api.PodPending, pod.Status.Phase)
+
+		// Malformed YAML is rejected
+		resp = serve(handlers.MIMEYAML, "metadata: [name: broken")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
 // Helper function to create a test container
 func (s *APIServer) createTestContainer() *restful.Container {
 	container := restful.NewContainer()
@@ -118,3 +233,131 @@ func withTestServer(t *testing.T, fn func(*clientv3.Client)) {
 
 	fn(client)
 }
+
+func TestAPIServer_Endpoints(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		server := NewAPIServer(store)
+		container := server.createTestContainer()
+		podRegistry := registry.NewPodRegistry(store)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for name, labels := range map[string]map[string]string{
+			"web-1": {"app": "web", "tier": "frontend"},
+			"web-2": {"app": "web"},
+			"db-1":  {"app": "db"},
+		} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name, Labels: labels},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+				Status:     api.PodStatus{Conditions: []api.Condition{{Type: api.PodConditionReady, Status: api.ConditionTrue}}},
+			}))
+		}
+		getEndpoints := func(path string) (int, api.Endpoints) {
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+			var endpoints api.Endpoints
+			if resp.Code == http.StatusOK {
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &endpoints))
+			}
+			return resp.Code, endpoints
+		}
+
+		// The endpoints are unavailable until the cache has synced
+		code, _ := getEndpoints("/api/v1/endpoints?labelSelector=app=web")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		go server.RunEndpointsCache(ctx)
+		require.Eventually(t, func() bool {
+			code, _ := getEndpoints("/api/v1/endpoints")
+			return code == http.StatusOK
+		}, 2*time.Second, 10*time.Millisecond)
+
+		code, endpoints := getEndpoints("/api/v1/endpoints?labelSelector=app=web")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"web-1", "web-2"}, endpoints.Pods)
+
+		code, endpoints = getEndpoints("/api/v1/namespaces/default/endpoints?labelSelector=app=web,tier=frontend")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"web-1"}, endpoints.Pods)
+
+		code, endpoints = getEndpoints("/api/v1/namespaces/other/endpoints?labelSelector=app=web")
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, endpoints.Pods)
+
+		code, _ = getEndpoints("/api/v1/endpoints?labelSelector=app")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestAPIServer_MaxRequestsInFlight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Every pod lookup blocks until released, keeping its request in flight
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	mockStore := mockStorage.NewMockStorage(ctrl)
+	mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key string, obj runtime.Object) error {
+		started <- struct{}{}
+		<-release
+		return storage.ErrNotFound
+	}).AnyTimes()
+
+	metricsRegistry := prometheus.NewRegistry()
+	opts := DefaultOptions()
+	opts.MaxRequestsInFlight = 2
+	opts.MaxMutatingRequestsInFlight = 1
+	opts.Registerer = metricsRegistry
+	server := NewAPIServerWithOptions(mockStore, opts)
+	container := server.createTestContainer()
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, httptest.NewRequest(method, "/api/v1/pods/test-pod", nil))
+		return resp
+	}
+	inFlight := func(kind string) float64 {
+		return testutil.ToFloat64(server.limiter.metrics.inFlight.WithLabelValues(kind))
+	}
+
+	// Saturate both limits
+	var wg sync.WaitGroup
+	codes := make(chan int, 3)
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodDelete} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(method).Code
+		}()
+	}
+	for range 3 {
+		<-started
+	}
+	assert.Equal(t, float64(2), inFlight(requestKindReadOnly))
+	assert.Equal(t, float64(1), inFlight(requestKindMutating))
+
+	// The requests over the limits are rejected right away, while the others are in flight
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		resp := serve(method)
+		assert.Equal(t, http.StatusTooManyRequests, resp.Code, method)
+		assert.Equal(t, "1", resp.Header().Get("Retry-After"))
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(server.limiter.metrics.rejected.WithLabelValues(requestKindReadOnly)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(server.limiter.metrics.rejected.WithLabelValues(requestKindMutating)))
+	count, err := testutil.GatherAndCount(metricsRegistry, "apiserver_current_inflight_requests", "apiserver_rejected_requests_total")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// Once the requests complete, the slots are free again
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		assert.Equal(t, http.StatusNotFound, code)
+	}
+	assert.Equal(t, float64(0), inFlight(requestKindReadOnly))
+	assert.Equal(t, float64(0), inFlight(requestKindMutating))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet).Code)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// TableRower is implemented by objects that have a human readable summary of one table row
+type TableRower interface {
+	// TableColumns returns the column headers of the table
+	TableColumns() []string
+	// TableRow returns the row of the object, with its age relative to now
+	TableRow(now time.Time) []string
+}
+
+// TableColumns returns the pod columns
+func (p *Pod) TableColumns() []string {
+	return []string{"NAME", "STATUS", "NODE", "AGE"}
+}
+
+// TableRow returns the pod name, status, node and age
+func (p *Pod) TableRow(now time.Time) []string {
+	status := string(p.Status.Phase)
+	if p.IsTerminating() {
+		status = "Terminating"
+	}
+	return []string{p.Name, status, valueOrNone(p.NodeName), FormatAge(p.CreationTimestamp, now)}
+}
+
+// TableColumns returns the node columns
+func (n *Node) TableColumns() []string {
+	return []string{"NAME", "STATUS", "AGE"}
+}
+
+// TableRow returns the node name, status and age. A cordoned node has SchedulingDisabled added
+// to its status.
+func (n *Node) TableRow(now time.Time) []string {
+	status := string(n.Status)
+	if n.Spec.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return []string{n.Name, status, FormatAge(n.CreationTimestamp, now)}
+}
+
+// TableColumns returns the replica set columns
+func (rs *ReplicaSet) TableColumns() []string {
+	return []string{"NAME", "DESIRED", "CURRENT", "AGE"}
+}
+
+// TableRow returns the replica set name, desired and current replicas, and age
+func (rs *ReplicaSet) TableRow(now time.Time) []string {
+	return []string{rs.Name, fmt.Sprint(rs.Spec.Replicas), fmt.Sprint(rs.Status.Replicas), FormatAge(rs.CreationTimestamp, now)}
+}
+
+// PrintTable writes the objects as a table with aligned columns, headed by the columns of T
+func PrintTable[T TableRower](out io.Writer, objects []T, now time.Time) error {
+	var zero T
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, strings.Join(zero.TableColumns(), "\t")); err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if _, err := fmt.Fprintln(w, strings.Join(object.TableRow(now), "\t")); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// FormatAge returns how long before now the timestamp was, in its largest whole unit, such as
+// 45s, 12m, 5h or 3d. A zero timestamp is <unknown>.
+func FormatAge(timestamp, now time.Time) string {
+	if timestamp.IsZero() {
+		return "<unknown>"
+	}
+
+	age := now.Sub(timestamp)
+	switch {
+	case age < 0:
+		return "0s"
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintTable(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	deleted := now.Add(-time.Second)
+
+	t.Run("pods", func(t *testing.T) {
+		pods := []*Pod{
+			{ObjectMeta: ObjectMeta{Name: "nginx", CreationTimestamp: now.Add(-45 * time.Second)}, Status: PodStatus{Phase: PodPending}},
+			{ObjectMeta: ObjectMeta{Name: "web-1", CreationTimestamp: now.Add(-90 * time.Minute)}, NodeName: "node-1", Status: PodStatus{Phase: PodRunning}},
+			{ObjectMeta: ObjectMeta{Name: "web-2", CreationTimestamp: now.Add(-72 * time.Hour), DeletionTimestamp: &deleted}, NodeName: "node-2", Status: PodStatus{Phase: PodRunning}},
+		}
+
+		assert.Equal(t, []string{"web-1", "Running", "node-1", "1h"}, pods[1].TableRow(now))
+
+		var out bytes.Buffer
+		require.NoError(t, PrintTable(&out, pods, now))
+		assert.Equal(t, ""+
+			"NAME    STATUS        NODE     AGE\n"+
+			"nginx   Pending       <none>   45s\n"+
+			"web-1   Running       node-1   1h\n"+
+			"web-2   Terminating   node-2   3d\n", out.String())
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		nodes := []*Node{
+			{ObjectMeta: ObjectMeta{Name: "node-1", CreationTimestamp: now.Add(-5 * time.Hour)}, Status: NodeReady},
+			{ObjectMeta: ObjectMeta{Name: "node-2"}, Spec: NodeSpec{Unschedulable: true}, Status: NodeReady},
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, PrintTable(&out, nodes, now))
+		assert.Equal(t, ""+
+			"NAME     STATUS                     AGE\n"+
+			"node-1   Ready                      5h\n"+
+			"node-2   Ready,SchedulingDisabled   <unknown>\n", out.String())
+	})
+
+	t.Run("replicasets", func(t *testing.T) {
+		replicaSets := []*ReplicaSet{{
+			ObjectMeta: ObjectMeta{Name: "web", CreationTimestamp: now.Add(-2 * time.Minute)},
+			Spec:       ReplicaSetSpec{Replicas: 3},
+			Status:     ReplicaSetStatus{Replicas: 2},
+		}}
+
+		var out bytes.Buffer
+		require.NoError(t, PrintTable(&out, replicaSets, now))
+		assert.Equal(t, "NAME   DESIRED   CURRENT   AGE\nweb    3         2         2m\n", out.String())
+	})
+}
@@ -2,49 +2,143 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
-type PodStatus string
+var (
+	ErrInvalidNodeSpec       = errors.New("invalid node spec")
+	ErrInvalidReplicaSetSpec = errors.New("invalid replicaset spec")
+)
+
+// ResourceRequirements describes the resources a container needs
+type ResourceRequirements struct {
+	// Requests is the amount of each resource that must be free on a node for the container
+	// to be scheduled there
+	Requests ResourceList `json:"requests,omitempty"`
+}
 
 const (
-	// PodPending means the pod has been accepted by the system, but one or more of the containers
-	// has not been started. This includes time before being bound to a node, as well as time spent
-	// pulling images onto the host.
-	PodPending PodStatus = "Pending"
+	// NamespaceDefault is the namespace of objects that don't set one
+	NamespaceDefault = "default"
+	// NamespaceAll lists objects of all namespaces
+	NamespaceAll = ""
+)
 
-	// PodRunning means the pod has been bound to a node and all of the containers have been started.
-	// At least one container is still running or is in the process of being restarted.
-	PodRunning PodStatus = "Running"
+// NamespaceOrDefault returns the namespace, or the default namespace if it is empty
+func NamespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return NamespaceDefault
+	}
+	return namespace
+}
 
-	// PodSucceeded means that all containers in the pod have voluntarily terminated
-	// with a container exit code of 0, and the system is not going to restart any of these containers.
-	PodSucceeded PodStatus = "Succeeded"
+// LocalObjectReference refers to an object by name
+type LocalObjectReference struct {
+	Name string `json:"name" validate:"required"`
+}
 
-	// PodFailed means that all containers in the pod have terminated, and at least one container has
-	// terminated in a failure (exited with a non-zero exit code or was stopped by the system).
-	PodFailed PodStatus = "Failed"
+// ObjectMeta is minimal metadata that all persisted resources must have
+type ObjectMeta struct {
+	Name              string            `json:"name" validate:"required"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	CreationTimestamp time.Time         `json:"creationTimestamp,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	OwnerReferences   []OwnerReference  `json:"ownerReferences,omitempty"`
+	// Finalizers must all be removed before an object marked for deletion is removed from storage
+	Finalizers []string `json:"finalizers,omitempty"`
+	// DeletionTimestamp is set when deletion was requested while finalizers were pending
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+}
 
-	//TODO: Kubernetes separates PodPhase and PodCondition. We have simplified to have a single pod status.
-	PodScheduled PodStatus = "Scheduled"
-)
+// GetResourceVersion returns the version of the stored object the metadata was read from
+func (m *ObjectMeta) GetResourceVersion() string {
+	return m.ResourceVersion
+}
 
-var (
-	ErrInvalidNodeSpec = errors.New("invalid node spec")
-)
+// SetResourceVersion sets the version of the stored object the metadata was read from
+func (m *ObjectMeta) SetResourceVersion(version string) {
+	m.ResourceVersion = version
+}
 
-type Container struct {
-	Name  string `json:"name" validate:"required"`
-	Image string `json:"image" validate:"required"`
+// GetObjectMeta returns the metadata, so that objects embedding it can be handled alike
+func (m *ObjectMeta) GetObjectMeta() *ObjectMeta {
+	return m
 }
 
-// ObjectMeta is minimal metadata that all persisted resources must have
-type ObjectMeta struct {
-	Name              string    `json:"name" validate:"required"`
-	Namespace         string    `json:"namespace,omitempty"`
-	UID               string    `json:"uid,omitempty"`
-	ResourceVersion   string    `json:"resourceVersion,omitempty"`
-	CreationTimestamp time.Time `json:"creationTimestamp,omitempty"`
+// KindReplicaSet is the kind used in owner references to ReplicaSets
+const KindReplicaSet = "ReplicaSet"
+
+// OwnerReference identifies the object that owns another object
+type OwnerReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	UID  string `json:"uid,omitempty"`
+	// Controller is true if the owner manages the lifecycle of the object
+	Controller bool `json:"controller,omitempty"`
+}
+
+// NewControllerRef returns a controller owner reference to the given owner
+func NewControllerRef(owner *ObjectMeta, kind string) OwnerReference {
+	return OwnerReference{
+		Kind:       kind,
+		Name:       owner.Name,
+		UID:        owner.UID,
+		Controller: true,
+	}
+}
+
+// GetControllerOf returns the controller owner reference of the object, or nil if it has none
+func GetControllerOf(meta *ObjectMeta) *OwnerReference {
+	for i := range meta.OwnerReferences {
+		if meta.OwnerReferences[i].Controller {
+			return &meta.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+// RefersTo checks if the owner reference points to the given object
+func (r *OwnerReference) RefersTo(owner *ObjectMeta, kind string) bool {
+	if r.Kind != kind || r.Name != owner.Name {
+		return false
+	}
+	return r.UID == "" || owner.UID == "" || r.UID == owner.UID
+}
+
+// MatchesSelector checks if the labels contain all the key/value pairs of the selector.
+// An empty selector matches everything.
+func MatchesSelector(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+type ConditionType string
+
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// Condition describes the state of a resource at a certain point
+type Condition struct {
+	Type   ConditionType   `json:"type"`
+	Status ConditionStatus `json:"status"`
+	// Reason is a machine readable explanation for the condition's last transition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the condition
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the last time the condition changed status
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
 }
 
 // NodeSpec describes the basic attributes of a node
@@ -63,6 +157,11 @@ const (
 	NodeDiskPressure   NodeStatus = "DiskPressure"
 )
 
+const (
+	// NodeConditionReady means the kubelet is healthy and ready to accept pods
+	NodeConditionReady ConditionType = "Ready"
+)
+
 // ReplicaSet represents the configuration of a ReplicaSet
 type ReplicaSet struct {
 	ObjectMeta `json:"metadata,omitempty"`
@@ -70,11 +169,38 @@ type ReplicaSet struct {
 	Status     ReplicaSetStatus `json:"status,omitempty"`
 }
 
+// Validate checks if the ReplicaSet configuration is valid.
+// The pod template is validated as the Pod the controller would create from it.
+func (rs *ReplicaSet) Validate() error {
+	if rs.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidReplicaSetSpec)
+	}
+	if rs.Spec.Replicas < 0 {
+		return fmt.Errorf("%w: replicas must not be negative", ErrInvalidReplicaSetSpec)
+	}
+	if len(rs.Spec.Template.Spec.Containers) == 0 {
+		return fmt.Errorf("%w: template must have at least one container", ErrInvalidReplicaSetSpec)
+	}
+
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: rs.Name, Labels: rs.Spec.Template.Labels},
+		Spec:       rs.Spec.Template.Spec,
+	}
+	if err := pod.Validate(); err != nil {
+		return fmt.Errorf("%w: invalid template: %v", ErrInvalidReplicaSetSpec, err)
+	}
+
+	return nil
+}
+
 // ReplicaSetSpec is the specification of a ReplicaSet
 type ReplicaSetSpec struct {
 	Replicas int32             `json:"replicas" validate:"gte=0"`
 	Selector map[string]string `json:"selector"`
 	Template PodTemplateSpec   `json:"template"`
+	// Paused stops the controller from reconciling the ReplicaSet, so that no pods are created
+	// or deleted for it until it is unpaused
+	Paused bool `json:"paused,omitempty"`
 }
 
 // PodTemplateSpec describes the data a pod should have when created from a template
@@ -89,4 +215,32 @@ type ReplicaSetStatus struct {
 	FullyLabeledReplicas int32 `json:"fullyLabeledReplicas,omitempty"`
 	ReadyReplicas        int32 `json:"readyReplicas,omitempty"`
 	AvailableReplicas    int32 `json:"availableReplicas,omitempty"`
+	// Conditions reports the Paused condition while the ReplicaSet is paused
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// ReplicaSetDrift reports a ReplicaSet whose observed replica count differs from the desired one
+type ReplicaSetDrift struct {
+	Name            string `json:"name"`
+	DesiredReplicas int32  `json:"desiredReplicas"`
+	CurrentReplicas int32  `json:"currentReplicas"`
+	// Delta is the number of replicas missing, negative for a ReplicaSet with too many replicas
+	Delta int32 `json:"delta"`
 }
+
+// Drift returns the drift of the ReplicaSet computed from its stored status, and whether its
+// observed replica count differs from the desired one
+func (rs *ReplicaSet) Drift() (ReplicaSetDrift, bool) {
+	drift := ReplicaSetDrift{
+		Name:            rs.Name,
+		DesiredReplicas: rs.Spec.Replicas,
+		CurrentReplicas: rs.Status.Replicas,
+		Delta:           rs.Spec.Replicas - rs.Status.Replicas,
+	}
+	return drift, drift.Delta != 0
+}
+
+const (
+	// ReplicaSetConditionPaused is present and True while reconciliation of the ReplicaSet is paused
+	ReplicaSetConditionPaused ConditionType = "Paused"
+)
@@ -101,3 +101,63 @@ func TestObjectMetaValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestReplicaSetValidation(t *testing.T) {
+	newReplicaSet := func(containers ...Container) *ReplicaSet {
+		return &ReplicaSet{
+			ObjectMeta: ObjectMeta{Name: "nginx-rs"},
+			Spec: ReplicaSetSpec{
+				Replicas: 2,
+				Template: PodTemplateSpec{
+					Spec: PodSpec{Containers: containers},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		replicaSet *ReplicaSet
+		wantErr    bool
+	}{
+		{
+			name:       "valid template",
+			replicaSet: newReplicaSet(Container{Name: "nginx", Image: "nginx:latest"}),
+		},
+		{
+			name:       "template without containers",
+			replicaSet: newReplicaSet(),
+			wantErr:    true,
+		},
+		{
+			name:       "template with empty container list",
+			replicaSet: &ReplicaSet{ObjectMeta: ObjectMeta{Name: "nginx-rs"}, Spec: ReplicaSetSpec{Template: PodTemplateSpec{Spec: PodSpec{Containers: []Container{}}}}},
+			wantErr:    true,
+		},
+		{
+			name:       "template container without image",
+			replicaSet: newReplicaSet(Container{Name: "nginx"}),
+			wantErr:    true,
+		},
+		{
+			name: "negative replicas",
+			replicaSet: func() *ReplicaSet {
+				rs := newReplicaSet(Container{Name: "nginx", Image: "nginx:latest"})
+				rs.Spec.Replicas = -1
+				return rs
+			}(),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.replicaSet.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidReplicaSetSpec)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import "encoding/json"
+
+// WatchEventType defines the possible types of events streamed by watch requests
+type WatchEventType string
+
+const (
+	// WatchAdded indicates a new object was created
+	WatchAdded WatchEventType = "ADDED"
+	// WatchModified indicates an existing object was updated
+	WatchModified WatchEventType = "MODIFIED"
+	// WatchDeleted indicates an object was removed
+	WatchDeleted WatchEventType = "DELETED"
+	// WatchBookmark marks the end of the initial list of a WatchList request. It carries no
+	// object, only the resource version the list was read at.
+	WatchBookmark WatchEventType = "BOOKMARK"
+	// WatchError ends a watch that failed. Its object is a WatchStatus, with the code 410 Gone
+	// when the requested resource version was compacted and the client has to list again.
+	WatchError WatchEventType = "ERROR"
+)
+
+// WatchStatus is the object of a WatchError event
+type WatchStatus struct {
+	// Code is the HTTP status code matching the failure
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// WatchEvent is a single change streamed to clients watching a resource.
+// For deletions Object holds the last known state of the removed object.
+type WatchEvent struct {
+	Type            WatchEventType  `json:"type"`
+	Object          json.RawMessage `json:"object"`
+	ResourceVersion string          `json:"resourceVersion,omitempty"`
+}
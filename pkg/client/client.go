@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"gokube/pkg/api"
+)
+
+var (
+	// ErrNotFound is returned when the API server has no resource with the requested name
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when creating a resource whose name is taken
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalid is returned when the API server rejects a resource as invalid
+	ErrInvalid = errors.New("invalid")
+	// ErrServer is returned for any other unsuccessful response from the API server
+	ErrServer = errors.New("server error")
+)
+
+// Options configures the Client. The paths must match the Options of the API server.
+type Options struct {
+	// BasePath is prepended to the path of every group, as set on the API server
+	BasePath string
+	// Groups maps a resource to the group version it is served under, as set on the API
+	// server. Resources that aren't listed are served under the core group.
+	Groups map[string]api.GroupVersion
+}
+
+// DefaultOptions returns the default client configuration, reaching all resources under /api/v1
+func DefaultOptions() Options {
+	return Options{}
+}
+
+// Client is a typed client for the gokube API server
+type Client struct {
+	opts       Options
+	server     string
+	httpClient *http.Client
+}
+
+// NewClient creates a Client for the API server at server.
+// The scheme defaults to http when server is a bare host:port.
+func NewClient(server string) *Client {
+	return NewClientWithOptions(server, DefaultOptions())
+}
+
+// NewClientWithOptions creates a Client for the API server at server with the given options
+func NewClientWithOptions(server string, opts Options) *Client {
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	return &Client{
+		opts:       opts,
+		server:     strings.TrimSuffix(server, "/"),
+		httpClient: http.DefaultClient,
+	}
+}
+
+// CreatePod creates a pod and returns it as stored by the API server
+func (c *Client) CreatePod(ctx context.Context, pod *api.Pod) (*api.Pod, error) {
+	created := new(api.Pod)
+	if err := c.do(ctx, http.MethodPost, c.url(api.ResourcePods, ""), pod, created, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetPod returns the pod with the given name
+func (c *Client) GetPod(ctx context.Context, name string) (*api.Pod, error) {
+	pod := new(api.Pod)
+	if err := c.do(ctx, http.MethodGet, c.url(api.ResourcePods, name), nil, pod, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// ListPods returns all pods
+func (c *Client) ListPods(ctx context.Context) ([]*api.Pod, error) {
+	var pods []*api.Pod
+	if err := c.do(ctx, http.MethodGet, c.url(api.ResourcePods, ""), nil, &pods, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return pods, nil
+}
+
+// DeletePod deletes the pod with the given name.
+// A pod with finalizers is removed once its finalizers are removed.
+func (c *Client) DeletePod(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, c.url(api.ResourcePods, name), nil, nil, http.StatusNoContent, http.StatusAccepted)
+}
+
+// CreateNode creates a node and returns it as stored by the API server
+func (c *Client) CreateNode(ctx context.Context, node *api.Node) (*api.Node, error) {
+	created := new(api.Node)
+	if err := c.do(ctx, http.MethodPost, c.url(api.ResourceNodes, ""), node, created, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetNode returns the node with the given name
+func (c *Client) GetNode(ctx context.Context, name string) (*api.Node, error) {
+	node := new(api.Node)
+	if err := c.do(ctx, http.MethodGet, c.url(api.ResourceNodes, name), nil, node, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// ListNodes returns all nodes
+func (c *Client) ListNodes(ctx context.Context) ([]*api.Node, error) {
+	var nodes []*api.Node
+	if err := c.do(ctx, http.MethodGet, c.url(api.ResourceNodes, ""), nil, &nodes, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// DeleteNode deletes the node with the given name
+func (c *Client) DeleteNode(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, c.url(api.ResourceNodes, name), nil, nil, http.StatusNoContent)
+}
+
+// CreateReplicaSet creates a replicaset and returns it as stored by the API server
+func (c *Client) CreateReplicaSet(ctx context.Context, rs *api.ReplicaSet) (*api.ReplicaSet, error) {
+	created := new(api.ReplicaSet)
+	if err := c.do(ctx, http.MethodPost, c.url(api.ResourceReplicaSets, ""), rs, created, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetReplicaSet returns the replicaset with the given name
+func (c *Client) GetReplicaSet(ctx context.Context, name string) (*api.ReplicaSet, error) {
+	rs := new(api.ReplicaSet)
+	if err := c.do(ctx, http.MethodGet, c.url(api.ResourceReplicaSets, name), nil, rs, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// ListReplicaSets returns all replicasets
+func (c *Client) ListReplicaSets(ctx context.Context) ([]*api.ReplicaSet, error) {
+	var replicaSets []*api.ReplicaSet
+	if err := c.do(ctx, http.MethodGet, c.url(api.ResourceReplicaSets, ""), nil, &replicaSets, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return replicaSets, nil
+}
+
+// DeleteReplicaSet deletes the replicaset with the given name, along with the pods it controls
+func (c *Client) DeleteReplicaSet(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, c.url(api.ResourceReplicaSets, name), nil, nil, http.StatusNoContent)
+}
+
+// url returns the URL of the named resource, or of the resource collection when name is
+// empty, under the group version the resource is served under
+func (c *Client) url(resource, name string) string {
+	gv, ok := c.opts.Groups[resource]
+	if !ok {
+		gv = api.CoreGroupVersion
+	}
+	target := c.server + c.opts.BasePath + gv.Path() + "/" + resource
+	if name != "" {
+		target += "/" + url.PathEscape(name)
+	}
+	return target
+}
+
+// do sends a request to target with in encoded as the JSON body, if set, and decodes the
+// response into out, if set. A response with any status other than the expected ones is
+// returned as an error.
+func (c *Client) do(ctx context.Context, method, target string, in, out interface{}, expected ...int) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(expected, resp.StatusCode) {
+		return responseError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// responseError converts an unsuccessful response into an error wrapping one of the client errors
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = resp.Status
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, message)
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, message)
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrInvalid, message)
+	default:
+		return fmt.Errorf("%w: %s", ErrServer, message)
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestClient_GroupVersions(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		apps := api.GroupVersion{Group: "apps", Version: "v1"}
+		apiServer := httptest.NewServer(server.NewAPIServerWithOptions(storage.NewEtcdStorage(etcdServer), server.Options{
+			BasePath: "/gokube",
+			Groups:   map[string]api.GroupVersion{api.ResourceReplicaSets: apps},
+		}).Handler())
+		defer apiServer.Close()
+		ctx := context.Background()
+
+		t.Run("should reach every resource under the group version it is served under", func(t *testing.T) {
+			c := NewClientWithOptions(apiServer.URL, Options{
+				BasePath: "/gokube",
+				Groups:   map[string]api.GroupVersion{api.ResourceReplicaSets: apps},
+			})
+			spec := api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx"}}}
+
+			_, err := c.CreatePod(ctx, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}, Spec: spec})
+			require.NoError(t, err)
+			pod, err := c.GetPod(ctx, "web")
+			require.NoError(t, err)
+			assert.Equal(t, "web", pod.Name)
+
+			_, err = c.CreateReplicaSet(ctx, &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: "web"},
+				Spec: api.ReplicaSetSpec{
+					Replicas: 1,
+					Selector: map[string]string{"app": "web"},
+					Template: api.PodTemplateSpec{ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "web"}}, Spec: spec},
+				},
+			})
+			require.NoError(t, err)
+			replicaSets, err := c.ListReplicaSets(ctx)
+			require.NoError(t, err)
+			require.Len(t, replicaSets, 1)
+			assert.Equal(t, "web", replicaSets[0].Name)
+		})
+
+		t.Run("should not find resources served under another path", func(t *testing.T) {
+			_, err := NewClient(apiServer.URL).ListReplicaSets(ctx)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			_, err = NewClientWithOptions(apiServer.URL, Options{BasePath: "/gokube"}).ListReplicaSets(ctx)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	})
+}
@@ -0,0 +1,404 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"slices"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/listwatch"
+	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
+	"gokube/pkg/storage"
+	"gokube/pkg/workqueue"
+)
+
+// deploymentWatchPrefix is the etcd prefix the registry stores Deployments under
+const deploymentWatchPrefix = "/deployments/"
+
+// DeploymentController manages the ReplicaSets of Deployments. A Deployment has a ReplicaSet per
+// pod template it ran, when its template changes the pods are rolled over from the old
+// ReplicaSets to the new one within the MaxSurge and MaxUnavailable of its strategy.
+type DeploymentController struct {
+	deploymentRegistry *registry.DeploymentRegistry
+	replicaSetRegistry *registry.ReplicaSetRegistry
+	podRegistry        *registry.PodRegistry
+	opts               Options
+}
+
+// NewDeploymentController creates a new DeploymentController
+func NewDeploymentController(deploymentRegistry *registry.DeploymentRegistry, rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry) *DeploymentController {
+	return NewDeploymentControllerWithOptions(deploymentRegistry, rsRegistry, podRegistry, DefaultOptions())
+}
+
+// NewDeploymentControllerWithOptions creates a DeploymentController with the given configuration
+func NewDeploymentControllerWithOptions(deploymentRegistry *registry.DeploymentRegistry, rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry, opts Options) *DeploymentController {
+	return &DeploymentController{
+		deploymentRegistry: deploymentRegistry,
+		replicaSetRegistry: rsRegistry,
+		podRegistry:        podRegistry,
+		opts:               opts,
+	}
+}
+
+// Reconcile moves the Deployment one step towards running its desired replicas from its current
+// template. The ReplicaSet of the template is created or scaled up as far as MaxSurge allows,
+// and the old ReplicaSets are scaled down as far as MaxUnavailable allows. Pods becoming
+// available let the next reconcile go further.
+func (dc *DeploymentController) Reconcile(ctx context.Context, deployment *api.Deployment) error {
+	current, err := dc.deploymentRegistry.Get(ctx, deployment.Name)
+	if err != nil {
+		return err
+	}
+
+	replicaSets, err := dc.replicaSetsOf(ctx, current)
+	if err != nil {
+		return err
+	}
+	hash := podTemplateHash(current.Spec.Template)
+	var newRS *api.ReplicaSet
+	var oldRSs []*api.ReplicaSet
+	for _, rs := range replicaSets {
+		if rs.Labels[api.PodTemplateHashLabel] == hash {
+			newRS = rs
+		} else {
+			oldRSs = append(oldRSs, rs)
+		}
+	}
+
+	counts := make(map[string]podCounts, len(replicaSets))
+	for _, rs := range replicaSets {
+		if counts[rs.Name], err = dc.countPods(ctx, rs); err != nil {
+			return err
+		}
+	}
+
+	desired := current.Spec.Replicas
+	maxSurge, maxUnavailable := current.Spec.Strategy.RollingUpdateLimits()
+	total := int32(0)
+	for _, rs := range replicaSets {
+		total += rs.Spec.Replicas
+	}
+
+	// Scale the ReplicaSet of the template up as far as the surge allows
+	if newRS == nil {
+		replicas := min(desired, max(desired+maxSurge-total, 0))
+		if newRS, err = dc.createReplicaSet(ctx, current, hash, replicas); err != nil {
+			return err
+		}
+		total += replicas
+	} else if replicas := newReplicaSetReplicas(newRS.Spec.Replicas, desired, maxSurge, total); replicas != newRS.Spec.Replicas {
+		total += replicas - newRS.Spec.Replicas
+		if err := dc.scale(ctx, newRS, replicas); err != nil {
+			return err
+		}
+	}
+
+	// Old pods that aren't available are removed first, as that doesn't reduce availability,
+	// then available old pods as long as enough pods stay available
+	minAvailable := desired - maxUnavailable
+	newUnavailable := newRS.Spec.Replicas - counts[newRS.Name].availableOf(newRS)
+	maxCleanup := total - minAvailable - newUnavailable
+	for _, rs := range oldRSs {
+		unavailable := rs.Spec.Replicas - counts[rs.Name].availableOf(rs)
+		if cleanup := min(maxCleanup, unavailable); cleanup > 0 {
+			maxCleanup -= cleanup
+			if err := dc.scale(ctx, rs, rs.Spec.Replicas-cleanup); err != nil {
+				return err
+			}
+		}
+	}
+
+	scaleDown := -minAvailable
+	for _, rs := range replicaSets {
+		scaleDown += counts[rs.Name].availableOf(rs)
+	}
+	for _, rs := range oldRSs {
+		if scaleDown <= 0 {
+			break
+		}
+		if count := min(scaleDown, rs.Spec.Replicas); count > 0 {
+			scaleDown -= count
+			if err := dc.scale(ctx, rs, rs.Spec.Replicas-count); err != nil {
+				return err
+			}
+		}
+	}
+
+	status := api.DeploymentStatus{UpdatedReplicas: counts[newRS.Name].active}
+	for _, count := range counts {
+		status.Replicas += count.active
+		status.AvailableReplicas += count.available
+	}
+	if status == current.Status {
+		return nil
+	}
+	_, err = dc.deploymentRegistry.UpdateStatus(ctx, current.Name, status)
+	return err
+}
+
+// newReplicaSetReplicas returns the replicas of the ReplicaSet of the current template: the
+// desired replicas, but no more than the surge allows above the total of all ReplicaSets
+func newReplicaSetReplicas(current, desired, maxSurge, total int32) int32 {
+	if current >= desired {
+		return desired
+	}
+	return min(desired, current+max(desired+maxSurge-total, 0))
+}
+
+// podCounts counts the pods of a ReplicaSet
+type podCounts struct {
+	active    int32
+	available int32
+}
+
+// availableOf returns the available pods, counting no more than the ReplicaSet is scaled to
+// as the pods it scales down may not be deleted yet
+func (c podCounts) availableOf(rs *api.ReplicaSet) int32 {
+	return min(c.available, rs.Spec.Replicas)
+}
+
+// countPods counts the active pods of the ReplicaSet, and the ones that are available because
+// they run and aren't terminating
+func (dc *DeploymentController) countPods(ctx context.Context, rs *api.ReplicaSet) (podCounts, error) {
+	pods, err := dc.podRegistry.ListPodsByOwner(ctx, rs.UID)
+	if err != nil {
+		return podCounts{}, err
+	}
+
+	var counts podCounts
+	for _, pod := range pods {
+		if !api.IsPodActiveAndOwnedBy(pod, &rs.ObjectMeta) {
+			continue
+		}
+		counts.active++
+		if pod.Status.Phase == api.PodRunning && !pod.IsTerminating() {
+			counts.available++
+		}
+	}
+	return counts, nil
+}
+
+// replicaSetsOf returns the ReplicaSets controlled by the Deployment, oldest first
+func (dc *DeploymentController) replicaSetsOf(ctx context.Context, deployment *api.Deployment) ([]*api.ReplicaSet, error) {
+	all, err := dc.replicaSetRegistry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicaSets []*api.ReplicaSet
+	for _, rs := range all {
+		if ref := api.GetControllerOf(&rs.ObjectMeta); ref != nil && ref.RefersTo(&deployment.ObjectMeta, api.KindDeployment) {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	slices.SortStableFunc(replicaSets, func(a, b *api.ReplicaSet) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp)
+	})
+	return replicaSets, nil
+}
+
+// createReplicaSet creates the ReplicaSet of the pod template of the Deployment. It is named
+// and labeled after the hash of the template, which is added to its selector as well.
+func (dc *DeploymentController) createReplicaSet(ctx context.Context, deployment *api.Deployment, hash string, replicas int32) (*api.ReplicaSet, error) {
+	labels := withPodTemplateHash(deployment.Spec.Template.Labels, hash)
+	rs := &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{
+			Name:            deployment.Name + "-" + hash,
+			Namespace:       deployment.Namespace,
+			Labels:          labels,
+			OwnerReferences: []api.OwnerReference{api.NewControllerRef(&deployment.ObjectMeta, api.KindDeployment)},
+		},
+		Spec: api.ReplicaSetSpec{
+			Replicas: replicas,
+			Selector: withPodTemplateHash(deployment.Spec.Selector, hash),
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{Labels: copyLabels(labels)},
+				Spec:       deployment.Spec.Template.Spec,
+			},
+		},
+	}
+	if err := dc.replicaSetRegistry.Create(ctx, rs); err != nil {
+		return nil, fmt.Errorf("failed to create ReplicaSet of Deployment %s: %w", deployment.Name, err)
+	}
+	log.Printf("Deployment %s created ReplicaSet %s with %d replicas", deployment.Name, rs.Name, replicas)
+	return rs, nil
+}
+
+// scale updates the replicas of the ReplicaSet, the ReplicaSetController creates or deletes
+// its pods. The ReplicaSet is only written if it is still at the resource version it was read
+// at, ErrReplicaSetConflict is returned otherwise.
+func (dc *DeploymentController) scale(ctx context.Context, rs *api.ReplicaSet, replicas int32) error {
+	revision, err := storage.ResourceVersionOf(rs)
+	if err != nil {
+		return err
+	}
+	log.Printf("Scaling ReplicaSet %s from %d to %d replicas", rs.Name, rs.Spec.Replicas, replicas)
+	rs.Spec.Replicas = replicas
+	if err := dc.replicaSetRegistry.UpdateAtRevision(ctx, rs, revision); err != nil {
+		return fmt.Errorf("failed to scale ReplicaSet %s: %w", rs.Name, err)
+	}
+	return nil
+}
+
+// podTemplateHash returns the hash of the pod template, which identifies the ReplicaSet
+// running it
+func podTemplateHash(template api.PodTemplateSpec) string {
+	data, err := runtime.Encode(template)
+	if err != nil {
+		// A template that was decoded from JSON always encodes
+		panic(fmt.Sprintf("failed to encode pod template: %v", err))
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write(data)
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// withPodTemplateHash returns a copy of the labels with the pod template hash label set
+func withPodTemplateHash(labels map[string]string, hash string) map[string]string {
+	copied := copyLabels(labels)
+	if copied == nil {
+		copied = make(map[string]string, 1)
+	}
+	copied[api.PodTemplateHashLabel] = hash
+	return copied
+}
+
+// Start reconciles Deployments until the context is done. With Endpoints set, Deployments,
+// ReplicaSets and pods are watched and a Deployment is queued whenever it, one of its
+// ReplicaSets or one of their pods changes. Every ResyncPeriod all Deployments are queued as well.
+func (dc *DeploymentController) Start(ctx context.Context) {
+	queue := workqueue.New[string]()
+	defer queue.ShutDown()
+
+	if len(dc.opts.Endpoints) > 0 {
+		if err := dc.startInformers(ctx, queue); err != nil {
+			log.Printf("Error watching Deployments, only the full sweep reconciles Deployments: %v", err)
+		}
+	}
+
+	for i := 0; i < max(dc.opts.Workers, 1); i++ {
+		go dc.runWorker(ctx, queue)
+	}
+
+	ticker := time.NewTicker(dc.opts.ResyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := dc.resync(ctx, queue); err != nil {
+				log.Printf("Error resyncing Deployments: %v", err)
+			}
+		}
+	}
+}
+
+// startInformers watches Deployments, ReplicaSets and pods, queueing the Deployments affected
+// by a change
+func (dc *DeploymentController) startInformers(ctx context.Context, queue *workqueue.Queue[string]) error {
+	deploymentWatch, err := listwatch.NewListWatch(dc.opts.Endpoints, deploymentWatchPrefix, dc.opts.ListWatch, logger{})
+	if err != nil {
+		return fmt.Errorf("failed to watch Deployments: %w", err)
+	}
+	rsWatch, err := listwatch.NewListWatch(dc.opts.Endpoints, replicaSetWatchPrefix, dc.opts.ListWatch, logger{})
+	if err != nil {
+		return fmt.Errorf("failed to watch ReplicaSets: %w", err)
+	}
+	podWatch, err := listwatch.NewListWatch(dc.opts.Endpoints, podWatchPrefix, dc.opts.ListWatch, logger{})
+	if err != nil {
+		return fmt.Errorf("failed to watch pods: %w", err)
+	}
+
+	enqueueDeployment := func(deployment *api.Deployment) { queue.Add(deployment.Name) }
+	deployments := listwatch.NewInformer(deploymentWatch, func() *api.Deployment { return &api.Deployment{} },
+		listwatch.ResourceEventHandlerFuncs[*api.Deployment]{
+			OnAdd:    enqueueDeployment,
+			OnUpdate: func(_, deployment *api.Deployment) { enqueueDeployment(deployment) },
+			OnDelete: enqueueDeployment,
+		})
+
+	enqueueOwner := func(meta *api.ObjectMeta) {
+		if ref := api.GetControllerOf(meta); ref != nil && ref.Kind == api.KindDeployment {
+			queue.Add(ref.Name)
+		}
+	}
+	enqueueReplicaSet := func(rs *api.ReplicaSet) { enqueueOwner(&rs.ObjectMeta) }
+	replicaSets := listwatch.NewInformer(rsWatch, func() *api.ReplicaSet { return &api.ReplicaSet{} },
+		listwatch.ResourceEventHandlerFuncs[*api.ReplicaSet]{
+			OnAdd:    enqueueReplicaSet,
+			OnUpdate: func(_, rs *api.ReplicaSet) { enqueueReplicaSet(rs) },
+			OnDelete: enqueueReplicaSet,
+		})
+
+	// A pod becoming available lets the rollout of the Deployment of its ReplicaSet go on
+	enqueuePod := func(pod *api.Pod) {
+		ref := api.GetControllerOf(&pod.ObjectMeta)
+		if ref == nil || ref.Kind != api.KindReplicaSet {
+			return
+		}
+		if rs, ok := replicaSets.GetByKey(replicaSetWatchPrefix + ref.Name); ok {
+			enqueueOwner(&rs.ObjectMeta)
+		}
+	}
+	pods := listwatch.NewInformer(podWatch, func() *api.Pod { return &api.Pod{} },
+		listwatch.ResourceEventHandlerFuncs[*api.Pod]{
+			OnAdd:    enqueuePod,
+			OnUpdate: func(_, pod *api.Pod) { enqueuePod(pod) },
+			OnDelete: enqueuePod,
+		})
+
+	for name, informer := range map[string]interface{ Run(context.Context) error }{
+		"Deployments": deployments,
+		"ReplicaSets": replicaSets,
+		"pods":        pods,
+	} {
+		go func() {
+			if err := informer.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error watching %s: %v", name, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// runWorker reconciles the queued Deployments until the queue is shut down. A Deployment that
+// fails to reconcile is queued again with backoff.
+func (dc *DeploymentController) runWorker(ctx context.Context, queue *workqueue.Queue[string]) {
+	for processNextItem(ctx, queue, "Deployment", dc.sync) {
+	}
+}
+
+// sync reconciles the named Deployment. The ReplicaSets of a deleted Deployment are left as
+// they are. A Deployment whose ReplicaSet was changed during the reconcile is requeued.
+func (dc *DeploymentController) sync(ctx context.Context, name string) (Result, error) {
+	err := dc.Reconcile(ctx, &api.Deployment{ObjectMeta: api.ObjectMeta{Name: name}})
+	switch {
+	case errors.Is(err, registry.ErrDeploymentNotFound):
+		return Result{}, nil
+	case errors.Is(err, registry.ErrConflict):
+		// A ReplicaSet changed since it was read, reconcile again from the changed state
+		log.Printf("Deployment %s changed while reconciling, requeueing: %v", name, err)
+		return Result{Requeue: true}, nil
+	}
+	return Result{}, err
+}
+
+// resync queues every Deployment
+func (dc *DeploymentController) resync(ctx context.Context, queue *workqueue.Queue[string]) error {
+	deployments, err := dc.deploymentRegistry.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, deployment := range deployments {
+		queue.Add(deployment.Name)
+	}
+	return nil
+}
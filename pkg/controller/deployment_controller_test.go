@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestDeploymentRollingUpdate(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		deploymentRegistry := registry.NewDeploymentRegistry(etcdStorage)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		dc := NewDeploymentController(deploymentRegistry, replicaSetRegistry, podRegistry)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		deployment := &api.Deployment{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec: api.DeploymentSpec{
+				Replicas: 3,
+				Selector: map[string]string{"app": "web"},
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "web"}},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx:latest"}}},
+				},
+				Strategy: api.DeploymentStrategy{MaxSurge: 1, MaxUnavailable: 0},
+			},
+		}
+		require.NoError(t, deploymentRegistry.Create(ctx, deployment))
+
+		// step reconciles the Deployment and its ReplicaSets, then starts the new pods like a
+		// kubelet would. It returns the pods, and how many of them were running already.
+		step := func() (total, running int) {
+			require.NoError(t, dc.Reconcile(ctx, deployment))
+			replicaSets, err := replicaSetRegistry.List(ctx)
+			require.NoError(t, err)
+			for _, rs := range replicaSets {
+				require.NoError(t, rsc.Reconcile(ctx, rs))
+			}
+
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			for _, pod := range pods {
+				if pod.Status.Phase == api.PodRunning {
+					running++
+					continue
+				}
+				pod.Status.Phase = api.PodRunning
+				_, err := podRegistry.UpdatePodStatus(ctx, pod)
+				require.NoError(t, err)
+			}
+			return len(pods), running
+		}
+		images := func() map[string]int {
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			images := make(map[string]int)
+			for _, pod := range pods {
+				images[pod.Spec.Containers[0].Image]++
+			}
+			return images
+		}
+		converge := func() {
+			for range 20 {
+				pods, running := step()
+				// The rollout stays within MaxSurge and MaxUnavailable
+				assert.LessOrEqual(t, pods, 4)
+				assert.GreaterOrEqual(t, running, 3)
+
+				current, err := deploymentRegistry.Get(ctx, "web")
+				require.NoError(t, err)
+				if current.Status == (api.DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3}) {
+					return
+				}
+			}
+			t.Fatal("Deployment did not converge")
+		}
+
+		// The first pods are created at once
+		step()
+		assert.Equal(t, map[string]int{"docker.io/library/nginx:latest": 3}, images())
+		converge()
+
+		current, err := deploymentRegistry.Get(ctx, "web")
+		require.NoError(t, err)
+		current.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+		require.NoError(t, deploymentRegistry.Update(ctx, current))
+		converge()
+
+		assert.Equal(t, map[string]int{"docker.io/library/nginx:1.19": 3}, images())
+		replicaSets, err := replicaSetRegistry.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, replicaSets, 2)
+		for _, rs := range replicaSets {
+			assert.True(t, api.GetControllerOf(&rs.ObjectMeta).RefersTo(&current.ObjectMeta, api.KindDeployment))
+			assert.Equal(t, rs.Spec.Selector[api.PodTemplateHashLabel], rs.Labels[api.PodTemplateHashLabel])
+			if rs.Spec.Template.Spec.Containers[0].Image == "nginx:1.19" {
+				assert.Equal(t, int32(3), rs.Spec.Replicas)
+			} else {
+				assert.Equal(t, int32(0), rs.Spec.Replicas, "old ReplicaSet %s is scaled to zero", rs.Name)
+				assert.Equal(t, int32(0), rs.Status.Replicas)
+			}
+		}
+	})
+}
+
+func TestDeploymentControllerKeepsConcurrentReplicaSetChanges(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		deploymentRegistry := registry.NewDeploymentRegistry(etcdStorage)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		dc := NewDeploymentController(deploymentRegistry, replicaSetRegistry, podRegistry)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		deployment := &api.Deployment{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec: api.DeploymentSpec{
+				Replicas: 2,
+				Selector: map[string]string{"app": "web"},
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "web"}},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx:latest"}}},
+				},
+			},
+		}
+		require.NoError(t, deploymentRegistry.Create(ctx, deployment))
+		require.NoError(t, dc.Reconcile(ctx, deployment))
+
+		replicaSets, err := replicaSetRegistry.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, replicaSets, 1)
+		stale := replicaSets[0]
+
+		scaled := *stale
+		scaled.Spec.Replicas = 4
+		require.NoError(t, replicaSetRegistry.Update(ctx, &scaled))
+
+		t.Run("should not scale a ReplicaSet changed since it was read", func(t *testing.T) {
+			staleCopy := *stale
+			err := dc.scale(ctx, &staleCopy, 1)
+			assert.ErrorIs(t, err, registry.ErrReplicaSetConflict)
+
+			stored, err := replicaSetRegistry.Get(ctx, stale.Name)
+			require.NoError(t, err)
+			assert.Equal(t, int32(4), stored.Spec.Replicas)
+		})
+
+		t.Run("should keep the replicas when writing the status of the ReplicaSet", func(t *testing.T) {
+			staleCopy := *stale
+			staleCopy.Status.Replicas = 2
+			require.NoError(t, rsc.updateStatus(ctx, &staleCopy))
+
+			stored, err := replicaSetRegistry.Get(ctx, stale.Name)
+			require.NoError(t, err)
+			assert.Equal(t, int32(4), stored.Spec.Replicas)
+			assert.Equal(t, int32(2), stored.Status.Replicas)
+		})
+
+		t.Run("should keep the spec when writing the status of the Deployment", func(t *testing.T) {
+			current, err := deploymentRegistry.Get(ctx, "web")
+			require.NoError(t, err)
+			current.Spec.Replicas = 5
+			require.NoError(t, deploymentRegistry.Update(ctx, current))
+
+			_, err = deploymentRegistry.UpdateStatus(ctx, "web", api.DeploymentStatus{Replicas: 2})
+			require.NoError(t, err)
+
+			stored, err := deploymentRegistry.Get(ctx, "web")
+			require.NoError(t, err)
+			assert.Equal(t, int32(5), stored.Spec.Replicas)
+			assert.Equal(t, int32(2), stored.Status.Replicas)
+		})
+	})
+}
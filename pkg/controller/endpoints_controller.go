@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// EndpointsController keeps the endpoints of a label selector, the names of the ready pods
+// matching it, up to date by watching pods. Pods that aren't ready or are terminating never
+// receive traffic, so they are left out.
+//
+// The endpoints are looked up from a ReadyPodCache, which indexes the ready pods by label.
+type EndpointsController struct {
+	cache    *registry.ReadyPodCache
+	selector map[string]string
+}
+
+// NewEndpointsController creates an EndpointsController whose Endpoints are the pods matching
+// the selector. The endpoints of any other selector are served by EndpointsFor.
+func NewEndpointsController(podRegistry *registry.PodRegistry, selector map[string]string) *EndpointsController {
+	return &EndpointsController{
+		cache:    registry.NewReadyPodCache(podRegistry),
+		selector: selector,
+	}
+}
+
+// Endpoints returns the names of the ready pods matching the selector of the controller, sorted
+func (c *EndpointsController) Endpoints() []string {
+	return c.cache.EndpointsFor(api.NamespaceAll, c.selector)
+}
+
+// EndpointsFor returns the names of the ready pods of the namespace, or of all namespaces for
+// NamespaceAll, that match the selector, sorted
+func (c *EndpointsController) EndpointsFor(namespace string, selector map[string]string) []string {
+	return c.cache.EndpointsFor(namespace, selector)
+}
+
+// HasSynced reports whether the pods have been listed into the cache
+func (c *EndpointsController) HasSynced() bool {
+	return c.cache.HasSynced()
+}
+
+// Start syncs the endpoints with the current pods, then updates them as pods change until the
+// context is done. A closed watch is resumed by listing the pods again.
+func (c *EndpointsController) Start(ctx context.Context) error {
+	return c.cache.Run(ctx)
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func setPodReady(t *testing.T, podRegistry *registry.PodRegistry, name string, status api.ConditionStatus) {
+	t.Helper()
+	pod, err := podRegistry.GetPod(context.Background(), api.NamespaceDefault, name)
+	require.NoError(t, err)
+	pod.Status.Conditions = []api.Condition{{Type: api.PodConditionReady, Status: status}}
+	_, err = podRegistry.UpdatePodStatus(context.Background(), pod)
+	require.NoError(t, err)
+}
+
+func TestEndpointsControllerTracksReadyPods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for _, pod := range []*api.Pod{
+			{
+				ObjectMeta: api.ObjectMeta{Name: "web-1", Labels: map[string]string{"app": "web"}, Finalizers: []string{"test"}},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx"}}},
+			},
+			{
+				ObjectMeta: api.ObjectMeta{Name: "other-1", Labels: map[string]string{"app": "other"}},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "other", Image: "nginx"}}},
+			},
+		} {
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		}
+		setPodReady(t, podRegistry, "other-1", api.ConditionTrue)
+
+		ec := NewEndpointsController(podRegistry, map[string]string{"app": "web"})
+		go func() { _ = ec.Start(ctx) }()
+
+		endpointsAre := func(expected ...string) func() bool {
+			return func() bool { return assert.ObjectsAreEqual(append([]string{}, expected...), ec.Endpoints()) }
+		}
+
+		// A pod without a Ready condition gets no traffic
+		assert.Never(t, func() bool { return len(ec.Endpoints()) > 0 }, 300*time.Millisecond, 50*time.Millisecond)
+
+		setPodReady(t, podRegistry, "web-1", api.ConditionTrue)
+		assert.Eventually(t, endpointsAre("web-1"), 2*time.Second, 10*time.Millisecond, "ready pod should be added")
+
+		setPodReady(t, podRegistry, "web-1", api.ConditionFalse)
+		assert.Eventually(t, endpointsAre(), 2*time.Second, 10*time.Millisecond, "not ready pod should be removed")
+
+		setPodReady(t, podRegistry, "web-1", api.ConditionTrue)
+		assert.Eventually(t, endpointsAre("web-1"), 2*time.Second, 10*time.Millisecond, "pod ready again should be added")
+
+		// The finalizer keeps the pod stored, but a terminating pod gets no traffic
+		pod, err := podRegistry.MarkPodForDeletion(ctx, api.NamespaceDefault, "web-1")
+		require.NoError(t, err)
+		require.NotNil(t, pod)
+		assert.False(t, pod.IsReady())
+		assert.Eventually(t, endpointsAre(), 2*time.Second, 10*time.Millisecond, "terminating pod should be removed")
+	})
+}
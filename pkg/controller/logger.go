@@ -0,0 +1,14 @@
+package controller
+
+import "log"
+
+// logger adapts the standard library logger to the listwatch.Logger interface
+type logger struct{}
+
+func (logger) Info(msg string, keysAndValues ...interface{}) {
+	log.Println(append([]interface{}{"INFO", msg}, keysAndValues...)...)
+}
+
+func (logger) Error(msg string, keysAndValues ...interface{}) {
+	log.Println(append([]interface{}{"ERROR", msg}, keysAndValues...)...)
+}
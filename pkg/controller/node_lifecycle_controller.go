@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/registry"
+)
+
+// ReasonNodeNotReady is the reason of the PodScheduled condition of the pods rescheduled off
+// a node that stayed NotReady longer than the pod eviction timeout
+const ReasonNodeNotReady = "NodeNotReady"
+
+// NodeLifecycleController reschedules the pods of nodes that stopped being ready. Once a node
+// has been NotReady for PodEvictionTimeout its pods are unbound and go back to Pending, so that
+// the scheduler places them on the nodes that are still ready. A kubelet of a node that comes
+// back stops the pods that were moved away.
+type NodeLifecycleController struct {
+	nodeRegistry *registry.NodeRegistry
+	podRegistry  *registry.PodRegistry
+	opts         NodeLifecycleOptions
+
+	// notReadySince holds when the controller first saw a node NotReady that doesn't report
+	// since when it is, by the node name
+	notReadySince map[string]time.Time
+	// now returns the current time, tests replace it to control the clock
+	now func() time.Time
+}
+
+// NodeLifecycleOptions configures the NodeLifecycleController behavior
+type NodeLifecycleOptions struct {
+	// NodeMonitorPeriod is how often the readiness of the nodes is checked
+	NodeMonitorPeriod time.Duration
+	// PodEvictionTimeout is how long a node may be NotReady before its pods are rescheduled on
+	// other nodes
+	PodEvictionTimeout time.Duration
+}
+
+// DefaultNodeLifecycleOptions returns the default NodeLifecycleController configuration
+func DefaultNodeLifecycleOptions() NodeLifecycleOptions {
+	return NodeLifecycleOptions{
+		NodeMonitorPeriod:  5 * time.Second,
+		PodEvictionTimeout: 5 * time.Minute,
+	}
+}
+
+// NewNodeLifecycleController creates a new NodeLifecycleController
+func NewNodeLifecycleController(nodeRegistry *registry.NodeRegistry, podRegistry *registry.PodRegistry) *NodeLifecycleController {
+	return NewNodeLifecycleControllerWithOptions(nodeRegistry, podRegistry, DefaultNodeLifecycleOptions())
+}
+
+// NewNodeLifecycleControllerWithOptions creates a NodeLifecycleController with the given configuration
+func NewNodeLifecycleControllerWithOptions(nodeRegistry *registry.NodeRegistry, podRegistry *registry.PodRegistry, opts NodeLifecycleOptions) *NodeLifecycleController {
+	return &NodeLifecycleController{
+		nodeRegistry:  nodeRegistry,
+		podRegistry:   podRegistry,
+		opts:          opts,
+		notReadySince: make(map[string]time.Time),
+		now:           time.Now,
+	}
+}
+
+// Start checks the nodes every NodeMonitorPeriod until the context is done
+func (nc *NodeLifecycleController) Start(ctx context.Context) {
+	ticker := time.NewTicker(nc.opts.NodeMonitorPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := nc.monitorNodes(ctx); err != nil {
+				log.Printf("Error monitoring nodes: %v", err)
+			}
+		}
+	}
+}
+
+// monitorNodes reschedules the pods of the nodes that have been NotReady for longer than
+// PodEvictionTimeout
+func (nc *NodeLifecycleController) monitorNodes(ctx context.Context) error {
+	nodes, err := nc.nodeRegistry.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(nodes))
+	var errs []error
+	for _, node := range nodes {
+		seen[node.Name] = true
+		since, notReady := nc.notReadyTime(node)
+		if !notReady {
+			continue
+		}
+
+		notReadyFor := nc.now().Sub(since)
+		if notReadyFor <= nc.opts.PodEvictionTimeout {
+			continue
+		}
+		if err := nc.reschedulePods(ctx, node, notReadyFor); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Forget the nodes that were deleted
+	for name := range nc.notReadySince {
+		if !seen[name] {
+			delete(nc.notReadySince, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notReadyTime reports whether the node is NotReady and since when. The transition time of the
+// Ready condition is used when the node reports it, otherwise the time the controller first saw
+// the node NotReady.
+func (nc *NodeLifecycleController) notReadyTime(node *api.Node) (time.Time, bool) {
+	if node.IsReady() {
+		delete(nc.notReadySince, node.Name)
+		return time.Time{}, false
+	}
+
+	if ready := conditions.GetCondition(node.Conditions, api.NodeConditionReady); ready != nil && !ready.LastTransitionTime.IsZero() {
+		return ready.LastTransitionTime, true
+	}
+
+	since, ok := nc.notReadySince[node.Name]
+	if !ok {
+		since = nc.now()
+		nc.notReadySince[node.Name] = since
+	}
+	return since, true
+}
+
+// reschedulePods unbinds the pods of the node so that they are scheduled on another node.
+// Pods that finished are left as they are, as are terminating pods, which are deleted anyway.
+func (nc *NodeLifecycleController) reschedulePods(ctx context.Context, node *api.Node, notReadyFor time.Duration) error {
+	pods, err := nc.podRegistry.ListPodsByNode(ctx, node.Name)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("node %s has been not ready for %v", node.Name, notReadyFor.Round(time.Second))
+	var errs []error
+	for _, pod := range pods {
+		if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		_, err := nc.podRegistry.ReschedulePod(ctx, pod.Namespace, pod.Name, node.Name, ReasonNodeNotReady, message)
+		switch {
+		case err == nil:
+			log.Printf("Rescheduling pod %s off node %s, %s", pod.Name, node.Name, message)
+		case errors.Is(err, registry.ErrPodNotOnNode), errors.Is(err, registry.ErrPodNotFound):
+			// The pod was moved, finished or deleted in the meantime
+		default:
+			errs = append(errs, fmt.Errorf("failed to reschedule pod %s: %w", pod.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestNodeLifecycleControllerReschedulesPodsOfNotReadyNodes(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		podRegistry := registry.NewPodRegistry(store)
+		ctx := context.Background()
+
+		for _, name := range []string{"node-1", "node-2"} {
+			require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: name}, Status: api.NodeReady}))
+		}
+		for pod, node := range map[string]string{"web-1": "node-1", "web-2": "node-1", "web-3": "node-2"} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: pod},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx"}}},
+			}))
+			_, err := podRegistry.BindPod(ctx, api.NamespaceDefault, pod, node)
+			require.NoError(t, err)
+		}
+
+		// node-1 misses its heartbeats and is flipped to NotReady
+		notReadyAt := time.Now().UTC().Truncate(time.Second)
+		_, err := nodeRegistry.UpdateNodeStatus(ctx, &api.Node{
+			ObjectMeta: api.ObjectMeta{Name: "node-1"},
+			Status:     api.NodeNotReady,
+			Conditions: []api.Condition{{
+				Type:               api.NodeConditionReady,
+				Status:             api.ConditionFalse,
+				LastTransitionTime: notReadyAt,
+			}},
+		})
+		require.NoError(t, err)
+
+		opts := DefaultNodeLifecycleOptions()
+		opts.PodEvictionTimeout = time.Minute
+		nc := NewNodeLifecycleControllerWithOptions(nodeRegistry, podRegistry, opts)
+		clock := notReadyAt
+		nc.now = func() time.Time { return clock }
+
+		nodeOf := func(name string) string {
+			pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, name)
+			require.NoError(t, err)
+			return pod.NodeName
+		}
+
+		// Before the timeout the pods stay on the node
+		clock = notReadyAt.Add(opts.PodEvictionTimeout - time.Second)
+		require.NoError(t, nc.monitorNodes(ctx))
+		assert.Equal(t, "node-1", nodeOf("web-1"))
+		assert.Equal(t, "node-1", nodeOf("web-2"))
+
+		// After the timeout they are rescheduled, the pods of ready nodes are left alone
+		clock = notReadyAt.Add(opts.PodEvictionTimeout + time.Second)
+		require.NoError(t, nc.monitorNodes(ctx))
+		for _, name := range []string{"web-1", "web-2"} {
+			pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, name)
+			require.NoError(t, err)
+			assert.Empty(t, pod.NodeName)
+			assert.Equal(t, api.PodPending, pod.Status.Phase)
+			scheduled := conditions.GetCondition(pod.Status.Conditions, api.PodConditionScheduled)
+			require.NotNil(t, scheduled)
+			assert.Equal(t, ReasonNodeNotReady, scheduled.Reason)
+		}
+		assert.Equal(t, "node-2", nodeOf("web-3"))
+
+		pending, err := podRegistry.ListPendingPods(ctx)
+		require.NoError(t, err)
+		assert.Len(t, pending, 2)
+	})
+}
+
+func TestNodeLifecycleControllerTracksNodesWithoutReadyCondition(t *testing.T) {
+	nc := NewNodeLifecycleController(nil, nil)
+	clock := time.Now()
+	nc.now = func() time.Time { return clock }
+	node := &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1"}, Status: api.NodeNotReady}
+
+	since, notReady := nc.notReadyTime(node)
+	assert.True(t, notReady)
+	assert.Equal(t, clock, since)
+
+	// The time the node was first seen NotReady is kept
+	clock = clock.Add(time.Minute)
+	since, _ = nc.notReadyTime(node)
+	assert.Equal(t, clock.Add(-time.Minute), since)
+
+	// A node that is ready again starts over
+	node.Status = api.NodeReady
+	_, notReady = nc.notReadyTime(node)
+	assert.False(t, notReady)
+	assert.Empty(t, nc.notReadySince)
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gokube/pkg/workqueue"
+)
+
+// Result tells the worker what to do with an item that was reconciled without error
+type Result struct {
+	// Requeue reconciles the item again with backoff, such as after a write that conflicted
+	// with a concurrent change, without reporting an error
+	Requeue bool
+	// RequeueAfter reconciles the item again once the delay has passed, such as when waiting
+	// for something that happens at a known time. Zero leaves the item until it changes again.
+	RequeueAfter time.Duration
+}
+
+// reconcileFunc reconciles the object of the name
+type reconcileFunc func(ctx context.Context, name string) (Result, error)
+
+// processNextItem reconciles the next item of the queue. An item that fails to reconcile is
+// queued again with backoff, as is an item whose Result asks to be requeued. An item whose
+// Result asks for it is queued again after RequeueAfter. It returns false once the queue is shut down.
+func processNextItem(ctx context.Context, queue *workqueue.Queue[string], kind string, reconcile reconcileFunc) bool {
+	name, ok := queue.Get()
+	if !ok {
+		return false
+	}
+	defer queue.Done(name)
+
+	result, err := reconcile(ctx, name)
+	if err != nil {
+		log.Printf("Error reconciling %s %s: %v", kind, name, err)
+		queue.AddRateLimited(name)
+		return true
+	}
+
+	if result.Requeue {
+		queue.AddRateLimited(name)
+		return true
+	}
+	queue.Forget(name)
+	if result.RequeueAfter > 0 {
+		queue.AddAfter(name, result.RequeueAfter)
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gokube/pkg/retry"
+	"gokube/pkg/workqueue"
+)
+
+func TestProcessNextItem(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should reconcile the item again after RequeueAfter", func(t *testing.T) {
+		queue := workqueue.New[string]()
+		defer queue.ShutDown()
+		queue.Add("web")
+
+		const requeueAfter = 200 * time.Millisecond
+		var reconciled []time.Time
+		reconcile := func(ctx context.Context, name string) (Result, error) {
+			reconciled = append(reconciled, time.Now())
+			if len(reconciled) == 1 {
+				return Result{RequeueAfter: requeueAfter}, nil
+			}
+			return Result{}, nil
+		}
+
+		require.True(t, processNextItem(ctx, queue, "Test", reconcile))
+		assert.Zero(t, queue.Len(), "the item should not be queued before the delay")
+		require.True(t, processNextItem(ctx, queue, "Test", reconcile))
+
+		require.Len(t, reconciled, 2)
+		elapsed := reconciled[1].Sub(reconciled[0])
+		assert.GreaterOrEqual(t, elapsed, requeueAfter)
+		assert.Less(t, elapsed, requeueAfter+time.Second)
+
+		// Without RequeueAfter the item is left until it changes again
+		assert.Never(t, func() bool { return queue.Len() > 0 }, 2*requeueAfter, 20*time.Millisecond)
+	})
+
+	t.Run("should queue a failed item again with backoff", func(t *testing.T) {
+		queue := workqueue.NewWithOptions[string](retry.Options{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1})
+		defer queue.ShutDown()
+		queue.Add("web")
+
+		require.True(t, processNextItem(ctx, queue, "Test", func(ctx context.Context, name string) (Result, error) {
+			return Result{RequeueAfter: time.Hour}, errors.New("failed")
+		}))
+		assert.Equal(t, 1, queue.NumRequeues("web"))
+		assert.Eventually(t, func() bool { return queue.Len() == 1 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("should queue an item asking to be requeued again with backoff", func(t *testing.T) {
+		queue := workqueue.NewWithOptions[string](retry.Options{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1})
+		defer queue.ShutDown()
+		queue.Add("web")
+
+		require.True(t, processNextItem(ctx, queue, "Test", func(ctx context.Context, name string) (Result, error) {
+			return Result{Requeue: true}, nil
+		}))
+		assert.Equal(t, 1, queue.NumRequeues("web"))
+		assert.Eventually(t, func() bool { return queue.Len() == 1 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("should stop once the queue is shut down", func(t *testing.T) {
+		queue := workqueue.New[string]()
+		queue.ShutDown()
+		assert.False(t, processNextItem(ctx, queue, "Test", func(ctx context.Context, name string) (Result, error) {
+			t.Fatal("nothing should be reconciled")
+			return Result{}, nil
+		}))
+	})
+}
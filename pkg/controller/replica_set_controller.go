@@ -2,26 +2,92 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/listwatch"
 	"gokube/pkg/registry"
 	"gokube/pkg/registry/names"
+	"gokube/pkg/workqueue"
 )
 
+// maxPodNameAttempts bounds how many names are generated for a pod when the generated names
+// are taken already
+const maxPodNameAttempts = 5
+
 // ReplicaSetController manages the lifecycle of ReplicaSets
 type ReplicaSetController struct {
 	replicaSetRegistry *registry.ReplicaSetRegistry
 	podRegistry        *registry.PodRegistry
+	// nameGenerator generates the names of the pods created from a ReplicaSet
+	nameGenerator names.NameGenerator
+	opts          Options
+}
+
+// Options configures the ReplicaSetController behavior
+type Options struct {
+	// ResyncPeriod is the interval of the full sweep that reconciles every ReplicaSet, catching
+	// changes that were missed otherwise
+	ResyncPeriod time.Duration
+	// Endpoints are the etcd endpoints ReplicaSets and pods are watched on, so that a ReplicaSet
+	// is reconciled as soon as it or one of its pods changes. Without endpoints ReplicaSets are
+	// only reconciled by the full sweep.
+	Endpoints []string
+	// ListWatch configures the watches of ReplicaSets and pods
+	ListWatch listwatch.Options
+	// Workers is the number of ReplicaSets reconciled concurrently
+	Workers int
+	// MaxPodSurge is how many pods a ReplicaSet may have beyond its replicas after a reconcile
+	// created pods. A reconcile never creates more, guarding against pods being created without
+	// bound, such as by creates retried after they went through.
+	MaxPodSurge int
+	// Events records the events of the ReplicaSets, such as the pods created and deleted for
+	// them. Nil records no events.
+	Events *registry.EventRegistry
+}
+
+// DefaultOptions returns the default ReplicaSetController configuration
+func DefaultOptions() Options {
+	return Options{
+		ResyncPeriod: 30 * time.Second,
+		ListWatch:    listwatch.DefaultOptions(),
+		Workers:      2,
+		MaxPodSurge:  1,
+	}
 }
 
+// The etcd prefixes the registries store ReplicaSets and pods under
+const (
+	replicaSetWatchPrefix = "/replicasets/"
+	podWatchPrefix        = "/pods/"
+)
+
 // NewReplicaSetController creates a new ReplicaSetController
 func NewReplicaSetController(rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry) *ReplicaSetController {
+	return NewReplicaSetControllerWithOptions(rsRegistry, podRegistry, DefaultOptions())
+}
+
+// NewReplicaSetControllerWithOptions creates a ReplicaSetController with the given configuration
+func NewReplicaSetControllerWithOptions(rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry, opts Options) *ReplicaSetController {
+	rsc := NewReplicaSetControllerWithNameGenerator(rsRegistry, podRegistry, names.SimpleNameGenerator)
+	rsc.opts = opts
+	return rsc
+}
+
+// NewReplicaSetControllerWithNameGenerator creates a ReplicaSetController that names the pods it
+// creates with the given generator
+func NewReplicaSetControllerWithNameGenerator(rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry, nameGenerator names.NameGenerator) *ReplicaSetController {
 	return &ReplicaSetController{
 		replicaSetRegistry: rsRegistry,
 		podRegistry:        podRegistry,
+		nameGenerator:      nameGenerator,
+		opts:               DefaultOptions(),
 	}
 }
 
@@ -32,14 +98,34 @@ func (rsc *ReplicaSetController) Reconcile(ctx context.Context, rs *api.ReplicaS
 		return err
 	}
 
-	// Get all pods
-	allPods, err := rsc.podRegistry.ListPods(ctx)
+	// A paused ReplicaSet keeps its pods as they are, only its status reports the pause
+	if currentRS.Spec.Paused {
+		paused := api.Condition{
+			Type:    api.ReplicaSetConditionPaused,
+			Status:  api.ConditionTrue,
+			Reason:  "Paused",
+			Message: "reconciliation is paused",
+		}
+		if conditions.SetCondition(&currentRS.Status.Conditions, paused) {
+			return rsc.updateStatus(ctx, currentRS)
+		}
+		return nil
+	}
+	resumed := conditions.RemoveCondition(&currentRS.Status.Conditions, api.ReplicaSetConditionPaused)
+
+	// Get active pods for this ReplicaSet
+	ownedPods, err := rsc.getPodsOwnedBy(ctx, currentRS)
+	if err != nil {
+		return err
+	}
+	activePods, err := rsc.getPodsForReplicaSet(currentRS, ownedPods, isPodCountedFor)
 	if err != nil {
 		return err
 	}
 
-	// Get active pods for this ReplicaSet
-	activePods, err := rsc.getPodsForReplicaSet(currentRS, allPods, api.IsPodActiveAndOwnedBy)
+	// Repair over-replication first, whatever caused it, so that the ReplicaSet converges to
+	// its replicas before any pod is adopted or created
+	activePods, repaired, err := rsc.deleteExcessPods(ctx, currentRS, activePods)
 	if err != nil {
 		return err
 	}
@@ -49,38 +135,221 @@ func (rsc *ReplicaSetController) Reconcile(ctx context.Context, rs *api.ReplicaS
 	desiredPodCount := int(currentRS.Spec.Replicas)
 
 	if currentPodCount < desiredPodCount {
-		// Create new pods
+		// Adopt matching pods without a controller in the namespace of the ReplicaSet before
+		// creating new ones
+		allPods, err := rsc.podRegistry.ListPodsInNamespace(ctx, api.NamespaceOrDefault(currentRS.Namespace))
+		if err != nil {
+			return err
+		}
+		adoptedPods, err := rsc.adoptOrphans(ctx, currentRS, allPods)
+		if err != nil {
+			return err
+		}
+		currentPodCount += len(adoptedPods)
+		activePods = append(activePods, adoptedPods...)
+	}
+
+	if currentPodCount < desiredPodCount {
+		// Create a pod with all the containers of the template per missing replica, but never
+		// more than the replicas and the surge allow
+		maxPods := desiredPodCount + max(rsc.opts.MaxPodSurge, 0)
+		budget := newPodCreationBudget(maxPods-currentPodCount, activePods)
+		created := 0
 		for i := currentPodCount; i < desiredPodCount; i++ {
-			for _, container := range currentRS.Spec.Template.Spec.Containers {
-				pod := &api.Pod{
-					ObjectMeta: api.ObjectMeta{
-						Name: generatePodNameFromReplicaSet(currentRS.Name),
-					},
-					Spec: api.PodSpec{
-						Containers: []api.Container{container},
-					},
-				}
-				if err := rsc.podRegistry.CreatePod(ctx, pod); err != nil {
-					return err
-				}
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{
+					Namespace:       currentRS.Namespace,
+					Labels:          copyLabels(currentRS.Spec.Template.Labels),
+					OwnerReferences: []api.OwnerReference{api.NewControllerRef(&currentRS.ObjectMeta, api.KindReplicaSet)},
+				},
+				Spec: podSpecFromTemplate(currentRS.Spec.Template.Spec),
+			}
+			err := rsc.createPod(ctx, currentRS, pod, budget)
+			if errors.Is(err, errPodCreationCapped) {
+				message := fmt.Sprintf("Not creating more pods, the ReplicaSet would have more than %d pods for %d replicas", maxPods, desiredPodCount)
+				log.Printf("ReplicaSet %s: %s", currentRS.Name, message)
+				rsc.recordEvent(ctx, currentRS, api.EventTypeWarning, "PodCreationCapped", message)
+				break
 			}
+			if err != nil {
+				return err
+			}
+			created++
 		}
-		currentPodCount = desiredPodCount //
+		currentPodCount += created
 		// Update ReplicaSet status
 		currentRS.Status.Replicas = int32(currentPodCount)
-		return rsc.replicaSetRegistry.Update(ctx, currentRS)
+		return rsc.updateStatus(ctx, currentRS)
+	}
 
-	} else if currentPodCount > desiredPodCount {
-		// TODO: Implement pod deletion logic if needed
-		currentPodCount = desiredPodCount
-		// Update ReplicaSet status
+	if repaired {
 		currentRS.Status.Replicas = int32(currentPodCount)
-		return rsc.replicaSetRegistry.Update(ctx, currentRS)
+		return rsc.updateStatus(ctx, currentRS)
+	}
+	if resumed {
+		return rsc.updateStatus(ctx, currentRS)
 	}
-
 	return nil
 }
 
+// updateStatus writes the status of the ReplicaSet alone, so that a change of its replicas made
+// during the reconcile, such as a scale by the Deployment controller, is kept
+func (rsc *ReplicaSetController) updateStatus(ctx context.Context, rs *api.ReplicaSet) error {
+	_, err := rsc.replicaSetRegistry.UpdateStatus(ctx, rs.Name, rs.Status)
+	return err
+}
+
+// deleteExcessPods detects a ReplicaSet with more active pods than its replicas and deletes
+// the excess, whether the ReplicaSet was scaled down or pods were duplicated, such as by a
+// reconcile that raced with another. The pods to delete are chosen deterministically, so that
+// reconciles of the same state agree on them. It returns the pods that remain and whether any
+// pod was deleted.
+func (rsc *ReplicaSetController) deleteExcessPods(ctx context.Context, rs *api.ReplicaSet, activePods []*api.Pod) ([]*api.Pod, bool, error) {
+	excess := len(activePods) - int(rs.Spec.Replicas)
+	if excess <= 0 {
+		return activePods, false, nil
+	}
+	log.Printf("ReplicaSet %s has %d active pods for %d replicas, deleting %d", rs.Name, len(activePods), rs.Spec.Replicas, excess)
+
+	// Delete the excess pods, the ones disrupting running workloads least first
+	excessPods := podsToDelete(activePods, excess)
+	for _, pod := range excessPods {
+		// Pods with finalizers are only marked, and no longer counted while they terminate
+		_, err := rsc.podRegistry.MarkPodForDeletion(ctx, pod.Namespace, pod.Name)
+		if errors.Is(err, registry.ErrPodNotFound) {
+			// The pod was deleted in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to delete pod %s of ReplicaSet %s: %w", pod.Name, rs.Name, err)
+		}
+		log.Printf("ReplicaSet %s deleted excess pod %s", rs.Name, pod.Name)
+		rsc.recordEvent(ctx, rs, api.EventTypeNormal, "SuccessfulDelete", "Deleted pod: "+pod.Name)
+	}
+
+	remaining := slices.DeleteFunc(slices.Clone(activePods), func(pod *api.Pod) bool {
+		return slices.Contains(excessPods, pod)
+	})
+	return remaining, true, nil
+}
+
+// errPodCreationCapped is returned by createPod once a reconcile has no pod creations left
+var errPodCreationCapped = errors.New("pod creation cap reached")
+
+// podCreationBudget is the number of pods a reconcile may still create for a ReplicaSet. The
+// pods of the ReplicaSet it didn't know of when it started, whether it created them or found
+// them under a generated name, are taken from it.
+type podCreationBudget struct {
+	remaining int
+	// known are the names of the pods of the ReplicaSet already accounted for
+	known map[string]bool
+}
+
+func newPodCreationBudget(remaining int, pods []*api.Pod) *podCreationBudget {
+	known := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		known[pod.Name] = true
+	}
+	return &podCreationBudget{remaining: remaining, known: known}
+}
+
+// take accounts for the pod of the ReplicaSet with the name
+func (b *podCreationBudget) take(name string) {
+	b.known[name] = true
+	b.remaining--
+}
+
+// isPodCountedFor reports whether the pod counts towards the replicas of the ReplicaSet: it is
+// active, controlled by the ReplicaSet and not terminating. A terminating pod is on its way out,
+// so it is replaced rather than deleted again.
+func isPodCountedFor(pod *api.Pod, meta *api.ObjectMeta) bool {
+	return api.IsPodActiveAndOwnedBy(pod, meta) && !pod.IsTerminating()
+}
+
+// createPod creates the pod under a name generated from the ReplicaSet name. When the generated
+// name is taken already a new one is generated, up to maxPodNameAttempts times, rather than
+// failing the reconcile. A taken name held by a pod of the ReplicaSet the reconcile didn't know
+// of, such as one created by a create that reported a failure although it went through, is taken
+// from the budget, and errPodCreationCapped is returned once the budget is used up.
+func (rsc *ReplicaSetController) createPod(ctx context.Context, rs *api.ReplicaSet, pod *api.Pod, budget *podCreationBudget) error {
+	var err error
+	for attempt := 0; attempt < maxPodNameAttempts; attempt++ {
+		if budget.remaining <= 0 {
+			return errPodCreationCapped
+		}
+
+		pod.Name = rsc.nameGenerator.GenerateName(rs.Name)
+		err = rsc.podRegistry.CreatePod(ctx, pod)
+		if err == nil {
+			budget.take(pod.Name)
+			rsc.recordEvent(ctx, rs, api.EventTypeNormal, "SuccessfulCreate", "Created pod: "+pod.Name)
+		}
+		if !errors.Is(err, registry.ErrPodAlreadyExists) {
+			return err
+		}
+
+		if existing, getErr := rsc.podRegistry.GetPod(ctx, pod.Namespace, pod.Name); getErr == nil &&
+			api.IsOwnedBy(existing, &rs.ObjectMeta) && !budget.known[pod.Name] {
+			log.Printf("Found pod %s of ReplicaSet %s under a generated name, counting it as created", pod.Name, rs.Name)
+			budget.take(pod.Name)
+		}
+		log.Printf("Generated pod name %s for ReplicaSet %s is taken, generating a new one", pod.Name, rs.Name)
+	}
+	return fmt.Errorf("failed to generate a free pod name for ReplicaSet %s: %w", rs.Name, err)
+}
+
+// recordEvent records an event of the ReplicaSet if the controller records events. Failing to
+// record it doesn't fail the reconcile, it is only logged.
+func (rsc *ReplicaSetController) recordEvent(ctx context.Context, rs *api.ReplicaSet, eventType, reason, message string) {
+	if rsc.opts.Events == nil {
+		return
+	}
+	event := &api.Event{
+		InvolvedObject: api.ObjectReference{Kind: api.KindReplicaSet, Name: rs.Name},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+	}
+	if err := rsc.opts.Events.Record(ctx, event); err != nil {
+		log.Printf("Failed to record event %s of ReplicaSet %s: %v", reason, rs.Name, err)
+	}
+}
+
+// podSpecFromTemplate returns the spec of a pod created from the template, which shares no
+// containers or node selector with the template
+func podSpecFromTemplate(template api.PodSpec) api.PodSpec {
+	spec := template
+	spec.Containers = slices.Clone(template.Containers)
+	spec.NodeSelector = copyLabels(template.NodeSelector)
+	return spec
+}
+
+// podsToDelete returns the count pods to delete when scaling down. Pods that aren't running yet,
+// because they are pending or unassigned, are deleted first, then the youngest pods. Pods
+// created at the same time are ordered by name, so the choice doesn't depend on the order the
+// pods were listed in.
+func podsToDelete(pods []*api.Pod, count int) []*api.Pod {
+	sorted := slices.Clone(pods)
+	slices.SortFunc(sorted, func(a, b *api.Pod) int {
+		if aStarted, bStarted := isStarted(a), isStarted(b); aStarted != bStarted {
+			if aStarted {
+				return 1
+			}
+			return -1
+		}
+		if c := b.CreationTimestamp.Compare(a.CreationTimestamp); c != 0 {
+			return c
+		}
+		return strings.Compare(b.Name, a.Name)
+	})
+	return sorted[:min(count, len(sorted))]
+}
+
+// isStarted checks if the pod was assigned to a node and is no longer pending
+func isStarted(pod *api.Pod) bool {
+	return pod.NodeName != "" && pod.Status.Phase != api.PodPending
+}
+
 func (rsc *ReplicaSetController) getPodsForReplicaSet(
 	rs *api.ReplicaSet,
 	allPods []*api.Pod,
@@ -96,12 +365,115 @@ func (rsc *ReplicaSetController) getPodsForReplicaSet(
 	return activePods, nil
 }
 
-func (rsc *ReplicaSetController) getPodsOwnedBy(rs *api.ReplicaSet, pods []*api.Pod) ([]*api.Pod, error) {
+// adoptOrphans adopts the active pods without a controller that match the ReplicaSet by
+// setting their controller reference, and returns the adopted pods.
+func (rsc *ReplicaSetController) adoptOrphans(ctx context.Context, rs *api.ReplicaSet, allPods []*api.Pod) ([]*api.Pod, error) {
+	var adoptedPods []*api.Pod
+	for _, pod := range allPods {
+		if !pod.IsActive() || api.GetControllerOf(&pod.ObjectMeta) != nil {
+			continue
+		}
+
+		if !matchesReplicaSet(rs, pod) {
+			continue
+		}
+
+		ref := api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet)
+		adopted, err := rsc.podRegistry.SetControllerRef(ctx, pod.Namespace, pod.Name, &ref)
+		if err != nil {
+			if errors.Is(err, registry.ErrPodAlreadyOwned) || errors.Is(err, registry.ErrPodNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		log.Printf("ReplicaSet %s adopted pod %s", rs.Name, pod.Name)
+		adoptedPods = append(adoptedPods, adopted)
+	}
+
+	return adoptedPods, nil
+}
+
+// matchesReplicaSet checks if a pod without a controller belongs to the ReplicaSet. Pods are
+// matched on the ReplicaSet selector, or on its template labels when it has no selector. A
+// ReplicaSet with neither matches no pod, rather than every pod of its namespace.
+func matchesReplicaSet(rs *api.ReplicaSet, pod *api.Pod) bool {
+	selector := rs.Spec.Selector
+	if len(selector) == 0 {
+		selector = rs.Spec.Template.Labels
+	}
+	return len(selector) > 0 && api.MatchesSelector(selector, pod.Labels)
+}
+
+// releaseOrphans removes the controller reference from pods whose ReplicaSet no longer exists,
+// so they can be adopted by another ReplicaSet.
+func (rsc *ReplicaSetController) releaseOrphans(ctx context.Context, replicaSets []*api.ReplicaSet) error {
+	pods, err := rsc.podRegistry.ListPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		ref := api.GetControllerOf(&pod.ObjectMeta)
+		if ref == nil || ref.Kind != api.KindReplicaSet {
+			continue
+		}
+
+		found := false
+		for _, rs := range replicaSets {
+			if ref.RefersTo(&rs.ObjectMeta, api.KindReplicaSet) {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		if _, err := rsc.podRegistry.SetControllerRef(ctx, pod.Namespace, pod.Name, nil); err != nil && !errors.Is(err, registry.ErrPodNotFound) {
+			return err
+		}
+		log.Printf("Released pod %s from deleted ReplicaSet %s", pod.Name, ref.Name)
+	}
+
+	return nil
+}
+
+// getPodsOwnedBy returns the pods controlled by the ReplicaSet using the per-owner index.
+// ReplicaSets without a UID fall back to filtering all pods.
+func (rsc *ReplicaSetController) getPodsOwnedBy(ctx context.Context, rs *api.ReplicaSet) ([]*api.Pod, error) {
+	var pods []*api.Pod
+	var err error
+	if rs.UID != "" {
+		pods, err = rsc.podRegistry.ListPodsByOwner(ctx, rs.UID)
+	} else {
+		pods, err = rsc.podRegistry.ListPods(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	return rsc.getPodsForReplicaSet(rs, pods, api.IsOwnedBy)
 }
 
+// Start reconciles ReplicaSets until the context is done. With Endpoints set, ReplicaSets and
+// pods are watched and a ReplicaSet is queued whenever it or one of its pods changes. Every
+// ResyncPeriod all ReplicaSets are queued as well, as a safety net for missed changes. Workers
+// reconcile the queued ReplicaSets, a ReplicaSet is never reconciled by two workers at once.
 func (rsc *ReplicaSetController) Start(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Second)
+	queue := workqueue.New[string]()
+	defer queue.ShutDown()
+
+	if len(rsc.opts.Endpoints) > 0 {
+		if err := rsc.startInformers(ctx, queue); err != nil {
+			log.Printf("Error watching ReplicaSets and pods, only the full sweep reconciles ReplicaSets: %v", err)
+		}
+	}
+
+	for i := 0; i < max(rsc.opts.Workers, 1); i++ {
+		go rsc.runWorker(ctx, queue)
+	}
+
+	ticker := time.NewTicker(rsc.opts.ResyncPeriod)
 	defer ticker.Stop()
 
 	for {
@@ -109,13 +481,117 @@ func (rsc *ReplicaSetController) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := rsc.Run(ctx); err != nil {
-				fmt.Printf("Error reconciling replicaset: %v\n", err)
+			if err := rsc.resync(ctx, queue); err != nil {
+				log.Printf("Error resyncing ReplicaSets: %v", err)
 			}
 		}
 	}
 }
 
+// startInformers watches ReplicaSets and pods, queueing the ReplicaSets affected by a change
+func (rsc *ReplicaSetController) startInformers(ctx context.Context, queue *workqueue.Queue[string]) error {
+	rsWatch, err := listwatch.NewListWatch(rsc.opts.Endpoints, replicaSetWatchPrefix, rsc.opts.ListWatch, logger{})
+	if err != nil {
+		return fmt.Errorf("failed to watch ReplicaSets: %w", err)
+	}
+	podWatch, err := listwatch.NewListWatch(rsc.opts.Endpoints, podWatchPrefix, rsc.opts.ListWatch, logger{})
+	if err != nil {
+		return fmt.Errorf("failed to watch pods: %w", err)
+	}
+
+	enqueueReplicaSet := func(rs *api.ReplicaSet) { queue.Add(rs.Name) }
+	replicaSets := listwatch.NewInformer(rsWatch, func() *api.ReplicaSet { return &api.ReplicaSet{} },
+		listwatch.ResourceEventHandlerFuncs[*api.ReplicaSet]{
+			OnAdd:    enqueueReplicaSet,
+			OnUpdate: func(_, rs *api.ReplicaSet) { enqueueReplicaSet(rs) },
+			OnDelete: enqueueReplicaSet,
+		})
+
+	enqueuePod := func(pod *api.Pod) {
+		for _, name := range replicaSetsForPod(pod, replicaSets.List()) {
+			queue.Add(name)
+		}
+	}
+	pods := listwatch.NewInformer(podWatch, func() *api.Pod { return &api.Pod{} },
+		listwatch.ResourceEventHandlerFuncs[*api.Pod]{
+			OnAdd: enqueuePod,
+			OnUpdate: func(oldPod, pod *api.Pod) {
+				enqueuePod(oldPod)
+				enqueuePod(pod)
+			},
+			OnDelete: enqueuePod,
+		})
+
+	go func() {
+		if err := replicaSets.Run(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error watching ReplicaSets: %v", err)
+		}
+	}()
+	go func() {
+		if err := pods.Run(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error watching pods: %v", err)
+		}
+	}()
+	return nil
+}
+
+// replicaSetsForPod returns the names of the ReplicaSets a change of the pod affects: the
+// ReplicaSet controlling it, or the ReplicaSets that could adopt it if it has no controller
+func replicaSetsForPod(pod *api.Pod, replicaSets []*api.ReplicaSet) []string {
+	if ref := api.GetControllerOf(&pod.ObjectMeta); ref != nil {
+		if ref.Kind != api.KindReplicaSet {
+			return nil
+		}
+		return []string{ref.Name}
+	}
+
+	var names []string
+	for _, rs := range replicaSets {
+		if api.NamespaceOrDefault(rs.Namespace) == api.NamespaceOrDefault(pod.Namespace) && matchesReplicaSet(rs, pod) {
+			names = append(names, rs.Name)
+		}
+	}
+	return names
+}
+
+// runWorker reconciles the queued ReplicaSets until the queue is shut down. A ReplicaSet that
+// fails to reconcile is queued again with backoff.
+func (rsc *ReplicaSetController) runWorker(ctx context.Context, queue *workqueue.Queue[string]) {
+	for processNextItem(ctx, queue, "ReplicaSet", rsc.sync) {
+	}
+}
+
+// sync reconciles the named ReplicaSet. Once it is deleted, the pods it controlled are released.
+func (rsc *ReplicaSetController) sync(ctx context.Context, name string) (Result, error) {
+	err := rsc.Reconcile(ctx, &api.ReplicaSet{ObjectMeta: api.ObjectMeta{Name: name}})
+	if !errors.Is(err, registry.ErrReplicaSetNotFound) {
+		return Result{}, err
+	}
+
+	replicaSets, err := rsc.replicaSetRegistry.List(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{}, rsc.releaseOrphans(ctx, replicaSets)
+}
+
+// resync releases the pods of deleted ReplicaSets and queues every ReplicaSet
+func (rsc *ReplicaSetController) resync(ctx context.Context, queue *workqueue.Queue[string]) error {
+	replicaSets, err := rsc.replicaSetRegistry.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := rsc.releaseOrphans(ctx, replicaSets); err != nil {
+		return fmt.Errorf("failed to release orphaned pods: %w", err)
+	}
+
+	for _, rs := range replicaSets {
+		queue.Add(rs.Name)
+	}
+	return nil
+}
+
 func (rsc *ReplicaSetController) Run(_ context.Context) error {
 
 	rscList, err := rsc.replicaSetRegistry.List(context.Background())
@@ -124,6 +600,10 @@ func (rsc *ReplicaSetController) Run(_ context.Context) error {
 		return err
 	}
 
+	if err := rsc.releaseOrphans(context.Background(), rscList); err != nil {
+		return fmt.Errorf("failed to release orphaned pods: %w", err)
+	}
+
 	for _, rs := range rscList {
 		err := rsc.Reconcile(context.Background(), rs)
 		if err != nil {
@@ -133,7 +613,13 @@ func (rsc *ReplicaSetController) Run(_ context.Context) error {
 	return nil
 }
 
-// GeneratePodNameFromReplicaSet creates a pod name based on the ReplicaSet and container names
-func generatePodNameFromReplicaSet(replicaSetName string) string {
-	return names.SimpleNameGenerator.GenerateName(replicaSetName)
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
 }
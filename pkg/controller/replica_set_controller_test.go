@@ -2,11 +2,21 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
 	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 )
 
@@ -102,11 +112,7 @@ func TestReconcile(t *testing.T) {
 				}
 
 				// Check the number of pods
-				allPods, err := podRegistry.ListPods(ctx)
-				if err != nil {
-					t.Fatalf("Failed to list pods: %v", err)
-				}
-				actualPods, err := rsc.getPodsOwnedBy(tc.initialRS, allPods)
+				actualPods, err := rsc.getPodsOwnedBy(ctx, tc.initialRS)
 				if err != nil {
 					t.Fatalf("Failed to list pods: %v", err)
 				}
@@ -131,8 +137,20 @@ func TestGetActivePodsForReplicaSet(t *testing.T) {
 	rs := &api.ReplicaSet{
 		ObjectMeta: api.ObjectMeta{
 			Name: "test-rs",
+			UID:  "test-rs-uid",
 		},
 	}
+	owned := func(name string, phase api.PodPhase) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name:            name,
+				OwnerReferences: []api.OwnerReference{api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet)},
+			},
+			Status: api.PodStatus{Phase: phase},
+		}
+	}
+	// A ReplicaSet whose name starts with the name of rs
+	longer := &api.ReplicaSet{ObjectMeta: api.ObjectMeta{Name: "test-rs-b", UID: "test-rs-b-uid"}}
 
 	testCases := []struct {
 		name          string
@@ -142,21 +160,35 @@ func TestGetActivePodsForReplicaSet(t *testing.T) {
 		{
 			name: "All active and owned pods",
 			pods: []*api.Pod{
-				{ObjectMeta: api.ObjectMeta{Name: "test-rs-pod1"}, Status: api.PodRunning},
-				{ObjectMeta: api.ObjectMeta{Name: "test-rs-pod2"}, Status: api.PodPending},
+				owned("test-rs-pod1", api.PodRunning),
+				owned("test-rs-pod2", api.PodPending),
 			},
 			expectedCount: 2,
 		},
 		{
 			name: "Mix of active, inactive, and unowned pods",
 			pods: []*api.Pod{
-				{ObjectMeta: api.ObjectMeta{Name: "test-rs-pod1"}, Status: api.PodRunning},
-				{ObjectMeta: api.ObjectMeta{Name: "test-rs-pod2"}, Status: api.PodSucceeded},
-				{ObjectMeta: api.ObjectMeta{Name: "test-rs-pod3"}, Status: api.PodFailed},
-				{ObjectMeta: api.ObjectMeta{Name: "other-rs-pod"}, Status: api.PodRunning},
+				owned("test-rs-pod1", api.PodRunning),
+				owned("test-rs-pod2", api.PodSucceeded),
+				owned("test-rs-pod3", api.PodFailed),
+				{ObjectMeta: api.ObjectMeta{Name: "other-rs-pod"}, Status: api.PodStatus{Phase: api.PodRunning}},
 			},
 			expectedCount: 2, //succeeded is considered active FIXME:
 		},
+		{
+			name: "Pods named after the ReplicaSet without a reference to it",
+			pods: []*api.Pod{
+				{ObjectMeta: api.ObjectMeta{Name: "test-rs-orphan"}, Status: api.PodStatus{Phase: api.PodRunning}},
+				{
+					ObjectMeta: api.ObjectMeta{
+						Name:            "test-rs-b-pod",
+						OwnerReferences: []api.OwnerReference{api.NewControllerRef(&longer.ObjectMeta, api.KindReplicaSet)},
+					},
+					Status: api.PodStatus{Phase: api.PodRunning},
+				},
+			},
+			expectedCount: 0,
+		},
 		{
 			name:          "No pods",
 			pods:          []*api.Pod{},
@@ -178,13 +210,754 @@ func TestGetActivePodsForReplicaSet(t *testing.T) {
 			}
 
 			for _, pod := range activePods {
-				if (pod.Status != api.PodRunning && pod.Status != api.PodSucceeded) && pod.Status != api.PodPending {
+				if (pod.Status.Phase != api.PodRunning && pod.Status.Phase != api.PodSucceeded) && pod.Status.Phase != api.PodPending {
 					t.Errorf("Expected pod status to be Running/Succeeded or Pending, got %s", pod.Status)
 				}
-				if len(pod.Name) <= len(rs.Name) || pod.Name[:len(rs.Name)] != rs.Name {
-					t.Errorf("Expected pod name to start with %s, got %s", rs.Name, pod.Name)
+				if !api.IsOwnedBy(pod, &rs.ObjectMeta) {
+					t.Errorf("Expected pod %s to be controlled by %s", pod.Name, rs.Name)
 				}
 			}
 		})
 	}
 }
+
+func TestReconcileAdoptsMatchingPods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		newReplicaSet := func(name string, replicas int32) *api.ReplicaSet {
+			return &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec: api.ReplicaSetSpec{
+					Replicas: replicas,
+					Selector: map[string]string{"app": name},
+					Template: api.PodTemplateSpec{
+						ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": name}},
+						Spec: api.PodSpec{
+							Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+						},
+					},
+				},
+			}
+		}
+		newPod := func(name string, labels map[string]string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name, Labels: labels},
+				Spec: api.PodSpec{
+					Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+				},
+			}
+		}
+		countOwned := func(rs *api.ReplicaSet) int {
+			owned, err := rsc.getPodsOwnedBy(ctx, rs)
+			require.NoError(t, err)
+			return len(owned)
+		}
+
+		t.Run("adopts unowned matching pods and creates only the missing ones", func(t *testing.T) {
+			rs := newReplicaSet("web", 3)
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("leftover-1", map[string]string{"app": "web"})))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("leftover-2", map[string]string{"app": "web"})))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("unrelated", map[string]string{"app": "db"})))
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+
+			for _, name := range []string{"leftover-1", "leftover-2"} {
+				pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, name)
+				require.NoError(t, err)
+				ref := api.GetControllerOf(&pod.ObjectMeta)
+				require.NotNil(t, ref)
+				assert.Equal(t, api.KindReplicaSet, ref.Kind)
+				assert.Equal(t, "web", ref.Name)
+				assert.Equal(t, rs.UID, ref.UID)
+			}
+
+			unrelated, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "unrelated")
+			require.NoError(t, err)
+			assert.Nil(t, api.GetControllerOf(&unrelated.ObjectMeta))
+
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			assert.Len(t, pods, 4)
+			assert.Equal(t, 3, countOwned(rs))
+		})
+
+		t.Run("does not over-create when enough matching pods exist", func(t *testing.T) {
+			rs := newReplicaSet("api", 2)
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("api-old-1", map[string]string{"app": "api"})))
+			require.NoError(t, podRegistry.CreatePod(ctx, newPod("api-old-2", map[string]string{"app": "api"})))
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+
+			assert.Equal(t, 2, countOwned(rs))
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			matching := 0
+			for _, pod := range pods {
+				if api.MatchesSelector(rs.Spec.Selector, pod.Labels) {
+					matching++
+				}
+			}
+			assert.Equal(t, 2, matching)
+		})
+
+		t.Run("does not adopt pods controlled by another replicaset", func(t *testing.T) {
+			owner := newReplicaSet("owner", 1)
+			require.NoError(t, replicaSetRegistry.Create(ctx, owner))
+			require.NoError(t, rsc.Reconcile(ctx, owner))
+
+			rs := newReplicaSet("thief", 1)
+			rs.Spec.Selector = map[string]string{"app": "owner"}
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+
+			assert.Equal(t, 1, countOwned(owner))
+			assert.Equal(t, 1, countOwned(rs))
+		})
+
+		t.Run("replaces deleted pods", func(t *testing.T) {
+			rs := newReplicaSet("cache", 2)
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+
+			owned, err := rsc.getPodsOwnedBy(ctx, rs)
+			require.NoError(t, err)
+			require.Len(t, owned, 2)
+
+			require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, owned[0].Name))
+			assert.Equal(t, 1, countOwned(rs))
+
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+			replaced, err := rsc.getPodsOwnedBy(ctx, rs)
+			require.NoError(t, err)
+			require.Len(t, replaced, 2)
+			for _, pod := range replaced {
+				assert.NotEqual(t, owned[0].Name, pod.Name)
+			}
+		})
+
+		t.Run("releases pods of a deleted replicaset", func(t *testing.T) {
+			rs := newReplicaSet("gone", 2)
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+			require.Equal(t, 2, countOwned(rs))
+
+			require.NoError(t, replicaSetRegistry.Delete(ctx, rs.Name))
+			require.NoError(t, rsc.Run(ctx))
+
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			released := 0
+			for _, pod := range pods {
+				if api.MatchesSelector(rs.Spec.Selector, pod.Labels) {
+					assert.Nil(t, api.GetControllerOf(&pod.ObjectMeta))
+					released++
+				}
+			}
+			assert.Equal(t, 2, released)
+
+			// A new ReplicaSet with the same name is a different object and adopts the released pods
+			recreated := newReplicaSet("gone", 2)
+			require.NoError(t, replicaSetRegistry.Create(ctx, recreated))
+			require.NoError(t, rsc.Reconcile(ctx, recreated))
+			assert.NotEqual(t, rs.UID, recreated.UID)
+			assert.Equal(t, 2, countOwned(recreated))
+		})
+	})
+}
+
+func TestReconcilePausedReplicaSet(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "paused-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Paused:   true,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+		// Reconciling twice checks that the pause is stable
+		for i := 0; i < 2; i++ {
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+		}
+
+		pods, err := rsc.getPodsOwnedBy(ctx, rs)
+		require.NoError(t, err)
+		assert.Empty(t, pods, "no pods are created while paused")
+
+		pausedRS, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.True(t, conditions.IsConditionTrue(pausedRS.Status.Conditions, api.ReplicaSetConditionPaused))
+
+		// Unpausing resumes reconciliation
+		pausedRS.Spec.Paused = false
+		require.NoError(t, replicaSetRegistry.Update(ctx, pausedRS))
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		pods, err = rsc.getPodsOwnedBy(ctx, rs)
+		require.NoError(t, err)
+		assert.Len(t, pods, 2)
+
+		resumedRS, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.Nil(t, conditions.GetCondition(resumedRS.Status.Conditions, api.ReplicaSetConditionPaused))
+		assert.Equal(t, int32(2), resumedRS.Status.Replicas)
+	})
+}
+
+// sequenceNameGenerator returns the suffixes in order, repeating the last one
+type sequenceNameGenerator struct {
+	suffixes []string
+	calls    int
+}
+
+func (g *sequenceNameGenerator) GenerateName(base string) string {
+	suffix := g.suffixes[min(g.calls, len(g.suffixes)-1)]
+	g.calls++
+	return base + suffix
+}
+
+func TestReconcileRetriesPodNameCollisions(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		ctx := context.Background()
+
+		newReplicaSet := func(name string) *api.ReplicaSet {
+			return &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec: api.ReplicaSetSpec{
+					Replicas: 1,
+					Template: api.PodTemplateSpec{
+						Spec: api.PodSpec{
+							Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+						},
+					},
+				},
+			}
+		}
+
+		// A pod unrelated to the ReplicaSets already has the first generated name
+		require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "collide-taken"},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "other", Image: "busybox"}}},
+			Status:     api.PodStatus{Phase: api.PodFailed},
+		}))
+
+		t.Run("should regenerate a taken name", func(t *testing.T) {
+			generator := &sequenceNameGenerator{suffixes: []string{"-taken", "-free"}}
+			rsc := NewReplicaSetControllerWithNameGenerator(replicaSetRegistry, podRegistry, generator)
+			rs := newReplicaSet("collide")
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+
+			pods, err := rsc.getPodsOwnedBy(ctx, rs)
+			require.NoError(t, err)
+			require.Len(t, pods, 1)
+			assert.Equal(t, "collide-free", pods[0].Name)
+			assert.Equal(t, 2, generator.calls)
+		})
+
+		t.Run("should give up after a bounded number of attempts", func(t *testing.T) {
+			generator := &sequenceNameGenerator{suffixes: []string{"-free"}}
+			rsc := NewReplicaSetControllerWithNameGenerator(replicaSetRegistry, podRegistry, generator)
+			// Scaling up needs a second pod, but only the name of the first one is generated
+			rs, err := replicaSetRegistry.Get(ctx, "collide")
+			require.NoError(t, err)
+			rs.Spec.Replicas = 2
+			require.NoError(t, replicaSetRegistry.Update(ctx, rs))
+
+			err = rsc.Reconcile(ctx, rs)
+			assert.ErrorIs(t, err, registry.ErrPodAlreadyExists)
+			assert.Equal(t, maxPodNameAttempts, generator.calls)
+		})
+	})
+}
+
+func TestStartSweepsAllReplicaSetsEveryResyncPeriod(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		createRS := func(name string) *api.ReplicaSet {
+			rs := &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec: api.ReplicaSetSpec{
+					Replicas: 1,
+					Template: api.PodTemplateSpec{
+						Spec: api.PodSpec{
+							Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+						},
+					},
+				},
+			}
+			require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+			return rs
+		}
+		reconciled := func(names ...string) func() bool {
+			return func() bool {
+				for _, name := range names {
+					rs, err := replicaSetRegistry.Get(ctx, name)
+					if err != nil || rs.Status.Replicas != 1 {
+						return false
+					}
+				}
+				return true
+			}
+		}
+
+		// No sweep happens before the first resync period has passed
+		slow := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, Options{ResyncPeriod: time.Hour})
+		go slow.Start(ctx)
+		createRS("sweep-rs-1")
+		assert.Never(t, reconciled("sweep-rs-1"), 500*time.Millisecond, 50*time.Millisecond)
+
+		// Every sweep reconciles all ReplicaSets, including those created since the last one
+		fast := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, Options{ResyncPeriod: 100 * time.Millisecond})
+		go fast.Start(ctx)
+		assert.Eventually(t, reconciled("sweep-rs-1"), 2*time.Second, 20*time.Millisecond)
+		createRS("sweep-rs-2")
+		createRS("sweep-rs-3")
+		assert.Eventually(t, reconciled("sweep-rs-1", "sweep-rs-2", "sweep-rs-3"), 2*time.Second, 20*time.Millisecond)
+	})
+}
+
+func TestReconcileScalesDown(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "scale-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 5,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+		// Four running pods, oldest first, and a pending one
+		created := time.Now().UTC().Add(-time.Hour)
+		for i := 0; i < 5; i++ {
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{
+					Name:              fmt.Sprintf("scale-rs-%d", i),
+					CreationTimestamp: created.Add(time.Duration(i) * time.Minute),
+					OwnerReferences:   []api.OwnerReference{api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet)},
+				},
+				Spec:     rs.Spec.Template.Spec,
+				NodeName: "node-1",
+				Status:   api.PodStatus{Phase: api.PodRunning},
+			}
+			if i == 4 {
+				pod.NodeName = ""
+				pod.Status.Phase = api.PodPending
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		}
+
+		current, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		current.Spec.Replicas = 2
+		require.NoError(t, replicaSetRegistry.Update(ctx, current))
+
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		pods, err := podRegistry.ListPods(ctx)
+		require.NoError(t, err)
+		var remaining []string
+		for _, pod := range pods {
+			remaining = append(remaining, pod.Name)
+		}
+		assert.ElementsMatch(t, []string{"scale-rs-0", "scale-rs-1"}, remaining, "the pending and youngest pods should be deleted")
+
+		scaled, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), scaled.Status.Replicas)
+	})
+}
+
+func TestReconcileRecordsEvents(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		events := registry.NewEventRegistry(etcdStorage)
+		opts := DefaultOptions()
+		opts.Events = events
+		rsc := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, opts)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "events-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		current, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		current.Spec.Replicas = 1
+		require.NoError(t, replicaSetRegistry.Update(ctx, current))
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		recorded, err := events.ListFor(ctx, api.ObjectReference{Kind: api.KindReplicaSet, Name: rs.Name})
+		require.NoError(t, err)
+		var reasons []string
+		for _, event := range recorded {
+			reasons = append(reasons, event.Reason)
+		}
+		assert.ElementsMatch(t, []string{"SuccessfulCreate", "SuccessfulCreate", "SuccessfulDelete"}, reasons)
+	})
+}
+
+func TestReconcileMarksExcessPodsWithFinalizers(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "finalized-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		current, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		pods, err := rsc.getPodsOwnedBy(ctx, current)
+		require.NoError(t, err)
+		for _, pod := range pods {
+			pod.Finalizers = []string{"example.com/drain"}
+			require.NoError(t, podRegistry.UpdatePod(ctx, pod))
+		}
+
+		current.Spec.Replicas = 1
+		require.NoError(t, replicaSetRegistry.Update(ctx, current))
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		// The excess pod is marked and left to its finalizers rather than deleted
+		pods, err = rsc.getPodsOwnedBy(ctx, current)
+		require.NoError(t, err)
+		require.Len(t, pods, 2)
+		terminating := 0
+		for _, pod := range pods {
+			if pod.IsTerminating() {
+				terminating++
+			}
+		}
+		assert.Equal(t, 1, terminating)
+
+		// The terminating pod isn't counted, so no other pod is deleted or created for it
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+		pods, err = rsc.getPodsOwnedBy(ctx, current)
+		require.NoError(t, err)
+		require.Len(t, pods, 2)
+		terminating = 0
+		for _, pod := range pods {
+			if pod.IsTerminating() {
+				terminating++
+			}
+		}
+		assert.Equal(t, 1, terminating)
+
+		stored, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), stored.Status.Replicas)
+	})
+}
+
+func TestReconcileRepairsDuplicatePods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "dup-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 3,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+		// The pods were duplicated, created at the same time and running, without the
+		// replicas ever changing
+		created := time.Now().UTC().Add(-time.Hour)
+		for i := 0; i < 7; i++ {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{
+					Name:              fmt.Sprintf("dup-rs-%d", i),
+					CreationTimestamp: created,
+					OwnerReferences:   []api.OwnerReference{api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet)},
+				},
+				Spec:     rs.Spec.Template.Spec,
+				NodeName: "node-1",
+				Status:   api.PodStatus{Phase: api.PodRunning},
+			}))
+		}
+
+		remainingPods := func() []string {
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			return names
+		}
+
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+		assert.ElementsMatch(t, []string{"dup-rs-0", "dup-rs-1", "dup-rs-2"}, remainingPods(),
+			"the same excess pods should be deleted whatever order they are listed in")
+		repaired, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), repaired.Status.Replicas)
+
+		// Once converged, reconciling again changes nothing
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+		assert.ElementsMatch(t, []string{"dup-rs-0", "dup-rs-1", "dup-rs-2"}, remainingPods())
+		unchanged, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.Equal(t, repaired.ResourceVersion, unchanged.ResourceVersion)
+	})
+}
+
+// racingCreateStorage creates a pod controlled by the ReplicaSet under every pod name that is
+// looked up before it exists, as a reconcile racing to create the same pods would
+type racingCreateStorage struct {
+	storage.Storage
+	owner *api.ReplicaSet
+}
+
+func (s *racingCreateStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
+	err := s.Storage.Get(ctx, key, obj)
+	if !errors.Is(err, storage.ErrNotFound) || !strings.HasPrefix(key, "/pods/") {
+		return err
+	}
+
+	raced := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:       api.NamespaceDefault,
+			Name:            path.Base(key),
+			OwnerReferences: []api.OwnerReference{api.NewControllerRef(&s.owner.ObjectMeta, api.KindReplicaSet)},
+		},
+		Spec:   api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx"}}},
+		Status: api.PodStatus{Phase: api.PodPending},
+	}
+	if err := s.Storage.Create(ctx, key, raced); err != nil {
+		return err
+	}
+	return s.Storage.Get(ctx, key, obj)
+}
+
+func TestReconcileCapsPodCreation(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		events := registry.NewEventRegistry(etcdStorage)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "storm-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 3,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "web", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+		stored, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+
+		// Every generated name is taken by a pod of the ReplicaSet by the time it is created,
+		// which without the cap would create pods until the name attempts ran out
+		podRegistry := registry.NewPodRegistry(&racingCreateStorage{Storage: etcdStorage, owner: stored})
+		opts := DefaultOptions()
+		opts.Events = events
+		rsc := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, opts)
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		pods, err := rsc.getPodsOwnedBy(ctx, stored)
+		require.NoError(t, err)
+		assert.Len(t, pods, 3+DefaultOptions().MaxPodSurge)
+
+		recorded, err := events.ListFor(ctx, api.ObjectReference{Kind: api.KindReplicaSet, Name: rs.Name})
+		require.NoError(t, err)
+		require.Len(t, recorded, 1)
+		assert.Equal(t, api.EventTypeWarning, recorded[0].Type)
+		assert.Equal(t, "PodCreationCapped", recorded[0].Reason)
+	})
+}
+
+func TestReconcileCreatesMultiContainerPods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "sidecar-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{
+							{Name: "web", Image: "nginx"},
+							{Name: "sidecar", Image: "busybox"},
+						},
+						NodeSelector: map[string]string{"disk": "ssd"},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+
+		pods, err := rsc.getPodsOwnedBy(ctx, rs)
+		require.NoError(t, err)
+		require.Len(t, pods, 2)
+		for _, pod := range pods {
+			require.Len(t, pod.Spec.Containers, 2)
+			assert.Equal(t, "web", pod.Spec.Containers[0].Name)
+			assert.Equal(t, "sidecar", pod.Spec.Containers[1].Name)
+			assert.Equal(t, rs.Spec.Template.Spec.NodeSelector, pod.Spec.NodeSelector)
+		}
+		assert.NotEqual(t, pods[0].Name, pods[1].Name)
+
+		// The pods are counted once each, not once per container
+		require.NoError(t, rsc.Reconcile(ctx, rs))
+		pods, err = rsc.getPodsOwnedBy(ctx, rs)
+		require.NoError(t, err)
+		assert.Len(t, pods, 2)
+
+		current, err := replicaSetRegistry.Get(ctx, rs.Name)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), current.Status.Replicas)
+	})
+}
+
+func TestStartReconcilesChangedReplicaSets(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The full sweep never runs, so every reconcile is triggered by a change
+		opts := DefaultOptions()
+		opts.ResyncPeriod = time.Hour
+		opts.Endpoints = etcdServer.Endpoints()
+		opts.ListWatch.Registerer = prometheus.NewRegistry()
+		rsc := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, opts)
+		go rsc.Start(ctx)
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "event-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{
+						Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+					},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+		ownedPods := func() []*api.Pod {
+			pods, err := podRegistry.ListPods(ctx)
+			require.NoError(t, err)
+			var owned []*api.Pod
+			for _, pod := range pods {
+				if ref := api.GetControllerOf(&pod.ObjectMeta); ref != nil && ref.Name == "event-rs" {
+					owned = append(owned, pod)
+				}
+			}
+			return owned
+		}
+		require.Eventually(t, func() bool { return len(ownedPods()) == 2 }, 5*time.Second, 20*time.Millisecond)
+
+		// A deleted pod is replaced right away
+		deleted := ownedPods()[0]
+		require.NoError(t, podRegistry.DeletePod(ctx, deleted.Namespace, deleted.Name))
+		require.Eventually(t, func() bool {
+			pods := ownedPods()
+			for _, pod := range pods {
+				if pod.Name == deleted.Name {
+					return false
+				}
+			}
+			return len(pods) == 2
+		}, 5*time.Second, 20*time.Millisecond)
+
+		// Scaling the ReplicaSet is reconciled right away
+		current, err := replicaSetRegistry.Get(ctx, "event-rs")
+		require.NoError(t, err)
+		current.Spec.Replicas = 3
+		require.NoError(t, replicaSetRegistry.Update(ctx, current))
+		require.Eventually(t, func() bool { return len(ownedPods()) == 3 }, 5*time.Second, 20*time.Millisecond)
+	})
+}
@@ -0,0 +1,188 @@
+package etcdclient
+
+import (
+	"context"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Operation names an etcd call made through a Client
+type Operation string
+
+const (
+	OperationGet     Operation = "get"
+	OperationPut     Operation = "put"
+	OperationDelete  Operation = "delete"
+	OperationTxn     Operation = "txn"
+	OperationWatch   Operation = "watch"
+	OperationGrant   Operation = "grant"
+	OperationRevoke  Operation = "revoke"
+	OperationCompact Operation = "compact"
+)
+
+// Call describes an etcd call passed to the hooks of a Client
+type Call struct {
+	Operation Operation
+	// Key is the key or prefix of the call, empty for calls without one such as Txn and Grant
+	Key string
+}
+
+// Hook observes the etcd calls made through a Client, such as to log or trace them.
+// Either function may be nil.
+type Hook struct {
+	// Before is called before the call is made and returns the context it is made with, so
+	// that it can carry a trace span or request scoped logger to After
+	Before func(ctx context.Context, call Call) context.Context
+	// After is called once the call returned, with the context returned by Before, how long the
+	// call took and its error. For a Watch it is called when the watch channel is closed.
+	After func(ctx context.Context, call Call, duration time.Duration, err error)
+}
+
+// Client wraps an etcd client and invokes its hooks around every Get, Put, Delete, Txn, Watch,
+// Grant, Revoke and Compact. Other calls go directly to the embedded client.
+type Client struct {
+	*clientv3.Client
+	hooks []Hook
+}
+
+// Wrap returns a Client making its calls with the etcd client
+func Wrap(client *clientv3.Client, hooks ...Hook) *Client {
+	return &Client{Client: client, hooks: hooks}
+}
+
+// start invokes the Before hooks of a call and returns its context and the function that
+// invokes the After hooks once it returned
+func (c *Client) start(ctx context.Context, call Call) (context.Context, func(error)) {
+	if len(c.hooks) == 0 {
+		return ctx, func(error) {}
+	}
+
+	for _, hook := range c.hooks {
+		if hook.Before != nil {
+			ctx = hook.Before(ctx, call)
+		}
+	}
+	started := time.Now()
+	return ctx, func(err error) {
+		duration := time.Since(started)
+		for _, hook := range c.hooks {
+			if hook.After != nil {
+				hook.After(ctx, call, duration, err)
+			}
+		}
+	}
+}
+
+// Get retrieves the key, or the keys matching the options
+func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, finish := c.start(ctx, Call{Operation: OperationGet, Key: key})
+	resp, err := c.Client.Get(ctx, key, opts...)
+	finish(err)
+	return resp, err
+}
+
+// Put stores the value at the key
+func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	ctx, finish := c.start(ctx, Call{Operation: OperationPut, Key: key})
+	resp, err := c.Client.Put(ctx, key, val, opts...)
+	finish(err)
+	return resp, err
+}
+
+// Delete removes the key, or the keys matching the options
+func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	ctx, finish := c.start(ctx, Call{Operation: OperationDelete, Key: key})
+	resp, err := c.Client.Delete(ctx, key, opts...)
+	finish(err)
+	return resp, err
+}
+
+// Txn returns a transaction whose Commit invokes the hooks
+func (c *Client) Txn(ctx context.Context) clientv3.Txn {
+	return &txn{client: c, ctx: ctx}
+}
+
+// Grant creates a lease of ttl seconds
+func (c *Client) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	ctx, finish := c.start(ctx, Call{Operation: OperationGrant})
+	resp, err := c.Client.Grant(ctx, ttl)
+	finish(err)
+	return resp, err
+}
+
+// Revoke revokes the lease, deleting the keys attached to it
+func (c *Client) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	ctx, finish := c.start(ctx, Call{Operation: OperationRevoke})
+	resp, err := c.Client.Revoke(ctx, id)
+	finish(err)
+	return resp, err
+}
+
+// Compact compacts the key-value history up to the revision
+func (c *Client) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	ctx, finish := c.start(ctx, Call{Operation: OperationCompact})
+	resp, err := c.Client.Compact(ctx, rev, opts...)
+	finish(err)
+	return resp, err
+}
+
+// Watch watches the key, or the keys matching the options. The After hooks are invoked once
+// the returned channel is closed, with the error of the last response, if any.
+func (c *Client) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	if len(c.hooks) == 0 {
+		return c.Client.Watch(ctx, key, opts...)
+	}
+
+	hookCtx, finish := c.start(ctx, Call{Operation: OperationWatch, Key: key})
+	watchChan := c.Client.Watch(hookCtx, key, opts...)
+
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+
+		var err error
+		for resp := range watchChan {
+			err = resp.Err()
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				// Nobody reads anymore, the watch channel is closed once the watch is cancelled
+			}
+		}
+		finish(err)
+	}()
+	return out
+}
+
+// txn collects the comparisons and operations of a transaction, so that it is only started
+// with the context returned by the hooks once it is committed
+type txn struct {
+	client  *Client
+	ctx     context.Context
+	cmps    []clientv3.Cmp
+	thenOps []clientv3.Op
+	elseOps []clientv3.Op
+}
+
+func (t *txn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *txn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.thenOps = append(t.thenOps, ops...)
+	return t
+}
+
+func (t *txn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.elseOps = append(t.elseOps, ops...)
+	return t
+}
+
+func (t *txn) Commit() (*clientv3.TxnResponse, error) {
+	ctx, finish := t.client.start(t.ctx, Call{Operation: OperationTxn})
+	resp, err := t.client.Client.Txn(ctx).If(t.cmps...).Then(t.thenOps...).Else(t.elseOps...).Commit()
+	finish(err)
+	return resp, err
+}
@@ -0,0 +1,137 @@
+package etcdclient_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/storage"
+)
+
+type traceKey struct{}
+
+// recordedCall is a call observed by the hooks of a Client
+type recordedCall struct {
+	call     etcdclient.Call
+	trace    any
+	duration time.Duration
+	err      error
+}
+
+// callRecorder records the calls observed by its hook
+type callRecorder struct {
+	mutex  sync.Mutex
+	before []etcdclient.Call
+	after  []recordedCall
+}
+
+func (r *callRecorder) hook() etcdclient.Hook {
+	return etcdclient.Hook{
+		Before: func(ctx context.Context, call etcdclient.Call) context.Context {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.before = append(r.before, call)
+			return context.WithValue(ctx, traceKey{}, string(call.Operation)+" "+call.Key)
+		},
+		After: func(ctx context.Context, call etcdclient.Call, duration time.Duration, err error) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.after = append(r.after, recordedCall{call: call, trace: ctx.Value(traceKey{}), duration: duration, err: err})
+		},
+	}
+}
+
+// last returns the last call that returned
+func (r *callRecorder) last(t *testing.T) recordedCall {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	require.NotEmpty(t, r.after)
+	return r.after[len(r.after)-1]
+}
+
+func TestClientHooks(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		recorder := &callRecorder{}
+		client := etcdclient.Wrap(cli, recorder.hook())
+		ctx := context.Background()
+
+		assertCall := func(t *testing.T, operation etcdclient.Operation, key string) recordedCall {
+			call := recorder.last(t)
+			assert.Equal(t, etcdclient.Call{Operation: operation, Key: key}, call.call)
+			assert.Equal(t, string(operation)+" "+key, call.trace, "the context of Before is passed to After")
+			assert.Positive(t, call.duration)
+			assert.Less(t, call.duration, 5*time.Second)
+			return call
+		}
+
+		t.Run("should observe reads and writes", func(t *testing.T) {
+			_, err := client.Put(ctx, "/hooks/a", "1")
+			require.NoError(t, err)
+			assert.NoError(t, assertCall(t, etcdclient.OperationPut, "/hooks/a").err)
+
+			resp, err := client.Get(ctx, "/hooks/", clientv3.WithPrefix())
+			require.NoError(t, err)
+			assert.Len(t, resp.Kvs, 1)
+			assert.NoError(t, assertCall(t, etcdclient.OperationGet, "/hooks/").err)
+
+			_, err = client.Delete(ctx, "/hooks/a")
+			require.NoError(t, err)
+			assert.NoError(t, assertCall(t, etcdclient.OperationDelete, "/hooks/a").err)
+		})
+
+		t.Run("should observe transactions when they are committed", func(t *testing.T) {
+			before := len(recorder.before)
+			txn := client.Txn(ctx).
+				If(clientv3.Compare(clientv3.CreateRevision("/hooks/txn"), "=", 0)).
+				Then(clientv3.OpPut("/hooks/txn", "1"))
+			assert.Len(t, recorder.before, before)
+
+			resp, err := txn.Commit()
+			require.NoError(t, err)
+			assert.True(t, resp.Succeeded)
+			assert.NoError(t, assertCall(t, etcdclient.OperationTxn, "").err)
+		})
+
+		t.Run("should observe leases", func(t *testing.T) {
+			lease, err := client.Grant(ctx, 60)
+			require.NoError(t, err)
+			assertCall(t, etcdclient.OperationGrant, "")
+
+			_, err = client.Revoke(ctx, lease.ID)
+			require.NoError(t, err)
+			assertCall(t, etcdclient.OperationRevoke, "")
+		})
+
+		t.Run("should report the error of a call", func(t *testing.T) {
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+
+			_, err := client.Get(cancelled, "/hooks/a")
+			require.Error(t, err)
+			assert.ErrorIs(t, assertCall(t, etcdclient.OperationGet, "/hooks/a").err, context.Canceled)
+		})
+
+		t.Run("should observe a watch until its channel is closed", func(t *testing.T) {
+			watchCtx, cancel := context.WithCancel(ctx)
+			watchChan := client.Watch(watchCtx, "/hooks/watched")
+
+			_, err := client.Put(ctx, "/hooks/watched", "1")
+			require.NoError(t, err)
+			resp := <-watchChan
+			require.Len(t, resp.Events, 1)
+
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			for range watchChan {
+			}
+
+			assert.GreaterOrEqual(t, assertCall(t, etcdclient.OperationWatch, "/hooks/watched").duration, 50*time.Millisecond)
+		})
+	})
+}
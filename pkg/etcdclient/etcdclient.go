@@ -0,0 +1,145 @@
+// Package etcdclient builds etcd clients for plaintext or secured etcd clusters.
+//
+// The clients invoke the Hooks of their Config around every call, so that logging and tracing
+// of etcd calls is set up in one place for every component.
+//
+// A Config with any of the TLS files of its Security set connects over TLS, treating its
+// endpoints as https:// endpoints. The files are checked before dialing, so that a missing
+// certificate is reported up front rather than as a dial failure.
+package etcdclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ErrInvalidConfig is returned for a Config that can't be used to connect to etcd
+var ErrInvalidConfig = errors.New("invalid etcd client config")
+
+// Security holds the credentials used to connect to a secured etcd cluster
+type Security struct {
+	// CAFile is the PEM encoded CA bundle the etcd server certificates are verified with.
+	// Without it the system roots are used.
+	CAFile string
+	// CertFile and KeyFile are the PEM encoded client certificate and key, for clusters that
+	// authenticate clients by certificate. They must be set together.
+	CertFile string
+	KeyFile  string
+	// Username and Password authenticate with etcd's own authentication
+	Username string
+	Password string
+}
+
+// TLSEnabled reports whether any TLS file is set, connecting over TLS
+func (s Security) TLSEnabled() bool {
+	return s.CAFile != "" || s.CertFile != "" || s.KeyFile != ""
+}
+
+// Validate checks that the certificate files exist and that the settings are complete
+func (s Security) Validate() error {
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return fmt.Errorf("%w: client certificate and key must be set together", ErrInvalidConfig)
+	}
+	if s.Password != "" && s.Username == "" {
+		return fmt.Errorf("%w: password set without a username", ErrInvalidConfig)
+	}
+
+	for _, file := range []struct{ name, path string }{
+		{"CA file", s.CAFile},
+		{"client certificate", s.CertFile},
+		{"client key", s.KeyFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, file.name, err)
+		}
+	}
+	return nil
+}
+
+// Config describes how to connect to etcd
+type Config struct {
+	Endpoints   []string
+	DialTimeout time.Duration
+	Security    Security
+	// Hooks are invoked around the calls of the clients created from the config
+	Hooks []Hook
+}
+
+// DefaultConfig returns the default configuration, connecting to a local plaintext etcd
+func DefaultConfig() Config {
+	return Config{
+		Endpoints:   []string{"http://localhost:2379"},
+		DialTimeout: 5 * time.Second,
+	}
+}
+
+// ClientConfig validates the config and returns the etcd client config it describes
+func (c Config) ClientConfig() (clientv3.Config, error) {
+	if len(c.Endpoints) == 0 {
+		return clientv3.Config{}, fmt.Errorf("%w: no endpoints", ErrInvalidConfig)
+	}
+	if err := c.Security.Validate(); err != nil {
+		return clientv3.Config{}, err
+	}
+
+	config := clientv3.Config{
+		Endpoints:   c.Endpoints,
+		DialTimeout: c.DialTimeout,
+		Username:    c.Security.Username,
+		Password:    c.Security.Password,
+	}
+	if !c.Security.TLSEnabled() {
+		return config, nil
+	}
+
+	tlsInfo := transport.TLSInfo{
+		CertFile:      c.Security.CertFile,
+		KeyFile:       c.Security.KeyFile,
+		TrustedCAFile: c.Security.CAFile,
+	}
+	tlsConfig, err := tlsInfo.ClientConfig()
+	if err != nil {
+		return clientv3.Config{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	config.TLS = tlsConfig
+	config.Endpoints = httpsEndpoints(c.Endpoints)
+	return config, nil
+}
+
+// New validates the config and creates an etcd client for it
+func New(c Config) (*Client, error) {
+	config, err := c.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientv3.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(client, c.Hooks...), nil
+}
+
+// httpsEndpoints returns the endpoints with an https:// scheme, replacing http:// and adding
+// it to endpoints without a scheme
+func httpsEndpoints(endpoints []string) []string {
+	secured := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		if !strings.HasPrefix(endpoint, "https://") {
+			endpoint = "https://" + endpoint
+		}
+		secured[i] = endpoint
+	}
+	return secured
+}
@@ -0,0 +1,131 @@
+package etcdclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate and its key to dir and returns their paths
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gokube-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestConfig_ClientConfig(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+
+	t.Run("plaintext config keeps the endpoints", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Endpoints = []string{"http://localhost:2379", "localhost:2380"}
+		config.Security = Security{Username: "root", Password: "secret"}
+
+		clientConfig, err := config.ClientConfig()
+		require.NoError(t, err)
+		assert.Equal(t, config.Endpoints, clientConfig.Endpoints)
+		assert.Nil(t, clientConfig.TLS)
+		assert.Equal(t, "root", clientConfig.Username)
+		assert.Equal(t, "secret", clientConfig.Password)
+		assert.Equal(t, config.DialTimeout, clientConfig.DialTimeout)
+	})
+
+	t.Run("TLS config uses https endpoints", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Endpoints = []string{"http://localhost:2379", "localhost:2380", "https://etcd:2379"}
+		config.Security = Security{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}
+
+		clientConfig, err := config.ClientConfig()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://localhost:2379", "https://localhost:2380", "https://etcd:2379"}, clientConfig.Endpoints)
+		require.NotNil(t, clientConfig.TLS)
+		assert.NotNil(t, clientConfig.TLS.RootCAs)
+		assert.NotNil(t, clientConfig.TLS.GetClientCertificate)
+		// The caller's endpoints are left untouched
+		assert.Equal(t, "http://localhost:2379", config.Endpoints[0])
+	})
+
+	t.Run("CA file alone enables TLS", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Security = Security{CAFile: certFile}
+
+		clientConfig, err := config.ClientConfig()
+		require.NoError(t, err)
+		assert.NotNil(t, clientConfig.TLS)
+		assert.Equal(t, []string{"https://localhost:2379"}, clientConfig.Endpoints)
+	})
+
+	invalid := []struct {
+		name     string
+		config   Config
+		contains string
+	}{
+		{
+			name:     "missing CA file",
+			config:   Config{Endpoints: []string{"localhost:2379"}, Security: Security{CAFile: "/does/not/exist/ca.pem"}},
+			contains: "/does/not/exist/ca.pem",
+		},
+		{
+			name:     "missing client key file",
+			config:   Config{Endpoints: []string{"localhost:2379"}, Security: Security{CertFile: certFile, KeyFile: "/does/not/exist/key.pem"}},
+			contains: "client key",
+		},
+		{
+			name:     "certificate without key",
+			config:   Config{Endpoints: []string{"localhost:2379"}, Security: Security{CertFile: certFile}},
+			contains: "set together",
+		},
+		{
+			name:     "password without username",
+			config:   Config{Endpoints: []string{"localhost:2379"}, Security: Security{Password: "secret"}},
+			contains: "username",
+		},
+		{
+			name:     "no endpoints",
+			config:   Config{},
+			contains: "no endpoints",
+		},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.config.ClientConfig()
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, tc.contains)
+
+			client, err := New(tc.config)
+			assert.Nil(t, client)
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package etcdclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// WatermarkPrefix is the prefix of the keys under which watchers publish the lowest revision
+// they still need, so that compaction, wherever it runs, keeps it
+const WatermarkPrefix = "/watermarks/"
+
+// Watermark publishes the lowest revision a watcher still needs under WatermarkPrefix. The key
+// is attached to a lease kept alive while the watermark is published, so the watermark of a
+// watcher that went away without withdrawing it expires with the lease.
+// A Watermark is not safe for concurrent use.
+type Watermark struct {
+	client *Client
+	ttl    time.Duration
+	lease  clientv3.LeaseID
+	// stopKeepAlive stops keeping the lease alive
+	stopKeepAlive context.CancelFunc
+}
+
+// NewWatermark creates a Watermark published with the client, which expires ttl after the
+// client stopped keeping it alive
+func NewWatermark(client *Client, ttl time.Duration) *Watermark {
+	return &Watermark{client: client, ttl: ttl}
+}
+
+// Publish publishes revision as the lowest revision still needed, replacing the revision
+// published before. The lease of the watermark is granted on the first call, and again if it
+// expired.
+func (w *Watermark) Publish(ctx context.Context, revision int64) error {
+	for attempt := 0; ; attempt++ {
+		if w.lease == 0 {
+			if err := w.grant(ctx); err != nil {
+				return err
+			}
+		}
+
+		_, err := w.client.Put(ctx, w.key(), strconv.FormatInt(revision, 10), clientv3.WithLease(w.lease))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, rpctypes.ErrLeaseNotFound) || attempt > 0 {
+			return fmt.Errorf("failed to publish watermark: %w", err)
+		}
+		// The lease expired while the watermark wasn't kept alive
+		w.stop()
+	}
+}
+
+// Withdraw removes the published watermark by revoking its lease
+func (w *Watermark) Withdraw(ctx context.Context) error {
+	if w.lease == 0 {
+		return nil
+	}
+
+	lease := w.lease
+	w.stop()
+	if _, err := w.client.Revoke(ctx, lease); err != nil && !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return fmt.Errorf("failed to withdraw watermark: %w", err)
+	}
+	return nil
+}
+
+// grant grants the lease of the watermark and keeps it alive until it is stopped
+func (w *Watermark) grant(ctx context.Context) error {
+	resp, err := w.client.Grant(ctx, int64(max(w.ttl/time.Second, 1)))
+	if err != nil {
+		return fmt.Errorf("failed to grant watermark lease: %w", err)
+	}
+
+	keepAliveCtx, stopKeepAlive := context.WithCancel(context.Background())
+	keepAlive, err := w.client.KeepAlive(keepAliveCtx, resp.ID)
+	if err != nil {
+		stopKeepAlive()
+		return fmt.Errorf("failed to keep watermark lease alive: %w", err)
+	}
+	go func() {
+		// The responses have to be drained for the lease to be kept alive
+		for range keepAlive {
+		}
+	}()
+
+	w.lease = resp.ID
+	w.stopKeepAlive = stopKeepAlive
+	return nil
+}
+
+// stop stops keeping the lease alive and forgets it
+func (w *Watermark) stop() {
+	if w.stopKeepAlive != nil {
+		w.stopKeepAlive()
+	}
+	w.lease = 0
+	w.stopKeepAlive = nil
+}
+
+// key returns the key of the watermark, unique as its lease is
+func (w *Watermark) key() string {
+	return fmt.Sprintf("%s%x", WatermarkPrefix, int64(w.lease))
+}
+
+// MinWatermark returns the lowest revision published under WatermarkPrefix, and false if no
+// watermark is published
+func MinWatermark(ctx context.Context, client *Client) (int64, bool, error) {
+	resp, err := client.Get(ctx, WatermarkPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, false, err
+	}
+
+	var minRevision int64
+	found := false
+	for _, kv := range resp.Kvs {
+		revision, err := strconv.ParseInt(string(kv.Value), 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid watermark %q at %s", kv.Value, kv.Key)
+		}
+		if !found || revision < minRevision {
+			minRevision = revision
+			found = true
+		}
+	}
+	return minRevision, found, nil
+}
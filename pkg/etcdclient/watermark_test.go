@@ -0,0 +1,77 @@
+package etcdclient_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/storage"
+)
+
+func TestWatermark(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		client := etcdclient.Wrap(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, ok, err := etcdclient.MinWatermark(ctx, client)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		first := etcdclient.NewWatermark(client, time.Minute)
+		second := etcdclient.NewWatermark(client, time.Minute)
+		require.NoError(t, first.Publish(ctx, 10))
+		require.NoError(t, second.Publish(ctx, 7))
+
+		revision, ok, err := etcdclient.MinWatermark(ctx, client)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(7), revision)
+
+		// Publishing again replaces the watermark
+		require.NoError(t, second.Publish(ctx, 12))
+		revision, _, err = etcdclient.MinWatermark(ctx, client)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), revision)
+
+		require.NoError(t, first.Withdraw(ctx))
+		revision, _, err = etcdclient.MinWatermark(ctx, client)
+		require.NoError(t, err)
+		assert.Equal(t, int64(12), revision)
+
+		require.NoError(t, second.Withdraw(ctx))
+		_, ok, err = etcdclient.MinWatermark(ctx, client)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestWatermark_RepublishesAfterTheLeaseExpired(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		client := etcdclient.Wrap(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		watermark := etcdclient.NewWatermark(client, time.Minute)
+		require.NoError(t, watermark.Publish(ctx, 5))
+
+		// Revoking the lease behind the back of the watermark, as its expiry would
+		leases, err := cli.Leases(ctx)
+		require.NoError(t, err)
+		for _, lease := range leases.Leases {
+			_, err := cli.Revoke(ctx, lease.ID)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, watermark.Publish(ctx, 6))
+		revision, ok, err := etcdclient.MinWatermark(ctx, client)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(6), revision)
+	})
+}
@@ -0,0 +1,169 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+)
+
+// Reasons of the Admitted condition the kubelet sets on the pods bound to its node
+const (
+	reasonAdmitted       = "Admitted"
+	reasonOutOfResources = "OutOfResources"
+	reasonEvicted        = "Evicted"
+	// reasonPullSecretNotFound is set on pods referring to an image pull secret the kubelet
+	// isn't configured with
+	reasonPullSecretNotFound = "PullSecretNotFound"
+)
+
+// admitPod checks that the resource requests of the pod fit the capacity of the node next to
+// the requests of the pods the kubelet runs. If they don't, the running pods of lower priority
+// are evicted, lowest priority first, until the pod fits. It returns the pods to evict, or an
+// error telling which resources are short if the pod can't fit.
+func (k *Kubelet) admitPod(ctx context.Context, pod *api.Pod) ([]*api.Pod, error) {
+	capacity, err := k.resources.Capacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var running, candidates []*api.Pod
+	for _, other := range k.pods {
+		if other.Status.Phase == api.PodFailed || other.Status.Phase == api.PodSucceeded {
+			continue
+		}
+		running = append(running, other)
+		if other.Spec.Priority < pod.Spec.Priority {
+			candidates = append(candidates, other)
+		}
+	}
+
+	requested := make(api.ResourceList)
+	for _, other := range running {
+		requested.Add(other.Spec.ResourceRequests())
+	}
+	request := pod.Spec.ResourceRequests()
+	if capacity.Fits(requested, request) {
+		return nil, nil
+	}
+
+	// Evict the pods of lowest priority first, the most recent first among equal priorities
+	slices.SortStableFunc(candidates, func(a, b *api.Pod) int {
+		if a.Spec.Priority != b.Spec.Priority {
+			return int(a.Spec.Priority - b.Spec.Priority)
+		}
+		return b.CreationTimestamp.Compare(a.CreationTimestamp)
+	})
+	var victims []*api.Pod
+	for _, victim := range candidates {
+		for name, quantity := range victim.Spec.ResourceRequests() {
+			requested[name] -= quantity
+		}
+		victims = append(victims, victim)
+		if capacity.Fits(requested, request) {
+			return victims, nil
+		}
+	}
+
+	return nil, fmt.Errorf("node %s has insufficient %s for pod %s", k.nodeName,
+		strings.Join(insufficientResources(capacity, requested, request), ", "), pod.Name)
+}
+
+// insufficientResources describes the resources of the request that don't fit once requested
+// is in use, ordered by name
+func insufficientResources(capacity, requested, request api.ResourceList) []string {
+	var short []string
+	for name, quantity := range request {
+		if !capacity.Fits(requested, api.ResourceList{name: quantity}) {
+			short = append(short, fmt.Sprintf("%s (requested %d, %d of %d free)",
+				name, quantity, max(capacity[name]-requested[name], 0), capacity[name]))
+		}
+	}
+	slices.Sort(short)
+	return short
+}
+
+// rejectPod reports the pod Pending with the reason it wasn't admitted. The pod isn't
+// tracked, so admission is tried again on the next pod assignments.
+func (k *Kubelet) rejectPod(pod *api.Pod, reason string, cause error) error {
+	pod.Status.Phase = api.PodPending
+	changed := conditions.SetCondition(&pod.Status.Conditions, api.Condition{
+		Type:    api.PodConditionAdmitted,
+		Status:  api.ConditionFalse,
+		Reason:  reason,
+		Message: cause.Error(),
+	})
+	if !changed {
+		return nil
+	}
+	return k.updatePodStatus(pod)
+}
+
+// markAdmitted clears the Admitted condition of a pod that was rejected before
+func (k *Kubelet) markAdmitted(pod *api.Pod) error {
+	if conditions.GetCondition(pod.Status.Conditions, api.PodConditionAdmitted) == nil {
+		return nil
+	}
+	changed := conditions.SetCondition(&pod.Status.Conditions, api.Condition{
+		Type:   api.PodConditionAdmitted,
+		Status: api.ConditionTrue,
+		Reason: reasonAdmitted,
+	})
+	if !changed {
+		return nil
+	}
+	return k.updatePodStatus(pod)
+}
+
+// evictPod reports the pod Failed, so that its controller replaces it, and stops its
+// containers to make room for the preemptor
+func (k *Kubelet) evictPod(victim, preemptor *api.Pod) error {
+	log.Printf("Evicting pod %s of priority %d to admit pod %s of priority %d",
+		victim.Name, victim.Spec.Priority, preemptor.Name, preemptor.Spec.Priority)
+
+	victim.Status.Phase = api.PodFailed
+	conditions.SetCondition(&victim.Status.Conditions, api.Condition{
+		Type:    api.PodConditionAdmitted,
+		Status:  api.ConditionFalse,
+		Reason:  reasonEvicted,
+		Message: fmt.Sprintf("evicted to admit pod %s of higher priority %d", preemptor.Name, preemptor.Spec.Priority),
+	})
+	k.startedPods.Delete(podKey(victim))
+	if err := k.updatePodStatus(victim); err != nil {
+		return err
+	}
+
+	k.goPodOperation(func(ctx context.Context) {
+		if err := k.stopPodContainers(ctx, victim); err != nil {
+			log.Printf("Error stopping containers of evicted pod %s: %v", victim.Name, err)
+		}
+	})
+	return nil
+}
+
+// stopPodContainers stops and removes the containers of the pod. Every container is stopped
+// even if stopping one of them fails, containers that are already gone are skipped.
+func (k *Kubelet) stopPodContainers(ctx context.Context, pod *api.Pod) error {
+	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: podLabelFilters(pod),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list containers of pod %s: %v", pod.Name, err)
+	}
+
+	var errs []error
+	for _, c := range containers {
+		if err := k.StopContainer(ctx, c.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package kubelet
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/api/server"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func podRequesting(name string, priority int32, cpu int64) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: api.NamespaceDefault},
+		NodeName:   "admission-node",
+		Status:     api.PodStatus{Phase: api.PodRunning},
+		Spec: api.PodSpec{
+			Priority: priority,
+			Containers: []api.Container{{
+				Name:      "app",
+				Image:     "nginx",
+				Resources: api.ResourceRequirements{Requests: api.ResourceList{api.ResourceCPU: cpu}},
+			}},
+		},
+	}
+}
+
+func TestRunNewPodsRejectsPodThatDoesNotFit(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		store := storage.NewEtcdStorage(cli)
+		apiServer := httptest.NewServer(server.NewAPIServer(store).Handler())
+		defer apiServer.Close()
+		podRegistry := registry.NewPodRegistry(store)
+		ctx := context.Background()
+
+		running := podRequesting("running", 10, 1500)
+		kubelet := &Kubelet{
+			nodeName:     "admission-node",
+			apiServerURL: strings.TrimPrefix(apiServer.URL, "http://"),
+			pods:         map[string]*api.Pod{podKey(running): running},
+			resources: &fakeResourceProvider{
+				capacity: api.ResourceList{api.ResourceCPU: 2000, api.ResourceMemory: 4 << 30},
+				usage:    api.ResourceList{},
+			},
+			runtime: &fakeRuntime{},
+			opts:    DefaultOptions(),
+		}
+
+		// The pod doesn't fit, and the running pod is of the same priority so it isn't evicted
+		pod := podRequesting("too-big", 10, 1000)
+		pod.Status.Phase = api.PodPending
+		require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		require.NoError(t, kubelet.runNewPods(ctx, []*api.Pod{pod}))
+
+		assert.NotContains(t, kubelet.pods, "default/too-big")
+		assert.Equal(t, api.PodRunning, running.Status.Phase)
+
+		stored, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "too-big")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodPending, stored.Status.Phase)
+		admitted := conditions.GetCondition(stored.Status.Conditions, api.PodConditionAdmitted)
+		require.NotNil(t, admitted)
+		assert.Equal(t, api.ConditionFalse, admitted.Status)
+		assert.Equal(t, reasonOutOfResources, admitted.Reason)
+		assert.Equal(t, "node admission-node has insufficient cpu (requested 1000, 500 of 2000 free) for pod too-big", admitted.Message)
+	})
+}
+
+func TestAdmitPodEvictsLowerPriorityPods(t *testing.T) {
+	kubelet := &Kubelet{
+		nodeName: "admission-node",
+		pods:     make(map[string]*api.Pod),
+		resources: &fakeResourceProvider{
+			capacity: api.ResourceList{api.ResourceCPU: 2000},
+		},
+	}
+	for _, pod := range []*api.Pod{
+		podRequesting("low", 1, 500),
+		podRequesting("lowest", 0, 500),
+		podRequesting("high", 100, 500),
+		podRequesting("done", 0, 500),
+	} {
+		kubelet.pods[podKey(pod)] = pod
+	}
+	kubelet.pods["default/done"].Status.Phase = api.PodSucceeded
+	ctx := context.Background()
+
+	// 500 millicores are free, finished pods don't count
+	victims, err := kubelet.admitPod(ctx, podRequesting("fits", 0, 500))
+	require.NoError(t, err)
+	assert.Empty(t, victims)
+
+	// The pods of lowest priority are evicted until the pod fits
+	victims, err = kubelet.admitPod(ctx, podRequesting("preemptor", 50, 1000))
+	require.NoError(t, err)
+	require.Len(t, victims, 1)
+	assert.Equal(t, "lowest", victims[0].Name)
+
+	victims, err = kubelet.admitPod(ctx, podRequesting("preemptor", 50, 1500))
+	require.NoError(t, err)
+	require.Len(t, victims, 2)
+	assert.Equal(t, "lowest", victims[0].Name)
+	assert.Equal(t, "low", victims[1].Name)
+
+	// Pods of higher priority are never evicted
+	_, err = kubelet.admitPod(ctx, podRequesting("preemptor", 50, 2000))
+	assert.ErrorContains(t, err, "insufficient cpu")
+}
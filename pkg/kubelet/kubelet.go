@@ -5,18 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/emicklei/go-restful/v3"
 
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
 	"gokube/pkg/registry/names"
 )
 
@@ -24,10 +24,89 @@ type Kubelet struct {
 	nodeName     string
 	apiServerURL string
 	dockerClient *client.Client
-	pods         map[string]*api.Pod
+	resources    ResourceProvider
+	runtime      ContainerRuntime
+	opts         Options
+
+	// podsMutex guards pods and the status of the pods in it. The pod watch holds it while
+	// handling a change, the status updates while updating the status of a pod.
+	podsMutex sync.RWMutex
+	// pods holds the pods the kubelet runs, by podKey
+	pods map[string]*api.Pod
+	// startedPods holds the keys of the pods whose containers were started. Only their
+	// status is reported, a pod still pulling images has no containers yet.
+	startedPods sync.Map
+
+	// lifecycleMutex guards the contexts below, which are created on first use
+	lifecycleMutex sync.Mutex
+	// ctx is cancelled by Stop to end the loops started by Start
+	ctx    context.Context
+	cancel context.CancelFunc
+	// podCtx is passed to the pod operations, it is only cancelled when Stop gives up waiting
+	// for them
+	podCtx              context.Context
+	cancelPodOperations context.CancelFunc
+	// podOperations tracks the in-flight pod operations, Stop waits for them to finish
+	podOperations sync.WaitGroup
+}
+
+// Options configures the Kubelet behavior
+type Options struct {
+	// StopGracePeriod is how long a container is given to exit after SIGTERM
+	// before it is force-killed
+	StopGracePeriod time.Duration
+	// NodeStatusUpdateInterval is how often the node status, including the resources still
+	// allocatable on the host, is reported to the API server
+	NodeStatusUpdateInterval time.Duration
+	// OwnerLabels labels containers with the kind, name and UID of the workload that owns
+	// their pod, so Docker can be queried for all containers of a ReplicaSet
+	OwnerLabels bool
+	// RuntimeRetryInterval is how often the container runtime is retried while it is
+	// unreachable, the node is reported NotReady until then
+	RuntimeRetryInterval time.Duration
+	// PodStatusUpdateInterval is how often the state of the containers of the pods is inspected
+	// and the pod statuses that changed are reported to the API server
+	PodStatusUpdateInterval time.Duration
+	// StopContainersOnShutdown stops the containers of the pods the kubelet runs when it is
+	// stopped, rather than leaving them running for the next kubelet to adopt
+	StopContainersOnShutdown bool
+	// PodWatchRetryInterval is how long the kubelet waits before watching its pods again after
+	// the watch failed
+	PodWatchRetryInterval time.Duration
+	// PodResyncPeriod is how often the watch of the pods is restarted with a full list, which
+	// retries the pods that were rejected for lack of resources. Zero keeps the watch open.
+	PodResyncPeriod time.Duration
+	// LogConfig is the log driver and size limits the containers are created with
+	LogConfig LogConfig
+	// PullSecrets are the registry credentials the pods refer to by name in their
+	// ImagePullSecrets. Pods referring to a secret that isn't configured are rejected.
+	PullSecrets map[string]RegistryCredentials
+}
+
+// DefaultOptions returns the default Kubelet configuration
+func DefaultOptions() Options {
+	return Options{
+		StopGracePeriod:          10 * time.Second,
+		NodeStatusUpdateInterval: 10 * time.Second,
+		OwnerLabels:              true,
+		RuntimeRetryInterval:     5 * time.Second,
+		PodStatusUpdateInterval:  10 * time.Second,
+		PodWatchRetryInterval:    5 * time.Second,
+		PodResyncPeriod:          time.Minute,
+		LogConfig:                DefaultLogConfig(),
+	}
 }
 
 func NewKubelet(nodeName, apiServerURL string) (*Kubelet, error) {
+	return NewKubeletWithOptions(nodeName, apiServerURL, DefaultOptions())
+}
+
+// NewKubeletWithOptions creates a Kubelet with the given configuration
+func NewKubeletWithOptions(nodeName, apiServerURL string, opts Options) (*Kubelet, error) {
+	if err := opts.LogConfig.Validate(); err != nil {
+		return nil, err
+	}
+
 	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 
 	if err != nil {
@@ -39,32 +118,108 @@ func NewKubelet(nodeName, apiServerURL string) (*Kubelet, error) {
 		apiServerURL: apiServerURL,
 		dockerClient: dockerClient,
 		pods:         make(map[string]*api.Pod),
+		resources:    &dockerResourceProvider{dockerClient: dockerClient},
+		runtime:      &dockerRuntime{dockerClient: dockerClient},
+		opts:         opts,
 	}, nil
 }
 
 func (k *Kubelet) Start() error {
-	// Register the node with the API server
+	// Register the node with the API server, as NotReady while the container runtime is
+	// unreachable
 	if err := k.registerNode(); err != nil {
 		return fmt.Errorf("failed to register node: %w", err)
 	}
 
 	// TODO: Implement other Kubelet functionality here
 
-	// Start watching for pod assignments
-	go k.watchPods()
+	ctx := k.lifecycle()
+
+	// Start reporting the resources still free on the node
+	go k.updateNodeStatuses(ctx)
+
+	go func() {
+		// Pods can only be run once the container runtime is reachable
+		if err := k.waitForRuntime(ctx); err != nil {
+			log.Printf("Error reporting node %s ready: %v", k.nodeName, err)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		// Start watching for pod assignments
+		go k.watchPods(ctx)
 
-	// Start updating pod statuses
-	go k.updatePodStatuses()
+		// Start updating pod statuses
+		go k.updatePodStatuses(ctx)
+	}()
 
 	return nil
 }
 
+// Stop ends the loops started by Start and waits for the in-flight pod operations, such as
+// container starts, to finish. If ctx is done first the operations are cancelled and an error
+// is returned. With StopContainersOnShutdown the containers of the pods are stopped as well.
+func (k *Kubelet) Stop(ctx context.Context) error {
+	k.lifecycle()
+	k.lifecycleMutex.Lock()
+	k.cancel()
+	k.lifecycleMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		k.podOperations.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		k.cancelPodOperations()
+		return fmt.Errorf("timed out waiting for in-flight pod operations: %w", ctx.Err())
+	}
+
+	if k.opts.StopContainersOnShutdown {
+		return k.CleanupContainers(ctx)
+	}
+	return nil
+}
+
+// lifecycle returns the context of the loops, creating the contexts of the kubelet on first use
+func (k *Kubelet) lifecycle() context.Context {
+	k.lifecycleMutex.Lock()
+	defer k.lifecycleMutex.Unlock()
+
+	if k.ctx == nil {
+		k.ctx, k.cancel = context.WithCancel(context.Background())
+		k.podCtx, k.cancelPodOperations = context.WithCancel(context.Background())
+	}
+	return k.ctx
+}
+
+// goPodOperation runs the operation in a goroutine tracked by Stop. Once the kubelet is
+// stopping no operation is started and false is returned.
+func (k *Kubelet) goPodOperation(operation func(ctx context.Context)) bool {
+	k.lifecycle()
+	k.lifecycleMutex.Lock()
+	defer k.lifecycleMutex.Unlock()
+
+	if k.ctx.Err() != nil {
+		return false
+	}
+
+	k.podOperations.Add(1)
+	go func() {
+		defer k.podOperations.Done()
+		operation(k.podCtx)
+	}()
+	return true
+}
+
 func (k *Kubelet) registerNode() error {
-	node := &api.Node{
-		ObjectMeta: api.ObjectMeta{
-			Name: k.nodeName,
-		},
-		Status: api.NodeReady,
+	node, err := k.nodeStatus(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get node status: %w", err)
 	}
 
 	jsonData, err := json.Marshal(node)
@@ -85,90 +240,83 @@ func (k *Kubelet) registerNode() error {
 	return nil
 }
 
-func (k *Kubelet) watchPods() {
-	for {
-		pods, err := k.getPodAssignments()
-		if err != nil {
-			log.Printf("Error getting pod assignments: %v", err)
-			time.Sleep(5 * time.Second)
+// runNewPods runs the pods that aren't running yet once they are admitted. A pod that doesn't
+// fit on the node is reported Pending and tried again once it changes or the pods are listed again.
+// The caller holds podsMutex.
+func (k *Kubelet) runNewPods(ctx context.Context, pods []*api.Pod) error {
+	for _, pod := range pods {
+		if _, exists := k.pods[podKey(pod)]; exists {
 			continue
 		}
 
-		if err := k.runNewPods(pods); err != nil {
-			log.Printf("Error running new pods: %v", err)
+		if err := k.checkPullSecrets(pod); err != nil {
+			log.Printf("Rejecting pod %s: %v", pod.Name, err)
+			if err := k.rejectPod(pod, reasonPullSecretNotFound, err); err != nil {
+				log.Printf("Error reporting rejected pod %s: %v", pod.Name, err)
+			}
+			continue
 		}
 
-		time.Sleep(10 * time.Second) // Poll every 10 seconds
-	}
-}
+		victims, err := k.admitPod(ctx, pod)
+		if err != nil {
+			log.Printf("Rejecting pod %s: %v", pod.Name, err)
+			if err := k.rejectPod(pod, reasonOutOfResources, err); err != nil {
+				log.Printf("Error reporting rejected pod %s: %v", pod.Name, err)
+			}
+			continue
+		}
+		for _, victim := range victims {
+			if err := k.evictPod(victim, pod); err != nil {
+				log.Printf("Error evicting pod %s: %v", victim.Name, err)
+			}
+		}
+		if err := k.markAdmitted(pod); err != nil {
+			log.Printf("Error reporting admitted pod %s: %v", pod.Name, err)
+		}
 
-func (k *Kubelet) runNewPods(pods []*api.Pod) error {
-	for _, pod := range pods {
-		if _, exists := k.pods[pod.Name]; !exists {
-			log.Printf("New pod assigned: %s", pod.Name)
-			k.pods[pod.Name] = pod
-			go k.runPod(pod)
+		if !k.goPodOperation(func(ctx context.Context) { k.runPod(ctx, pod) }) {
+			return nil
 		}
+		log.Printf("New pod assigned: %s", pod.Name)
+		k.pods[podKey(pod)] = pod
 	}
 	return nil
 }
 
-func (k *Kubelet) getPodAssignments() ([]*api.Pod, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/v1/pods?nodeName=%s", "http://"+k.apiServerURL, k.nodeName))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var pods []*api.Pod
-	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
-		return nil, err
-	}
-
-	return pods, nil
-}
-
-func (k *Kubelet) runPod(pod *api.Pod) {
+func (k *Kubelet) runPod(ctx context.Context, pod *api.Pod) {
 	// Simulate running a pod
 	log.Printf("Running pod: %s", pod.Name)
 	for _, container := range pod.Spec.Containers {
-		if err := k.StartContainer(context.Background(), pod, container.Name, container.Image); err != nil {
+		if err := k.StartContainer(ctx, pod, container.Name, container.Image); err != nil {
 			log.Printf("Failed to start container %s: %v", container.Name, err)
 		}
 	}
-	// In a real implementation, this would involve setting up containers, etc.
+	// A container that failed to start is missing, which is reported as failed
+	k.startedPods.Store(podKey(pod), true)
 }
 
 func (k *Kubelet) StartContainer(ctx context.Context, pod *api.Pod, containerName, imageName string) error {
-
-	log.Printf("Pulling image: %s", imageName)
-
-	// Pull the image
-	out, err := k.dockerClient.ImagePull(ctx, imageName, image.PullOptions{})
-	if err != nil {
-		panic(err)
-	}
-	defer out.Close()
-	_, err = io.Copy(os.Stdout, out)
+	// Pods stored before images were normalized may still carry a short reference
+	imageName, err := api.NormalizeImage(imageName)
 	if err != nil {
-		return fmt.Errorf("failed to pull image %s: %v", imageName, err)
+		return err
 	}
 
-	log.Printf("Successfully pulled image: %s", "nginx")
-
-	labels := map[string]string{
-		"gokube.pod.name":       pod.Name,
-		"gokube.pod.namespace":  pod.Namespace,
-		"gokube.container.name": containerName,
+	if err := k.pullImage(ctx, pod, imageName); err != nil {
+		return err
 	}
 
+	labels := k.containerLabels(pod, containerName)
+
 	uniqueContainerName := names.SimpleNameGenerator.GenerateName(fmt.Sprintf("%s-%s", pod.Name, containerName))
 	// Create the container
 	resp, err := k.dockerClient.ContainerCreate(ctx, &container.Config{
 		Image:  imageName,
 		Labels: labels,
 		// You can add more configuration options here as needed
-	}, nil, nil, nil, uniqueContainerName)
+	}, &container.HostConfig{
+		LogConfig: k.opts.LogConfig.dockerLogConfig(),
+	}, nil, nil, uniqueContainerName)
 	if err != nil {
 		return fmt.Errorf("failed to create container %s: %v", containerName, err)
 	}
@@ -182,6 +330,64 @@ func (k *Kubelet) StartContainer(ctx context.Context, pod *api.Pod, containerNam
 	return nil
 }
 
+// Labels set on the containers the kubelet creates
+const (
+	labelPodName       = "gokube.pod.name"
+	labelPodNamespace  = "gokube.pod.namespace"
+	labelContainerName = "gokube.container.name"
+	labelOwnerKind     = "gokube.owner.kind"
+	labelOwnerName     = "gokube.owner.name"
+	labelOwnerUID      = "gokube.owner.uid"
+)
+
+// containerLabels returns the labels of a container of the pod. With OwnerLabels, the
+// controller of the pod, or its first owner if it has no controller, is added.
+func (k *Kubelet) containerLabels(pod *api.Pod, containerName string) map[string]string {
+	labels := map[string]string{
+		labelPodName:       pod.Name,
+		labelPodNamespace:  api.NamespaceOrDefault(pod.Namespace),
+		labelContainerName: containerName,
+	}
+	if !k.opts.OwnerLabels {
+		return labels
+	}
+
+	owner := api.GetControllerOf(&pod.ObjectMeta)
+	if owner == nil && len(pod.OwnerReferences) > 0 {
+		owner = &pod.OwnerReferences[0]
+	}
+	if owner != nil {
+		labels[labelOwnerKind] = owner.Kind
+		labels[labelOwnerName] = owner.Name
+		labels[labelOwnerUID] = owner.UID
+	}
+	return labels
+}
+
+// podLabelFilters matches the containers of the pod, pods of the same name in other
+// namespaces have containers of their own
+func podLabelFilters(pod *api.Pod, extra ...filters.KeyValuePair) filters.Args {
+	args := []filters.KeyValuePair{
+		filters.Arg("label", labelPodName+"="+pod.Name),
+		filters.Arg("label", labelPodNamespace+"="+api.NamespaceOrDefault(pod.Namespace)),
+	}
+	return filters.NewArgs(append(args, extra...)...)
+}
+
+// managedContainerFilters matches the containers the kubelet created for pods
+func managedContainerFilters() filters.Args {
+	return filters.NewArgs(
+		filters.Arg("label", labelPodName),
+		filters.Arg("label", labelPodNamespace),
+	)
+}
+
+// containerPodKey returns the podKey of the pod a container was created for, from its labels.
+// Containers created before pods had namespaces are in the default namespace.
+func containerPodKey(labels map[string]string) string {
+	return api.NamespaceOrDefault(labels[labelPodNamespace]) + "/" + labels[labelPodName]
+}
+
 func (k *Kubelet) GetNodeName() string {
 	return k.nodeName
 }
@@ -194,25 +400,27 @@ type ContainerStatus struct {
 }
 
 func (k *Kubelet) ListContainers(ctx context.Context) ([]ContainerStatus, error) {
-	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{})
+	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{
+		Filters: managedContainerFilters(),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %v", err)
 	}
 
 	var statuses []ContainerStatus
 	for _, c := range containers {
-		podName, ok := c.Labels["gokube.pod.name"]
+		podName, ok := c.Labels[labelPodName]
 		if !ok {
 			continue // Skip containers not managed by our system
 		}
 
-		pod, ok := k.pods[podName]
+		pod, ok := k.trackedPod(containerPodKey(c.Labels))
 		if !ok || pod.NodeName != k.nodeName {
 			continue // Skip pods not assigned to this node
 		}
 
 		for _, containerSpec := range pod.Spec.Containers {
-			if containerSpec.Name == c.Labels["gokube.container.name"] {
+			if containerSpec.Name == c.Labels[labelContainerName] {
 				status := ContainerStatus{
 					PodName:       podName,
 					ContainerName: containerSpec.Name,
@@ -228,17 +436,17 @@ func (k *Kubelet) ListContainers(ctx context.Context) ([]ContainerStatus, error)
 	return statuses, nil
 }
 
-func (k *Kubelet) getPodStatus(ctx context.Context, pod *api.Pod) (api.PodStatus, error) {
+func (k *Kubelet) getPodPhase(ctx context.Context, pod *api.Pod) (api.PodPhase, error) {
 	var containerStates []containerState
 	for _, container := range pod.Spec.Containers {
-		state, err := k.getContainerState(ctx, container.Name)
+		state, err := k.getContainerState(ctx, pod, container.Name)
 		if err != nil {
 			return api.PodRunning, fmt.Errorf("failed to get state for container %s: %w", container.Name, err)
 		}
 		containerStates = append(containerStates, state)
 	}
 
-	return determinePodStatus(containerStates), nil
+	return determinePodPhase(containerStates), nil
 }
 
 type containerState struct {
@@ -247,8 +455,21 @@ type containerState struct {
 	exitCode int
 }
 
-func (k *Kubelet) getContainerState(ctx context.Context, containerName string) (containerState, error) {
-	containerInfo, err := k.dockerClient.ContainerInspect(ctx, containerName)
+// getContainerState inspects the container of the pod, found by its labels as the container
+// name in Docker is made unique
+func (k *Kubelet) getContainerState(ctx context.Context, pod *api.Pod, containerName string) (containerState, error) {
+	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: podLabelFilters(pod, filters.Arg("label", labelContainerName+"="+containerName)),
+	})
+	if err != nil {
+		return containerState{}, err
+	}
+	if len(containers) == 0 {
+		return containerState{exists: false}, nil
+	}
+
+	containerInfo, err := k.dockerClient.ContainerInspect(ctx, containers[0].ID)
 	if err != nil {
 		if client.IsErrNotFound(err) {
 			return containerState{exists: false}, nil
@@ -263,52 +484,43 @@ func (k *Kubelet) getContainerState(ctx context.Context, containerName string) (
 	}, nil
 }
 
-func determinePodStatus(states []containerState) api.PodStatus {
+// determinePodPhase maps the state of the containers to the pod phase. The pod is running
+// while any container runs. Once none does, it failed if a container exited non-zero or
+// disappeared, and succeeded if all exited cleanly.
+func determinePodPhase(states []containerState) api.PodPhase {
 	if anyContainerRunning(states) {
 		return api.PodRunning
 	}
 
-	if allContainersFailed(states) && anyContainerExists(states) {
-		return api.PodFailed
-	}
-
-	if allContainersSucceeded(states) {
-		return api.PodSucceeded
-	}
-
-	return api.PodScheduled
-}
-
-func allContainersSucceeded(states []containerState) bool {
 	for _, state := range states {
-		if state.exitCode != 0 {
-			return false
+		if !state.exists || state.exitCode != 0 {
+			return api.PodFailed
 		}
 	}
-	return true
+	return api.PodSucceeded
 }
 
-func anyContainerRunning(states []containerState) bool {
-	for _, state := range states {
-		if state.running {
-			return true
-		}
-	}
-	return false
-}
+// setPodPhase sets the phase of the pod along with its ContainersReady and Ready conditions,
+// which hold while the pod runs. It reports whether the status changed.
+func setPodPhase(pod *api.Pod, phase api.PodPhase) bool {
+	changed := pod.Status.Phase != phase
+	pod.Status.Phase = phase
 
-func allContainersFailed(states []containerState) bool {
-	for _, state := range states {
-		if state.exists && state.exitCode == 0 {
-			return false
+	status := api.ConditionFalse
+	if phase == api.PodRunning {
+		status = api.ConditionTrue
+	}
+	for _, conditionType := range []api.ConditionType{api.PodConditionContainersReady, api.PodConditionReady} {
+		if conditions.SetCondition(&pod.Status.Conditions, api.Condition{Type: conditionType, Status: status}) {
+			changed = true
 		}
 	}
-	return true
+	return changed
 }
 
-func anyContainerExists(states []containerState) bool {
+func anyContainerRunning(states []containerState) bool {
 	for _, state := range states {
-		if state.exists {
+		if state.running {
 			return true
 		}
 	}
@@ -316,16 +528,18 @@ func anyContainerExists(states []containerState) bool {
 }
 
 func (k *Kubelet) CleanupContainers(ctx context.Context) error {
-	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: managedContainerFilters(),
+	})
 	if err != nil {
 		return fmt.Errorf("error listing containers for cleanup: %v", err)
 	}
 
 	for _, c := range containers {
-		if podName, ok := c.Labels["gokube.pod.name"]; ok {
-			if pod, exists := k.pods[podName]; exists && pod.NodeName == k.nodeName {
-				err := k.dockerClient.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
-				if err != nil {
+		if podName, ok := c.Labels[labelPodName]; ok {
+			if pod, exists := k.trackedPod(containerPodKey(c.Labels)); exists && pod.NodeName == k.nodeName {
+				if err := k.StopContainer(ctx, c.ID); err != nil {
 					log.Printf("Error removing container %s: %v", c.ID, err)
 				} else {
 					log.Printf("Removed container %s for pod %s", c.ID, podName)
@@ -337,33 +551,91 @@ func (k *Kubelet) CleanupContainers(ctx context.Context) error {
 	return nil
 }
 
-func (k *Kubelet) updatePodStatuses() {
-	ticker := time.NewTicker(10 * time.Second) // Check every 10 seconds
+// stopTimeoutSeconds returns the grace period in the whole seconds Docker takes, rounded up so
+// that a sub-second grace period still gives the container a second rather than killing it
+// right away
+func stopTimeoutSeconds(gracePeriod time.Duration) int {
+	if gracePeriod <= 0 {
+		return 0
+	}
+	return int((gracePeriod + time.Second - 1) / time.Second)
+}
+
+// StopContainer stops a container and removes it. The container is sent SIGTERM and given
+// the configured grace period to exit; if it is still running after that it is force-killed.
+// Stopping a container that is already gone is not an error.
+func (k *Kubelet) StopContainer(ctx context.Context, containerID string) error {
+	timeout := stopTimeoutSeconds(k.opts.StopGracePeriod)
+	log.Printf("Stopping container %s with grace period %v", containerID, k.opts.StopGracePeriod)
+	if err := k.dockerClient.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout}); err != nil {
+		if client.IsErrNotFound(err) {
+			log.Printf("Container %s is already gone", containerID)
+			return nil
+		}
+		log.Printf("Error stopping container %s: %v", containerID, err)
+	}
+
+	info, err := k.dockerClient.ContainerInspect(ctx, containerID)
+	if client.IsErrNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %v", containerID, err)
+	}
+
+	if info.State.Running {
+		log.Printf("Container %s still running after grace period, killing it", containerID)
+		if err := k.dockerClient.ContainerKill(ctx, containerID, "SIGKILL"); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to kill container %s: %v", containerID, err)
+		}
+	}
+
+	log.Printf("Removing container %s", containerID)
+	if err := k.dockerClient.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to remove container %s: %v", containerID, err)
+	}
+
+	return nil
+}
+
+// updatePodStatuses periodically reports the status of the started pods, derived from the
+// state of their containers, to the API server when it changed
+func (k *Kubelet) updatePodStatuses(ctx context.Context) {
+	ticker := time.NewTicker(k.opts.PodStatusUpdateInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			for _, pod := range k.pods {
-				status, err := k.getPodStatus(context.Background(), pod)
+			for _, pod := range k.trackedPods() {
+				if _, started := k.startedPods.Load(podKey(pod)); !started {
+					continue
+				}
+
+				// Only the spec of the pod is read, which doesn't change
+				phase, err := k.getPodPhase(ctx, pod)
 				if err != nil {
 					log.Printf("Error getting status for pod %s: %v", pod.Name, err)
 					continue
 				}
 
-				if pod.Status != status {
-					pod.Status = status
+				k.podsMutex.Lock()
+				if setPodPhase(pod, phase) {
 					if err := k.updatePodStatus(pod); err != nil {
 						log.Printf("Error updating status for pod %s: %v", pod.Name, err)
 					}
 				}
+				k.podsMutex.Unlock()
 			}
 		}
 	}
 }
 
 func (k *Kubelet) updatePodStatus(pod *api.Pod) error {
-	url := fmt.Sprintf("http://%s/api/v1/pods/%s", k.apiServerURL, pod.Name)
+	// The status subresource leaves the binding and spec of the pod untouched
+	url := fmt.Sprintf("http://%s/api/v1/namespaces/%s/pods/%s/status", k.apiServerURL, api.NamespaceOrDefault(pod.Namespace), pod.Name)
 
 	jsonData, err := json.Marshal(pod)
 	if err != nil {
@@ -386,7 +658,7 @@ func (k *Kubelet) updatePodStatus(pod *api.Pod) error {
 		return fmt.Errorf("failed to update pod status, status code: %d", resp.StatusCode)
 	}
 
-	log.Printf("Updated pod status for %s: %v", pod.Name, pod.Status)
+	log.Printf("Updated pod status for %s: %v", pod.Name, pod.Status.Phase)
 
 	return nil
 }
@@ -3,6 +3,7 @@ package kubelet
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -46,7 +47,7 @@ func TestStartContainerWithRealDocker(t *testing.T) {
 		},
 	}
 
-	err = kubelet.runNewPods([]*api.Pod{pod})
+	err = kubelet.runNewPods(ctx, []*api.Pod{pod})
 	if err != nil {
 		t.Fatalf("StartContainer failed: %v", err)
 	}
@@ -126,3 +127,267 @@ func listContainerIDs(ctx context.Context, dockerClient *client.Client, dockerCo
 	}
 	return containerIds
 }
+
+func TestStopTimeoutSecondsRoundsUp(t *testing.T) {
+	for gracePeriod, expected := range map[time.Duration]int{
+		0:                       0,
+		-time.Second:            0,
+		time.Millisecond:        1,
+		500 * time.Millisecond:  1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+		10 * time.Second:        10,
+	} {
+		if timeout := stopTimeoutSeconds(gracePeriod); timeout != expected {
+			t.Errorf("Expected a grace period of %v to stop with a timeout of %ds, got %ds", gracePeriod, expected, timeout)
+		}
+	}
+}
+
+func TestStopContainerForceKillsAfterGracePeriod(t *testing.T) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Skip("Skipping test: unable to connect to Docker")
+	}
+	ctx := context.Background()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		t.Skip("Skipping test: Docker daemon is not reachable")
+	}
+
+	imageName := "alpine:latest"
+	checkAndPullImage(t, ctx, dockerClient, imageName)
+
+	// The container ignores SIGTERM so it can only be stopped by SIGKILL
+	resp, err := dockerClient.ContainerCreate(ctx, &container.Config{
+		Image: imageName,
+		Cmd:   []string{"sh", "-c", "trap '' TERM; while true; do sleep 1; done"},
+	}, nil, nil, nil, "test-stop-grace-period")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer func() {
+		_ = dockerClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	if err := dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.StopGracePeriod = time.Second
+	kubelet, err := NewKubeletWithOptions("test-node", "http://fake-api-server-url", opts)
+	if err != nil {
+		t.Fatalf("Failed to create Kubelet: %v", err)
+	}
+
+	start := time.Now()
+	if err := kubelet.StopContainer(ctx, resp.ID); err != nil {
+		t.Fatalf("StopContainer failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < opts.StopGracePeriod {
+		t.Errorf("Expected container to be given %v to stop, stopped after %v", opts.StopGracePeriod, elapsed)
+	}
+
+	if _, err := dockerClient.ContainerInspect(ctx, resp.ID); !client.IsErrNotFound(err) {
+		t.Errorf("Expected container to be removed, got: %v", err)
+	}
+}
+
+func TestStopContainerIsIdempotentWithRealDocker(t *testing.T) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Skip("Skipping test: unable to connect to Docker")
+	}
+	ctx := context.Background()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		t.Skip("Skipping test: Docker daemon is not reachable")
+	}
+
+	imageName := "alpine:latest"
+	checkAndPullImage(t, ctx, dockerClient, imageName)
+
+	resp, err := dockerClient.ContainerCreate(ctx, &container.Config{
+		Image: imageName,
+		Cmd:   []string{"sleep", "60"},
+	}, nil, nil, nil, "test-stop-idempotent")
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer func() {
+		_ = dockerClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	opts := DefaultOptions()
+	opts.StopGracePeriod = time.Second
+	kubelet, err := NewKubeletWithOptions("test-node", "http://fake-api-server-url", opts)
+	if err != nil {
+		t.Fatalf("Failed to create Kubelet: %v", err)
+	}
+
+	if err := kubelet.StopContainer(ctx, resp.ID); err != nil {
+		t.Fatalf("StopContainer failed: %v", err)
+	}
+	// The container is gone, stopping it again succeeds
+	if err := kubelet.StopContainer(ctx, resp.ID); err != nil {
+		t.Errorf("Expected stopping a removed container to succeed, got: %v", err)
+	}
+}
+
+func TestContainerLabels(t *testing.T) {
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name: "web-abcde",
+			OwnerReferences: []api.OwnerReference{
+				{Kind: api.KindReplicaSet, Name: "web", UID: "uid-1", Controller: true},
+			},
+		},
+	}
+
+	kubelet := &Kubelet{opts: DefaultOptions()}
+	labels := kubelet.containerLabels(pod, "nginx")
+	expected := map[string]string{
+		labelPodName:       "web-abcde",
+		labelPodNamespace:  api.NamespaceDefault,
+		labelContainerName: "nginx",
+		labelOwnerKind:     api.KindReplicaSet,
+		labelOwnerName:     "web",
+		labelOwnerUID:      "uid-1",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, labels)
+	}
+
+	kubelet.opts.OwnerLabels = false
+	if _, ok := kubelet.containerLabels(pod, "nginx")[labelOwnerName]; ok {
+		t.Errorf("Expected no owner labels when disabled")
+	}
+}
+
+func TestPodLabelFilters(t *testing.T) {
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", Namespace: "team-a"}}
+
+	args := podLabelFilters(pod, filters.Arg("label", labelContainerName+"=nginx"))
+	expected := []string{
+		labelPodName + "=web",
+		labelPodNamespace + "=team-a",
+		labelContainerName + "=nginx",
+	}
+	for _, label := range expected {
+		if !args.ExactMatch("label", label) {
+			t.Errorf("Expected filter on label %s, got %v", label, args.Get("label"))
+		}
+	}
+	if args.ExactMatch("label", labelPodNamespace+"="+api.NamespaceDefault) {
+		t.Errorf("Expected no filter on the default namespace")
+	}
+}
+
+func TestStartContainerSetsOwnerLabelsWithRealDocker(t *testing.T) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Skip("Skipping test: unable to connect to Docker")
+	}
+	ctx := context.Background()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		t.Skip("Skipping test: Docker daemon is not reachable")
+	}
+
+	kubelet, err := NewKubelet("test-node", "http://fake-api-server-url")
+	if err != nil {
+		t.Fatalf("Failed to create Kubelet: %v", err)
+	}
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name: "test-owned-pod",
+			OwnerReferences: []api.OwnerReference{
+				{Kind: api.KindReplicaSet, Name: "test-rs", UID: "uid-1", Controller: true},
+			},
+		},
+	}
+	if err := kubelet.StartContainer(ctx, pod, "test-container", "alpine:latest"); err != nil {
+		t.Fatalf("StartContainer failed: %v", err)
+	}
+
+	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelOwnerName+"=test-rs")),
+	})
+	if err != nil {
+		t.Fatalf("Failed to list containers: %v", err)
+	}
+	defer func() {
+		for _, c := range containers {
+			_ = dockerClient.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
+		}
+	}()
+
+	if len(containers) != 1 {
+		t.Fatalf("Expected 1 container of the ReplicaSet, got %d", len(containers))
+	}
+	labels := containers[0].Labels
+	if labels[labelOwnerKind] != api.KindReplicaSet || labels[labelOwnerUID] != "uid-1" {
+		t.Errorf("Expected owner labels of the ReplicaSet, got %v", labels)
+	}
+}
+
+func TestStopWaitsForInFlightPodOperations(t *testing.T) {
+	kubelet := &Kubelet{opts: DefaultOptions(), pods: make(map[string]*api.Pod)}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	kubelet.goPodOperation(func(ctx context.Context) {
+		close(started)
+		<-release
+		close(finished)
+	})
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- kubelet.Stop(context.Background()) }()
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned before the pod operation finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// No pod operation is started once the kubelet is stopping
+	if kubelet.goPodOperation(func(ctx context.Context) {}) {
+		t.Errorf("Expected no pod operation to start while stopping")
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Expected Stop to succeed, got %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Errorf("Expected the pod operation to have finished when Stop returned")
+	}
+}
+
+func TestStopCancelsPodOperationsOnTimeout(t *testing.T) {
+	kubelet := &Kubelet{opts: DefaultOptions(), pods: make(map[string]*api.Pod)}
+
+	cancelled := make(chan struct{})
+	kubelet.goPodOperation(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := kubelet.Stop(ctx); err == nil {
+		t.Fatalf("Expected Stop to time out")
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the pod operation to be cancelled after the timeout")
+	}
+}
@@ -0,0 +1,80 @@
+package kubelet
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ErrInvalidLogConfig is returned for a container log configuration Docker can't apply
+var ErrInvalidLogConfig = errors.New("invalid container log config")
+
+// Log drivers the kubelet configures containers with
+const (
+	LogDriverJSONFile = "json-file"
+	LogDriverLocal    = "local"
+	LogDriverNone     = "none"
+)
+
+// logSizePattern matches the sizes Docker accepts for max-size, such as 512k, 10m or 1g
+var logSizePattern = regexp.MustCompile(`^[1-9][0-9]*[kmg]?$`)
+
+// LogConfig configures how the logs of the containers are stored on the node
+type LogConfig struct {
+	// Driver is the Docker log driver, json-file, local or none
+	Driver string
+	// MaxSize is the size a log file is rotated at, such as 10m. Empty never rotates.
+	MaxSize string
+	// MaxFile is how many rotated log files are kept
+	MaxFile int
+}
+
+// DefaultLogConfig returns the default log configuration, json-file logs rotated at 10m
+// with three files kept
+func DefaultLogConfig() LogConfig {
+	return LogConfig{
+		Driver:  LogDriverJSONFile,
+		MaxSize: "10m",
+		MaxFile: 3,
+	}
+}
+
+// Validate checks that the driver is supported and that its size limits are well-formed
+func (c LogConfig) Validate() error {
+	switch c.Driver {
+	case LogDriverJSONFile, LogDriverLocal:
+	case LogDriverNone:
+		if c.MaxSize != "" || c.MaxFile != 0 {
+			return fmt.Errorf("%w: the %s driver keeps no logs to limit", ErrInvalidLogConfig, c.Driver)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported driver %q", ErrInvalidLogConfig, c.Driver)
+	}
+
+	if c.MaxSize != "" && !logSizePattern.MatchString(c.MaxSize) {
+		return fmt.Errorf("%w: invalid max size %q", ErrInvalidLogConfig, c.MaxSize)
+	}
+	if c.MaxFile < 0 {
+		return fmt.Errorf("%w: negative max file count %d", ErrInvalidLogConfig, c.MaxFile)
+	}
+	if c.MaxFile > 1 && c.MaxSize == "" {
+		return fmt.Errorf("%w: max file count without a max size", ErrInvalidLogConfig)
+	}
+	return nil
+}
+
+// dockerLogConfig returns the Docker log configuration of the containers
+func (c LogConfig) dockerLogConfig() container.LogConfig {
+	config := map[string]string{}
+	if c.MaxSize != "" {
+		config["max-size"] = c.MaxSize
+	}
+	if c.MaxFile > 0 {
+		config["max-file"] = strconv.Itoa(c.MaxFile)
+	}
+	return container.LogConfig{Type: c.Driver, Config: config}
+}
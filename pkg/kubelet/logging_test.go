@@ -0,0 +1,86 @@
+package kubelet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+)
+
+func TestLogConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LogConfig
+		wantErr bool
+	}{
+		{name: "default", config: DefaultLogConfig()},
+		{name: "local driver", config: LogConfig{Driver: LogDriverLocal, MaxSize: "512k", MaxFile: 5}},
+		{name: "no rotation", config: LogConfig{Driver: LogDriverJSONFile}},
+		{name: "no logs", config: LogConfig{Driver: LogDriverNone}},
+		{name: "unsupported driver", config: LogConfig{Driver: "syslog"}, wantErr: true},
+		{name: "empty driver", config: LogConfig{MaxSize: "10m", MaxFile: 3}, wantErr: true},
+		{name: "malformed size", config: LogConfig{Driver: LogDriverJSONFile, MaxSize: "10mb", MaxFile: 3}, wantErr: true},
+		{name: "zero size", config: LogConfig{Driver: LogDriverJSONFile, MaxSize: "0", MaxFile: 3}, wantErr: true},
+		{name: "negative file count", config: LogConfig{Driver: LogDriverJSONFile, MaxSize: "10m", MaxFile: -1}, wantErr: true},
+		{name: "file count without size", config: LogConfig{Driver: LogDriverJSONFile, MaxFile: 3}, wantErr: true},
+		{name: "limits without logs", config: LogConfig{Driver: LogDriverNone, MaxSize: "10m"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLogConfig)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	opts := DefaultOptions()
+	opts.LogConfig.Driver = "syslog"
+	_, err := NewKubeletWithOptions("test-node", "http://fake-api-server-url", opts)
+	assert.ErrorIs(t, err, ErrInvalidLogConfig)
+}
+
+func TestStartContainerSetsLogConfigWithRealDocker(t *testing.T) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Skip("Skipping test: unable to connect to Docker")
+	}
+	ctx := context.Background()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		t.Skip("Skipping test: Docker daemon is not reachable")
+	}
+
+	opts := DefaultOptions()
+	opts.LogConfig = LogConfig{Driver: LogDriverJSONFile, MaxSize: "1m", MaxFile: 2}
+	kubelet, err := NewKubeletWithOptions("test-node", "http://fake-api-server-url", opts)
+	require.NoError(t, err)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "test-log-config-pod"}}
+	require.NoError(t, kubelet.StartContainer(ctx, pod, "test-container", "alpine:latest"))
+
+	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelPodName+"=test-log-config-pod")),
+	})
+	require.NoError(t, err)
+	defer func() {
+		for _, c := range containers {
+			_ = dockerClient.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
+		}
+	}()
+	require.Len(t, containers, 1)
+
+	inspect, err := dockerClient.ContainerInspect(ctx, containers[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, LogDriverJSONFile, inspect.HostConfig.LogConfig.Type)
+	assert.Equal(t, map[string]string{"max-size": "1m", "max-file": "2"}, inspect.HostConfig.LogConfig.Config)
+}
@@ -17,9 +17,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
 )
 
-func TestGetPodStatus(t *testing.T) {
+func TestGetPodPhase(t *testing.T) {
 	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	require.NoError(t, err)
 	defer dockerClient.Close()
@@ -32,7 +33,7 @@ func TestGetPodStatus(t *testing.T) {
 		name            string
 		containerNames  []string
 		setupContainers func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string
-		expectedStatus  api.PodStatus
+		expectedStatus  api.PodPhase
 	}{
 		{
 			name:           "All containers running",
@@ -108,6 +109,16 @@ func TestGetPodStatus(t *testing.T) {
 			},
 			expectedStatus: api.PodRunning,
 		},
+		{
+			name:           "Container disappeared",
+			containerNames: []string{"c1", "c2"},
+			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
+				return createContainers(t, ctx, dockerClient, []string{containerNames[0]}, func(config *container.Config) {
+					config.Cmd = []string{"echo", "success"}
+				})
+			},
+			expectedStatus: api.PodFailed,
+		},
 		{
 			name:           "No containers created",
 			containerNames: []string{},
@@ -135,19 +146,45 @@ func TestGetPodStatus(t *testing.T) {
 				pod.Spec.Containers[i] = api.Container{Name: tt.containerNames[i]}
 			}
 
-			status, err := kubelet.getPodStatus(ctx, pod)
+			status, err := kubelet.getPodPhase(ctx, pod)
 			require.NoError(t, err)
 			assert.Equal(t, tt.expectedStatus, status)
 		})
 	}
 }
 
+func TestDeterminePodPhase(t *testing.T) {
+	running := containerState{exists: true, running: true}
+	succeeded := containerState{exists: true}
+	failed := containerState{exists: true, exitCode: 1}
+	missing := containerState{exists: false}
+
+	tests := []struct {
+		name     string
+		states   []containerState
+		expected api.PodPhase
+	}{
+		{name: "running while any container runs", states: []containerState{running, failed, missing}, expected: api.PodRunning},
+		{name: "succeeded on clean exit", states: []containerState{succeeded, succeeded}, expected: api.PodSucceeded},
+		{name: "failed on non-zero exit", states: []containerState{succeeded, failed}, expected: api.PodFailed},
+		{name: "failed when a container disappeared", states: []containerState{succeeded, missing}, expected: api.PodFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, determinePodPhase(tt.states))
+		})
+	}
+}
+
 func createContainers(t *testing.T, ctx context.Context, dockerClient *client.Client, containerNames []string, configModifier func(*container.Config)) []string {
 	ids := make([]string, len(containerNames))
 	for i, name := range containerNames {
 		imageName := "alpine:latest"
+		// The kubelet finds the containers of a pod by their labels
 		config := &container.Config{
-			Image: imageName,
+			Image:  imageName,
+			Labels: map[string]string{labelPodName: "test-pod", labelPodNamespace: api.NamespaceDefault, labelContainerName: name},
 		}
 		configModifier(config)
 		checkAndPullImage(t, ctx, dockerClient, imageName)
@@ -176,6 +213,20 @@ func checkAndPullImage(t *testing.T, ctx context.Context, dockerClient *client.C
 	}
 }
 
+func TestSetPodPhase(t *testing.T) {
+	pod := &api.Pod{Status: api.PodStatus{Phase: api.PodPending}}
+
+	assert.True(t, setPodPhase(pod, api.PodRunning))
+	assert.Equal(t, api.PodRunning, pod.Status.Phase)
+	assert.True(t, conditions.IsConditionTrue(pod.Status.Conditions, api.PodConditionContainersReady))
+	assert.True(t, conditions.IsConditionTrue(pod.Status.Conditions, api.PodConditionReady))
+	assert.False(t, setPodPhase(pod, api.PodRunning), "an unchanged status is not reported again")
+
+	assert.True(t, setPodPhase(pod, api.PodSucceeded))
+	assert.False(t, conditions.IsConditionTrue(pod.Status.Conditions, api.PodConditionContainersReady))
+	assert.False(t, pod.IsReady())
+}
+
 func removeContainers(t *testing.T, ctx context.Context, dockerClient *client.Client, containerIDs []string) {
 	for _, id := range containerIDs {
 		err := dockerClient.ContainerRemove(ctx, id, container.RemoveOptions{Force: true})
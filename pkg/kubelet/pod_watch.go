@@ -0,0 +1,240 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// podWatchHandlers are invoked for the pods streamed by a pod watch
+type podWatchHandlers struct {
+	// synced is called with the pods listed when the watch starts
+	synced func(pods []*api.Pod)
+	// changed is called for every change of a pod after the list
+	changed func(eventType api.WatchEventType, pod *api.Pod)
+}
+
+// watchPods runs the pods assigned to the node and stops the pods that are deleted or moved
+// to another node, as soon as the API server reports the change. Every watch starts with a full
+// list of the pods, so that a change missed while the watch was down is caught up on.
+func (k *Kubelet) watchPods(ctx context.Context) {
+	k.runPodWatch(ctx, podWatchHandlers{
+		synced: func(pods []*api.Pod) { k.syncPods(ctx, pods) },
+		changed: func(eventType api.WatchEventType, pod *api.Pod) {
+			k.handlePodChange(ctx, eventType, pod)
+		},
+	})
+}
+
+// runPodWatch watches the pods until the context is done, watching again after
+// PodWatchRetryInterval when the watch fails and right away when it ends
+func (k *Kubelet) runPodWatch(ctx context.Context, handlers podWatchHandlers) {
+	for {
+		err := k.watchPodsOnce(ctx, handlers)
+		if ctx.Err() != nil {
+			return
+		}
+
+		interval := time.Duration(0)
+		if err != nil {
+			log.Printf("Error watching pods of node %s, retrying in %v: %v", k.nodeName, k.opts.PodWatchRetryInterval, err)
+			interval = k.opts.PodWatchRetryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// watchPodsOnce lists and watches the pods on a single connection to the API server. The pods
+// are listed up to the first BOOKMARK event, then their changes are streamed. It returns nil
+// once the API server ends the watch or PodResyncPeriod has passed.
+func (k *Kubelet) watchPodsOnce(ctx context.Context, handlers podWatchHandlers) error {
+	if k.opts.PodResyncPeriod > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, k.opts.PodResyncPeriod)
+		defer cancel()
+	}
+
+	// Only the pods of the node are streamed, a pod leaving the node is streamed as deleted
+	query := url.Values{
+		"watch":             {"true"},
+		"sendInitialEvents": {"true"},
+		"fieldSelector":     {"spec.nodeName=" + k.nodeName},
+	}
+	watchURL := fmt.Sprintf("http://%s/api/v1/pods?%s", k.apiServerURL, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, watchURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return watchEndError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to watch pods, status code: %d", resp.StatusCode)
+	}
+
+	var listed []*api.Pod
+	synced := false
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event api.WatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return watchEndError(ctx, err)
+		}
+
+		if event.Type == api.WatchBookmark {
+			if !synced {
+				handlers.synced(listed)
+				synced = true
+			}
+			continue
+		}
+		if event.Type == api.WatchError {
+			// The watch is over, watching again starts with a fresh list of the pods
+			var status api.WatchStatus
+			if err := json.Unmarshal(event.Object, &status); err != nil {
+				return fmt.Errorf("pod watch failed: %s", event.Object)
+			}
+			return fmt.Errorf("pod watch failed with status %d: %s", status.Code, status.Message)
+		}
+
+		pod := new(api.Pod)
+		if err := json.Unmarshal(event.Object, pod); err != nil {
+			log.Printf("Error decoding pod of %s watch event: %v", event.Type, err)
+			continue
+		}
+		if synced {
+			handlers.changed(event.Type, pod)
+		} else {
+			listed = append(listed, pod)
+		}
+	}
+}
+
+// watchEndError returns the error a watch ended with, nil if it ended because its context did
+func watchEndError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// syncPods runs the listed pods assigned to the node and stops the pods the kubelet runs that
+// are no longer assigned to it
+func (k *Kubelet) syncPods(ctx context.Context, pods []*api.Pod) {
+	k.podsMutex.Lock()
+	defer k.podsMutex.Unlock()
+
+	var assigned []*api.Pod
+	for _, pod := range pods {
+		if pod.NodeName == k.nodeName {
+			assigned = append(assigned, pod)
+		}
+	}
+
+	for _, pod := range k.stalePods(assigned) {
+		k.removePod(pod)
+	}
+
+	if err := k.runNewPods(ctx, assigned); err != nil {
+		log.Printf("Error running new pods: %v", err)
+	}
+}
+
+// stalePods diffs the pods the kubelet runs against the desired pods, returning the ones
+// that are not desired anymore. The caller holds podsMutex.
+func (k *Kubelet) stalePods(desired []*api.Pod) []*api.Pod {
+	keys := make(map[string]bool, len(desired))
+	for _, pod := range desired {
+		keys[podKey(pod)] = true
+	}
+
+	var stale []*api.Pod
+	for _, pod := range k.pods {
+		if !keys[podKey(pod)] {
+			stale = append(stale, pod)
+		}
+	}
+	return stale
+}
+
+// podKey identifies a pod by its namespace and name
+func podKey(pod *api.Pod) string {
+	return api.NamespaceOrDefault(pod.Namespace) + "/" + pod.Name
+}
+
+// trackedPod returns the pod the kubelet runs with the podKey
+func (k *Kubelet) trackedPod(key string) (*api.Pod, bool) {
+	k.podsMutex.RLock()
+	defer k.podsMutex.RUnlock()
+
+	pod, ok := k.pods[key]
+	return pod, ok
+}
+
+// trackedPods returns the pods the kubelet runs
+func (k *Kubelet) trackedPods() []*api.Pod {
+	k.podsMutex.RLock()
+	defer k.podsMutex.RUnlock()
+
+	pods := make([]*api.Pod, 0, len(k.pods))
+	for _, pod := range k.pods {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// handlePodChange runs a pod newly assigned to the node and stops a pod the kubelet runs once
+// it is deleted or assigned to another node
+func (k *Kubelet) handlePodChange(ctx context.Context, eventType api.WatchEventType, pod *api.Pod) {
+	k.podsMutex.Lock()
+	defer k.podsMutex.Unlock()
+
+	running, tracked := k.pods[podKey(pod)]
+
+	switch {
+	case eventType == api.WatchDeleted:
+		if tracked {
+			k.removePod(running)
+		}
+	case pod.NodeName == k.nodeName:
+		if err := k.runNewPods(ctx, []*api.Pod{pod}); err != nil {
+			log.Printf("Error running new pods: %v", err)
+		}
+	case tracked:
+		k.removePod(running)
+	}
+}
+
+// removePod forgets a pod that is no longer assigned to the node and stops its containers.
+// The caller holds podsMutex.
+func (k *Kubelet) removePod(pod *api.Pod) {
+	log.Printf("Pod %s is no longer assigned to node %s, stopping it", pod.Name, k.nodeName)
+	delete(k.pods, podKey(pod))
+	k.startedPods.Delete(podKey(pod))
+
+	k.goPodOperation(func(ctx context.Context) {
+		if err := k.stopPodContainers(ctx, pod); err != nil {
+			log.Printf("Error stopping containers of pod %s: %v", pod.Name, err)
+		}
+	})
+}
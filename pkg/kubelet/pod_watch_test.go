@@ -0,0 +1,235 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+// podWatchRecorder records what a pod watch reports
+type podWatchRecorder struct {
+	mutex   sync.Mutex
+	syncs   [][]string
+	changes []string
+}
+
+func (r *podWatchRecorder) handlers() podWatchHandlers {
+	return podWatchHandlers{
+		synced: func(pods []*api.Pod) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			names := []string{}
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			r.syncs = append(r.syncs, names)
+		},
+		changed: func(eventType api.WatchEventType, pod *api.Pod) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.changes = append(r.changes, string(eventType)+" "+pod.Name+" on "+pod.NodeName)
+		},
+	}
+}
+
+func (r *podWatchRecorder) snapshot() ([][]string, []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([][]string(nil), r.syncs...), append([]string(nil), r.changes...)
+}
+
+func TestRunPodWatch(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		store := storage.NewEtcdStorage(cli)
+		podRegistry := registry.NewPodRegistry(store)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The API server can be taken down to cut the watch and reject the watches after it
+		var down atomic.Bool
+		apiHandler := server.NewAPIServer(store).Handler()
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			apiHandler.ServeHTTP(w, r)
+		}))
+		defer apiServer.Close()
+
+		createPod := func(name, nodeName string) {
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+				NodeName:   nodeName,
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		}
+		createPod("listed", "watch-node")
+
+		opts := DefaultOptions()
+		opts.PodWatchRetryInterval = 20 * time.Millisecond
+		kubelet := &Kubelet{
+			nodeName:     "watch-node",
+			apiServerURL: strings.TrimPrefix(apiServer.URL, "http://"),
+			pods:         make(map[string]*api.Pod),
+			opts:         opts,
+		}
+
+		recorder := &podWatchRecorder{}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			kubelet.runPodWatch(ctx, recorder.handlers())
+		}()
+
+		require.Eventually(t, func() bool {
+			syncs, _ := recorder.snapshot()
+			return len(syncs) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// Changes are reported as soon as they are made, pods of other nodes aren't watched
+		createPod("elsewhere", "other-node")
+		createPod("assigned", "watch-node")
+		require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, "listed"))
+		require.Eventually(t, func() bool {
+			_, changes := recorder.snapshot()
+			return len(changes) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// A pod deleted while the watch is down is missing from the list of the next watch
+		down.Store(true)
+		apiServer.CloseClientConnections()
+		require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, "assigned"))
+		createPod("missed", "watch-node")
+		time.Sleep(100 * time.Millisecond)
+		down.Store(false)
+
+		require.Eventually(t, func() bool {
+			syncs, _ := recorder.snapshot()
+			return len(syncs) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+
+		cancel()
+		<-done
+
+		syncs, changes := recorder.snapshot()
+		assert.Equal(t, [][]string{{"listed"}, {"missed"}}, syncs)
+		assert.Equal(t, []string{"ADDED assigned on watch-node", "DELETED listed on watch-node"}, changes)
+	})
+}
+
+func TestWatchPodsOnceEndsOnErrorEvent(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"BOOKMARK","object":null,"resourceVersion":"5"}`)
+		fmt.Fprintln(w, `{"type":"ERROR","object":{"code":410,"message":"revision has been compacted"}}`)
+		fmt.Fprintln(w, `{"type":"ADDED","object":{"metadata":{"name":"after-error"}},"resourceVersion":"6"}`)
+	}))
+	defer apiServer.Close()
+
+	kubelet := &Kubelet{
+		nodeName:     "watch-node",
+		apiServerURL: strings.TrimPrefix(apiServer.URL, "http://"),
+		pods:         make(map[string]*api.Pod),
+		opts:         DefaultOptions(),
+	}
+
+	// The watch fails, so that the pods are listed again by the next one
+	recorder := &podWatchRecorder{}
+	err := kubelet.watchPodsOnce(context.Background(), recorder.handlers())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "410")
+
+	syncs, changes := recorder.snapshot()
+	assert.Equal(t, [][]string{{}}, syncs)
+	assert.Empty(t, changes)
+}
+
+func TestHandlePodChange(t *testing.T) {
+	running := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", Namespace: api.NamespaceDefault}, NodeName: "node-1"}
+	kubelet := &Kubelet{
+		nodeName: "node-1",
+		pods:     map[string]*api.Pod{podKey(running): running},
+	}
+	// Stopped kubelets start no pod operations, so the containers aren't looked up
+	kubelet.lifecycle()
+	kubelet.cancel()
+	ctx := context.Background()
+
+	// A pod of the same name in another namespace is not the running pod
+	other := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", Namespace: "team-a"}, NodeName: "node-2"}
+	kubelet.handlePodChange(ctx, api.WatchModified, other)
+	kubelet.handlePodChange(ctx, api.WatchDeleted, other)
+	assert.Contains(t, kubelet.pods, "default/web")
+	assert.NotContains(t, kubelet.pods, "team-a/web")
+
+	// A pod moved to another node is stopped
+	moved := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", Namespace: api.NamespaceDefault}, NodeName: "node-2"}
+	kubelet.handlePodChange(ctx, api.WatchModified, moved)
+	assert.NotContains(t, kubelet.pods, "default/web")
+}
+
+func TestTrackedPodsWhileHandlingPodChanges(t *testing.T) {
+	kubelet := &Kubelet{nodeName: "node-1", pods: make(map[string]*api.Pod)}
+	kubelet.lifecycle()
+	kubelet.cancel()
+	ctx := context.Background()
+
+	// The status updates read the pods while the watch changes them, run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			for _, pod := range kubelet.trackedPods() {
+				kubelet.trackedPod(podKey(pod))
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: fmt.Sprintf("web-%d", i)}, NodeName: "node-2"}
+		kubelet.podsMutex.Lock()
+		kubelet.pods[podKey(pod)] = pod
+		kubelet.podsMutex.Unlock()
+		kubelet.handlePodChange(ctx, api.WatchModified, pod)
+	}
+	<-done
+
+	assert.Empty(t, kubelet.trackedPods())
+}
+
+func TestStalePods(t *testing.T) {
+	web := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", Namespace: api.NamespaceDefault}}
+	db := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "db"}}
+	kubelet := &Kubelet{pods: map[string]*api.Pod{podKey(web): web, podKey(db): db}}
+
+	// Pods with an empty namespace are in the default one
+	desired := []*api.Pod{
+		{ObjectMeta: api.ObjectMeta{Name: "web"}},
+		{ObjectMeta: api.ObjectMeta{Name: "db", Namespace: api.NamespaceDefault}},
+	}
+	assert.Empty(t, kubelet.stalePods(desired))
+
+	// A desired pod of the same name in another namespace doesn't keep the running pod
+	desired = []*api.Pod{
+		{ObjectMeta: api.ObjectMeta{Name: "web"}},
+		{ObjectMeta: api.ObjectMeta{Name: "db", Namespace: "team-a"}},
+	}
+	assert.Equal(t, []*api.Pod{db}, kubelet.stalePods(desired))
+
+	assert.ElementsMatch(t, []*api.Pod{web, db}, kubelet.stalePods(nil))
+}
@@ -0,0 +1,96 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/docker/docker/api/types/registry"
+
+	"gokube/pkg/api"
+)
+
+// ErrPullSecretNotFound is returned for a pod referring to an image pull secret the kubelet
+// isn't configured with
+var ErrPullSecretNotFound = errors.New("image pull secret not found")
+
+// RegistryCredentials are the credentials of an image pull secret
+type RegistryCredentials struct {
+	// Registry is the host the credentials are for, such as registry.example.com. Empty uses
+	// them for every registry.
+	Registry string `json:"registry,omitempty"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoadPullSecrets reads the image pull secrets from a JSON file mapping the secret names to
+// their credentials
+func LoadPullSecrets(path string) (map[string]RegistryCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image pull secrets: %v", err)
+	}
+	var secrets map[string]RegistryCredentials
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse image pull secrets %s: %v", path, err)
+	}
+	return secrets, nil
+}
+
+// checkPullSecrets checks that the kubelet is configured with every image pull secret the
+// pod refers to
+func (k *Kubelet) checkPullSecrets(pod *api.Pod) error {
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		if _, ok := k.opts.PullSecrets[ref.Name]; !ok {
+			return fmt.Errorf("%w: %s, referenced by pod %s", ErrPullSecretNotFound, ref.Name, pod.Name)
+		}
+	}
+	return nil
+}
+
+// registryAuth returns the base64 encoded credentials the image of the pod is pulled with.
+// They are those of the first image pull secret of the pod that is for the registry of the
+// image, or empty if there is none, in which case the image is pulled anonymously.
+func (k *Kubelet) registryAuth(pod *api.Pod, image string) (string, error) {
+	if len(pod.Spec.ImagePullSecrets) == 0 {
+		return "", nil
+	}
+	host, err := api.ImageRegistry(image)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		creds, ok := k.opts.PullSecrets[ref.Name]
+		if !ok {
+			return "", fmt.Errorf("%w: %s, referenced by pod %s", ErrPullSecretNotFound, ref.Name, pod.Name)
+		}
+		if creds.Registry != "" && creds.Registry != host {
+			continue
+		}
+		return registry.EncodeAuthConfig(registry.AuthConfig{
+			Username:      creds.Username,
+			Password:      creds.Password,
+			ServerAddress: host,
+		})
+	}
+	return "", nil
+}
+
+// pullImage pulls the image of a container of the pod with the pull secrets of the pod
+func (k *Kubelet) pullImage(ctx context.Context, pod *api.Pod, image string) error {
+	auth, err := k.registryAuth(pod, image)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+
+	log.Printf("Pulling image: %s", image)
+	if err := k.runtime.PullImage(ctx, image, auth); err != nil {
+		return fmt.Errorf("failed to pull image %s: %v", image, err)
+	}
+	log.Printf("Successfully pulled image: %s", image)
+	return nil
+}
@@ -0,0 +1,108 @@
+package kubelet
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dockerregistry "github.com/docker/docker/api/types/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/api/server"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func podWithPullSecrets(name, image string, secrets ...string) *api.Pod {
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: api.NamespaceDefault},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: image}}},
+	}
+	for _, secret := range secrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, api.LocalObjectReference{Name: secret})
+	}
+	return pod
+}
+
+func TestPullImagePassesPullSecretAuth(t *testing.T) {
+	runtime := &fakeRuntime{}
+	opts := DefaultOptions()
+	opts.PullSecrets = map[string]RegistryCredentials{
+		"other":   {Registry: "other.example.com", Username: "other", Password: "other-password"},
+		"private": {Registry: "registry.example.com", Username: "deploy", Password: "s3cret"},
+	}
+	kubelet := &Kubelet{runtime: runtime, opts: opts}
+	ctx := context.Background()
+
+	pod := podWithPullSecrets("private", "registry.example.com/team/app:v1", "other", "private")
+	require.NoError(t, kubelet.pullImage(ctx, pod, pod.Spec.Containers[0].Image))
+
+	// Images of other registries are pulled anonymously
+	require.NoError(t, kubelet.pullImage(ctx, pod, "docker.io/library/nginx:latest"))
+
+	require.Len(t, runtime.pulls, 2)
+	assert.Equal(t, "registry.example.com/team/app:v1", runtime.pulls[0].image)
+	auth, err := dockerregistry.DecodeAuthConfig(runtime.pulls[0].registryAuth)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy", auth.Username)
+	assert.Equal(t, "s3cret", auth.Password)
+	assert.Equal(t, "registry.example.com", auth.ServerAddress)
+	assert.Empty(t, runtime.pulls[1].registryAuth)
+
+	// A pod referring to a secret that isn't configured isn't pulled
+	missing := podWithPullSecrets("missing", "registry.example.com/team/app:v1", "missing")
+	assert.ErrorIs(t, kubelet.pullImage(ctx, missing, missing.Spec.Containers[0].Image), ErrPullSecretNotFound)
+	assert.Len(t, runtime.pulls, 2)
+}
+
+func TestRunNewPodsRejectsPodWithMissingPullSecret(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		store := storage.NewEtcdStorage(cli)
+		apiServer := httptest.NewServer(server.NewAPIServer(store).Handler())
+		defer apiServer.Close()
+		podRegistry := registry.NewPodRegistry(store)
+		ctx := context.Background()
+
+		kubelet := &Kubelet{
+			nodeName:     "pull-secret-node",
+			apiServerURL: strings.TrimPrefix(apiServer.URL, "http://"),
+			pods:         make(map[string]*api.Pod),
+			runtime:      &fakeRuntime{},
+			opts:         DefaultOptions(),
+		}
+
+		pod := podWithPullSecrets("missing-secret", "registry.example.com/team/app:v1", "private")
+		require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		require.NoError(t, kubelet.runNewPods(ctx, []*api.Pod{pod}))
+		assert.NotContains(t, kubelet.pods, "default/missing-secret")
+
+		stored, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "missing-secret")
+		require.NoError(t, err)
+		admitted := conditions.GetCondition(stored.Status.Conditions, api.PodConditionAdmitted)
+		require.NotNil(t, admitted)
+		assert.Equal(t, api.ConditionFalse, admitted.Status)
+		assert.Equal(t, reasonPullSecretNotFound, admitted.Reason)
+	})
+}
+
+func TestLoadPullSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pull-secrets.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"private": {"registry": "registry.example.com", "username": "deploy", "password": "s3cret"}}`), 0o600))
+
+	secrets, err := LoadPullSecrets(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]RegistryCredentials{
+		"private": {Registry: "registry.example.com", Username: "deploy", Password: "s3cret"},
+	}, secrets)
+
+	require.NoError(t, os.WriteFile(path, []byte(`not json`), 0o600))
+	_, err = LoadPullSecrets(path)
+	assert.Error(t, err)
+}
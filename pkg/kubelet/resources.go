@@ -0,0 +1,183 @@
+package kubelet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+)
+
+// ResourceProvider reports the resources of the host the kubelet runs on
+type ResourceProvider interface {
+	// Capacity returns the total resources of the host
+	Capacity(ctx context.Context) (api.ResourceList, error)
+	// Usage returns the resources currently used by the pods on the host
+	Usage(ctx context.Context) (api.ResourceList, error)
+}
+
+// dockerResourceProvider reports the resources of the Docker host.
+// Only the memory usage of containers is sampled, so allocatable CPU stays at capacity.
+type dockerResourceProvider struct {
+	dockerClient *client.Client
+}
+
+func (p *dockerResourceProvider) Capacity(ctx context.Context) (api.ResourceList, error) {
+	info, err := p.dockerClient.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Docker host info: %v", err)
+	}
+
+	return api.ResourceList{
+		api.ResourceCPU:    int64(info.NCPU) * 1000,
+		api.ResourceMemory: info.MemTotal,
+	}, nil
+}
+
+func (p *dockerResourceProvider) Usage(ctx context.Context) (api.ResourceList, error) {
+	containers, err := p.dockerClient.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", labelPodName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	usage := api.ResourceList{api.ResourceCPU: 0, api.ResourceMemory: 0}
+	for _, c := range containers {
+		stats, err := p.containerStats(ctx, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		usage[api.ResourceMemory] += int64(stats.MemoryStats.Usage)
+	}
+
+	return usage, nil
+}
+
+func (p *dockerResourceProvider) containerStats(ctx context.Context, containerID string) (*types.Stats, error) {
+	resp, err := p.dockerClient.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats of container %s: %v", containerID, err)
+	}
+	defer resp.Body.Close()
+
+	stats := new(types.Stats)
+	if err := json.NewDecoder(resp.Body).Decode(stats); err != nil {
+		return nil, fmt.Errorf("failed to decode stats of container %s: %v", containerID, err)
+	}
+	return stats, nil
+}
+
+// allocatable returns the resources of capacity that aren't in use, never less than zero
+func allocatable(capacity, usage api.ResourceList) api.ResourceList {
+	free := make(api.ResourceList, len(capacity))
+	for name, quantity := range capacity {
+		free[name] = max(quantity-usage[name], 0)
+	}
+	return free
+}
+
+// nodeStatus returns the status the kubelet reports for its node, with the current capacity and
+// allocatable resources of the host
+func (k *Kubelet) nodeStatus(ctx context.Context) (*api.Node, error) {
+	node := &api.Node{
+		ObjectMeta: api.ObjectMeta{
+			Name: k.nodeName,
+		},
+		Status: api.NodeReady,
+	}
+
+	// The resources of the host are unknown until the container runtime is reachable
+	if err := k.runtime.Ping(ctx); err != nil {
+		node.Status = api.NodeNotReady
+		conditions.SetCondition(&node.Conditions, api.Condition{
+			Type:    api.NodeConditionReady,
+			Status:  api.ConditionFalse,
+			Reason:  "ContainerRuntimeUnavailable",
+			Message: fmt.Sprintf("container runtime is unreachable: %v", err),
+		})
+		return node, nil
+	}
+
+	conditions.SetCondition(&node.Conditions, api.Condition{
+		Type:    api.NodeConditionReady,
+		Status:  api.ConditionTrue,
+		Reason:  "KubeletReady",
+		Message: "kubelet is posting ready status",
+	})
+
+	capacity, err := k.resources.Capacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := k.resources.Usage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	node.Capacity = capacity
+	node.Allocatable = allocatable(capacity, usage)
+
+	return node, nil
+}
+
+// updateNodeStatuses periodically reports the node status, so that the scheduler sees the
+// resources still free on the host as pods consume them
+func (k *Kubelet) updateNodeStatuses(ctx context.Context) {
+	ticker := time.NewTicker(k.opts.NodeStatusUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.updateNodeStatus(ctx); err != nil {
+				log.Printf("Error updating status for node %s: %v", k.nodeName, err)
+			}
+		}
+	}
+}
+
+func (k *Kubelet) updateNodeStatus(ctx context.Context) error {
+	node, err := k.nodeStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get node status: %w", err)
+	}
+
+	// The status subresource leaves the spec of the node, such as a cordon, untouched
+	url := fmt.Sprintf("http://%s/api/v1/nodes/%s/status", k.apiServerURL, k.nodeName)
+
+	jsonData, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to API server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update node status, status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
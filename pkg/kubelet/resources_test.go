@@ -0,0 +1,81 @@
+package kubelet
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+// fakeResourceProvider reports a fixed capacity and a usage set by the test
+type fakeResourceProvider struct {
+	capacity api.ResourceList
+	usage    api.ResourceList
+}
+
+func (p *fakeResourceProvider) Capacity(ctx context.Context) (api.ResourceList, error) {
+	return p.capacity, nil
+}
+
+func (p *fakeResourceProvider) Usage(ctx context.Context) (api.ResourceList, error) {
+	return p.usage, nil
+}
+
+func TestUpdateNodeStatus_AllocatableFollowsUsage(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		store := storage.NewEtcdStorage(cli)
+		apiServer := httptest.NewServer(server.NewAPIServer(store).Handler())
+		defer apiServer.Close()
+		nodeRegistry := registry.NewNodeRegistry(store)
+		ctx := context.Background()
+
+		resources := &fakeResourceProvider{
+			capacity: api.ResourceList{api.ResourceCPU: 4000, api.ResourceMemory: 8 << 30},
+			usage:    api.ResourceList{},
+		}
+		kubelet := &Kubelet{
+			nodeName:     "resource-node",
+			apiServerURL: strings.TrimPrefix(apiServer.URL, "http://"),
+			pods:         make(map[string]*api.Pod),
+			resources:    resources,
+			runtime:      &fakeRuntime{},
+			opts:         DefaultOptions(),
+		}
+		require.NoError(t, kubelet.registerNode())
+
+		node, err := nodeRegistry.GetNode(ctx, "resource-node")
+		require.NoError(t, err)
+		assert.Equal(t, resources.capacity, node.Capacity)
+		assert.Equal(t, resources.capacity, node.Allocatable)
+
+		previous := node.Allocatable
+		for _, memoryUsage := range []int64{1 << 30, 4 << 30, 7 << 30} {
+			resources.usage = api.ResourceList{api.ResourceCPU: 500, api.ResourceMemory: memoryUsage}
+			require.NoError(t, kubelet.updateNodeStatus(ctx))
+
+			node, err := nodeRegistry.GetNode(ctx, "resource-node")
+			require.NoError(t, err)
+			assert.Equal(t, resources.capacity, node.Capacity)
+			assert.Equal(t, int64(8<<30)-memoryUsage, node.Allocatable[api.ResourceMemory])
+			assert.Less(t, node.Allocatable[api.ResourceMemory], previous[api.ResourceMemory])
+			assert.Equal(t, int64(3500), node.Allocatable[api.ResourceCPU])
+			previous = node.Allocatable
+		}
+
+		// Usage beyond capacity leaves nothing allocatable
+		resources.usage = api.ResourceList{api.ResourceMemory: 9 << 30}
+		require.NoError(t, kubelet.updateNodeStatus(ctx))
+		node, err = nodeRegistry.GetNode(ctx, "resource-node")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), node.Allocatable[api.ResourceMemory])
+	})
+}
@@ -0,0 +1,65 @@
+package kubelet
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// ContainerRuntime is the container runtime the kubelet runs pods with
+type ContainerRuntime interface {
+	// Ping checks that the runtime is reachable
+	Ping(ctx context.Context) error
+	// PullImage pulls the image, authenticating with the base64 encoded registry credentials
+	// when registryAuth isn't empty
+	PullImage(ctx context.Context, imageName, registryAuth string) error
+}
+
+// dockerRuntime is the Docker daemon
+type dockerRuntime struct {
+	dockerClient *client.Client
+}
+
+func (r *dockerRuntime) Ping(ctx context.Context) error {
+	_, err := r.dockerClient.Ping(ctx)
+	return err
+}
+
+func (r *dockerRuntime) PullImage(ctx context.Context, imageName, registryAuth string) error {
+	out, err := r.dockerClient.ImagePull(ctx, imageName, image.PullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(os.Stdout, out)
+	return err
+}
+
+// waitForRuntime retries the container runtime every RuntimeRetryInterval until it is
+// reachable, then reports the node Ready without waiting for the next node status update
+func (k *Kubelet) waitForRuntime(ctx context.Context) error {
+	ticker := time.NewTicker(k.opts.RuntimeRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		err := k.runtime.Ping(ctx)
+		if err == nil {
+			break
+		}
+		log.Printf("Container runtime is unreachable, retrying in %v: %v", k.opts.RuntimeRetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	log.Printf("Container runtime is reachable, node %s is ready", k.nodeName)
+	return k.updateNodeStatus(ctx)
+}
@@ -0,0 +1,102 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/api/server"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+// fakeRuntime is a container runtime that is unreachable until the test makes it reachable.
+// It records the images it is asked to pull.
+type fakeRuntime struct {
+	unavailable atomic.Bool
+
+	mu    sync.Mutex
+	pulls []fakePull
+}
+
+// fakePull is an image pull made by the kubelet
+type fakePull struct {
+	image        string
+	registryAuth string
+}
+
+func (r *fakeRuntime) Ping(ctx context.Context) error {
+	if r.unavailable.Load() {
+		return errors.New("cannot connect to the container runtime")
+	}
+	return nil
+}
+
+func (r *fakeRuntime) PullImage(ctx context.Context, imageName, registryAuth string) error {
+	if err := r.Ping(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pulls = append(r.pulls, fakePull{image: imageName, registryAuth: registryAuth})
+	return nil
+}
+
+func TestKubeletRegistersNotReadyUntilRuntimeIsReachable(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		store := storage.NewEtcdStorage(cli)
+		apiServer := httptest.NewServer(server.NewAPIServer(store).Handler())
+		defer apiServer.Close()
+		nodeRegistry := registry.NewNodeRegistry(store)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		runtime := &fakeRuntime{}
+		runtime.unavailable.Store(true)
+		opts := DefaultOptions()
+		opts.RuntimeRetryInterval = 50 * time.Millisecond
+		kubelet := &Kubelet{
+			nodeName:     "runtime-node",
+			apiServerURL: strings.TrimPrefix(apiServer.URL, "http://"),
+			pods:         make(map[string]*api.Pod),
+			resources: &fakeResourceProvider{
+				capacity: api.ResourceList{api.ResourceCPU: 2000, api.ResourceMemory: 4 << 30},
+				usage:    api.ResourceList{},
+			},
+			runtime: runtime,
+			opts:    opts,
+		}
+		require.NoError(t, kubelet.registerNode())
+
+		node, err := nodeRegistry.GetNode(ctx, "runtime-node")
+		require.NoError(t, err)
+		assert.False(t, node.IsReady())
+		ready := conditions.GetCondition(node.Conditions, api.NodeConditionReady)
+		require.NotNil(t, ready)
+		assert.Equal(t, "ContainerRuntimeUnavailable", ready.Reason)
+
+		done := make(chan error, 1)
+		go func() { done <- kubelet.waitForRuntime(ctx) }()
+
+		time.Sleep(200 * time.Millisecond)
+		runtime.unavailable.Store(false)
+		require.NoError(t, <-done)
+
+		node, err = nodeRegistry.GetNode(ctx, "runtime-node")
+		require.NoError(t, err)
+		assert.True(t, node.IsReady())
+		assert.Equal(t, api.NodeReady, node.Status)
+		assert.Equal(t, int64(2000), node.Capacity[api.ResourceCPU])
+	})
+}
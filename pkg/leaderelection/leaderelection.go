@@ -0,0 +1,263 @@
+// Package leaderelection elects a single active instance among the replicas of a component,
+// such as the controller or the scheduler, so that running several of them for availability
+// doesn't make them act twice.
+//
+// The leader holds a well-known key attached to an etcd lease. Candidates create the key in a
+// transaction that only succeeds if it doesn't exist, and keep retrying while another instance
+// holds it. The leader renews its lease every RetryPeriod. If it can't renew it for RenewDeadline,
+// such as when it is partitioned from etcd, it stops leading before the lease expires after
+// LeaseDuration and another instance takes over.
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/etcdclient"
+)
+
+// KeyPrefix is the prefix of the keys held by the leaders, followed by the name of the election
+const KeyPrefix = "/leaderelection/"
+
+var (
+	// ErrInvalidOptions is returned for options whose durations can't be honored
+	ErrInvalidOptions = errors.New("invalid leader election options")
+	// ErrLeadershipLost is returned by Run once the leader could no longer renew its lease
+	ErrLeadershipLost = errors.New("leadership lost")
+)
+
+// Callbacks are invoked as the elector gains and loses the leadership
+type Callbacks struct {
+	// OnStartedLeading is called in its own goroutine once the elector leads. Its context is
+	// cancelled when the elector stops leading.
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading is called once the elector stopped leading, after it led
+	OnStoppedLeading func()
+}
+
+// Options configures the leader election
+type Options struct {
+	// Identity identifies the candidate in the key it holds as the leader
+	Identity string
+	// LeaseDuration is the TTL of the lease of the leader, how long the other candidates wait
+	// to take over from a leader that stopped renewing. It is rounded up to whole seconds.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader keeps leading without renewing its lease. It is
+	// shorter than LeaseDuration, so that the leader stops before another one can start.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often the leader renews its lease and the candidates try to acquire it
+	RetryPeriod time.Duration
+}
+
+// DefaultOptions returns the default leader election configuration
+func DefaultOptions() Options {
+	return Options{
+		Identity:      defaultIdentity(),
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+// defaultIdentity returns the host name and process id, unique for the replicas of a component
+func defaultIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s_%d", hostname, os.Getpid())
+}
+
+// validate checks that the durations leave the leader time to step down before its lease expires
+func (o Options) validate() error {
+	switch {
+	case o.Identity == "":
+		return fmt.Errorf("%w: identity is empty", ErrInvalidOptions)
+	case o.RetryPeriod <= 0:
+		return fmt.Errorf("%w: retry period must be positive", ErrInvalidOptions)
+	case o.RenewDeadline <= o.RetryPeriod:
+		return fmt.Errorf("%w: renew deadline %v must be longer than the retry period %v", ErrInvalidOptions, o.RenewDeadline, o.RetryPeriod)
+	case o.LeaseDuration <= o.RenewDeadline:
+		return fmt.Errorf("%w: lease duration %v must be longer than the renew deadline %v", ErrInvalidOptions, o.LeaseDuration, o.RenewDeadline)
+	}
+	return nil
+}
+
+// LeaderElector campaigns for the leadership of an election and leads until it loses it
+type LeaderElector struct {
+	client    *etcdclient.Client
+	key       string
+	opts      Options
+	callbacks Callbacks
+}
+
+// NewLeaderElector creates a LeaderElector for the named election with the default options
+func NewLeaderElector(client *etcdclient.Client, name string, callbacks Callbacks) (*LeaderElector, error) {
+	return NewLeaderElectorWithOptions(client, name, callbacks, DefaultOptions())
+}
+
+// NewLeaderElectorWithOptions creates a LeaderElector for the named election with the given configuration
+func NewLeaderElectorWithOptions(client *etcdclient.Client, name string, callbacks Callbacks, opts Options) (*LeaderElector, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: election name is empty", ErrInvalidOptions)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return &LeaderElector{client: client, key: KeyPrefix + name, opts: opts, callbacks: callbacks}, nil
+}
+
+// Run campaigns until the elector leads, then leads until the context is done or the lease can't
+// be renewed. It returns the context error once the context is done, and ErrLeadershipLost if
+// the lease was lost. A leader stepping down releases the key, so another candidate takes over
+// without waiting for the lease to expire.
+func (le *LeaderElector) Run(ctx context.Context) error {
+	lease, err := le.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s is now the leader of %s", le.opts.Identity, le.key)
+
+	leaderCtx, stopLeading := context.WithCancel(ctx)
+	if le.callbacks.OnStartedLeading != nil {
+		go le.callbacks.OnStartedLeading(leaderCtx)
+	}
+
+	err = le.renew(leaderCtx, lease)
+	stopLeading()
+	le.release(lease)
+	if le.callbacks.OnStoppedLeading != nil {
+		le.callbacks.OnStoppedLeading()
+	}
+	log.Printf("%s stopped leading %s: %v", le.opts.Identity, le.key, err)
+	return err
+}
+
+// acquire retries every RetryPeriod to create the key of the election, attached to a new lease,
+// until it succeeds or the context is done
+func (le *LeaderElector) acquire(ctx context.Context) (clientv3.LeaseID, error) {
+	ttl := int64((le.opts.LeaseDuration + time.Second - 1) / time.Second)
+	for {
+		lease, acquired, err := le.tryAcquire(ctx, ttl)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to acquire %s: %v", le.key, err)
+		}
+		if acquired {
+			return lease, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(le.opts.RetryPeriod):
+		}
+	}
+}
+
+// tryAcquire creates the key of the election unless another candidate holds it
+func (le *LeaderElector) tryAcquire(ctx context.Context, ttl int64) (clientv3.LeaseID, bool, error) {
+	grant, err := le.client.Grant(ctx, ttl)
+	if err != nil {
+		return 0, false, err
+	}
+
+	resp, err := le.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(le.key), "=", 0)).
+		Then(clientv3.OpPut(le.key, le.opts.Identity, clientv3.WithLease(grant.ID))).
+		Commit()
+	if err != nil || !resp.Succeeded {
+		le.release(grant.ID)
+		return 0, false, err
+	}
+	return grant.ID, true, nil
+}
+
+// renew renews the lease every RetryPeriod until the context is done. It returns
+// ErrLeadershipLost once the lease expired or couldn't be renewed for RenewDeadline.
+func (le *LeaderElector) renew(ctx context.Context, lease clientv3.LeaseID) error {
+	ticker := time.NewTicker(le.opts.RetryPeriod)
+	defer ticker.Stop()
+
+	lastRenewal := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithTimeout(ctx, le.opts.RetryPeriod)
+		_, err := le.client.KeepAliveOnce(renewCtx, lease)
+		cancel()
+		switch {
+		case err == nil:
+			lastRenewal = time.Now()
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, rpctypes.ErrLeaseNotFound):
+			return fmt.Errorf("%w: lease expired", ErrLeadershipLost)
+		case time.Since(lastRenewal) >= le.opts.RenewDeadline:
+			return fmt.Errorf("%w: failed to renew the lease for %v: %v", ErrLeadershipLost, le.opts.RenewDeadline, err)
+		default:
+			log.Printf("Failed to renew the lease of %s: %v", le.key, err)
+		}
+	}
+}
+
+// release revokes the lease, deleting the key of the election if it is attached to it
+func (le *LeaderElector) release(lease clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), le.opts.RetryPeriod)
+	defer cancel()
+	if _, err := le.client.Revoke(ctx, lease); err != nil && !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		log.Printf("Failed to revoke the lease of %s: %v", le.key, err)
+	}
+}
+
+// RunOrDie runs a LeaderElector for the named election and exits the process once it loses the
+// leadership, so that a component never acts without being the leader. It returns once the
+// context is done.
+func RunOrDie(ctx context.Context, client *etcdclient.Client, name string, callbacks Callbacks, opts Options) {
+	le, err := NewLeaderElectorWithOptions(client, name, callbacks, opts)
+	if err != nil {
+		log.Fatalf("Failed to create leader elector: %v", err)
+	}
+	if err := le.Run(ctx); errors.Is(err, ErrLeadershipLost) {
+		log.Fatalf("Exiting: %v", err)
+	}
+}
+
+// Run calls start once this instance is elected the leader of the named election, or right away
+// when the election is not enabled. The leader election runs with its own etcd client, created
+// from the config, and exits the process if the leadership is lost, as RunOrDie does. The
+// returned channel is closed once the leadership is released after the context is done.
+func Run(ctx context.Context, config etcdclient.Config, name string, enabled bool, leaseDuration time.Duration, start func(ctx context.Context)) (<-chan struct{}, error) {
+	stopped := make(chan struct{})
+	if !enabled {
+		start(ctx)
+		close(stopped)
+		return stopped, nil
+	}
+
+	client, err := etcdclient.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client for leader election: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.LeaseDuration = leaseDuration
+	opts.RenewDeadline = leaseDuration * 2 / 3
+
+	log.Printf("Waiting to be elected the leader of %s...", name)
+	go func() {
+		defer close(stopped)
+		defer client.Close()
+		RunOrDie(ctx, client, name, Callbacks{OnStartedLeading: start}, opts)
+	}()
+	return stopped, nil
+}
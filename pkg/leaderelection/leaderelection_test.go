@@ -0,0 +1,156 @@
+package leaderelection
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/storage"
+)
+
+func testOptions(identity string) Options {
+	return Options{
+		Identity:      identity,
+		LeaseDuration: 2 * time.Second,
+		RenewDeadline: time.Second,
+		RetryPeriod:   100 * time.Millisecond,
+	}
+}
+
+// candidate runs a LeaderElector and records whether it leads
+type candidate struct {
+	leading atomic.Bool
+	stopped atomic.Int32
+	cancel  context.CancelFunc
+	done    chan error
+}
+
+func startCandidate(t *testing.T, client *etcdclient.Client, identity string) *candidate {
+	c := &candidate{done: make(chan error, 1)}
+	le, err := NewLeaderElectorWithOptions(client, "test", Callbacks{
+		OnStartedLeading: func(ctx context.Context) {
+			c.leading.Store(true)
+			<-ctx.Done()
+			c.leading.Store(false)
+		},
+		OnStoppedLeading: func() { c.stopped.Add(1) },
+	}, testOptions(identity))
+	require.NoError(t, err)
+
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+	t.Cleanup(c.cancel)
+	go func() { c.done <- le.Run(ctx) }()
+	return c
+}
+
+func TestLeaderElector_SingleLeader(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		client := etcdclient.Wrap(cli)
+		first := startCandidate(t, client, "first")
+		require.Eventually(t, first.leading.Load, 2*time.Second, 10*time.Millisecond)
+
+		second := startCandidate(t, client, "second")
+		assert.Never(t, second.leading.Load, 500*time.Millisecond, 10*time.Millisecond, "only one candidate should lead")
+
+		resp, err := cli.Get(context.Background(), KeyPrefix+"test")
+		require.NoError(t, err)
+		require.Len(t, resp.Kvs, 1)
+		assert.Equal(t, "first", string(resp.Kvs[0].Value))
+
+		// The leader stepping down releases the key, the other candidate takes over right away
+		first.cancel()
+		assert.ErrorIs(t, <-first.done, context.Canceled)
+		assert.Equal(t, int32(1), first.stopped.Load())
+		assert.Eventually(t, second.leading.Load, time.Second, 10*time.Millisecond)
+		assert.False(t, first.leading.Load())
+	})
+}
+
+func TestLeaderElector_StopsLeadingOnLeaseExpiry(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		leader := startCandidate(t, etcdclient.Wrap(cli), "leader")
+		require.Eventually(t, leader.leading.Load, 2*time.Second, 10*time.Millisecond)
+
+		// The lease expires, as it does when the leader is partitioned from etcd
+		resp, err := cli.Get(context.Background(), KeyPrefix+"test")
+		require.NoError(t, err)
+		require.Len(t, resp.Kvs, 1)
+		_, err = cli.Revoke(context.Background(), clientv3.LeaseID(resp.Kvs[0].Lease))
+		require.NoError(t, err)
+
+		select {
+		case err := <-leader.done:
+			assert.ErrorIs(t, err, ErrLeadershipLost)
+		case <-time.After(time.Second):
+			t.Fatal("leader did not stop leading after its lease expired")
+		}
+		assert.Eventually(t, func() bool { return !leader.leading.Load() }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(1), leader.stopped.Load())
+	})
+}
+
+func TestNewLeaderElectorWithOptions(t *testing.T) {
+	valid := testOptions("candidate")
+	tests := []struct {
+		name   string
+		modify func(opts *Options)
+	}{
+		{"empty identity", func(opts *Options) { opts.Identity = "" }},
+		{"no retry period", func(opts *Options) { opts.RetryPeriod = 0 }},
+		{"renew deadline shorter than the retry period", func(opts *Options) { opts.RenewDeadline = opts.RetryPeriod }},
+		{"lease shorter than the renew deadline", func(opts *Options) { opts.LeaseDuration = opts.RenewDeadline }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			_, err := NewLeaderElectorWithOptions(nil, "test", Callbacks{}, opts)
+			assert.ErrorIs(t, err, ErrInvalidOptions)
+		})
+	}
+
+	_, err := NewLeaderElectorWithOptions(nil, "", Callbacks{}, valid)
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+	_, err = NewLeaderElectorWithOptions(nil, "test", Callbacks{}, valid)
+	assert.NoError(t, err)
+	assert.NoError(t, DefaultOptions().validate())
+}
+
+func TestRun(t *testing.T) {
+	t.Run("should start right away without leader election", func(t *testing.T) {
+		started := false
+		stopped, err := Run(context.Background(), etcdclient.Config{}, "test", false, time.Minute, func(ctx context.Context) { started = true })
+		require.NoError(t, err)
+		assert.True(t, started)
+		_, open := <-stopped
+		assert.False(t, open, "nothing should be left to stop")
+	})
+
+	t.Run("should start once elected and release the leadership when done", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			config := etcdclient.DefaultConfig()
+			config.Endpoints = cli.Endpoints()
+
+			var leading atomic.Bool
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped, err := Run(ctx, config, "test", true, DefaultOptions().LeaseDuration, func(ctx context.Context) {
+				leading.Store(true)
+			})
+			require.NoError(t, err)
+			require.Eventually(t, leading.Load, 2*time.Second, 10*time.Millisecond)
+
+			cancel()
+			<-stopped
+			resp, err := cli.Get(context.Background(), KeyPrefix+"test")
+			require.NoError(t, err)
+			assert.Empty(t, resp.Kvs, "the leadership should be released")
+		})
+	})
+}
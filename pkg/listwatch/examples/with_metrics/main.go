@@ -4,10 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"gokube/pkg/listwatch"
 	"gokube/pkg/retry"
@@ -51,11 +49,32 @@ func simulateDataChanges(ctx context.Context, client *clientv3.Client, prefix st
 	}
 }
 
+// stdLogger adapts the standard library logger to the listwatch.Logger interface
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	log.Println(append([]interface{}{"INFO", msg}, keysAndValues...)...)
+}
+
+func (stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	log.Println(append([]interface{}{"ERROR", msg}, keysAndValues...)...)
+}
+
 func main() {
 	// Start Prometheus metrics server
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		log.Fatal(http.ListenAndServe(":2112", nil))
+	metricsServer, err := listwatch.NewMetricsServer(listwatch.DefaultMetricsServerOptions())
+	if err != nil {
+		log.Fatalf("Failed to create metrics server: %v", err)
+	}
+	if err := metricsServer.Start(); err != nil {
+		log.Fatalf("Failed to start metrics server: %v", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down metrics server: %v", err)
+		}
 	}()
 
 	// Start embedded etcd
@@ -84,6 +103,10 @@ func main() {
 			MaxDelay:     30 * time.Second,
 			Multiplier:   2.0,
 		},
+		EventChannelBuffer: 100,
+		// Log one in every 10 events instead of every event
+		EventLogSampleRate: 10,
+		EventLogLevel:      listwatch.LogLevelInfo,
 	}
 
 	prefix := "/example/"
@@ -91,7 +114,7 @@ func main() {
 		[]string{endpoint},
 		prefix,
 		opts,
-		nil,
+		stdLogger{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to create ListWatch: %v", err)
@@ -107,18 +130,14 @@ func main() {
 	// Start goroutine to simulate data changes
 	go simulateDataChanges(ctx, etcdClient, prefix)
 
-	fmt.Println("Watching for events. Metrics available at :2112/metrics")
+	fmt.Printf("Watching for events. Metrics available at %s/metrics\n", metricsServer.Addr())
 	fmt.Println("Check the following metrics:")
 	fmt.Println("- listwatch_events_total{type=\"added|modified|deleted\"}")
 	fmt.Println("- listwatch_connection_state")
 	fmt.Println("- listwatch_watch_session_duration_seconds")
 	fmt.Println("- listwatch_errors_total{type=\"connection_failed|watch_error\"}")
 
-	for event := range eventCh {
-		if event.Type == listwatch.Error {
-			log.Printf("Error event: %s", string(event.Value))
-			continue
-		}
-		log.Printf("Event: type=%s key=%s value=%s", event.Type, event.Key, string(event.Value))
+	// Events are logged by the ListWatch itself according to the sample rate
+	for range eventCh {
 	}
 }
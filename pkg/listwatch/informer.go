@@ -0,0 +1,247 @@
+package listwatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"gokube/pkg/runtime"
+)
+
+// ResourceEventHandlerFuncs are the callbacks an Informer invokes for the changes it observes.
+// Any of them may be nil. They are called one at a time, in the order of the changes.
+type ResourceEventHandlerFuncs[T runtime.Object] struct {
+	OnAdd func(obj T)
+	// OnUpdate is called with the cached and the new state of a changed object. On a resync
+	// it is called with the cached object as both the old and the new state.
+	OnUpdate func(oldObj, newObj T)
+	// OnDelete is called with the last cached state of a removed object
+	OnDelete func(obj T)
+	// OnDecodeError is called for an object that can't be decoded. The object is skipped, so
+	// that it doesn't keep the rest of the cache from syncing, and is decoded again when it
+	// changes or is resynced.
+	OnDecodeError func(key string, err error)
+}
+
+// informerItem is a cached object with the revision it was decoded from
+type informerItem[T runtime.Object] struct {
+	obj      T
+	revision int64
+}
+
+// Informer decodes the events of a ListWatch into objects of type T, keeps them in a
+// thread-safe cache keyed by their etcd key and invokes its handlers for every change.
+// Callers and handlers are only given copies of the cached objects, so modifying an object
+// never changes the cache.
+//
+// A relist after a reconnect replaces the cache: the objects that didn't change since they
+// were cached are not reported again, and the objects deleted while the ListWatch was
+// disconnected are removed and reported to OnDelete. With a dropping OverflowPolicy an object
+// whose listed event was dropped is removed as well, until its next change.
+type Informer[T runtime.Object] struct {
+	lw        *ListWatch
+	newObject func() T
+	handlers  ResourceEventHandlerFuncs[T]
+
+	mutex  sync.RWMutex
+	items  map[string]informerItem[T]
+	synced atomic.Bool
+	// listedKeys are the keys of the list in progress, only used by the goroutine of Run
+	listedKeys map[string]bool
+}
+
+// NewInformer creates an Informer for the prefix of the ListWatch. newObject returns the
+// empty object each event is decoded into, such as func() *api.Pod { return &api.Pod{} }.
+func NewInformer[T runtime.Object](lw *ListWatch, newObject func() T, handlers ResourceEventHandlerFuncs[T]) *Informer[T] {
+	return &Informer[T]{
+		lw:        lw,
+		newObject: newObject,
+		handlers:  handlers,
+		items:     make(map[string]informerItem[T]),
+	}
+}
+
+// Run fills the cache with the list of ListAndWatch, then keeps it up to date with the watch
+// until the context is cancelled. It only returns early if the ListWatch gives up.
+func (i *Informer[T]) Run(ctx context.Context) error {
+	events, stop, err := i.lw.listAndWatch(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for event := range events {
+		i.handleEvent(event)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("informer of %s stopped: list and watch ended", i.lw.watchPrefix)
+}
+
+// HasSynced reports whether the initial list has been cached
+func (i *Informer[T]) HasSynced() bool {
+	return i.synced.Load()
+}
+
+// GetByKey returns a copy of the cached object stored at the etcd key
+func (i *Informer[T]) GetByKey(key string) (T, bool) {
+	i.mutex.RLock()
+	item, ok := i.items[key]
+	i.mutex.RUnlock()
+
+	if !ok {
+		return item.obj, false
+	}
+	return i.copyObject(key, item.obj)
+}
+
+// List returns copies of the cached objects, ordered by key
+func (i *Informer[T]) List() []T {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	keys := make([]string, 0, len(i.items))
+	for key := range i.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	objects := make([]T, 0, len(keys))
+	for _, key := range keys {
+		if obj, ok := i.copyObject(key, i.items[key].obj); ok {
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+// copyObject returns a copy of a cached object. An object that can't be copied is logged and
+// treated as missing, like an object that can't be decoded.
+func (i *Informer[T]) copyObject(key string, obj T) (T, bool) {
+	copied, err := runtime.DeepCopy(obj)
+	if err != nil {
+		if i.lw.logger != nil {
+			i.lw.logger.Error("Failed to copy cached object", "key", key, "error", err)
+		}
+		return copied, false
+	}
+	return copied, true
+}
+
+// handleEvent applies an event to the cache and invokes the matching handler
+func (i *Informer[T]) handleEvent(event Event) {
+	switch event.Type {
+	case Added, Modified:
+		if event.fromList {
+			if i.listedKeys == nil {
+				i.listedKeys = make(map[string]bool)
+			}
+			i.listedKeys[event.Key] = true
+		}
+		i.handleUpsert(event)
+	case listed:
+		i.removeUnlisted()
+		i.synced.Store(true)
+	case Deleted:
+		i.mutex.Lock()
+		item, ok := i.items[event.Key]
+		delete(i.items, event.Key)
+		i.mutex.Unlock()
+
+		if ok && i.handlers.OnDelete != nil {
+			i.handlers.OnDelete(item.obj)
+		}
+	case Error:
+		// The ListWatch reconnects and relists on its own
+	}
+}
+
+// removeUnlisted ends a list, removing the cached objects it didn't list as they were deleted
+// since they were cached
+func (i *Informer[T]) removeUnlisted() {
+	i.mutex.Lock()
+	var keys []string
+	for key := range i.items {
+		if !i.listedKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	removed := make([]T, 0, len(keys))
+	for _, key := range keys {
+		removed = append(removed, i.items[key].obj)
+		delete(i.items, key)
+	}
+	i.mutex.Unlock()
+	i.listedKeys = nil
+
+	if i.handlers.OnDelete != nil {
+		for _, obj := range removed {
+			i.handlers.OnDelete(obj)
+		}
+	}
+}
+
+// handleUpsert caches the object of an Added or Modified event
+func (i *Informer[T]) handleUpsert(event Event) {
+	i.mutex.RLock()
+	old, cached := i.items[event.Key]
+	i.mutex.RUnlock()
+
+	if cached && event.Revision <= old.revision {
+		// A relist or resync of an object that didn't change
+		if event.IsResync && i.handlers.OnUpdate != nil {
+			oldObj, ok := i.copyObject(event.Key, old.obj)
+			if !ok {
+				return
+			}
+			newObj, ok := i.copyObject(event.Key, old.obj)
+			if !ok {
+				return
+			}
+			i.handlers.OnUpdate(oldObj, newObj)
+		}
+		return
+	}
+
+	obj := i.newObject()
+	var err error
+	if event.Truncated {
+		err = fmt.Errorf("%w: %d bytes", ErrValueTruncated, len(event.Value))
+	} else {
+		err = runtime.Decode(event.Value, obj)
+	}
+	if err != nil {
+		i.lw.metrics.errorsByType.WithLabelValues("decode_failed").Inc()
+		if i.lw.logger != nil {
+			i.lw.logger.Error("Failed to decode object", "key", event.Key, "error", err)
+		}
+		if i.handlers.OnDecodeError != nil {
+			i.handlers.OnDecodeError(event.Key, err)
+		}
+		return
+	}
+	if versioned, ok := any(obj).(runtime.Versioned); ok {
+		versioned.SetResourceVersion(strconv.FormatInt(event.Revision, 10))
+	}
+
+	// The handlers are given the decoded object, the cache keeps a copy of its own
+	cachedObj, ok := i.copyObject(event.Key, obj)
+	if !ok {
+		return
+	}
+	i.mutex.Lock()
+	i.items[event.Key] = informerItem[T]{obj: cachedObj, revision: event.Revision}
+	i.mutex.Unlock()
+
+	switch {
+	case cached && i.handlers.OnUpdate != nil:
+		i.handlers.OnUpdate(old.obj, obj)
+	case !cached && i.handlers.OnAdd != nil:
+		i.handlers.OnAdd(obj)
+	}
+}
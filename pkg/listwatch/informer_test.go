@@ -0,0 +1,269 @@
+package listwatch
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/retry"
+)
+
+// recordingHandlers records the calls of the handlers of an Informer
+type recordingHandlers struct {
+	mutex        sync.Mutex
+	added        []string
+	updated      [][2]string
+	deleted      []string
+	decodeErrors map[string]int
+}
+
+func (r *recordingHandlers) funcs() ResourceEventHandlerFuncs[*api.Pod] {
+	return ResourceEventHandlerFuncs[*api.Pod]{
+		OnAdd: func(pod *api.Pod) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.added = append(r.added, pod.Name)
+		},
+		OnUpdate: func(oldPod, newPod *api.Pod) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.updated = append(r.updated, [2]string{string(oldPod.Status.Phase), string(newPod.Status.Phase)})
+		},
+		OnDelete: func(pod *api.Pod) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.deleted = append(r.deleted, pod.Name)
+		},
+		OnDecodeError: func(key string, err error) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			if r.decodeErrors == nil {
+				r.decodeErrors = make(map[string]int)
+			}
+			r.decodeErrors[key]++
+		},
+	}
+}
+
+// snapshot returns a copy of the recorded calls
+func (r *recordingHandlers) snapshot() recordingHandlers {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	decodeErrors := make(map[string]int, len(r.decodeErrors))
+	for key, count := range r.decodeErrors {
+		decodeErrors[key] = count
+	}
+	return recordingHandlers{
+		added:        append([]string(nil), r.added...),
+		updated:      append([][2]string(nil), r.updated...),
+		deleted:      append([]string(nil), r.deleted...),
+		decodeErrors: decodeErrors,
+	}
+}
+
+func putPod(t *testing.T, lw *ListWatch, key string, pod *api.Pod) {
+	data, err := json.Marshal(pod)
+	require.NoError(t, err)
+	_, err = lw.etcdCli.Put(context.Background(), key, string(data))
+	require.NoError(t, err)
+}
+
+func newInformerListWatch(t *testing.T, prefix string, resyncPeriod time.Duration) *ListWatch {
+	_, endpoint, cleanup := setupEtcd(t)
+	t.Cleanup(cleanup)
+
+	opts := DefaultOptions()
+	opts.ResyncPeriod = resyncPeriod
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+	lw.metrics = newTestMetrics(prefix)
+	return lw
+}
+
+func runInformer(t *testing.T, informer *Informer[*api.Pod]) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = informer.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	require.Eventually(t, informer.HasSynced, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInformer_HandlersAndCache(t *testing.T) {
+	prefix := "/test/informer/"
+	lw := newInformerListWatch(t, prefix, 0)
+
+	putPod(t, lw, prefix+"pod1", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod1"}, Status: api.PodStatus{Phase: api.PodPending}})
+
+	handlers := &recordingHandlers{}
+	informer := NewInformer(lw, func() *api.Pod { return &api.Pod{} }, handlers.funcs())
+	runInformer(t, informer)
+
+	// The listed pod is cached
+	pod, ok := informer.GetByKey(prefix + "pod1")
+	require.True(t, ok)
+	assert.Equal(t, "pod1", pod.Name)
+	assert.NotEmpty(t, pod.ResourceVersion)
+
+	putPod(t, lw, prefix+"pod2", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod2"}, Status: api.PodStatus{Phase: api.PodPending}})
+	putPod(t, lw, prefix+"pod1", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod1"}, Status: api.PodStatus{Phase: api.PodRunning}})
+	_, err := lw.etcdCli.Delete(context.Background(), prefix+"pod2")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(handlers.snapshot().deleted) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	recorded := handlers.snapshot()
+	assert.Equal(t, []string{"pod1", "pod2"}, recorded.added)
+	assert.Equal(t, [][2]string{{string(api.PodPending), string(api.PodRunning)}}, recorded.updated)
+	assert.Equal(t, []string{"pod2"}, recorded.deleted)
+
+	pods := informer.List()
+	require.Len(t, pods, 1)
+	assert.Equal(t, api.PodRunning, pods[0].Status.Phase)
+	_, ok = informer.GetByKey(prefix + "pod2")
+	assert.False(t, ok)
+}
+
+func TestInformer_SkipsUndecodableObjects(t *testing.T) {
+	prefix := "/test/informer-decode/"
+	lw := newInformerListWatch(t, prefix, 200*time.Millisecond)
+
+	putPod(t, lw, prefix+"good", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "good"}})
+	_, err := lw.etcdCli.Put(context.Background(), prefix+"corrupt", "{not json")
+	require.NoError(t, err)
+
+	handlers := &recordingHandlers{}
+	informer := NewInformer(lw, func() *api.Pod { return &api.Pod{} }, handlers.funcs())
+	runInformer(t, informer)
+
+	// The rest of the cache syncs and the bad key is reported
+	pods := informer.List()
+	require.Len(t, pods, 1)
+	assert.Equal(t, "good", pods[0].Name)
+	assert.GreaterOrEqual(t, handlers.snapshot().decodeErrors[prefix+"corrupt"], 1)
+	assert.GreaterOrEqual(t, testutil.ToFloat64(lw.metrics.errorsByType.WithLabelValues("decode_failed")), 1.0)
+
+	// Resyncs try to decode the bad key again
+	require.Eventually(t, func() bool {
+		return handlers.snapshot().decodeErrors[prefix+"corrupt"] >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Once the key is fixed it is cached like any other
+	putPod(t, lw, prefix+"corrupt", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "fixed"}})
+	require.Eventually(t, func() bool {
+		pod, ok := informer.GetByKey(prefix + "corrupt")
+		return ok && pod.Name == "fixed"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, handlers.snapshot().added, "fixed")
+}
+
+func TestInformer_ReturnsCopies(t *testing.T) {
+	prefix := "/test/informer-copies/"
+	lw := newInformerListWatch(t, prefix, 0)
+
+	putPod(t, lw, prefix+"pod1", &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "web"}},
+		Status:     api.PodStatus{Phase: api.PodPending},
+	})
+
+	// A handler modifying the object it is given doesn't modify the cache
+	informer := NewInformer(lw, func() *api.Pod { return &api.Pod{} }, ResourceEventHandlerFuncs[*api.Pod]{
+		OnAdd: func(pod *api.Pod) {
+			pod.Status.Phase = api.PodFailed
+		},
+	})
+	runInformer(t, informer)
+
+	pod, ok := informer.GetByKey(prefix + "pod1")
+	require.True(t, ok)
+	assert.Equal(t, api.PodPending, pod.Status.Phase)
+	pod.Status.Phase = api.PodRunning
+	pod.Labels["app"] = "changed"
+
+	pods := informer.List()
+	require.Len(t, pods, 1)
+	pods[0].Name = "changed"
+
+	cached, ok := informer.GetByKey(prefix + "pod1")
+	require.True(t, ok)
+	assert.Equal(t, "pod1", cached.Name)
+	assert.Equal(t, api.PodPending, cached.Status.Phase)
+	assert.Equal(t, map[string]string{"app": "web"}, cached.Labels)
+	assert.NotEmpty(t, cached.ResourceVersion)
+}
+
+func TestInformer_RelistRemovesDeletedObjects(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	t.Cleanup(cleanup)
+
+	// The first watch misses every change and fails on demand, so that the ListWatch relists
+	prefix := "/test/informer-relist/"
+	opts := DefaultOptions()
+	opts.WatchResumeAttempts = 0
+	opts.RetryOpts = retry.Options{InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 1.5}
+	disconnect := make(chan struct{})
+	var watches atomic.Int32
+	opts.WatchFunc = func(ctx context.Context, client *etcdclient.Client, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+		if watches.Add(1) > 1 {
+			return client.Watch(ctx, key, opts...)
+		}
+		out := make(chan clientv3.WatchResponse)
+		go func() {
+			defer close(out)
+			select {
+			case <-disconnect:
+				out <- clientv3.WatchResponse{Canceled: true}
+			case <-ctx.Done():
+			}
+		}()
+		return out
+	}
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+	lw.metrics = newTestMetrics(prefix)
+
+	putPod(t, lw, prefix+"pod1", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod1"}})
+	putPod(t, lw, prefix+"pod2", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod2"}})
+
+	handlers := &recordingHandlers{}
+	informer := NewInformer(lw, func() *api.Pod { return &api.Pod{} }, handlers.funcs())
+	runInformer(t, informer)
+	require.Len(t, informer.List(), 2)
+
+	// pod2 is deleted while the watch is disconnected, the relist reports it as deleted
+	_, err = lw.etcdCli.Delete(context.Background(), prefix+"pod2")
+	require.NoError(t, err)
+	close(disconnect)
+
+	require.Eventually(t, func() bool {
+		return len(handlers.snapshot().deleted) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	recorded := handlers.snapshot()
+	assert.Equal(t, []string{"pod1", "pod2"}, recorded.added)
+	assert.Empty(t, recorded.updated, "unchanged objects should not be reported again")
+	assert.Equal(t, []string{"pod2"}, recorded.deleted)
+	_, ok := informer.GetByKey(prefix + "pod2")
+	assert.False(t, ok)
+	_, ok = informer.GetByKey(prefix + "pod1")
+	assert.True(t, ok)
+}
@@ -38,35 +38,79 @@ Basic Usage:
 	    }
 	}
 
+WatchKey watches exactly one key instead of the prefix, so changes to sibling keys are not delivered.
+
+Informer builds on ListAndWatch: it decodes every event into a typed object, keeps the objects
+in a cache and calls OnAdd, OnUpdate and OnDelete handlers. Objects that can't be decoded are
+skipped and reported, so that they don't keep the rest of the cache from syncing.
+
 Configuration:
 The Options struct allows customizing:
   - DialTimeout: Timeout for etcd client connection
   - RetryInitialDelay: Initial delay for retry attempts
   - RetryMaxDelay: Maximum delay between retries
   - RetryMultiplier: Factor for exponential backoff
-  - EventChannelBuffer: Size of the event channel buffer
+  - RetryResetAfter: Run time after which a failed attempt backs off from the initial delay again
+  - EventChannelBuffer: Size of the event channel buffer, see Backpressure
+  - OverflowPolicy: What to do when the event channel is full (Block, DropOldest, DropNewest, Error)
+  - EventLogSampleRate: Log one in every N events (0 disables event logging, errors are always logged)
+  - EventLogLevel: Level at which sampled events are logged
+  - WatchResumeAttempts: Consecutive transient watch errors resumed before reconnecting
+  - ResyncPeriod: How often the current state is listed again and re-emitted as resync events
+  - MaxEventValueSize: Size above which event values are truncated and flagged (0 disables it)
+  - WatermarkInterval: How often watches publish the revision they need kept by compaction
+
+Backpressure:
+Events are buffered in the channel for up to EventChannelBuffer events. When the consumer falls
+further behind, OverflowPolicy decides what happens: the default OverflowBlock stops reading the
+etcd watch until the consumer makes room, so events are delayed but never dropped.
 
 Metrics:
-The package exports Prometheus metrics for monitoring:
+Each ListWatch exports Prometheus metrics, labelled with its prefix, to the registerer in
+Options.Registerer:
   - Event counts by type (add/modify/delete)
   - Connection state (connected/disconnected)
   - Watch session duration
   - Error counts by type
+  - Events dropped on channel overflow
+  - Watch errors resumed or failed
+
+MetricsServer serves these metrics, with an optional health endpoint, on a configurable address.
 
 Error Handling:
 Errors are handled in multiple ways:
 1. Immediate errors are returned directly
 2. Watch errors are sent as Error events
-3. Connection failures trigger automatic reconnection
-4. All errors are tracked via metrics
+3. Transient watch errors, such as a leader change, resume the watch without relisting
+4. Connection failures and repeated watch errors trigger automatic reconnection
+5. All errors are tracked via metrics
+
+Ordering:
+Events for any single key are delivered in etcd revision order, and no revision of a key is
+delivered twice by the same watch, even when it resumes after a transient error. Events that a
+resumed watch replays are dropped and counted as out_of_order_event errors. A relist after a
+reconnect starts over with the current state of every key.
+
+Cancelling the context or calling the stop function is an intentional shutdown: the watch is
+stopped before the etcd client is closed and the event channel is closed without Error events.
 */
 package listwatch
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"gokube/pkg/etcdclient"
 	"gokube/pkg/retry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
@@ -75,6 +119,13 @@ const (
 	defaultErrorRetryAttempts = 3
 	// defaultErrorRetryDelay is the delay between error event send attempts
 	defaultErrorRetryDelay = 10 * time.Millisecond
+	// watchResumeDelay is the delay before resuming a watch after a transient error
+	watchResumeDelay = 100 * time.Millisecond
+	// watermarkTTL is how long the watermark of a watch outlives a process that went away
+	// without withdrawing it
+	watermarkTTL = time.Minute
+	// watermarkWithdrawTimeout bounds withdrawing the watermark of a stopped watch
+	watermarkWithdrawTimeout = 5 * time.Second
 )
 
 // EventType defines the possible types of events.
@@ -89,6 +140,9 @@ const (
 	Deleted EventType = "DELETED"
 	// Error indicates a problem occurred during watch/list operations
 	Error EventType = "ERROR"
+	// listed marks the end of a list of the prefix for an Informer. It is only sent by
+	// listAndWatch with markLists set.
+	listed EventType = "LISTED"
 )
 
 // Event represents a single event to a watched resource.
@@ -103,6 +157,17 @@ type Event struct {
 	Value []byte
 	// Prefix is the watch prefix that produced this event
 	Prefix string
+	// Revision is the etcd revision at which the change happened.
+	// For listed items it is the revision at which the item was last modified.
+	Revision int64
+	// IsResync is set on the Added and Modified events that re-emit the current state on a
+	// periodic resync rather than report a change
+	IsResync bool
+	// Truncated is set when Value was cut to Options.MaxEventValueSize bytes. The full value
+	// is fetched by getting Key.
+	Truncated bool
+	// fromList is set on the events of a list of the prefix that a listed event follows
+	fromList bool
 }
 
 // validate checks if the Event is well-formed
@@ -119,27 +184,157 @@ func (e Event) validate() error {
 	return nil
 }
 
+// OverflowPolicy defines what happens when an event is sent to a full event channel
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits until the consumer makes room in the channel
+	OverflowBlock OverflowPolicy = "Block"
+	// OverflowDropOldest discards the oldest buffered event to make room for the new one
+	OverflowDropOldest OverflowPolicy = "DropOldest"
+	// OverflowDropNewest discards the new event
+	OverflowDropNewest OverflowPolicy = "DropNewest"
+	// OverflowError discards the new event and fails the watch, which is then re-established
+	OverflowError OverflowPolicy = "Error"
+)
+
+// ErrEventChannelFull is returned when an event can't be delivered under the OverflowError policy
+var ErrEventChannelFull = errors.New("event channel full")
+
+// ErrValueTruncated is reported for an event whose value was truncated to MaxEventValueSize and
+// can't be decoded
+var ErrValueTruncated = errors.New("event value truncated")
+
+// errInvalidEvent is returned for an event that isn't well-formed, such as an event of an empty
+// prefix. Retrying wouldn't change the event, so it isn't retried.
+var errInvalidEvent = errors.New("invalid event")
+
+// LogLevel defines the level at which events are logged
+type LogLevel string
+
+const (
+	// LogLevelInfo logs events using Logger.Info
+	LogLevelInfo LogLevel = "info"
+	// LogLevelDebug logs events using Debug if the logger implements DebugLogger, falling back to Info
+	LogLevelDebug LogLevel = "debug"
+)
+
 // Options configures the ListWatch behavior
 type Options struct {
-	DialTimeout        time.Duration
-	RetryOpts          retry.Options
+	DialTimeout time.Duration
+	RetryOpts   retry.Options
+	// EventChannelBuffer is the number of events buffered in the channels of Watch, WatchKey and
+	// ListAndWatch for a slow consumer. Once the buffer is full the OverflowPolicy applies: with
+	// OverflowBlock the watch waits for the consumer and no event is dropped.
 	EventChannelBuffer int
+	// Security holds the TLS files and credentials of a secured etcd cluster. With TLS files
+	// set the endpoints are treated as https:// endpoints.
+	Security etcdclient.Security
+	// OverflowPolicy decides what happens when the event channel is full
+	OverflowPolicy OverflowPolicy
+	// EventLogSampleRate logs one in every EventLogSampleRate events delivered to the channel.
+	// Zero disables event logging, except for error events which are always logged.
+	EventLogSampleRate int
+	// EventLogLevel is the level at which sampled events are logged
+	EventLogLevel LogLevel
+	// WatchResumeAttempts is the number of consecutive transient watch errors, such as an etcd
+	// leader change, after which the watch is resumed from the last delivered revision. Once
+	// exceeded the watch fails and the ListWatch reconnects and relists. Zero disables resuming.
+	WatchResumeAttempts int
+	// ResyncPeriod is how often ListAndWatch lists the prefix again and re-emits the current
+	// state as events with IsResync set, so that consumers can reconcile a cache that missed an
+	// event. The watch is kept open. Zero disables resyncing.
+	ResyncPeriod time.Duration
+	// Registerer is where the metrics of the ListWatch are registered. Nil registers them with
+	// the default Prometheus registerer.
+	Registerer prometheus.Registerer
+	// ClientHooks are invoked around every etcd call of the ListWatch, such as to log or trace them
+	ClientHooks []etcdclient.Hook
+	// SuppressUnchanged drops Modified events whose value is byte-equal to the previous value of
+	// the key, such as those of a no-op update. Only the resourceVersion of the object changed,
+	// so consumers that write objects back conditionally on the version they last saw should
+	// leave it disabled, or refetch an object before writing it.
+	SuppressUnchanged bool
+	// MaxEventValueSize caps the size of the values delivered in events, protecting consumers
+	// from the memory of very large objects. Longer values are truncated and the event is
+	// flagged as Truncated. Consumers that decode the values, such as an Informer, skip the
+	// objects of truncated events. Zero delivers values of any size.
+	MaxEventValueSize int
+	// WatchFunc starts the etcd watches with the etcd client of the ListWatch. It can wrap the
+	// watches of the client, such as to inject watch failures. Nil watches with the client.
+	WatchFunc func(ctx context.Context, client *etcdclient.Client, key string, opts ...clientv3.OpOption) clientv3.WatchChan
+	// IsTransientWatchError reports whether an error ending a watch is expected to clear up on
+	// its own, so that the watch is resumed. Nil resumes after etcd leader elections and
+	// unavailable connections.
+	IsTransientWatchError func(err error) bool
+	// WatermarkInterval is how often each watch publishes the revision it resumes from as an
+	// etcdclient.Watermark, so that the compaction of the API server keeps the revisions the
+	// watch still needs. The watermark is withdrawn when the watch stops. Zero disables it, and
+	// a watch that falls behind compaction fails and relists.
+	WatermarkInterval time.Duration
 }
 
 // DefaultOptions returns the default configuration options
 func DefaultOptions() Options {
+	// A watch that ran for a while before failing backs off from the initial delay again, and
+	// watches losing etcd together don't reconnect in lockstep
+	retryOpts := retry.DefaultOptions()
+	retryOpts.ResetAfter = time.Minute
+	retryOpts.Jitter = 0.2
+
 	return Options{
-		DialTimeout:        5 * time.Second,
-		RetryOpts:          retry.DefaultOptions(),
-		EventChannelBuffer: 100,
+		DialTimeout:         5 * time.Second,
+		RetryOpts:           retryOpts,
+		EventChannelBuffer:  100,
+		OverflowPolicy:      OverflowBlock,
+		EventLogSampleRate:  0,
+		EventLogLevel:       LogLevelInfo,
+		WatchResumeAttempts: 3,
+		Registerer:          prometheus.DefaultRegisterer,
+		WatermarkInterval:   30 * time.Second,
+	}
+}
+
+// logEvent logs a sampled subset of events. Error events are always logged, even with
+// EventLogSampleRate disabling event logging.
+func (lw *ListWatch) logEvent(event Event) {
+	if lw.logger == nil {
+		return
+	}
+
+	if event.Type == Error {
+		lw.logger.Error("Watch error event", "prefix", lw.watchPrefix, "error", string(event.Value))
+		return
+	}
+
+	if lw.opts.EventLogSampleRate <= 0 {
+		return
+	}
+
+	if (lw.eventCount.Add(1)-1)%uint64(lw.opts.EventLogSampleRate) != 0 {
+		return
+	}
+
+	keysAndValues := []interface{}{"type", event.Type, "key", event.Key, "prefix", event.Prefix, "sampleRate", lw.opts.EventLogSampleRate}
+	if debugLogger, ok := lw.logger.(DebugLogger); ok && lw.opts.EventLogLevel == LogLevelDebug {
+		debugLogger.Debug("Watch event", keysAndValues...)
+		return
 	}
+	lw.logger.Info("Watch event", keysAndValues...)
 }
 
-// tryToSendErrorEvent attempts to send an error event with retries
+// tryToSendErrorEvent attempts to send an error event with retries.
+// No event is sent once the context is cancelled, as the consumer asked to stop watching.
 func (lw *ListWatch) tryToSendErrorEvent(ch chan<- Event, errMsg string, ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
 	err := retry.WithRetries(ctx, defaultErrorRetryAttempts, defaultErrorRetryDelay, func(ctx context.Context) error {
 		select {
 		case ch <- Event{Type: Error, Value: []byte(errMsg)}:
+			lw.recordEvent(Event{Type: Error})
+			lw.logEvent(Event{Type: Error, Value: []byte(errMsg)})
 			return nil
 		case <-ctx.Done():
 			return ctx.Err()
@@ -151,18 +346,86 @@ func (lw *ListWatch) tryToSendErrorEvent(ch chan<- Event, errMsg string, ctx con
 }
 
 // sendEvent sends an event to the channel with context cancellation handling
-func (lw *ListWatch) sendEvent(ctx context.Context, ch chan<- Event, event Event) error {
+func (lw *ListWatch) sendEvent(ctx context.Context, ch chan Event, event Event) error {
 	if err := event.validate(); err != nil {
 		lw.logger.Error("Invalid event", "error", err)
-		return fmt.Errorf("invalid event: %v", err)
+		return fmt.Errorf("%w: %v", errInvalidEvent, err)
 	}
 
-	select {
-	case ch <- event:
-		lw.metrics.eventProcessed.Inc()
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+	delivered, err := lw.deliver(ctx, ch, event)
+	if err != nil {
+		return err
+	}
+	if delivered {
+		lw.recordEvent(event)
+		lw.logEvent(event)
+	}
+	return nil
+}
+
+// recordEvent counts an event delivered to the consumer, both in total and by type.
+// Every event is counted once, by whichever stage hands it to the consumer.
+func (lw *ListWatch) recordEvent(event Event) {
+	lw.metrics.eventProcessed.Inc()
+	lw.metrics.eventsByType.WithLabelValues(string(event.Type)).Inc()
+}
+
+// truncate cuts the value of the event to MaxEventValueSize bytes and flags it as truncated
+func (lw *ListWatch) truncate(event Event) Event {
+	if lw.opts.MaxEventValueSize <= 0 || event.Type == Error || len(event.Value) <= lw.opts.MaxEventValueSize {
+		return event
+	}
+	lw.metrics.eventsTruncated.Inc()
+	event.Value = event.Value[:lw.opts.MaxEventValueSize:lw.opts.MaxEventValueSize]
+	event.Truncated = true
+	return event
+}
+
+// deliver puts the event on the channel according to the overflow policy, with its value
+// truncated to MaxEventValueSize. It returns false if the event was dropped.
+func (lw *ListWatch) deliver(ctx context.Context, ch chan Event, event Event) (bool, error) {
+	event = lw.truncate(event)
+	switch lw.opts.OverflowPolicy {
+	case OverflowDropNewest, OverflowError:
+		select {
+		case ch <- event:
+			return true, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		default:
+		}
+
+		lw.metrics.eventsDropped.WithLabelValues(string(lw.opts.OverflowPolicy)).Inc()
+		if lw.opts.OverflowPolicy == OverflowError {
+			return false, fmt.Errorf("%w: dropped %s event for key %s", ErrEventChannelFull, event.Type, event.Key)
+		}
+		return false, nil
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- event:
+				return true, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			default:
+			}
+
+			// Make room by discarding the oldest buffered event
+			select {
+			case <-ch:
+				lw.metrics.eventsDropped.WithLabelValues(string(OverflowDropOldest)).Inc()
+			default:
+			}
+		}
+
+	default:
+		select {
+		case ch <- event:
+			return true, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
 	}
 }
 
@@ -180,10 +443,7 @@ func (lw *ListWatch) ensureConnected(ctx context.Context, ch chan<- Event) error
 		return nil
 	}
 
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   lw.endpoints,
-		DialTimeout: lw.opts.DialTimeout,
-	})
+	cli, err := lw.newEtcdClient()
 	if err != nil {
 		lw.logger.Error("Failed to create etcd client", "error", err)
 		lw.metrics.connectionState.Set(0)
@@ -202,10 +462,9 @@ func (lw *ListWatch) handleCleanup(ctx context.Context, ch chan Event, done chan
 	lw.closeEtcdClient()
 	lw.metrics.connectionState.Set(0)
 
-	// Try to send context cancellation error
+	// Cancellation is an intentional shutdown, so it is only counted rather than sent as an error event
 	if ctx.Err() != nil {
 		lw.metrics.errorsByType.WithLabelValues("context_cancelled").Inc()
-		lw.tryToSendErrorEvent(ch, fmt.Sprintf("context cancelled: %v", ctx.Err()), ctx)
 	}
 
 	close(ch)
@@ -216,11 +475,13 @@ func (lw *ListWatch) handleCleanup(ctx context.Context, ch chan Event, done chan
 // ListWatch knows how to list and watch a set of resources in etcd.
 type ListWatch struct {
 	endpoints   []string
-	etcdCli     *clientv3.Client
+	etcdCli     *etcdclient.Client
 	watchPrefix string
 	opts        Options
 	metrics     *metrics
 	logger      Logger
+	// eventCount counts events considered for sampled logging
+	eventCount atomic.Uint64
 }
 
 // Logger interface for structured logging
@@ -229,34 +490,54 @@ type Logger interface {
 	Error(msg string, keysAndValues ...interface{})
 }
 
+// DebugLogger is implemented by loggers that support debug level logging
+type DebugLogger interface {
+	Debug(msg string, keysAndValues ...interface{})
+}
+
 // NewListWatch creates a new ListWatch for the given prefix.
 func NewListWatch(endpoints []string, prefix string, opts Options, logger Logger) (*ListWatch, error) {
 	if prefix == "" {
 		return nil, fmt.Errorf("prefix cannot be empty")
 	}
 
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   endpoints,
-		DialTimeout: opts.DialTimeout,
-	})
+	m, err := newMetrics(opts.Registerer, prefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create etcd client: %v", err)
+		return nil, err
 	}
 
-	return &ListWatch{
+	lw := &ListWatch{
 		endpoints:   endpoints,
-		etcdCli:     cli,
 		watchPrefix: prefix,
 		opts:        opts,
-		metrics:     newMetrics(),
+		metrics:     m,
 		logger:      logger,
-	}, nil
+	}
+
+	cli, err := lw.newEtcdClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	lw.etcdCli = cli
+
+	return lw, nil
 }
 
-// listAndSendExisting lists and sends existing items to the channel
-func (lw *ListWatch) listAndSendExisting(ctx context.Context, ch chan<- Event) error {
+// newEtcdClient creates an etcd client for the endpoints and security options
+func (lw *ListWatch) newEtcdClient() (*etcdclient.Client, error) {
+	return etcdclient.New(etcdclient.Config{
+		Endpoints:   lw.endpoints,
+		DialTimeout: lw.opts.DialTimeout,
+		Security:    lw.opts.Security,
+		Hooks:       lw.opts.ClientHooks,
+	})
+}
+
+// listAndSendExisting lists and sends existing items to the channel. With markLists the items
+// are sent flagged as fromList and followed by a listed event at the revision of the list.
+func (lw *ListWatch) listAndSendExisting(ctx context.Context, ch chan Event, markLists bool) (int64, error) {
 	start := time.Now()
-	existing, err := lw.List(ctx)
+	existing, revision, err := lw.list(ctx)
 	lw.metrics.listLatency.Observe(time.Since(start).Seconds())
 
 	if err != nil {
@@ -264,23 +545,57 @@ func (lw *ListWatch) listAndSendExisting(ctx context.Context, ch chan<- Event) e
 		lw.tryToSendErrorEvent(ch, fmt.Sprintf("failed to list items: %v", err), ctx)
 		lw.closeEtcdClient()
 		lw.metrics.retryCount.Inc()
-		return err
+		return 0, err
 	}
 
 	for _, event := range existing {
+		event.fromList = markLists
 		if err := lw.sendEvent(ctx, ch, event); err != nil {
-			return err
+			return 0, err
+		}
+	}
+
+	if markLists {
+		// The marker isn't a change of the prefix, so it is neither counted nor logged
+		if _, err := lw.deliver(ctx, ch, Event{Type: listed, Prefix: lw.watchPrefix, Revision: revision}); err != nil {
+			return 0, err
 		}
 	}
+	return revision, nil
+}
 
+// resync lists the prefix again and sends the current state marked as a resync
+func (lw *ListWatch) resync(ctx context.Context, ch chan Event) error {
+	start := time.Now()
+	existing, err := lw.List(ctx)
+	lw.metrics.listLatency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		lw.logger.Error("Failed to resync", "prefix", lw.watchPrefix, "error", err)
+		lw.metrics.errorsByType.WithLabelValues("resync_failed").Inc()
+		// The watch is still healthy, the next resync tries again
+		return nil
+	}
+
+	for _, event := range existing {
+		event.IsResync = true
+		if err := lw.sendEvent(ctx, ch, event); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // List gets all keys with the configured prefix.
 func (lw *ListWatch) List(ctx context.Context) ([]Event, error) {
+	events, _, err := lw.list(ctx)
+	return events, err
+}
+
+// list gets all keys with the configured prefix and the revision they were listed at
+func (lw *ListWatch) list(ctx context.Context) ([]Event, int64, error) {
 	resp, err := lw.etcdCli.Get(ctx, lw.watchPrefix, clientv3.WithPrefix())
 	if err != nil {
-		return nil, fmt.Errorf("failed to list keys: %v", err)
+		return nil, 0, fmt.Errorf("failed to list keys: %v", err)
 	}
 
 	events := make([]Event, len(resp.Kvs))
@@ -291,25 +606,32 @@ func (lw *ListWatch) List(ctx context.Context) ([]Event, error) {
 			eventType = Modified
 		}
 
-		events[i] = Event{
-			Type:   eventType,
-			Key:    string(kv.Key),
-			Value:  kv.Value,
-			Prefix: lw.watchPrefix,
-		}
+		events[i] = lw.truncate(Event{
+			Type:     eventType,
+			Key:      string(kv.Key),
+			Value:    kv.Value,
+			Prefix:   lw.watchPrefix,
+			Revision: kv.ModRevision,
+		})
 	}
 
-	return events, nil
+	return events, resp.Header.Revision, nil
 }
 
 // handleWatchChannelClose handles the case when the watch channel closes unexpectedly
 func (lw *ListWatch) handleWatchChannelClose(ctx context.Context, ch chan<- Event) error {
+	if ctx.Err() != nil {
+		// The watch was closed because we are shutting down
+		return ctx.Err()
+	}
+
 	lw.logger.Error("Watch channel closed unexpectedly")
 
 	// Try multiple times to ensure error event is sent
 	for i := 0; i < 3; i++ {
 		select {
 		case ch <- Event{Type: Error, Value: []byte("watch channel closed unexpectedly")}:
+			lw.recordEvent(Event{Type: Error})
 			lw.closeEtcdClient()
 			return fmt.Errorf("watch channel closed")
 		case <-ctx.Done():
@@ -324,9 +646,11 @@ func (lw *ListWatch) handleWatchChannelClose(ctx context.Context, ch chan<- Even
 	return fmt.Errorf("watch channel closed")
 }
 
-// watchAndForwardEvents starts a watch and forwards events to the channel
-func (lw *ListWatch) watchAndForwardEvents(ctx context.Context, ch chan<- Event) error {
-	watchCh, watchCancel, err := lw.Watch(ctx)
+// watchAndForwardEvents starts a watch after the revision the prefix was listed at, so that no
+// change made since the list is missed, and forwards events to the channel
+func (lw *ListWatch) watchAndForwardEvents(ctx context.Context, ch chan Event, revision int64) error {
+	// The events are counted as they are forwarded, rather than by the watch
+	watchCh, watchCancel, err := lw.startWatch(ctx, lw.watchPrefix, revision, false, clientv3.WithPrefix())
 	if err != nil {
 		lw.logger.Error("Failed to start watch", "error", err)
 		lw.tryToSendErrorEvent(ch, fmt.Sprintf("failed to start watch: %v", err), ctx)
@@ -339,20 +663,34 @@ func (lw *ListWatch) watchAndForwardEvents(ctx context.Context, ch chan<- Event)
 	watchCtx, watchCtxCancel := context.WithCancel(ctx)
 	defer watchCtxCancel()
 
-	// Start a goroutine to monitor etcd client status
-	go func() {
-		<-watchCtx.Done()
-		if ctx.Err() == nil { // Only send error if parent context is not done
-			lw.tryToSendErrorEvent(ch, "etcd connection lost", ctx)
-		}
-	}()
+	// Monitor the etcd connection, as the client keeps the watch open while it tries to
+	// reconnect and would otherwise never report that the server went away
+	connectionLost := lw.monitorConnection(watchCtx)
+
+	var resyncCh <-chan time.Time
+	if lw.opts.ResyncPeriod > 0 {
+		ticker := time.NewTicker(lw.opts.ResyncPeriod)
+		defer ticker.Stop()
+		resyncCh = ticker.C
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			lw.tryToSendErrorEvent(ch, "watch stopped: context cancelled", ctx)
 			return ctx.Err()
 
+		case <-resyncCh:
+			if err := lw.resync(ctx, ch); err != nil {
+				return err
+			}
+
+		case <-connectionLost:
+			lw.logger.Error("Lost connection to etcd")
+			lw.metrics.connectionState.Set(0)
+			lw.metrics.errorsByType.WithLabelValues("connection_lost").Inc()
+			lw.tryToSendErrorEvent(ch, "etcd connection lost", ctx)
+			return fmt.Errorf("etcd connection lost")
+
 		case event, ok := <-watchCh:
 			if !ok {
 				// Cancel watch context to trigger error event
@@ -367,76 +705,340 @@ func (lw *ListWatch) watchAndForwardEvents(ctx context.Context, ch chan<- Event)
 	}
 }
 
+// monitorConnection returns a channel that is closed when the etcd client connection fails
+func (lw *ListWatch) monitorConnection(ctx context.Context) <-chan struct{} {
+	lost := make(chan struct{})
+	conn := lw.etcdCli.ActiveConnection()
+
+	go func() {
+		state := conn.GetState()
+		for state != connectivity.TransientFailure && state != connectivity.Shutdown {
+			if !conn.WaitForStateChange(ctx, state) {
+				return
+			}
+			state = conn.GetState()
+		}
+		close(lost)
+	}()
+
+	return lost
+}
+
 // Watch starts watching for changes on the configured prefix.
 // It returns a channel that will receive events and a function to stop watching.
 func (lw *ListWatch) Watch(ctx context.Context) (<-chan Event, func(), error) {
+	return lw.startWatch(ctx, lw.watchPrefix, 0, true, clientv3.WithPrefix())
+}
+
+// WatchKey starts watching for changes on exactly one key, so that changes to keys sharing
+// its prefix are not delivered. The key does not have to be under the configured prefix.
+// Events carry the key as their Prefix. It returns a channel that will receive events and a
+// function to stop watching.
+func (lw *ListWatch) WatchKey(ctx context.Context, key string) (<-chan Event, func(), error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("key cannot be empty")
+	}
+	return lw.startWatch(ctx, key, 0, true)
+}
+
+// startWatch watches key with the given options, which select a prefix or a single key watch,
+// for the changes after revision, or after the current revision if revision is 0. Delivered
+// events are counted in the metrics when countEvents is set.
+func (lw *ListWatch) startWatch(ctx context.Context, key string, revision int64, countEvents bool, opts ...clientv3.OpOption) (<-chan Event, func(), error) {
 	start := time.Now()
 	defer func() {
 		lw.metrics.watchSessionDuration.Observe(time.Since(start).Seconds())
 	}()
 
-	// Get current revision
-	resp, err := lw.etcdCli.Get(ctx, lw.watchPrefix, clientv3.WithPrefix())
-	if err != nil {
-		lw.metrics.errorsByType.WithLabelValues("get_revision_failed").Inc()
-		return nil, nil, fmt.Errorf("failed to get current revision: %v", err)
+	if revision == 0 {
+		resp, err := lw.etcdCli.Get(ctx, key, opts...)
+		if err != nil {
+			lw.metrics.errorsByType.WithLabelValues("get_revision_failed").Inc()
+			return nil, nil, fmt.Errorf("failed to get current revision: %v", err)
+		}
+		revision = resp.Header.Revision
 	}
 
-	// Create buffered channel to prevent blocking
-	ch := make(chan Event, 100)
+	// The buffer absorbs bursts of events, a consumer that falls further behind gets the
+	// OverflowPolicy applied
+	ch := make(chan Event, lw.opts.EventChannelBuffer)
+
+	// Watch from the next revision. The watch has its own context so that it can be stopped
+	// before the client is closed. A resumed watch starts after the last forwarded revision.
+	watchCtx, stopWatch := context.WithCancel(ctx)
 
-	// Create watch channel starting from next revision
-	watchChan := lw.etcdCli.Watch(ctx, lw.watchPrefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	// The revision the watch resumes from, which its watermark publishes
+	var resumeRevision atomic.Int64
+	resumeRevision.Store(revision)
+	client := lw.etcdCli
 
 	// Start goroutine to process watch events
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		defer close(ch)
 
-		for watchResp := range watchChan {
-			if watchResp.Err() != nil {
-				lw.metrics.errorsByType.WithLabelValues("watch_error").Inc()
-				ch <- Event{Type: Error, Value: []byte(watchResp.Err().Error())}
+		watermarkCtx, stopWatermark := context.WithCancel(watchCtx)
+		watermarkDone := lw.publishWatermark(watermarkCtx, client, &resumeRevision)
+		defer func() {
+			stopWatermark()
+			<-watermarkDone
+		}()
+
+		failures := 0
+		sequencer := &eventSequencer{}
+		for {
+			attemptCtx, cancelAttempt := context.WithCancel(watchCtx)
+			watchOpts := append(slices.Clone(opts), clientv3.WithRev(resumeRevision.Load()+1))
+			if lw.opts.SuppressUnchanged {
+				watchOpts = append(watchOpts, clientv3.WithPrevKV())
+			}
+			watchChan := lw.watch(attemptCtx, key, watchOpts...)
+			progressed, err := lw.forwardWatchResponses(attemptCtx, watchChan, ch, key, &resumeRevision, sequencer, countEvents)
+			cancelAttempt()
+
+			if err == nil || watchCtx.Err() != nil {
 				return
 			}
 
-			for _, event := range watchResp.Events {
-				var eventType EventType
-				switch event.Type {
-				case clientv3.EventTypePut:
-					// If CreateRevision equals ModRevision, this is a new key
-					if event.Kv.CreateRevision == event.Kv.ModRevision {
-						eventType = Added
-					} else {
-						eventType = Modified
+			if progressed {
+				failures = 0
+			}
+			failures++
+
+			if !lw.isTransientWatchError(err) || failures > lw.opts.WatchResumeAttempts {
+				lw.metrics.watchResumes.WithLabelValues("failed").Inc()
+				lw.metrics.errorsByType.WithLabelValues("watch_error").Inc()
+				errorEvent := Event{Type: Error, Value: []byte(err.Error()), Prefix: key}
+				select {
+				case ch <- errorEvent:
+					if countEvents {
+						lw.recordEvent(errorEvent)
 					}
-				case clientv3.EventTypeDelete:
-					eventType = Deleted
+				case <-watchCtx.Done():
 				}
+				return
+			}
 
-				event := Event{
-					Type:   eventType,
-					Key:    string(event.Kv.Key),
-					Value:  event.Kv.Value,
-					Prefix: lw.watchPrefix,
-				}
-				ch <- event
-				lw.metrics.eventsByType.WithLabelValues(string(eventType)).Inc()
+			// Resume the same watch from the last delivered revision instead of relisting
+			lw.logger.Info("Resuming watch after transient error", "error", err, "revision", resumeRevision.Load(), "attempt", failures)
+			lw.metrics.watchResumes.WithLabelValues("resumed").Inc()
+			select {
+			case <-time.After(watchResumeDelay):
+			case <-watchCtx.Done():
+				return
 			}
 		}
 	}()
 
-	// Return cancel function
+	// Return cancel function. The watch is stopped and drained before the client is closed,
+	// so closing the client isn't reported as a watch error.
 	cancel := func() {
-		if lw.etcdCli != nil {
-			lw.etcdCli.Close()
-		}
+		stopWatch()
+		<-done
+		lw.closeEtcdClient()
 	}
 
 	return ch, cancel, nil
 }
 
+// publishWatermark publishes the revision a watch resumes from as a watermark with the client,
+// once right away and then every WatermarkInterval if it changed, until the context is done.
+// The watermark is withdrawn then and the returned channel is closed.
+func (lw *ListWatch) publishWatermark(ctx context.Context, client *etcdclient.Client, revision *atomic.Int64) <-chan struct{} {
+	done := make(chan struct{})
+	if lw.opts.WatermarkInterval <= 0 {
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+
+		watermark := etcdclient.NewWatermark(client, watermarkTTL)
+		defer func() {
+			// The watch context is done, withdrawing gets a context of its own
+			withdrawCtx, cancel := context.WithTimeout(context.Background(), watermarkWithdrawTimeout)
+			defer cancel()
+			if err := watermark.Withdraw(withdrawCtx); err != nil {
+				lw.logger.Error("Failed to withdraw watermark", "prefix", lw.watchPrefix, "error", err)
+			}
+		}()
+
+		ticker := time.NewTicker(lw.opts.WatermarkInterval)
+		defer ticker.Stop()
+
+		var published int64
+		for {
+			if current := revision.Load(); current != published {
+				if err := watermark.Publish(ctx, current); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					lw.logger.Error("Failed to publish watermark", "prefix", lw.watchPrefix, "error", err)
+					lw.metrics.errorsByType.WithLabelValues("watermark_failed").Inc()
+				} else {
+					published = current
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// watch starts an etcd watch on key
+func (lw *ListWatch) watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	if lw.opts.WatchFunc != nil {
+		return lw.opts.WatchFunc(ctx, lw.etcdCli, key, opts...)
+	}
+	return lw.etcdCli.Watch(ctx, key, opts...)
+}
+
+// forwardWatchResponses forwards the events of an etcd watch on prefix to ch and records the revision
+// of the last forwarded event. Events the sequencer has seen already are dropped, so that
+// resumed watches keep per-key revision order. It reports whether any response was received and
+// returns the error that ended the watch, or nil if the watch channel closed or an event couldn't be delivered.
+// Delivered events are counted in the metrics when countEvents is set.
+func (lw *ListWatch) forwardWatchResponses(ctx context.Context, watchChan clientv3.WatchChan, ch chan Event, prefix string, revision *atomic.Int64, sequencer *eventSequencer, countEvents bool) (bool, error) {
+	progressed := false
+	for watchResp := range watchChan {
+		if err := watchResp.Err(); err != nil {
+			return progressed, err
+		}
+		progressed = true
+
+		for _, event := range watchResp.Events {
+			if lw.isUnchanged(event) {
+				lw.metrics.eventsSuppressed.Inc()
+				continue
+			}
+
+			var eventType EventType
+			switch event.Type {
+			case clientv3.EventTypePut:
+				// If CreateRevision equals ModRevision, this is a new key
+				if event.Kv.CreateRevision == event.Kv.ModRevision {
+					eventType = Added
+				} else {
+					eventType = Modified
+				}
+			case clientv3.EventTypeDelete:
+				eventType = Deleted
+			}
+
+			event := Event{
+				Type:     eventType,
+				Key:      string(event.Kv.Key),
+				Value:    event.Kv.Value,
+				Prefix:   prefix,
+				Revision: event.Kv.ModRevision,
+			}
+			if !sequencer.next(event.Key, event.Revision) {
+				lw.logger.Error("Dropping out of order watch event", "key", event.Key, "revision", event.Revision, "lastRevision", sequencer.revision)
+				lw.metrics.errorsByType.WithLabelValues("out_of_order_event").Inc()
+				continue
+			}
+			delivered, err := lw.deliver(ctx, ch, event)
+			if err != nil {
+				// Closing the channel makes the consumer re-establish the watch
+				return progressed, nil
+			}
+			if delivered && countEvents {
+				lw.recordEvent(event)
+			}
+		}
+
+		// The watch resumes after the last revision it has forwarded
+		if watchResp.Header.Revision > revision.Load() {
+			revision.Store(watchResp.Header.Revision)
+		}
+	}
+	return progressed, nil
+}
+
+// isUnchanged reports whether the event is a modification that left the value of the key as it
+// was and is to be suppressed. The previous value is only known when SuppressUnchanged is set.
+func (lw *ListWatch) isUnchanged(event *clientv3.Event) bool {
+	return lw.opts.SuppressUnchanged &&
+		event.Type == clientv3.EventTypePut &&
+		event.PrevKv != nil &&
+		event.Kv.CreateRevision != event.Kv.ModRevision &&
+		bytes.Equal(event.PrevKv.Value, event.Kv.Value)
+}
+
+// eventSequencer enforces revision order on the events of a watch. etcd delivers the events of
+// a watch in revision order, with several keys sharing a revision when they were changed in one
+// transaction, so tracking the last revision and the keys delivered at it is enough to reject
+// any event that would take a key back in time or repeat it.
+type eventSequencer struct {
+	revision int64
+	keys     map[string]struct{}
+}
+
+// next reports whether an event for key at revision may be delivered and records it if so
+func (s *eventSequencer) next(key string, revision int64) bool {
+	switch {
+	case revision < s.revision:
+		return false
+	case revision > s.revision, s.keys == nil:
+		s.revision = revision
+		s.keys = map[string]struct{}{}
+	}
+
+	if _, seen := s.keys[key]; seen {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+// isTransientWatchError reports whether a watch error is transient with the configured
+// classification
+func (lw *ListWatch) isTransientWatchError(err error) bool {
+	if lw.opts.IsTransientWatchError != nil {
+		return lw.opts.IsTransientWatchError(err)
+	}
+	return isTransientWatchError(err)
+}
+
+// isTransientWatchError reports whether a watch error is expected to clear up on its own, as
+// during an etcd leader election, so that the watch can be resumed rather than re-established.
+func isTransientWatchError(err error) bool {
+	switch {
+	case errors.Is(err, rpctypes.ErrNoLeader),
+		errors.Is(err, rpctypes.ErrLeaderChanged),
+		errors.Is(err, rpctypes.ErrNotCapable),
+		errors.Is(err, rpctypes.ErrStopped),
+		errors.Is(err, rpctypes.ErrTimeout),
+		errors.Is(err, rpctypes.ErrTimeoutDueToLeaderFail),
+		errors.Is(err, rpctypes.ErrTimeoutDueToConnectionLost),
+		errors.Is(err, rpctypes.ErrUnhealthy):
+		return true
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// isRetryableError reports whether the list and watch are retried after failing with the error.
+// Connection and watch errors are, errors that would only happen again and the cancellation
+// of the context are not.
+func isRetryableError(err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, errInvalidEvent):
+		return false
+	}
+	return true
+}
+
 // runListWatchLoop handles the main loop of listing and watching items
-func (lw *ListWatch) runListWatchLoop(ctx context.Context, ch chan Event, done chan struct{}) {
+func (lw *ListWatch) runListWatchLoop(ctx context.Context, ch chan Event, done chan struct{}, markLists bool) {
 	defer lw.handleCleanup(ctx, ch, done)
 
 	for {
@@ -444,25 +1046,40 @@ func (lw *ListWatch) runListWatchLoop(ctx context.Context, ch chan Event, done c
 			return
 		}
 
-		err := retry.WithExponentialBackoff(ctx, lw.opts.RetryOpts, func(ctx context.Context) error {
+		retryOpts := lw.opts.RetryOpts
+		if retryOpts.IsRetryable == nil {
+			retryOpts.IsRetryable = isRetryableError
+		}
+		err := retry.WithExponentialBackoff(ctx, retryOpts, func(ctx context.Context) error {
 			// Ensure we have a valid connection
 			if err := lw.ensureConnected(ctx, ch); err != nil {
 				return err
 			}
 
 			// List existing items
-			if err := lw.listAndSendExisting(ctx, ch); err != nil {
+			revision, err := lw.listAndSendExisting(ctx, ch, markLists)
+			if err != nil {
 				return err
 			}
 
 			// Watch for changes
-			if err := lw.watchAndForwardEvents(ctx, ch); err != nil {
+			if err := lw.watchAndForwardEvents(ctx, ch, revision); err != nil {
 				return err
 			}
 
 			return nil
 		})
 
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, retry.ErrRetryExhausted) || (err != nil && !retryOpts.IsRetryable(err)) {
+			// The retries are capped or the error is permanent, the ListWatch gives up and
+			// closes its channel
+			lw.logger.Error("ListWatch giving up", "error", err)
+			lw.tryToSendErrorEvent(ch, err.Error(), ctx)
+			return
+		}
 		if err != nil && err != context.Canceled {
 			lw.logger.Error("ListWatch loop failed", "error", err)
 		}
@@ -473,11 +1090,17 @@ func (lw *ListWatch) runListWatchLoop(ctx context.Context, ch chan Event, done c
 // It first lists all existing items and then starts watching for changes.
 // If the watch operation fails, it will retry with exponential backoff.
 func (lw *ListWatch) ListAndWatch(ctx context.Context) (<-chan Event, func(), error) {
+	return lw.listAndWatch(ctx, false)
+}
+
+// listAndWatch is ListAndWatch that, with markLists, flags the events of every list, the first
+// one and the relists after a reconnect, and sends a listed event once a list is complete
+func (lw *ListWatch) listAndWatch(ctx context.Context, markLists bool) (<-chan Event, func(), error) {
 	ch := make(chan Event, lw.opts.EventChannelBuffer)
 	done := make(chan struct{})
 	watchCtx, cancelWatch := context.WithCancel(ctx)
 
-	go lw.runListWatchLoop(watchCtx, ch, done)
+	go lw.runListWatchLoop(watchCtx, ch, done, markLists)
 
 	// Return cancel function that ensures cleanup
 	cancel := func() {
@@ -2,17 +2,27 @@ package listwatch
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gokube/pkg/etcdclient"
 	"gokube/pkg/retry"
 	"gokube/pkg/storage"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/server/v3/embed"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // zapLogger adapts zap.Logger to our Logger interface
@@ -75,6 +85,17 @@ func TestNewListWatch(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name:      "missing TLS file",
+			prefix:    "/test/prefix",
+			endpoints: []string{"localhost:2379"},
+			opts: func() Options {
+				opts := DefaultOptions()
+				opts.Security = etcdclient.Security{CAFile: "/does/not/exist/ca.pem"}
+				return opts
+			}(),
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -96,6 +117,39 @@ func TestNewListWatch(t *testing.T) {
 	}
 }
 
+func TestNewListWatch_MetricsPerPrefix(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := DefaultOptions()
+	opts.Registerer = registry
+
+	newListWatch := func(prefix string) *ListWatch {
+		lw, err := NewListWatch([]string{"localhost:2379"}, prefix, opts, setupLogger(t))
+		require.NoError(t, err)
+		return lw
+	}
+
+	pods := newListWatch("/pods/")
+	nodes := newListWatch("/nodes/")
+	podsAgain := newListWatch("/pods/")
+
+	pods.metrics.eventProcessed.Inc()
+	nodes.metrics.eventProcessed.Add(2)
+
+	// ListWatches of the same prefix share their metrics
+	assert.Same(t, pods.metrics.eventsByType, podsAgain.metrics.eventsByType)
+	assert.Equal(t, float64(1), testutil.ToFloat64(podsAgain.metrics.eventProcessed))
+
+	count, err := testutil.GatherAndCount(registry, "listwatch_events_processed_total")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP listwatch_events_processed_total Total number of events delivered
+# TYPE listwatch_events_processed_total counter
+listwatch_events_processed_total{component="listwatch",prefix="/nodes/"} 2
+listwatch_events_processed_total{component="listwatch",prefix="/pods/"} 1
+`), "listwatch_events_processed_total"))
+}
+
 // setupEtcd starts an embedded etcd server and returns its cleanup function
 func setupEtcd(t *testing.T) (*embed.Etcd, string, func()) {
 	// Start embedded etcd
@@ -240,6 +294,14 @@ func TestListWatch_RetryBehavior(t *testing.T) {
 	}
 }
 
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, isRetryableError(errors.New("connection refused")))
+	assert.True(t, isRetryableError(fmt.Errorf("watch failed: %w", rpctypes.ErrCompacted)))
+	assert.False(t, isRetryableError(context.Canceled))
+	assert.False(t, isRetryableError(fmt.Errorf("list failed: %w", context.DeadlineExceeded)))
+	assert.False(t, isRetryableError(fmt.Errorf("%w: empty prefix", errInvalidEvent)))
+}
+
 func TestListWatch_Integration(t *testing.T) {
 	// Setup embedded etcd
 	_, endpoint, cleanup := setupEtcd(t)
@@ -325,3 +387,962 @@ func TestListWatch_Integration(t *testing.T) {
 		t.Fatal("timeout waiting for deletion event")
 	}
 }
+
+// recordingLogger records log calls by level
+type recordingLogger struct {
+	mu     sync.Mutex
+	infos  []string
+	errors []string
+	debugs []string
+}
+
+func (r *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.infos = append(r.infos, msg)
+}
+
+func (r *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, msg)
+}
+
+func (r *recordingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.debugs = append(r.debugs, msg)
+}
+
+func TestListWatch_EventLogSampling(t *testing.T) {
+	newTestListWatch := func(opts Options, logger Logger) *ListWatch {
+		return &ListWatch{
+			watchPrefix: "/test/",
+			opts:        opts,
+			metrics:     newTestMetrics("/test/"),
+			logger:      logger,
+		}
+	}
+
+	sendEvents := func(t *testing.T, lw *ListWatch, count int) {
+		ctx := context.Background()
+		ch := make(chan Event, count)
+		for i := 0; i < count; i++ {
+			err := lw.sendEvent(ctx, ch, Event{Type: Added, Key: fmt.Sprintf("/test/key%d", i), Prefix: "/test/"})
+			require.NoError(t, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		sampleRate int
+		level      LogLevel
+		events     int
+		wantInfos  int
+		wantDebugs int
+	}{
+		{name: "disabled", sampleRate: 0, level: LogLevelInfo, events: 100, wantInfos: 0},
+		{name: "every event", sampleRate: 1, level: LogLevelInfo, events: 100, wantInfos: 100},
+		{name: "one in ten", sampleRate: 10, level: LogLevelInfo, events: 100, wantInfos: 10},
+		{name: "one in seven rounds up", sampleRate: 7, level: LogLevelInfo, events: 100, wantInfos: 15},
+		{name: "debug level", sampleRate: 10, level: LogLevelDebug, events: 100, wantDebugs: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			opts := DefaultOptions()
+			opts.EventLogSampleRate = tt.sampleRate
+			opts.EventLogLevel = tt.level
+			lw := newTestListWatch(opts, logger)
+
+			sendEvents(t, lw, tt.events)
+
+			assert.Len(t, logger.infos, tt.wantInfos)
+			assert.Len(t, logger.debugs, tt.wantDebugs)
+			assert.Empty(t, logger.errors)
+		})
+	}
+
+	t.Run("error events are always logged", func(t *testing.T) {
+		logger := &recordingLogger{}
+		opts := DefaultOptions()
+		opts.EventLogSampleRate = 1000
+		lw := newTestListWatch(opts, logger)
+
+		ch := make(chan Event, 10)
+		for i := 0; i < 3; i++ {
+			require.True(t, lw.tryToSendErrorEvent(ch, "boom", context.Background()))
+		}
+
+		assert.Len(t, logger.errors, 3)
+		assert.Empty(t, logger.infos)
+	})
+
+	t.Run("error events are logged with event logging disabled", func(t *testing.T) {
+		logger := &recordingLogger{}
+		opts := DefaultOptions()
+		opts.EventLogSampleRate = 0
+		lw := newTestListWatch(opts, logger)
+
+		require.True(t, lw.tryToSendErrorEvent(make(chan Event, 1), "boom", context.Background()))
+		sendEvents(t, lw, 10)
+
+		assert.Len(t, logger.errors, 1)
+		assert.Empty(t, logger.infos)
+	})
+}
+
+func TestListWatch_OverflowPolicy(t *testing.T) {
+	newTestListWatch := func(policy OverflowPolicy) *ListWatch {
+		opts := DefaultOptions()
+		opts.OverflowPolicy = policy
+		return &ListWatch{
+			watchPrefix: "/test/",
+			opts:        opts,
+			metrics:     newTestMetrics("/test/"),
+			logger:      &recordingLogger{},
+		}
+	}
+
+	newEvent := func(i int) Event {
+		return Event{Type: Added, Key: fmt.Sprintf("/test/key%d", i), Prefix: "/test/"}
+	}
+
+	// drain returns the keys buffered in the channel
+	drain := func(ch chan Event) []string {
+		var keys []string
+		for {
+			select {
+			case event := <-ch:
+				keys = append(keys, event.Key)
+			default:
+				return keys
+			}
+		}
+	}
+
+	dropped := func(lw *ListWatch, policy OverflowPolicy) float64 {
+		return testutil.ToFloat64(lw.metrics.eventsDropped.WithLabelValues(string(policy)))
+	}
+
+	t.Run("block waits for the stalled consumer", func(t *testing.T) {
+		lw := newTestListWatch(OverflowBlock)
+		ch := make(chan Event, 2)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		require.NoError(t, lw.sendEvent(ctx, ch, newEvent(0)))
+		require.NoError(t, lw.sendEvent(ctx, ch, newEvent(1)))
+
+		err := lw.sendEvent(ctx, ch, newEvent(2))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, []string{"/test/key0", "/test/key1"}, drain(ch))
+	})
+
+	t.Run("drop oldest keeps the latest events", func(t *testing.T) {
+		lw := newTestListWatch(OverflowDropOldest)
+		ch := make(chan Event, 2)
+		before := dropped(lw, OverflowDropOldest)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, lw.sendEvent(context.Background(), ch, newEvent(i)))
+		}
+
+		assert.Equal(t, []string{"/test/key3", "/test/key4"}, drain(ch))
+		assert.Equal(t, before+3, dropped(lw, OverflowDropOldest))
+	})
+
+	t.Run("drop newest keeps the earliest events", func(t *testing.T) {
+		lw := newTestListWatch(OverflowDropNewest)
+		ch := make(chan Event, 2)
+		before := dropped(lw, OverflowDropNewest)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, lw.sendEvent(context.Background(), ch, newEvent(i)))
+		}
+
+		assert.Equal(t, []string{"/test/key0", "/test/key1"}, drain(ch))
+		assert.Equal(t, before+3, dropped(lw, OverflowDropNewest))
+	})
+
+	t.Run("error fails the send when the channel is full", func(t *testing.T) {
+		lw := newTestListWatch(OverflowError)
+		ch := make(chan Event, 2)
+		before := dropped(lw, OverflowError)
+
+		require.NoError(t, lw.sendEvent(context.Background(), ch, newEvent(0)))
+		require.NoError(t, lw.sendEvent(context.Background(), ch, newEvent(1)))
+
+		err := lw.sendEvent(context.Background(), ch, newEvent(2))
+		assert.ErrorIs(t, err, ErrEventChannelFull)
+		assert.Equal(t, []string{"/test/key0", "/test/key1"}, drain(ch))
+		assert.Equal(t, before+1, dropped(lw, OverflowError))
+	})
+}
+
+func TestListWatch_CleanShutdown(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	opts := DefaultOptions()
+	opts.RetryOpts = retry.Options{
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+		Multiplier:   1.5,
+	}
+
+	// drainUntilClosed returns the events left on the channel, failing if it isn't closed in time
+	drainUntilClosed := func(t *testing.T, ch <-chan Event) []Event {
+		var events []Event
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case event, ok := <-ch:
+				if !ok {
+					return events
+				}
+				events = append(events, event)
+			case <-timeout:
+				t.Fatal("timeout waiting for the event channel to close")
+			}
+		}
+	}
+
+	shutdowns := map[string]func(stopWatch func(), cancel context.CancelFunc){
+		"stop function":     func(stopWatch func(), _ context.CancelFunc) { stopWatch() },
+		"context cancelled": func(_ func(), cancel context.CancelFunc) { cancel() },
+		"cancel then stop":  func(stopWatch func(), cancel context.CancelFunc) { cancel(); stopWatch() },
+		"stop then cancel":  func(stopWatch func(), cancel context.CancelFunc) { stopWatch(); cancel() },
+	}
+
+	for name, shutdown := range shutdowns {
+		t.Run(name, func(t *testing.T) {
+			prefix := fmt.Sprintf("/test/shutdown/%s/", name)
+			lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ch, stopWatch, err := lw.ListAndWatch(ctx)
+			require.NoError(t, err)
+
+			// Wait for the watch to be established and deliver an event
+			require.Eventually(t, func() bool {
+				cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}, DialTimeout: time.Second})
+				if err != nil {
+					return false
+				}
+				defer cli.Close()
+				_, err = cli.Put(ctx, prefix+"key", "value")
+				return err == nil
+			}, 5*time.Second, 50*time.Millisecond)
+
+			err = waitForEvents(t, ch, 5*time.Second, testEventCondition{
+				description: "Added event",
+				condition: func(event Event) bool {
+					return event.Key == prefix+"key"
+				},
+			})
+			require.NoError(t, err)
+
+			shutdown(stopWatch, cancel)
+			if name == "context cancelled" {
+				defer stopWatch()
+			}
+
+			for _, event := range drainUntilClosed(t, ch) {
+				assert.NotEqual(t, Error, event.Type, "unexpected error event on shutdown: %s", string(event.Value))
+			}
+		})
+	}
+}
+
+func TestListWatch_ListAndWatchMissesNoChangeAfterTheList(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}, DialTimeout: time.Second})
+	require.NoError(t, err)
+	defer cli.Close()
+
+	// A key is written right after the prefix is listed, before the watch is started
+	prefix := "/test/gap/"
+	var once sync.Once
+	opts := DefaultOptions()
+	opts.ClientHooks = []etcdclient.Hook{{
+		After: func(ctx context.Context, call etcdclient.Call, duration time.Duration, err error) {
+			if call.Operation != etcdclient.OperationGet || call.Key != prefix {
+				return
+			}
+			once.Do(func() {
+				_, err := cli.Put(context.Background(), prefix+"written-after-list", "value")
+				require.NoError(t, err)
+			})
+		},
+	}}
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ch, stopWatch, err := lw.ListAndWatch(ctx)
+	require.NoError(t, err)
+	defer stopWatch()
+
+	select {
+	case event := <-ch:
+		assert.Equal(t, Added, event.Type)
+		assert.Equal(t, prefix+"written-after-list", event.Key)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the key written between the list and the watch was never delivered")
+	}
+}
+
+func TestListWatch_PublishesWatermark(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}, DialTimeout: time.Second})
+	require.NoError(t, err)
+	defer cli.Close()
+	client := etcdclient.Wrap(cli)
+
+	prefix := "/test/watermark/"
+	opts := DefaultOptions()
+	opts.WatermarkInterval = 20 * time.Millisecond
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ch, stopWatch, err := lw.ListAndWatch(ctx)
+	require.NoError(t, err)
+
+	// The watermark follows the revisions the watch delivered, so compaction keeps the rest
+	resp, err := cli.Put(ctx, prefix+"key", "value")
+	require.NoError(t, err)
+	require.NoError(t, waitForEvents(t, ch, 3*time.Second, testEventCondition{
+		description: "the put key",
+		condition:   func(event Event) bool { return event.Key == prefix+"key" },
+	}))
+	require.Eventually(t, func() bool {
+		revision, ok, err := etcdclient.MinWatermark(ctx, client)
+		return err == nil && ok && revision >= resp.Header.Revision
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// A stopped watch needs no revision anymore
+	stopWatch()
+	_, ok, err := etcdclient.MinWatermark(ctx, client)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestListWatch_TransientWatchErrorResumes(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	// The injected watch failure is a cancelled response, which is treated as transient here
+	prefix := "/test/resume/"
+	opts := DefaultOptions()
+	opts.IsTransientWatchError = func(err error) bool { return errors.Is(err, rpctypes.ErrFutureRev) }
+	opts.RetryOpts = retry.Options{InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Multiplier: 1.5}
+
+	// Fail the first watch on demand; later watches are passed through
+	inject := make(chan struct{})
+	var watches atomic.Int32
+	opts.WatchFunc = func(ctx context.Context, client *etcdclient.Client, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+		watchChan := client.Watch(ctx, key, opts...)
+		if watches.Add(1) > 1 {
+			return watchChan
+		}
+
+		out := make(chan clientv3.WatchResponse)
+		go func() {
+			defer close(out)
+			for {
+				select {
+				case resp, ok := <-watchChan:
+					if !ok {
+						return
+					}
+					select {
+					case out <- resp:
+					case <-ctx.Done():
+						return
+					}
+				case <-inject:
+					select {
+					case out <- clientv3.WatchResponse{Canceled: true}:
+					case <-ctx.Done():
+					}
+					return
+				}
+			}
+		}()
+		return out
+	}
+
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = lw.etcdCli.Put(ctx, prefix+"key0", "value0")
+	require.NoError(t, err)
+
+	resumed := func() float64 {
+		return testutil.ToFloat64(lw.metrics.watchResumes.WithLabelValues("resumed"))
+	}
+	before := resumed()
+
+	ch, stopWatch, err := lw.ListAndWatch(ctx)
+	require.NoError(t, err)
+	defer stopWatch()
+
+	next := func() Event {
+		select {
+		case event, ok := <-ch:
+			require.True(t, ok, "event channel closed")
+			return event
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return Event{}
+		}
+	}
+
+	assert.Equal(t, prefix+"key0", next().Key)
+
+	// The watch starts from the revision current when it is established
+	require.Eventually(t, func() bool { return watches.Load() == 1 }, 3*time.Second, 10*time.Millisecond)
+
+	_, err = lw.etcdCli.Put(ctx, prefix+"key1", "value1")
+	require.NoError(t, err)
+	assert.Equal(t, prefix+"key1", next().Key)
+
+	// Changes made while the watch is down are replayed once it resumes
+	close(inject)
+	_, err = lw.etcdCli.Put(ctx, prefix+"key2", "value2")
+	require.NoError(t, err)
+	_, err = lw.etcdCli.Put(ctx, prefix+"key3", "value3")
+	require.NoError(t, err)
+
+	for _, key := range []string{"key2", "key3"} {
+		event := next()
+		assert.Equal(t, Added, event.Type, "unexpected %s event: %s", event.Type, string(event.Value))
+		assert.Equal(t, prefix+key, event.Key, "the watch should resume without relisting")
+	}
+
+	assert.Equal(t, int32(2), watches.Load())
+	assert.Equal(t, before+1, resumed())
+}
+
+func TestListWatch_WatchKey(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	prefix := "/test/watchkey/"
+	lw, err := NewListWatch([]string{endpoint}, prefix, DefaultOptions(), &recordingLogger{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _, err = lw.WatchKey(ctx, "")
+	assert.Error(t, err)
+
+	key := prefix + "pod"
+	ch, stopWatch, err := lw.WatchKey(ctx, key)
+	require.NoError(t, err)
+	defer stopWatch()
+	assert.Equal(t, DefaultOptions().EventChannelBuffer, cap(ch))
+
+	for _, neighbor := range []string{prefix + "pod-1", prefix + "other"} {
+		_, err = lw.etcdCli.Put(ctx, neighbor, "value")
+		require.NoError(t, err)
+	}
+	_, err = lw.etcdCli.Put(ctx, key, "v1")
+	require.NoError(t, err)
+	_, err = lw.etcdCli.Put(ctx, key, "v2")
+	require.NoError(t, err)
+	_, err = lw.etcdCli.Delete(ctx, prefix+"pod-1")
+	require.NoError(t, err)
+	_, err = lw.etcdCli.Delete(ctx, key)
+	require.NoError(t, err)
+
+	for _, expected := range []EventType{Added, Modified, Deleted} {
+		select {
+		case event := <-ch:
+			assert.Equal(t, expected, event.Type)
+			assert.Equal(t, key, event.Key)
+			assert.Equal(t, key, event.Prefix)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %s event", expected)
+		}
+	}
+
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected %s event for %s", event.Type, event.Key)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestListWatch_SuppressUnchanged(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	for _, suppress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("suppress=%t", suppress), func(t *testing.T) {
+			prefix := fmt.Sprintf("/test/suppress-%t/", suppress)
+			opts := DefaultOptions()
+			opts.SuppressUnchanged = suppress
+			lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+			require.NoError(t, err)
+			lw.metrics = newTestMetrics(prefix)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			ch, stopWatch, err := lw.Watch(ctx)
+			require.NoError(t, err)
+			defer stopWatch()
+
+			key := prefix + "pod"
+			for _, value := range []string{"v1", "v1", "v1", "v2"} {
+				_, err = lw.etcdCli.Put(ctx, key, value)
+				require.NoError(t, err)
+			}
+
+			var values []string
+			var lastRevision int64
+			for len(values) == 0 || !strings.HasSuffix(values[len(values)-1], "v2") {
+				select {
+				case event := <-ch:
+					values = append(values, fmt.Sprintf("%s %s", event.Type, event.Value))
+					lastRevision = event.Revision
+				case <-time.After(3 * time.Second):
+					t.Fatalf("timed out waiting for events, got %v", values)
+				}
+			}
+
+			if suppress {
+				assert.Equal(t, []string{"ADDED v1", "MODIFIED v2"}, values)
+				assert.Equal(t, 2.0, testutil.ToFloat64(lw.metrics.eventsSuppressed))
+			} else {
+				assert.Equal(t, []string{"ADDED v1", "MODIFIED v1", "MODIFIED v1", "MODIFIED v2"}, values)
+				assert.Zero(t, testutil.ToFloat64(lw.metrics.eventsSuppressed))
+			}
+
+			// The event of a real change carries its own revision, past the suppressed ones
+			resp, err := lw.etcdCli.Get(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, resp.Kvs[0].ModRevision, lastRevision)
+		})
+	}
+}
+
+func TestListWatch_MaxEventValueSize(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	prefix := "/test/oversized/"
+	opts := DefaultOptions()
+	opts.MaxEventValueSize = 8
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+	lw.metrics = newTestMetrics(prefix)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = lw.etcdCli.Put(ctx, prefix+"listed", strings.Repeat("l", 1024))
+	require.NoError(t, err)
+	listed, err := lw.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.True(t, listed[0].Truncated)
+	assert.Equal(t, "llllllll", string(listed[0].Value))
+
+	ch, stopWatch, err := lw.Watch(ctx)
+	require.NoError(t, err)
+	defer stopWatch()
+
+	_, err = lw.etcdCli.Put(ctx, prefix+"large", strings.Repeat("x", 1024))
+	require.NoError(t, err)
+	_, err = lw.etcdCli.Put(ctx, prefix+"small", "small")
+	require.NoError(t, err)
+
+	for _, expected := range []struct {
+		key       string
+		value     string
+		truncated bool
+	}{
+		{prefix + "large", "xxxxxxxx", true},
+		{prefix + "small", "small", false},
+	} {
+		select {
+		case event := <-ch:
+			assert.Equal(t, expected.key, event.Key)
+			assert.Equal(t, expected.value, string(event.Value))
+			assert.Equal(t, expected.truncated, event.Truncated)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for the event of %s", expected.key)
+		}
+	}
+	assert.Equal(t, 2.0, testutil.ToFloat64(lw.metrics.eventsTruncated))
+}
+
+func TestListWatch_ResyncPeriod(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	prefix := "/test/resync/"
+	opts := DefaultOptions()
+	opts.ResyncPeriod = 300 * time.Millisecond
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = lw.etcdCli.Put(ctx, prefix+"a", "v1")
+	require.NoError(t, err)
+
+	ch, stop, err := lw.ListAndWatch(ctx)
+	require.NoError(t, err)
+	defer stop()
+
+	next := func() Event {
+		select {
+		case event := <-ch:
+			return event
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for event")
+			return Event{}
+		}
+	}
+
+	listed := next()
+	assert.Equal(t, Added, listed.Type)
+	assert.False(t, listed.IsResync)
+
+	_, err = lw.etcdCli.Put(ctx, prefix+"b", "v1")
+	require.NoError(t, err)
+
+	// Resyncs re-emit every key, including one written while the watch was being started
+	// that the watch may have missed. The key that was not changed isn't reported otherwise.
+	resynced := map[string]bool{}
+	for !resynced[prefix+"a"] || !resynced[prefix+"b"] {
+		event := next()
+		if !event.IsResync {
+			assert.Equal(t, prefix+"b", event.Key)
+			continue
+		}
+		assert.Equal(t, Added, event.Type)
+		resynced[event.Key] = true
+	}
+}
+
+func TestIsTransientWatchError(t *testing.T) {
+	assert.True(t, isTransientWatchError(rpctypes.ErrNoLeader))
+	assert.True(t, isTransientWatchError(rpctypes.ErrLeaderChanged))
+	assert.True(t, isTransientWatchError(status.Error(codes.Unavailable, "connection refused")))
+	assert.False(t, isTransientWatchError(rpctypes.ErrCompacted))
+	assert.False(t, isTransientWatchError(rpctypes.ErrFutureRev))
+	assert.False(t, isTransientWatchError(errors.New("permission denied")))
+}
+
+func TestEventSequencer(t *testing.T) {
+	sequencer := &eventSequencer{}
+
+	assert.True(t, sequencer.next("/a", 5))
+	assert.True(t, sequencer.next("/b", 5), "keys changed in one transaction share a revision")
+	assert.False(t, sequencer.next("/a", 5), "a key can't be delivered twice at a revision")
+	assert.True(t, sequencer.next("/a", 6))
+	assert.False(t, sequencer.next("/b", 5), "a key can't go back in revision")
+	assert.False(t, sequencer.next("/c", 4), "events can't go back in revision")
+	assert.True(t, sequencer.next("/b", 7))
+}
+
+func TestListWatch_PerKeyRevisionOrder(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	// The injected watch failure is a cancelled response, which is treated as transient here
+	prefix := "/test/ordering/"
+	opts := DefaultOptions()
+	opts.IsTransientWatchError = func(err error) bool { return errors.Is(err, rpctypes.ErrFutureRev) }
+	opts.WatchResumeAttempts = 1000
+
+	// Every watch fails after its first response, and resumed watches start a few revisions
+	// before the requested one, replaying events that were delivered already
+	const replayedRevisions = 10
+	opts.WatchFunc = func(ctx context.Context, client *etcdclient.Client, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+		if rev := clientv3.OpGet(key, opts...).Rev(); rev > replayedRevisions {
+			opts = append(opts, clientv3.WithRev(rev-replayedRevisions))
+		}
+		watchChan := client.Watch(ctx, key, opts...)
+
+		out := make(chan clientv3.WatchResponse)
+		go func() {
+			defer close(out)
+			for _, failed := range []bool{false, true} {
+				resp, ok := clientv3.WatchResponse{Canceled: true}, true
+				if !failed {
+					resp, ok = <-watchChan
+				}
+				if !ok {
+					return
+				}
+				select {
+				case out <- resp:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+
+	lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ch, stopWatch, err := lw.Watch(ctx)
+	require.NoError(t, err)
+	defer stopWatch()
+
+	const writers, writesPerWriter = 4, 200
+	keys := []string{prefix + "hot", prefix + "warm"}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writesPerWriter; i++ {
+				key := keys[0]
+				if i%4 == 0 {
+					key = keys[1]
+				}
+				if _, err := lw.etcdCli.Put(ctx, key, fmt.Sprintf("writer-%d-%d", w, i)); err != nil {
+					t.Errorf("put failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	lastRevision := map[string]int64{}
+	for received := 0; received < writers*writesPerWriter; received++ {
+		select {
+		case event, ok := <-ch:
+			require.True(t, ok, "event channel closed")
+			require.NotEqual(t, Error, event.Type, "unexpected error event: %s", string(event.Value))
+			require.Greater(t, event.Revision, lastRevision[event.Key], "event for %s delivered out of revision order", event.Key)
+			lastRevision[event.Key] = event.Revision
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d events", received)
+		}
+	}
+	wg.Wait()
+
+	// No further events, such as replayed duplicates, follow the writes
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event %s for %s at revision %d", event.Type, event.Key, event.Revision)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestListWatch_EventMetricsByType(t *testing.T) {
+	_, endpoint, cleanup := setupEtcd(t)
+	defer cleanup()
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}, DialTimeout: time.Second})
+	require.NoError(t, err)
+	defer cli.Close()
+
+	snapshot := func(m *metrics) map[EventType]float64 {
+		counts := map[EventType]float64{"total": testutil.ToFloat64(m.eventProcessed)}
+		for _, eventType := range []EventType{Added, Modified, Deleted, Error} {
+			counts[eventType] = testutil.ToFloat64(m.eventsByType.WithLabelValues(string(eventType)))
+		}
+		return counts
+	}
+
+	// assertCounted checks that the counters grew by exactly the events received
+	assertCounted := func(t *testing.T, m *metrics, before map[EventType]float64, received []Event) {
+		expected := map[EventType]float64{"total": before["total"] + float64(len(received))}
+		for _, eventType := range []EventType{Added, Modified, Deleted, Error} {
+			expected[eventType] = before[eventType]
+		}
+		for _, event := range received {
+			expected[event.Type]++
+		}
+		assert.Equal(t, expected, snapshot(m))
+	}
+
+	// failingWatch makes the first watch end with a non-transient error once fail is closed.
+	// The returned channel is closed when the first watch has started.
+	failingWatch := func(opts *Options, fail chan struct{}) <-chan struct{} {
+		var once sync.Once
+		started := make(chan struct{})
+		opts.WatchFunc = func(ctx context.Context, client *etcdclient.Client, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+			watchChan := client.Watch(ctx, key, opts...)
+			failing := false
+			once.Do(func() { failing = true })
+			if !failing {
+				return watchChan
+			}
+			defer close(started)
+
+			out := make(chan clientv3.WatchResponse)
+			go func() {
+				defer close(out)
+				for {
+					select {
+					case resp, ok := <-watchChan:
+						if !ok {
+							return
+						}
+						select {
+						case out <- resp:
+						case <-ctx.Done():
+							return
+						}
+					case <-fail:
+						// A cancelled response without a reason is reported as rpctypes.ErrFutureRev
+						select {
+						case out <- clientv3.WatchResponse{Canceled: true}:
+						case <-ctx.Done():
+						}
+						return
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return out
+		}
+		return started
+	}
+
+	// receive reads events until one matches done
+	receive := func(t *testing.T, ch <-chan Event, done func(Event) bool) []Event {
+		var received []Event
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case event, ok := <-ch:
+				require.True(t, ok, "channel closed after %v", received)
+				received = append(received, event)
+				if done(event) {
+					return received
+				}
+			case <-timeout:
+				t.Fatalf("timed out, received %v", received)
+			}
+		}
+	}
+
+	// changeKeys modifies, deletes and adds keys under prefix
+	changeKeys := func(t *testing.T, prefix string) {
+		ctx := context.Background()
+		_, err := cli.Put(ctx, prefix+"a", "modified")
+		require.NoError(t, err)
+		_, err = cli.Delete(ctx, prefix+"a")
+		require.NoError(t, err)
+		_, err = cli.Put(ctx, prefix+"b", "added")
+		require.NoError(t, err)
+	}
+
+	t.Run("ListAndWatch", func(t *testing.T) {
+		prefix := "/test/metrics/listandwatch/"
+		_, err := cli.Put(context.Background(), prefix+"a", "listed")
+		require.NoError(t, err)
+
+		fail := make(chan struct{})
+		opts := DefaultOptions()
+		watching := failingWatch(&opts, fail)
+		lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+		require.NoError(t, err)
+
+		before := snapshot(lw.metrics)
+		ch, stop, err := lw.ListAndWatch(context.Background())
+		require.NoError(t, err)
+
+		received := receive(t, ch, func(e Event) bool { return e.Type == Added && e.Key == prefix+"a" })
+		<-watching
+		changeKeys(t, prefix)
+		received = append(received, receive(t, ch, func(e Event) bool { return e.Key == prefix+"b" })...)
+
+		// The failed watch is reported and the relist delivers the remaining key again
+		close(fail)
+		received = append(received, receive(t, ch, func(e Event) bool { return e.Type == Added && e.Key == prefix+"b" })...)
+
+		stop()
+		for event := range ch {
+			received = append(received, event)
+		}
+
+		types := make(map[EventType]bool)
+		for _, event := range received {
+			types[event.Type] = true
+		}
+		assert.Equal(t, map[EventType]bool{Added: true, Modified: true, Deleted: true, Error: true}, types)
+		assertCounted(t, lw.metrics, before, received)
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		prefix := "/test/metrics/watch/"
+		fail := make(chan struct{})
+		opts := DefaultOptions()
+		failingWatch(&opts, fail)
+		lw, err := NewListWatch([]string{endpoint}, prefix, opts, &recordingLogger{})
+		require.NoError(t, err)
+
+		before := snapshot(lw.metrics)
+		ch, stop, err := lw.Watch(context.Background())
+		require.NoError(t, err)
+		defer stop()
+
+		_, err = cli.Put(context.Background(), prefix+"a", "added")
+		require.NoError(t, err)
+		changeKeys(t, prefix)
+		received := receive(t, ch, func(e Event) bool { return e.Key == prefix+"b" })
+
+		close(fail)
+		received = append(received, receive(t, ch, func(e Event) bool { return e.Type == Error })...)
+		for event := range ch {
+			received = append(received, event)
+		}
+
+		assert.Equal(t, []EventType{Added, Modified, Deleted, Added, Error}, eventTypes(received))
+		assertCounted(t, lw.metrics, before, received)
+	})
+}
+
+// newTestMetrics returns metrics for the prefix registered with a new registry
+func newTestMetrics(prefix string) *metrics {
+	m, err := newMetrics(prometheus.NewRegistry(), prefix)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
@@ -1,7 +1,8 @@
 package listwatch
 
 import (
-	"sync"
+	"errors"
+	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -16,85 +17,140 @@ type metrics struct {
 	connectionState      prometheus.Gauge
 	watchSessionDuration prometheus.Histogram
 	errorsByType         *prometheus.CounterVec
+	eventsDropped        *prometheus.CounterVec
+	eventsSuppressed     prometheus.Counter
+	eventsTruncated      prometheus.Counter
+	watchResumes         *prometheus.CounterVec
 }
 
-var (
-	defaultMetrics *metrics
-	metricsOnce    sync.Once
-)
+// newMetrics creates the metrics of a ListWatch watching the prefix and registers them with the
+// registerer. The metrics carry the prefix as a label, so ListWatches of different prefixes have
+// their own metrics. Metrics already registered for the same prefix are reused.
+func newMetrics(registerer prometheus.Registerer, prefix string) (*metrics, error) {
+	labels := prometheus.Labels{"component": "listwatch", "prefix": prefix}
+	m := &metrics{
+		watchFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "listwatch_watch_failures_total",
+			Help:        "Total number of watch operation failures",
+			ConstLabels: labels,
+		}),
+		listLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "listwatch_list_duration_seconds",
+			Help:        "Duration of list operations in seconds",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+		watchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "listwatch_watch_event_duration_seconds",
+			Help:        "Duration of watch event processing in seconds",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+		eventProcessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "listwatch_events_processed_total",
+			Help:        "Total number of events delivered",
+			ConstLabels: labels,
+		}),
+		retryCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "listwatch_retry_attempts_total",
+			Help:        "Total number of retry attempts",
+			ConstLabels: labels,
+		}),
+		eventsByType: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "listwatch_events_by_type_total",
+				Help:        "Total number of events delivered by type (added/modified/deleted/error)",
+				ConstLabels: labels,
+			},
+			[]string{"event_type"},
+		),
+		connectionState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "listwatch_connection_state",
+			Help:        "Current connection state (1=connected, 0=disconnected)",
+			ConstLabels: labels,
+		}),
+		watchSessionDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "listwatch_watch_session_duration_seconds",
+			Help:        "Duration of watch sessions in seconds",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+		errorsByType: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "listwatch_errors_by_type_total",
+				Help:        "Total number of errors by type",
+				ConstLabels: labels,
+			},
+			[]string{"error_type"},
+		),
+		eventsDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "listwatch_events_dropped_total",
+				Help:        "Total number of events dropped because the event channel was full, by overflow policy",
+				ConstLabels: labels,
+			},
+			[]string{"policy"},
+		),
+		eventsSuppressed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "listwatch_events_suppressed_total",
+			Help:        "Total number of Modified events suppressed because the value didn't change",
+			ConstLabels: labels,
+		}),
+		eventsTruncated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "listwatch_events_truncated_total",
+			Help:        "Total number of events whose value was truncated to the maximum event value size",
+			ConstLabels: labels,
+		}),
+		watchResumes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "listwatch_watch_resumes_total",
+				Help:        "Total number of watch errors by outcome (resumed from the last revision or failed)",
+				ConstLabels: labels,
+			},
+			[]string{"result"},
+		),
+	}
 
-func newMetrics() *metrics {
-	metricsOnce.Do(func() {
-		defaultMetrics = &metrics{
-			watchFailures: prometheus.NewCounter(prometheus.CounterOpts{
-				Name:        "listwatch_watch_failures_total",
-				Help:        "Total number of watch operation failures",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-			}),
-			listLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
-				Name:        "listwatch_list_duration_seconds",
-				Help:        "Duration of list operations in seconds",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-				Buckets:     prometheus.DefBuckets,
-			}),
-			watchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
-				Name:        "listwatch_watch_event_duration_seconds",
-				Help:        "Duration of watch event processing in seconds",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-				Buckets:     prometheus.DefBuckets,
-			}),
-			eventProcessed: prometheus.NewCounter(prometheus.CounterOpts{
-				Name:        "listwatch_events_processed_total",
-				Help:        "Total number of events processed",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-			}),
-			retryCount: prometheus.NewCounter(prometheus.CounterOpts{
-				Name:        "listwatch_retry_attempts_total",
-				Help:        "Total number of retry attempts",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-			}),
-			eventsByType: prometheus.NewCounterVec(
-				prometheus.CounterOpts{
-					Name:        "listwatch_events_by_type_total",
-					Help:        "Total number of events by type (add/modify/delete)",
-					ConstLabels: prometheus.Labels{"component": "listwatch"},
-				},
-				[]string{"event_type"},
-			),
-			connectionState: prometheus.NewGauge(prometheus.GaugeOpts{
-				Name:        "listwatch_connection_state",
-				Help:        "Current connection state (1=connected, 0=disconnected)",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-			}),
-			watchSessionDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
-				Name:        "listwatch_watch_session_duration_seconds",
-				Help:        "Duration of watch sessions in seconds",
-				ConstLabels: prometheus.Labels{"component": "listwatch"},
-				Buckets:     prometheus.DefBuckets,
-			}),
-			errorsByType: prometheus.NewCounterVec(
-				prometheus.CounterOpts{
-					Name:        "listwatch_errors_by_type_total",
-					Help:        "Total number of errors by type",
-					ConstLabels: prometheus.Labels{"component": "listwatch"},
-				},
-				[]string{"error_type"},
-			),
-		}
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	err := errors.Join(
+		register(registerer, &m.watchFailures),
+		register(registerer, &m.listLatency),
+		register(registerer, &m.watchLatency),
+		register(registerer, &m.eventProcessed),
+		register(registerer, &m.retryCount),
+		register(registerer, &m.eventsByType),
+		register(registerer, &m.connectionState),
+		register(registerer, &m.watchSessionDuration),
+		register(registerer, &m.errorsByType),
+		register(registerer, &m.eventsDropped),
+		register(registerer, &m.eventsSuppressed),
+		register(registerer, &m.eventsTruncated),
+		register(registerer, &m.watchResumes),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register listwatch metrics: %v", err)
+	}
 
-		// Register metrics only once
-		prometheus.MustRegister(
-			defaultMetrics.watchFailures,
-			defaultMetrics.listLatency,
-			defaultMetrics.watchLatency,
-			defaultMetrics.eventProcessed,
-			defaultMetrics.retryCount,
-			defaultMetrics.eventsByType,
-			defaultMetrics.connectionState,
-			defaultMetrics.watchSessionDuration,
-			defaultMetrics.errorsByType,
-		)
-	})
+	return m, nil
+}
+
+// register registers the collector with the registerer. If an identical collector is registered
+// already, such as by another ListWatch of the same prefix, the collector is replaced by it.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector *T) error {
+	err := registerer.Register(*collector)
+	if err == nil {
+		return nil
+	}
 
-	return defaultMetrics
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+			*collector = existing
+			return nil
+		}
+	}
+	return err
 }
@@ -0,0 +1,106 @@
+package listwatch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsServerOptions configures a MetricsServer
+type MetricsServerOptions struct {
+	// Addr is the address to listen on. Use ":0" to listen on a free port.
+	Addr string
+	// MetricsPath is the path the metrics are served on
+	MetricsPath string
+	// HealthPath is the path of the health endpoint. Empty disables the endpoint.
+	HealthPath string
+	// Registry is the registry to serve. Set it as the Registerer of the ListWatches whose
+	// metrics are served. Nil serves the default Prometheus registry.
+	Registry *prometheus.Registry
+}
+
+// DefaultMetricsServerOptions returns the default metrics server configuration
+func DefaultMetricsServerOptions() MetricsServerOptions {
+	return MetricsServerOptions{
+		Addr:        ":2112",
+		MetricsPath: "/metrics",
+		HealthPath:  "/healthz",
+	}
+}
+
+// MetricsServer serves the ListWatch metrics, including the connection state and event
+// counters, over HTTP for Prometheus to scrape
+type MetricsServer struct {
+	opts     MetricsServerOptions
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{}
+}
+
+// NewMetricsServer creates a MetricsServer
+func NewMetricsServer(opts MetricsServerOptions) (*MetricsServer, error) {
+	if opts.MetricsPath == "" {
+		return nil, fmt.Errorf("metrics path cannot be empty")
+	}
+
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if opts.Registry != nil {
+		gatherer = opts.Registry
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(opts.MetricsPath, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	if opts.HealthPath != "" {
+		mux.HandleFunc(opts.HealthPath, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok"))
+		})
+	}
+
+	return &MetricsServer{
+		opts:   opts,
+		server: &http.Server{Handler: mux},
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start listens on the configured address and serves metrics in the background
+func (s *MetricsServer) Start() error {
+	listener, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", s.opts.Addr, err)
+	}
+	s.listener = listener
+
+	go func() {
+		defer close(s.done)
+		_ = s.server.Serve(listener)
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on, or an empty string if it isn't started
+func (s *MetricsServer) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown gracefully stops the server, waiting for in-flight scrapes until the context is done
+func (s *MetricsServer) Shutdown(ctx context.Context) error {
+	if s.listener == nil {
+		return nil
+	}
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		return err
+	}
+	<-s.done
+	return nil
+}
@@ -0,0 +1,94 @@
+package listwatch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsServer(t *testing.T) {
+	get := func(t *testing.T, url string) (int, string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	startServer := func(t *testing.T, opts MetricsServerOptions) *MetricsServer {
+		server, err := NewMetricsServer(opts)
+		require.NoError(t, err)
+		require.NoError(t, server.Start())
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			assert.NoError(t, server.Shutdown(ctx))
+		})
+		return server
+	}
+
+	t.Run("serves listwatch metrics from the default registry", func(t *testing.T) {
+		opts := DefaultMetricsServerOptions()
+		opts.Addr = "127.0.0.1:0"
+		server := startServer(t, opts)
+
+		m, err := newMetrics(nil, "/test/server/default/")
+		require.NoError(t, err)
+		m.connectionState.Set(1)
+
+		status, body := get(t, fmt.Sprintf("http://%s/metrics", server.Addr()))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `listwatch_connection_state{component="listwatch",prefix="/test/server/default/"} 1`)
+		assert.Contains(t, body, "listwatch_events_processed_total")
+
+		status, body = get(t, fmt.Sprintf("http://%s/healthz", server.Addr()))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "ok", body)
+	})
+
+	t.Run("serves a custom registry on custom paths", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		server := startServer(t, MetricsServerOptions{
+			Addr:        "127.0.0.1:0",
+			MetricsPath: "/custom/metrics",
+			HealthPath:  "/custom/health",
+			Registry:    registry,
+		})
+
+		m, err := newMetrics(registry, "/test/server/custom/")
+		require.NoError(t, err)
+		m.eventsByType.WithLabelValues(string(Added)).Inc()
+
+		status, body := get(t, fmt.Sprintf("http://%s/custom/metrics", server.Addr()))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "listwatch_connection_state")
+		assert.Contains(t, body, `listwatch_events_by_type_total{component="listwatch",event_type="ADDED",prefix="/test/server/custom/"}`)
+
+		status, _ = get(t, fmt.Sprintf("http://%s/custom/health", server.Addr()))
+		assert.Equal(t, http.StatusOK, status)
+
+		status, _ = get(t, fmt.Sprintf("http://%s/metrics", server.Addr()))
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("stops serving after shutdown", func(t *testing.T) {
+		server, err := NewMetricsServer(MetricsServerOptions{Addr: "127.0.0.1:0", MetricsPath: "/metrics"})
+		require.NoError(t, err)
+		require.NoError(t, server.Start())
+		addr := server.Addr()
+
+		require.NoError(t, server.Shutdown(context.Background()))
+
+		_, err = http.Get(fmt.Sprintf("http://%s/metrics", addr))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,118 @@
+// Package lock provides short-lived locks on single objects, such as to keep two workers from
+// reconciling the same ReplicaSet, where electing a leader for the whole component would be
+// too coarse.
+//
+// A lock is a key attached to an etcd lease. It is acquired by creating the key in a
+// transaction that only succeeds if it doesn't exist, the other callers wait until it is
+// deleted. The lease is kept alive until the lock is unlocked, so a lock is held as long as
+// needed, while the lock of a holder that crashed is released once its lease expires.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/etcdclient"
+)
+
+// KeyPrefix is the prefix of the keys of the locks, followed by the locked key
+const KeyPrefix = "/locks/"
+
+// revokeTimeout bounds how long unlocking waits for etcd to revoke the lease of a lock
+const revokeTimeout = 5 * time.Second
+
+// ErrInvalidTTL is returned for a lock TTL that isn't positive
+var ErrInvalidTTL = errors.New("invalid lock TTL")
+
+// Locker acquires locks stored in etcd
+type Locker struct {
+	client *etcdclient.Client
+}
+
+// NewLocker creates a Locker storing its locks with the etcd client
+func NewLocker(client *etcdclient.Client) *Locker {
+	return &Locker{client: client}
+}
+
+// Lock waits until it holds the lock of the key, or the context is done. The lock is held
+// until the returned unlock function is called, which may be called more than once. If the
+// process stops without unlocking, the lock is released once the TTL, rounded up to whole
+// seconds, expires.
+func (l *Locker) Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTTL, ttl)
+	}
+
+	grant, err := l.client.Grant(ctx, int64((ttl+time.Second-1)/time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant the lease of lock %s: %v", key, err)
+	}
+
+	// The lease is kept alive while waiting too, the lock is created with it as soon as it is free
+	keepAliveCtx, stopKeepAlive := context.WithCancel(context.Background())
+	release := func() {
+		stopKeepAlive()
+		l.revoke(key, grant.ID)
+	}
+	keepAlive, err := l.client.KeepAlive(keepAliveCtx, grant.ID)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to keep the lease of lock %s alive: %v", key, err)
+	}
+	go func() {
+		for range keepAlive {
+		}
+	}()
+
+	lockKey := KeyPrefix + key
+	for {
+		resp, err := l.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(lockKey), "=", 0)).
+			Then(clientv3.OpPut(lockKey, "", clientv3.WithLease(grant.ID))).
+			Commit()
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("failed to acquire lock %s: %v", key, err)
+		}
+		if resp.Succeeded {
+			var once sync.Once
+			return func() { once.Do(release) }, nil
+		}
+
+		if err := l.waitForRelease(ctx, lockKey, resp.Header.Revision); err != nil {
+			release()
+			return nil, err
+		}
+	}
+}
+
+// waitForRelease waits until the lock key is deleted after the revision, or the context is done
+func (l *Locker) waitForRelease(ctx context.Context, lockKey string, revision int64) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for resp := range l.client.Watch(watchCtx, lockKey, clientv3.WithRev(revision+1), clientv3.WithFilterPut()) {
+		if err := resp.Err(); err != nil {
+			return fmt.Errorf("failed to watch lock %s: %v", lockKey, err)
+		}
+		if len(resp.Events) > 0 {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// revoke revokes the lease of the lock, deleting its key
+func (l *Locker) revoke(key string, lease clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+	defer cancel()
+	if _, err := l.client.Revoke(ctx, lease); err != nil {
+		log.Printf("Failed to revoke the lease of lock %s: %v", key, err)
+	}
+}
@@ -0,0 +1,88 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/storage"
+)
+
+func TestLocker_MutualExclusion(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		locker := NewLocker(etcdclient.Wrap(cli))
+		ctx := context.Background()
+
+		var holders, acquired atomic.Int32
+		var overlapped atomic.Bool
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 5 {
+					unlock, err := locker.Lock(ctx, "replicasets/default/web", 5*time.Second)
+					if !assert.NoError(t, err) {
+						return
+					}
+					acquired.Add(1)
+					if holders.Add(1) > 1 {
+						overlapped.Store(true)
+					}
+					time.Sleep(10 * time.Millisecond)
+					holders.Add(-1)
+					unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(10), acquired.Load())
+		assert.False(t, overlapped.Load(), "the lock was held by two goroutines at once")
+
+		// Unlocking deletes the lock key
+		resp, err := cli.Get(ctx, KeyPrefix, clientv3.WithPrefix())
+		require.NoError(t, err)
+		assert.Empty(t, resp.Kvs)
+	})
+}
+
+func TestLocker_ReleasedWhenHolderCrashes(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx := context.Background()
+
+		// The holder has its own connection, closing it stops the keepalives like a crash would
+		holderClient, err := clientv3.New(clientv3.Config{Endpoints: cli.Endpoints()})
+		require.NoError(t, err)
+		_, err = NewLocker(etcdclient.Wrap(holderClient)).Lock(ctx, "crashed", time.Second)
+		require.NoError(t, err)
+
+		locker := NewLocker(etcdclient.Wrap(cli))
+		waitCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		_, err = locker.Lock(waitCtx, "crashed", time.Second)
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "the lock is held while its holder is alive")
+
+		require.NoError(t, holderClient.Close())
+
+		start := time.Now()
+		acquireCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		unlock, err := locker.Lock(acquireCtx, "crashed", time.Second)
+		require.NoError(t, err, "the lock of the crashed holder should expire with its lease")
+		defer unlock()
+		assert.Less(t, time.Since(start), 4*time.Second)
+	})
+}
+
+func TestLocker_InvalidTTL(t *testing.T) {
+	_, err := NewLocker(nil).Lock(context.Background(), "key", 0)
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+}
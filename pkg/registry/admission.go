@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"gokube/pkg/api"
+)
+
+// operation is the kind of change an object is admitted for
+type operation string
+
+const (
+	operationCreate operation = "create"
+	operationUpdate operation = "update"
+)
+
+// mutateFunc defaults or normalizes an object being admitted. It fails if the object can't be
+// normalized, such as a pod with an image that can't be parsed.
+type mutateFunc[T any] func(obj T, op operation) error
+
+// validateFunc checks an object being admitted
+type validateFunc[T any] func(obj T, op operation) error
+
+// admission is the pipeline every created or updated object goes through before it is
+// persisted. All mutators run first, in order, so that the validators check the object as it
+// is stored rather than as it was sent.
+type admission[T any] struct {
+	mutators   []mutateFunc[T]
+	validators []validateFunc[T]
+}
+
+// admit mutates then validates the object, stopping at the first error
+func (a admission[T]) admit(obj T, op operation) error {
+	for _, mutate := range a.mutators {
+		if err := mutate(obj, op); err != nil {
+			return err
+		}
+	}
+	for _, validate := range a.validators {
+		if err := validate(obj, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultObjectMeta sets the UID and creation timestamp of a created object
+func defaultObjectMeta(meta *api.ObjectMeta, op operation) {
+	if op != operationCreate {
+		return
+	}
+	if meta.UID == "" {
+		meta.UID = uuid.NewString()
+	}
+	if meta.CreationTimestamp.IsZero() {
+		meta.CreationTimestamp = time.Now().UTC()
+	}
+}
+
+// newPodAdmission returns the admission of pods
+func newPodAdmission() admission[*api.Pod] {
+	return admission[*api.Pod]{
+		mutators: []mutateFunc[*api.Pod]{
+			func(pod *api.Pod, op operation) error {
+				pod.Namespace = api.NamespaceOrDefault(pod.Namespace)
+				defaultObjectMeta(&pod.ObjectMeta, op)
+				if op == operationCreate && pod.Status.Phase == "" {
+					pod.Status.Phase = api.PodPending
+				}
+				return nil
+			},
+			// Images are stored fully qualified, so that the kubelet pulls exactly what was validated
+			func(pod *api.Pod, _ operation) error {
+				return pod.Spec.NormalizeImages()
+			},
+		},
+		validators: []validateFunc[*api.Pod]{
+			func(pod *api.Pod, _ operation) error { return pod.Validate() },
+		},
+	}
+}
+
+// newNodeAdmission returns the admission of nodes
+func newNodeAdmission() admission[*api.Node] {
+	return admission[*api.Node]{
+		mutators: []mutateFunc[*api.Node]{
+			func(node *api.Node, op operation) error {
+				if op == operationCreate && node.CreationTimestamp.IsZero() {
+					node.CreationTimestamp = time.Now().UTC()
+				}
+				return nil
+			},
+		},
+		validators: []validateFunc[*api.Node]{
+			func(node *api.Node, _ operation) error { return node.Validate() },
+		},
+	}
+}
+
+// newReplicaSetAdmission returns the admission of ReplicaSets
+func newReplicaSetAdmission() admission[*api.ReplicaSet] {
+	return admission[*api.ReplicaSet]{
+		mutators: []mutateFunc[*api.ReplicaSet]{
+			func(rs *api.ReplicaSet, op operation) error {
+				defaultObjectMeta(&rs.ObjectMeta, op)
+				return nil
+			},
+		},
+		validators: []validateFunc[*api.ReplicaSet]{
+			func(rs *api.ReplicaSet, _ operation) error { return rs.Validate() },
+		},
+	}
+}
+
+// newDeploymentAdmission returns the admission of Deployments
+func newDeploymentAdmission() admission[*api.Deployment] {
+	return admission[*api.Deployment]{
+		mutators: []mutateFunc[*api.Deployment]{
+			func(deployment *api.Deployment, op operation) error {
+				defaultObjectMeta(&deployment.ObjectMeta, op)
+				return nil
+			},
+		},
+		validators: []validateFunc[*api.Deployment]{
+			func(deployment *api.Deployment, _ operation) error { return deployment.Validate() },
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func TestAdmission_Admit(t *testing.T) {
+	var calls []string
+	errRejected := errors.New("rejected")
+	a := admission[*api.Pod]{
+		mutators: []mutateFunc[*api.Pod]{
+			func(pod *api.Pod, op operation) error {
+				calls = append(calls, "default "+string(op))
+				pod.Status.Phase = api.PodPending
+				return nil
+			},
+		},
+		validators: []validateFunc[*api.Pod]{
+			func(pod *api.Pod, op operation) error {
+				calls = append(calls, "validate "+string(op))
+				if pod.Status.Phase != api.PodPending {
+					return errors.New("validated before defaulting")
+				}
+				return nil
+			},
+			func(pod *api.Pod, _ operation) error {
+				if pod.Name == "" {
+					return errRejected
+				}
+				return nil
+			},
+		},
+	}
+
+	require.NoError(t, a.admit(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "pod"}}, operationCreate))
+	assert.Equal(t, []string{"default create", "validate create"}, calls)
+
+	calls = nil
+	assert.ErrorIs(t, a.admit(&api.Pod{}, operationUpdate), errRejected)
+	assert.Equal(t, []string{"default update", "validate update"}, calls)
+
+	// A failed mutation stops the admission before the validation
+	calls = nil
+	a.mutators = append(a.mutators, func(*api.Pod, operation) error { return errRejected })
+	assert.ErrorIs(t, a.admit(&api.Pod{}, operationCreate), errRejected)
+	assert.Equal(t, []string{"default create"}, calls)
+}
+
+// rejectingValidator returns a validator recording the objects it is given, and rejecting them
+// once reject is set
+func rejectingValidator[T any](seen *[]T, reject *bool) validateFunc[T] {
+	return func(obj T, _ operation) error {
+		*seen = append(*seen, obj)
+		if *reject {
+			return errors.New("rejected by admission")
+		}
+		return nil
+	}
+}
+
+func TestRegistries_Admission(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		ctx := context.Background()
+
+		t.Run("pods", func(t *testing.T) {
+			registry := NewPodRegistry(store)
+			var seen []*api.Pod
+			var reject bool
+			registry.admission.validators = append(registry.admission.validators, rejectingValidator(&seen, &reject))
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "admitted"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+			}
+			require.NoError(t, registry.CreatePod(ctx, pod))
+			require.Len(t, seen, 1)
+			// The validators see the defaulted pod
+			assert.Equal(t, api.NamespaceDefault, seen[0].Namespace)
+			assert.Equal(t, api.PodPending, seen[0].Status.Phase)
+			assert.NotEmpty(t, seen[0].UID)
+			assert.False(t, seen[0].CreationTimestamp.IsZero())
+			assert.Equal(t, "docker.io/library/nginx:latest", seen[0].Spec.Containers[0].Image)
+
+			reject = true
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "admitted")
+			require.NoError(t, err)
+			stored.Labels = map[string]string{"rejected": "true"}
+			assert.ErrorIs(t, registry.UpdatePod(ctx, stored), ErrPodInvalid)
+			assert.Len(t, seen, 2)
+
+			stored, err = registry.GetPod(ctx, api.NamespaceDefault, "admitted")
+			require.NoError(t, err)
+			assert.Empty(t, stored.Labels)
+		})
+
+		t.Run("nodes", func(t *testing.T) {
+			registry := NewNodeRegistry(store)
+			var seen []*api.Node
+			var reject bool
+			registry.admission.validators = append(registry.admission.validators, rejectingValidator(&seen, &reject))
+
+			require.NoError(t, registry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "admitted"}}))
+			require.Len(t, seen, 1)
+			assert.False(t, seen[0].CreationTimestamp.IsZero())
+
+			reject = true
+			stored, err := registry.GetNode(ctx, "admitted")
+			require.NoError(t, err)
+			stored.Labels = map[string]string{"rejected": "true"}
+			assert.ErrorIs(t, registry.UpdateNode(ctx, stored), ErrNodeInvalid)
+			assert.Len(t, seen, 2)
+
+			stored, err = registry.GetNode(ctx, "admitted")
+			require.NoError(t, err)
+			assert.Empty(t, stored.Labels)
+		})
+
+		t.Run("replicasets", func(t *testing.T) {
+			registry := NewReplicaSetRegistry(store)
+			var seen []*api.ReplicaSet
+			var reject bool
+			registry.admission.validators = append(registry.admission.validators, rejectingValidator(&seen, &reject))
+
+			require.NoError(t, registry.Create(ctx, createTestReplicaSet("admitted", 1, "nginx")))
+			require.Len(t, seen, 1)
+			assert.NotEmpty(t, seen[0].UID)
+			assert.False(t, seen[0].CreationTimestamp.IsZero())
+
+			reject = true
+			stored, err := registry.Get(ctx, "admitted")
+			require.NoError(t, err)
+			stored.Spec.Replicas = 3
+			assert.ErrorIs(t, registry.Update(ctx, stored), ErrReplicaSetInvalid)
+			assert.Len(t, seen, 2)
+
+			stored, err = registry.Get(ctx, "admitted")
+			require.NoError(t, err)
+			assert.Equal(t, int32(1), stored.Spec.Replicas)
+		})
+	})
+}
@@ -0,0 +1,203 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gokube/pkg/api"
+	"gokube/pkg/runtime"
+	"gokube/pkg/storage"
+)
+
+const (
+	deploymentPrefix = "/deployments"
+)
+
+var (
+	ErrDeploymentExists   = errors.New("deployment already exists")
+	ErrDeploymentNotFound = fmt.Errorf("deployment %w", ErrNotFound)
+	ErrListDeployments    = errors.New("error listing deployments")
+	ErrDeploymentInvalid  = errors.New("invalid deployment")
+	ErrDeploymentConflict = fmt.Errorf("deployment %w", ErrConflict)
+)
+
+// DeploymentRegistry stores Deployments
+type DeploymentRegistry struct {
+	storage   storage.Storage
+	mutex     sync.RWMutex
+	admission admission[*api.Deployment]
+}
+
+// NewDeploymentRegistry creates a DeploymentRegistry on the storage
+func NewDeploymentRegistry(storage storage.Storage) *DeploymentRegistry {
+	return &DeploymentRegistry{
+		storage:   storage,
+		admission: newDeploymentAdmission(),
+	}
+}
+
+func (r *DeploymentRegistry) generateKey(name string) string {
+	return fmt.Sprintf("%s/%s", deploymentPrefix, name)
+}
+
+// Create stores a new Deployment
+func (r *DeploymentRegistry) Create(ctx context.Context, deployment *api.Deployment) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.admission.admit(deployment, operationCreate); err != nil {
+		return fmt.Errorf("%w: %v", ErrDeploymentInvalid, err)
+	}
+
+	key := r.generateKey(deployment.Name)
+
+	existing := &api.Deployment{}
+	if err := r.storage.Get(ctx, key, existing); err == nil {
+		return fmt.Errorf("%w: %s", ErrDeploymentExists, deployment.Name)
+	}
+
+	return r.storage.Create(ctx, key, deployment)
+}
+
+// Get retrieves a Deployment by name
+func (r *DeploymentRegistry) Get(ctx context.Context, name string) (*api.Deployment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	key := r.generateKey(name)
+	deployment := &api.Deployment{}
+	if err := r.storage.Get(ctx, key, deployment); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrDeploymentNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get deployment: %v", ErrInternal, err)
+		}
+	}
+
+	return deployment, nil
+}
+
+// Update replaces a Deployment, whatever the resource version of the stored Deployment
+func (r *DeploymentRegistry) Update(ctx context.Context, deployment *api.Deployment) error {
+	return r.update(ctx, deployment, 0)
+}
+
+// UpdateAtRevision is Update that only replaces the Deployment if it is still at the resource
+// version, ErrDeploymentConflict is returned if it was modified since
+func (r *DeploymentRegistry) UpdateAtRevision(ctx context.Context, deployment *api.Deployment, revision int64) error {
+	return r.update(ctx, deployment, revision)
+}
+
+// UpdateStatus replaces the status of the stored Deployment, leaving the rest of it as stored, so
+// that a status write never reverts a concurrent change of the spec. It returns the updated
+// Deployment.
+func (r *DeploymentRegistry) UpdateStatus(ctx context.Context, name string, status api.DeploymentStatus) (*api.Deployment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	updated := &api.Deployment{}
+	err := r.storage.GuaranteedUpdate(ctx, r.generateKey(name), updated, func(obj runtime.Object) error {
+		obj.(*api.Deployment).Status = status
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrDeploymentNotFound, name)
+		}
+		return nil, fmt.Errorf("%w: failed to update deployment status: %v", ErrInternal, err)
+	}
+	return updated, nil
+}
+
+// update replaces the Deployment, only if it is at revision unless revision is 0
+func (r *DeploymentRegistry) update(ctx context.Context, deployment *api.Deployment, revision int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.admission.admit(deployment, operationUpdate); err != nil {
+		return fmt.Errorf("%w: %v", ErrDeploymentInvalid, err)
+	}
+
+	key := r.generateKey(deployment.Name)
+
+	existing := &api.Deployment{}
+	if err := r.storage.Get(ctx, key, existing); err != nil {
+		return fmt.Errorf("%w: %s", ErrDeploymentNotFound, deployment.Name)
+	}
+
+	var err error
+	if revision != 0 {
+		err = r.storage.UpdateAtRevision(ctx, key, deployment, revision)
+	} else {
+		err = r.storage.Update(ctx, key, deployment)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return fmt.Errorf("%w: %v", ErrDeploymentConflict, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a Deployment by name. Its ReplicaSets are left as they are.
+func (r *DeploymentRegistry) Delete(ctx context.Context, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := r.generateKey(name)
+	return r.storage.Delete(ctx, key)
+}
+
+// DeleteAtRevision removes the Deployment if it is still at the resource version,
+// ErrDeploymentConflict is returned if it was modified since
+func (r *DeploymentRegistry) DeleteAtRevision(ctx context.Context, name string, revision int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := r.generateKey(name)
+	return deleteAtRevisionError(r.storage.DeleteAtRevision(ctx, key, revision), ErrDeploymentNotFound, ErrDeploymentConflict)
+}
+
+// List retrieves all Deployments
+func (r *DeploymentRegistry) List(ctx context.Context) ([]*api.Deployment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	deployments := make([]*api.Deployment, 0)
+	if err := r.storage.List(ctx, deploymentPrefix+"/", &deployments); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListDeployments, err)
+	}
+
+	return deployments, nil
+}
+
+// ListWithRevision lists all Deployments like List, and also returns the revision they were
+// listed at. Watching from that revision misses no change.
+func (r *DeploymentRegistry) ListWithRevision(ctx context.Context) ([]*api.Deployment, int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	deployments := make([]*api.Deployment, 0)
+	_, revision, err := r.storage.ListWithMeta(ctx, deploymentPrefix+"/", &deployments)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListDeployments, err)
+	}
+
+	return deployments, revision, nil
+}
+
+// CurrentRevision returns the latest resource version, watching Deployments from it misses no
+// later change
+func (r *DeploymentRegistry) CurrentRevision(ctx context.Context) (int64, error) {
+	return r.storage.CurrentRevision(ctx)
+}
+
+// Watch streams changes to Deployments made after the given resource version.
+// A resource version of 0 watches for changes from now on.
+func (r *DeploymentRegistry) Watch(ctx context.Context, resourceVersion int64) (<-chan storage.WatchEvent, error) {
+	return r.storage.WatchFromRevision(ctx, deploymentPrefix+"/", resourceVersion, storage.DefaultWatchOptions())
+}
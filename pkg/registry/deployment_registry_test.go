@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func createTestDeployment(name string, replicas int32, image string) *api.Deployment {
+	return &api.Deployment{
+		ObjectMeta: api.ObjectMeta{
+			Name: name,
+		},
+		Spec: api.DeploymentSpec{
+			Replicas: replicas,
+			Selector: map[string]string{"app": "test"},
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "test"}},
+				Spec: api.PodSpec{
+					Containers: []api.Container{
+						{
+							Name:  "test-container",
+							Image: image,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDeploymentRegistry(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		ctx := context.Background()
+		registry := NewDeploymentRegistry(storage.NewEtcdStorage(etcdServer))
+
+		t.Run("should create a Deployment", func(t *testing.T) {
+			deployment := createTestDeployment("web", 3, "nginx:latest")
+			require.NoError(t, registry.Create(ctx, deployment))
+			assert.NotEmpty(t, deployment.UID)
+			assert.False(t, deployment.CreationTimestamp.IsZero())
+
+			err := registry.Create(ctx, createTestDeployment("web", 3, "nginx:latest"))
+			assert.ErrorIs(t, err, ErrDeploymentExists)
+		})
+
+		t.Run("should reject an invalid Deployment", func(t *testing.T) {
+			deployment := createTestDeployment("mismatched", 3, "nginx:latest")
+			deployment.Spec.Selector = map[string]string{"app": "other"}
+			assert.ErrorIs(t, registry.Create(ctx, deployment), ErrDeploymentInvalid)
+
+			deployment = createTestDeployment("negative", 3, "nginx:latest")
+			deployment.Spec.Strategy.MaxSurge = -1
+			assert.ErrorIs(t, registry.Create(ctx, deployment), ErrDeploymentInvalid)
+		})
+
+		t.Run("should update a Deployment at its resource version", func(t *testing.T) {
+			deployment, err := registry.Get(ctx, "web")
+			require.NoError(t, err)
+			stale := *deployment
+			staleRevision, err := strconv.ParseInt(stale.ResourceVersion, 10, 64)
+			require.NoError(t, err)
+
+			deployment.Spec.Template.Spec.Containers[0].Image = "nginx:1.19"
+			require.NoError(t, registry.UpdateAtRevision(ctx, deployment, staleRevision))
+
+			stale.Spec.Replicas = 5
+			assert.ErrorIs(t, registry.UpdateAtRevision(ctx, &stale, staleRevision), ErrDeploymentConflict)
+
+			deployments, err := registry.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, deployments, 1)
+			assert.Equal(t, "nginx:1.19", deployments[0].Spec.Template.Spec.Containers[0].Image)
+		})
+
+		t.Run("should update the status of a Deployment alone", func(t *testing.T) {
+			stale, err := registry.Get(ctx, "web")
+			require.NoError(t, err)
+			current := *stale
+			current.Spec.Replicas = 7
+			require.NoError(t, registry.Update(ctx, &current))
+
+			updated, err := registry.UpdateStatus(ctx, "web", api.DeploymentStatus{Replicas: 2, AvailableReplicas: 1})
+			require.NoError(t, err)
+			assert.Equal(t, int32(7), updated.Spec.Replicas)
+			assert.Equal(t, api.DeploymentStatus{Replicas: 2, AvailableReplicas: 1}, updated.Status)
+
+			_, err = registry.UpdateStatus(ctx, "missing", api.DeploymentStatus{})
+			assert.ErrorIs(t, err, ErrDeploymentNotFound)
+		})
+
+		t.Run("should delete a Deployment", func(t *testing.T) {
+			require.NoError(t, registry.Delete(ctx, "web"))
+			_, err := registry.Get(ctx, "web")
+			assert.ErrorIs(t, err, ErrDeploymentNotFound)
+		})
+	})
+}
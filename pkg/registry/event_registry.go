@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry/names"
+	"gokube/pkg/storage"
+)
+
+const (
+	eventPrefix = "/events"
+)
+
+var (
+	ErrEventInvalid       = errors.New("invalid event")
+	ErrEventAlreadyExists = errors.New("event already exists")
+	ErrListEvents         = errors.New("error listing events")
+)
+
+// EventOptions configures how long events are retained
+type EventOptions struct {
+	// TTL is how long an event is kept before it expires
+	TTL time.Duration
+	// MaxEventsPerObject caps the events kept for one object, the oldest are removed first.
+	// Zero means no cap.
+	MaxEventsPerObject int
+}
+
+// DefaultEventOptions returns the default event retention
+func DefaultEventOptions() EventOptions {
+	return EventOptions{
+		TTL:                time.Hour,
+		MaxEventsPerObject: 100,
+	}
+}
+
+// EventRegistry records events, which expire after the configured TTL so they don't
+// accumulate in storage
+type EventRegistry struct {
+	storage storage.Storage
+	opts    EventOptions
+	mutex   sync.Mutex
+}
+
+// NewEventRegistry creates an EventRegistry with the default retention
+func NewEventRegistry(storage storage.Storage) *EventRegistry {
+	return NewEventRegistryWithOptions(storage, DefaultEventOptions())
+}
+
+// NewEventRegistryWithOptions creates an EventRegistry with the given retention
+func NewEventRegistryWithOptions(storage storage.Storage, opts EventOptions) *EventRegistry {
+	return &EventRegistry{
+		storage: storage,
+		opts:    opts,
+	}
+}
+
+func (r *EventRegistry) objectPrefix(ref api.ObjectReference) string {
+	return fmt.Sprintf("%s/%s/%s/", eventPrefix, ref.Kind, ref.Name)
+}
+
+func (r *EventRegistry) generateKey(event *api.Event) string {
+	return r.objectPrefix(event.InvolvedObject) + event.Name
+}
+
+// Record stores the event, which expires after the TTL, and removes the oldest events of the
+// involved object beyond MaxEventsPerObject
+func (r *EventRegistry) Record(ctx context.Context, event *api.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrEventInvalid, err)
+	}
+
+	if event.Name == "" {
+		event.Name = names.SimpleNameGenerator.GenerateName(event.InvolvedObject.Name + ".")
+	}
+	if event.CreationTimestamp.IsZero() {
+		event.CreationTimestamp = time.Now().UTC()
+	}
+
+	err := r.storage.CreateWithTTL(ctx, r.generateKey(event), event, r.opts.TTL)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		return fmt.Errorf("%w: %s", ErrEventAlreadyExists, event.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to record event: %v", ErrInternal, err)
+	}
+
+	return r.trim(ctx, event.InvolvedObject)
+}
+
+// trim removes the oldest events of the object beyond MaxEventsPerObject
+func (r *EventRegistry) trim(ctx context.Context, ref api.ObjectReference) error {
+	if r.opts.MaxEventsPerObject <= 0 {
+		return nil
+	}
+
+	events, err := r.ListFor(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	for len(events) > r.opts.MaxEventsPerObject {
+		oldest := events[0]
+		events = events[1:]
+		if err := r.storage.Delete(ctx, r.generateKey(oldest)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: failed to trim event %s: %v", ErrInternal, oldest.Name, err)
+		}
+		log.Printf("Trimmed event %s of %s %s", oldest.Name, ref.Kind, ref.Name)
+	}
+	return nil
+}
+
+// ListFor returns the events of the object, oldest first
+func (r *EventRegistry) ListFor(ctx context.Context, ref api.ObjectReference) ([]*api.Event, error) {
+	return r.list(ctx, r.objectPrefix(ref))
+}
+
+// List returns all events, oldest first
+func (r *EventRegistry) List(ctx context.Context) ([]*api.Event, error) {
+	return r.list(ctx, eventPrefix+"/")
+}
+
+func (r *EventRegistry) list(ctx context.Context, prefix string) ([]*api.Event, error) {
+	var events []*api.Event
+	if err := r.storage.List(ctx, prefix, &events); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListEvents, err)
+	}
+
+	slices.SortStableFunc(events, func(a, b *api.Event) int {
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return events, nil
+}
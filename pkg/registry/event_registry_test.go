@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func createTestEvent(podName, reason string) *api.Event {
+	return &api.Event{
+		InvolvedObject: api.ObjectReference{Kind: "Pod", Name: podName},
+		Type:           api.EventTypeNormal,
+		Reason:         reason,
+	}
+}
+
+func TestEventRegistry_Record(t *testing.T) {
+	t.Run("should expire events after the TTL", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			ctx := context.Background()
+			registry := NewEventRegistryWithOptions(storage.NewEtcdStorage(etcdServer), EventOptions{TTL: time.Second})
+
+			require.NoError(t, registry.Record(ctx, createTestEvent("pod-1", "Scheduled")))
+
+			events, err := registry.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "Scheduled", events[0].Reason)
+
+			assert.Eventually(t, func() bool {
+				events, err := registry.List(ctx)
+				return err == nil && len(events) == 0
+			}, 10*time.Second, 100*time.Millisecond, "event should expire after its TTL")
+		})
+	})
+
+	t.Run("should trim the oldest events beyond the per-object cap", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			ctx := context.Background()
+			registry := NewEventRegistryWithOptions(storage.NewEtcdStorage(etcdServer), EventOptions{
+				TTL:                time.Hour,
+				MaxEventsPerObject: 3,
+			})
+
+			start := time.Now().UTC()
+			for i := 0; i < 5; i++ {
+				event := createTestEvent("pod-1", fmt.Sprintf("Reason%d", i))
+				event.CreationTimestamp = start.Add(time.Duration(i) * time.Second)
+				require.NoError(t, registry.Record(ctx, event))
+			}
+			require.NoError(t, registry.Record(ctx, createTestEvent("pod-2", "Other")))
+
+			events, err := registry.ListFor(ctx, api.ObjectReference{Kind: "Pod", Name: "pod-1"})
+			require.NoError(t, err)
+			var reasons []string
+			for _, event := range events {
+				reasons = append(reasons, event.Reason)
+			}
+			assert.Equal(t, []string{"Reason2", "Reason3", "Reason4"}, reasons)
+
+			others, err := registry.ListFor(ctx, api.ObjectReference{Kind: "Pod", Name: "pod-2"})
+			require.NoError(t, err)
+			assert.Len(t, others, 1)
+		})
+	})
+
+	t.Run("should not overwrite an event of the same name", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			ctx := context.Background()
+			registry := NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+
+			first := createTestEvent("pod-1", "Scheduled")
+			require.NoError(t, registry.Record(ctx, first))
+
+			duplicate := createTestEvent("pod-1", "Killing")
+			duplicate.Name = first.Name
+			assert.ErrorIs(t, registry.Record(ctx, duplicate), ErrEventAlreadyExists)
+
+			events, err := registry.ListFor(ctx, first.InvolvedObject)
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "Scheduled", events[0].Reason)
+		})
+	})
+
+	t.Run("should reject events without an involved object", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			registry := NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+
+			err := registry.Record(context.Background(), &api.Event{Reason: "Scheduled"})
+			assert.ErrorIs(t, err, ErrEventInvalid)
+		})
+	})
+}
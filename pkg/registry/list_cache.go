@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"sync"
+	"time"
+
+	"gokube/pkg/runtime"
+)
+
+// listCache keeps the result of a full list for a short TTL, so that repeated lists within the
+// TTL are served from memory. The list is kept encoded and decoded on every hit, so callers
+// never share objects. Writes through the registry invalidate it; writes made elsewhere are
+// seen once the TTL expires.
+type listCache[T any] struct {
+	ttl   time.Duration
+	now   func() time.Time
+	mutex sync.Mutex
+	data  []byte
+	// expires is when data stops being served, it is zero when nothing is cached
+	expires time.Time
+	// generation is incremented on every invalidation, a list that started before an
+	// invalidation must not be cached
+	generation uint64
+}
+
+// newListCache creates a listCache, or returns nil when ttl disables caching
+func newListCache[T any](ttl time.Duration) *listCache[T] {
+	if ttl <= 0 {
+		return nil
+	}
+	return &listCache[T]{ttl: ttl, now: time.Now}
+}
+
+// list returns the cached list, or calls list and caches its result
+func (c *listCache[T]) list(list func() ([]T, error)) ([]T, error) {
+	if c == nil {
+		return list()
+	}
+
+	c.mutex.Lock()
+	if !c.expires.IsZero() && c.now().Before(c.expires) {
+		data := c.data
+		c.mutex.Unlock()
+		var items []T
+		if err := runtime.Decode(data, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	generation := c.generation
+	c.mutex.Unlock()
+
+	items, err := list()
+	if err != nil {
+		return nil, err
+	}
+	data, err := runtime.Encode(items)
+	if err != nil {
+		return items, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation == generation {
+		c.data = data
+		c.expires = c.now().Add(c.ttl)
+	}
+	return items, nil
+}
+
+// invalidate drops the cached list, it is called after every write
+func (c *listCache[T]) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.data = nil
+	c.expires = time.Time{}
+}
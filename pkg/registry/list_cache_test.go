@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// countingStorage counts the lists made against the storage
+type countingStorage struct {
+	storage.Storage
+	lists atomic.Int64
+}
+
+func (s *countingStorage) List(ctx context.Context, prefix string, listObj interface{}) error {
+	s.lists.Add(1)
+	return s.Storage.List(ctx, prefix, listObj)
+}
+
+func (s *countingStorage) ListWithMeta(ctx context.Context, prefix string, listObj interface{}) ([]storage.ItemMeta, int64, error) {
+	s.lists.Add(1)
+	return s.Storage.ListWithMeta(ctx, prefix, listObj)
+}
+
+func TestPodRegistry_ListCache(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := &countingStorage{Storage: storage.NewEtcdStorage(etcdServer)}
+		podRegistry := NewPodRegistryWithOptions(store, PodRegistryOptions{ListCacheTTL: time.Hour})
+		ctx := context.Background()
+
+		newPod := func(name string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx"}}},
+			}
+		}
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-1")))
+
+		for i := 0; i < 100; i++ {
+			pods, err := podRegistry.ListUnassignedPods(ctx)
+			require.NoError(t, err)
+			require.Len(t, pods, 1)
+		}
+		assert.Equal(t, int64(1), store.lists.Load(), "repeated lists within the TTL should be served from memory")
+
+		// Callers get their own copies of the cached pods
+		pods, err := podRegistry.ListPods(ctx)
+		require.NoError(t, err)
+		pods[0].Labels = map[string]string{"changed": "true"}
+		pods, err = podRegistry.ListPods(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, pods[0].Labels)
+
+		// Writes through the registry are reflected right away
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod-2")))
+		_, err = podRegistry.BindPod(ctx, api.NamespaceDefault, "pod-1", "node-1")
+		require.NoError(t, err)
+		pods, err = podRegistry.ListPods(ctx)
+		require.NoError(t, err)
+		require.Len(t, pods, 2)
+		assert.Equal(t, "node-1", pods[0].NodeName)
+		assert.Equal(t, int64(2), store.lists.Load())
+
+		require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, "pod-2"))
+		pods, err = podRegistry.ListPods(ctx)
+		require.NoError(t, err)
+		assert.Len(t, pods, 1)
+	})
+}
+
+func TestListCache_Expires(t *testing.T) {
+	cache := newListCache[string](time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	list := func() ([]string, error) {
+		calls++
+		return []string{fmt.Sprintf("list-%d", calls)}, nil
+	}
+
+	items, err := cache.list(list)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list-1"}, items)
+
+	now = now.Add(30 * time.Second)
+	items, err = cache.list(list)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list-1"}, items)
+
+	now = now.Add(time.Minute)
+	items, err = cache.list(list)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list-2"}, items)
+
+	assert.Nil(t, newListCache[string](0), "a zero TTL disables the cache")
+}
@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gokube/pkg/api"
+)
+
+// ListOrder is the order objects are listed in
+type ListOrder string
+
+const (
+	// OrderByName lists objects by namespace and name, the order they are stored in
+	OrderByName ListOrder = "name"
+	// OrderByNameDesc lists objects by namespace and name, in reverse
+	OrderByNameDesc ListOrder = "-name"
+	// OrderByCreationTimestamp lists the oldest objects first
+	OrderByCreationTimestamp ListOrder = "creationTimestamp"
+	// OrderByCreationTimestampDesc lists the newest objects first
+	OrderByCreationTimestampDesc ListOrder = "-creationTimestamp"
+)
+
+// ErrInvalidListOrder is returned for a list order that isn't one of the ListOrder constants
+var ErrInvalidListOrder = errors.New("invalid list order")
+
+var listOrders = []ListOrder{OrderByName, OrderByNameDesc, OrderByCreationTimestamp, OrderByCreationTimestampDesc}
+
+// ParseListOrder parses a list order, an empty value orders by name
+func ParseListOrder(value string) (ListOrder, error) {
+	if value == "" {
+		return OrderByName, nil
+	}
+	if order := ListOrder(value); slices.Contains(listOrders, order) {
+		return order, nil
+	}
+
+	valid := make([]string, len(listOrders))
+	for i, order := range listOrders {
+		valid[i] = string(order)
+	}
+	return "", fmt.Errorf("%w %q, must be one of %s", ErrInvalidListOrder, value, strings.Join(valid, ", "))
+}
+
+// SortByOrder sorts objects with metadata, such as Pods or Nodes, in the order. Objects created
+// at the same time are ordered by namespace and name, so that the order is deterministic.
+func SortByOrder[T interface{ GetObjectMeta() *api.ObjectMeta }](objects []T, order ListOrder) {
+	byName := func(a, b *api.ObjectMeta) int {
+		if c := strings.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	}
+
+	slices.SortFunc(objects, func(x, y T) int {
+		a, b := x.GetObjectMeta(), y.GetObjectMeta()
+		switch order {
+		case OrderByNameDesc:
+			return byName(b, a)
+		case OrderByCreationTimestamp:
+			if c := a.CreationTimestamp.Compare(b.CreationTimestamp); c != 0 {
+				return c
+			}
+		case OrderByCreationTimestampDesc:
+			if c := b.CreationTimestamp.Compare(a.CreationTimestamp); c != 0 {
+				return c
+			}
+		}
+		return byName(a, b)
+	})
+}
@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+)
+
+func TestParseListOrder(t *testing.T) {
+	order, err := ParseListOrder("")
+	require.NoError(t, err)
+	assert.Equal(t, OrderByName, order)
+
+	order, err = ParseListOrder("-creationTimestamp")
+	require.NoError(t, err)
+	assert.Equal(t, OrderByCreationTimestampDesc, order)
+
+	_, err = ParseListOrder("age")
+	assert.ErrorIs(t, err, ErrInvalidListOrder)
+}
+
+func TestSortByOrder(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pod := func(namespace, name string, age time.Duration) *api.Pod {
+		return &api.Pod{ObjectMeta: api.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: created.Add(-age)}}
+	}
+
+	tests := []struct {
+		order    ListOrder
+		expected []string
+	}{
+		{OrderByName, []string{"a/old", "a/young", "b/new", "b/twin"}},
+		{OrderByNameDesc, []string{"b/twin", "b/new", "a/young", "a/old"}},
+		// Pods created at the same time are ordered by name either way
+		{OrderByCreationTimestamp, []string{"a/old", "a/young", "b/new", "b/twin"}},
+		{OrderByCreationTimestampDesc, []string{"b/new", "b/twin", "a/young", "a/old"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			pods := []*api.Pod{
+				pod("b", "twin", 0),
+				pod("a", "young", time.Minute),
+				pod("b", "new", 0),
+				pod("a", "old", time.Hour),
+			}
+			SortByOrder(pods, tt.order)
+
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Namespace+"/"+pod.Name)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"reflect"
 
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 )
 
@@ -15,20 +18,23 @@ const (
 )
 
 var (
-	ErrNodeNotFound      = errors.New("node not found")
+	ErrNodeNotFound      = fmt.Errorf("node %w", ErrNotFound)
 	ErrNodeAlreadyExists = errors.New("node already exists")
 	ErrListNodesFailed   = errors.New("failed to list nodes")
 	ErrNodeInvalid       = errors.New("invalid node")
+	ErrNodeSpecChanged   = errors.New("node spec cannot be changed by a status update")
+	ErrNodeConflict      = fmt.Errorf("node update %w", ErrConflict)
 )
 
 // NodeRegistry provides CRUD operations for Node objects
 type NodeRegistry struct {
-	storage storage.Storage
+	storage   storage.Storage
+	admission admission[*api.Node]
 }
 
 // NewNodeRegistry creates a new NodeRegistry
 func NewNodeRegistry(storage storage.Storage) *NodeRegistry {
-	return &NodeRegistry{storage: storage}
+	return &NodeRegistry{storage: storage, admission: newNodeAdmission()}
 }
 
 // generateKey generates the storage key for a given node name
@@ -45,8 +51,7 @@ func (r *NodeRegistry) CreateNode(ctx context.Context, node *api.Node) error {
 		return fmt.Errorf("%w: %s", ErrNodeAlreadyExists, node.Name)
 	}
 
-	// Validate Node spec
-	if err := node.Validate(); err != nil {
+	if err := r.admission.admit(node, operationCreate); err != nil {
 		return fmt.Errorf("%w: %v", ErrNodeInvalid, err)
 	}
 
@@ -70,16 +75,96 @@ func (r *NodeRegistry) GetNode(ctx context.Context, name string) (*api.Node, err
 	return node, nil
 }
 
-// UpdateNode updates an existing Node
+// UpdateNode updates an existing Node, whatever the resource version of the stored Node
 func (r *NodeRegistry) UpdateNode(ctx context.Context, node *api.Node) error {
+	return r.update(ctx, node, 0)
+}
+
+// UpdateNodeAtRevision is UpdateNode that only updates the Node if it is still at the resource
+// version, ErrNodeConflict is returned if it was modified since
+func (r *NodeRegistry) UpdateNodeAtRevision(ctx context.Context, node *api.Node, revision int64) error {
+	return r.update(ctx, node, revision)
+}
+
+// update updates the Node, only if it is at revision unless revision is 0
+func (r *NodeRegistry) update(ctx context.Context, node *api.Node, revision int64) error {
 	key := generateKey(nodePrefix, node.Name)
 
-	// Validate Node spec
-	if err := node.Validate(); err != nil {
+	if err := r.admission.admit(node, operationUpdate); err != nil {
 		return fmt.Errorf("%w: %v", ErrNodeInvalid, err)
 	}
 
-	return r.storage.Update(ctx, key, node)
+	var err error
+	if revision != 0 {
+		err = r.storage.UpdateAtRevision(ctx, key, node, revision)
+	} else {
+		err = r.storage.Update(ctx, key, node)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return fmt.Errorf("%w: %v", ErrNodeConflict, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ApplyNode stores the node whether or not it exists already, in a single write, and reports
+// whether it was created. The node replaces the stored node as given, including its status,
+// whatever its resource version, so it suits callers that own the whole node. The creation
+// timestamp of a stored node is kept, a new node is given one.
+func (r *NodeRegistry) ApplyNode(ctx context.Context, node *api.Node) (bool, error) {
+	key := generateKey(nodePrefix, node.Name)
+
+	if err := r.admission.admit(node, operationCreate); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrNodeInvalid, err)
+	}
+
+	created, err := r.storage.CreateOrUpdate(ctx, key, node, func(stored runtime.Object) {
+		node.CreationTimestamp = stored.(*api.Node).CreationTimestamp
+	})
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to apply node: %v", ErrInternal, err)
+	}
+	return created, nil
+}
+
+// UpdateNodeStatus updates the status of the stored node from the given node and sets the
+// conditions it carries, keeping conditions of other types. Capacity and allocatable resources are
+// replaced when reported. The spec, such as a node cordoned in the meantime, is left as stored.
+func (r *NodeRegistry) UpdateNodeStatus(ctx context.Context, node *api.Node) (*api.Node, error) {
+	key := generateKey(nodePrefix, node.Name)
+	updated := &api.Node{}
+	err := r.storage.GuaranteedUpdate(ctx, key, updated, func(obj runtime.Object) error {
+		current := obj.(*api.Node)
+		if !reflect.DeepEqual(node.Spec, api.NodeSpec{}) && !reflect.DeepEqual(node.Spec, current.Spec) {
+			return fmt.Errorf("%w: %s", ErrNodeSpecChanged, node.Name)
+		}
+
+		current.Status = node.Status
+		for _, condition := range node.Conditions {
+			conditions.SetCondition(&current.Conditions, condition)
+		}
+		if node.Capacity != nil {
+			current.Capacity = node.Capacity
+		}
+		if node.Allocatable != nil {
+			current.Allocatable = node.Allocatable
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNodeSpecChanged):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, node.Name)
+		default:
+			return nil, fmt.Errorf("%w: failed to update node status: %v", ErrInternal, err)
+		}
+	}
+
+	return updated, nil
 }
 
 // DeleteNode removes a Node by name
@@ -88,6 +173,13 @@ func (r *NodeRegistry) DeleteNode(ctx context.Context, name string) error {
 	return r.storage.Delete(ctx, key)
 }
 
+// DeleteNodeAtRevision removes a Node by name if it is still at the resource version,
+// ErrNodeConflict is returned if it was modified since
+func (r *NodeRegistry) DeleteNodeAtRevision(ctx context.Context, name string, revision int64) error {
+	key := generateKey(nodePrefix, name)
+	return deleteAtRevisionError(r.storage.DeleteAtRevision(ctx, key, revision), ErrNodeNotFound, ErrNodeConflict)
+}
+
 // ListNodes retrieves all Nodes
 func (r *NodeRegistry) ListNodes(ctx context.Context) ([]*api.Node, error) {
 	nodes := make([]*api.Node, 0)
@@ -98,3 +190,51 @@ func (r *NodeRegistry) ListNodes(ctx context.Context) ([]*api.Node, error) {
 
 	return nodes, nil
 }
+
+// ListNodesOrdered retrieves all Nodes sorted in the given order
+func (r *NodeRegistry) ListNodesOrdered(ctx context.Context, order ListOrder) ([]*api.Node, error) {
+	nodes, err := r.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	SortByOrder(nodes, order)
+	return nodes, nil
+}
+
+// ListNodesPaged retrieves at most limit Nodes, continuing the listing the continue token was
+// returned for. It also returns the token that continues the listing, which is empty once all
+// Nodes were listed.
+func (r *NodeRegistry) ListNodesPaged(ctx context.Context, limit int64, continueToken string) ([]*api.Node, string, error) {
+	var nodes []*api.Node
+	next, err := r.storage.ListPaged(ctx, nodePrefix, limit, continueToken, &nodes)
+	if err != nil {
+		return nil, "", listPagedError(err, ErrListNodesFailed)
+	}
+
+	return nodes, next, nil
+}
+
+// ListNodesWithRevision lists all Nodes like ListNodes, and also returns the revision they
+// were listed at. Watching from that revision misses no change.
+func (r *NodeRegistry) ListNodesWithRevision(ctx context.Context) ([]*api.Node, int64, error) {
+	nodes := make([]*api.Node, 0)
+	_, revision, err := r.storage.ListWithMeta(ctx, nodePrefix, &nodes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListNodesFailed, err)
+	}
+
+	return nodes, revision, nil
+}
+
+// CurrentRevision returns the latest resource version, watching Nodes from it misses no
+// later change
+func (r *NodeRegistry) CurrentRevision(ctx context.Context) (int64, error) {
+	return r.storage.CurrentRevision(ctx)
+}
+
+// WatchNodes streams changes to Nodes made after the given resource version.
+// A resource version of 0 watches for changes from now on.
+func (r *NodeRegistry) WatchNodes(ctx context.Context, resourceVersion int64) (<-chan storage.WatchEvent, error) {
+	return r.storage.WatchFromRevision(ctx, nodePrefix, resourceVersion, storage.DefaultWatchOptions())
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -150,6 +151,190 @@ func TestNodeRegistry_UpdateNode(t *testing.T) {
 			assert.ErrorIs(t, err, ErrNodeInvalid)
 		})
 	})
+
+	t.Run("should return a conflict for a stale resource version", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			nodeRegistry := NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+			createTestNodeInRegistry(t, nodeRegistry, "test-node-3", "789")
+
+			stale, err := nodeRegistry.GetNode(ctx, "test-node-3")
+			require.NoError(t, err)
+			_, err = nodeRegistry.UpdateNodeStatus(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "test-node-3"}, Status: api.NodeNotReady})
+			require.NoError(t, err)
+
+			revision, err := storage.ResourceVersionOf(stale)
+			require.NoError(t, err)
+			stale.Spec.Unschedulable = true
+			err = nodeRegistry.UpdateNodeAtRevision(ctx, stale, revision)
+			assert.ErrorIs(t, err, ErrNodeConflict)
+			assert.ErrorIs(t, err, ErrConflict)
+
+			stored, err := nodeRegistry.GetNode(ctx, "test-node-3")
+			require.NoError(t, err)
+			assert.False(t, stored.Spec.Unschedulable)
+			assert.Equal(t, api.NodeNotReady, stored.Status)
+		})
+	})
+
+	t.Run("should update whatever the resource version", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			nodeRegistry := NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+			createTestNodeInRegistry(t, nodeRegistry, "test-node-3", "789")
+
+			stale, err := nodeRegistry.GetNode(ctx, "test-node-3")
+			require.NoError(t, err)
+			_, err = nodeRegistry.UpdateNodeStatus(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "test-node-3"}, Status: api.NodeNotReady})
+			require.NoError(t, err)
+
+			stale.Spec.Unschedulable = true
+			require.NoError(t, nodeRegistry.UpdateNode(ctx, stale))
+
+			stored, err := nodeRegistry.GetNode(ctx, "test-node-3")
+			require.NoError(t, err)
+			assert.True(t, stored.Spec.Unschedulable)
+		})
+	})
+
+	t.Run("should keep both a concurrent cordon and heartbeats", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			nodeRegistry := NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+			createTestNodeInRegistry(t, nodeRegistry, "test-node-3", "789")
+
+			const heartbeats = 20
+			heartbeatsDone := make(chan error, 1)
+			go func() {
+				for i := 0; i < heartbeats; i++ {
+					_, err := nodeRegistry.UpdateNodeStatus(ctx, &api.Node{
+						ObjectMeta: api.ObjectMeta{Name: "test-node-3"},
+						Status:     api.NodeReady,
+						Capacity:   api.ResourceList{api.ResourceCPU: int64(i)},
+					})
+					if err != nil {
+						heartbeatsDone <- err
+						return
+					}
+				}
+				heartbeatsDone <- nil
+			}()
+
+			// The cordon retries from a fresh read until it doesn't conflict with a heartbeat
+			conflicts := 0
+			for {
+				node, err := nodeRegistry.GetNode(ctx, "test-node-3")
+				require.NoError(t, err)
+				revision, err := storage.ResourceVersionOf(node)
+				require.NoError(t, err)
+				node.Spec.Unschedulable = true
+				err = nodeRegistry.UpdateNodeAtRevision(ctx, node, revision)
+				if errors.Is(err, ErrConflict) {
+					conflicts++
+					continue
+				}
+				require.NoError(t, err)
+				break
+			}
+			require.NoError(t, <-heartbeatsDone)
+			t.Logf("cordon retried %d times", conflicts)
+
+			node, err := nodeRegistry.GetNode(ctx, "test-node-3")
+			require.NoError(t, err)
+			assert.True(t, node.Spec.Unschedulable, "cordon should persist")
+			assert.Equal(t, api.NodeReady, node.Status)
+			assert.Equal(t, int64(heartbeats-1), node.Capacity[api.ResourceCPU], "last heartbeat should persist")
+		})
+	})
+}
+
+func TestNodeRegistry_ApplyNode(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		nodeRegistry := NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+
+		node := createTestNode("applied-node", "123")
+		created, err := nodeRegistry.ApplyNode(ctx, node)
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.NotEmpty(t, node.ResourceVersion)
+		assert.False(t, node.CreationTimestamp.IsZero())
+
+		stored, err := nodeRegistry.GetNode(ctx, "applied-node")
+		require.NoError(t, err)
+		assert.Equal(t, node.ResourceVersion, stored.ResourceVersion)
+
+		stored.Labels = map[string]string{"zone": "a"}
+		created, err = nodeRegistry.ApplyNode(ctx, stored)
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.NotEqual(t, node.ResourceVersion, stored.ResourceVersion)
+
+		updated, err := nodeRegistry.GetNode(ctx, "applied-node")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"zone": "a"}, updated.Labels)
+		assert.Equal(t, stored.ResourceVersion, updated.ResourceVersion)
+		assert.True(t, node.CreationTimestamp.Equal(updated.CreationTimestamp))
+
+		// A node applied with another creation timestamp keeps the stored one
+		fresh := createTestNode("applied-node", "123")
+		fresh.CreationTimestamp = time.Now().Add(time.Hour)
+		_, err = nodeRegistry.ApplyNode(ctx, fresh)
+		require.NoError(t, err)
+		assert.True(t, node.CreationTimestamp.Equal(fresh.CreationTimestamp))
+		updated, err = nodeRegistry.GetNode(ctx, "applied-node")
+		require.NoError(t, err)
+		assert.True(t, node.CreationTimestamp.Equal(updated.CreationTimestamp))
+
+		_, err = nodeRegistry.ApplyNode(ctx, createTestNode("", "456"))
+		assert.ErrorIs(t, err, ErrNodeInvalid)
+	})
+}
+
+func TestNodeRegistry_UpdateNodeStatus(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		nodeRegistry := NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+		nodeName := "status-node"
+		createTestNodeInRegistry(t, nodeRegistry, nodeName, "123")
+
+		// The node is cordoned after the kubelet read it
+		node, err := nodeRegistry.GetNode(ctx, nodeName)
+		require.NoError(t, err)
+		node.Spec.Unschedulable = true
+		require.NoError(t, nodeRegistry.UpdateNode(ctx, node))
+
+		t.Run("should update resources and keep the spec", func(t *testing.T) {
+			updated, err := nodeRegistry.UpdateNodeStatus(ctx, &api.Node{
+				ObjectMeta:  api.ObjectMeta{Name: nodeName},
+				Status:      api.NodeReady,
+				Capacity:    api.ResourceList{api.ResourceCPU: 4000, api.ResourceMemory: 8 << 30},
+				Allocatable: api.ResourceList{api.ResourceCPU: 3000, api.ResourceMemory: 6 << 30},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int64(3000), updated.Allocatable[api.ResourceCPU])
+
+			stored, err := nodeRegistry.GetNode(ctx, nodeName)
+			require.NoError(t, err)
+			assert.True(t, stored.Spec.Unschedulable)
+			assert.Equal(t, api.NodeReady, stored.Status)
+			assert.Equal(t, int64(8<<30), stored.Capacity[api.ResourceMemory])
+			assert.Equal(t, int64(6<<30), stored.Allocatable[api.ResourceMemory])
+		})
+
+		t.Run("should reject a spec change", func(t *testing.T) {
+			_, err := nodeRegistry.UpdateNodeStatus(ctx, &api.Node{
+				ObjectMeta: api.ObjectMeta{Name: nodeName},
+				Spec:       api.NodeSpec{ProviderID: "other"},
+			})
+			assert.ErrorIs(t, err, ErrNodeSpecChanged)
+		})
+
+		t.Run("should return not found for a missing node", func(t *testing.T) {
+			_, err := nodeRegistry.UpdateNodeStatus(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "missing"}})
+			assert.ErrorIs(t, err, ErrNodeNotFound)
+		})
+	})
 }
 
 func TestNodeRegistry_ListNodes(t *testing.T) {
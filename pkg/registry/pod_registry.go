@@ -4,71 +4,148 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 )
 
-const podPrefix = "/pods/"
+const (
+	podPrefix = "/pods/"
+
+	// podsByNodeIndex indexes Pods by the name of the node they are bound to
+	podsByNodeIndex = "pods-by-node"
+
+	// podsByOwnerIndex indexes Pods by the UID of their controller
+	podsByOwnerIndex = "pods-by-owner"
+)
 
 var (
 	ErrPodAlreadyExists = errors.New("pod already exists")
-	ErrPodNotFound      = errors.New("pod not found")
+	ErrPodNotFound      = fmt.Errorf("pod %w", ErrNotFound)
 	ErrListPodsFailed   = errors.New("failed to list pods")
 	ErrPodInvalid       = errors.New("invalid pod")
+	ErrPodAlreadyBound  = errors.New("pod already bound")
+	ErrPodNotOnNode     = errors.New("pod not bound to the node")
+	ErrPodAlreadyOwned  = errors.New("pod already owned by another controller")
+	ErrPodSpecChanged   = errors.New("pod spec cannot be changed by a status update")
+	ErrPodConflict      = fmt.Errorf("pod %w", ErrConflict)
+	// ErrPodInvalidContainerName is returned for a Pod with a container name that is not a
+	// DNS-1123 label or is not unique within the Pod. It wraps ErrPodInvalid.
+	ErrPodInvalidContainerName = fmt.Errorf("%w: invalid container name", ErrPodInvalid)
 )
 
 // PodRegistry provides thread-safe operations for managing Pod objects in the storage.
 type PodRegistry struct {
 	storage storage.Storage
 	mutex   sync.RWMutex
+	// listCache serves repeated ListPods calls, it is nil when caching is disabled
+	listCache *listCache[*api.Pod]
+	admission admission[*api.Pod]
+}
+
+// PodRegistryOptions configures the PodRegistry behavior
+type PodRegistryOptions struct {
+	// ListCacheTTL is how long a list of all Pods is served from memory. Writes through the
+	// registry are reflected right away, other writes once the TTL expires. Zero disables
+	// the cache.
+	ListCacheTTL time.Duration
 }
 
 // NewPodRegistry creates a new PodRegistry with the given storage.
-func NewPodRegistry(storage storage.Storage) *PodRegistry {
+// If the storage supports indexes, Pods are indexed by node and by controller so they can be
+// listed per node and per owner.
+func NewPodRegistry(s storage.Storage) *PodRegistry {
+	return NewPodRegistryWithOptions(s, PodRegistryOptions{})
+}
+
+// NewPodRegistryWithOptions creates a PodRegistry with the given configuration
+func NewPodRegistryWithOptions(s storage.Storage, opts PodRegistryOptions) *PodRegistry {
+	if indexed, ok := s.(storage.IndexedStorage); ok {
+		indexed.AddIndexer(storage.Indexer{
+			Name:      podsByNodeIndex,
+			Prefix:    podPrefix,
+			NewObject: func() runtime.Object { return &api.Pod{} },
+			IndexFunc: func(obj runtime.Object) string {
+				return obj.(*api.Pod).NodeName
+			},
+		})
+		indexed.AddIndexer(storage.Indexer{
+			Name:      podsByOwnerIndex,
+			Prefix:    podPrefix,
+			NewObject: func() runtime.Object { return &api.Pod{} },
+			IndexFunc: func(obj runtime.Object) string {
+				return controllerUID(obj.(*api.Pod))
+			},
+		})
+	}
+
 	return &PodRegistry{
-		storage: storage,
+		storage:   s,
+		listCache: newListCache[*api.Pod](opts.ListCacheTTL),
+		admission: newPodAdmission(),
 	}
 }
 
-func (r *PodRegistry) generateKey(podName string) string {
-	return fmt.Sprintf("%s%s", podPrefix, podName)
+// namespacePrefix returns the storage prefix of the Pods of the namespace. NamespaceAll
+// returns the prefix of the Pods of all namespaces.
+func (r *PodRegistry) namespacePrefix(namespace string) string {
+	if namespace == api.NamespaceAll {
+		return podPrefix
+	}
+	return fmt.Sprintf("%s%s/", podPrefix, namespace)
+}
+
+// generateKey returns the storage key of the Pod, /pods/{namespace}/{name}. Pods without a
+// namespace are in the default namespace.
+func (r *PodRegistry) generateKey(namespace, podName string) string {
+	return r.namespacePrefix(api.NamespaceOrDefault(namespace)) + podName
 }
 
 // CreatePod creates a new pod in the registry.
 // It returns an error if the pod already exists or if the pod spec is invalid.
-// If the pod status is not set, it defaults to api.PodPending.
+// If the pod status is not set, it defaults to api.PodPending and the creation timestamp defaults to now.
 func (r *PodRegistry) CreatePod(ctx context.Context, pod *api.Pod) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
 
-	key := r.generateKey(pod.Name)
+	pod.Namespace = api.NamespaceOrDefault(pod.Namespace)
+	key := r.generateKey(pod.Namespace, pod.Name)
 	existingPod := &api.Pod{}
 	err := r.storage.Get(ctx, key, existingPod)
 	if err == nil {
 		return fmt.Errorf("%w: %s", ErrPodAlreadyExists, pod.Name)
 	}
 
-	if pod.Status == "" {
-		pod.Status = api.PodPending
-	}
-
-	// Validate Pod spec
-	if err := pod.Validate(); err != nil {
-		return fmt.Errorf("%w: %v", ErrPodInvalid, err)
+	if err := r.admission.admit(pod, operationCreate); err != nil {
+		return podValidationError(err)
 	}
 
 	return r.storage.Create(ctx, key, pod)
 }
 
-// GetPod retrieves a Pod by its name from the registry.
+// podValidationError wraps an error of Pod.Validate in the registry error matching its cause
+func podValidationError(err error) error {
+	if errors.Is(err, api.ErrInvalidContainerName) {
+		return fmt.Errorf("%w: %v", ErrPodInvalidContainerName, err)
+	}
+	return fmt.Errorf("%w: %v", ErrPodInvalid, err)
+}
+
+// GetPod retrieves a Pod by its namespace and name from the registry.
 // It returns the Pod object if found, otherwise it returns an error indicating that the Pod was not found.
-func (r *PodRegistry) GetPod(ctx context.Context, name string) (*api.Pod, error) {
+func (r *PodRegistry) GetPod(ctx context.Context, namespace, name string) (*api.Pod, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	key := r.generateKey(name)
+	key := r.generateKey(namespace, name)
 	pod := &api.Pod{}
 	if err := r.storage.Get(ctx, key, pod); err != nil {
 		switch {
@@ -82,49 +159,566 @@ func (r *PodRegistry) GetPod(ctx context.Context, name string) (*api.Pod, error)
 	return pod, nil
 }
 
-// UpdatePod updates an existing Pod in the registry.
-// It returns an error if the Pod spec is invalid.
+// UpdatePod updates an existing Pod in the registry, whatever the resource version of the
+// stored Pod. It returns an error if the Pod spec is invalid.
 func (r *PodRegistry) UpdatePod(ctx context.Context, pod *api.Pod) error {
+	return r.updatePod(ctx, pod, 0)
+}
+
+// UpdatePodAtRevision is UpdatePod that only updates the Pod if it is still at the resource
+// version, ErrPodConflict is returned if it was modified since
+func (r *PodRegistry) UpdatePodAtRevision(ctx context.Context, pod *api.Pod, revision int64) error {
+	return r.updatePod(ctx, pod, revision)
+}
+
+// updatePod updates the Pod, only if it is at revision unless revision is 0
+func (r *PodRegistry) updatePod(ctx context.Context, pod *api.Pod, revision int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	if err := r.admission.admit(pod, operationUpdate); err != nil {
+		return podValidationError(err)
+	}
+	key := r.generateKey(pod.Namespace, pod.Name)
+	stored := &api.Pod{}
+	if err := r.storage.Get(ctx, key, stored); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: %s", ErrPodNotFound, pod.Name)
+		}
+		return err
+	}
+	// Only MarkPodForDeletion sets the deletion timestamp, an update can't set or clear it
+	pod.DeletionTimestamp = stored.DeletionTimestamp
+
+	// Removing the last finalizer of a pod marked for deletion completes the deletion
+	if stored.DeletionTimestamp != nil && len(stored.Finalizers) > 0 && len(pod.Finalizers) == 0 {
+		if revision == 0 {
+			// Deleting at the revision read keeps the pod if finalizers were added since
+			var err error
+			if revision, err = storage.ResourceVersionOf(stored); err != nil {
+				return err
+			}
+		}
+		return deleteAtRevisionError(r.storage.DeleteAtRevision(ctx, key, revision), ErrPodNotFound, ErrPodConflict)
+	}
+
+	var err error
+	if revision != 0 {
+		err = r.storage.UpdateAtRevision(ctx, key, pod, revision)
+	} else {
+		err = r.storage.Update(ctx, key, pod)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return fmt.Errorf("%w: %v", ErrPodConflict, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdatePodStatus updates the phase of the stored pod from the given pod and sets the
+// conditions it carries, keeping conditions of other types. Other fields, such as the NodeName set by a concurrent binding, are left as stored. A status
+// update that carries a spec must carry the stored one, as the spec can't be changed this way.
+func (r *PodRegistry) UpdatePodStatus(ctx context.Context, pod *api.Pod) (*api.Pod, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(pod.Namespace, pod.Name)
+	updated := &api.Pod{}
+	err := r.storage.GuaranteedUpdate(ctx, key, updated, func(obj runtime.Object) error {
+		current := obj.(*api.Pod)
+		if !reflect.DeepEqual(pod.Spec, api.PodSpec{}) && !reflect.DeepEqual(pod.Spec, current.Spec) {
+			return fmt.Errorf("%w: %s", ErrPodSpecChanged, pod.Name)
+		}
+
+		current.Status.Phase = pod.Status.Phase
+		for _, condition := range pod.Status.Conditions {
+			conditions.SetCondition(&current.Status.Conditions, condition)
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPodSpecChanged):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, pod.Name)
+		default:
+			return nil, fmt.Errorf("%w: failed to update pod status: %v", ErrInternal, err)
+		}
+	}
+
+	return updated, nil
+}
+
+// BindPod assigns an unassigned Pod to the given node.
+// The binding is written in a transaction conditioned on the Pod still being unassigned,
+// so when several schedulers race to bind the same Pod exactly one of them succeeds and
+// the others get ErrPodAlreadyBound.
+func (r *PodRegistry) BindPod(ctx context.Context, namespace, name, nodeName string) (*api.Pod, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(namespace, name)
+	pod := &api.Pod{}
+	err := r.storage.GuaranteedUpdate(ctx, key, pod, func(obj runtime.Object) error {
+		current := obj.(*api.Pod)
+		if current.NodeName != "" || current.Status.Phase != api.PodPending {
+			return fmt.Errorf("%w: %s is bound to node %q", ErrPodAlreadyBound, name, current.NodeName)
+		}
+
+		current.NodeName = nodeName
+		conditions.SetCondition(&current.Status.Conditions, api.Condition{
+			Type:   api.PodConditionScheduled,
+			Status: api.ConditionTrue,
+		})
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPodAlreadyBound):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to bind pod: %v", ErrInternal, err)
+		}
+	}
+
+	return pod, nil
+}
+
+// MarkPodUnschedulable records on an unassigned Pod that it could not be scheduled by setting
+// its PodScheduled condition to False with the given reason. When failed is true the Pod is
+// also moved to PodFailed so that it is no longer considered for scheduling.
+// It returns ErrPodAlreadyBound if the Pod was bound in the meantime.
+func (r *PodRegistry) MarkPodUnschedulable(ctx context.Context, namespace, name, reason, message string, failed bool) (*api.Pod, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(namespace, name)
+	pod := &api.Pod{}
+	err := r.storage.GuaranteedUpdate(ctx, key, pod, func(obj runtime.Object) error {
+		current := obj.(*api.Pod)
+		if current.NodeName != "" || current.Status.Phase != api.PodPending {
+			return fmt.Errorf("%w: %s is bound to node %q", ErrPodAlreadyBound, name, current.NodeName)
+		}
+
+		conditions.SetCondition(&current.Status.Conditions, api.Condition{
+			Type:    api.PodConditionScheduled,
+			Status:  api.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		})
+
+		if failed {
+			current.Status.Phase = api.PodFailed
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPodAlreadyBound):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to update pod: %v", ErrInternal, err)
+		}
+	}
+
+	return pod, nil
+}
+
+// ReschedulePod unbinds a Pod from the node it is bound to, moving it back to Pending so that
+// the scheduler places it on another node. Its PodScheduled condition is set to False with the
+// given reason and it is no longer Ready. It returns ErrPodNotOnNode if the Pod was bound to
+// another node or finished in the meantime.
+func (r *PodRegistry) ReschedulePod(ctx context.Context, namespace, name, nodeName, reason, message string) (*api.Pod, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(namespace, name)
+	pod := &api.Pod{}
+	err := r.storage.GuaranteedUpdate(ctx, key, pod, func(obj runtime.Object) error {
+		current := obj.(*api.Pod)
+		if current.NodeName != nodeName || current.Status.Phase == api.PodSucceeded || current.Status.Phase == api.PodFailed {
+			return fmt.Errorf("%w: %s is bound to node %q", ErrPodNotOnNode, name, current.NodeName)
+		}
+
+		current.NodeName = ""
+		current.Status.Phase = api.PodPending
+		conditions.SetCondition(&current.Status.Conditions, api.Condition{
+			Type:    api.PodConditionScheduled,
+			Status:  api.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		})
+		for _, conditionType := range []api.ConditionType{api.PodConditionContainersReady, api.PodConditionReady} {
+			if conditions.GetCondition(current.Status.Conditions, conditionType) != nil {
+				conditions.SetCondition(&current.Status.Conditions, api.Condition{
+					Type:   conditionType,
+					Status: api.ConditionFalse,
+					Reason: reason,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPodNotOnNode):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to reschedule pod: %v", ErrInternal, err)
+		}
+	}
+
+	return pod, nil
+}
+
+// SetControllerRef sets the controller owner reference of a Pod.
+// Setting the reference succeeds only if the Pod has no controller or is already controlled by
+// the same owner, otherwise ErrPodAlreadyOwned is returned. A nil ref releases the Pod from its
+// current controller.
+func (r *PodRegistry) SetControllerRef(ctx context.Context, namespace, name string, ref *api.OwnerReference) (*api.Pod, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(namespace, name)
+	pod := &api.Pod{}
+	err := r.storage.GuaranteedUpdate(ctx, key, pod, func(obj runtime.Object) error {
+		current := obj.(*api.Pod)
 
-	key := r.generateKey(pod.Name)
+		owners := make([]api.OwnerReference, 0, len(current.OwnerReferences))
+		for _, owner := range current.OwnerReferences {
+			if !owner.Controller {
+				owners = append(owners, owner)
+				continue
+			}
+			if ref != nil && (owner.Kind != ref.Kind || owner.Name != ref.Name || owner.UID != ref.UID) {
+				return fmt.Errorf("%w: %s is controlled by %s %q", ErrPodAlreadyOwned, name, owner.Kind, owner.Name)
+			}
+		}
 
-	// Validate Pod spec
-	if err := pod.Validate(); err != nil {
-		return fmt.Errorf("%w: %v", ErrPodInvalid, err)
+		if ref != nil {
+			owners = append(owners, *ref)
+		}
+		if len(owners) == 0 {
+			owners = nil
+		}
+		current.OwnerReferences = owners
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPodAlreadyOwned):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to update pod: %v", ErrInternal, err)
+		}
 	}
 
-	return r.storage.Update(ctx, key, pod)
+	return pod, nil
+}
+
+// errDeletePod aborts the update of MarkPodForDeletion for a pod without finalizers, which is
+// deleted rather than marked
+var errDeletePod = errors.New("pod has no finalizers")
+
+// MarkPodForDeletion requests the deletion of a pod that has finalizers. The pod is marked with a
+// deletion timestamp and is removed once its finalizers are removed by UpdatePod. A pod without
+// finalizers is deleted right away, at the resource version it was found without them, in which
+// case nil is returned. A pod that gains a finalizer meanwhile is marked instead.
+func (r *PodRegistry) MarkPodForDeletion(ctx context.Context, namespace, name string) (*api.Pod, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(namespace, name)
+	for {
+		pod := &api.Pod{}
+		var revision int64
+		err := r.storage.GuaranteedUpdate(ctx, key, pod, func(obj runtime.Object) error {
+			current := obj.(*api.Pod)
+			if len(current.Finalizers) == 0 {
+				var err error
+				revision, err = storage.ResourceVersionOf(current)
+				if err != nil {
+					return err
+				}
+				return errDeletePod
+			}
+			if current.DeletionTimestamp == nil {
+				now := time.Now()
+				current.DeletionTimestamp = &now
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+			return pod, nil
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, name)
+		case !errors.Is(err, errDeletePod):
+			return nil, fmt.Errorf("%w: failed to mark pod for deletion: %v", ErrInternal, err)
+		}
+
+		err = deleteAtRevisionError(r.storage.DeleteAtRevision(ctx, key, revision), ErrPodNotFound, ErrPodConflict)
+		if errors.Is(err, ErrPodConflict) {
+			// The pod changed since, such as by gaining a finalizer, decide again
+			continue
+		}
+		return nil, err
+	}
 }
 
-// DeletePod removes a Pod from the registry by its name.
-// It returns an error if the deletion fails.
-func (r *PodRegistry) DeletePod(ctx context.Context, name string) error {
+// DeletePod removes the pod from storage immediately, regardless of its finalizers.
+// ErrPodNotFound is returned if the pod doesn't exist.
+func (r *PodRegistry) DeletePod(ctx context.Context, namespace, name string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
 
-	key := r.generateKey(name)
+	key := r.generateKey(namespace, name)
+	if err := r.storage.Get(ctx, key, &api.Pod{}); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: %s", ErrPodNotFound, name)
+		}
+		return fmt.Errorf("%w: failed to get pod: %v", ErrInternal, err)
+	}
 	return r.storage.Delete(ctx, key)
 }
 
-// ListPods retrieves all Pods from the registry.
+// DeletePodAtRevision removes the pod from storage if it is still at the resource version,
+// regardless of its finalizers. ErrPodConflict is returned if it was modified since.
+func (r *PodRegistry) DeletePodAtRevision(ctx context.Context, namespace, name string, revision int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	key := r.generateKey(namespace, name)
+	return deleteAtRevisionError(r.storage.DeleteAtRevision(ctx, key, revision), ErrPodNotFound, ErrPodConflict)
+}
+
+// MigrateLegacyPods moves the pods stored before pods had namespaces, under /pods/{name}, to
+// their key in the default namespace, and returns how many were moved. A legacy pod whose
+// namespaced key is already taken is left in place and logged.
+func (r *PodRegistry) MigrateLegacyPods(ctx context.Context) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.listCache.invalidate()
+
+	var pods []*api.Pod
+	metas, _, err := r.storage.ListWithMeta(ctx, podPrefix, &pods)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+
+	migrated := 0
+	for i, meta := range metas {
+		if strings.Contains(strings.TrimPrefix(meta.Key, podPrefix), "/") {
+			continue
+		}
+
+		pod := pods[i]
+		pod.Namespace = api.NamespaceOrDefault(pod.Namespace)
+		key := r.generateKey(pod.Namespace, pod.Name)
+		err := r.storage.Get(ctx, key, &api.Pod{})
+		if err == nil {
+			log.Printf("Not migrating pod %s, %s already exists", meta.Key, key)
+			continue
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return migrated, fmt.Errorf("%w: failed to get pod: %v", ErrInternal, err)
+		}
+
+		if err := r.storage.Create(ctx, key, pod); err != nil {
+			return migrated, fmt.Errorf("%w: failed to migrate pod %s: %v", ErrInternal, meta.Key, err)
+		}
+		if err := r.storage.DeleteAtRevision(ctx, meta.Key, meta.ModRevision); err != nil {
+			return migrated, fmt.Errorf("%w: failed to delete migrated pod %s: %v", ErrInternal, meta.Key, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// ListPods retrieves the Pods of all namespaces from the registry.
 // It returns a slice of Pod objects and an error if the listing fails.
 func (r *PodRegistry) ListPods(ctx context.Context) ([]*api.Pod, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
+	return r.listCache.list(func() ([]*api.Pod, error) {
+		var pods []*api.Pod
+		if err := r.storage.List(ctx, podPrefix, &pods); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+		}
+		return pods, nil
+	})
+}
+
+// ListPodsInNamespace retrieves the Pods of the namespace, or of all namespaces for
+// NamespaceAll
+func (r *PodRegistry) ListPodsInNamespace(ctx context.Context, namespace string) ([]*api.Pod, error) {
+	if namespace == api.NamespaceAll {
+		return r.ListPods(ctx)
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var pods []*api.Pod
+	if err := r.storage.List(ctx, r.namespacePrefix(namespace), &pods); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	return pods, nil
+}
+
+// ListPodsOrdered retrieves the Pods of the namespace, or of all namespaces for NamespaceAll,
+// sorted in the given order
+func (r *PodRegistry) ListPodsOrdered(ctx context.Context, namespace string, order ListOrder) ([]*api.Pod, error) {
+	pods, err := r.ListPodsInNamespace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	SortByOrder(pods, order)
+	return pods, nil
+}
+
+// ListPodsPaged retrieves at most limit Pods of the namespace, or of all namespaces for
+// NamespaceAll, continuing the listing the continue token was returned for. It also returns
+// the token that continues the listing, which is empty once all Pods were listed.
+func (r *PodRegistry) ListPodsPaged(ctx context.Context, namespace string, limit int64, continueToken string) ([]*api.Pod, string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var pods []*api.Pod
+	next, err := r.storage.ListPaged(ctx, r.namespacePrefix(namespace), limit, continueToken, &pods)
+	if err != nil {
+		return nil, "", listPagedError(err, ErrListPodsFailed)
+	}
+
+	return pods, next, nil
+}
+
+// ListPodsByNode retrieves the Pods bound to the given node.
+// The per-node index is updated in the same transaction as the Pod, so a Pod is listed as
+// soon as its binding is committed.
+func (r *PodRegistry) ListPodsByNode(ctx context.Context, nodeName string) ([]*api.Pod, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var pods []*api.Pod
+	indexed, ok := r.storage.(storage.IndexedStorage)
+	if !ok {
+		if err := r.storage.List(ctx, podPrefix, &pods); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+		}
+
+		filteredPods := make([]*api.Pod, 0)
+		for _, pod := range pods {
+			if pod.NodeName == nodeName {
+				filteredPods = append(filteredPods, pod)
+			}
+		}
+		return filteredPods, nil
+	}
+
+	if err := indexed.ListByIndex(ctx, podsByNodeIndex, nodeName, &pods); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+
+	return pods, nil
+}
+
+// ListPodsByOwner retrieves the Pods whose controller has the given UID.
+// The per-owner index is updated in the same transaction as the Pod, so adopting, releasing
+// or deleting a Pod is reflected as soon as it is committed.
+func (r *PodRegistry) ListPodsByOwner(ctx context.Context, ownerUID string) ([]*api.Pod, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if ownerUID == "" {
+		return nil, fmt.Errorf("%w: owner UID cannot be empty", ErrListPodsFailed)
+	}
+
 	var pods []*api.Pod
-	if err := r.storage.List(ctx, podPrefix, &pods); err != nil {
+	indexed, ok := r.storage.(storage.IndexedStorage)
+	if !ok {
+		if err := r.storage.List(ctx, podPrefix, &pods); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+		}
+
+		filteredPods := make([]*api.Pod, 0)
+		for _, pod := range pods {
+			if controllerUID(pod) == ownerUID {
+				filteredPods = append(filteredPods, pod)
+			}
+		}
+		return filteredPods, nil
+	}
+
+	if err := indexed.ListByIndex(ctx, podsByOwnerIndex, ownerUID, &pods); err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
 	}
 
 	return pods, nil
 }
 
-// listPodsByStatus retrieves all Pods with a specific status from the registry.
-// It returns a slice of Pod objects with the given status and an error if the listing fails.
-func (r *PodRegistry) listPodsByStatus(ctx context.Context, status api.PodStatus) ([]*api.Pod, error) {
+func controllerUID(pod *api.Pod) string {
+	if ref := api.GetControllerOf(&pod.ObjectMeta); ref != nil {
+		return ref.UID
+	}
+	return ""
+}
+
+// CurrentRevision returns the latest resource version, watching Pods from it misses no
+// later change
+func (r *PodRegistry) CurrentRevision(ctx context.Context) (int64, error) {
+	return r.storage.CurrentRevision(ctx)
+}
+
+// WatchPods streams changes to Pods made after the given resource version.
+// A resource version of 0 watches for changes from now on.
+func (r *PodRegistry) WatchPods(ctx context.Context, resourceVersion int64) (<-chan storage.WatchEvent, error) {
+	return r.WatchPodsInNamespace(ctx, api.NamespaceAll, resourceVersion)
+}
+
+// WatchPodsInNamespace is WatchPods for the Pods of one namespace, or of all namespaces for
+// NamespaceAll
+func (r *PodRegistry) WatchPodsInNamespace(ctx context.Context, namespace string, resourceVersion int64) (<-chan storage.WatchEvent, error) {
+	return r.storage.WatchFromRevision(ctx, r.namespacePrefix(namespace), resourceVersion, storage.DefaultWatchOptions())
+}
+
+// ListPodsWithRevision lists the Pods of the namespace like ListPodsInNamespace, and also
+// returns the revision they were listed at. Watching from that revision misses no change.
+func (r *PodRegistry) ListPodsWithRevision(ctx context.Context, namespace string) ([]*api.Pod, int64, error) {
+	pods := make([]*api.Pod, 0)
+	_, revision, err := r.storage.ListWithMeta(ctx, r.namespacePrefix(namespace), &pods)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+
+	return pods, revision, nil
+}
+
+// listUnscheduledPods retrieves all PodPending Pods that aren't bound to a node from the registry.
+// It returns a slice of Pod objects and an error if the listing fails.
+func (r *PodRegistry) listUnscheduledPods(ctx context.Context) ([]*api.Pod, error) {
 	pods, err := r.ListPods(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
@@ -132,7 +726,7 @@ func (r *PodRegistry) listPodsByStatus(ctx context.Context, status api.PodStatus
 
 	filteredPods := make([]*api.Pod, 0)
 	for _, pod := range pods {
-		if pod.Status == status {
+		if pod.Status.Phase == api.PodPending && pod.NodeName == "" {
 			filteredPods = append(filteredPods, pod)
 		}
 	}
@@ -140,14 +734,14 @@ func (r *PodRegistry) listPodsByStatus(ctx context.Context, status api.PodStatus
 	return filteredPods, nil
 }
 
-// ListUnassignedPods retrieves all Pods with a status of PodPending from the registry.
+// ListUnassignedPods retrieves all PodPending Pods that aren't bound to a node from the registry.
 // It returns a slice of unassigned Pod objects and an error if the listing fails.
 func (r *PodRegistry) ListUnassignedPods(ctx context.Context) ([]*api.Pod, error) {
-	return r.listPodsByStatus(ctx, api.PodPending)
+	return r.listUnscheduledPods(ctx)
 }
 
-// ListPendingPods retrieves all Pods with a status of PodPending from the registry.
-// It returns a slice of pending Pod objects and an error if the listing fails.
+// ListPendingPods retrieves all Pods waiting to be scheduled, the PodPending Pods that aren't
+// bound to a node yet. It returns a slice of pending Pod objects and an error if the listing fails.
 func (r *PodRegistry) ListPendingPods(ctx context.Context) ([]*api.Pod, error) {
-	return r.listPodsByStatus(ctx, api.PodPending)
+	return r.listUnscheduledPods(ctx)
 }
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
 	"github.com/stretchr/testify/assert"
@@ -47,23 +51,23 @@ func TestPodRegistry_GetPod(t *testing.T) {
 					},
 					Replicas: 3,
 				},
-				Status: api.PodPending,
+				Status: api.PodStatus{Phase: api.PodPending},
 			}
 
 			err := registry.CreatePod(ctx, pod)
 			require.NoError(t, err)
 
 			// Test GetPod
-			retrievedPod, err := registry.GetPod(ctx, "test-pod")
+			retrievedPod, err := registry.GetPod(ctx, api.NamespaceDefault, "test-pod")
 			require.NoError(t, err)
 
 			// Verify pod name and status
 			assert.Equal(t, "test-pod", retrievedPod.Name)
-			assert.Equal(t, api.PodPending, retrievedPod.Status)
+			assert.Equal(t, api.PodPending, retrievedPod.Status.Phase)
 
 			// Verify pod spec
 			assert.Len(t, retrievedPod.Spec.Containers, 1)
-			assert.Equal(t, "nginx:latest", retrievedPod.Spec.Containers[0].Image)
+			assert.Equal(t, "docker.io/library/nginx:latest", retrievedPod.Spec.Containers[0].Image)
 			assert.Equal(t, int32(3), retrievedPod.Spec.Replicas)
 		})
 	})
@@ -74,7 +78,7 @@ func TestPodRegistry_GetPod(t *testing.T) {
 			registry := NewPodRegistry(etcdStorage)
 			ctx := context.Background()
 
-			_, err := registry.GetPod(ctx, "non-existent-pod")
+			_, err := registry.GetPod(ctx, api.NamespaceDefault, "non-existent-pod")
 			assert.ErrorIs(t, err, ErrPodNotFound)
 			assert.EqualError(t, err, "pod not found: non-existent-pod")
 		})
@@ -90,7 +94,7 @@ func TestPodRegistry_GetPod(t *testing.T) {
 
 		mStorage.EXPECT().Get(ctx, gomock.Any(), gomock.Any()).Return(fmt.Errorf("storage error"))
 
-		_, err := registry.GetPod(ctx, "invalid-pod")
+		_, err := registry.GetPod(ctx, api.NamespaceDefault, "invalid-pod")
 		assert.ErrorIs(t, err, ErrInternal)
 	})
 }
@@ -115,18 +119,41 @@ func TestPodRegistry_CreatePod(t *testing.T) {
 					},
 					Replicas: 3,
 				},
-				Status: api.PodPending,
+				Status: api.PodStatus{Phase: api.PodPending},
 			}
 
 			err := registry.CreatePod(ctx, pod)
 			require.NoError(t, err)
 
 			// Verify pod was created
-			_, err = registry.GetPod(ctx, "test-pod")
+			_, err = registry.GetPod(ctx, api.NamespaceDefault, "test-pod")
 			require.NoError(t, err)
 		})
 	})
 
+	t.Run("should store normalized images and reject invalid ones", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "image-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+			}
+			require.NoError(t, registry.CreatePod(ctx, pod))
+
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "image-pod")
+			require.NoError(t, err)
+			assert.Equal(t, "docker.io/library/nginx:latest", stored.Spec.Containers[0].Image)
+
+			invalid := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "invalid-image-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx::latest"}}},
+			}
+			assert.ErrorIs(t, registry.CreatePod(ctx, invalid), ErrPodInvalid)
+		})
+	})
+
 	t.Run("should fail to create pod with the same name", func(t *testing.T) {
 		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
 			etcdStorage := storage.NewEtcdStorage(etcdServer)
@@ -147,7 +174,7 @@ func TestPodRegistry_CreatePod(t *testing.T) {
 					},
 					Replicas: 3,
 				},
-				Status: api.PodPending,
+				Status: api.PodStatus{Phase: api.PodPending},
 			}
 
 			err := registry.CreatePod(ctx, pod)
@@ -186,9 +213,9 @@ func TestPodRegistry_CreatePod(t *testing.T) {
 			require.NoError(t, err)
 
 			// Verify pod was created with default status
-			retrievedPod, err := registry.GetPod(ctx, "no-status-pod")
+			retrievedPod, err := registry.GetPod(ctx, api.NamespaceDefault, "no-status-pod")
 			require.NoError(t, err)
-			assert.Equal(t, api.PodPending, retrievedPod.Status)
+			assert.Equal(t, api.PodPending, retrievedPod.Status.Phase)
 		})
 	})
 
@@ -239,21 +266,21 @@ func TestPodRegistry_UpdatePod(t *testing.T) {
 					},
 					Replicas: 3,
 				},
-				Status: api.PodPending,
+				Status: api.PodStatus{Phase: api.PodPending},
 			}
 
 			err := registry.CreatePod(ctx, pod)
 			require.NoError(t, err)
 
 			// Update pod status
-			pod.Status = api.PodRunning
+			pod.Status.Phase = api.PodRunning
 			err = registry.UpdatePod(ctx, pod)
 			require.NoError(t, err)
 
 			// Verify updated status
-			retrievedPod, err := registry.GetPod(ctx, "test-pod")
+			retrievedPod, err := registry.GetPod(ctx, api.NamespaceDefault, "test-pod")
 			require.NoError(t, err)
-			assert.Equal(t, api.PodRunning, retrievedPod.Status)
+			assert.Equal(t, api.PodRunning, retrievedPod.Status.Phase)
 		})
 	})
 	t.Run("should validate pod spec on update", func(t *testing.T) {
@@ -275,7 +302,7 @@ func TestPodRegistry_UpdatePod(t *testing.T) {
 					},
 					Replicas: 3,
 				},
-				Status: api.PodPending,
+				Status: api.PodStatus{Phase: api.PodPending},
 			}
 
 			err := registry.CreatePod(ctx, validPod)
@@ -308,17 +335,203 @@ func TestPodRegistry_DeletePod(t *testing.T) {
 				},
 				Replicas: 3,
 			},
-			Status: api.PodPending,
+			Status: api.PodStatus{Phase: api.PodPending},
 		}
 
 		err := registry.CreatePod(ctx, pod)
 		require.NoError(t, err)
 
-		err = registry.DeletePod(ctx, "test-pod")
+		err = registry.DeletePod(ctx, api.NamespaceDefault, "test-pod")
 		require.NoError(t, err)
 
-		_, err = registry.GetPod(ctx, "test-pod")
+		_, err = registry.GetPod(ctx, api.NamespaceDefault, "test-pod")
 		assert.Error(t, err)
+
+		err = registry.DeletePod(ctx, api.NamespaceDefault, "test-pod")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+	})
+}
+
+func TestPodRegistry_MigrateLegacyPods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		registry := NewPodRegistry(etcdStorage)
+		ctx := context.Background()
+
+		newPod := func(name string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				Status:     api.PodStatus{Phase: api.PodPending},
+			}
+		}
+		// Pods stored before pods had namespaces, one of them shadowed by a namespaced pod
+		require.NoError(t, etcdStorage.Create(ctx, podPrefix+"legacy", newPod("legacy")))
+		require.NoError(t, etcdStorage.Create(ctx, podPrefix+"taken", newPod("taken")))
+		require.NoError(t, registry.CreatePod(ctx, newPod("taken")))
+
+		migrated, err := registry.MigrateLegacyPods(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, migrated)
+
+		pod, err := registry.GetPod(ctx, api.NamespaceDefault, "legacy")
+		require.NoError(t, err)
+		assert.Equal(t, api.NamespaceDefault, pod.Namespace)
+		assert.ErrorIs(t, etcdStorage.Get(ctx, podPrefix+"legacy", &api.Pod{}), storage.ErrNotFound)
+		assert.NoError(t, etcdStorage.Get(ctx, podPrefix+"taken", &api.Pod{}))
+
+		migrated, err = registry.MigrateLegacyPods(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, migrated)
+	})
+}
+
+// finalizerRacingStorage adds a finalizer to the pod the first time it is deleted at a revision,
+// right before the delete, as a client racing with the deletion would
+type finalizerRacingStorage struct {
+	storage.Storage
+	once sync.Once
+}
+
+func (s *finalizerRacingStorage) DeleteAtRevision(ctx context.Context, key string, revision int64) error {
+	var err error
+	s.once.Do(func() {
+		err = s.Storage.GuaranteedUpdate(ctx, key, &api.Pod{}, func(obj runtime.Object) error {
+			pod := obj.(*api.Pod)
+			pod.Finalizers = append(pod.Finalizers, "example.com/raced")
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return s.Storage.DeleteAtRevision(ctx, key, revision)
+}
+
+func TestPodRegistry_MarkPodForDeletion(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+
+		newPod := func(name string, finalizers ...string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name, Finalizers: finalizers},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+		}
+
+		t.Run("should keep a pod with finalizers until they are removed", func(t *testing.T) {
+			require.NoError(t, registry.CreatePod(ctx, newPod("finalized", "example.com/cleanup")))
+
+			marked, err := registry.MarkPodForDeletion(ctx, api.NamespaceDefault, "finalized")
+			require.NoError(t, err)
+			require.NotNil(t, marked)
+			assert.NotNil(t, marked.DeletionTimestamp)
+
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "finalized")
+			require.NoError(t, err)
+			assert.NotNil(t, stored.DeletionTimestamp)
+
+			stored.Finalizers = nil
+			require.NoError(t, registry.UpdatePod(ctx, stored))
+
+			_, err = registry.GetPod(ctx, api.NamespaceDefault, "finalized")
+			assert.ErrorIs(t, err, ErrPodNotFound)
+		})
+
+		t.Run("should not delete a pod updated with a deletion timestamp", func(t *testing.T) {
+			require.NoError(t, registry.CreatePod(ctx, newPod("updated")))
+
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "updated")
+			require.NoError(t, err)
+			now := time.Now()
+			stored.DeletionTimestamp = &now
+			require.NoError(t, registry.UpdatePod(ctx, stored))
+
+			stored, err = registry.GetPod(ctx, api.NamespaceDefault, "updated")
+			require.NoError(t, err)
+			assert.Nil(t, stored.DeletionTimestamp)
+		})
+
+		t.Run("should keep a pod marked for deletion while it has finalizers", func(t *testing.T) {
+			require.NoError(t, registry.CreatePod(ctx, newPod("unfinalized", "example.com/a", "example.com/b")))
+			_, err := registry.MarkPodForDeletion(ctx, api.NamespaceDefault, "unfinalized")
+			require.NoError(t, err)
+
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "unfinalized")
+			require.NoError(t, err)
+			stored.Finalizers = []string{"example.com/b"}
+			stored.DeletionTimestamp = nil
+			require.NoError(t, registry.UpdatePod(ctx, stored))
+
+			stored, err = registry.GetPod(ctx, api.NamespaceDefault, "unfinalized")
+			require.NoError(t, err)
+			assert.NotNil(t, stored.DeletionTimestamp, "an update can't clear the deletion timestamp")
+
+			stored.Finalizers = nil
+			require.NoError(t, registry.UpdatePod(ctx, stored))
+			_, err = registry.GetPod(ctx, api.NamespaceDefault, "unfinalized")
+			assert.ErrorIs(t, err, ErrPodNotFound)
+		})
+
+		t.Run("should delete a pod without finalizers right away", func(t *testing.T) {
+			require.NoError(t, registry.CreatePod(ctx, newPod("plain")))
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "plain")
+			require.NoError(t, err)
+			revision, err := storage.ResourceVersionOf(stored)
+			require.NoError(t, err)
+
+			marked, err := registry.MarkPodForDeletion(ctx, api.NamespaceDefault, "plain")
+			require.NoError(t, err)
+			assert.Nil(t, marked)
+
+			_, err = registry.GetPod(ctx, api.NamespaceDefault, "plain")
+			assert.ErrorIs(t, err, ErrPodNotFound)
+
+			// The pod is deleted without being marked first
+			watchCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			events, err := registry.WatchPods(watchCtx, revision)
+			require.NoError(t, err)
+			select {
+			case event := <-events:
+				assert.Equal(t, storage.EventDelete, event.Type)
+				assert.Contains(t, event.Key, "plain")
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for the delete event")
+			}
+		})
+
+		t.Run("should mark a pod that gains a finalizer before it is deleted", func(t *testing.T) {
+			inner := storage.NewEtcdStorage(etcdServer)
+			racing := &finalizerRacingStorage{Storage: inner}
+			registry := NewPodRegistry(racing)
+			require.NoError(t, registry.CreatePod(ctx, newPod("raced")))
+
+			marked, err := registry.MarkPodForDeletion(ctx, api.NamespaceDefault, "raced")
+			require.NoError(t, err)
+			require.NotNil(t, marked)
+			assert.NotNil(t, marked.DeletionTimestamp)
+			assert.Equal(t, []string{"example.com/raced"}, marked.Finalizers)
+
+			stored, err := registry.GetPod(ctx, api.NamespaceDefault, "raced")
+			require.NoError(t, err)
+			assert.NotNil(t, stored.DeletionTimestamp)
+		})
+
+		t.Run("should return not found for a missing pod", func(t *testing.T) {
+			_, err := registry.MarkPodForDeletion(ctx, api.NamespaceDefault, "missing")
+			assert.ErrorIs(t, err, ErrPodNotFound)
+		})
+
+		t.Run("should delete a pod regardless of its finalizers", func(t *testing.T) {
+			require.NoError(t, registry.CreatePod(ctx, newPod("stuck", "example.com/stuck")))
+
+			require.NoError(t, registry.DeletePod(ctx, api.NamespaceDefault, "stuck"))
+
+			_, err := registry.GetPod(ctx, api.NamespaceDefault, "stuck")
+			assert.ErrorIs(t, err, ErrPodNotFound)
+		})
 	})
 }
 
@@ -344,7 +557,7 @@ func TestPodRegistry_ListPods(t *testing.T) {
 				},
 				Replicas: 3,
 			},
-			Status: api.PodPending,
+			Status: api.PodStatus{Phase: api.PodPending},
 		}
 
 		pod2 := &api.Pod{
@@ -360,7 +573,7 @@ func TestPodRegistry_ListPods(t *testing.T) {
 				},
 				Replicas: 3,
 			},
-			Status: api.PodRunning,
+			Status: api.PodStatus{Phase: api.PodRunning},
 		}
 
 		err := registry.CreatePod(ctx, pod1)
@@ -395,6 +608,53 @@ func TestPodRegistry_ListPods(t *testing.T) {
 	})
 }
 
+func TestPodRegistry_Namespaces(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+
+		newPod := func(namespace, image string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "web", Namespace: namespace},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: image}}},
+			}
+		}
+
+		// Pods of the same name in different namespaces don't collide
+		require.NoError(t, registry.CreatePod(ctx, newPod("", "nginx:1")))
+		require.NoError(t, registry.CreatePod(ctx, newPod("team-a", "nginx:2")))
+		err := registry.CreatePod(ctx, newPod("team-a", "nginx:3"))
+		assert.ErrorIs(t, err, ErrPodAlreadyExists)
+
+		pod, err := registry.GetPod(ctx, api.NamespaceDefault, "web")
+		require.NoError(t, err)
+		assert.Equal(t, api.NamespaceDefault, pod.Namespace)
+		assert.Equal(t, "docker.io/library/nginx:1", pod.Spec.Containers[0].Image)
+
+		pod, err = registry.GetPod(ctx, "team-a", "web")
+		require.NoError(t, err)
+		assert.Equal(t, "docker.io/library/nginx:2", pod.Spec.Containers[0].Image)
+
+		_, err = registry.GetPod(ctx, "team-b", "web")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+
+		pods, err := registry.ListPodsInNamespace(ctx, "team-a")
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, "team-a", pods[0].Namespace)
+
+		pods, err = registry.ListPodsInNamespace(ctx, api.NamespaceAll)
+		require.NoError(t, err)
+		assert.Len(t, pods, 2)
+
+		require.NoError(t, registry.DeletePod(ctx, "team-a", "web"))
+		pods, err = registry.ListPods(ctx)
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, api.NamespaceDefault, pods[0].Namespace)
+	})
+}
+
 func TestPodRegistry_ListPendingPods(t *testing.T) {
 	t.Run("should list pending pods", func(t *testing.T) {
 		testCases := []struct {
@@ -407,10 +667,10 @@ func TestPodRegistry_ListPendingPods(t *testing.T) {
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod1"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Status: api.PodStatus{Phase: api.PodRunning}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod2"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Status: api.PodStatus{Phase: api.PodRunning}},
 				},
 				expectedPendingPods: 0,
 			},
@@ -419,13 +679,13 @@ func TestPodRegistry_ListPendingPods(t *testing.T) {
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod3"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod4"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Status: api.PodStatus{Phase: api.PodRunning}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod5"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 				},
 				expectedPendingPods: 2,
 			},
@@ -434,10 +694,10 @@ func TestPodRegistry_ListPendingPods(t *testing.T) {
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod6"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod7"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 				},
 				expectedPendingPods: 2,
 			},
@@ -496,10 +756,10 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod1"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Status: api.PodStatus{Phase: api.PodRunning}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod2"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Status: api.PodStatus{Phase: api.PodRunning}},
 				},
 				expectedUnassignedPods: 0,
 			},
@@ -508,13 +768,13 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod3"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod4"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Status: api.PodStatus{Phase: api.PodRunning}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod5"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 				},
 				expectedUnassignedPods: 2,
 			},
@@ -523,10 +783,10 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod6"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 					{ObjectMeta: api.ObjectMeta{Name: "pod7"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodPending},
+						Status: api.PodStatus{Phase: api.PodPending}},
 				},
 				expectedUnassignedPods: 2,
 			},
@@ -572,3 +832,284 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 		assert.Nil(t, pods, "Expected nil list of pods")
 	})
 }
+
+func TestPodRegistry_BindPod(t *testing.T) {
+	t.Run("should bind pending pod to node", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			}
+			require.NoError(t, registry.CreatePod(ctx, pod))
+
+			boundPod, err := registry.BindPod(ctx, api.NamespaceDefault, "test-pod", "node-1")
+			require.NoError(t, err)
+			assert.Equal(t, "node-1", boundPod.NodeName)
+			assert.True(t, conditions.IsConditionTrue(boundPod.Status.Conditions, api.PodConditionScheduled))
+
+			retrievedPod, err := registry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err)
+			assert.Equal(t, "node-1", retrievedPod.NodeName)
+			assert.True(t, conditions.IsConditionTrue(retrievedPod.Status.Conditions, api.PodConditionScheduled))
+		})
+	})
+
+	t.Run("should fail to bind pod that is already bound", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			}
+			require.NoError(t, registry.CreatePod(ctx, pod))
+
+			_, err := registry.BindPod(ctx, api.NamespaceDefault, "test-pod", "node-1")
+			require.NoError(t, err)
+
+			_, err = registry.BindPod(ctx, api.NamespaceDefault, "test-pod", "node-2")
+			assert.ErrorIs(t, err, ErrPodAlreadyBound)
+
+			retrievedPod, err := registry.GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err)
+			assert.Equal(t, "node-1", retrievedPod.NodeName)
+		})
+	})
+
+	t.Run("should return error if pod does not exist", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+
+			_, err := registry.BindPod(context.Background(), api.NamespaceDefault, "non-existent-pod", "node-1")
+			assert.ErrorIs(t, err, ErrPodNotFound)
+		})
+	})
+
+	t.Run("should let exactly one of two concurrent binds win", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			}
+			require.NoError(t, NewPodRegistry(etcdStorage).CreatePod(ctx, pod))
+
+			// Each binder has its own registry, like two scheduler instances would
+			nodes := []string{"node-1", "node-2"}
+			errs := make([]error, len(nodes))
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i, node := range nodes {
+				wg.Add(1)
+				go func(i int, node string) {
+					defer wg.Done()
+					registry := NewPodRegistry(etcdStorage)
+					<-start
+					_, errs[i] = registry.BindPod(ctx, api.NamespaceDefault, "test-pod", node)
+				}(i, node)
+			}
+			close(start)
+			wg.Wait()
+
+			winner := ""
+			for i, err := range errs {
+				if err == nil {
+					assert.Empty(t, winner, "more than one bind succeeded")
+					winner = nodes[i]
+					continue
+				}
+				assert.ErrorIs(t, err, ErrPodAlreadyBound)
+			}
+			require.NotEmpty(t, winner, "no bind succeeded")
+
+			retrievedPod, err := NewPodRegistry(etcdStorage).GetPod(ctx, api.NamespaceDefault, "test-pod")
+			require.NoError(t, err)
+			assert.Equal(t, winner, retrievedPod.NodeName)
+			assert.True(t, conditions.IsConditionTrue(retrievedPod.Status.Conditions, api.PodConditionScheduled))
+		})
+	})
+}
+
+func TestPodRegistry_ReschedulePod(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+		}
+		require.NoError(t, registry.CreatePod(ctx, pod))
+		_, err := registry.BindPod(ctx, api.NamespaceDefault, "test-pod", "node-1")
+		require.NoError(t, err)
+
+		// A pod bound to another node is left alone
+		_, err = registry.ReschedulePod(ctx, api.NamespaceDefault, "test-pod", "node-2", "NodeNotReady", "node-2 is not ready")
+		assert.ErrorIs(t, err, ErrPodNotOnNode)
+
+		rescheduled, err := registry.ReschedulePod(ctx, api.NamespaceDefault, "test-pod", "node-1", "NodeNotReady", "node-1 is not ready")
+		require.NoError(t, err)
+		assert.Empty(t, rescheduled.NodeName)
+		assert.Equal(t, api.PodPending, rescheduled.Status.Phase)
+		scheduled := conditions.GetCondition(rescheduled.Status.Conditions, api.PodConditionScheduled)
+		require.NotNil(t, scheduled)
+		assert.Equal(t, api.ConditionFalse, scheduled.Status)
+		assert.Equal(t, "NodeNotReady", scheduled.Reason)
+
+		// The pod can be bound again
+		_, err = registry.BindPod(ctx, api.NamespaceDefault, "test-pod", "node-2")
+		require.NoError(t, err)
+
+		_, err = registry.ReschedulePod(ctx, api.NamespaceDefault, "missing", "node-1", "NodeNotReady", "")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+	})
+}
+
+func TestPodRegistry_ListPodsByNode(t *testing.T) {
+	t.Run("should list pods bound to the node", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+			ctx := context.Background()
+
+			for _, name := range []string{"pod-1", "pod-2"} {
+				require.NoError(t, registry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				}))
+			}
+
+			pods, err := registry.ListPodsByNode(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Empty(t, pods)
+
+			_, err = registry.BindPod(ctx, api.NamespaceDefault, "pod-2", "node-1")
+			require.NoError(t, err)
+
+			pods, err = registry.ListPodsByNode(ctx, "node-1")
+			require.NoError(t, err)
+			require.Len(t, pods, 1)
+			assert.Equal(t, "pod-2", pods[0].Name)
+			assert.True(t, conditions.IsConditionTrue(pods[0].Status.Conditions, api.PodConditionScheduled))
+		})
+	})
+
+	t.Run("should filter listed pods when storage has no indexes", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStore := mockStorage.NewMockStorage(ctrl)
+		registry := NewPodRegistry(mockStore)
+
+		mockStore.EXPECT().List(gomock.Any(), podPrefix, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, listObj interface{}) error {
+				*listObj.(*[]*api.Pod) = []*api.Pod{
+					{ObjectMeta: api.ObjectMeta{Name: "pod-1"}, NodeName: "node-1"},
+					{ObjectMeta: api.ObjectMeta{Name: "pod-2"}, NodeName: "node-2"},
+				}
+				return nil
+			})
+
+		pods, err := registry.ListPodsByNode(context.Background(), "node-1")
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, "pod-1", pods[0].Name)
+	})
+}
+
+func TestPodRegistry_ListPodsByOwner(t *testing.T) {
+	t.Run("should follow adoption, release and deletion", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+			ctx := context.Background()
+
+			ownerA := api.OwnerReference{Kind: api.KindReplicaSet, Name: "rs-a", UID: "uid-a", Controller: true}
+			ownerB := api.OwnerReference{Kind: api.KindReplicaSet, Name: "rs-b", UID: "uid-b", Controller: true}
+
+			podNames := func(pods []*api.Pod) []string {
+				names := make([]string, 0, len(pods))
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+				return names
+			}
+			listOwned := func(uid string) []string {
+				pods, err := registry.ListPodsByOwner(ctx, uid)
+				require.NoError(t, err)
+				return podNames(pods)
+			}
+
+			require.NoError(t, registry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "pod-1", OwnerReferences: []api.OwnerReference{ownerA}},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			}))
+			require.NoError(t, registry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "pod-2"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			}))
+
+			assert.ElementsMatch(t, []string{"pod-1"}, listOwned("uid-a"))
+
+			// Adoption adds the pod to the owner's index
+			_, err := registry.SetControllerRef(ctx, api.NamespaceDefault, "pod-2", &ownerA)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"pod-1", "pod-2"}, listOwned("uid-a"))
+
+			// Another controller can't take an owned pod
+			_, err = registry.SetControllerRef(ctx, api.NamespaceDefault, "pod-2", &ownerB)
+			assert.ErrorIs(t, err, ErrPodAlreadyOwned)
+
+			// Releasing and re-adopting moves the pod between owners
+			_, err = registry.SetControllerRef(ctx, api.NamespaceDefault, "pod-2", nil)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"pod-1"}, listOwned("uid-a"))
+			_, err = registry.SetControllerRef(ctx, api.NamespaceDefault, "pod-2", &ownerB)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"pod-2"}, listOwned("uid-b"))
+
+			// Deleting a pod removes it from the index
+			require.NoError(t, registry.DeletePod(ctx, api.NamespaceDefault, "pod-1"))
+			assert.Empty(t, listOwned("uid-a"))
+			assert.ElementsMatch(t, []string{"pod-2"}, listOwned("uid-b"))
+		})
+	})
+
+	t.Run("should filter listed pods when storage has no indexes", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStore := mockStorage.NewMockStorage(ctrl)
+		registry := NewPodRegistry(mockStore)
+
+		mockStore.EXPECT().List(gomock.Any(), podPrefix, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, listObj interface{}) error {
+				*listObj.(*[]*api.Pod) = []*api.Pod{
+					{ObjectMeta: api.ObjectMeta{Name: "pod-1", OwnerReferences: []api.OwnerReference{{Kind: api.KindReplicaSet, Name: "rs", UID: "uid-1", Controller: true}}}},
+					{ObjectMeta: api.ObjectMeta{Name: "pod-2"}},
+				}
+				return nil
+			})
+
+		pods, err := registry.ListPodsByOwner(context.Background(), "uid-1")
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, "pod-1", pods[0].Name)
+	})
+
+	t.Run("should reject an empty owner UID", func(t *testing.T) {
+		registry := NewPodRegistry(nil)
+
+		_, err := registry.ListPodsByOwner(context.Background(), "")
+		assert.ErrorIs(t, err, ErrListPodsFailed)
+	})
+}
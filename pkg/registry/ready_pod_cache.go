@@ -0,0 +1,198 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// ReadyPodCache keeps the ready pods, indexed by label, up to date by watching pods, so that
+// the pods matching a label selector are looked up without listing pods. Pods that aren't
+// ready or are terminating never receive traffic, so they are left out.
+//
+// The cache is filled by a single list and then updated incrementally from the watch.
+type ReadyPodCache struct {
+	podRegistry *PodRegistry
+
+	mutex sync.RWMutex
+	// ready holds the ready pods by namespace/name
+	ready map[string]*api.Pod
+	// byLabel holds the namespace/name of the ready pods by label, as key=value
+	byLabel map[string]map[string]struct{}
+	synced  atomic.Bool
+}
+
+// NewReadyPodCache creates a ReadyPodCache of the pods of the registry. It is empty until Run
+// has listed the pods.
+func NewReadyPodCache(podRegistry *PodRegistry) *ReadyPodCache {
+	return &ReadyPodCache{
+		podRegistry: podRegistry,
+		ready:       make(map[string]*api.Pod),
+		byLabel:     make(map[string]map[string]struct{}),
+	}
+}
+
+// EndpointsFor returns the endpoints of the selector: the names of the ready pods of the
+// namespace, or of all namespaces for NamespaceAll, that match it, sorted. Only the pods
+// having the least common label of the selector are checked.
+func (c *ReadyPodCache) EndpointsFor(namespace string, selector map[string]string) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var candidates map[string]struct{}
+	for key, value := range selector {
+		keys := c.byLabel[labelIndexKey(key, value)]
+		if candidates == nil || len(keys) < len(candidates) {
+			candidates = keys
+		}
+		if len(candidates) == 0 {
+			return []string{}
+		}
+	}
+
+	names := make([]string, 0)
+	add := func(pod *api.Pod) {
+		if (namespace == api.NamespaceAll || pod.Namespace == namespace) && api.MatchesSelector(selector, pod.Labels) {
+			names = append(names, pod.Name)
+		}
+	}
+	if candidates == nil {
+		// An empty selector matches every pod
+		for _, pod := range c.ready {
+			add(pod)
+		}
+	} else {
+		for key := range candidates {
+			add(c.ready[key])
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// HasSynced reports whether the pods have been listed into the cache
+func (c *ReadyPodCache) HasSynced() bool {
+	return c.synced.Load()
+}
+
+// Run syncs the cache with the current pods, then updates it as pods change until the
+// context is done. A closed watch is resumed by listing the pods again.
+func (c *ReadyPodCache) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		pods, revision, err := c.podRegistry.ListPodsWithRevision(ctx, api.NamespaceAll)
+		if err != nil {
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+		// Watching from the revision of the list misses no change made since
+		events, err := c.podRegistry.WatchPods(ctx, revision)
+		if err != nil {
+			return fmt.Errorf("failed to watch pods: %w", err)
+		}
+		c.replace(pods)
+
+		for event := range events {
+			c.handleEvent(event)
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *ReadyPodCache) handleEvent(event storage.WatchEvent) {
+	if event.Type == storage.EventError {
+		// The watch ends with the error, the pods are listed again
+		log.Printf("Pod watch failed, relisting: %v", event.Err)
+		return
+	}
+
+	pod := &api.Pod{}
+	if err := event.Decode(pod); err != nil {
+		log.Printf("Error decoding pod event: %v", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if event.Type == storage.EventDelete {
+		c.remove(readyPodKey(pod))
+		return
+	}
+	c.update(pod)
+}
+
+// replace resets the cache to the listed pods
+func (c *ReadyPodCache) replace(pods []*api.Pod) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	listed := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		listed[readyPodKey(pod)] = true
+		c.update(pod)
+	}
+	// Forget the pods deleted while the watch was down
+	for key := range c.ready {
+		if !listed[key] {
+			c.remove(key)
+		}
+	}
+	c.synced.Store(true)
+}
+
+// update adds the pod to the cache if it is ready, and removes it otherwise. The caller
+// holds the lock.
+func (c *ReadyPodCache) update(pod *api.Pod) {
+	key := readyPodKey(pod)
+	if !pod.IsReady() {
+		c.remove(key)
+		return
+	}
+
+	if old, ok := c.ready[key]; ok {
+		c.unindex(key, old)
+	}
+	c.ready[key] = pod
+	for label, value := range pod.Labels {
+		indexKey := labelIndexKey(label, value)
+		if c.byLabel[indexKey] == nil {
+			c.byLabel[indexKey] = make(map[string]struct{})
+		}
+		c.byLabel[indexKey][key] = struct{}{}
+	}
+}
+
+// remove removes the pod from the cache. The caller holds the lock.
+func (c *ReadyPodCache) remove(key string) {
+	pod, ok := c.ready[key]
+	if !ok {
+		return
+	}
+	c.unindex(key, pod)
+	delete(c.ready, key)
+}
+
+// unindex removes the pod from the label index. The caller holds the lock.
+func (c *ReadyPodCache) unindex(key string, pod *api.Pod) {
+	for label, value := range pod.Labels {
+		indexKey := labelIndexKey(label, value)
+		delete(c.byLabel[indexKey], key)
+		if len(c.byLabel[indexKey]) == 0 {
+			delete(c.byLabel, indexKey)
+		}
+	}
+}
+
+// labelIndexKey returns the key of a label in the label index
+func labelIndexKey(key, value string) string {
+	return key + "=" + value
+}
+
+// readyPodKey returns the namespace/name of the pod, unique across namespaces
+func readyPodKey(pod *api.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func setPodReady(t *testing.T, podRegistry *PodRegistry, name string, status api.ConditionStatus) {
+	t.Helper()
+	pod, err := podRegistry.GetPod(context.Background(), api.NamespaceDefault, name)
+	require.NoError(t, err)
+	pod.Status.Conditions = []api.Condition{{Type: api.PodConditionReady, Status: status}}
+	_, err = podRegistry.UpdatePodStatus(context.Background(), pod)
+	require.NoError(t, err)
+}
+
+func TestReadyPodCache_EndpointsFor(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := &countingStorage{Storage: storage.NewEtcdStorage(etcdServer)}
+		podRegistry := NewPodRegistry(store)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for name, labels := range map[string]map[string]string{
+			"web-1": {"app": "web", "tier": "frontend"},
+			"web-2": {"app": "web", "tier": "frontend"},
+			"api-1": {"app": "api", "tier": "backend"},
+		} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name, Labels: labels},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+			}))
+			setPodReady(t, podRegistry, name, api.ConditionTrue)
+		}
+
+		cache := NewReadyPodCache(podRegistry)
+		assert.False(t, cache.HasSynced())
+		go func() { _ = cache.Run(ctx) }()
+		require.Eventually(t, cache.HasSynced, 2*time.Second, 10*time.Millisecond)
+		lists := store.lists.Load()
+
+		assert.Equal(t, []string{"web-1", "web-2"}, cache.EndpointsFor(api.NamespaceAll, map[string]string{"tier": "frontend"}))
+		assert.Equal(t, []string{"api-1"}, cache.EndpointsFor(api.NamespaceDefault, map[string]string{"app": "api", "tier": "backend"}))
+		assert.Empty(t, cache.EndpointsFor(api.NamespaceAll, map[string]string{"app": "api", "tier": "frontend"}))
+		assert.Empty(t, cache.EndpointsFor("other", map[string]string{"app": "web"}))
+		assert.Equal(t, []string{"api-1", "web-1", "web-2"}, cache.EndpointsFor(api.NamespaceAll, nil))
+
+		// Readiness changes are picked up from the watch
+		setPodReady(t, podRegistry, "web-2", api.ConditionFalse)
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]string{"web-1"}, cache.EndpointsFor(api.NamespaceAll, map[string]string{"app": "web"}))
+		}, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, podRegistry.DeletePod(ctx, api.NamespaceDefault, "web-1"))
+		assert.Eventually(t, func() bool {
+			return len(cache.EndpointsFor(api.NamespaceAll, map[string]string{"app": "web"})) == 0
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, lists, store.lists.Load(), "endpoint lookups should not list pods")
+	})
+}
+
+func TestReadyPodCache_RunStopsWithTheContext(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		cache := NewReadyPodCache(NewPodRegistry(storage.NewEtcdStorage(etcdServer)))
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error, 1)
+		go func() { done <- cache.Run(ctx) }()
+		require.Eventually(t, cache.HasSynced, 2*time.Second, 10*time.Millisecond)
+
+		cancel()
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Run did not return after the context was done")
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"gokube/pkg/api"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 )
 
@@ -16,18 +17,22 @@ const (
 
 var (
 	ErrReplicaSetExists   = errors.New("replicaset already exists")
-	ErrReplicaSetNotFound = errors.New("replicaset not found")
+	ErrReplicaSetNotFound = fmt.Errorf("replicaset %w", ErrNotFound)
 	ErrListReplicaSets    = errors.New("error listing replicasets")
+	ErrReplicaSetInvalid  = errors.New("invalid replicaset")
+	ErrReplicaSetConflict = fmt.Errorf("replicaset %w", ErrConflict)
 )
 
 type ReplicaSetRegistry struct {
-	storage storage.Storage
-	mutex   sync.RWMutex
+	storage   storage.Storage
+	mutex     sync.RWMutex
+	admission admission[*api.ReplicaSet]
 }
 
 func NewReplicaSetRegistry(storage storage.Storage) *ReplicaSetRegistry {
 	return &ReplicaSetRegistry{
-		storage: storage,
+		storage:   storage,
+		admission: newReplicaSetAdmission(),
 	}
 }
 
@@ -39,6 +44,10 @@ func (r *ReplicaSetRegistry) Create(ctx context.Context, rs *api.ReplicaSet) err
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if err := r.admission.admit(rs, operationCreate); err != nil {
+		return fmt.Errorf("%w: %v", ErrReplicaSetInvalid, err)
+	}
+
 	key := r.generateKey(rs.Name)
 
 	// Check if ReplicaSet already exists
@@ -58,16 +67,58 @@ func (r *ReplicaSetRegistry) Get(ctx context.Context, name string) (*api.Replica
 	key := r.generateKey(name)
 	rs := &api.ReplicaSet{}
 	if err := r.storage.Get(ctx, key, rs); err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, name)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get replicaset: %v", ErrInternal, err)
+		}
 	}
 
 	return rs, nil
 }
 
+// Update replaces a ReplicaSet, whatever the resource version of the stored ReplicaSet
 func (r *ReplicaSetRegistry) Update(ctx context.Context, rs *api.ReplicaSet) error {
+	return r.update(ctx, rs, 0)
+}
+
+// UpdateAtRevision is Update that only replaces the ReplicaSet if it is still at the resource
+// version, ErrReplicaSetConflict is returned if it was modified since
+func (r *ReplicaSetRegistry) UpdateAtRevision(ctx context.Context, rs *api.ReplicaSet, revision int64) error {
+	return r.update(ctx, rs, revision)
+}
+
+// UpdateStatus replaces the status of the stored ReplicaSet, leaving the rest of it as stored, so
+// that a status write never reverts a concurrent change of the spec. It returns the updated
+// ReplicaSet.
+func (r *ReplicaSetRegistry) UpdateStatus(ctx context.Context, name string, status api.ReplicaSetStatus) (*api.ReplicaSet, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	updated := &api.ReplicaSet{}
+	err := r.storage.GuaranteedUpdate(ctx, r.generateKey(name), updated, func(obj runtime.Object) error {
+		obj.(*api.ReplicaSet).Status = status
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, name)
+		}
+		return nil, fmt.Errorf("%w: failed to update replicaset status: %v", ErrInternal, err)
+	}
+	return updated, nil
+}
+
+// update replaces the ReplicaSet, only if it is at revision unless revision is 0
+func (r *ReplicaSetRegistry) update(ctx context.Context, rs *api.ReplicaSet, revision int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.admission.admit(rs, operationUpdate); err != nil {
+		return fmt.Errorf("%w: %v", ErrReplicaSetInvalid, err)
+	}
+
 	key := r.generateKey(rs.Name)
 
 	// Check if ReplicaSet exists
@@ -76,8 +127,19 @@ func (r *ReplicaSetRegistry) Update(ctx context.Context, rs *api.ReplicaSet) err
 		return fmt.Errorf("%w: %s", ErrReplicaSetNotFound, rs.Name)
 	}
 
-	// Update the ReplicaSet
-	return r.storage.Update(ctx, key, rs)
+	var err error
+	if revision != 0 {
+		err = r.storage.UpdateAtRevision(ctx, key, rs, revision)
+	} else {
+		err = r.storage.Update(ctx, key, rs)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return fmt.Errorf("%w: %v", ErrReplicaSetConflict, err)
+		}
+		return err
+	}
+	return nil
 }
 
 func (r *ReplicaSetRegistry) Delete(ctx context.Context, name string) error {
@@ -88,6 +150,16 @@ func (r *ReplicaSetRegistry) Delete(ctx context.Context, name string) error {
 	return r.storage.Delete(ctx, key)
 }
 
+// DeleteAtRevision removes the ReplicaSet if it is still at the resource version,
+// ErrReplicaSetConflict is returned if it was modified since
+func (r *ReplicaSetRegistry) DeleteAtRevision(ctx context.Context, name string, revision int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := r.generateKey(name)
+	return deleteAtRevisionError(r.storage.DeleteAtRevision(ctx, key, revision), ErrReplicaSetNotFound, ErrReplicaSetConflict)
+}
+
 func (r *ReplicaSetRegistry) List(ctx context.Context) ([]*api.ReplicaSet, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
@@ -100,3 +172,46 @@ func (r *ReplicaSetRegistry) List(ctx context.Context) ([]*api.ReplicaSet, error
 
 	return replicaSets, nil
 }
+
+// ListPaged retrieves at most limit ReplicaSets, continuing the listing the continue token was
+// returned for. It also returns the token that continues the listing, which is empty once all
+// ReplicaSets were listed.
+func (r *ReplicaSetRegistry) ListPaged(ctx context.Context, limit int64, continueToken string) ([]*api.ReplicaSet, string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var replicaSets []*api.ReplicaSet
+	next, err := r.storage.ListPaged(ctx, replicaSetPrefix+"/", limit, continueToken, &replicaSets)
+	if err != nil {
+		return nil, "", listPagedError(err, ErrListReplicaSets)
+	}
+
+	return replicaSets, next, nil
+}
+
+// ListWithRevision lists all ReplicaSets like List, and also returns the revision they were
+// listed at. Watching from that revision misses no change.
+func (r *ReplicaSetRegistry) ListWithRevision(ctx context.Context) ([]*api.ReplicaSet, int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	replicaSets := make([]*api.ReplicaSet, 0)
+	_, revision, err := r.storage.ListWithMeta(ctx, replicaSetPrefix+"/", &replicaSets)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListReplicaSets, err)
+	}
+
+	return replicaSets, revision, nil
+}
+
+// CurrentRevision returns the latest resource version, watching ReplicaSets from it misses no
+// later change
+func (r *ReplicaSetRegistry) CurrentRevision(ctx context.Context) (int64, error) {
+	return r.storage.CurrentRevision(ctx)
+}
+
+// Watch streams changes to ReplicaSets made after the given resource version.
+// A resource version of 0 watches for changes from now on.
+func (r *ReplicaSetRegistry) Watch(ctx context.Context, resourceVersion int64) (<-chan storage.WatchEvent, error) {
+	return r.storage.WatchFromRevision(ctx, replicaSetPrefix+"/", resourceVersion, storage.DefaultWatchOptions())
+}
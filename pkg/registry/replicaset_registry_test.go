@@ -27,6 +27,7 @@ func createTestReplicaSet(name string, replicas int32, image string) *api.Replic
 				Spec: api.PodSpec{
 					Containers: []api.Container{
 						{
+							Name:  "test-container",
 							Image: image,
 						},
 					},
@@ -100,6 +101,21 @@ func TestReplicaSetRegistry_Get(t *testing.T) {
 			assert.ErrorIs(t, err, ErrReplicaSetNotFound, "Expected ErrReplicaSetNotFound error")
 		})
 	})
+	t.Run("should return ErrInternal on storage error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mStorage := mockStorage.NewMockStorage(ctrl)
+		registry := NewReplicaSetRegistry(mStorage)
+		ctx := context.Background()
+
+		mStorage.EXPECT().Get(ctx, gomock.Any(), gomock.Any()).Return(errors.New("storage error"))
+
+		rs, err := registry.Get(ctx, "test-replicaset")
+		assert.Nil(t, rs)
+		assert.ErrorIs(t, err, ErrInternal)
+		assert.NotErrorIs(t, err, ErrReplicaSetNotFound)
+	})
 }
 
 func TestReplicaSetRegistry_Update(t *testing.T) {
@@ -142,6 +158,28 @@ func TestReplicaSetRegistry_Update(t *testing.T) {
 	})
 }
 
+func TestReplicaSetRegistry_UpdateStatus(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		ctx := context.Background()
+		registry := NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+		require.NoError(t, registry.Create(ctx, createTestReplicaSet("test-replicaset", 3, "nginx:latest")))
+
+		t.Run("should keep the spec as stored", func(t *testing.T) {
+			require.NoError(t, registry.Update(ctx, createTestReplicaSet("test-replicaset", 5, "nginx:latest")))
+
+			updated, err := registry.UpdateStatus(ctx, "test-replicaset", api.ReplicaSetStatus{Replicas: 3})
+			require.NoError(t, err)
+			assert.Equal(t, int32(5), updated.Spec.Replicas)
+			assert.Equal(t, int32(3), updated.Status.Replicas)
+		})
+
+		t.Run("should return not found for a missing ReplicaSet", func(t *testing.T) {
+			_, err := registry.UpdateStatus(ctx, "missing", api.ReplicaSetStatus{})
+			assert.ErrorIs(t, err, ErrReplicaSetNotFound)
+		})
+	})
+}
+
 func TestReplicaSetRegistry_List(t *testing.T) {
 	t.Run("should list all ReplicaSets", func(t *testing.T) {
 		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
@@ -1,5 +1,47 @@
 package registry
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"gokube/pkg/storage"
+)
 
 var ErrInternal = errors.New("internal error")
+
+// ErrNotFound is wrapped by the not found error of every resource, so callers can detect a
+// missing object with errors.Is regardless of its kind
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is wrapped by the conflict error of every resource. It is returned when an object
+// is updated from a resource version that is no longer current, the caller should read the
+// object again and retry.
+var ErrConflict = errors.New("conflict")
+
+// deleteAtRevisionError wraps the error of a delete at a resource version in the not found or
+// conflict error of the resource
+func deleteAtRevisionError(err, notFoundErr, conflictErr error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrNotFound):
+		return fmt.Errorf("%w: %v", notFoundErr, err)
+	case errors.Is(err, storage.ErrConflict):
+		return fmt.Errorf("%w: %v", conflictErr, err)
+	default:
+		return err
+	}
+}
+
+// ErrInvalidContinueToken is returned when a paged list is continued with a token that wasn't
+// returned for that list
+var ErrInvalidContinueToken = errors.New("invalid continue token")
+
+// listPagedError wraps the error of a paged list in listErr, unless the continue token was
+// invalid, which is a mistake of the caller rather than a failure of the list
+func listPagedError(err, listErr error) error {
+	if errors.Is(err, storage.ErrInvalidContinueToken) {
+		return fmt.Errorf("%w: %v", ErrInvalidContinueToken, err)
+	}
+	return fmt.Errorf("%w: %v", listErr, err)
+}
@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func TestGetMissingObjectReturnsNotFound(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		podRegistry := NewPodRegistry(etcdStorage)
+		nodeRegistry := NewNodeRegistry(etcdStorage)
+		replicaSetRegistry := NewReplicaSetRegistry(etcdStorage)
+		ctx := context.Background()
+
+		testCases := []struct {
+			name     string
+			get      func() error
+			notFound error
+		}{
+			{
+				name: "pod",
+				get: func() error {
+					pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "missing")
+					assert.Nil(t, pod)
+					return err
+				},
+				notFound: ErrPodNotFound,
+			},
+			{
+				name: "node",
+				get: func() error {
+					node, err := nodeRegistry.GetNode(ctx, "missing")
+					assert.Nil(t, node)
+					return err
+				},
+				notFound: ErrNodeNotFound,
+			},
+			{
+				name: "replicaset",
+				get: func() error {
+					rs, err := replicaSetRegistry.Get(ctx, "missing")
+					assert.Nil(t, rs)
+					return err
+				},
+				notFound: ErrReplicaSetNotFound,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				err := tc.get()
+				assert.True(t, errors.Is(err, tc.notFound), "expected %v, got %v", tc.notFound, err)
+				assert.True(t, errors.Is(err, ErrNotFound), "expected %v, got %v", ErrNotFound, err)
+				assert.False(t, errors.Is(err, ErrInternal))
+			})
+		}
+	})
+}
@@ -2,14 +2,42 @@ package retry
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"time"
 )
 
+// ErrRetryExhausted is returned, wrapping the error of the last attempt, once an operation
+// failed MaxAttempts times or for longer than MaxElapsedTime
+var ErrRetryExhausted = errors.New("retries exhausted")
+
 // Options configures the retry behavior
 type Options struct {
 	InitialDelay time.Duration
 	MaxDelay     time.Duration
 	Multiplier   float64
+	// ResetAfter resets the delay to InitialDelay when a failed attempt ran for at least this
+	// long, so that a long-running operation that fails again doesn't inherit the delay of an
+	// earlier, unrelated failure. Zero never resets the delay.
+	ResetAfter time.Duration
+	// Jitter randomizes every delay by up to this fraction of it in either direction, so that
+	// clients failing together don't retry in lockstep. It is between 0 and 1, zero waits
+	// exactly the backed off delay.
+	Jitter float64
+	// MaxAttempts is how many times the operation is attempted before giving up. Zero attempts
+	// it until the context is done.
+	MaxAttempts int
+	// MaxElapsedTime is how long the operation is retried for before giving up, no attempt is
+	// started after it passed. Zero retries it until the context is done.
+	MaxElapsedTime time.Duration
+	// IsRetryable reports whether the operation is retried after failing with the error. An
+	// error it rejects is returned right away. Nil retries every error.
+	IsRetryable func(err error) bool
+	// Now and After are the clock the delays are measured and waited with. Nil uses the time
+	// package.
+	Now   func() time.Time
+	After func(d time.Duration) <-chan time.Time
 }
 
 // DefaultOptions returns the default retry configuration
@@ -21,20 +49,48 @@ func DefaultOptions() Options {
 	}
 }
 
-// WithExponentialBackoff executes the given operation with exponential backoff
+// WithExponentialBackoff executes the given operation with exponential backoff. Once MaxAttempts
+// or MaxElapsedTime is exceeded it gives up, returning ErrRetryExhausted. An error IsRetryable
+// rejects is returned as is.
 func WithExponentialBackoff(ctx context.Context, opts Options, operation func(context.Context) error) error {
+	now, after := time.Now, time.After
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	if opts.After != nil {
+		after = opts.After
+	}
+
 	currentDelay := opts.InitialDelay
+	begin := now()
 
-	for {
+	for attempt := 1; ; attempt++ {
+		start := now()
 		err := operation(ctx)
 		if err == nil {
 			return nil
 		}
+		if opts.IsRetryable != nil && !opts.IsRetryable(err) {
+			return err
+		}
+		if opts.MaxAttempts > 0 && attempt >= opts.MaxAttempts {
+			return fmt.Errorf("%w: %d attempts failed: %w", ErrRetryExhausted, attempt, err)
+		}
+
+		if opts.ResetAfter > 0 && now().Sub(start) >= opts.ResetAfter {
+			currentDelay = opts.InitialDelay
+		}
+
+		// Don't wait for an attempt that would start after MaxElapsedTime
+		delay := jittered(currentDelay, opts.Jitter)
+		if elapsed := now().Sub(begin); opts.MaxElapsedTime > 0 && elapsed+delay > opts.MaxElapsedTime {
+			return fmt.Errorf("%w: failed for %v: %w", ErrRetryExhausted, elapsed, err)
+		}
 
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(currentDelay):
+		case <-after(delay):
 			// Calculate next delay with exponential backoff
 			nextDelay := time.Duration(float64(currentDelay) * opts.Multiplier)
 			if nextDelay > opts.MaxDelay {
@@ -45,6 +101,15 @@ func WithExponentialBackoff(ctx context.Context, opts Options, operation func(co
 	}
 }
 
+// jittered returns the delay randomized by up to the jitter fraction of it in either direction
+func jittered(delay time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return delay
+	}
+	jitter = min(jitter, 1)
+	return time.Duration(float64(delay) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
 // WithRetries attempts to execute an operation with a fixed number of retries
 func WithRetries(ctx context.Context, attempts int, delay time.Duration, operation func(context.Context) error) error {
 	for i := 0; i < attempts; i++ {
@@ -0,0 +1,197 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock that passes the delays waited for right away, recording them
+type fakeClock struct {
+	current time.Time
+	delays  []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{current: time.Unix(0, 0)}
+}
+
+// options returns opts retrying with the clock
+func (c *fakeClock) options(opts Options) Options {
+	opts.Now = func() time.Time { return c.current }
+	opts.After = func(d time.Duration) <-chan time.Time {
+		c.delays = append(c.delays, d)
+		c.current = c.current.Add(d)
+		ch := make(chan time.Time, 1)
+		ch <- c.current
+		return ch
+	}
+	return opts
+}
+
+func TestWithExponentialBackoff(t *testing.T) {
+	opts := Options{
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     40 * time.Millisecond,
+		Multiplier:   2,
+	}
+	errFailed := errors.New("failed")
+
+	t.Run("should back off exponentially up to the max delay", func(t *testing.T) {
+		clock := newFakeClock()
+
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			if attempts < 5 {
+				return errFailed
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}, clock.delays)
+	})
+
+	t.Run("should reset the delay after a long-running attempt fails", func(t *testing.T) {
+		clock := newFakeClock()
+		opts := opts
+		opts.ResetAfter = time.Minute
+
+		// The third attempt runs for longer than ResetAfter before failing
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			switch {
+			case attempts == 3:
+				clock.current = clock.current.Add(2 * time.Minute)
+				return errFailed
+			case attempts < 5:
+				return errFailed
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond}, clock.delays)
+	})
+
+	t.Run("should not reset the delay without ResetAfter", func(t *testing.T) {
+		clock := newFakeClock()
+
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			if attempts == 3 {
+				clock.current = clock.current.Add(2 * time.Minute)
+			}
+			if attempts < 4 {
+				return errFailed
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond}, clock.delays)
+	})
+
+	t.Run("should randomize the delays by the jitter", func(t *testing.T) {
+		clock := newFakeClock()
+		opts := opts
+		opts.Jitter = 0.25
+
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			if attempts <= 1000 {
+				return errFailed
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		// The backoff itself isn't randomized, only the delay waited for each step of it
+		require.Len(t, clock.delays, 1000)
+		distinct := make(map[time.Duration]bool)
+		for i, delay := range clock.delays {
+			base := opts.MaxDelay
+			if i < 2 {
+				base = opts.InitialDelay << i
+			}
+			assert.GreaterOrEqual(t, delay, time.Duration(float64(base)*0.75))
+			assert.LessOrEqual(t, delay, time.Duration(float64(base)*1.25))
+			distinct[delay] = true
+		}
+		assert.Greater(t, len(distinct), 100)
+	})
+
+	t.Run("should give up after MaxAttempts", func(t *testing.T) {
+		clock := newFakeClock()
+		opts := opts
+		opts.MaxAttempts = 3
+
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			return errFailed
+		})
+
+		assert.ErrorIs(t, err, ErrRetryExhausted)
+		assert.ErrorIs(t, err, errFailed)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.delays)
+	})
+
+	t.Run("should give up after MaxElapsedTime", func(t *testing.T) {
+		clock := newFakeClock()
+		opts := opts
+		opts.MaxElapsedTime = 100 * time.Millisecond
+
+		// The attempts start after 0, 10, 30 and 70ms, the next one would start after 110ms
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			return errFailed
+		})
+
+		assert.ErrorIs(t, err, ErrRetryExhausted)
+		assert.ErrorIs(t, err, errFailed)
+		assert.Equal(t, 4, attempts)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond}, clock.delays)
+	})
+
+	t.Run("should fail fast on an error that isn't retryable", func(t *testing.T) {
+		clock := newFakeClock()
+		errInvalid := errors.New("invalid")
+		opts := opts
+		opts.IsRetryable = func(err error) bool { return !errors.Is(err, errInvalid) }
+
+		attempts := 0
+		err := WithExponentialBackoff(context.Background(), clock.options(opts), func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errFailed
+			}
+			return errInvalid
+		})
+
+		assert.Equal(t, errInvalid, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.delays)
+	})
+
+	t.Run("should stop when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := WithExponentialBackoff(ctx, opts, func(ctx context.Context) error {
+			return errFailed
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
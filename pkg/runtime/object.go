@@ -18,7 +18,29 @@ func Decode(data []byte, obj Object) error {
 	return json.Unmarshal(data, obj)
 }
 
+// DeepCopy returns a copy of the object that shares no pointers, maps or slices with it, so
+// that modifying the copy leaves the object unchanged. The object is copied through its JSON
+// encoding, fields that aren't encoded are not copied.
+func DeepCopy[T Object](obj T) (T, error) {
+	var copied T
+	data, err := Encode(obj)
+	if err != nil {
+		return copied, fmt.Errorf("failed to copy %s: %w", GetObjectKind(obj), err)
+	}
+	if err := Decode(data, &copied); err != nil {
+		return copied, fmt.Errorf("failed to copy %s: %w", GetObjectKind(obj), err)
+	}
+	return copied, nil
+}
+
 // GetObjectKind returns the kind of the object
 func GetObjectKind(obj Object) string {
 	return fmt.Sprintf("%T", obj)
 }
+
+// Versioned is implemented by objects that carry the resource version of their stored state,
+// which storage sets when reading them and checks when updating them
+type Versioned interface {
+	GetResourceVersion() string
+	SetResourceVersion(version string)
+}
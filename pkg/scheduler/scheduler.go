@@ -2,25 +2,76 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
 	"time"
 
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
 	"gokube/pkg/registry"
 )
 
+const (
+	// ReasonNoNodesAvailable means there were no nodes to schedule the pod on. This is transient
+	// as nodes may join the cluster later.
+	ReasonNoNodesAvailable = "NoNodesAvailable"
+
+	// ReasonNodeSelectorMismatch means none of the nodes match the pod's node selector.
+	ReasonNodeSelectorMismatch = "NodeSelectorMismatch"
+
+	// ReasonNoSchedulableNodes means the nodes matching the pod's node selector are all cordoned
+	// or not ready. This is transient as nodes may be uncordoned or recover.
+	ReasonNoSchedulableNodes = "NoSchedulableNodes"
+
+	// ReasonInsufficientResources means none of the nodes matching the pod's node selector
+	// has enough resources left for the pod's requests. This is transient as pods may finish.
+	ReasonInsufficientResources = "InsufficientResources"
+)
+
 type Scheduler struct {
 	podRegistry    *registry.PodRegistry
 	nodeRegistry   *registry.NodeRegistry
 	schedulingRate time.Duration
+	opts           Options
+}
+
+// Options configures the Scheduler behavior
+type Options struct {
+	// SchedulingTimeout is how long a pod may stay unscheduled before a FailedScheduling
+	// condition is recorded on it. Zero disables the timeout.
+	SchedulingTimeout time.Duration
+
+	// FailOnTimeout marks pods that can never be scheduled as Failed once the timeout expires.
+	// Pods that are unschedulable for transient reasons are left Pending.
+	FailOnTimeout bool
+
+	// BatchSize is the most pods a scheduling pass considers, the others wait for the next pass.
+	// Zero considers every pending pod.
+	BatchSize int
+}
+
+// DefaultOptions returns the default Scheduler configuration
+func DefaultOptions() Options {
+	return Options{
+		SchedulingTimeout: 5 * time.Minute,
+		FailOnTimeout:     false,
+		BatchSize:         100,
+	}
 }
 
 func NewScheduler(podRegistry *registry.PodRegistry, nodeRegistry *registry.NodeRegistry, schedulingRate time.Duration) *Scheduler {
+	return NewSchedulerWithOptions(podRegistry, nodeRegistry, schedulingRate, DefaultOptions())
+}
+
+// NewSchedulerWithOptions creates a Scheduler with the given configuration
+func NewSchedulerWithOptions(podRegistry *registry.PodRegistry, nodeRegistry *registry.NodeRegistry, schedulingRate time.Duration, opts Options) *Scheduler {
 	return &Scheduler{
 		podRegistry:    podRegistry,
 		nodeRegistry:   nodeRegistry,
 		schedulingRate: schedulingRate,
+		opts:           opts,
 	}
 }
 
@@ -47,33 +98,195 @@ func (s *Scheduler) schedulePendingPods(ctx context.Context) error {
 		return fmt.Errorf("failed to list pending pods: %v", err)
 	}
 
+	// The workloads take turns, so that a large one doesn't take all the capacity left
+	pods = fairOrder(pods)
+	if s.opts.BatchSize > 0 && len(pods) > s.opts.BatchSize {
+		pods = pods[:s.opts.BatchSize]
+	}
+
 	// Get all available nodes
 	nodes, err := s.nodeRegistry.ListNodes(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list nodes: %v", err)
 	}
 
-	if len(nodes) == 0 {
-		return fmt.Errorf("no nodes available for scheduling")
+	requested, err := s.requestedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) > 0 && len(filterSchedulableNodes(nodes)) == 0 {
+		fmt.Printf("No schedulable nodes: all %d nodes are cordoned or not ready, pods stay pending\n", len(nodes))
 	}
 
-	// Simple round-robin scheduling
 	for _, pod := range pods {
+		request := pod.Spec.ResourceRequests()
+		// A node selector that no node matches fails the pod, even when the matching nodes are
+		// only temporarily cordoned or not ready
+		matchingNodes := filterNodes(pod, nodes)
+		schedulableNodes := filterSchedulableNodes(matchingNodes)
+		feasibleNodes := filterNodesByResources(schedulableNodes, requested, request)
+		if len(feasibleNodes) == 0 {
+			reason, message, permanent := ReasonNoNodesAvailable, "no nodes available for scheduling", false
+			switch {
+			case len(schedulableNodes) > 0:
+				reason, message = ReasonInsufficientResources, fmt.Sprintf("0/%d nodes have enough resources for the pod's requests", len(schedulableNodes))
+			case len(matchingNodes) > 0:
+				reason, message = ReasonNoSchedulableNodes, fmt.Sprintf("0/%d nodes are schedulable, the others are cordoned or not ready", len(matchingNodes))
+			case len(nodes) > 0:
+				reason, message, permanent = ReasonNodeSelectorMismatch, fmt.Sprintf("0/%d nodes match the node selector", len(nodes)), true
+			}
+			if err := s.handleUnschedulable(ctx, pod, reason, message, permanent); err != nil {
+				return err
+			}
+			continue
+		}
+
 		//TODO: We are picking up the nodes randomly. Need to have better policy based on the node status that the kubelet
 		//		updates periodically
-		node := nodes[rand.Intn(len(nodes))]
-
-		// Assign the pod to the node
-		pod.NodeName = node.Name
-		pod.Status = api.PodScheduled
+		node := feasibleNodes[rand.Intn(len(feasibleNodes))]
 
-		// Update the pod in the registry
-		if err := s.podRegistry.UpdatePod(ctx, pod); err != nil {
-			return fmt.Errorf("failed to update pod %s: %v", pod.Name, err)
+		// Bind the pod to the node. The binding only succeeds if the pod is still unassigned,
+		// so a pod that another scheduler bound in the meantime is skipped
+		if _, err := s.podRegistry.BindPod(ctx, pod.Namespace, pod.Name, node.Name); err != nil {
+			if errors.Is(err, registry.ErrPodAlreadyBound) {
+				fmt.Printf("Skipping pod %s: %v\n", pod.Name, err)
+				continue
+			}
+			return fmt.Errorf("failed to bind pod %s: %v", pod.Name, err)
 		}
+		// Later pods of this pass see the resources taken by this one
+		requested[node.Name].Add(request)
 
 		fmt.Printf("Scheduled pod %s on node %s\n", pod.Name, node.Name)
 	}
 
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes available for scheduling")
+	}
+
+	return nil
+}
+
+// fairOrder orders the pending pods round-robin across the workloads owning them: the oldest
+// pod of every workload first, then the second oldest of every workload and so on. The pods
+// of a workload are those sharing a controller, a pod without a controller is a workload of
+// its own. Workloads are ordered by their oldest pod.
+func fairOrder(pods []*api.Pod) []*api.Pod {
+	sorted := slices.Clone(pods)
+	slices.SortStableFunc(sorted, func(a, b *api.Pod) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp)
+	})
+
+	var owners []string
+	byOwner := make(map[string][]*api.Pod)
+	for _, pod := range sorted {
+		owner := pod.Namespace + "/" + pod.Name
+		if ref := api.GetControllerOf(&pod.ObjectMeta); ref != nil {
+			owner = ref.UID
+		}
+		if _, ok := byOwner[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		byOwner[owner] = append(byOwner[owner], pod)
+	}
+
+	ordered := make([]*api.Pod, 0, len(pods))
+	for turn := 0; len(ordered) < len(pods); turn++ {
+		for _, owner := range owners {
+			if turn < len(byOwner[owner]) {
+				ordered = append(ordered, byOwner[owner][turn])
+			}
+		}
+	}
+	return ordered
+}
+
+// handleUnschedulable records a FailedScheduling condition on a pod that has been waiting longer
+// than the scheduling timeout. Pods that can never be scheduled are marked Failed if configured.
+func (s *Scheduler) handleUnschedulable(ctx context.Context, pod *api.Pod, reason, message string, permanent bool) error {
+	if s.opts.SchedulingTimeout <= 0 || pod.CreationTimestamp.IsZero() {
+		return nil
+	}
+	if time.Since(pod.CreationTimestamp) < s.opts.SchedulingTimeout {
+		return nil
+	}
+
+	failed := permanent && s.opts.FailOnTimeout
+	if condition := conditions.GetCondition(pod.Status.Conditions, api.PodConditionScheduled); !failed && condition != nil &&
+		condition.Status == api.ConditionFalse && condition.Reason == reason {
+		// Already recorded, avoid rewriting the pod on every scheduling cycle
+		return nil
+	}
+
+	if _, err := s.podRegistry.MarkPodUnschedulable(ctx, pod.Namespace, pod.Name, reason, message, failed); err != nil {
+		if errors.Is(err, registry.ErrPodAlreadyBound) {
+			return nil
+		}
+		return fmt.Errorf("failed to mark pod %s unschedulable: %v", pod.Name, err)
+	}
+
+	fmt.Printf("FailedScheduling pod %s: %s: %s\n", pod.Name, reason, message)
 	return nil
 }
+
+// requestedResources returns the resources requested by the pods assigned to each node. Pods
+// that finished no longer hold their resources.
+func (s *Scheduler) requestedResources(ctx context.Context) (map[string]api.ResourceList, error) {
+	pods, err := s.podRegistry.ListPods(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	requested := make(map[string]api.ResourceList)
+	for _, pod := range pods {
+		if pod.NodeName == "" || pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
+			continue
+		}
+		if requested[pod.NodeName] == nil {
+			requested[pod.NodeName] = make(api.ResourceList)
+		}
+		requested[pod.NodeName].Add(pod.Spec.ResourceRequests())
+	}
+	return requested, nil
+}
+
+// filterNodesByResources returns the nodes the request fits on. The request must fit the
+// allocatable resources the node reported, which are those its pods don't use yet, and, with
+// the resources requested by the pods already on the node, its capacity, so that pods that
+// don't use their requests yet still hold them. It makes sure every returned node has an entry
+// in requested.
+func filterNodesByResources(nodes []*api.Node, requested map[string]api.ResourceList, request api.ResourceList) []*api.Node {
+	feasible := make([]*api.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if requested[node.Name] == nil {
+			requested[node.Name] = make(api.ResourceList)
+		}
+		if node.Allocatable.Fits(nil, request) && node.Capacity.Fits(requested[node.Name], request) {
+			feasible = append(feasible, node)
+		}
+	}
+	return feasible
+}
+
+// filterSchedulableNodes returns the nodes that are ready and not cordoned
+func filterSchedulableNodes(nodes []*api.Node) []*api.Node {
+	schedulable := make([]*api.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.IsReady() && !node.Spec.Unschedulable {
+			schedulable = append(schedulable, node)
+		}
+	}
+	return schedulable
+}
+
+// filterNodes returns the nodes whose labels satisfy the pod's node selector
+func filterNodes(pod *api.Pod, nodes []*api.Node) []*api.Node {
+	feasible := make([]*api.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if api.MatchesSelector(pod.Spec.NodeSelector, node.Labels) {
+			feasible = append(feasible, node)
+		}
+	}
+	return feasible
+}
@@ -2,13 +2,18 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gokube/pkg/api"
+	"gokube/pkg/api/conditions"
 	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -62,8 +67,8 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 		{
 			name: "Schedule pending pods to available nodes",
 			nodes: []*api.Node{
-				{ObjectMeta: api.ObjectMeta{Name: "node1"}},
-				{ObjectMeta: api.ObjectMeta{Name: "node2"}},
+				{ObjectMeta: api.ObjectMeta{Name: "node1"}, Status: api.NodeReady},
+				{ObjectMeta: api.ObjectMeta{Name: "node2"}, Status: api.NodeReady},
 			},
 			pendingPods: []*api.Pod{
 				{
@@ -71,21 +76,21 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 					Spec: api.PodSpec{
 						Containers: []api.Container{{Name: "container1", Image: "nginx:latest"}},
 					},
-					Status: api.PodPending,
+					Status: api.PodStatus{Phase: api.PodPending},
 				},
 				{
 					ObjectMeta: api.ObjectMeta{Name: "pod2"},
 					Spec: api.PodSpec{
 						Containers: []api.Container{{Name: "container2", Image: "redis:latest"}},
 					},
-					Status: api.PodPending,
+					Status: api.PodStatus{Phase: api.PodPending},
 				},
 				{
 					ObjectMeta: api.ObjectMeta{Name: "pod3"},
 					Spec: api.PodSpec{
 						Containers: []api.Container{{Name: "container3", Image: "mysql:5.7"}},
 					},
-					Status: api.PodPending,
+					Status: api.PodStatus{Phase: api.PodPending},
 				},
 			},
 			expectedScheduled: 3,
@@ -99,7 +104,7 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 					Spec: api.PodSpec{
 						Containers: []api.Container{{Name: "container4", Image: "busybox:latest"}},
 					},
-					Status: api.PodPending,
+					Status: api.PodStatus{Phase: api.PodPending},
 				},
 			},
 			expectedScheduled: 0,
@@ -141,7 +146,7 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 
 				scheduledCount := 0
 				for _, pod := range scheduledPods {
-					if pod.Status == api.PodScheduled && pod.NodeName != "" {
+					if pod.NodeName != "" {
 						scheduledCount++
 					}
 				}
@@ -151,3 +156,391 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 		})
 	}
 }
+
+func TestScheduler_SchedulingTimeout(t *testing.T) {
+	t.Run("unsatisfiable node selector fails the pod after the timeout", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdClient)
+			podRegistry := registry.NewPodRegistry(etcdStorage)
+			nodeRegistry := registry.NewNodeRegistry(etcdStorage)
+			opts := Options{SchedulingTimeout: 200 * time.Millisecond, FailOnTimeout: true}
+			scheduler := NewSchedulerWithOptions(podRegistry, nodeRegistry, time.Second, opts)
+			ctx := context.Background()
+
+			err := nodeRegistry.CreateNode(ctx, &api.Node{
+				ObjectMeta: api.ObjectMeta{Name: "node1", Labels: map[string]string{"disk": "hdd"}},
+				Status:     api.NodeReady,
+			})
+			require.NoError(t, err)
+
+			err = podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "pod1"},
+				Spec: api.PodSpec{
+					Containers:   []api.Container{{Name: "container1", Image: "nginx:latest"}},
+					NodeSelector: map[string]string{"disk": "ssd"},
+				},
+			})
+			require.NoError(t, err)
+
+			// Before the timeout the pod is left pending without a condition
+			require.NoError(t, scheduler.schedulePendingPods(ctx))
+			pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod1")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodPending, pod.Status.Phase)
+			assert.Empty(t, pod.Status.Conditions)
+
+			time.Sleep(opts.SchedulingTimeout)
+
+			require.NoError(t, scheduler.schedulePendingPods(ctx))
+			pod, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "pod1")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodFailed, pod.Status.Phase)
+			assert.Empty(t, pod.NodeName)
+			require.Len(t, pod.Status.Conditions, 1)
+			assert.Equal(t, api.PodConditionScheduled, pod.Status.Conditions[0].Type)
+			assert.Equal(t, api.ConditionFalse, pod.Status.Conditions[0].Status)
+			assert.Equal(t, ReasonNodeSelectorMismatch, pod.Status.Conditions[0].Reason)
+		})
+	})
+
+	t.Run("transient failures leave the pod pending", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdClient)
+			podRegistry := registry.NewPodRegistry(etcdStorage)
+			nodeRegistry := registry.NewNodeRegistry(etcdStorage)
+			opts := Options{SchedulingTimeout: time.Nanosecond, FailOnTimeout: true}
+			scheduler := NewSchedulerWithOptions(podRegistry, nodeRegistry, time.Second, opts)
+			ctx := context.Background()
+
+			err := podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "pod1"},
+				Spec: api.PodSpec{
+					Containers: []api.Container{{Name: "container1", Image: "nginx:latest"}},
+				},
+			})
+			require.NoError(t, err)
+
+			assert.Error(t, scheduler.schedulePendingPods(ctx))
+
+			pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod1")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodPending, pod.Status.Phase)
+			require.Len(t, pod.Status.Conditions, 1)
+			assert.Equal(t, ReasonNoNodesAvailable, pod.Status.Conditions[0].Reason)
+
+			// Once a node joins the pod is scheduled
+			require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node1"}, Status: api.NodeReady}))
+			require.NoError(t, scheduler.schedulePendingPods(ctx))
+
+			pod, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "pod1")
+			require.NoError(t, err)
+			assert.True(t, conditions.IsConditionTrue(pod.Status.Conditions, api.PodConditionScheduled))
+			assert.Equal(t, "node1", pod.NodeName)
+		})
+	})
+}
+
+// failingStorage fails every GuaranteedUpdate after the first allowed ones
+type failingStorage struct {
+	storage.Storage
+	allowed atomic.Int64
+}
+
+func (s *failingStorage) GuaranteedUpdate(ctx context.Context, key string, destination runtime.Object, tryUpdate func(runtime.Object) error) error {
+	if s.allowed.Add(-1) < 0 {
+		return errors.New("injected failure")
+	}
+	return s.Storage.GuaranteedUpdate(ctx, key, destination, tryUpdate)
+}
+
+func TestScheduler_FailureMidPassLeavesNoPartiallyBoundPod(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := &failingStorage{Storage: storage.NewEtcdStorage(etcdServer)}
+		podRegistry := registry.NewPodRegistry(store)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		ctx := context.Background()
+
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node1"}, Status: api.NodeReady}))
+		for _, name := range []string{"pod1", "pod2", "pod3"} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}))
+		}
+
+		// The pass fails on binding the second pod
+		store.allowed.Store(1)
+		scheduler := NewScheduler(podRegistry, nodeRegistry, time.Second)
+		assert.Error(t, scheduler.schedulePendingPods(ctx))
+
+		pods, err := podRegistry.ListPods(ctx)
+		require.NoError(t, err)
+		bound := 0
+		for _, pod := range pods {
+			condition := conditions.GetCondition(pod.Status.Conditions, api.PodConditionScheduled)
+			if pod.NodeName == "" {
+				// A pod is either untouched by the pass or fully bound
+				assert.Equal(t, api.PodPending, pod.Status.Phase, pod.Name)
+				assert.Nil(t, condition, pod.Name)
+				continue
+			}
+			bound++
+			assert.True(t, conditions.IsConditionTrue(pod.Status.Conditions, api.PodConditionScheduled), pod.Name)
+			require.NotNil(t, condition, pod.Name)
+			assert.Equal(t, api.ConditionTrue, condition.Status, pod.Name)
+		}
+		assert.Equal(t, 1, bound)
+
+		// The next pass binds the remaining pods
+		store.allowed.Store(10)
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+		pending, err := podRegistry.ListPendingPods(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
+
+func TestScheduler_ResourceAwareScheduling(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdClient)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		nodeRegistry := registry.NewNodeRegistry(etcdStorage)
+		scheduler := NewScheduler(podRegistry, nodeRegistry, time.Second)
+		ctx := context.Background()
+
+		for _, name := range []string{"node1", "node2"} {
+			require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Status:     api.NodeReady,
+				Capacity:   api.ResourceList{api.ResourceCPU: 2000, api.ResourceMemory: 4 << 30},
+			}))
+		}
+
+		newPod := func(name string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec: api.PodSpec{
+					Containers: []api.Container{{
+						Name:      "app",
+						Image:     "nginx:latest",
+						Resources: api.ResourceRequirements{Requests: api.ResourceList{api.ResourceCPU: 2000}},
+					}},
+				},
+			}
+		}
+
+		// Both pods are scheduled in the same pass, so the second must see the first's request
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod1")))
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod2")))
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+
+		pod1, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod1")
+		require.NoError(t, err)
+		pod2, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod2")
+		require.NoError(t, err)
+		assert.True(t, conditions.IsConditionTrue(pod1.Status.Conditions, api.PodConditionScheduled))
+		assert.True(t, conditions.IsConditionTrue(pod2.Status.Conditions, api.PodConditionScheduled))
+		assert.ElementsMatch(t, []string{"node1", "node2"}, []string{pod1.NodeName, pod2.NodeName})
+
+		// A third pod fits nowhere and stays pending
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("pod3")))
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+
+		pod3, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod3")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodPending, pod3.Status.Phase)
+		assert.Empty(t, pod3.NodeName)
+
+		// Once a pod finishes its resources are free again
+		pod1.Status.Phase = api.PodSucceeded
+		require.NoError(t, podRegistry.UpdatePod(ctx, pod1))
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+
+		pod3, err = podRegistry.GetPod(ctx, api.NamespaceDefault, "pod3")
+		require.NoError(t, err)
+		assert.True(t, conditions.IsConditionTrue(pod3.Status.Conditions, api.PodConditionScheduled))
+		assert.Equal(t, pod1.NodeName, pod3.NodeName)
+	})
+}
+
+func TestScheduler_SkipsUnschedulableNodes(t *testing.T) {
+	healthy := &api.Node{ObjectMeta: api.ObjectMeta{Name: "healthy"}, Status: api.NodeReady}
+	cordoned := &api.Node{ObjectMeta: api.ObjectMeta{Name: "cordoned"}, Spec: api.NodeSpec{Unschedulable: true}, Status: api.NodeReady}
+	notReady := &api.Node{ObjectMeta: api.ObjectMeta{Name: "not-ready"}, Status: api.NodeNotReady}
+
+	testCases := []struct {
+		name         string
+		nodes        []*api.Node
+		expectedNode string
+	}{
+		{
+			name:         "cordoned node is skipped in favor of a healthy one",
+			nodes:        []*api.Node{cordoned, healthy},
+			expectedNode: "healthy",
+		},
+		{
+			name:         "NotReady node is skipped in favor of a healthy one",
+			nodes:        []*api.Node{notReady, healthy},
+			expectedNode: "healthy",
+		},
+		{
+			name:  "pods stay pending without schedulable nodes",
+			nodes: []*api.Node{cordoned, notReady},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+				etcdStorage := storage.NewEtcdStorage(etcdClient)
+				podRegistry := registry.NewPodRegistry(etcdStorage)
+				nodeRegistry := registry.NewNodeRegistry(etcdStorage)
+				scheduler := NewScheduler(podRegistry, nodeRegistry, time.Second)
+				ctx := context.Background()
+
+				for _, node := range tc.nodes {
+					require.NoError(t, nodeRegistry.CreateNode(ctx, node))
+				}
+				for _, name := range []string{"pod1", "pod2", "pod3"} {
+					require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+						ObjectMeta: api.ObjectMeta{Name: name},
+						Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					}))
+				}
+
+				require.NoError(t, scheduler.schedulePendingPods(ctx))
+
+				pods, err := podRegistry.ListPods(ctx)
+				require.NoError(t, err)
+				for _, pod := range pods {
+					if tc.expectedNode == "" {
+						assert.Equal(t, api.PodPending, pod.Status.Phase, pod.Name)
+						assert.Empty(t, pod.NodeName, pod.Name)
+						continue
+					}
+					assert.True(t, conditions.IsConditionTrue(pod.Status.Conditions, api.PodConditionScheduled), pod.Name)
+					assert.Equal(t, tc.expectedNode, pod.NodeName, pod.Name)
+				}
+			})
+		})
+	}
+}
+
+func TestScheduler_FitsAllocatableResources(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdClient)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		nodeRegistry := registry.NewNodeRegistry(etcdStorage)
+		scheduler := NewScheduler(podRegistry, nodeRegistry, time.Second)
+		ctx := context.Background()
+
+		// Both nodes have the capacity for the pod, but most of the memory of node1 is in use
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+			ObjectMeta:  api.ObjectMeta{Name: "node1"},
+			Status:      api.NodeReady,
+			Capacity:    api.ResourceList{api.ResourceMemory: 4 << 30},
+			Allocatable: api.ResourceList{api.ResourceMemory: 512 << 20},
+		}))
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+			ObjectMeta:  api.ObjectMeta{Name: "node2"},
+			Status:      api.NodeReady,
+			Capacity:    api.ResourceList{api.ResourceMemory: 4 << 30},
+			Allocatable: api.ResourceList{api.ResourceMemory: 2 << 30},
+		}))
+
+		require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "pod1"},
+			Spec: api.PodSpec{Containers: []api.Container{{
+				Name:      "app",
+				Image:     "nginx:latest",
+				Resources: api.ResourceRequirements{Requests: api.ResourceList{api.ResourceMemory: 1 << 30}},
+			}}},
+		}))
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+
+		pod, err := podRegistry.GetPod(ctx, api.NamespaceDefault, "pod1")
+		require.NoError(t, err)
+		assert.Equal(t, "node2", pod.NodeName)
+	})
+}
+
+func TestScheduler_FairnessAcrossOwners(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdClient)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		nodeRegistry := registry.NewNodeRegistry(etcdStorage)
+		ctx := context.Background()
+
+		// The node only has room for four of the eight pods
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+			ObjectMeta: api.ObjectMeta{Name: "node1"},
+			Status:     api.NodeReady,
+			Capacity:   api.ResourceList{api.ResourceCPU: 4000},
+		}))
+
+		// The pods of the first ReplicaSet are all older and listed first
+		created := time.Now().Add(-time.Hour)
+		for _, owner := range []string{"alpha", "beta"} {
+			for i := 0; i < 4; i++ {
+				created = created.Add(time.Second)
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{
+						Name:              fmt.Sprintf("%s-%d", owner, i),
+						CreationTimestamp: created,
+						OwnerReferences:   []api.OwnerReference{{Kind: api.KindReplicaSet, Name: owner, UID: owner + "-uid", Controller: true}},
+					},
+					Spec: api.PodSpec{Containers: []api.Container{{
+						Name:      "app",
+						Image:     "nginx:latest",
+						Resources: api.ResourceRequirements{Requests: api.ResourceList{api.ResourceCPU: 1000}},
+					}}},
+				}))
+			}
+		}
+
+		scheduledPods := func() []string {
+			pods, err := podRegistry.ListPodsByNode(ctx, "node1")
+			require.NoError(t, err)
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			return names
+		}
+
+		// A batch of two schedules a pod of each ReplicaSet
+		opts := DefaultOptions()
+		opts.BatchSize = 2
+		scheduler := NewSchedulerWithOptions(podRegistry, nodeRegistry, time.Second, opts)
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+		assert.ElementsMatch(t, []string{"alpha-0", "beta-0"}, scheduledPods())
+
+		// The remaining capacity is shared alike
+		scheduler.opts.BatchSize = 0
+		require.NoError(t, scheduler.schedulePendingPods(ctx))
+		assert.ElementsMatch(t, []string{"alpha-0", "alpha-1", "beta-0", "beta-1"}, scheduledPods())
+	})
+}
+
+func TestFairOrder(t *testing.T) {
+	base := time.Now()
+	newPod := func(name, owner string, age int) *api.Pod {
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: name, CreationTimestamp: base.Add(-time.Duration(age) * time.Minute)}}
+		if owner != "" {
+			pod.OwnerReferences = []api.OwnerReference{{Kind: api.KindReplicaSet, Name: owner, UID: owner, Controller: true}}
+		}
+		return pod
+	}
+
+	pods := []*api.Pod{
+		newPod("a-1", "a", 9), newPod("a-2", "a", 8), newPod("a-3", "a", 7),
+		newPod("b-1", "b", 6), newPod("b-2", "b", 5),
+		newPod("bare", "", 4),
+	}
+
+	var names []string
+	for _, pod := range fairOrder(pods) {
+		names = append(names, pod.Name)
+	}
+	assert.Equal(t, []string{"a-1", "b-1", "bare", "a-2", "b-2", "a-3"}, names)
+}
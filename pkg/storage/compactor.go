@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gokube/pkg/etcdclient"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// watcherTracker keeps track of the last revision delivered to each active watcher, so that
+// compaction never removes revisions a watcher still needs. An event is delivered once the
+// consumer received it from the channel, not when it is buffered.
+type watcherTracker struct {
+	mu       sync.Mutex
+	nextID   int64
+	watchers map[int64]*trackedWatcher
+}
+
+// trackedWatcher is the progress of a watcher
+type trackedWatcher struct {
+	// revision is the last revision delivered to the consumer
+	revision int64
+	// pending holds the revisions of the events sent to the channel that may still be
+	// buffered, oldest first
+	pending []int64
+	// buffered returns the number of events buffered in the channel
+	buffered func() int
+}
+
+func newWatcherTracker() *watcherTracker {
+	return &watcherTracker{watchers: make(map[int64]*trackedWatcher)}
+}
+
+// register adds a watcher that has seen everything up to and including revision, whose
+// channel holds buffered events
+func (t *watcherTracker) register(revision int64, buffered func() int) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	t.watchers[t.nextID] = &trackedWatcher{revision: revision, buffered: buffered}
+	return t.nextID
+}
+
+// sent records an event sent to the channel of the watcher. It is called after the send, so
+// the event is never counted as delivered before it was.
+func (t *watcherTracker) sent(id, revision int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if w, ok := t.watchers[id]; ok {
+		w.pending = append(w.pending, revision)
+	}
+}
+
+// update advances the revision seen by the watcher, for an event that isn't sent to the
+// channel. It is seen once the events buffered before it are delivered.
+func (t *watcherTracker) update(id, revision int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.watchers[id]
+	switch {
+	case !ok:
+	case len(w.pending) > 0:
+		w.pending[len(w.pending)-1] = max(w.pending[len(w.pending)-1], revision)
+	case revision > w.revision:
+		w.revision = revision
+	}
+}
+
+func (t *watcherTracker) unregister(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.watchers, id)
+}
+
+// min returns the lowest revision delivered to any active watcher
+func (t *watcherTracker) min() (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var minRevision int64
+	found := false
+	for _, w := range t.watchers {
+		// The events that left the channel are the oldest pending ones
+		if delivered := len(w.pending) - w.buffered(); delivered > 0 {
+			w.revision = max(w.revision, w.pending[delivered-1])
+			w.pending = w.pending[delivered:]
+		}
+		if !found || w.revision < minRevision {
+			minRevision = w.revision
+			found = true
+		}
+	}
+	return minRevision, found
+}
+
+// CurrentRevision returns the latest revision of the etcd store
+func (s *EtcdStorage) CurrentRevision(ctx context.Context) (int64, error) {
+	resp, err := s.client.Get(ctx, "/", clientv3.WithCountOnly())
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	return resp.Header.Revision, nil
+}
+
+// CompactorOptions configures the periodic compaction of the etcd history
+type CompactorOptions struct {
+	// Interval is how often the history is compacted
+	Interval time.Duration
+	// MaxRetainedRevisions bounds how many revisions behind the current one are kept for
+	// lagging watchers. The watches of watchers further behind fail once compacted, and their
+	// consumers relist. Zero keeps every revision an active watcher still needs.
+	MaxRetainedRevisions int64
+}
+
+// DefaultCompactorOptions returns the default compaction configuration
+func DefaultCompactorOptions() CompactorOptions {
+	return CompactorOptions{
+		Interval:             5 * time.Minute,
+		MaxRetainedRevisions: 100000,
+	}
+}
+
+// Compact compacts the etcd history up to the lowest revision still needed by the active
+// watchers of this storage and by the watchers that published a watermark, such as the
+// ListWatches of other processes, or up to the current revision if there are none.
+// It returns the revision compacted to, or 0 if there was nothing to compact.
+func (s *EtcdStorage) Compact(ctx context.Context) (int64, error) {
+	return s.compact(ctx, 0)
+}
+
+// compact is Compact keeping no more than maxRetained revisions behind the current one,
+// unless maxRetained is 0
+func (s *EtcdStorage) compact(ctx context.Context, maxRetained int64) (int64, error) {
+	s.compactMutex.Lock()
+	defer s.compactMutex.Unlock()
+
+	current, err := s.CurrentRevision(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	target := current
+	if watermark, ok := s.watchers.min(); ok && watermark < target {
+		target = watermark
+	}
+	watermark, ok, err := etcdclient.MinWatermark(ctx, s.client)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	if ok && watermark < target {
+		target = watermark
+	}
+	if maxRetained > 0 && target < current-maxRetained {
+		log.Printf("Compacting etcd past revision %d still needed by a watcher more than %d revisions behind", target, maxRetained)
+		target = current - maxRetained
+	}
+
+	if target <= s.compactedRevision {
+		return 0, nil
+	}
+
+	if _, err := s.client.Compact(ctx, target); err != nil {
+		if !errors.Is(err, rpctypes.ErrCompacted) {
+			return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		// Someone else compacted past this revision already
+	}
+
+	s.compactedRevision = target
+	return target, nil
+}
+
+// StartCompactor periodically compacts the etcd history until the context is cancelled.
+func (s *EtcdStorage) StartCompactor(ctx context.Context, interval time.Duration) {
+	opts := DefaultCompactorOptions()
+	opts.Interval = interval
+	s.StartCompactorWithOptions(ctx, opts)
+}
+
+// StartCompactorWithOptions periodically compacts the etcd history with the given
+// configuration until the context is cancelled.
+func (s *EtcdStorage) StartCompactorWithOptions(ctx context.Context, opts CompactorOptions) {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			revision, err := s.compact(ctx, opts.MaxRetainedRevisions)
+			if err != nil {
+				log.Printf("Error compacting etcd: %v", err)
+				continue
+			}
+			if revision > 0 {
+				log.Printf("Compacted etcd to revision %d", revision)
+			}
+		}
+	}
+}
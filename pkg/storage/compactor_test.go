@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gokube/pkg/etcdclient"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdStorage_Compact(t *testing.T) {
+	t.Run("should compact to the current revision without watchers", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			for i := 0; i < 5; i++ {
+				require.NoError(t, storage.Create(ctx, fmt.Sprintf("/compact/key%d", i), &TestObject{Name: "test"}))
+			}
+			current, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+
+			revision, err := storage.Compact(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, current, revision)
+
+			// Nothing new to compact
+			revision, err = storage.Compact(ctx)
+			require.NoError(t, err)
+			assert.Zero(t, revision)
+		})
+	})
+
+	t.Run("should not compact past an active watcher", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			prefix := "/compact/"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, storage.Create(ctx, prefix+"key0", &TestObject{Name: "test"}))
+			watchRevision, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+
+			watchCtx, stopWatch := context.WithCancel(ctx)
+			defer stopWatch()
+			watchChan, err := storage.WatchFromRevision(watchCtx, prefix, watchRevision, DefaultWatchOptions())
+			require.NoError(t, err)
+
+			for i := 1; i < 5; i++ {
+				require.NoError(t, storage.Create(ctx, fmt.Sprintf("%skey%d", prefix, i), &TestObject{Name: "test"}))
+			}
+
+			// The watcher has not consumed any events yet
+			revision, err := storage.Compact(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, watchRevision, revision)
+
+			// Consuming an event advances the watermark
+			select {
+			case event := <-watchChan:
+				assert.Equal(t, prefix+"key1", event.Key)
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for event")
+			}
+			require.Eventually(t, func() bool {
+				revision, err = storage.Compact(ctx)
+				return err == nil && revision == watchRevision+1
+			}, time.Second, 10*time.Millisecond)
+
+			// Once the watcher disconnects compaction catches up to the current revision
+			stopWatch()
+			for range watchChan {
+			}
+
+			current, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+			revision, err = storage.Compact(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, current, revision)
+		})
+	})
+
+	t.Run("should compact past a watcher lagging more than the retained revisions", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			prefix := "/compact/"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			watchRevision, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+			_, err = storage.WatchFromRevision(ctx, prefix, watchRevision, DefaultWatchOptions())
+			require.NoError(t, err)
+
+			for i := 0; i < 5; i++ {
+				require.NoError(t, storage.Create(ctx, fmt.Sprintf("%skey%d", prefix, i), &TestObject{Name: "test"}))
+			}
+			current, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+
+			// The watcher hasn't consumed any events, but only two revisions are retained
+			revision, err := storage.compact(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, current-2, revision)
+		})
+	})
+	t.Run("should not compact past a published watermark", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, storage.Create(ctx, "/compact/key0", &TestObject{Name: "test"}))
+			watermarkRevision, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+
+			// A watcher of another process needs the revisions after the watermark
+			watermark := etcdclient.NewWatermark(etcdclient.Wrap(cli), time.Minute)
+			require.NoError(t, watermark.Publish(ctx, watermarkRevision))
+			for i := 1; i < 5; i++ {
+				require.NoError(t, storage.Create(ctx, fmt.Sprintf("/compact/key%d", i), &TestObject{Name: "test"}))
+			}
+
+			revision, err := storage.Compact(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, watermarkRevision, revision)
+
+			// Once the watermark is withdrawn compaction catches up to the current revision
+			require.NoError(t, watermark.Withdraw(ctx))
+			current, err := storage.CurrentRevision(ctx)
+			require.NoError(t, err)
+			revision, err = storage.Compact(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, current, revision)
+		})
+	})
+}
+
+func TestEtcdStorage_WatchCompacted(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		stale, err := storage.CurrentRevision(ctx)
+		require.NoError(t, err)
+		require.NoError(t, storage.Create(ctx, "/compact/key0", &TestObject{Name: "test"}))
+		require.NoError(t, storage.Create(ctx, "/compact/key1", &TestObject{Name: "test"}))
+		_, err = storage.Compact(ctx)
+		require.NoError(t, err)
+
+		// The watch ends with an error event rather than closing as if it was done
+		watchChan, err := storage.WatchFromRevision(ctx, "/compact/", stale, DefaultWatchOptions())
+		require.NoError(t, err)
+		select {
+		case event := <-watchChan:
+			assert.Equal(t, EventError, event.Type)
+			assert.ErrorIs(t, event.Err, ErrCompacted)
+			assert.ErrorIs(t, event.Decode(&TestObject{}), ErrCompacted)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the error event")
+		}
+		_, open := <-watchChan
+		assert.False(t, open, "the watch should be closed after the error event")
+	})
+}
@@ -2,23 +2,88 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 
+	"gokube/pkg/etcdclient"
 	"gokube/pkg/runtime"
 
+	"github.com/prometheus/client_golang/prometheus"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // EtcdStorage implements the Storage interface using etcd
 type EtcdStorage struct {
-	client *clientv3.Client
+	client   *etcdclient.Client
+	watchers *watcherTracker
+
+	compactMutex      sync.Mutex
+	compactedRevision int64
+
+	indexMutex sync.RWMutex
+	indexers   map[string]Indexer
+	// backfilled are the names of the indexes that hold the objects written before they were
+	// added. backfillMutex serializes the backfills.
+	backfilled    map[string]bool
+	backfillMutex sync.Mutex
+
+	// leases are the leases objects created with a TTL are attached to, by their TTL in seconds,
+	// so that objects created shortly after one another share a lease
+	leaseMutex sync.Mutex
+	leases     map[int64]ttlLease
+
+	// metrics is nil unless the storage is instrumented
+	metrics *storageMetrics
 }
 
 // NewEtcdStorage creates a new EtcdStorage
 func NewEtcdStorage(client *clientv3.Client) *EtcdStorage {
-	return &EtcdStorage{client: client}
+	return NewEtcdStorageWithClient(etcdclient.Wrap(client))
+}
+
+// NewEtcdStorageWithClient creates a new EtcdStorage making its calls through the client, which
+// invokes its hooks around each of them
+func NewEtcdStorageWithClient(client *etcdclient.Client) *EtcdStorage {
+	return &EtcdStorage{client: client, watchers: newWatcherTracker()}
+}
+
+// NewEtcdStorageFromConfig creates a new EtcdStorage connected to the etcd described by the
+// config, which may be a TLS secured and authenticated cluster, and invokes the hooks of the
+// config around its etcd calls. The storage owns the client, Close closes it.
+func NewEtcdStorageFromConfig(config etcdclient.Config) (*EtcdStorage, error) {
+	clientConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientv3.New(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	return NewEtcdStorageWithClient(etcdclient.Wrap(client, config.Hooks...)), nil
+}
+
+// Close closes the etcd client of the storage
+func (s *EtcdStorage) Close() error {
+	return s.client.Close()
+}
+
+// NewEtcdStorageWithMetrics creates a new EtcdStorage that records the latency and outcome of
+// every operation in metrics registered with the given registerer
+func NewEtcdStorageWithMetrics(client *clientv3.Client, registerer prometheus.Registerer) (*EtcdStorage, error) {
+	metrics, err := newStorageMetrics(registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewEtcdStorage(client)
+	s.metrics = metrics
+	return s, nil
 }
 
 var (
@@ -26,22 +91,128 @@ var (
 	ErrDecoding   = fmt.Errorf("error decoding object")
 	ErrNotFound   = fmt.Errorf("object not found")
 	ErrEtcdClient = fmt.Errorf("etcd client error")
+	// ErrInvalidContinueToken is returned for a continue token that wasn't returned by ListPaged
+	// for the listed prefix
+	ErrInvalidContinueToken = fmt.Errorf("invalid continue token")
+	// ErrConflict is returned when an object is updated from a resource version that is no
+	// longer the stored one
+	ErrConflict = fmt.Errorf("object has been modified")
+	// ErrAlreadyExists is returned when creating an object under a key that is taken
+	ErrAlreadyExists = fmt.Errorf("object already exists")
+	// ErrCompacted is the error of a watch whose revision was compacted. The watcher has to
+	// list again and watch from the revision of the list.
+	ErrCompacted = fmt.Errorf("revision has been compacted")
 )
 
-func (s *EtcdStorage) Create(ctx context.Context, key string, obj runtime.Object) error {
-	data, err := runtime.Encode(obj)
+func (s *EtcdStorage) Create(ctx context.Context, key string, obj runtime.Object) (err error) {
+	defer s.metrics.observe(operationCreate, time.Now(), &err)
+
+	data, err := encode(obj)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEncoding, err)
 	}
 
-	_, err = s.client.Put(ctx, key, string(data))
+	if indexers := s.indexersFor(key); len(indexers) > 0 {
+		_, err := s.putIndexed(ctx, key, obj, data, indexers, 0)
+		return err
+	}
+
+	resp, err := s.client.Put(ctx, key, string(data))
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
+	setResourceVersion(obj, resp.Header.Revision)
 	return nil
 }
 
-func (s *EtcdStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
+// CreateWithTTL stores the object under a lease of ttl, rounded up to whole seconds, so etcd
+// removes it once the lease expires. It returns ErrAlreadyExists if the key is taken. Objects
+// created with the same TTL within a short window share a lease, so they may outlive their TTL
+// by up to that window. Objects under indexed prefixes can't expire, as their index entries
+// would outlive them.
+func (s *EtcdStorage) CreateWithTTL(ctx context.Context, key string, obj runtime.Object, ttl time.Duration) (err error) {
+	defer s.metrics.observe(operationCreateWithTTL, time.Now(), &err)
+
+	if len(s.indexersFor(key)) > 0 {
+		return fmt.Errorf("%w: key %s is indexed and can't expire", ErrEtcdClient, key)
+	}
+
+	data, err := encode(obj)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+
+	seconds := leaseSeconds(ttl)
+	lease, err := s.lease(ctx, seconds)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(data), clientv3.WithLease(lease))).
+		Commit()
+	if err != nil {
+		// The lease may have been revoked or have expired, a new one is granted next time
+		s.forgetLease(seconds, lease)
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, key)
+	}
+	setResourceVersion(obj, resp.Header.Revision)
+	return nil
+}
+
+// ttlLease is a lease shared by the objects created with the same TTL until reuseUntil
+type ttlLease struct {
+	id         clientv3.LeaseID
+	reuseUntil time.Time
+}
+
+// lease returns a lease keeping objects for at least seconds. A lease is reused for a tenth of
+// its TTL, and at least a second, and outlasts the TTL by as much, so that every object attached
+// to it is kept for its TTL.
+func (s *EtcdStorage) lease(ctx context.Context, seconds int64) (clientv3.LeaseID, error) {
+	s.leaseMutex.Lock()
+	defer s.leaseMutex.Unlock()
+
+	now := time.Now()
+	if lease, ok := s.leases[seconds]; ok && now.Before(lease.reuseUntil) {
+		return lease.id, nil
+	}
+
+	window := max(seconds/10, 1)
+	resp, err := s.client.Grant(ctx, seconds+window)
+	if err != nil {
+		return 0, err
+	}
+	if s.leases == nil {
+		s.leases = make(map[int64]ttlLease)
+	}
+	s.leases[seconds] = ttlLease{id: resp.ID, reuseUntil: now.Add(time.Duration(window) * time.Second)}
+	return resp.ID, nil
+}
+
+// forgetLease stops reusing the lease for objects created with a TTL of seconds
+func (s *EtcdStorage) forgetLease(seconds int64, id clientv3.LeaseID) {
+	s.leaseMutex.Lock()
+	defer s.leaseMutex.Unlock()
+
+	if lease, ok := s.leases[seconds]; ok && lease.id == id {
+		delete(s.leases, seconds)
+	}
+}
+
+// leaseSeconds returns the TTL of a lease lasting at least ttl, etcd leases last whole seconds
+func leaseSeconds(ttl time.Duration) int64 {
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	return max(seconds, 1)
+}
+
+func (s *EtcdStorage) Get(ctx context.Context, key string, obj runtime.Object) (err error) {
+	defer s.metrics.observe(operationGet, time.Now(), &err)
+
 	resp, err := s.client.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
@@ -51,51 +222,433 @@ func (s *EtcdStorage) Get(ctx context.Context, key string, obj runtime.Object) e
 		return fmt.Errorf("%w: %s", ErrNotFound, key)
 	}
 
-	if err := runtime.Decode(resp.Kvs[0].Value, obj); err != nil {
-		return fmt.Errorf("%w: %v", ErrDecoding, err)
+	if err := decode(key, resp.Kvs[0].Value, obj); err != nil {
+		return err
 	}
+	setResourceVersion(obj, resp.Kvs[0].ModRevision)
 	return nil
 }
 
-func (s *EtcdStorage) Update(ctx context.Context, key string, obj runtime.Object) error {
-	data, err := runtime.Encode(obj)
+// Update writes obj at key, replacing the stored object whatever its resource version. The
+// resource version carried by obj is ignored, UpdateAtRevision only writes over a given one.
+func (s *EtcdStorage) Update(ctx context.Context, key string, obj runtime.Object) (err error) {
+	defer s.metrics.observe(operationUpdate, time.Now(), &err)
+
+	return s.update(ctx, key, obj, 0)
+}
+
+// UpdateAtRevision is Update that only writes obj if the stored object is still at the
+// revision, ErrConflict is returned if it was modified or deleted since
+func (s *EtcdStorage) UpdateAtRevision(ctx context.Context, key string, obj runtime.Object, revision int64) (err error) {
+	defer s.metrics.observe(operationUpdate, time.Now(), &err)
+
+	if revision <= 0 {
+		return fmt.Errorf("%w: invalid resource version %d", ErrConflict, revision)
+	}
+	return s.update(ctx, key, obj, revision)
+}
+
+// update writes obj at key, only if the stored object is at revision unless revision is 0
+func (s *EtcdStorage) update(ctx context.Context, key string, obj runtime.Object, revision int64) error {
+	data, err := encode(obj)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEncoding, err)
 	}
 
-	if _, err = s.client.Put(ctx, key, string(data)); err != nil {
+	if indexers := s.indexersFor(key); len(indexers) > 0 {
+		_, err := s.putIndexed(ctx, key, obj, data, indexers, revision)
+		return err
+	}
+
+	if revision == 0 {
+		resp, err := s.client.Put(ctx, key, string(data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		setResourceVersion(obj, resp.Header.Revision)
+		return nil
+	}
+
+	txnResp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("%w: %s is no longer at resource version %d", ErrConflict, key, revision)
+	}
+	setResourceVersion(obj, txnResp.Header.Revision)
 	return nil
 }
 
-func (s *EtcdStorage) Delete(ctx context.Context, key string) error {
-	if _, err := s.client.Delete(ctx, key); err != nil {
-		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+// CreateOrUpdate writes obj at key whether or not an object is stored there already, in a
+// single transaction, and reports whether it was created. The resource version carried by obj
+// is ignored, the write replaces any stored object, and obj is given the resulting one. When an
+// object is stored, preserve, unless nil, is called with it before obj is written, so that obj
+// can keep fields of it. The stored object is read again and preserve called again if it
+// changes before the write.
+func (s *EtcdStorage) CreateOrUpdate(ctx context.Context, key string, obj runtime.Object, preserve func(stored runtime.Object)) (created bool, err error) {
+	defer s.metrics.observe(operationCreateOrUpdate, time.Now(), &err)
+
+	indexers := s.indexersFor(key)
+	for {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+
+		var oldValue []byte
+		cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		if len(resp.Kvs) > 0 {
+			kv := resp.Kvs[0]
+			oldValue = kv.Value
+			cmp = clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)
+			if preserve != nil {
+				stored := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+				if err := decode(key, kv.Value, stored); err != nil {
+					return false, err
+				}
+				setResourceVersion(stored, kv.ModRevision)
+				preserve(stored)
+			}
+		}
+
+		data, err := encode(obj)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrEncoding, err)
+		}
+		ops, err := indexOps(indexers, key, oldValue, obj, data)
+		if err != nil {
+			return false, err
+		}
+
+		txnResp, err := s.client.Txn(ctx).
+			If(cmp).
+			Then(append([]clientv3.Op{clientv3.OpPut(key, string(data))}, ops...)...).
+			Commit()
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		if txnResp.Succeeded {
+			setResourceVersion(obj, txnResp.Header.Revision)
+			return len(resp.Kvs) == 0, nil
+		}
+		// The key was modified concurrently, retry against the latest state
+	}
+}
+
+// GuaranteedUpdate reads the object stored at key into obj, applies tryUpdate to it and
+// writes the result back in a transaction that only commits if the key has not been
+// modified since it was read. If another writer got in first, the object is re-read and
+// tryUpdate is applied again. An error returned by tryUpdate aborts the update.
+func (s *EtcdStorage) GuaranteedUpdate(ctx context.Context, key string, obj runtime.Object, tryUpdate func(obj runtime.Object) error) (err error) {
+	defer s.metrics.observe(operationGuaranteedUpdate, time.Now(), &err)
+
+	indexers := s.indexersFor(key)
+	for {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+
+		if len(resp.Kvs) == 0 {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+
+		kv := resp.Kvs[0]
+		resetObject(obj)
+		if err := decode(key, kv.Value, obj); err != nil {
+			return err
+		}
+		setResourceVersion(obj, kv.ModRevision)
+
+		if err := tryUpdate(obj); err != nil {
+			return err
+		}
+
+		data, err := encode(obj)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEncoding, err)
+		}
+
+		ops, err := indexOps(indexers, key, kv.Value, obj, data)
+		if err != nil {
+			return err
+		}
+
+		txnResp, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(append([]clientv3.Op{clientv3.OpPut(key, string(data))}, ops...)...).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+
+		if txnResp.Succeeded {
+			setResourceVersion(obj, txnResp.Header.Revision)
+			return nil
+		}
+		// The key was modified concurrently, retry against the latest state
+	}
+}
+
+// resetObject sets the value pointed to by obj to its zero value, so that decoding
+// into a previously used object doesn't leave stale fields behind
+func resetObject(obj runtime.Object) {
+	value := reflect.ValueOf(obj)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value.Elem().Set(reflect.Zero(value.Elem().Type()))
+	}
+}
+
+func (s *EtcdStorage) Delete(ctx context.Context, key string) (err error) {
+	defer s.metrics.observe(operationDelete, time.Now(), &err)
+	return s.delete(ctx, key, 0)
+}
+
+// DeleteAtRevision deletes key if it was last modified at revision
+func (s *EtcdStorage) DeleteAtRevision(ctx context.Context, key string, revision int64) (err error) {
+	defer s.metrics.observe(operationDelete, time.Now(), &err)
+
+	if revision <= 0 {
+		return fmt.Errorf("%w: invalid resource version %d", ErrConflict, revision)
+	}
+	return s.delete(ctx, key, revision)
+}
+
+// delete deletes key, only if it is at revision unless revision is 0
+func (s *EtcdStorage) delete(ctx context.Context, key string, revision int64) error {
+	if indexers := s.indexersFor(key); len(indexers) > 0 {
+		return s.deleteIndexed(ctx, key, indexers, revision)
+	}
+
+	if revision == 0 {
+		if _, err := s.client.Delete(ctx, key); err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		return nil
 	}
 
+	txnResp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpDelete(key)).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	if !txnResp.Succeeded {
+		if txnResp.Responses[0].GetResponseRange().Count == 0 {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return fmt.Errorf("%w: %s is no longer at resource version %d", ErrConflict, key, revision)
+	}
 	return nil
 }
 
-func (s *EtcdStorage) List(ctx context.Context, prefix string, listObj interface{}) error {
+// List decodes the objects stored under prefix into listObj, which must be a pointer to a
+// slice of object pointers. The slice is replaced, not appended to: any existing contents are
+// discarded and an empty, non-nil slice is set when nothing is stored under the prefix.
+func (s *EtcdStorage) List(ctx context.Context, prefix string, listObj interface{}) (err error) {
+	defer s.metrics.observe(operationList, time.Now(), &err)
+
 	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
 
+	return decodeList(resp, listObj)
+}
+
+// ListPaged fetches a page of at most limit keys, starting after the last key of the previous
+// page. The continue token is that last key, base64 encoded.
+func (s *EtcdStorage) ListPaged(ctx context.Context, prefix string, limit int64, continueToken string, listObj interface{}) (_ string, err error) {
+	defer s.metrics.observe(operationList, time.Now(), &err)
+
+	resp, next, err := s.getPage(ctx, prefix, limit, continueToken)
+	if err != nil {
+		return "", err
+	}
+	if err := decodeList(resp, listObj); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// getPage gets the keys of a page of ListPaged, and the continue token of the next page
+func (s *EtcdStorage) getPage(ctx context.Context, prefix string, limit int64, continueToken string) (*clientv3.GetResponse, string, error) {
+	if limit < 0 {
+		return nil, "", fmt.Errorf("invalid limit %d, must not be negative", limit)
+	}
+
+	start := prefix
+	if continueToken != "" {
+		lastKey, err := decodeContinueToken(prefix, continueToken)
+		if err != nil {
+			return nil, "", err
+		}
+		// The smallest key after the last key of the previous page
+		start = lastKey + "\x00"
+	}
+
+	resp, err := s.client.Get(ctx, start,
+		clientv3.WithRange(clientv3.GetPrefixRangeEnd(prefix)),
+		clientv3.WithLimit(limit),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+
+	if !resp.More || len(resp.Kvs) == 0 {
+		return resp, "", nil
+	}
+	return resp, encodeContinueToken(string(resp.Kvs[len(resp.Kvs)-1].Key)), nil
+}
+
+// streamPageSize is how many keys ListStream gets from etcd at a time, which bounds how many
+// listed objects are held in memory
+const streamPageSize = 100
+
+// ListStream lists the objects under prefix a page at a time and sends them on the returned
+// channel one by one, in key order, decoded into objects created by newFunc. Only the page
+// being sent is held in memory, so the caller can process a large prefix incrementally.
+//
+// The object channel is closed once the listing is complete, the context is done or an error
+// occurs. The error channel then receives the error, if any, and is closed. The caller must
+// receive from the object channel until it is closed, or cancel the context.
+func (s *EtcdStorage) ListStream(ctx context.Context, prefix string, newFunc func() runtime.Object) (<-chan runtime.Object, <-chan error) {
+	objects := make(chan runtime.Object)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(objects)
+
+		continueToken := ""
+		for {
+			start := time.Now()
+			resp, next, err := s.getPage(ctx, prefix, streamPageSize, continueToken)
+			s.metrics.observe(operationList, start, &err)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			for _, kv := range resp.Kvs {
+				obj := newFunc()
+				if err := decode(string(kv.Key), kv.Value, obj); err != nil {
+					errs <- err
+					return
+				}
+				setResourceVersion(obj, kv.ModRevision)
+
+				select {
+				case objects <- obj:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+
+			if next == "" {
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				errs <- err
+				return
+			}
+			continueToken = next
+		}
+	}()
+
+	return objects, errs
+}
+
+func encodeContinueToken(lastKey string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+}
+
+// decodeContinueToken returns the last key of the page the token was returned for, which must
+// be under the listed prefix
+func decodeContinueToken(prefix, token string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(data), prefix) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidContinueToken, token)
+	}
+	return string(data), nil
+}
+
+// ItemMeta is the etcd metadata of a listed object
+type ItemMeta struct {
+	Key string
+	// CreateRevision is the revision at which the object was created
+	CreateRevision int64
+	// ModRevision is the revision at which the object was last modified
+	ModRevision int64
+}
+
+// ListWithMeta lists the objects stored under prefix into listObj like List, and also returns
+// the metadata of every object, in the same order, and the revision the list was read at.
+// Comparing ModRevision with the revision of an earlier list tells which objects changed since.
+func (s *EtcdStorage) ListWithMeta(ctx context.Context, prefix string, listObj interface{}) (_ []ItemMeta, _ int64, err error) {
+	defer s.metrics.observe(operationList, time.Now(), &err)
+
+	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+
+	if err := decodeList(resp, listObj); err != nil {
+		return nil, 0, err
+	}
+
+	metas := make([]ItemMeta, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		metas[i] = ItemMeta{
+			Key:            string(kv.Key),
+			CreateRevision: kv.CreateRevision,
+			ModRevision:    kv.ModRevision,
+		}
+	}
+	return metas, resp.Header.Revision, nil
+}
+
+// maxDecodeErrorValue is how much of a value that failed to decode is quoted in the error
+const maxDecodeErrorValue = 64
+
+// decode decodes the value stored at key into obj. The error of a value that can't be decoded
+// names the key and quotes the start of the value, to help find corrupt data.
+func decode(key string, value []byte, obj runtime.Object) error {
+	if err := runtime.Decode(value, obj); err != nil {
+		snippet := value
+		if len(snippet) > maxDecodeErrorValue {
+			snippet = snippet[:maxDecodeErrorValue]
+		}
+		return fmt.Errorf("%w: key %s (%d bytes, starting %q): %v", ErrDecoding, key, len(value), snippet, err)
+	}
+	return nil
+}
+
+// decodeList replaces the slice pointed to by listObj with the objects of the response
+func decodeList(resp *clientv3.GetResponse, listObj interface{}) error {
 	listValue := reflect.ValueOf(listObj)
 	if listValue.Kind() != reflect.Ptr || listValue.Elem().Kind() != reflect.Slice {
 		return fmt.Errorf("listObj must be a pointer to a slice")
 	}
 
-	sliceValue := listValue.Elem()
-	elementType := sliceValue.Type().Elem()
+	// Replace rather than append to the destination, sized for the listed objects
+	sliceType := listValue.Elem().Type()
+	elementType := sliceType.Elem()
+	sliceValue := reflect.MakeSlice(sliceType, 0, len(resp.Kvs))
 
 	for _, kv := range resp.Kvs {
 		obj := reflect.New(elementType.Elem()).Interface().(runtime.Object)
-		if err := runtime.Decode(kv.Value, obj); err != nil {
-			return fmt.Errorf("%w: %v", ErrDecoding, err)
+		if err := decode(string(kv.Key), kv.Value, obj); err != nil {
+			return err
 		}
+		setResourceVersion(obj, kv.ModRevision)
 		sliceValue = reflect.Append(sliceValue, reflect.ValueOf(obj))
 	}
 
@@ -103,7 +656,23 @@ func (s *EtcdStorage) List(ctx context.Context, prefix string, listObj interface
 	return nil
 }
 
-func (s *EtcdStorage) DeletePrefix(ctx context.Context, prefix string) error {
+func (s *EtcdStorage) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	defer s.metrics.observe(operationDeletePrefix, time.Now(), &err)
+
+	if s.hasIndexersUnder(prefix) {
+		// Delete key by key so that the index entries are removed along with the objects
+		resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		for _, kv := range resp.Kvs {
+			if err := s.Delete(ctx, string(kv.Key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	if _, err := s.client.Delete(ctx, prefix, clientv3.WithPrefix()); err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
@@ -118,6 +687,9 @@ const (
 	EventAdd    EventType = "ADD"
 	EventUpdate EventType = "UPDATE"
 	EventDelete EventType = "DELETE"
+	// EventError ends a watch that failed, such as one whose revision was compacted. Its Err
+	// holds the error, and no event follows it.
+	EventError EventType = "ERROR"
 )
 
 // WatchEvent represents a change event from etcd
@@ -126,14 +698,124 @@ type WatchEvent struct {
 	Key      string
 	Value    []byte
 	OldValue []byte
+	// Revision is the etcd revision at which the change happened
+	Revision int64
+	// Err is the error of an EventError event, ErrCompacted if the watch fell behind compaction
+	Err error
 }
 
-// Watch watches for changes on keys with the given prefix
+// Decode decodes the value of the event into obj, or the previous value for deletions. The
+// resource version of obj is set to the revision of the change. The error of an EventError
+// event is returned as is.
+func (e WatchEvent) Decode(obj runtime.Object) error {
+	if e.Type == EventError {
+		return e.Err
+	}
+	value := e.Value
+	if e.Type == EventDelete {
+		value = e.OldValue
+	}
+	if err := decode(e.Key, value, obj); err != nil {
+		return err
+	}
+	setResourceVersion(obj, e.Revision)
+	return nil
+}
+
+// WatchOptions configures a watch
+type WatchOptions struct {
+	// BufferSize is the number of events buffered in the watch channel for a slow consumer.
+	// Once the buffer is full the watch stops reading from etcd until the consumer catches up,
+	// so events are delayed rather than dropped. Zero makes the channel unbuffered.
+	BufferSize int
+}
+
+// DefaultWatchOptions returns the default watch configuration
+func DefaultWatchOptions() WatchOptions {
+	return WatchOptions{BufferSize: 100}
+}
+
+// Watch watches for changes on keys with the given prefix, with the DefaultWatchOptions
 func (s *EtcdStorage) Watch(ctx context.Context, prefix string) (<-chan WatchEvent, error) {
-	watchChan := make(chan WatchEvent)
-	watcher := s.client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
+	return s.WatchWithOptions(ctx, prefix, DefaultWatchOptions())
+}
 
-	go s.handleWatchEvents(ctx, watcher, watchChan)
+// WatchWithOptions is Watch with the given watch configuration
+func (s *EtcdStorage) WatchWithOptions(ctx context.Context, prefix string, opts WatchOptions) (<-chan WatchEvent, error) {
+	return s.watch(ctx, prefix, 0, nil, opts, clientv3.WithPrefix())
+}
+
+// WatchFromRevision watches for changes on keys with the given prefix that happened after
+// the given revision. A revision of 0 watches for changes from now on.
+// While the watch is active, compaction does not go past the last revision it delivered.
+func (s *EtcdStorage) WatchFromRevision(ctx context.Context, prefix string, revision int64, opts WatchOptions) (<-chan WatchEvent, error) {
+	return s.watch(ctx, prefix, revision, nil, opts, clientv3.WithPrefix())
+}
+
+// WatchKey watches for changes on exactly one key. Unlike Watch, changes to other keys that
+// share the key as a prefix are not delivered.
+func (s *EtcdStorage) WatchKey(ctx context.Context, key string, opts WatchOptions) (<-chan WatchEvent, error) {
+	if key == "" {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+	return s.watch(ctx, key, 0, nil, opts)
+}
+
+// WatchFiltered watches for changes on keys with the given prefix and only forwards events
+// of the given types. Events are otherwise identical to those of Watch, including OldValue.
+// Calling it without types forwards all events.
+func (s *EtcdStorage) WatchFiltered(ctx context.Context, prefix string, types ...EventType) (<-chan WatchEvent, error) {
+	var filter map[EventType]bool
+	if len(types) > 0 {
+		filter = make(map[EventType]bool, len(types))
+		for _, eventType := range types {
+			switch eventType {
+			case EventAdd, EventUpdate, EventDelete:
+				filter[eventType] = true
+			default:
+				return nil, fmt.Errorf("invalid event type %q", eventType)
+			}
+		}
+	}
+
+	return s.watch(ctx, prefix, 0, filter, DefaultWatchOptions(), clientv3.WithPrefix())
+}
+
+// watch starts a watch on key, which opts can turn into a prefix. A nil filter forwards all event types.
+// The watch metrics cover starting the watch, not the lifetime of the watch.
+func (s *EtcdStorage) watch(ctx context.Context, key string, revision int64, filter map[EventType]bool, watchOpts WatchOptions, opts ...clientv3.OpOption) (_ <-chan WatchEvent, err error) {
+	defer s.metrics.observe(operationWatch, time.Now(), &err)
+
+	if revision < 0 {
+		return nil, fmt.Errorf("invalid revision %d", revision)
+	}
+
+	if revision == 0 {
+		current, err := s.CurrentRevision(ctx)
+		if err != nil {
+			return nil, err
+		}
+		revision = current
+	}
+
+	opts = append(opts, clientv3.WithPrevKV(), clientv3.WithRev(revision+1))
+	// Let etcd drop whole classes of events that would be filtered out anyway
+	if filter != nil && !filter[EventAdd] && !filter[EventUpdate] {
+		opts = append(opts, clientv3.WithFilterPut())
+	}
+	if filter != nil && !filter[EventDelete] {
+		opts = append(opts, clientv3.WithFilterDelete())
+	}
+
+	if watchOpts.BufferSize < 0 {
+		return nil, fmt.Errorf("invalid watch buffer size %d", watchOpts.BufferSize)
+	}
+
+	watchChan := make(chan WatchEvent, watchOpts.BufferSize)
+	watcherID := s.watchers.register(revision, func() int { return len(watchChan) })
+	watcher := s.client.Watch(ctx, key, opts...)
+
+	go s.handleWatchEvents(ctx, watcherID, watcher, filter, watchChan)
 
 	return watchChan, nil
 }
@@ -141,35 +823,66 @@ func (s *EtcdStorage) Watch(ctx context.Context, prefix string) (<-chan WatchEve
 // handleWatchEvents processes events from etcd and sends them to the watch channel
 func (s *EtcdStorage) handleWatchEvents(
 	ctx context.Context,
+	watcherID int64,
 	watcher clientv3.WatchChan,
+	filter map[EventType]bool,
 	watchChan chan<- WatchEvent,
 ) {
 	defer close(watchChan)
+	defer s.watchers.unregister(watcherID)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case resp, ok := <-watcher:
-			if !ok || resp.Canceled {
+			if !ok {
+				return
+			}
+			if err := resp.Err(); err != nil || resp.Canceled {
+				s.sendWatchError(ctx, resp, watchChan)
 				return
 			}
-			s.processWatchResponse(ctx, resp, watchChan)
+			s.processWatchResponse(ctx, watcherID, resp, filter, watchChan)
 		}
 	}
 }
 
+// sendWatchError ends the watch with an EventError event for the response that cancelled it,
+// so that the consumer knows to list again rather than mistaking it for a closed watch
+func (s *EtcdStorage) sendWatchError(ctx context.Context, resp clientv3.WatchResponse, watchChan chan<- WatchEvent) {
+	err := fmt.Errorf("%w: watch cancelled", ErrEtcdClient)
+	switch {
+	case resp.CompactRevision != 0:
+		err = fmt.Errorf("%w: history compacted up to revision %d", ErrCompacted, resp.CompactRevision)
+	case resp.Err() != nil:
+		err = fmt.Errorf("%w: %v", ErrEtcdClient, resp.Err())
+	}
+
+	select {
+	case watchChan <- WatchEvent{Type: EventError, Err: err}:
+	case <-ctx.Done():
+	}
+}
+
 // processWatchResponse handles a single watch response from etcd
 func (s *EtcdStorage) processWatchResponse(
 	ctx context.Context,
+	watcherID int64,
 	resp clientv3.WatchResponse,
+	filter map[EventType]bool,
 	watchChan chan<- WatchEvent,
 ) {
 	for _, event := range resp.Events {
 		watchEvent := s.convertToWatchEvent(event)
+		if filter != nil && !filter[watchEvent.Type] {
+			s.watchers.update(watcherID, watchEvent.Revision)
+			continue
+		}
 
 		select {
 		case watchChan <- watchEvent:
+			s.watchers.sent(watcherID, watchEvent.Revision)
 		case <-ctx.Done():
 			return
 		}
@@ -179,9 +892,10 @@ func (s *EtcdStorage) processWatchResponse(
 // convertToWatchEvent converts an etcd event to our WatchEvent type
 func (s *EtcdStorage) convertToWatchEvent(event *clientv3.Event) WatchEvent {
 	watchEvent := WatchEvent{
-		Type:  convertEventType(event),
-		Key:   string(event.Kv.Key),
-		Value: event.Kv.Value,
+		Type:     convertEventType(event),
+		Key:      string(event.Kv.Key),
+		Value:    event.Kv.Value,
+		Revision: event.Kv.ModRevision,
 	}
 
 	if event.PrevKv != nil {
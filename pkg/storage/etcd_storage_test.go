@@ -2,10 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"gokube/pkg/etcdclient"
+	"gokube/pkg/runtime"
+
 	"github.com/stretchr/testify/assert"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -32,6 +40,63 @@ func TestEtcdStorage_Create(t *testing.T) {
 	})
 }
 
+func TestEtcdStorage_CreateWithTTL(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, storage.CreateWithTTL(ctx, "ttl-key-1", &TestObject{Name: "first"}, time.Minute))
+
+		t.Run("should not overwrite a stored object", func(t *testing.T) {
+			err := storage.CreateWithTTL(ctx, "ttl-key-1", &TestObject{Name: "second"}, time.Minute)
+			assert.ErrorIs(t, err, ErrAlreadyExists)
+
+			var obj TestObject
+			require.NoError(t, storage.Get(ctx, "ttl-key-1", &obj))
+			assert.Equal(t, "first", obj.Name)
+		})
+
+		t.Run("should share a lease between objects created with the same TTL", func(t *testing.T) {
+			require.NoError(t, storage.CreateWithTTL(ctx, "ttl-key-2", &TestObject{Name: "other"}, time.Minute))
+			require.NoError(t, storage.CreateWithTTL(ctx, "ttl-key-3", &TestObject{Name: "short"}, time.Second))
+
+			leases, err := cli.Leases(ctx)
+			require.NoError(t, err)
+			assert.Len(t, leases.Leases, 2, "one lease per TTL")
+
+			ttl, err := cli.TimeToLive(ctx, leases.Leases[0].ID, clientv3.WithAttachedKeys())
+			require.NoError(t, err)
+			other, err := cli.TimeToLive(ctx, leases.Leases[1].ID, clientv3.WithAttachedKeys())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []int{1, 2}, []int{len(ttl.Keys), len(other.Keys)})
+		})
+	})
+}
+
+func TestNewEtcdStorageFromConfig(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx := context.Background()
+
+		config := etcdclient.DefaultConfig()
+		config.Endpoints = cli.Endpoints()
+		storage, err := NewEtcdStorageFromConfig(config)
+		require.NoError(t, err)
+
+		require.NoError(t, storage.Create(ctx, "test-key", &TestObject{Name: "test-value"}))
+		var obj TestObject
+		require.NoError(t, NewEtcdStorage(cli).Get(ctx, "test-key", &obj))
+		assert.Equal(t, "test-value", obj.Name)
+		assert.NoError(t, storage.Close())
+
+		// Invalid TLS settings are reported before dialing
+		config.Security.CAFile = "/does/not/exist/ca.pem"
+		storage, err = NewEtcdStorageFromConfig(config)
+		assert.Nil(t, storage)
+		assert.ErrorIs(t, err, etcdclient.ErrInvalidConfig)
+	})
+}
+
 func TestEtcdStorage_Update(t *testing.T) {
 	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
 		storage := NewEtcdStorage(cli)
@@ -54,6 +119,39 @@ func TestEtcdStorage_Update(t *testing.T) {
 	})
 }
 
+func TestEtcdStorage_UpdateAtRevision(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		obj := &versionedObject{Name: "first"}
+		require.NoError(t, storage.Create(ctx, "test-key", obj))
+		staleRevision, err := strconv.ParseInt(obj.ResourceVersion, 10, 64)
+		require.NoError(t, err)
+
+		// Update ignores the resource version the object carries
+		stale := &versionedObject{Name: "second", ResourceVersion: obj.ResourceVersion}
+		require.NoError(t, storage.Update(ctx, "test-key", stale))
+		require.NoError(t, storage.Update(ctx, "test-key", &versionedObject{Name: "third", ResourceVersion: obj.ResourceVersion}))
+
+		err = storage.UpdateAtRevision(ctx, "test-key", &versionedObject{Name: "fourth"}, staleRevision)
+		assert.ErrorIs(t, err, ErrConflict)
+		stored := &versionedObject{}
+		require.NoError(t, storage.Get(ctx, "test-key", stored))
+		assert.Equal(t, "third", stored.Name)
+
+		revision, err := strconv.ParseInt(stored.ResourceVersion, 10, 64)
+		require.NoError(t, err)
+		require.NoError(t, storage.UpdateAtRevision(ctx, "test-key", &versionedObject{Name: "fourth"}, revision))
+		require.NoError(t, storage.Get(ctx, "test-key", stored))
+		assert.Equal(t, "fourth", stored.Name)
+
+		err = storage.UpdateAtRevision(ctx, "missing-key", &versionedObject{Name: "fourth"}, revision)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+}
+
 func TestEtcdStorage_Delete(t *testing.T) {
 	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
 		storage := NewEtcdStorage(cli)
@@ -73,6 +171,35 @@ func TestEtcdStorage_Delete(t *testing.T) {
 	})
 }
 
+func TestEtcdStorage_DeleteAtRevision(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, storage.Create(ctx, "test-key", &TestObject{Name: "test-value"}))
+		resp, err := cli.Get(ctx, "test-key")
+		require.NoError(t, err)
+		staleRevision := resp.Kvs[0].ModRevision
+
+		require.NoError(t, storage.Update(ctx, "test-key", &TestObject{Name: "updated-value"}))
+		resp, err = cli.Get(ctx, "test-key")
+		require.NoError(t, err)
+		revision := resp.Kvs[0].ModRevision
+
+		err = storage.DeleteAtRevision(ctx, "test-key", staleRevision)
+		assert.ErrorIs(t, err, ErrConflict)
+		var retrievedObj TestObject
+		require.NoError(t, storage.Get(ctx, "test-key", &retrievedObj), "a stale delete should keep the object")
+
+		require.NoError(t, storage.DeleteAtRevision(ctx, "test-key", revision))
+		assert.ErrorIs(t, storage.Get(ctx, "test-key", &retrievedObj), ErrNotFound)
+
+		err = storage.DeleteAtRevision(ctx, "test-key", revision)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
 func TestEtcdStorage_List(t *testing.T) {
 	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
 		storage := NewEtcdStorage(cli)
@@ -96,6 +223,122 @@ func TestEtcdStorage_List(t *testing.T) {
 	})
 }
 
+func TestEtcdStorage_ListReplacesDestination(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		obj := &TestObject{Name: "value1"}
+		require.NoError(t, storage.Create(ctx, "/prefix/key1", obj))
+
+		stale := &TestObject{Name: "stale"}
+		list := []*TestObject{stale, stale, stale}
+		require.NoError(t, storage.List(ctx, "/prefix/", &list))
+		assert.Equal(t, []*TestObject{obj}, list)
+
+		// An empty prefix yields an empty slice rather than the previous contents
+		require.NoError(t, storage.List(ctx, "/missing/", &list))
+		assert.NotNil(t, list)
+		assert.Empty(t, list)
+	})
+}
+
+func TestEtcdStorage_DecodeErrorNamesKey(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, storage.Create(ctx, "/corrupt/good", &TestObject{Name: "good"}))
+		const badValue = `{"name": truncated`
+		_, err := cli.Put(ctx, "/corrupt/bad", badValue)
+		require.NoError(t, err)
+
+		assertNamesBadKey := func(t *testing.T, err error) {
+			assert.ErrorIs(t, err, ErrDecoding)
+			assert.ErrorContains(t, err, fmt.Sprintf("key /corrupt/bad (%d bytes, starting %q)", len(badValue), badValue))
+		}
+
+		t.Run("List", func(t *testing.T) {
+			var list []*TestObject
+			assertNamesBadKey(t, storage.List(ctx, "/corrupt/", &list))
+		})
+
+		t.Run("Get", func(t *testing.T) {
+			assertNamesBadKey(t, storage.Get(ctx, "/corrupt/bad", &TestObject{}))
+		})
+
+		t.Run("GuaranteedUpdate", func(t *testing.T) {
+			err := storage.GuaranteedUpdate(ctx, "/corrupt/bad", &TestObject{}, func(obj runtime.Object) error { return nil })
+			assertNamesBadKey(t, err)
+		})
+
+		t.Run("Watch", func(t *testing.T) {
+			watchCtx, stopWatch := context.WithCancel(ctx)
+			defer stopWatch()
+			events, err := storage.WatchKey(watchCtx, "/corrupt/bad", DefaultWatchOptions())
+			require.NoError(t, err)
+
+			_, err = cli.Put(ctx, "/corrupt/bad", badValue)
+			require.NoError(t, err)
+
+			select {
+			case event := <-events:
+				assertNamesBadKey(t, event.Decode(&TestObject{}))
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for watch event")
+			}
+		})
+
+		t.Run("long values are truncated", func(t *testing.T) {
+			value := `{"name": "` + strings.Repeat("x", 100)
+			err := decode("/corrupt/long", []byte(value), &TestObject{})
+			assert.ErrorContains(t, err, fmt.Sprintf("key /corrupt/long (%d bytes, starting %q)", len(value), value[:maxDecodeErrorValue]))
+		})
+	})
+}
+
+func TestEtcdStorage_ListWithMeta(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		prefix := "/list-meta/"
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, storage.Create(ctx, prefix+"a", &TestObject{Name: "a"}))
+		require.NoError(t, storage.Create(ctx, prefix+"b", &TestObject{Name: "b"}))
+
+		var objects []*TestObject
+		metas, revision, err := storage.ListWithMeta(ctx, prefix, &objects)
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		require.Len(t, metas, 2)
+
+		resp, err := cli.Get(ctx, prefix, clientv3.WithPrefix())
+		require.NoError(t, err)
+		assert.Equal(t, resp.Header.Revision, revision)
+		for i, kv := range resp.Kvs {
+			assert.Equal(t, string(kv.Key), metas[i].Key)
+			assert.Equal(t, kv.CreateRevision, metas[i].CreateRevision)
+			assert.Equal(t, kv.ModRevision, metas[i].ModRevision)
+			assert.Equal(t, string(kv.Key), prefix+objects[i].Name, "metadata is in the order of the objects")
+		}
+
+		require.NoError(t, storage.Update(ctx, prefix+"a", &TestObject{Name: "a"}))
+
+		updatedMetas, updatedRevision, err := storage.ListWithMeta(ctx, prefix, &objects)
+		require.NoError(t, err)
+		assert.Greater(t, updatedRevision, revision)
+
+		// Only the updated object is modified after the earlier list
+		assert.Greater(t, updatedMetas[0].ModRevision, revision)
+		assert.Equal(t, metas[0].CreateRevision, updatedMetas[0].CreateRevision)
+		assert.Equal(t, metas[1].ModRevision, updatedMetas[1].ModRevision)
+		assert.LessOrEqual(t, updatedMetas[1].ModRevision, revision)
+	})
+}
+
 func TestEtcdStorage_Watch(t *testing.T) {
 	t.Run("should watch all CRUD operations", func(t *testing.T) {
 		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
@@ -227,6 +470,188 @@ func TestEtcdStorage_Watch(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("should block on a full buffer without dropping events", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			prefix := "/buffered-watch/"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			watchChan, err := storage.WatchWithOptions(ctx, prefix, WatchOptions{BufferSize: 2})
+			require.NoError(t, err)
+			assert.Equal(t, 2, cap(watchChan))
+
+			keys := []string{"key1", "key2", "key3", "key4", "key5"}
+			for _, key := range keys {
+				require.NoError(t, storage.Create(ctx, prefix+key, &TestObject{Name: key}))
+			}
+
+			// The buffer fills up while nothing is consumed, the remaining events wait
+			assert.Eventually(t, func() bool { return len(watchChan) == 2 }, time.Second, 10*time.Millisecond)
+			for _, key := range keys {
+				verifyWatchEvent(t, watchChan, watchExpectation{eventType: EventAdd, key: prefix + key, hasValue: true})
+			}
+		})
+	})
+
+	t.Run("should reject a negative buffer size", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			_, err := NewEtcdStorage(cli).WatchWithOptions(context.Background(), "/test/", WatchOptions{BufferSize: -1})
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestEtcdStorage_WatchFromRevision(t *testing.T) {
+	t.Run("should replay changes made after the given revision", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			prefix := "/watch-rev/"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, storage.Create(ctx, prefix+"key1", &TestObject{Name: "test1"}))
+			resp, err := cli.Get(ctx, prefix+"key1")
+			require.NoError(t, err)
+			revision := resp.Kvs[0].ModRevision
+
+			require.NoError(t, storage.Create(ctx, prefix+"key2", &TestObject{Name: "test2"}))
+
+			watchChan, err := storage.WatchFromRevision(ctx, prefix, revision, WatchOptions{BufferSize: 3})
+			require.NoError(t, err)
+			assert.Equal(t, 3, cap(watchChan))
+
+			select {
+			case event := <-watchChan:
+				assert.Equal(t, EventAdd, event.Type)
+				assert.Equal(t, prefix+"key2", event.Key)
+				assert.Greater(t, event.Revision, revision)
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for event")
+			}
+		})
+	})
+
+	t.Run("should reject negative revision", func(t *testing.T) {
+		storage := NewEtcdStorage(nil)
+
+		_, err := storage.WatchFromRevision(context.Background(), "/test/", -1, DefaultWatchOptions())
+		assert.Error(t, err)
+	})
+}
+
+func TestEtcdStorage_WatchKey(t *testing.T) {
+	t.Run("should only deliver events for the watched key", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			watchChan, err := storage.WatchKey(ctx, "/pods/web", WatchOptions{BufferSize: 1})
+			require.NoError(t, err)
+			assert.Equal(t, 1, cap(watchChan))
+
+			// Neighbors, including a key that has the watched key as its prefix, are not delivered
+			require.NoError(t, storage.Create(ctx, "/pods/web-1", &TestObject{Name: "sibling"}))
+			require.NoError(t, storage.Create(ctx, "/pods/other", &TestObject{Name: "other"}))
+			require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "created"}))
+			require.NoError(t, storage.Update(ctx, "/pods/web-1", &TestObject{Name: "sibling-updated"}))
+			require.NoError(t, storage.Update(ctx, "/pods/web", &TestObject{Name: "updated"}))
+			require.NoError(t, storage.Delete(ctx, "/pods/web"))
+
+			for _, expected := range []EventType{EventAdd, EventUpdate, EventDelete} {
+				select {
+				case event := <-watchChan:
+					assert.Equal(t, expected, event.Type)
+					assert.Equal(t, "/pods/web", event.Key)
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for %s event", expected)
+				}
+			}
+
+			select {
+			case event := <-watchChan:
+				t.Fatalf("unexpected %s event for %s", event.Type, event.Key)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	})
+
+	t.Run("should reject an empty key", func(t *testing.T) {
+		storage := NewEtcdStorage(nil)
+
+		_, err := storage.WatchKey(context.Background(), "", DefaultWatchOptions())
+		assert.Error(t, err)
+	})
+}
+
+func TestEtcdStorage_WatchFiltered(t *testing.T) {
+	t.Run("should only deliver requested event types", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			prefix := "/watch-filtered/"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			watchChan, err := storage.WatchFiltered(ctx, prefix, EventDelete)
+			require.NoError(t, err)
+
+			require.NoError(t, storage.Create(ctx, prefix+"key1", &TestObject{Name: "test1"}))
+			require.NoError(t, storage.Update(ctx, prefix+"key1", &TestObject{Name: "test1-updated"}))
+			require.NoError(t, storage.Create(ctx, prefix+"key2", &TestObject{Name: "test2"}))
+			require.NoError(t, storage.Delete(ctx, prefix+"key1"))
+
+			verifyWatchEvent(t, watchChan, watchExpectation{
+				eventType:   EventDelete,
+				key:         prefix + "key1",
+				hasValue:    false,
+				hasOldValue: true,
+			})
+
+			select {
+			case event := <-watchChan:
+				t.Fatalf("unexpected event %s for %s", event.Type, event.Key)
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	})
+
+	t.Run("should tell adds from updates when filtering puts", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			prefix := "/watch-filtered/"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			watchChan, err := storage.WatchFiltered(ctx, prefix, EventUpdate)
+			require.NoError(t, err)
+
+			require.NoError(t, storage.Create(ctx, prefix+"key1", &TestObject{Name: "test1"}))
+			require.NoError(t, storage.Update(ctx, prefix+"key1", &TestObject{Name: "test1-updated"}))
+			require.NoError(t, storage.Delete(ctx, prefix+"key1"))
+
+			verifyWatchEvent(t, watchChan, watchExpectation{
+				eventType:   EventUpdate,
+				key:         prefix + "key1",
+				hasValue:    true,
+				hasOldValue: true,
+			})
+
+			select {
+			case event := <-watchChan:
+				t.Fatalf("unexpected event %s for %s", event.Type, event.Key)
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	})
+
+	t.Run("should reject unknown event types", func(t *testing.T) {
+		storage := NewEtcdStorage(nil)
+
+		_, err := storage.WatchFiltered(context.Background(), "/test/", EventType("BOOKMARK"))
+		assert.Error(t, err)
+	})
 }
 
 type watchExpectation struct {
@@ -266,3 +691,263 @@ func verifyChannelClosed(t *testing.T, watchChan <-chan WatchEvent) {
 		t.Fatal("Timed out waiting for channel to close")
 	}
 }
+
+func TestEtcdStorage_GuaranteedUpdate(t *testing.T) {
+	t.Run("should apply update to the stored object", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, storage.Create(ctx, "test-key", &TestObject{Name: "test-value"}))
+
+			var obj TestObject
+			err := storage.GuaranteedUpdate(ctx, "test-key", &obj, func(current runtime.Object) error {
+				current.(*TestObject).Name += "-updated"
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "test-value-updated", obj.Name)
+
+			var retrievedObj TestObject
+			require.NoError(t, storage.Get(ctx, "test-key", &retrievedObj))
+			assert.Equal(t, "test-value-updated", retrievedObj.Name)
+		})
+	})
+
+	t.Run("should not write when tryUpdate returns an error", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, storage.Create(ctx, "test-key", &TestObject{Name: "test-value"}))
+
+			errAbort := errors.New("abort")
+			err := storage.GuaranteedUpdate(ctx, "test-key", &TestObject{}, func(current runtime.Object) error {
+				current.(*TestObject).Name = "should-not-be-written"
+				return errAbort
+			})
+			assert.ErrorIs(t, err, errAbort)
+
+			var retrievedObj TestObject
+			require.NoError(t, storage.Get(ctx, "test-key", &retrievedObj))
+			assert.Equal(t, "test-value", retrievedObj.Name)
+		})
+	})
+
+	t.Run("should return not found for missing key", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+
+			err := storage.GuaranteedUpdate(context.Background(), "missing-key", &TestObject{}, func(runtime.Object) error {
+				return nil
+			})
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	})
+
+	t.Run("should retry on concurrent modification", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			storage := NewEtcdStorage(cli)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, storage.Create(ctx, "test-key", &TestObject{Name: "v"}))
+
+			attempts := 0
+			err := storage.GuaranteedUpdate(ctx, "test-key", &TestObject{}, func(current runtime.Object) error {
+				attempts++
+				if attempts == 1 {
+					// Simulate another writer modifying the key between read and write
+					require.NoError(t, storage.Update(ctx, "test-key", &TestObject{Name: "concurrent"}))
+				}
+				current.(*TestObject).Name += "-updated"
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 2, attempts)
+
+			var retrievedObj TestObject
+			require.NoError(t, storage.Get(ctx, "test-key", &retrievedObj))
+			assert.Equal(t, "concurrent-updated", retrievedObj.Name)
+		})
+	})
+}
+
+func TestEtcdStorage_ListPaged(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		for i := 1; i <= 5; i++ {
+			require.NoError(t, storage.Create(ctx, fmt.Sprintf("/paged/key%d", i), &TestObject{Name: fmt.Sprintf("value%d", i)}))
+		}
+		require.NoError(t, storage.Create(ctx, "/paged0/other", &TestObject{Name: "other"}))
+
+		var names []string
+		token := ""
+		pages := 0
+		for {
+			var page []*TestObject
+			next, err := storage.ListPaged(ctx, "/paged/", 2, token, &page)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(page), 2)
+			for _, obj := range page {
+				names = append(names, obj.Name)
+			}
+			pages++
+			if next == "" {
+				break
+			}
+			token = next
+		}
+		assert.Equal(t, []string{"value1", "value2", "value3", "value4", "value5"}, names)
+		assert.Equal(t, 3, pages)
+
+		// Without a limit the remaining objects are listed at once
+		var all []*TestObject
+		next, err := storage.ListPaged(ctx, "/paged/", 0, "", &all)
+		require.NoError(t, err)
+		assert.Empty(t, next)
+		assert.Len(t, all, 5)
+
+		var page []*TestObject
+		_, err = storage.ListPaged(ctx, "/paged/", 2, "not base64!", &page)
+		assert.ErrorIs(t, err, ErrInvalidContinueToken)
+		_, err = storage.ListPaged(ctx, "/paged/", 2, encodeContinueToken("/other/key1"), &page)
+		assert.ErrorIs(t, err, ErrInvalidContinueToken)
+	})
+}
+
+func TestEtcdStorage_ListStream(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		const count = 500
+		for i := 0; i < count; i++ {
+			require.NoError(t, storage.Create(ctx, fmt.Sprintf("/stream/key%04d", i), &TestObject{Name: fmt.Sprintf("value%04d", i)}))
+		}
+		require.NoError(t, storage.Create(ctx, "/stream0/other", &TestObject{Name: "other"}))
+
+		// Every object decoded by the stream is created by newFunc
+		var decoded atomic.Int32
+		newFunc := func() runtime.Object {
+			decoded.Add(1)
+			return &TestObject{}
+		}
+		objects, errs := storage.ListStream(ctx, "/stream/", newFunc)
+
+		first := <-objects
+		require.NotNil(t, first)
+		assert.Equal(t, "value0000", first.(*TestObject).Name)
+		// The stream doesn't run ahead of the receiver by more than a page
+		time.Sleep(100 * time.Millisecond)
+		assert.LessOrEqual(t, decoded.Load(), int32(streamPageSize+1))
+
+		names := []string{first.(*TestObject).Name}
+		for obj := range objects {
+			names = append(names, obj.(*TestObject).Name)
+		}
+		require.NoError(t, <-errs)
+		require.Len(t, names, count)
+		for i, name := range names {
+			assert.Equal(t, fmt.Sprintf("value%04d", i), name)
+		}
+
+		// Cancelling the context stops the stream
+		streamCtx, cancelStream := context.WithCancel(ctx)
+		objects, errs = storage.ListStream(streamCtx, "/stream/", newFunc)
+		<-objects
+		cancelStream()
+		for range objects {
+		}
+		assert.ErrorIs(t, <-errs, context.Canceled)
+	})
+}
+
+// versionedObject is a TestObject carrying the resource version of its stored state
+type versionedObject struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+func (o *versionedObject) GetResourceVersion() string        { return o.ResourceVersion }
+func (o *versionedObject) SetResourceVersion(version string) { o.ResourceVersion = version }
+
+func TestEtcdStorage_CreateOrUpdate(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		t.Run("creates a missing object", func(t *testing.T) {
+			obj := &versionedObject{Name: "first"}
+			created, err := storage.CreateOrUpdate(ctx, "/upsert/key", obj, nil)
+			require.NoError(t, err)
+			assert.True(t, created)
+			require.NotEmpty(t, obj.ResourceVersion)
+
+			stored := &versionedObject{}
+			require.NoError(t, storage.Get(ctx, "/upsert/key", stored))
+			assert.Equal(t, "first", stored.Name)
+			assert.Equal(t, obj.ResourceVersion, stored.ResourceVersion)
+		})
+
+		t.Run("updates an existing object", func(t *testing.T) {
+			before := &versionedObject{}
+			require.NoError(t, storage.Get(ctx, "/upsert/key", before))
+
+			// A stale resource version doesn't stop the write
+			obj := &versionedObject{Name: "second", ResourceVersion: "1"}
+			created, err := storage.CreateOrUpdate(ctx, "/upsert/key", obj, nil)
+			require.NoError(t, err)
+			assert.False(t, created)
+			assert.NotEqual(t, before.ResourceVersion, obj.ResourceVersion)
+
+			stored := &versionedObject{}
+			require.NoError(t, storage.Get(ctx, "/upsert/key", stored))
+			assert.Equal(t, "second", stored.Name)
+			assert.Equal(t, obj.ResourceVersion, stored.ResourceVersion)
+
+			resp, err := cli.Get(ctx, "/upsert/key")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), resp.Kvs[0].Version, "the key should be updated rather than recreated")
+		})
+
+		t.Run("lets the object keep fields of the stored object", func(t *testing.T) {
+			var preserved *versionedObject
+			obj := &versionedObject{Name: "third"}
+			created, err := storage.CreateOrUpdate(ctx, "/upsert/key", obj, func(stored runtime.Object) {
+				preserved = stored.(*versionedObject)
+				obj.Name = preserved.Name + "-kept"
+			})
+			require.NoError(t, err)
+			assert.False(t, created)
+			require.NotNil(t, preserved)
+			assert.NotEmpty(t, preserved.ResourceVersion)
+
+			stored := &versionedObject{}
+			require.NoError(t, storage.Get(ctx, "/upsert/key", stored))
+			assert.Equal(t, "second-kept", stored.Name)
+		})
+
+		t.Run("keeps the index of indexed objects in sync", func(t *testing.T) {
+			addGroupIndexer(storage)
+
+			created, err := storage.CreateOrUpdate(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g1"}, nil)
+			require.NoError(t, err)
+			assert.True(t, created)
+			assert.Equal(t, []string{"a"}, listGroup(t, storage, "g1"))
+
+			created, err = storage.CreateOrUpdate(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g2"}, nil)
+			require.NoError(t, err)
+			assert.False(t, created)
+			assert.Empty(t, listGroup(t, storage, "g1"))
+			assert.Equal(t, []string{"a"}, listGroup(t, storage, "g2"))
+		})
+	})
+}
@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gokube/pkg/runtime"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const indexPrefix = "/indexes/"
+
+// IndexFunc returns the value an object is indexed under.
+// An empty value leaves the object out of the index.
+type IndexFunc func(obj runtime.Object) string
+
+// Indexer describes a secondary index over the objects stored under a prefix.
+// Index entries are written in the same transaction as the object itself, so the index
+// always reflects the latest committed state of the objects.
+type Indexer struct {
+	// Name identifies the index
+	Name string
+	// Prefix is the key prefix of the indexed objects
+	Prefix string
+	// NewObject returns an empty object to decode stored values into
+	NewObject func() runtime.Object
+	// IndexFunc computes the index value of an object
+	IndexFunc IndexFunc
+}
+
+// IndexedStorage is implemented by storages that can maintain secondary indexes
+type IndexedStorage interface {
+	// AddIndexer registers an index. Registering an index with the same name again replaces it.
+	// Objects stored before the index was registered are indexed too.
+	AddIndexer(indexer Indexer)
+	// ListByIndex lists the objects whose index value matches the given value
+	ListByIndex(ctx context.Context, indexName, value string, listObj interface{}) error
+}
+
+// AddIndexer registers an index that is maintained on every write under the indexer's prefix.
+// Objects written before the index was added are backfilled into it before it is first listed.
+func (s *EtcdStorage) AddIndexer(indexer Indexer) {
+	s.indexMutex.Lock()
+	defer s.indexMutex.Unlock()
+
+	if s.indexers == nil {
+		s.indexers = make(map[string]Indexer)
+		s.backfilled = make(map[string]bool)
+	}
+	s.indexers[indexer.Name] = indexer
+	delete(s.backfilled, indexer.Name)
+}
+
+// ListByIndex lists the objects whose index value matches the given value
+func (s *EtcdStorage) ListByIndex(ctx context.Context, indexName, value string, listObj interface{}) error {
+	s.indexMutex.RLock()
+	indexer, ok := s.indexers[indexName]
+	backfilled := s.backfilled[indexName]
+	s.indexMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown index %q", indexName)
+	}
+
+	if !backfilled {
+		if err := s.backfillOnce(ctx, indexer); err != nil {
+			return err
+		}
+	}
+	return s.listIndexed(ctx, indexValuePrefix(indexName, value), listObj)
+}
+
+// maxIndexedGets is the number of objects read per transaction when listing by index, below
+// the default limit of etcd on the operations of a transaction
+const maxIndexedGets = 100
+
+// listIndexed lists the objects the index entries under the prefix refer to. The objects are
+// read from their own keys, at the revision of the entries, so that they carry their own
+// resource version rather than the one of the entry.
+func (s *EtcdStorage) listIndexed(ctx context.Context, prefix string, listObj interface{}) (err error) {
+	defer s.metrics.observe(operationList, time.Now(), &err)
+
+	entries, err := s.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+
+	objects := &clientv3.GetResponse{Header: entries.Header}
+	for start := 0; start < len(entries.Kvs); start += maxIndexedGets {
+		end := min(start+maxIndexedGets, len(entries.Kvs))
+		ops := make([]clientv3.Op, 0, end-start)
+		for _, kv := range entries.Kvs[start:end] {
+			key := "/" + strings.TrimPrefix(string(kv.Key), prefix)
+			ops = append(ops, clientv3.OpGet(key, clientv3.WithRev(entries.Header.Revision)))
+		}
+		txnResp, err := s.client.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		for _, op := range txnResp.Responses {
+			objects.Kvs = append(objects.Kvs, op.GetResponseRange().Kvs...)
+		}
+	}
+	return decodeList(objects, listObj)
+}
+
+// backfillOnce backfills the index unless it has been backfilled already
+func (s *EtcdStorage) backfillOnce(ctx context.Context, indexer Indexer) error {
+	s.backfillMutex.Lock()
+	defer s.backfillMutex.Unlock()
+
+	s.indexMutex.RLock()
+	backfilled := s.backfilled[indexer.Name]
+	s.indexMutex.RUnlock()
+	if backfilled {
+		return nil
+	}
+
+	if err := s.backfill(ctx, indexer); err != nil {
+		return err
+	}
+
+	s.indexMutex.Lock()
+	defer s.indexMutex.Unlock()
+	s.backfilled[indexer.Name] = true
+	return nil
+}
+
+// backfill writes the index entries of the objects stored under the prefix of the indexer, such
+// as those written before the index was added, and removes the entries that don't match the
+// stored objects. An entry is only written while its object is at the revision it was read at,
+// and only removed while it is unchanged since, as writes racing with the backfill maintain the
+// index themselves. Entries already matching their object are left alone, so that backfilling
+// an index that is up to date only reads it.
+func (s *EtcdStorage) backfill(ctx context.Context, indexer Indexer) error {
+	stored, err := s.client.Get(ctx, indexPrefix+indexer.Name+"/", clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	storedValues := make(map[string][]byte, len(stored.Kvs))
+	for _, kv := range stored.Kvs {
+		storedValues[string(kv.Key)] = kv.Value
+	}
+
+	resp, err := s.client.Get(ctx, indexer.Prefix, clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+
+	entries := make(map[string]bool, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		obj := indexer.NewObject()
+		if err := decode(key, kv.Value, obj); err != nil {
+			return err
+		}
+		value := indexer.IndexFunc(obj)
+		if value == "" {
+			continue
+		}
+
+		entry := indexKey(indexer.Name, value, key)
+		entries[entry] = true
+		if storedValue, ok := storedValues[entry]; ok && bytes.Equal(storedValue, kv.Value) {
+			continue
+		}
+		_, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(entry, string(kv.Value))).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+	}
+
+	for _, kv := range stored.Kvs {
+		entry := string(kv.Key)
+		if entries[entry] {
+			continue
+		}
+		_, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(entry), "=", kv.ModRevision)).
+			Then(clientv3.OpDelete(entry)).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+	}
+	return nil
+}
+
+// indexersFor returns the indexers covering the given key
+func (s *EtcdStorage) indexersFor(key string) []Indexer {
+	s.indexMutex.RLock()
+	defer s.indexMutex.RUnlock()
+
+	var indexers []Indexer
+	for _, indexer := range s.indexers {
+		if strings.HasPrefix(key, indexer.Prefix) {
+			indexers = append(indexers, indexer)
+		}
+	}
+	return indexers
+}
+
+// hasIndexersUnder reports whether any indexed keys may live under the given prefix
+func (s *EtcdStorage) hasIndexersUnder(prefix string) bool {
+	s.indexMutex.RLock()
+	defer s.indexMutex.RUnlock()
+
+	for _, indexer := range s.indexers {
+		if strings.HasPrefix(indexer.Prefix, prefix) || strings.HasPrefix(prefix, indexer.Prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func indexValuePrefix(indexName, value string) string {
+	return fmt.Sprintf("%s%s/%s/", indexPrefix, indexName, value)
+}
+
+func indexKey(indexName, value, key string) string {
+	return indexValuePrefix(indexName, value) + strings.TrimPrefix(key, "/")
+}
+
+// indexOps returns the operations that move the index entries of key from the previously
+// stored value to the new object. A nil oldValue means the key did not exist and a nil
+// obj means the key is being deleted.
+func indexOps(indexers []Indexer, key string, oldValue []byte, obj runtime.Object, data []byte) ([]clientv3.Op, error) {
+	var ops []clientv3.Op
+	for _, indexer := range indexers {
+		oldIndexValue := ""
+		if oldValue != nil {
+			oldObj := indexer.NewObject()
+			if err := decode(key, oldValue, oldObj); err != nil {
+				return nil, err
+			}
+			oldIndexValue = indexer.IndexFunc(oldObj)
+		}
+
+		newIndexValue := ""
+		if obj != nil {
+			newIndexValue = indexer.IndexFunc(obj)
+		}
+
+		if oldIndexValue != "" && oldIndexValue != newIndexValue {
+			ops = append(ops, clientv3.OpDelete(indexKey(indexer.Name, oldIndexValue, key)))
+		}
+		if newIndexValue != "" {
+			ops = append(ops, clientv3.OpPut(indexKey(indexer.Name, newIndexValue, key), string(data)))
+		}
+	}
+	return ops, nil
+}
+
+// putIndexed writes obj at key together with its index entries, and reports whether the key
+// was created. The write is retried if the key changes between reading the previous value and
+// committing, unless a revision the stored object must be at is given, in which case
+// ErrConflict is returned.
+func (s *EtcdStorage) putIndexed(ctx context.Context, key string, obj runtime.Object, data []byte, indexers []Indexer, revision int64) (bool, error) {
+	for {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		if revision != 0 && (len(resp.Kvs) == 0 || resp.Kvs[0].ModRevision != revision) {
+			return false, fmt.Errorf("%w: %s is no longer at resource version %d", ErrConflict, key, revision)
+		}
+
+		var oldValue []byte
+		cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		if len(resp.Kvs) > 0 {
+			oldValue = resp.Kvs[0].Value
+			cmp = clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)
+		}
+
+		ops, err := indexOps(indexers, key, oldValue, obj, data)
+		if err != nil {
+			return false, err
+		}
+
+		txnResp, err := s.client.Txn(ctx).
+			If(cmp).
+			Then(append([]clientv3.Op{clientv3.OpPut(key, string(data))}, ops...)...).
+			Commit()
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		if txnResp.Succeeded {
+			setResourceVersion(obj, txnResp.Header.Revision)
+			return len(resp.Kvs) == 0, nil
+		}
+	}
+}
+
+// deleteIndexed deletes key together with its index entries. Unless revision is 0, the stored
+// object must be at that revision, ErrConflict or ErrNotFound is returned otherwise.
+func (s *EtcdStorage) deleteIndexed(ctx context.Context, key string, indexers []Indexer, revision int64) error {
+	for {
+		resp, err := s.client.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		if len(resp.Kvs) == 0 {
+			if revision != 0 {
+				return fmt.Errorf("%w: %s", ErrNotFound, key)
+			}
+			return nil
+		}
+		if revision != 0 && resp.Kvs[0].ModRevision != revision {
+			return fmt.Errorf("%w: %s is no longer at resource version %d", ErrConflict, key, revision)
+		}
+
+		ops, err := indexOps(indexers, key, resp.Kvs[0].Value, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		txnResp, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+			Then(append([]clientv3.Op{clientv3.OpDelete(key)}, ops...)...).
+			Commit()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gokube/pkg/runtime"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type indexedObject struct {
+	Name            string `json:"name"`
+	Group           string `json:"group"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+func (o *indexedObject) GetResourceVersion() string        { return o.ResourceVersion }
+func (o *indexedObject) SetResourceVersion(version string) { o.ResourceVersion = version }
+
+func addGroupIndexer(storage *EtcdStorage) {
+	storage.AddIndexer(Indexer{
+		Name:      "by-group",
+		Prefix:    "/objects/",
+		NewObject: func() runtime.Object { return &indexedObject{} },
+		IndexFunc: func(obj runtime.Object) string { return obj.(*indexedObject).Group },
+	})
+}
+
+func listGroup(t *testing.T, storage *EtcdStorage, group string) []string {
+	var objects []*indexedObject
+	require.NoError(t, storage.ListByIndex(context.Background(), "by-group", group, &objects))
+	names := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		names = append(names, obj.Name)
+	}
+	return names
+}
+
+func TestEtcdStorage_Index(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		addGroupIndexer(storage)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, storage.Create(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g1"}))
+		require.NoError(t, storage.Create(ctx, "/objects/b", &indexedObject{Name: "b", Group: "g2"}))
+		require.NoError(t, storage.Create(ctx, "/objects/c", &indexedObject{Name: "c"}))
+		assert.Equal(t, []string{"a"}, listGroup(t, storage, "g1"))
+		assert.Equal(t, []string{"b"}, listGroup(t, storage, "g2"))
+
+		// Update moves the object between index values
+		require.NoError(t, storage.Update(ctx, "/objects/b", &indexedObject{Name: "b", Group: "g1"}))
+		assert.Equal(t, []string{"a", "b"}, listGroup(t, storage, "g1"))
+		assert.Empty(t, listGroup(t, storage, "g2"))
+
+		// GuaranteedUpdate keeps the index in sync
+		obj := &indexedObject{}
+		require.NoError(t, storage.GuaranteedUpdate(ctx, "/objects/c", obj, func(obj runtime.Object) error {
+			obj.(*indexedObject).Group = "g2"
+			return nil
+		}))
+		assert.Equal(t, []string{"c"}, listGroup(t, storage, "g2"))
+
+		// Index entries hold the latest version of the object
+		require.NoError(t, storage.Update(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g1"}))
+		var objects []*indexedObject
+		require.NoError(t, storage.ListByIndex(ctx, "by-group", "g1", &objects))
+		require.Len(t, objects, 2)
+
+		// Delete removes the index entries
+		require.NoError(t, storage.Delete(ctx, "/objects/a"))
+		assert.Equal(t, []string{"b"}, listGroup(t, storage, "g1"))
+
+		require.NoError(t, storage.DeletePrefix(ctx, "/objects/"))
+		assert.Empty(t, listGroup(t, storage, "g1"))
+		assert.Empty(t, listGroup(t, storage, "g2"))
+	})
+}
+
+func TestEtcdStorage_ListByIndexUnknown(t *testing.T) {
+	storage := NewEtcdStorage(nil)
+
+	var objects []*indexedObject
+	err := storage.ListByIndex(context.Background(), "missing", "value", &objects)
+	assert.Error(t, err)
+}
+
+func TestEtcdStorage_IndexBackfill(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		indexed := NewEtcdStorage(cli)
+		addGroupIndexer(indexed)
+		require.NoError(t, indexed.Create(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g1"}))
+
+		// A storage without the index moves a to another group and stores b unindexed
+		unindexed := NewEtcdStorage(cli)
+		require.NoError(t, unindexed.Update(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g2"}))
+		require.NoError(t, unindexed.Create(ctx, "/objects/b", &indexedObject{Name: "b", Group: "g1"}))
+
+		storage := NewEtcdStorage(cli)
+		addGroupIndexer(storage)
+		assert.Equal(t, []string{"b"}, listGroup(t, storage, "g1"))
+		assert.Equal(t, []string{"a"}, listGroup(t, storage, "g2"))
+	})
+}
+
+func TestEtcdStorage_IndexBackfillKeepsResourceVersions(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		unindexed := NewEtcdStorage(cli)
+		require.NoError(t, unindexed.Create(ctx, "/objects/a", &indexedObject{Name: "a", Group: "g1"}))
+		stored := &indexedObject{}
+		require.NoError(t, unindexed.Get(ctx, "/objects/a", stored))
+
+		// Objects listed from backfilled entries carry the revision of the object
+		storage := NewEtcdStorage(cli)
+		addGroupIndexer(storage)
+		var objects []*indexedObject
+		require.NoError(t, storage.ListByIndex(ctx, "by-group", "g1", &objects))
+		require.Len(t, objects, 1)
+		assert.Equal(t, stored.ResourceVersion, objects[0].ResourceVersion)
+
+		revision, err := ResourceVersionOf(objects[0])
+		require.NoError(t, err)
+		objects[0].Group = "g2"
+		require.NoError(t, storage.UpdateAtRevision(ctx, "/objects/a", objects[0], revision))
+
+		// Backfilling an up to date index leaves its entries alone
+		entry := indexKey("by-group", "g2", "/objects/a")
+		before, err := cli.Get(ctx, entry)
+		require.NoError(t, err)
+		require.Len(t, before.Kvs, 1)
+
+		restarted := NewEtcdStorage(cli)
+		addGroupIndexer(restarted)
+		assert.Equal(t, []string{"a"}, listGroup(t, restarted, "g2"))
+
+		after, err := cli.Get(ctx, entry)
+		require.NoError(t, err)
+		require.Len(t, after.Kvs, 1)
+		assert.Equal(t, before.Kvs[0].ModRevision, after.Kvs[0].ModRevision)
+	})
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Storage operations, used as the operation label of the storage metrics
+const (
+	operationCreate           = "create"
+	operationCreateWithTTL    = "create_with_ttl"
+	operationGet              = "get"
+	operationUpdate           = "update"
+	operationCreateOrUpdate   = "create_or_update"
+	operationGuaranteedUpdate = "guaranteed_update"
+	operationDelete           = "delete"
+	operationDeletePrefix     = "delete_prefix"
+	operationList             = "list"
+	operationWatch            = "watch"
+)
+
+// Outcomes of storage operations, used as the result label of the storage metrics
+const (
+	resultSuccess  = "success"
+	resultNotFound = "not_found"
+	resultError    = "error"
+)
+
+type storageMetrics struct {
+	operations        *prometheus.CounterVec
+	operationDuration *prometheus.HistogramVec
+}
+
+// newStorageMetrics creates the storage metrics and registers them with the registerer
+func newStorageMetrics(registerer prometheus.Registerer) (*storageMetrics, error) {
+	m := &storageMetrics{
+		operations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "storage_operations_total",
+				Help: "Total number of storage operations by operation and result",
+			},
+			[]string{"operation", "result"},
+		),
+		operationDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "storage_operation_duration_seconds",
+				Help:    "Duration of storage operations in seconds by operation and result",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"operation", "result"},
+		),
+	}
+
+	for _, collector := range []prometheus.Collector{m.operations, m.operationDuration} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register storage metrics: %v", err)
+		}
+	}
+	return m, nil
+}
+
+// observe records the duration and outcome of an operation started at start.
+// It is meant to be deferred with a pointer to the operation's named error result.
+func (m *storageMetrics) observe(operation string, start time.Time, err *error) {
+	if m == nil {
+		return
+	}
+
+	result := resultSuccess
+	switch {
+	case *err == nil:
+	case errors.Is(*err, ErrNotFound):
+		result = resultNotFound
+	default:
+		result = resultError
+	}
+
+	m.operations.WithLabelValues(operation, result).Inc()
+	m.operationDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdStorage_Metrics(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		registry := prometheus.NewRegistry()
+		storage, err := NewEtcdStorageWithMetrics(cli, registry)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		operations := func(operation, result string) float64 {
+			return testutil.ToFloat64(storage.metrics.operations.WithLabelValues(operation, result))
+		}
+
+		require.NoError(t, storage.Create(ctx, "/metrics/a", &TestObject{Name: "a"}))
+		require.NoError(t, storage.Update(ctx, "/metrics/a", &TestObject{Name: "b"}))
+		require.NoError(t, storage.Get(ctx, "/metrics/a", &TestObject{}))
+		require.Error(t, storage.Get(ctx, "/metrics/missing", &TestObject{}))
+		require.NoError(t, storage.List(ctx, "/metrics/", &[]*TestObject{}))
+		require.Error(t, storage.List(ctx, "/metrics/", []*TestObject{}))
+		require.NoError(t, storage.Delete(ctx, "/metrics/a"))
+
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		_, err = storage.Watch(watchCtx, "/metrics/")
+		require.NoError(t, err)
+		stopWatch()
+
+		assert.Equal(t, 1.0, operations(operationCreate, resultSuccess))
+		assert.Equal(t, 1.0, operations(operationUpdate, resultSuccess))
+		assert.Equal(t, 1.0, operations(operationGet, resultSuccess))
+		assert.Equal(t, 1.0, operations(operationGet, resultNotFound))
+		assert.Equal(t, 1.0, operations(operationList, resultSuccess))
+		assert.Equal(t, 1.0, operations(operationList, resultError))
+		assert.Equal(t, 1.0, operations(operationDelete, resultSuccess))
+		assert.Equal(t, 1.0, operations(operationWatch, resultSuccess))
+
+		// Every operation's latency is observed under the same labels as its counter
+		assert.Equal(t, 8, testutil.CollectAndCount(storage.metrics.operationDuration))
+		count, err := testutil.GatherAndCount(registry, "storage_operation_duration_seconds")
+		require.NoError(t, err)
+		assert.Equal(t, 8, count)
+	})
+}
+
+func TestNewEtcdStorageWithMetrics_AlreadyRegistered(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	_, err := NewEtcdStorageWithMetrics(nil, registry)
+	require.NoError(t, err)
+
+	_, err = NewEtcdStorageWithMetrics(nil, registry)
+	assert.ErrorContains(t, err, "failed to register storage metrics")
+}
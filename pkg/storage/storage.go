@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"gokube/pkg/runtime"
 )
@@ -11,9 +12,45 @@ import (
 //go:generate $PROJECT_HOME/bin/mock mocks/pkg/storage
 type Storage interface {
 	Create(ctx context.Context, key string, obj runtime.Object) error
+	// CreateWithTTL creates objects that expire and are removed after ttl. Unlike Create it
+	// never overwrites a stored object, it returns ErrAlreadyExists instead.
+	CreateWithTTL(ctx context.Context, key string, obj runtime.Object, ttl time.Duration) error
 	Get(ctx context.Context, key string, obj runtime.Object) error
+	// Update writes obj at key, whatever the resource version of the stored object
 	Update(ctx context.Context, key string, obj runtime.Object) error
+	// UpdateAtRevision is Update that only writes obj if the stored object is still at the
+	// revision, ErrConflict is returned if it was modified or deleted since
+	UpdateAtRevision(ctx context.Context, key string, obj runtime.Object, revision int64) error
+	// CreateOrUpdate writes obj at key, creating it if it doesn't exist and replacing it
+	// otherwise, and reports whether it was created. Before replacing a stored object,
+	// preserve, unless nil, is called with it so that obj can keep fields of it.
+	CreateOrUpdate(ctx context.Context, key string, obj runtime.Object, preserve func(stored runtime.Object)) (bool, error)
 	Delete(ctx context.Context, key string) error
+	// DeleteAtRevision is Delete that only deletes the object if it is still at the revision,
+	// ErrConflict is returned if it was modified since and ErrNotFound if it is gone
+	DeleteAtRevision(ctx context.Context, key string, revision int64) error
 	DeletePrefix(ctx context.Context, prefix string) error
+	// List replaces the contents of the slice pointed to by listObj with the objects under prefix
 	List(ctx context.Context, prefix string, listObj interface{}) error
+	// ListPaged lists at most limit objects under prefix into listObj, starting after the
+	// object the continue token was returned for. The returned token continues the listing, it
+	// is empty once the listing is complete. A limit of 0 lists all remaining objects.
+	ListPaged(ctx context.Context, prefix string, limit int64, continueToken string, listObj interface{}) (string, error)
+	// ListStream lists the objects under prefix page by page, sending them one at a time on the
+	// object channel in key order. The error channel receives the error that stopped the
+	// listing, if any, once the object channel is closed.
+	ListStream(ctx context.Context, prefix string, newFunc func() runtime.Object) (<-chan runtime.Object, <-chan error)
+	// ListWithMeta is List that also returns the revisions of every object and of the list
+	ListWithMeta(ctx context.Context, prefix string, listObj interface{}) ([]ItemMeta, int64, error)
+	// CurrentRevision returns the latest revision of the store. Watching from it misses no
+	// later change.
+	CurrentRevision(ctx context.Context) (int64, error)
+	GuaranteedUpdate(ctx context.Context, key string, obj runtime.Object, tryUpdate func(obj runtime.Object) error) error
+	Watch(ctx context.Context, prefix string) (<-chan WatchEvent, error)
+	// WatchWithOptions is Watch with the watch channel configured by the options
+	WatchWithOptions(ctx context.Context, prefix string, opts WatchOptions) (<-chan WatchEvent, error)
+	WatchFromRevision(ctx context.Context, prefix string, revision int64, opts WatchOptions) (<-chan WatchEvent, error)
+	WatchFiltered(ctx context.Context, prefix string, types ...EventType) (<-chan WatchEvent, error)
+	// WatchKey watches a single key rather than a prefix
+	WatchKey(ctx context.Context, key string, opts WatchOptions) (<-chan WatchEvent, error)
 }
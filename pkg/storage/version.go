@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+
+	"gokube/pkg/runtime"
+)
+
+// The resource version of an object is the etcd revision its stored value was last modified at.
+// It is set on the objects read and written by the storage. Update ignores the resource version
+// an object carries and overwrites the stored object. UpdateAtRevision is the compare-and-swap
+// write: it only succeeds while the stored object is still at the given revision.
+
+// setResourceVersion sets the resource version of obj, if it carries one
+func setResourceVersion(obj runtime.Object, revision int64) {
+	if versioned, ok := obj.(runtime.Versioned); ok {
+		versioned.SetResourceVersion(strconv.FormatInt(revision, 10))
+	}
+}
+
+// ResourceVersionOf returns the resource version carried by obj, or 0 if it carries none.
+// ErrConflict is returned for a resource version that isn't a revision.
+func ResourceVersionOf(obj runtime.Object) (int64, error) {
+	versioned, ok := obj.(runtime.Versioned)
+	if !ok || versioned.GetResourceVersion() == "" {
+		return 0, nil
+	}
+
+	revision, err := strconv.ParseInt(versioned.GetResourceVersion(), 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, fmt.Errorf("%w: invalid resource version %q", ErrConflict, versioned.GetResourceVersion())
+	}
+	return revision, nil
+}
+
+// encode encodes obj without its resource version, which describes the stored value rather
+// than being part of it
+func encode(obj runtime.Object) ([]byte, error) {
+	if versioned, ok := obj.(runtime.Versioned); ok && versioned.GetResourceVersion() != "" {
+		version := versioned.GetResourceVersion()
+		versioned.SetResourceVersion("")
+		defer versioned.SetResourceVersion(version)
+	}
+	return runtime.Encode(obj)
+}
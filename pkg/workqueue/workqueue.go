@@ -0,0 +1,170 @@
+// Package workqueue provides a queue of items, such as the keys of objects to reconcile, for
+// controllers that process them with several workers.
+//
+// An item added while it is queued is queued once, and an item is never handed to two workers at
+// once: added while it is processed, it is queued again once the worker is done with it. Items
+// whose processing failed are requeued with exponential backoff by AddRateLimited, and items to
+// process again at a later time are added with AddAfter.
+package workqueue
+
+import (
+	"sync"
+	"time"
+
+	"gokube/pkg/retry"
+)
+
+// Queue is a work queue of items of type T
+type Queue[T comparable] struct {
+	backoff retry.Options
+
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	items      []T
+	queued     map[T]bool
+	processing map[T]bool
+	// failures counts the consecutive AddRateLimited calls for an item since it was forgotten
+	failures map[T]int
+	shutdown bool
+}
+
+// New creates a Queue that requeues failed items with the default retry backoff
+func New[T comparable]() *Queue[T] {
+	return NewWithOptions[T](retry.DefaultOptions())
+}
+
+// NewWithOptions creates a Queue that requeues failed items after InitialDelay, multiplying
+// the delay by Multiplier for every consecutive failure up to MaxDelay
+func NewWithOptions[T comparable](backoff retry.Options) *Queue[T] {
+	q := &Queue[T]{
+		backoff:    backoff,
+		queued:     make(map[T]bool),
+		processing: make(map[T]bool),
+		failures:   make(map[T]int),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+// Add queues the item unless it is queued already
+func (q *Queue[T]) Add(item T) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.shutdown || q.queued[item] {
+		return
+	}
+	q.queued[item] = true
+	if q.processing[item] {
+		// Queued by Done, once the item is processed
+		return
+	}
+	q.items = append(q.items, item)
+	q.cond.Signal()
+}
+
+// Get blocks until an item is queued and returns it, the caller must call Done with the item
+// once it is processed. It returns false once the queue is shut down.
+func (q *Queue[T]) Get() (T, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for len(q.items) == 0 && !q.shutdown {
+		q.cond.Wait()
+	}
+	if q.shutdown {
+		var zero T
+		return zero, false
+	}
+
+	item := q.items[0]
+	q.items = q.items[1:]
+	delete(q.queued, item)
+	q.processing[item] = true
+	return item, true
+}
+
+// Done marks the item as processed, queueing it again if it was added while it was processed
+func (q *Queue[T]) Done(item T) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	delete(q.processing, item)
+	if q.queued[item] && !q.shutdown {
+		q.items = append(q.items, item)
+		q.cond.Signal()
+	}
+}
+
+// AddRateLimited adds the item once its backoff delay has passed. The delay grows with every
+// call for the item until Forget is called for it.
+func (q *Queue[T]) AddRateLimited(item T) {
+	q.AddAfter(item, q.nextDelay(item))
+}
+
+// AddAfter adds the item once the delay has passed, or right away for a delay that isn't
+// positive. The delay is waited for with the After clock of the backoff options, if set.
+func (q *Queue[T]) AddAfter(item T, delay time.Duration) {
+	if delay <= 0 {
+		q.Add(item)
+		return
+	}
+
+	after := time.After
+	if q.backoff.After != nil {
+		after = q.backoff.After
+	}
+	passed := after(delay)
+	go func() {
+		<-passed
+		q.Add(item)
+	}()
+}
+
+// nextDelay returns the backoff delay of the item and counts the failure
+func (q *Queue[T]) nextDelay(item T) time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	delay := q.backoff.InitialDelay
+	for i := 0; i < q.failures[item] && delay < q.backoff.MaxDelay; i++ {
+		delay = time.Duration(float64(delay) * q.backoff.Multiplier)
+	}
+	if q.backoff.MaxDelay > 0 && delay > q.backoff.MaxDelay {
+		delay = q.backoff.MaxDelay
+	}
+	q.failures[item]++
+	return delay
+}
+
+// Forget resets the backoff delay of the item, typically once it was processed successfully.
+// It doesn't remove the item from the queue.
+func (q *Queue[T]) Forget(item T) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.failures, item)
+}
+
+// NumRequeues returns how many times the item was added with AddRateLimited since it was
+// last forgotten
+func (q *Queue[T]) NumRequeues(item T) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.failures[item]
+}
+
+// Len returns the number of queued items, not counting the items being processed
+func (q *Queue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+// ShutDown makes Get return false to all current and future callers and ignores later adds
+func (q *Queue[T]) ShutDown() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.shutdown = true
+	q.cond.Broadcast()
+}
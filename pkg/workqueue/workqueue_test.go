@@ -0,0 +1,194 @@
+package workqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/retry"
+)
+
+// fakeClock is the After clock of a queue, whose delays pass once Step moves the clock past them
+type fakeClock struct {
+	current time.Duration
+	timers  []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Duration
+	ch chan time.Time
+}
+
+// options returns the default backoff options waiting with the clock
+func (c *fakeClock) options() retry.Options {
+	opts := retry.DefaultOptions()
+	opts.After = func(delay time.Duration) <-chan time.Time {
+		timer := fakeTimer{at: c.current + delay, ch: make(chan time.Time, 1)}
+		c.timers = append(c.timers, timer)
+		return timer.ch
+	}
+	return opts
+}
+
+// Step advances the clock and fires the timers that became due
+func (c *fakeClock) Step(d time.Duration) {
+	c.current += d
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at <= c.current {
+			timer.ch <- time.Time{}
+		} else {
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("coalesces items added while queued", func(t *testing.T) {
+		queue := New[string]()
+		queue.Add("a")
+		queue.Add("b")
+		queue.Add("a")
+		assert.Equal(t, 2, queue.Len())
+
+		item, ok := queue.Get()
+		require.True(t, ok)
+		assert.Equal(t, "a", item)
+		queue.Done(item)
+		item, ok = queue.Get()
+		require.True(t, ok)
+		assert.Equal(t, "b", item)
+		queue.Done(item)
+		assert.Zero(t, queue.Len())
+	})
+
+	t.Run("does not hand out an item while it is processed", func(t *testing.T) {
+		queue := New[string]()
+		queue.Add("a")
+		item, _ := queue.Get()
+
+		queue.Add("a")
+		got := make(chan string, 1)
+		go func() {
+			item, _ := queue.Get()
+			got <- item
+		}()
+
+		select {
+		case item := <-got:
+			t.Fatalf("item %s handed out while processed", item)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		queue.Done(item)
+		select {
+		case item := <-got:
+			assert.Equal(t, "a", item)
+		case <-time.After(time.Second):
+			t.Fatal("item added while processed was not queued once done")
+		}
+	})
+
+	t.Run("shutdown releases waiting workers", func(t *testing.T) {
+		queue := New[int]()
+		done := make(chan bool, 1)
+		go func() {
+			_, ok := queue.Get()
+			done <- ok
+		}()
+
+		queue.ShutDown()
+		select {
+		case ok := <-done:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("Get did not return after shutdown")
+		}
+	})
+}
+
+func TestQueue_AddRateLimited(t *testing.T) {
+	backoff := retry.Options{
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     40 * time.Millisecond,
+		Multiplier:   2,
+	}
+
+	t.Run("grows the delay up to the maximum", func(t *testing.T) {
+		queue := NewWithOptions[string](backoff)
+
+		var delays []time.Duration
+		for range 5 {
+			delays = append(delays, queue.nextDelay("a"))
+		}
+		assert.Equal(t, []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+			40 * time.Millisecond,
+			40 * time.Millisecond,
+		}, delays)
+		assert.Equal(t, 5, queue.NumRequeues("a"))
+
+		// Other items have their own delay
+		assert.Equal(t, 10*time.Millisecond, queue.nextDelay("b"))
+	})
+
+	t.Run("forget resets the delay", func(t *testing.T) {
+		queue := NewWithOptions[string](backoff)
+		queue.nextDelay("a")
+		queue.nextDelay("a")
+
+		queue.Forget("a")
+		assert.Zero(t, queue.NumRequeues("a"))
+		assert.Equal(t, 10*time.Millisecond, queue.nextDelay("a"))
+	})
+
+	t.Run("queues the item once the delay passed", func(t *testing.T) {
+		queue := NewWithOptions[string](retry.Options{
+			InitialDelay: 100 * time.Millisecond,
+			MaxDelay:     time.Second,
+			Multiplier:   2,
+		})
+
+		start := time.Now()
+		queue.AddRateLimited("a")
+		assert.Zero(t, queue.Len())
+
+		item, ok := queue.Get()
+		require.True(t, ok)
+		assert.Equal(t, "a", item)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+		assert.Equal(t, 1, queue.NumRequeues("a"))
+	})
+}
+
+func TestQueue_AddAfter(t *testing.T) {
+	t.Run("queues the item once the delay passed", func(t *testing.T) {
+		clock := &fakeClock{}
+		queue := NewWithOptions[string](clock.options())
+
+		queue.AddAfter("a", time.Minute)
+		assert.Zero(t, queue.Len())
+
+		clock.Step(time.Minute - time.Second)
+		assert.Zero(t, queue.Len())
+
+		clock.Step(time.Second)
+		require.Eventually(t, func() bool { return queue.Len() == 1 }, time.Second, time.Millisecond)
+		item, ok := queue.Get()
+		require.True(t, ok)
+		assert.Equal(t, "a", item)
+	})
+
+	t.Run("queues the item right away without a delay", func(t *testing.T) {
+		clock := &fakeClock{}
+		queue := NewWithOptions[string](clock.options())
+
+		queue.AddAfter("a", 0)
+		assert.Equal(t, 1, queue.Len())
+	})
+}
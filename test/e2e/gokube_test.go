@@ -120,7 +120,7 @@ func setupTestCluster(t *testing.T) *TestCluster {
 	serverURL := "localhost:" + strconv.Itoa(port)
 	//TODO: Is this the idiomatic way to handle errors in goroutines?
 	go func() {
-		err := apiServer.Start(serverURL)
+		err := apiServer.Start(ctx, serverURL)
 		if err != nil {
 			t.Errorf("Failed to start API server: %v", err)
 		}
@@ -131,7 +131,9 @@ func setupTestCluster(t *testing.T) *TestCluster {
 	}
 	t.Log("API Server started at:", serverURL)
 
-	cntr := controller.NewReplicaSetController(replicaSetRegistry, registry.NewPodRegistry(etcdStorage))
+	controllerOpts := controller.DefaultOptions()
+	controllerOpts.Endpoints = []string{etcdServer.Config().ListenClientUrls[0].String()}
+	cntr := controller.NewReplicaSetControllerWithOptions(replicaSetRegistry, registry.NewPodRegistry(etcdStorage), controllerOpts)
 	go cntr.Start(ctx)
 
 	schdlr := scheduler.NewScheduler(registry.NewPodRegistry(etcdStorage), registry.NewNodeRegistry(etcdStorage), 1*time.Second)
@@ -304,7 +306,7 @@ func waitForPodsRunning(apiServerURL string, selector map[string]string, expecte
 
 			runningCount := 0
 			for _, pod := range pods {
-				if matchesSelector(pod) && (pod.Status == api.PodRunning || pod.Status == api.PodSucceeded) {
+				if matchesSelector(pod) && (pod.Status.Phase == api.PodRunning || pod.Status.Phase == api.PodSucceeded) {
 					runningCount++
 				}
 			}